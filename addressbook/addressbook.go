@@ -0,0 +1,182 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package addressbook maps human readable labels to ethereum addresses.
+package addressbook
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// lookalikeChars is the amount of leading and trailing hex characters compared when looking for lookalike addresses.
+// Most wallets and explorers abbreviate addresses to the first and last four characters.
+const lookalikeChars = 4
+
+// ErrInvalidChecksum is returned when a mixed case address does not match its EIP-55 checksum.
+var ErrInvalidChecksum = errors.New("address checksum is invalid")
+
+// ErrLabelNotFound is returned when no address is stored under the given label.
+var ErrLabelNotFound = errors.New("label not found")
+
+// Entry represents a single address book entry.
+type Entry struct {
+	Label   string
+	Address common.Address
+}
+
+// Storage is given to the AddressBook to persist entries.
+type Storage interface {
+	// UpsertAddressBookEntry inserts a new entry or updates an existing entry with the same label.
+	UpsertAddressBookEntry(e Entry) error
+
+	// DeleteAddressBookEntry removes the entry with the given label.
+	DeleteAddressBookEntry(label string) error
+
+	// GetAddressBookEntries returns all the stored entries.
+	GetAddressBookEntries() ([]Entry, error)
+}
+
+// AddressBook keeps track of labeled addresses.
+type AddressBook struct {
+	storage Storage
+	lock    sync.Mutex
+}
+
+// NewAddressBook returns a new instance of address book.
+func NewAddressBook(storage Storage) *AddressBook {
+	return &AddressBook{
+		storage: storage,
+	}
+}
+
+// ParseAddress parses the given hex address.
+// Mixed case addresses are validated against their EIP-55 checksum, all lower or all upper case addresses are accepted as is.
+func ParseAddress(address string) (common.Address, error) {
+	if !common.IsHexAddress(address) {
+		return common.Address{}, fmt.Errorf("%q is not a hex address", address)
+	}
+
+	addr := common.HexToAddress(address)
+	body := strings.TrimPrefix(strings.TrimPrefix(address, "0x"), "0X")
+	if body == strings.ToLower(body) || body == strings.ToUpper(body) {
+		return addr, nil
+	}
+
+	if strings.TrimPrefix(addr.Hex(), "0x") != body {
+		return common.Address{}, ErrInvalidChecksum
+	}
+
+	return addr, nil
+}
+
+// Add stores the given address under the given label, replacing any address previously stored under it.
+// Entries with other labels that look alike the given address are returned so that the caller can warn the user.
+func (ab *AddressBook) Add(label, address string) (lookalikes []Entry, err error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return nil, errors.New("label can not be empty")
+	}
+
+	addr, err := ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	ab.lock.Lock()
+	defer ab.lock.Unlock()
+
+	entries, err := ab.storage.GetAddressBookEntries()
+	if err != nil {
+		return nil, fmt.Errorf("could not get address book entries: %w", err)
+	}
+
+	for _, e := range entries {
+		if e.Label != label && looksAlike(e.Address, addr) {
+			lookalikes = append(lookalikes, e)
+		}
+	}
+
+	if err := ab.storage.UpsertAddressBookEntry(Entry{Label: label, Address: addr}); err != nil {
+		return nil, fmt.Errorf("could not store address book entry: %w", err)
+	}
+
+	return lookalikes, nil
+}
+
+// Remove removes the entry with the given label.
+func (ab *AddressBook) Remove(label string) error {
+	ab.lock.Lock()
+	defer ab.lock.Unlock()
+
+	return ab.storage.DeleteAddressBookEntry(label)
+}
+
+// Resolve returns the address for the given label.
+// If the given value is an address itself, it is parsed and returned instead.
+func (ab *AddressBook) Resolve(labelOrAddress string) (common.Address, error) {
+	if common.IsHexAddress(labelOrAddress) {
+		return ParseAddress(labelOrAddress)
+	}
+
+	entries, err := ab.storage.GetAddressBookEntries()
+	if err != nil {
+		return common.Address{}, fmt.Errorf("could not get address book entries: %w", err)
+	}
+
+	for _, e := range entries {
+		if e.Label == labelOrAddress {
+			return e.Address, nil
+		}
+	}
+
+	return common.Address{}, ErrLabelNotFound
+}
+
+// Label returns the label for the given address if it is known.
+func (ab *AddressBook) Label(address common.Address) (string, bool, error) {
+	entries, err := ab.storage.GetAddressBookEntries()
+	if err != nil {
+		return "", false, fmt.Errorf("could not get address book entries: %w", err)
+	}
+
+	for _, e := range entries {
+		if e.Address == address {
+			return e.Label, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// Entries returns all the address book entries.
+func (ab *AddressBook) Entries() ([]Entry, error) {
+	return ab.storage.GetAddressBookEntries()
+}
+
+// looksAlike checks if the two different addresses share the same abbreviated form.
+func looksAlike(a, b common.Address) bool {
+	if a == b {
+		return false
+	}
+
+	ah, bh := strings.ToLower(a.Hex()[2:]), strings.ToLower(b.Hex()[2:])
+	return ah[:lookalikeChars] == bh[:lookalikeChars] && ah[len(ah)-lookalikeChars:] == bh[len(bh)-lookalikeChars:]
+}
@@ -0,0 +1,93 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package addressbook
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockStorage struct {
+	entries []Entry
+}
+
+func (ms *mockStorage) UpsertAddressBookEntry(e Entry) error {
+	for i := range ms.entries {
+		if ms.entries[i].Label == e.Label {
+			ms.entries[i] = e
+			return nil
+		}
+	}
+	ms.entries = append(ms.entries, e)
+	return nil
+}
+
+func (ms *mockStorage) DeleteAddressBookEntry(label string) error {
+	for i := range ms.entries {
+		if ms.entries[i].Label == label {
+			ms.entries = append(ms.entries[:i], ms.entries[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (ms *mockStorage) GetAddressBookEntries() ([]Entry, error) {
+	return ms.entries, nil
+}
+
+func TestParseAddress(t *testing.T) {
+	_, err := ParseAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	assert.NoError(t, err)
+
+	_, err = ParseAddress("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed")
+	assert.NoError(t, err)
+
+	_, err = ParseAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD")
+	assert.Equal(t, ErrInvalidChecksum, err)
+
+	_, err = ParseAddress("0x5aAeb")
+	assert.Error(t, err)
+}
+
+func TestAddressBook(t *testing.T) {
+	ab := NewAddressBook(&mockStorage{})
+
+	lookalikes, err := ab.Add("hermes", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	assert.NoError(t, err)
+	assert.Len(t, lookalikes, 0)
+
+	lookalikes, err = ab.Add("not hermes", "0x5aaeb0000000000000000000000000000000eaed")
+	assert.NoError(t, err)
+	assert.Len(t, lookalikes, 1)
+	assert.Equal(t, "hermes", lookalikes[0].Label)
+
+	addr, err := ab.Resolve("hermes")
+	assert.NoError(t, err)
+	assert.Equal(t, common.HexToAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"), addr)
+
+	label, ok, err := ab.Label(addr)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "hermes", label)
+
+	assert.NoError(t, ab.Remove("hermes"))
+	_, err = ab.Resolve("hermes")
+	assert.Equal(t, ErrLabelNotFound, err)
+}
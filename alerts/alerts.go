@@ -0,0 +1,159 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package alerts evaluates threshold based alert rules and dispatches triggered alerts to a notifier.
+package alerts
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Severity represents the importance of an alert.
+type Severity string
+
+const (
+	// SeverityWarning marks alerts that need attention.
+	SeverityWarning Severity = "warning"
+	// SeverityCritical marks alerts that need immediate action.
+	SeverityCritical Severity = "critical"
+)
+
+// Alert is produced by a rule whose threshold was crossed.
+type Alert struct {
+	Rule     string
+	Severity Severity
+	Message  string
+	Time     time.Time
+}
+
+// Rule checks a single condition.
+type Rule interface {
+	// Name uniquely identifies the rule.
+	Name() string
+	// Evaluate returns a non nil alert if the rule is triggered.
+	Evaluate(now time.Time) (*Alert, error)
+}
+
+// Notifier delivers triggered alerts.
+type Notifier interface {
+	Notify(alert Alert) error
+}
+
+// NotifierFunc allows using ordinary functions as notifiers.
+type NotifierFunc func(alert Alert) error
+
+// Notify calls f(alert).
+func (f NotifierFunc) Notify(alert Alert) error {
+	return f(alert)
+}
+
+// LogFunc is called with errors that occur during rule evaluation and notification.
+type LogFunc func(error)
+
+// Engine periodically evaluates the rules.
+// An alert is dispatched once when the rule triggers and is not repeated until the rule resolves or the renotify interval passes.
+type Engine struct {
+	rules    []Rule
+	notifier Notifier
+	interval time.Duration
+	renotify time.Duration
+	logFunc  LogFunc
+	now      func() time.Time
+
+	lock   sync.Mutex
+	firing map[string]time.Time
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewEngine returns a new alerting engine.
+// If renotify is zero, alerts are only repeated after the rule resolves.
+func NewEngine(notifier Notifier, interval, renotify time.Duration, rules ...Rule) *Engine {
+	return &Engine{
+		rules:    rules,
+		notifier: notifier,
+		interval: interval,
+		renotify: renotify,
+		logFunc:  func(error) {},
+		now:      time.Now,
+		firing:   make(map[string]time.Time),
+		stop:     make(chan struct{}),
+	}
+}
+
+// AttachLogFunc attaches a log func to the engine.
+// Not thread safe, call before Run.
+func (e *Engine) AttachLogFunc(f LogFunc) {
+	e.logFunc = f
+}
+
+// Run evaluates the rules until stopped.
+func (e *Engine) Run() {
+	for {
+		e.Evaluate()
+
+		select {
+		case <-e.stop:
+			return
+		case <-time.After(e.interval):
+		}
+	}
+}
+
+// Stop stops the engine.
+func (e *Engine) Stop() {
+	e.once.Do(func() {
+		close(e.stop)
+	})
+}
+
+// Evaluate evaluates all the rules once and returns the dispatched alerts.
+func (e *Engine) Evaluate() []Alert {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	now := e.now()
+	var res []Alert
+	for _, rule := range e.rules {
+		alert, err := rule.Evaluate(now)
+		if err != nil {
+			e.logFunc(fmt.Errorf("could not evaluate rule %v: %w", rule.Name(), err))
+			continue
+		}
+
+		if alert == nil {
+			delete(e.firing, rule.Name())
+			continue
+		}
+
+		if last, ok := e.firing[rule.Name()]; ok && (e.renotify == 0 || now.Sub(last) < e.renotify) {
+			continue
+		}
+
+		if err := e.notifier.Notify(*alert); err != nil {
+			e.logFunc(fmt.Errorf("could not dispatch alert of rule %v: %w", rule.Name(), err))
+			continue
+		}
+
+		e.firing[rule.Name()] = now
+		res = append(res, *alert)
+	}
+
+	return res
+}
@@ -0,0 +1,69 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package alerts
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockGasPrice struct {
+	price *big.Int
+}
+
+func (m *mockGasPrice) SuggestGasPrice() (*big.Int, error) {
+	return m.price, nil
+}
+
+func TestEngine(t *testing.T) {
+	gas := &mockGasPrice{price: big.NewInt(10)}
+	failures := NewSettlementFailuresAbove(1, time.Hour, SeverityCritical)
+
+	var dispatched []Alert
+	notifier := NotifierFunc(func(a Alert) error {
+		dispatched = append(dispatched, a)
+		return nil
+	})
+
+	now := time.Unix(10000, 0)
+	e := NewEngine(notifier, time.Second, 0, NewGasPriceAbove(gas, big.NewInt(20), SeverityWarning), failures)
+	e.now = func() time.Time { return now }
+
+	assert.Len(t, e.Evaluate(), 0)
+
+	gas.price = big.NewInt(30)
+	failures.RecordFailure(now.Add(-2 * time.Hour))
+	failures.RecordFailure(now.Add(-time.Minute))
+	failures.RecordFailure(now)
+	alerts := e.Evaluate()
+	assert.Len(t, alerts, 2)
+	assert.Equal(t, "gas_price_above", alerts[0].Rule)
+	assert.Equal(t, SeverityCritical, alerts[1].Severity)
+
+	// still firing, not repeated
+	assert.Len(t, e.Evaluate(), 0)
+
+	// resolved and triggered again
+	gas.price = big.NewInt(1)
+	assert.Len(t, e.Evaluate(), 0)
+	gas.price = big.NewInt(30)
+	assert.Len(t, e.Evaluate(), 1)
+	assert.Len(t, dispatched, 3)
+}
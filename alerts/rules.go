@@ -0,0 +1,197 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package alerts
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/client"
+)
+
+type gasPriceSuggester interface {
+	SuggestGasPrice() (*big.Int, error)
+}
+
+type hermesBalanceGetter interface {
+	GetHermessAvailableBalance(hermesAddress common.Address) (*big.Int, error)
+}
+
+type providerChannelGetter interface {
+	GetProviderChannel(hermesAddress common.Address, addressToCheck common.Address, pending bool) (client.ProviderChannel, error)
+}
+
+// GasPriceAbove triggers when the suggested gas price is above the threshold.
+type GasPriceAbove struct {
+	bc        gasPriceSuggester
+	threshold *big.Int
+	severity  Severity
+}
+
+// NewGasPriceAbove returns a new gas price rule.
+func NewGasPriceAbove(bc gasPriceSuggester, threshold *big.Int, severity Severity) *GasPriceAbove {
+	return &GasPriceAbove{bc: bc, threshold: threshold, severity: severity}
+}
+
+// Name returns the rule name.
+func (r *GasPriceAbove) Name() string {
+	return "gas_price_above"
+}
+
+// Evaluate checks the current gas price.
+func (r *GasPriceAbove) Evaluate(now time.Time) (*Alert, error) {
+	price, err := r.bc.SuggestGasPrice()
+	if err != nil {
+		return nil, err
+	}
+	if price.Cmp(r.threshold) <= 0 {
+		return nil, nil
+	}
+	return &Alert{
+		Rule:     r.Name(),
+		Severity: r.severity,
+		Message:  fmt.Sprintf("gas price %v is above %v", price, r.threshold),
+		Time:     now,
+	}, nil
+}
+
+// HermesBalanceBelow triggers when the available hermes balance is below the threshold.
+type HermesBalanceBelow struct {
+	bc        hermesBalanceGetter
+	hermesID  common.Address
+	threshold *big.Int
+	severity  Severity
+}
+
+// NewHermesBalanceBelow returns a new hermes balance rule.
+func NewHermesBalanceBelow(bc hermesBalanceGetter, hermesID common.Address, threshold *big.Int, severity Severity) *HermesBalanceBelow {
+	return &HermesBalanceBelow{bc: bc, hermesID: hermesID, threshold: threshold, severity: severity}
+}
+
+// Name returns the rule name.
+func (r *HermesBalanceBelow) Name() string {
+	return "hermes_balance_below:" + r.hermesID.Hex()
+}
+
+// Evaluate checks the available hermes balance.
+func (r *HermesBalanceBelow) Evaluate(now time.Time) (*Alert, error) {
+	balance, err := r.bc.GetHermessAvailableBalance(r.hermesID)
+	if err != nil {
+		return nil, err
+	}
+	if balance.Cmp(r.threshold) >= 0 {
+		return nil, nil
+	}
+	return &Alert{
+		Rule:     r.Name(),
+		Severity: r.severity,
+		Message:  fmt.Sprintf("hermes %v available balance %v is below %v", r.hermesID.Hex(), balance, r.threshold),
+		Time:     now,
+	}, nil
+}
+
+// ChannelStakeBelow triggers when the provider channel stake is below the threshold.
+type ChannelStakeBelow struct {
+	bc        providerChannelGetter
+	hermesID  common.Address
+	provider  common.Address
+	threshold *big.Int
+	severity  Severity
+}
+
+// NewChannelStakeBelow returns a new channel stake rule.
+func NewChannelStakeBelow(bc providerChannelGetter, hermesID, provider common.Address, threshold *big.Int, severity Severity) *ChannelStakeBelow {
+	return &ChannelStakeBelow{bc: bc, hermesID: hermesID, provider: provider, threshold: threshold, severity: severity}
+}
+
+// Name returns the rule name.
+func (r *ChannelStakeBelow) Name() string {
+	return "channel_stake_below:" + r.hermesID.Hex() + ":" + r.provider.Hex()
+}
+
+// Evaluate checks the provider channel stake.
+func (r *ChannelStakeBelow) Evaluate(now time.Time) (*Alert, error) {
+	channel, err := r.bc.GetProviderChannel(r.hermesID, r.provider, false)
+	if err != nil {
+		return nil, err
+	}
+	if channel.Stake.Cmp(r.threshold) >= 0 {
+		return nil, nil
+	}
+	return &Alert{
+		Rule:     r.Name(),
+		Severity: r.severity,
+		Message:  fmt.Sprintf("provider %v stake %v is below %v", r.provider.Hex(), channel.Stake, r.threshold),
+		Time:     now,
+	}, nil
+}
+
+// SettlementFailuresAbove triggers when more than the allowed number of settlement failures happened within the window.
+// Failures are reported by calling RecordFailure.
+type SettlementFailuresAbove struct {
+	max      int
+	window   time.Duration
+	severity Severity
+
+	lock     sync.Mutex
+	failures []time.Time
+}
+
+// NewSettlementFailuresAbove returns a new settlement failure rule, e.g. more than 5 failures per hour.
+func NewSettlementFailuresAbove(max int, window time.Duration, severity Severity) *SettlementFailuresAbove {
+	return &SettlementFailuresAbove{max: max, window: window, severity: severity}
+}
+
+// Name returns the rule name.
+func (r *SettlementFailuresAbove) Name() string {
+	return "settlement_failures_above"
+}
+
+// RecordFailure records a settlement failure at the given time.
+func (r *SettlementFailuresAbove) RecordFailure(at time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.failures = append(r.failures, at)
+}
+
+// Evaluate counts the failures within the window.
+func (r *SettlementFailuresAbove) Evaluate(now time.Time) (*Alert, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	cutoff := now.Add(-r.window)
+	kept := r.failures[:0]
+	for _, f := range r.failures {
+		if f.After(cutoff) {
+			kept = append(kept, f)
+		}
+	}
+	r.failures = kept
+
+	if len(r.failures) <= r.max {
+		return nil, nil
+	}
+	return &Alert{
+		Rule:     r.Name(),
+		Severity: r.severity,
+		Message:  fmt.Sprintf("%v settlement failures in the last %v", len(r.failures), r.window),
+		Time:     now,
+	}, nil
+}
@@ -0,0 +1,235 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package analytics provides provider facing earnings queries built on top of indexed settlement events and promise storages.
+package analytics
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+// LogFilterer returns logs matching the given query.
+// Both the indexer storage and the blockchain client can be used.
+type LogFilterer interface {
+	FilterLogs(q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// PromiseStorage returns the latest hermes promises received by the provider.
+type PromiseStorage interface {
+	// GetLatestHermesPromise returns the promise with the highest amount issued by the given hermes for the given identity.
+	// A nil promise is returned if there are none.
+	GetLatestHermesPromise(hermesID, identity common.Address) (*crypto.Promise, error)
+}
+
+// ChannelGetter returns provider channels from BC.
+type ChannelGetter interface {
+	GetProviderChannel(hermesAddress common.Address, addressToCheck common.Address, pending bool) (client.ProviderChannel, error)
+}
+
+// Settlement represents a single settlement of a provider channel.
+type Settlement struct {
+	HermesID    common.Address
+	ChannelID   [32]byte
+	Beneficiary common.Address
+	Amount      *big.Int
+	Fees        *big.Int
+	BlockNumber uint64
+	BlockTime   time.Time
+	TxHash      common.Hash
+}
+
+// Earnings summarises provider settlements over a time window.
+type Earnings struct {
+	Identity common.Address
+	From     time.Time
+	To       time.Time
+	// Settled is the amount sent to the beneficiary.
+	Settled *big.Int
+	// Fees is the amount of hermes and transactor fees taken during settlement.
+	Fees        *big.Int
+	Settlements []Settlement
+}
+
+// Analytics answers earnings queries for provider identities.
+type Analytics struct {
+	logs     LogFilterer
+	headers  HeaderGetter
+	promises PromiseStorage
+	channels ChannelGetter
+	hermeses []common.Address
+}
+
+// NewAnalytics returns a new instance of analytics for the given hermeses.
+func NewAnalytics(logs LogFilterer, headers HeaderGetter, promises PromiseStorage, channels ChannelGetter, hermeses []common.Address) *Analytics {
+	return &Analytics{
+		logs:     logs,
+		headers:  headers,
+		promises: promises,
+		channels: channels,
+		hermeses: hermeses,
+	}
+}
+
+// Earnings returns the settlements of the given identity in the [from, to) time window.
+func (a *Analytics) Earnings(identity common.Address, from, to time.Time) (Earnings, error) {
+	res := Earnings{
+		Identity: identity,
+		From:     from,
+		To:       to,
+		Settled:  new(big.Int),
+		Fees:     new(big.Int),
+	}
+
+	start, end, err := BlockRange(a.headers, from, to)
+	if err != nil {
+		return Earnings{}, err
+	}
+	if start > end {
+		return res, nil
+	}
+
+	settlements, err := a.GetSettlements(identity, start, end)
+	if err != nil {
+		return Earnings{}, err
+	}
+
+	for _, s := range settlements {
+		res.Settled.Add(res.Settled, s.Amount)
+		res.Fees.Add(res.Fees, s.Fees)
+	}
+	res.Settlements = settlements
+
+	return res, nil
+}
+
+// TotalSettled returns the amount settled to the beneficiary of the given identity in the [from, to) time window.
+func (a *Analytics) TotalSettled(identity common.Address, from, to time.Time) (*big.Int, error) {
+	e, err := a.Earnings(identity, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return e.Settled, nil
+}
+
+// FeesPaid returns the fees paid during settlements of the given identity in the [from, to) time window.
+func (a *Analytics) FeesPaid(identity common.Address, from, to time.Time) (*big.Int, error) {
+	e, err := a.Earnings(identity, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return e.Fees, nil
+}
+
+// PendingUnsettled returns the amount the identity has received in promises but not yet settled, per hermes.
+func (a *Analytics) PendingUnsettled(identity common.Address) (map[common.Address]*big.Int, error) {
+	res := make(map[common.Address]*big.Int)
+	for _, hermesID := range a.hermeses {
+		promise, err := a.promises.GetLatestHermesPromise(hermesID, identity)
+		if err != nil {
+			return nil, fmt.Errorf("could not get latest promise of hermes %v: %w", hermesID.Hex(), err)
+		}
+		if promise == nil {
+			continue
+		}
+
+		channel, err := a.channels.GetProviderChannel(hermesID, identity, false)
+		if err != nil {
+			return nil, fmt.Errorf("could not get provider channel of hermes %v: %w", hermesID.Hex(), err)
+		}
+
+		pending := new(big.Int).Sub(promise.Amount, channel.Settled)
+		if pending.Sign() > 0 {
+			res[hermesID] = pending
+		}
+	}
+
+	return res, nil
+}
+
+// GetSettlements returns the settlements of the given identity in the inclusive block range.
+func (a *Analytics) GetSettlements(identity common.Address, fromBlock, toBlock uint64) ([]Settlement, error) {
+	if len(a.hermeses) == 0 {
+		return nil, nil
+	}
+
+	channels := make([]common.Hash, len(a.hermeses))
+	hermesByChannel := make(map[common.Hash]common.Address)
+	for i, hermesID := range a.hermeses {
+		channels[i] = common.BytesToHash(crypto.GenerateProviderChannelIDBytes(identity, hermesID))
+		hermesByChannel[channels[i]] = hermesID
+	}
+
+	logs, err := a.logs.FilterLogs(ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(fromBlock),
+		ToBlock:   new(big.Int).SetUint64(toBlock),
+		Addresses: a.hermeses,
+		Topics:    [][]common.Hash{{bindings.HermesImplementationPromiseSettledTopic}, channels},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not filter settlement logs: %w", err)
+	}
+
+	filterer, err := bindings.NewHermesImplementationFilterer(common.Address{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Settlement, 0, len(logs))
+	blockTimes := make(map[uint64]time.Time)
+	for _, l := range logs {
+		ev, err := filterer.ParsePromiseSettled(l)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse settlement log: %w", err)
+		}
+
+		hermesID, ok := hermesByChannel[common.Hash(ev.ChannelId)]
+		if !ok || hermesID != l.Address {
+			continue
+		}
+
+		blockTime, ok := blockTimes[l.BlockNumber]
+		if !ok {
+			header, err := a.headers.HeaderByNumber(new(big.Int).SetUint64(l.BlockNumber))
+			if err != nil {
+				return nil, fmt.Errorf("could not get header %v: %w", l.BlockNumber, err)
+			}
+			blockTime = time.Unix(int64(header.Time), 0).UTC()
+			blockTimes[l.BlockNumber] = blockTime
+		}
+
+		res = append(res, Settlement{
+			HermesID:    hermesID,
+			ChannelID:   ev.ChannelId,
+			Beneficiary: ev.Beneficiary,
+			Amount:      ev.AmountSentToBeneficiary,
+			Fees:        ev.Fees,
+			BlockNumber: l.BlockNumber,
+			BlockTime:   blockTime,
+			TxHash:      l.TxHash,
+		})
+	}
+
+	return res, nil
+}
@@ -0,0 +1,145 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package analytics
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockChain mines a block every 10 seconds starting at unix time 1000.
+type mockChain struct {
+	head uint64
+	logs []types.Log
+}
+
+func (mc *mockChain) HeaderByNumber(number *big.Int) (*types.Header, error) {
+	n := mc.head
+	if number != nil {
+		n = number.Uint64()
+	}
+	return &types.Header{Number: new(big.Int).SetUint64(n), Time: 1000 + n*10}, nil
+}
+
+func (mc *mockChain) FilterLogs(q ethereum.FilterQuery) ([]types.Log, error) {
+	var res []types.Log
+	for _, l := range mc.logs {
+		if l.BlockNumber >= q.FromBlock.Uint64() && l.BlockNumber <= q.ToBlock.Uint64() {
+			res = append(res, l)
+		}
+	}
+	return res, nil
+}
+
+func (mc *mockChain) GetProviderChannel(hermesAddress common.Address, addressToCheck common.Address, pending bool) (client.ProviderChannel, error) {
+	return client.ProviderChannel{Settled: big.NewInt(30)}, nil
+}
+
+type mockPromises struct{}
+
+func (mp *mockPromises) GetLatestHermesPromise(hermesID, identity common.Address) (*crypto.Promise, error) {
+	return &crypto.Promise{Amount: big.NewInt(100)}, nil
+}
+
+func settledLog(hermes, identity common.Address, block uint64, amount, fee int64) types.Log {
+	data := append(math.U256Bytes(big.NewInt(amount)), math.U256Bytes(big.NewInt(fee))...)
+	return types.Log{
+		Address: hermes,
+		Topics: []common.Hash{
+			bindings.HermesImplementationPromiseSettledTopic,
+			common.BytesToHash(crypto.GenerateProviderChannelIDBytes(identity, hermes)),
+			common.BytesToHash(identity.Bytes()),
+		},
+		Data:        data,
+		BlockNumber: block,
+	}
+}
+
+func TestFirstBlockAfter(t *testing.T) {
+	chain := &mockChain{head: 100}
+
+	n, err := FirstBlockAfter(chain, time.Unix(1000, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), n)
+
+	n, err = FirstBlockAfter(chain, time.Unix(1055, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), n)
+
+	n, err = FirstBlockAfter(chain, time.Unix(5000, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(101), n)
+}
+
+func TestEarnings(t *testing.T) {
+	hermes := common.HexToAddress("0x1")
+	identity := common.HexToAddress("0x2")
+	chain := &mockChain{
+		head: 100,
+		logs: []types.Log{
+			settledLog(hermes, identity, 5, 10, 1),
+			settledLog(hermes, identity, 20, 20, 2),
+			settledLog(hermes, identity, 50, 40, 4),
+		},
+	}
+	a := NewAnalytics(chain, chain, &mockPromises{}, chain, []common.Address{hermes})
+
+	e, err := a.Earnings(identity, time.Unix(1100, 0), time.Unix(1500, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(20), e.Settled)
+	assert.Equal(t, big.NewInt(2), e.Fees)
+	assert.Len(t, e.Settlements, 1)
+
+	pending, err := a.PendingUnsettled(identity)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(70), pending[hermes])
+}
+
+func TestExportSettlements(t *testing.T) {
+	hermes := common.HexToAddress("0x1")
+	identity := common.HexToAddress("0x2")
+	chain := &mockChain{
+		head: 100,
+		logs: []types.Log{
+			settledLog(hermes, identity, 5, 10, 1),
+			settledLog(hermes, identity, 20, 20, 2),
+		},
+	}
+	a := NewAnalytics(chain, chain, &mockPromises{}, chain, []common.Address{hermes})
+
+	var buf bytes.Buffer
+	err := a.ExportSettlements(NewCSVWriter(&buf), []common.Address{identity}, time.Unix(1100, 0), time.Unix(1500, 0))
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, strings.Join(SettlementColumns, ","), lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "1970-01-01T00:20:00Z,20,"))
+	assert.True(t, strings.HasSuffix(lines[1], ",20,2"))
+}
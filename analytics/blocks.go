@@ -0,0 +1,81 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package analytics
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// HeaderGetter returns block headers from the canonical chain.
+type HeaderGetter interface {
+	HeaderByNumber(number *big.Int) (*types.Header, error)
+}
+
+// FirstBlockAfter returns the number of the first block mined at or after the given time.
+// If no such block exists yet, the number following the chain head is returned.
+func FirstBlockAfter(hg HeaderGetter, t time.Time) (uint64, error) {
+	head, err := hg.HeaderByNumber(nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not get chain head: %w", err)
+	}
+
+	target := uint64(t.Unix())
+	if head.Time < target {
+		return head.Number.Uint64() + 1, nil
+	}
+
+	lo, hi := uint64(0), head.Number.Uint64()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		header, err := hg.HeaderByNumber(new(big.Int).SetUint64(mid))
+		if err != nil {
+			return 0, fmt.Errorf("could not get header %v: %w", mid, err)
+		}
+
+		if header.Time < target {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	return lo, nil
+}
+
+// BlockRange returns the inclusive block range of blocks mined in the [from, to) time window.
+// The returned range is empty (from > to) if no blocks were mined in the window.
+func BlockRange(hg HeaderGetter, from, to time.Time) (uint64, uint64, error) {
+	start, err := FirstBlockAfter(hg, from)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end, err := FirstBlockAfter(hg, to)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if end == 0 {
+		return 1, 0, nil
+	}
+
+	return start, end - 1, nil
+}
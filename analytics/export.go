@@ -0,0 +1,119 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package analytics
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SettlementColumns is the stable column schema of exported settlement history.
+// New columns may only be appended to keep existing pipelines working.
+var SettlementColumns = []string{
+	"block_time",
+	"block_number",
+	"tx_hash",
+	"hermes_id",
+	"channel_id",
+	"beneficiary",
+	"amount",
+	"fees",
+}
+
+// RowWriter writes a single exported row.
+// It is implemented by the bundled CSV and parquet writers and allows plugging in other formats.
+type RowWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []string) error
+	Flush() error
+}
+
+// CSVWriter writes exported rows as CSV.
+type CSVWriter struct {
+	w *csv.Writer
+}
+
+// NewCSVWriter returns a new CSV row writer.
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// WriteHeader writes the column names.
+func (cw *CSVWriter) WriteHeader(columns []string) error {
+	return cw.w.Write(columns)
+}
+
+// WriteRow writes a single row.
+func (cw *CSVWriter) WriteRow(values []string) error {
+	return cw.w.Write(values)
+}
+
+// Flush flushes the buffered rows to the underlying writer.
+func (cw *CSVWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// ExportSettlements writes the settlements of the given identities in the [from, to) time window.
+// The rows are streamed to the writer identity by identity, so the whole history is never held in memory,
+// the ParquetWriter only buffers the rows of its current row group.
+func (a *Analytics) ExportSettlements(w RowWriter, identities []common.Address, from, to time.Time) error {
+	if err := w.WriteHeader(SettlementColumns); err != nil {
+		return fmt.Errorf("could not write header: %w", err)
+	}
+
+	start, end, err := BlockRange(a.headers, from, to)
+	if err != nil {
+		return err
+	}
+
+	if start <= end {
+		for _, identity := range identities {
+			settlements, err := a.GetSettlements(identity, start, end)
+			if err != nil {
+				return err
+			}
+
+			for _, s := range settlements {
+				if err := w.WriteRow(settlementRow(s)); err != nil {
+					return fmt.Errorf("could not write row: %w", err)
+				}
+			}
+		}
+	}
+
+	return w.Flush()
+}
+
+func settlementRow(s Settlement) []string {
+	return []string{
+		s.BlockTime.UTC().Format(time.RFC3339),
+		strconv.FormatUint(s.BlockNumber, 10),
+		s.TxHash.Hex(),
+		s.HermesID.Hex(),
+		"0x" + hex.EncodeToString(s.ChannelID[:]),
+		s.Beneficiary.Hex(),
+		s.Amount.String(),
+		s.Fees.String(),
+	}
+}
@@ -0,0 +1,160 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package analytics
+
+import (
+	"errors"
+	"math/big"
+	"time"
+)
+
+// ErrNotEnoughSamples is returned when the promise inflow rate can not be determined.
+var ErrNotEnoughSamples = errors.New("at least two promise samples spanning some time are required")
+
+// PromiseSample is the cumulative promised amount observed at a point in time.
+type PromiseSample struct {
+	Time   time.Time
+	Amount *big.Int
+}
+
+// ForecastParams are the inputs of an earnings forecast.
+type ForecastParams struct {
+	// Samples are the recent promise observations, ordered by time.
+	Samples []PromiseSample
+	// HermesFee is the hermes fee in basis points, as returned by GetHermesFee.
+	HermesFee uint16
+	// SettlementCost is the transactor fee paid per settlement.
+	SettlementCost *big.Int
+	// MaxFeePercent is the highest acceptable share of fees in a settlement, e.g. 5 for 5%.
+	MaxFeePercent float64
+	// MaxInterval caps the recommended time between settlements.
+	MaxInterval time.Duration
+	// Horizon and Step control the projection points.
+	Horizon time.Duration
+	Step    time.Duration
+}
+
+// Projection is the expected settlement outcome if settled after the given time.
+type Projection struct {
+	After      time.Duration
+	Earned     *big.Int
+	Payout     *big.Int
+	FeePercent float64
+}
+
+// Forecast is the projected settlement value over time and the recommended settlement schedule.
+type Forecast struct {
+	// RatePerHour is the promise inflow rate.
+	RatePerHour *big.Int
+	Projections []Projection
+	// SettleEvery is the shortest interval keeping fees at or below MaxFeePercent, capped by MaxInterval.
+	SettleEvery time.Duration
+	// SettleAmount is the expected earned amount at each settlement.
+	SettleAmount *big.Int
+}
+
+// ForecastEarnings projects settlement value over time from the promise inflow rate and the current fees.
+func ForecastEarnings(p ForecastParams) (Forecast, error) {
+	if len(p.Samples) < 2 {
+		return Forecast{}, ErrNotEnoughSamples
+	}
+	first, last := p.Samples[0], p.Samples[len(p.Samples)-1]
+	span := last.Time.Sub(first.Time)
+	if span <= 0 {
+		return Forecast{}, ErrNotEnoughSamples
+	}
+
+	inflow := new(big.Int).Sub(last.Amount, first.Amount)
+	if inflow.Sign() < 0 {
+		inflow.SetInt64(0)
+	}
+	cost := p.SettlementCost
+	if cost == nil {
+		cost = new(big.Int)
+	}
+
+	res := Forecast{
+		RatePerHour: earnedAfter(inflow, span, time.Hour),
+	}
+
+	if p.Step > 0 {
+		for after := p.Step; after <= p.Horizon; after += p.Step {
+			earned := earnedAfter(inflow, span, after)
+			payout, percent := settlementOutcome(earned, p.HermesFee, cost)
+			res.Projections = append(res.Projections, Projection{
+				After:      after,
+				Earned:     earned,
+				Payout:     payout,
+				FeePercent: percent,
+			})
+		}
+	}
+
+	res.SettleEvery = recommendInterval(inflow, span, p.HermesFee, cost, p.MaxFeePercent, p.MaxInterval)
+	res.SettleAmount = earnedAfter(inflow, span, res.SettleEvery)
+	return res, nil
+}
+
+func earnedAfter(inflow *big.Int, span, after time.Duration) *big.Int {
+	res := new(big.Int).Mul(inflow, big.NewInt(int64(after)))
+	return res.Div(res, big.NewInt(int64(span)))
+}
+
+// settlementOutcome returns the payout and the share of fees in percent for the given settled amount.
+func settlementOutcome(earned *big.Int, hermesFee uint16, cost *big.Int) (*big.Int, float64) {
+	fee := new(big.Int).Mul(earned, big.NewInt(int64(hermesFee)))
+	fee.Div(fee, big.NewInt(10000))
+	fee.Add(fee, cost)
+
+	payout := new(big.Int).Sub(earned, fee)
+	if payout.Sign() < 0 {
+		payout.SetInt64(0)
+	}
+
+	if earned.Sign() == 0 {
+		return payout, 100
+	}
+	percent, _ := new(big.Float).Quo(new(big.Float).SetInt(fee), new(big.Float).SetInt(earned)).Float64()
+	if percent > 1 {
+		percent = 1
+	}
+	return payout, percent * 100
+}
+
+// recommendInterval finds the time needed to earn enough for the fixed settlement cost to fit into the max fee percent.
+func recommendInterval(inflow *big.Int, span time.Duration, hermesFee uint16, cost *big.Int, maxFeePercent float64, maxInterval time.Duration) time.Duration {
+	hermesPercent := float64(hermesFee) / 100
+	if inflow.Sign() == 0 || maxFeePercent <= hermesPercent {
+		return maxInterval
+	}
+
+	// cost / amount * 100 <= maxFeePercent - hermesPercent
+	required, _ := new(big.Float).Quo(
+		new(big.Float).Mul(new(big.Float).SetInt(cost), big.NewFloat(100)),
+		big.NewFloat(maxFeePercent-hermesPercent),
+	).Int(nil)
+
+	// time = required / inflow * span, rounded up
+	t := new(big.Int).Mul(required, big.NewInt(int64(span)))
+	t.Add(t, new(big.Int).Sub(inflow, big.NewInt(1)))
+	t.Div(t, inflow)
+
+	if maxInterval > 0 && (!t.IsInt64() || time.Duration(t.Int64()) > maxInterval) {
+		return maxInterval
+	}
+	return time.Duration(t.Int64())
+}
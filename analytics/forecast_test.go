@@ -0,0 +1,58 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package analytics
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForecastEarnings(t *testing.T) {
+	start := time.Unix(0, 0)
+	params := ForecastParams{
+		Samples: []PromiseSample{
+			{Time: start, Amount: big.NewInt(0)},
+			{Time: start.Add(2 * time.Hour), Amount: big.NewInt(2000)},
+		},
+		HermesFee:      500,
+		SettlementCost: big.NewInt(50),
+		MaxFeePercent:  10,
+		MaxInterval:    24 * time.Hour,
+		Horizon:        3 * time.Hour,
+		Step:           time.Hour,
+	}
+
+	f, err := ForecastEarnings(params)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1000), f.RatePerHour)
+	assert.Len(t, f.Projections, 3)
+	assert.Equal(t, big.NewInt(900), f.Projections[0].Payout)
+	assert.InDelta(t, 10, f.Projections[0].FeePercent, 0.001)
+	assert.Equal(t, time.Hour, f.SettleEvery)
+	assert.Equal(t, big.NewInt(1000), f.SettleAmount)
+
+	params.MaxFeePercent = 5
+	f, err = ForecastEarnings(params)
+	assert.NoError(t, err)
+	assert.Equal(t, 24*time.Hour, f.SettleEvery)
+
+	_, err = ForecastEarnings(ForecastParams{})
+	assert.Equal(t, ErrNotEnoughSamples, err)
+}
@@ -0,0 +1,390 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package analytics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// parquetMagic opens and closes every parquet file.
+const parquetMagic = "PAR1"
+
+// parquet format enums, see https://github.com/apache/parquet-format/blob/master/src/main/thrift/parquet.thrift
+const (
+	parquetTypeByteArray      = 6
+	parquetRepetitionRequired = 0
+	parquetConvertedTypeUTF8  = 0
+	parquetEncodingPlain      = 0
+	parquetCodecUncompressed  = 0
+	parquetPageTypeDataPage   = 0
+	parquetEncodingRLE        = 3
+	parquetFormatVersion      = 1
+	parquetCreatedBy          = "mysteriumnetwork/payments"
+)
+
+// DefaultParquetRowGroupSize is the number of rows buffered before they are written out as a row group.
+const DefaultParquetRowGroupSize = 10000
+
+// maxParquetPageSize is the size limit of a page, parquet stores the page sizes as int32.
+var maxParquetPageSize = math.MaxInt32
+
+// ErrParquetFlushed is returned when rows are written after the parquet file was flushed.
+var ErrParquetFlushed = errors.New("parquet file is already flushed")
+
+// ErrParquetPageTooLarge is returned when the values of a column in a row group do not fit a parquet page.
+var ErrParquetPageTooLarge = errors.New("parquet page is too large")
+
+// ParquetWriter writes exported rows as a parquet file.
+// Every column is stored as an uncompressed, plain encoded UTF8 string, the same values the CSV writer produces.
+// Being columnar, the rows are buffered until a row group is complete and then written out,
+// so only a single row group is held in memory. Flush writes the last row group and the footer and may only be called once.
+type ParquetWriter struct {
+	w            io.Writer
+	rowGroupSize int
+	offset       int64
+
+	columns  []string
+	values   [][]string
+	buffered int
+	groups   []parquetRowGroup
+	started  bool
+	flushed  bool
+}
+
+// NewParquetWriter returns a new parquet row writer with the DefaultParquetRowGroupSize.
+func NewParquetWriter(w io.Writer) *ParquetWriter {
+	return NewParquetWriterSize(w, DefaultParquetRowGroupSize)
+}
+
+// NewParquetWriterSize returns a new parquet row writer writing out a row group every rowGroupSize rows.
+// Non positive sizes use the DefaultParquetRowGroupSize.
+func NewParquetWriterSize(w io.Writer, rowGroupSize int) *ParquetWriter {
+	if rowGroupSize <= 0 || rowGroupSize > math.MaxInt32 {
+		rowGroupSize = DefaultParquetRowGroupSize
+	}
+	return &ParquetWriter{w: w, rowGroupSize: rowGroupSize}
+}
+
+// WriteHeader sets the column names.
+func (pw *ParquetWriter) WriteHeader(columns []string) error {
+	if pw.flushed {
+		return ErrParquetFlushed
+	}
+	if pw.started {
+		return errors.New("parquet header is already written")
+	}
+	if err := pw.start(); err != nil {
+		return err
+	}
+	pw.columns = columns
+	pw.values = make([][]string, len(columns))
+	return nil
+}
+
+// WriteRow buffers a single row, writing out the row group once it is complete.
+func (pw *ParquetWriter) WriteRow(values []string) error {
+	if pw.flushed {
+		return ErrParquetFlushed
+	}
+	if len(values) != len(pw.columns) {
+		return fmt.Errorf("row has %v values, expected %v", len(values), len(pw.columns))
+	}
+	for i := range values {
+		pw.values[i] = append(pw.values[i], values[i])
+	}
+	pw.buffered++
+	if pw.buffered < pw.rowGroupSize {
+		return nil
+	}
+	return pw.writeRowGroup()
+}
+
+// Flush writes the buffered rows and the footer of the parquet file to the underlying writer.
+func (pw *ParquetWriter) Flush() error {
+	if pw.flushed {
+		return ErrParquetFlushed
+	}
+	pw.flushed = true
+
+	if err := pw.start(); err != nil {
+		return err
+	}
+	if pw.buffered > 0 {
+		if err := pw.writeRowGroup(); err != nil {
+			return err
+		}
+	}
+
+	var footer thriftCompactWriter
+	footer.writeFileMetaData(pw.columns, pw.groups)
+	if err := pw.write(footer.buf.Bytes()); err != nil {
+		return err
+	}
+	footerLen := make([]byte, 4)
+	binary.LittleEndian.PutUint32(footerLen, uint32(footer.buf.Len()))
+	if err := pw.write(footerLen); err != nil {
+		return err
+	}
+	return pw.write([]byte(parquetMagic))
+}
+
+func (pw *ParquetWriter) start() error {
+	if pw.started {
+		return nil
+	}
+	pw.started = true
+	return pw.write([]byte(parquetMagic))
+}
+
+func (pw *ParquetWriter) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	if err != nil {
+		return fmt.Errorf("could not write parquet file: %w", err)
+	}
+	return nil
+}
+
+// writeRowGroup writes the buffered rows as a row group with a single page per column.
+func (pw *ParquetWriter) writeRowGroup() error {
+	group := parquetRowGroup{
+		chunks: make([]parquetColumnChunk, len(pw.columns)),
+		rows:   int64(pw.buffered),
+	}
+	for i := range pw.columns {
+		page, err := plainPage(pw.values[i])
+		if err != nil {
+			return fmt.Errorf("could not encode column %v: %w", pw.columns[i], err)
+		}
+
+		var header thriftCompactWriter
+		header.writePageHeader(int32(len(page)), int32(len(pw.values[i])))
+
+		group.chunks[i] = parquetColumnChunk{
+			name:   pw.columns[i],
+			offset: pw.offset,
+			size:   int64(header.buf.Len() + len(page)),
+		}
+		group.size += group.chunks[i].size
+		if err := pw.write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := pw.write(page); err != nil {
+			return err
+		}
+		pw.values[i] = pw.values[i][:0]
+	}
+	pw.groups = append(pw.groups, group)
+	pw.buffered = 0
+	return nil
+}
+
+// plainPage encodes the values as plain byte arrays, each prefixed with its little endian length.
+func plainPage(values []string) ([]byte, error) {
+	size := 0
+	for _, v := range values {
+		if len(v) > maxParquetPageSize-4-size {
+			return nil, ErrParquetPageTooLarge
+		}
+		size += 4 + len(v)
+	}
+
+	page := make([]byte, 0, size)
+	for _, v := range values {
+		var n [4]byte
+		binary.LittleEndian.PutUint32(n[:], uint32(len(v)))
+		page = append(page, n[:]...)
+		page = append(page, v...)
+	}
+	return page, nil
+}
+
+type parquetRowGroup struct {
+	chunks []parquetColumnChunk
+	rows   int64
+	size   int64
+}
+
+type parquetColumnChunk struct {
+	name   string
+	offset int64
+	size   int64
+}
+
+// thrift compact protocol types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftCompactWriter encodes the parquet metadata structures with the thrift compact protocol.
+type thriftCompactWriter struct {
+	buf    bytes.Buffer
+	last   int16
+	parent []int16
+}
+
+func (tw *thriftCompactWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - tw.last; delta > 0 && delta <= 15 {
+		tw.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		tw.buf.WriteByte(typ)
+		tw.varint(uint64((id << 1) ^ (id >> 15)))
+	}
+	tw.last = id
+}
+
+func (tw *thriftCompactWriter) varint(v uint64) {
+	b := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(b, v)
+	tw.buf.Write(b[:n])
+}
+
+func (tw *thriftCompactWriter) i32(v int32) {
+	tw.varint(uint64(uint32((v << 1) ^ (v >> 31))))
+}
+
+func (tw *thriftCompactWriter) i64(v int64) {
+	tw.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (tw *thriftCompactWriter) binary(s string) {
+	tw.varint(uint64(len(s)))
+	tw.buf.WriteString(s)
+}
+
+func (tw *thriftCompactWriter) fieldI32(id int16, v int32) {
+	tw.fieldHeader(id, thriftI32)
+	tw.i32(v)
+}
+
+func (tw *thriftCompactWriter) fieldI64(id int16, v int64) {
+	tw.fieldHeader(id, thriftI64)
+	tw.i64(v)
+}
+
+func (tw *thriftCompactWriter) fieldBinary(id int16, s string) {
+	tw.fieldHeader(id, thriftBinary)
+	tw.binary(s)
+}
+
+func (tw *thriftCompactWriter) listHeader(size int, elemType byte) {
+	if size < 15 {
+		tw.buf.WriteByte(byte(size)<<4 | elemType)
+		return
+	}
+	tw.buf.WriteByte(0xf0 | elemType)
+	tw.varint(uint64(size))
+}
+
+func (tw *thriftCompactWriter) fieldList(id int16, size int, elemType byte) {
+	tw.fieldHeader(id, thriftList)
+	tw.listHeader(size, elemType)
+}
+
+func (tw *thriftCompactWriter) beginStruct() {
+	tw.parent = append(tw.parent, tw.last)
+	tw.last = 0
+}
+
+func (tw *thriftCompactWriter) endStruct() {
+	tw.buf.WriteByte(0)
+	tw.last = tw.parent[len(tw.parent)-1]
+	tw.parent = tw.parent[:len(tw.parent)-1]
+}
+
+func (tw *thriftCompactWriter) fieldStruct(id int16) {
+	tw.fieldHeader(id, thriftStruct)
+	tw.beginStruct()
+}
+
+func (tw *thriftCompactWriter) writePageHeader(size, values int32) {
+	tw.beginStruct()
+	tw.fieldI32(1, parquetPageTypeDataPage)
+	tw.fieldI32(2, size)
+	tw.fieldI32(3, size)
+	tw.fieldStruct(5)
+	tw.fieldI32(1, values)
+	tw.fieldI32(2, parquetEncodingPlain)
+	tw.fieldI32(3, parquetEncodingRLE)
+	tw.fieldI32(4, parquetEncodingRLE)
+	tw.endStruct()
+	tw.endStruct()
+}
+
+func (tw *thriftCompactWriter) writeFileMetaData(columns []string, groups []parquetRowGroup) {
+	var rows int64
+	for _, g := range groups {
+		rows += g.rows
+	}
+
+	tw.beginStruct()
+	tw.fieldI32(1, parquetFormatVersion)
+
+	tw.fieldList(2, len(columns)+1, thriftStruct)
+	tw.beginStruct()
+	tw.fieldBinary(4, "schema")
+	tw.fieldI32(5, int32(len(columns)))
+	tw.endStruct()
+	for _, c := range columns {
+		tw.beginStruct()
+		tw.fieldI32(1, parquetTypeByteArray)
+		tw.fieldI32(3, parquetRepetitionRequired)
+		tw.fieldBinary(4, c)
+		tw.fieldI32(6, parquetConvertedTypeUTF8)
+		tw.endStruct()
+	}
+
+	tw.fieldI64(3, rows)
+
+	tw.fieldList(4, len(groups), thriftStruct)
+	for _, g := range groups {
+		tw.beginStruct()
+		tw.fieldList(1, len(g.chunks), thriftStruct)
+		for _, c := range g.chunks {
+			tw.beginStruct()
+			tw.fieldI64(2, c.offset)
+			tw.fieldStruct(3)
+			tw.fieldI32(1, parquetTypeByteArray)
+			tw.fieldList(2, 1, thriftI32)
+			tw.i32(parquetEncodingPlain)
+			tw.fieldList(3, 1, thriftBinary)
+			tw.binary(c.name)
+			tw.fieldI32(4, parquetCodecUncompressed)
+			tw.fieldI64(5, g.rows)
+			tw.fieldI64(6, c.size)
+			tw.fieldI64(7, c.size)
+			tw.fieldI64(9, c.offset)
+			tw.endStruct()
+			tw.endStruct()
+		}
+		tw.fieldI64(2, g.size)
+		tw.fieldI64(3, g.rows)
+		tw.endStruct()
+	}
+
+	tw.fieldBinary(6, parquetCreatedBy)
+	tw.endStruct()
+}
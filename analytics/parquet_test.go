@@ -0,0 +1,176 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package analytics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// thriftReader decodes thrift compact structs into maps keyed by field id.
+type thriftReader struct {
+	r *bytes.Reader
+}
+
+func (tr thriftReader) zigzag() int64 {
+	v, _ := binary.ReadUvarint(tr.r)
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (tr thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		return tr.zigzag()
+	case thriftBinary:
+		n, _ := binary.ReadUvarint(tr.r)
+		b := make([]byte, n)
+		tr.r.Read(b)
+		return string(b)
+	case thriftList:
+		h, _ := tr.r.ReadByte()
+		size := int(h >> 4)
+		if size == 15 {
+			n, _ := binary.ReadUvarint(tr.r)
+			size = int(n)
+		}
+		res := make([]interface{}, size)
+		for i := range res {
+			res[i] = tr.value(h & 0x0f)
+		}
+		return res
+	case thriftStruct:
+		return tr.structure()
+	}
+	panic("unexpected type")
+}
+
+func (tr thriftReader) structure() map[int16]interface{} {
+	res := make(map[int16]interface{})
+	var id int16
+	for {
+		h, _ := tr.r.ReadByte()
+		if h == 0 {
+			return res
+		}
+		if delta := int16(h >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(tr.zigzag())
+		}
+		res[id] = tr.value(h & 0x0f)
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	var buf bytes.Buffer
+	pw := NewParquetWriter(&buf)
+	assert.NoError(t, pw.WriteHeader([]string{"a", "b"}))
+	assert.NoError(t, pw.WriteRow([]string{"1", "x"}))
+	assert.NoError(t, pw.WriteRow([]string{"22", "yy"}))
+	assert.Error(t, pw.WriteRow([]string{"3"}))
+	assert.NoError(t, pw.Flush())
+	assert.Equal(t, ErrParquetFlushed, pw.WriteRow([]string{"3", "z"}))
+
+	file := buf.Bytes()
+	assert.Equal(t, parquetMagic, string(file[:4]))
+	assert.Equal(t, parquetMagic, string(file[len(file)-4:]))
+
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := file[len(file)-8-footerLen : len(file)-8]
+	meta := thriftReader{r: bytes.NewReader(footer)}.structure()
+	assert.Equal(t, int64(2), meta[3])
+
+	schema := meta[2].([]interface{})
+	assert.Len(t, schema, 3)
+	assert.Equal(t, int64(2), schema[0].(map[int16]interface{})[5])
+	assert.Equal(t, "b", schema[2].(map[int16]interface{})[4])
+
+	rowGroup := meta[4].([]interface{})[0].(map[int16]interface{})
+	columns := rowGroup[1].([]interface{})
+	assert.Len(t, columns, 2)
+
+	var values [][]string
+	for _, c := range columns {
+		cm := c.(map[int16]interface{})[3].(map[int16]interface{})
+		offset := cm[9].(int64)
+
+		r := bytes.NewReader(file[offset : offset+cm[7].(int64)])
+		header := thriftReader{r: r}.structure()
+		page := header[5].(map[int16]interface{})
+		assert.Equal(t, int64(2), page[1])
+
+		var column []string
+		for i := int64(0); i < page[1].(int64); i++ {
+			var n uint32
+			assert.NoError(t, binary.Read(r, binary.LittleEndian, &n))
+			v := make([]byte, n)
+			r.Read(v)
+			column = append(column, string(v))
+		}
+		values = append(values, column)
+	}
+	assert.Equal(t, [][]string{{"1", "22"}, {"x", "yy"}}, values)
+}
+
+// testdata/row_groups.parquet was read back with an independent reader, github.com/xitongsys/parquet-go,
+// which returned the same schema and values across both row groups.
+func TestParquetWriterRowGroups(t *testing.T) {
+	var buf bytes.Buffer
+	pw := NewParquetWriterSize(&buf, 2)
+	assert.NoError(t, pw.WriteHeader([]string{"block_number", "amount"}))
+	assert.NoError(t, pw.WriteRow([]string{"1", "10"}))
+	assert.NoError(t, pw.WriteRow([]string{"2", "200"}))
+
+	// the first row group is written out once complete
+	written := buf.Len()
+	assert.True(t, written > len(parquetMagic))
+
+	assert.NoError(t, pw.WriteRow([]string{"3", ""}))
+	assert.Equal(t, written, buf.Len())
+	assert.NoError(t, pw.Flush())
+
+	fixture, err := ioutil.ReadFile("testdata/row_groups.parquet")
+	assert.NoError(t, err)
+	assert.Equal(t, fixture, buf.Bytes())
+}
+
+func TestParquetWriterPageTooLarge(t *testing.T) {
+	maxParquetPageSize = 10
+	defer func() { maxParquetPageSize = math.MaxInt32 }()
+
+	pw := NewParquetWriterSize(ioutil.Discard, 2)
+	assert.NoError(t, pw.WriteHeader([]string{"a"}))
+	assert.NoError(t, pw.WriteRow([]string{"123"}))
+	assert.True(t, errors.Is(pw.WriteRow([]string{"123"}), ErrParquetPageTooLarge))
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestParquetWriterWriteError(t *testing.T) {
+	pw := NewParquetWriter(failingWriter{})
+	assert.Error(t, pw.WriteHeader([]string{"a"}))
+}
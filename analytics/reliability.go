@@ -0,0 +1,205 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package analytics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The weights of the metrics in the hermes reliability score.
+const (
+	settlementWeight = 0.4
+	apiWeight        = 0.3
+	latencyWeight    = 0.3
+)
+
+// ReliabilityOpts configure hermes reliability scoring.
+type ReliabilityOpts struct {
+	// Alpha is the weight of a new observation in the moving averages, between 0 and 1.
+	Alpha float64
+	// TargetLatency is the promise issuance latency that scores fully. Slower hermeses score TargetLatency/latency.
+	TargetLatency time.Duration
+	// MinScore is the score below which providers should switch away from a hermes, see Preferred.
+	MinScore float64
+}
+
+// HermesScore is the reliability of a single hermes.
+type HermesScore struct {
+	HermesID common.Address
+	// Score is between 0 and 1, higher is better.
+	Score float64
+	// Latency is the moving average of the promise issuance latency.
+	Latency time.Duration
+	// SettlementSuccess is the moving average of the settlement success rate, between 0 and 1.
+	SettlementSuccess float64
+	// APIErrorRate is the moving average of the API error rate, between 0 and 1.
+	APIErrorRate float64
+	// Observations is the number of recorded observations of all metrics.
+	Observations int
+}
+
+type hermesMetrics struct {
+	latency     ema
+	settlements ema
+	apiErrors   ema
+}
+
+type ema struct {
+	value   float64
+	samples int
+}
+
+func (e *ema) add(v, alpha float64) {
+	if e.samples == 0 {
+		e.value = v
+	} else {
+		e.value += alpha * (v - e.value)
+	}
+	e.samples++
+}
+
+// HermesReliability tracks hermes promise issuance latency, settlement success rate and API error rate
+// as exponential moving averages and scores the hermeses by them.
+// Metrics without observations do not lower the score, so a new hermes starts with a full score.
+type HermesReliability struct {
+	opts ReliabilityOpts
+
+	lock     sync.Mutex
+	hermeses map[common.Address]*hermesMetrics
+}
+
+// NewHermesReliability returns a new hermes reliability tracker.
+func NewHermesReliability(opts ReliabilityOpts) *HermesReliability {
+	return &HermesReliability{
+		opts:     opts,
+		hermeses: make(map[common.Address]*hermesMetrics),
+	}
+}
+
+// ObservePromiseLatency records the time hermes took to issue a promise.
+func (hr *HermesReliability) ObservePromiseLatency(hermesID common.Address, latency time.Duration) {
+	hr.observe(hermesID, func(m *hermesMetrics) {
+		m.latency.add(float64(latency), hr.opts.Alpha)
+	})
+}
+
+// ObserveSettlement records the outcome of a settlement through hermes.
+func (hr *HermesReliability) ObserveSettlement(hermesID common.Address, success bool) {
+	hr.observe(hermesID, func(m *hermesMetrics) {
+		m.settlements.add(boolToFloat(success), hr.opts.Alpha)
+	})
+}
+
+// ObserveAPICall records the outcome of a hermes API call, a nil error being a success.
+func (hr *HermesReliability) ObserveAPICall(hermesID common.Address, err error) {
+	hr.observe(hermesID, func(m *hermesMetrics) {
+		m.apiErrors.add(boolToFloat(err != nil), hr.opts.Alpha)
+	})
+}
+
+func (hr *HermesReliability) observe(hermesID common.Address, f func(m *hermesMetrics)) {
+	hr.lock.Lock()
+	defer hr.lock.Unlock()
+
+	m, ok := hr.hermeses[hermesID]
+	if !ok {
+		m = &hermesMetrics{}
+		hr.hermeses[hermesID] = m
+	}
+	f(m)
+}
+
+// Score returns the reliability of the hermes. Unknown hermeses have a full score.
+func (hr *HermesReliability) Score(hermesID common.Address) HermesScore {
+	hr.lock.Lock()
+	defer hr.lock.Unlock()
+
+	m, ok := hr.hermeses[hermesID]
+	if !ok {
+		m = &hermesMetrics{}
+	}
+	return hr.score(hermesID, m)
+}
+
+// Scores returns the reliability of all observed hermeses, the most reliable first.
+func (hr *HermesReliability) Scores() []HermesScore {
+	hr.lock.Lock()
+	res := make([]HermesScore, 0, len(hr.hermeses))
+	for id, m := range hr.hermeses {
+		res = append(res, hr.score(id, m))
+	}
+	hr.lock.Unlock()
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Score == res[j].Score {
+			return res[i].HermesID.Hex() < res[j].HermesID.Hex()
+		}
+		return res[i].Score > res[j].Score
+	})
+	return res
+}
+
+// Preferred returns the hermes a provider should use.
+// The current hermes is kept while its score is at least MinScore, to avoid flapping between hermeses of similar scores.
+// Otherwise the best scoring candidate is returned.
+func (hr *HermesReliability) Preferred(current common.Address, candidates []common.Address) common.Address {
+	if hr.Score(current).Score >= hr.opts.MinScore {
+		return current
+	}
+
+	best, bestScore := current, hr.Score(current).Score
+	for _, c := range candidates {
+		if s := hr.Score(c).Score; s > bestScore {
+			best, bestScore = c, s
+		}
+	}
+	return best
+}
+
+func (hr *HermesReliability) score(hermesID common.Address, m *hermesMetrics) HermesScore {
+	res := HermesScore{
+		HermesID:          hermesID,
+		Latency:           time.Duration(m.latency.value),
+		SettlementSuccess: 1,
+		Observations:      m.latency.samples + m.settlements.samples + m.apiErrors.samples,
+	}
+	if m.settlements.samples > 0 {
+		res.SettlementSuccess = m.settlements.value
+	}
+	if m.apiErrors.samples > 0 {
+		res.APIErrorRate = m.apiErrors.value
+	}
+
+	latencyScore := 1.0
+	if m.latency.samples > 0 && m.latency.value > float64(hr.opts.TargetLatency) {
+		latencyScore = float64(hr.opts.TargetLatency) / m.latency.value
+	}
+
+	res.Score = settlementWeight*res.SettlementSuccess + apiWeight*(1-res.APIErrorRate) + latencyWeight*latencyScore
+	return res
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,60 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package analytics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHermesReliability(t *testing.T) {
+	good := common.HexToAddress("0x1")
+	flaky := common.HexToAddress("0x2")
+	unknown := common.HexToAddress("0x3")
+
+	hr := NewHermesReliability(ReliabilityOpts{Alpha: 0.5, TargetLatency: time.Second, MinScore: 0.8})
+	for i := 0; i < 4; i++ {
+		hr.ObservePromiseLatency(good, 500*time.Millisecond)
+		hr.ObserveSettlement(good, true)
+		hr.ObserveAPICall(good, nil)
+
+		hr.ObservePromiseLatency(flaky, 4*time.Second)
+		hr.ObserveSettlement(flaky, i%2 == 0)
+		hr.ObserveAPICall(flaky, errors.New("boom"))
+	}
+
+	assert.InDelta(t, 1, hr.Score(good).Score, 0.0001)
+	assert.Equal(t, 12, hr.Score(good).Observations)
+	assert.Equal(t, 1.0, hr.Score(unknown).Score)
+
+	flakyScore := hr.Score(flaky)
+	assert.Equal(t, 4*time.Second, flakyScore.Latency)
+	assert.Equal(t, 1.0, flakyScore.APIErrorRate)
+	assert.InDelta(t, 0.375, flakyScore.SettlementSuccess, 0.0001)
+	assert.InDelta(t, 0.4*0.375+0.3*0.25, flakyScore.Score, 0.0001)
+
+	scores := hr.Scores()
+	assert.Len(t, scores, 2)
+	assert.Equal(t, good, scores[0].HermesID)
+
+	assert.Equal(t, good, hr.Preferred(flaky, []common.Address{flaky, good}))
+	assert.Equal(t, good, hr.Preferred(good, []common.Address{flaky, good}))
+}
@@ -0,0 +1,168 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package analytics
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SpendAnomaly is reported when the spend rate of a channel deviates from its baseline.
+type SpendAnomaly struct {
+	ChannelID       common.Hash
+	RatePerHour     *big.Int
+	BaselinePerHour *big.Int
+	// Deviation is the rate divided by the baseline, below 1 for drops.
+	Deviation float64
+	Time      time.Time
+}
+
+// AnomalyFunc is called with detected spend anomalies.
+type AnomalyFunc func(a SpendAnomaly)
+
+// VelocityOpts configure the spend monitor.
+type VelocityOpts struct {
+	// Window is the time over which the spend is summed into a single rate sample.
+	Window time.Duration
+	// Alpha is the weight of a new rate sample in the baseline, between 0 and 1.
+	Alpha float64
+	// Factor is the deviation from the baseline, in either direction, that is reported as an anomaly, e.g. 3.
+	Factor float64
+	// WarmUp is the number of rate samples learned before anomalies are reported.
+	WarmUp int
+}
+
+type channelVelocity struct {
+	last        *big.Int
+	windowStart time.Time
+	windowSpend *big.Int
+	baseline    float64
+	rate        float64
+	samples     int
+}
+
+// SpendMonitor computes the spend velocity of channels from their cumulative promise amounts and learns a baseline per channel.
+// Only the callbacks see the anomalies, no raw promise data leaves the monitor.
+// Anomalous rate samples are not learned, so a sustained attack does not become the new baseline.
+type SpendMonitor struct {
+	opts VelocityOpts
+
+	lock      sync.Mutex
+	channels  map[common.Hash]*channelVelocity
+	anomalyFn AnomalyFunc
+}
+
+// NewSpendMonitor returns a new spend monitor.
+func NewSpendMonitor(opts VelocityOpts) *SpendMonitor {
+	return &SpendMonitor{
+		opts:      opts,
+		channels:  make(map[common.Hash]*channelVelocity),
+		anomalyFn: func(SpendAnomaly) {},
+	}
+}
+
+// AttachAnomalyFunc sets the function anomalies are reported to.
+// Not thread safe, call before Observe.
+func (sm *SpendMonitor) AttachAnomalyFunc(f AnomalyFunc) {
+	sm.anomalyFn = f
+}
+
+// Observe records the cumulative promised amount of the channel at the given time.
+func (sm *SpendMonitor) Observe(channelID common.Hash, cumulative *big.Int, at time.Time) {
+	anomaly := sm.observe(channelID, cumulative, at)
+	if anomaly != nil {
+		sm.anomalyFn(*anomaly)
+	}
+}
+
+func (sm *SpendMonitor) observe(channelID common.Hash, cumulative *big.Int, at time.Time) *SpendAnomaly {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	cv, ok := sm.channels[channelID]
+	if !ok {
+		sm.channels[channelID] = &channelVelocity{
+			last:        new(big.Int).Set(cumulative),
+			windowStart: at,
+			windowSpend: new(big.Int),
+		}
+		return nil
+	}
+
+	if cumulative.Cmp(cv.last) > 0 {
+		cv.windowSpend.Add(cv.windowSpend, new(big.Int).Sub(cumulative, cv.last))
+		cv.last = new(big.Int).Set(cumulative)
+	}
+
+	elapsed := at.Sub(cv.windowStart)
+	if elapsed < sm.opts.Window || elapsed <= 0 {
+		return nil
+	}
+
+	spend, _ := new(big.Float).SetInt(cv.windowSpend).Float64()
+	cv.rate = spend / elapsed.Hours()
+	cv.windowStart = at
+	cv.windowSpend = new(big.Int)
+
+	if cv.samples >= sm.opts.WarmUp && cv.baseline > 0 {
+		deviation := cv.rate / cv.baseline
+		if deviation >= sm.opts.Factor || deviation <= 1/sm.opts.Factor {
+			return &SpendAnomaly{
+				ChannelID:       channelID,
+				RatePerHour:     floatToInt(cv.rate),
+				BaselinePerHour: floatToInt(cv.baseline),
+				Deviation:       deviation,
+				Time:            at,
+			}
+		}
+	}
+
+	if cv.samples == 0 {
+		cv.baseline = cv.rate
+	} else {
+		cv.baseline += sm.opts.Alpha * (cv.rate - cv.baseline)
+	}
+	cv.samples++
+	return nil
+}
+
+// Velocity returns the last spend rate and the learned baseline of the channel, per hour.
+func (sm *SpendMonitor) Velocity(channelID common.Hash) (rate, baseline *big.Int, ok bool) {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	cv, ok := sm.channels[channelID]
+	if !ok {
+		return nil, nil, false
+	}
+	return floatToInt(cv.rate), floatToInt(cv.baseline), true
+}
+
+// Forget drops the state of the channel, e.g. once it is closed.
+func (sm *SpendMonitor) Forget(channelID common.Hash) {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	delete(sm.channels, channelID)
+}
+
+func floatToInt(v float64) *big.Int {
+	res, _ := big.NewFloat(v).Int(nil)
+	return res
+}
@@ -0,0 +1,66 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package analytics
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpendMonitor(t *testing.T) {
+	sm := NewSpendMonitor(VelocityOpts{Window: time.Hour, Alpha: 0.5, Factor: 3, WarmUp: 3})
+	var anomalies []SpendAnomaly
+	sm.AttachAnomalyFunc(func(a SpendAnomaly) { anomalies = append(anomalies, a) })
+
+	channel := common.HexToHash("0x1")
+	start := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	amount := big.NewInt(0)
+	observe := func(hour int, spend int64) {
+		amount = new(big.Int).Add(amount, big.NewInt(spend))
+		sm.Observe(channel, amount, start.Add(time.Duration(hour)*time.Hour))
+	}
+
+	observe(0, 0)
+	for h := 1; h <= 4; h++ {
+		observe(h, 100)
+	}
+	assert.Empty(t, anomalies)
+	rate, baseline, ok := sm.Velocity(channel)
+	assert.True(t, ok)
+	assert.Equal(t, big.NewInt(100), rate)
+	assert.Equal(t, big.NewInt(100), baseline)
+
+	// a runaway spend is reported and not learned
+	observe(5, 1000)
+	assert.Len(t, anomalies, 1)
+	assert.Equal(t, big.NewInt(1000), anomalies[0].RatePerHour)
+	assert.Equal(t, big.NewInt(100), anomalies[0].BaselinePerHour)
+	assert.Equal(t, 10.0, anomalies[0].Deviation)
+
+	observe(6, 1000)
+	assert.Len(t, anomalies, 2)
+
+	// a moderate increase is learned
+	observe(7, 200)
+	assert.Len(t, anomalies, 2)
+	_, baseline, _ = sm.Velocity(channel)
+	assert.Equal(t, big.NewInt(150), baseline)
+}
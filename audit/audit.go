@@ -0,0 +1,212 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package audit records signing and submission actions into an append-only, hash chained log.
+package audit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	pc "github.com/mysteriumnetwork/payments/crypto"
+)
+
+// ErrChainBroken is returned when a record does not match the hash chain.
+var ErrChainBroken = errors.New("audit log hash chain is broken")
+
+// Action describes what was done.
+type Action string
+
+const (
+	// ActionSignHash is recorded when a hash, e.g. a promise, is signed.
+	ActionSignHash Action = "sign_hash"
+	// ActionSignTransaction is recorded when a transaction is signed.
+	ActionSignTransaction Action = "sign_transaction"
+	// ActionSubmitTransaction is recorded when a transaction is sent to the network.
+	ActionSubmitTransaction Action = "submit_transaction"
+)
+
+// Record is a single audit log entry.
+type Record struct {
+	Sequence uint64            `json:"sequence"`
+	Time     time.Time         `json:"time"`
+	Actor    common.Address    `json:"actor"`
+	Action   Action            `json:"action"`
+	TxHash   common.Hash       `json:"txHash"`
+	Params   map[string]string `json:"params,omitempty"`
+	PrevHash common.Hash       `json:"prevHash"`
+	Hash     common.Hash       `json:"hash"`
+}
+
+// ComputeHash returns the hash of the record chained to the previous record hash.
+func (r Record) ComputeHash() common.Hash {
+	var buf bytes.Buffer
+	buf.Write(r.PrevHash.Bytes())
+
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, r.Sequence)
+	buf.Write(b)
+	binary.BigEndian.PutUint64(b, uint64(r.Time.UnixNano()))
+	buf.Write(b)
+
+	buf.Write(r.Actor.Bytes())
+	writeString(&buf, string(r.Action))
+	buf.Write(r.TxHash.Bytes())
+
+	keys := make([]string, 0, len(r.Params))
+	for k := range r.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeString(&buf, k)
+		writeString(&buf, r.Params[k])
+	}
+
+	return pc.Keccak256Hash(buf.Bytes())
+}
+
+// writeString writes a length prefixed string so that different field splits can not produce the same bytes.
+func writeString(buf *bytes.Buffer, s string) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(len(s)))
+	buf.Write(b)
+	buf.WriteString(s)
+}
+
+// Storage persists audit records. Records must never be updated or deleted.
+type Storage interface {
+	// AppendAuditRecord stores a new record.
+	AppendAuditRecord(r Record) error
+	// GetLastAuditRecord returns the record with the highest sequence, or nil if the log is empty.
+	GetLastAuditRecord() (*Record, error)
+	// GetAuditRecords returns up to limit records starting from the given sequence, ordered by sequence.
+	GetAuditRecords(fromSequence uint64, limit int) ([]Record, error)
+}
+
+// Log is an append-only hash chained audit log.
+type Log struct {
+	storage Storage
+	now     func() time.Time
+
+	lock sync.Mutex
+}
+
+// NewLog returns a new audit log.
+func NewLog(storage Storage) *Log {
+	return &Log{
+		storage: storage,
+		now:     time.Now,
+	}
+}
+
+// Append records an action.
+func (l *Log) Append(actor common.Address, action Action, txHash common.Hash, params map[string]string) (Record, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	last, err := l.storage.GetLastAuditRecord()
+	if err != nil {
+		return Record{}, fmt.Errorf("could not get last audit record: %w", err)
+	}
+
+	r := Record{
+		// Truncated so that the hash survives storages with microsecond timestamp precision.
+		Time:   l.now().UTC().Truncate(time.Microsecond),
+		Actor:  actor,
+		Action: action,
+		TxHash: txHash,
+		Params: params,
+	}
+	if last != nil {
+		r.Sequence = last.Sequence + 1
+		r.PrevHash = last.Hash
+	}
+	r.Hash = r.ComputeHash()
+
+	if err := l.storage.AppendAuditRecord(r); err != nil {
+		return Record{}, fmt.Errorf("could not append audit record: %w", err)
+	}
+	return r, nil
+}
+
+const pageSize = 1000
+
+// Verify checks the whole hash chain.
+func (l *Log) Verify() error {
+	return l.walk(func(Record) error { return nil })
+}
+
+// Export verifies the log and writes it to the writer as JSON lines.
+func (l *Log) Export(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	return l.walk(func(r Record) error {
+		return enc.Encode(r)
+	})
+}
+
+func (l *Log) walk(f func(Record) error) error {
+	var prev common.Hash
+	var next uint64
+	for {
+		records, err := l.storage.GetAuditRecords(next, pageSize)
+		if err != nil {
+			return fmt.Errorf("could not get audit records: %w", err)
+		}
+
+		for _, r := range records {
+			if err := verifyRecord(r, next, prev); err != nil {
+				return err
+			}
+			if err := f(r); err != nil {
+				return err
+			}
+			prev = r.Hash
+			next++
+		}
+
+		if len(records) < pageSize {
+			return nil
+		}
+	}
+}
+
+// VerifyRecords checks the hash chain of exported records, starting at sequence zero.
+func VerifyRecords(records []Record) error {
+	var prev common.Hash
+	for i, r := range records {
+		if err := verifyRecord(r, uint64(i), prev); err != nil {
+			return err
+		}
+		prev = r.Hash
+	}
+	return nil
+}
+
+func verifyRecord(r Record, sequence uint64, prev common.Hash) error {
+	if r.Sequence != sequence || r.PrevHash != prev || r.ComputeHash() != r.Hash {
+		return fmt.Errorf("%w at sequence %v", ErrChainBroken, sequence)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type memStorage struct {
+	records []Record
+}
+
+func (ms *memStorage) AppendAuditRecord(r Record) error {
+	ms.records = append(ms.records, r)
+	return nil
+}
+
+func (ms *memStorage) GetLastAuditRecord() (*Record, error) {
+	if len(ms.records) == 0 {
+		return nil, nil
+	}
+	r := ms.records[len(ms.records)-1]
+	return &r, nil
+}
+
+func (ms *memStorage) GetAuditRecords(from uint64, limit int) ([]Record, error) {
+	if from >= uint64(len(ms.records)) {
+		return nil, nil
+	}
+	end := from + uint64(limit)
+	if end > uint64(len(ms.records)) {
+		end = uint64(len(ms.records))
+	}
+	return ms.records[from:end], nil
+}
+
+func TestAuditLog(t *testing.T) {
+	storage := &memStorage{}
+	log := NewLog(storage)
+
+	key, _ := crypto.GenerateKey()
+	opts := bind.NewKeyedTransactor(key)
+	signer := WrapSigner(opts.Signer, log)
+
+	tx := types.NewTransaction(1, common.HexToAddress("0x1"), big.NewInt(1), 21000, big.NewInt(1), nil)
+	signed, err := signer(types.HomesteadSigner{}, opts.From, tx)
+	assert.NoError(t, err)
+	assert.NoError(t, RecordSubmission(log, opts.From, signed))
+
+	assert.Len(t, storage.records, 2)
+	assert.Equal(t, signed.Hash(), storage.records[1].TxHash)
+	assert.Equal(t, storage.records[0].Hash, storage.records[1].PrevHash)
+	assert.NoError(t, log.Verify())
+
+	var buf bytes.Buffer
+	assert.NoError(t, log.Export(&buf))
+	var exported []Record
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var r Record
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+		exported = append(exported, r)
+	}
+	assert.NoError(t, VerifyRecords(exported))
+
+	storage.records[0].Params["gasPrice"] = "2"
+	assert.True(t, errors.Is(log.Verify(), ErrChainBroken))
+}
+
+func TestWrapRequestSigner(t *testing.T) {
+	storage := &memStorage{}
+	log := NewLog(storage)
+
+	key, _ := crypto.GenerateKey()
+	opts := bind.NewKeyedTransactor(key)
+	request := common.HexToHash("0xabc")
+
+	tx := types.NewTransaction(1, common.HexToAddress("0x1"), big.NewInt(1), 21000, big.NewInt(1), nil)
+	_, err := WrapRequestSigner(opts.Signer, log, request)(types.HomesteadSigner{}, opts.From, tx)
+	assert.NoError(t, err)
+
+	assert.Len(t, storage.records, 1)
+	assert.Equal(t, request.Hex(), storage.records[0].Params["request"])
+}
@@ -0,0 +1,108 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package audit
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type hashSigner interface {
+	SignHash(a accounts.Account, hash []byte) ([]byte, error)
+}
+
+// HashSigner records every signed hash in the audit log before returning the signature.
+// It can be used in place of the keystore when creating promises and exchange messages.
+type HashSigner struct {
+	signer hashSigner
+	log    *Log
+}
+
+// NewHashSigner returns a new auditing hash signer.
+func NewHashSigner(signer hashSigner, log *Log) *HashSigner {
+	return &HashSigner{signer: signer, log: log}
+}
+
+// SignHash signs the hash and records it.
+func (hs *HashSigner) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
+	sig, err := hs.signer.SignHash(a, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := hs.log.Append(a.Address, ActionSignHash, common.Hash{}, map[string]string{
+		"hash": hexutil.Encode(hash),
+	}); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// WrapSigner returns a transaction signer that records every signed transaction in the audit log.
+// Signatures are not returned if they can not be recorded.
+func WrapSigner(signer bind.SignerFn, log *Log) bind.SignerFn {
+	return wrapSigner(signer, log, nil)
+}
+
+// WrapRequestSigner is WrapSigner recording the content hash of the request the transaction is signed for,
+// see client.RequestHash, so the transactions of a retried request can be told apart from new operations.
+func WrapRequestSigner(signer bind.SignerFn, log *Log, request common.Hash) bind.SignerFn {
+	return wrapSigner(signer, log, &request)
+}
+
+func wrapSigner(signer bind.SignerFn, log *Log, request *common.Hash) bind.SignerFn {
+	return func(s types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		signed, err := signer(s, address, tx)
+		if err != nil {
+			return nil, err
+		}
+
+		params := txParams(signed)
+		if request != nil {
+			params["request"] = request.Hex()
+		}
+		if _, err := log.Append(address, ActionSignTransaction, signed.Hash(), params); err != nil {
+			return nil, err
+		}
+		return signed, nil
+	}
+}
+
+// RecordSubmission records a transaction sent to the network by the given sender.
+func RecordSubmission(log *Log, sender common.Address, tx *types.Transaction) error {
+	_, err := log.Append(sender, ActionSubmitTransaction, tx.Hash(), txParams(tx))
+	return err
+}
+
+func txParams(tx *types.Transaction) map[string]string {
+	params := map[string]string{
+		"nonce":    fmt.Sprint(tx.Nonce()),
+		"gas":      fmt.Sprint(tx.Gas()),
+		"gasPrice": tx.GasPrice().String(),
+		"value":    tx.Value().String(),
+		"data":     hexutil.Encode(tx.Data()),
+	}
+	if tx.To() != nil {
+		params["to"] = tx.To().Hex()
+	}
+	return params
+}
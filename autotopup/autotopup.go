@@ -0,0 +1,238 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package autotopup tops up consumer channels whose balance falls below a threshold.
+package autotopup
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrInvalidRule is returned for rules that can not be applied.
+var ErrInvalidRule = errors.New("invalid top-up rule")
+
+// BalanceReader returns myst balances. The client can be used.
+type BalanceReader interface {
+	GetMystBalance(mystAddress, identity common.Address) (*big.Int, error)
+}
+
+// Source sends myst to a channel, e.g. a wallet transfer, a DEX swap or an on-ramp order.
+type Source interface {
+	// Name identifies the source in logs and actions.
+	Name() string
+	// TopUp starts a top-up of the channel and returns a reference of it, such as a transaction hash or an order id.
+	TopUp(channel common.Address, amount *big.Int) (string, error)
+}
+
+// Rule tops up a channel back to the target once the balance falls below the threshold.
+type Rule struct {
+	Channel   common.Address
+	Token     common.Address
+	Threshold *big.Int
+	Target    *big.Int
+	// DailyCap limits the amount topped up per UTC day, nil means no limit.
+	DailyCap *big.Int
+	// Cooldown is the minimum time between two top-ups of the channel.
+	// It should cover the time a top-up takes to be reflected in the balance.
+	Cooldown time.Duration
+	Source   Source
+}
+
+func (r Rule) validate() error {
+	switch {
+	case r.Source == nil:
+		return fmt.Errorf("%w: no source", ErrInvalidRule)
+	case r.Threshold == nil || r.Target == nil:
+		return fmt.Errorf("%w: threshold and target are required", ErrInvalidRule)
+	case r.Target.Cmp(r.Threshold) <= 0:
+		return fmt.Errorf("%w: target must be above the threshold", ErrInvalidRule)
+	}
+	return nil
+}
+
+// Action is a triggered top-up.
+type Action struct {
+	Channel common.Address
+	Source  string
+	Amount  *big.Int
+	// Ref is the reference returned by the source.
+	Ref  string
+	Time time.Time
+}
+
+// LogFunc is called with errors that occur while checking and topping up the channels.
+type LogFunc func(error)
+
+type channelState struct {
+	last  time.Time
+	day   string
+	spent *big.Int
+}
+
+// Policy periodically checks the channel balances and triggers the top-ups.
+type Policy struct {
+	balances BalanceReader
+	interval time.Duration
+	logFunc  LogFunc
+	now      func() time.Time
+
+	lock   sync.Mutex
+	rules  map[common.Address]Rule
+	states map[common.Address]*channelState
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewPolicy returns a new top-up policy checking the balances at the interval.
+func NewPolicy(balances BalanceReader, interval time.Duration) *Policy {
+	return &Policy{
+		balances: balances,
+		interval: interval,
+		logFunc:  func(error) {},
+		now:      time.Now,
+		rules:    make(map[common.Address]Rule),
+		states:   make(map[common.Address]*channelState),
+		stop:     make(chan struct{}),
+	}
+}
+
+// AttachLogFunc attaches a log func to the policy.
+// Not thread safe, call before Run.
+func (p *Policy) AttachLogFunc(f LogFunc) {
+	p.logFunc = f
+}
+
+// SetRule sets the rule of the channel, replacing the previous one. Caps and cooldowns are kept.
+func (p *Policy) SetRule(r Rule) error {
+	if err := r.validate(); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.rules[r.Channel] = r
+	return nil
+}
+
+// RemoveRule stops topping up the channel.
+func (p *Policy) RemoveRule(channel common.Address) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.rules, channel)
+}
+
+// Run checks the channels until stopped.
+func (p *Policy) Run() {
+	for {
+		p.Check()
+
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(p.interval):
+		}
+	}
+}
+
+// Stop stops the policy.
+func (p *Policy) Stop() {
+	p.once.Do(func() {
+		close(p.stop)
+	})
+}
+
+// Check checks all the channels once and returns the triggered top-ups.
+func (p *Policy) Check() []Action {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var res []Action
+	for _, r := range p.rules {
+		action, err := p.check(r)
+		if err != nil {
+			p.logFunc(fmt.Errorf("could not top up channel %v: %w", r.Channel.Hex(), err))
+			continue
+		}
+		if action != nil {
+			res = append(res, *action)
+		}
+	}
+	return res
+}
+
+func (p *Policy) check(r Rule) (*Action, error) {
+	now := p.now()
+	state := p.state(r.Channel, now)
+	if !state.last.IsZero() && now.Sub(state.last) < r.Cooldown {
+		return nil, nil
+	}
+
+	balance, err := p.balances.GetMystBalance(r.Token, r.Channel)
+	if err != nil {
+		return nil, fmt.Errorf("could not get balance: %w", err)
+	}
+	if balance.Cmp(r.Threshold) >= 0 {
+		return nil, nil
+	}
+
+	amount := new(big.Int).Sub(r.Target, balance)
+	if r.DailyCap != nil {
+		left := new(big.Int).Sub(r.DailyCap, state.spent)
+		if left.Sign() <= 0 {
+			return nil, nil
+		}
+		if amount.Cmp(left) > 0 {
+			amount = left
+		}
+	}
+
+	ref, err := r.Source.TopUp(r.Channel, amount)
+	if err != nil {
+		return nil, fmt.Errorf("source %v failed: %w", r.Source.Name(), err)
+	}
+
+	state.last = now
+	state.spent.Add(state.spent, amount)
+	return &Action{
+		Channel: r.Channel,
+		Source:  r.Source.Name(),
+		Amount:  amount,
+		Ref:     ref,
+		Time:    now,
+	}, nil
+}
+
+// state returns the state of the channel, resetting the daily spending on a new UTC day.
+func (p *Policy) state(channel common.Address, now time.Time) *channelState {
+	day := now.UTC().Format("2006-01-02")
+	s, ok := p.states[channel]
+	if !ok {
+		s = &channelState{spent: new(big.Int)}
+		p.states[channel] = s
+	}
+	if s.day != day {
+		s.day = day
+		s.spent = new(big.Int)
+	}
+	return s
+}
@@ -0,0 +1,119 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package autotopup
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type balances map[common.Address]*big.Int
+
+func (b balances) GetMystBalance(mystAddress, identity common.Address) (*big.Int, error) {
+	if v, ok := b[identity]; ok {
+		return new(big.Int).Set(v), nil
+	}
+	return new(big.Int), nil
+}
+
+func TestPolicy(t *testing.T) {
+	channel := common.HexToAddress("0x1")
+	bal := balances{channel: big.NewInt(50)}
+
+	var topups []*big.Int
+	source := SourceFunc{SourceName: "test", Func: func(ch common.Address, amount *big.Int) (string, error) {
+		topups = append(topups, amount)
+		bal[ch] = new(big.Int).Add(bal[ch], amount)
+		return "ref", nil
+	}}
+
+	now := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	p := NewPolicy(bal, time.Minute)
+	p.now = func() time.Time { return now }
+
+	assert.True(t, errors.Is(p.SetRule(Rule{Channel: channel, Threshold: big.NewInt(100), Target: big.NewInt(100), Source: source}), ErrInvalidRule))
+	assert.NoError(t, p.SetRule(Rule{
+		Channel:   channel,
+		Threshold: big.NewInt(100),
+		Target:    big.NewInt(200),
+		DailyCap:  big.NewInt(250),
+		Cooldown:  time.Hour,
+		Source:    source,
+	}))
+
+	actions := p.Check()
+	assert.Len(t, actions, 1)
+	assert.Equal(t, big.NewInt(150), actions[0].Amount)
+	assert.Equal(t, "test", actions[0].Source)
+	assert.Equal(t, "ref", actions[0].Ref)
+
+	// within the cooldown
+	bal[channel] = big.NewInt(0)
+	now = now.Add(30 * time.Minute)
+	assert.Empty(t, p.Check())
+
+	// limited by the daily cap
+	now = now.Add(time.Hour)
+	actions = p.Check()
+	assert.Len(t, actions, 1)
+	assert.Equal(t, big.NewInt(100), actions[0].Amount)
+
+	// cap exhausted
+	bal[channel] = big.NewInt(0)
+	now = now.Add(2 * time.Hour)
+	assert.Empty(t, p.Check())
+
+	// next day
+	now = now.Add(24 * time.Hour)
+	actions = p.Check()
+	assert.Len(t, actions, 1)
+	assert.Equal(t, big.NewInt(200), actions[0].Amount)
+	assert.Len(t, topups, 3)
+
+	// above the threshold
+	now = now.Add(2 * time.Hour)
+	assert.Empty(t, p.Check())
+}
+
+func TestPolicyLogsSourceFailures(t *testing.T) {
+	channel := common.HexToAddress("0x1")
+	p := NewPolicy(balances{}, time.Minute)
+
+	var logged []error
+	p.AttachLogFunc(func(err error) { logged = append(logged, err) })
+
+	assert.NoError(t, p.SetRule(Rule{
+		Channel:   channel,
+		Threshold: big.NewInt(1),
+		Target:    big.NewInt(2),
+		Source: SourceFunc{SourceName: "failing", Func: func(common.Address, *big.Int) (string, error) {
+			return "", errors.New("boom")
+		}},
+	}))
+
+	assert.Empty(t, p.Check())
+	assert.Len(t, logged, 1)
+
+	// failed top-ups do not start the cooldown
+	assert.Empty(t, p.Check())
+	assert.Len(t, logged, 2)
+}
@@ -0,0 +1,126 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package autotopup
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/onramp"
+	"github.com/mysteriumnetwork/payments/rates"
+)
+
+// SourceFunc allows using ordinary functions as sources, e.g. to plug in a DEX swap.
+type SourceFunc struct {
+	SourceName string
+	Func       func(channel common.Address, amount *big.Int) (string, error)
+}
+
+// Name returns the source name.
+func (s SourceFunc) Name() string {
+	return s.SourceName
+}
+
+// TopUp calls the func.
+func (s SourceFunc) TopUp(channel common.Address, amount *big.Int) (string, error) {
+	return s.Func(channel, amount)
+}
+
+// MystSender transfers myst. The client can be used.
+type MystSender interface {
+	TransferMyst(req client.TransferRequest) (*types.Transaction, error)
+}
+
+// WalletSource transfers myst from a wallet to the channel.
+type WalletSource struct {
+	sender MystSender
+	token  common.Address
+	wallet client.WriteRequest
+}
+
+// NewWalletSource returns a new wallet source, the write request signs for the wallet.
+func NewWalletSource(sender MystSender, token common.Address, wallet client.WriteRequest) *WalletSource {
+	return &WalletSource{
+		sender: sender,
+		token:  token,
+		wallet: wallet,
+	}
+}
+
+// Name returns "wallet".
+func (ws *WalletSource) Name() string {
+	return "wallet"
+}
+
+// TopUp transfers the amount and returns the transaction hash.
+func (ws *WalletSource) TopUp(channel common.Address, amount *big.Int) (string, error) {
+	tx, err := ws.sender.TransferMyst(client.TransferRequest{
+		MystAddress:  ws.token,
+		Recipient:    channel,
+		Amount:       amount,
+		WriteRequest: ws.wallet,
+	})
+	if err != nil {
+		return "", err
+	}
+	return tx.Hash().Hex(), nil
+}
+
+// OnrampSource creates fiat on-ramp orders for the amount.
+type OnrampSource struct {
+	provider onramp.Provider
+	prices   rates.PriceOracle
+	chainID  int64
+	currency string
+}
+
+// NewOnrampSource returns a new on-ramp source paying in the given currency.
+func NewOnrampSource(provider onramp.Provider, prices rates.PriceOracle, chainID int64, currency string) *OnrampSource {
+	return &OnrampSource{
+		provider: provider,
+		prices:   prices,
+		chainID:  chainID,
+		currency: currency,
+	}
+}
+
+// Name returns "onramp".
+func (src *OnrampSource) Name() string {
+	return "onramp"
+}
+
+// TopUp creates an order worth the amount at the current price and returns the order id.
+func (src *OnrampSource) TopUp(channel common.Address, amount *big.Int) (string, error) {
+	price, err := src.prices.PriceAt(src.currency, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	order, err := src.provider.CreateOrder(onramp.OrderRequest{
+		ChainID:    src.chainID,
+		Channel:    channel,
+		Currency:   src.currency,
+		FiatAmount: rates.FiatValue(amount, price),
+	})
+	if err != nil {
+		return "", err
+	}
+	return order.ID, nil
+}
@@ -18,13 +18,13 @@ package bindings
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/pkg/errors"
 )
 
 // EstimateOpts is the collection of authorization data required to perform validation of Ethereum transaction.
@@ -59,9 +59,9 @@ func NewContractEstimator(address common.Address, binding string, transactor bin
 
 // Estimate simulates the (paid) contract method with params as input values and estimates gas needed.
 func (drt *ContractEstimator) Estimate(opts *EstimateOpts) (uint64, error) {
-	input, err := drt.contractAbi.Pack(opts.Method, opts.Params...)
+	msg, err := drt.CallMsg(opts)
 	if err != nil {
-		return 0, errors.Wrap(err, "could not pack input")
+		return 0, err
 	}
 
 	ctx := opts.Context
@@ -69,9 +69,19 @@ func (drt *ContractEstimator) Estimate(opts *EstimateOpts) (uint64, error) {
 		ctx = context.TODO()
 	}
 
-	return drt.transactor.EstimateGas(ctx, ethereum.CallMsg{
+	return drt.transactor.EstimateGas(ctx, msg)
+}
+
+// CallMsg returns the call of the contract method with params as input values.
+func (drt *ContractEstimator) CallMsg(opts *EstimateOpts) (ethereum.CallMsg, error) {
+	input, err := drt.contractAbi.Pack(opts.Method, opts.Params...)
+	if err != nil {
+		return ethereum.CallMsg{}, fmt.Errorf("could not pack input: %w", err)
+	}
+
+	return ethereum.CallMsg{
 		From: opts.From,
 		To:   &drt.contractAddress,
 		Data: input,
-	})
+	}, nil
 }
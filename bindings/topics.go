@@ -24,3 +24,6 @@ var ChannelImplementationExitRequestTopics = [][]common.Hash{
 		common.HexToHash("0xe60f0366d8d61555184ea027447889648bae94ebfb1202a39544b6b6803969db"),
 	},
 }
+
+// HermesImplementationPromiseSettledTopic the topic for hermes promise settled events.
+var HermesImplementationPromiseSettledTopic = common.HexToHash("0xa5a1f05785a942c5f624cee545c68394881a83bcaf21a83f4d76a9e8240a5668")
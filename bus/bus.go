@@ -0,0 +1,107 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package bus provides an in-process event dispatcher the payments subsystems publish to,
+// so reacting to them does not require coupling to the publishing component.
+package bus
+
+import (
+	"sync"
+)
+
+// Topic identifies a kind of events.
+type Topic string
+
+// Event is published on the bus. The concrete types are declared next to their publishers.
+type Event interface {
+	Topic() Topic
+}
+
+// Handler receives published events.
+type Handler func(Event)
+
+type subscription struct {
+	id      uint64
+	handler Handler
+}
+
+// Bus dispatches events to the handlers subscribed to their topic.
+// Handlers are called synchronously, in the order of subscription, on the goroutine of the publisher,
+// so they should return quickly and hand long running work off to their own goroutines.
+type Bus struct {
+	lock   sync.RWMutex
+	nextID uint64
+	topics map[Topic][]subscription
+}
+
+// New returns a new event bus.
+func New() *Bus {
+	return &Bus{
+		topics: make(map[Topic][]subscription),
+	}
+}
+
+// Subscribe calls the handler for every event published to the topic.
+// It returns a function removing the subscription.
+func (b *Bus) Subscribe(topic Topic, h Handler) func() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.nextID++
+	id := b.nextID
+	b.topics[topic] = append(b.topics[topic], subscription{id: id, handler: h})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.unsubscribe(topic, id)
+		})
+	}
+}
+
+func (b *Bus) unsubscribe(topic Topic, id uint64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	subs := b.topics[topic]
+	res := make([]subscription, 0, len(subs))
+	for _, s := range subs {
+		if s.id != id {
+			res = append(res, s)
+		}
+	}
+	if len(res) == 0 {
+		delete(b.topics, topic)
+		return
+	}
+	b.topics[topic] = res
+}
+
+// Publish delivers the event to the handlers subscribed to its topic. Publishing to a nil bus is a no-op,
+// so components can publish unconditionally.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.lock.RLock()
+	subs := b.topics[e.Topic()]
+	b.lock.RUnlock()
+
+	for _, s := range subs {
+		s.handler(e)
+	}
+}
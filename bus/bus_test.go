@@ -0,0 +1,56 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testEvent struct {
+	topic Topic
+	value int
+}
+
+func (e testEvent) Topic() Topic {
+	return e.topic
+}
+
+func TestBus(t *testing.T) {
+	b := New()
+
+	var first, second []int
+	unsubscribe := b.Subscribe("a", func(e Event) {
+		first = append(first, e.(testEvent).value)
+	})
+	b.Subscribe("a", func(e Event) {
+		second = append(second, e.(testEvent).value)
+	})
+
+	b.Publish(testEvent{topic: "a", value: 1})
+	b.Publish(testEvent{topic: "b", value: 2})
+	unsubscribe()
+	unsubscribe()
+	b.Publish(testEvent{topic: "a", value: 3})
+
+	assert.Equal(t, []int{1}, first)
+	assert.Equal(t, []int{1, 3}, second)
+
+	var nilBus *Bus
+	nilBus.Publish(testEvent{topic: "a"})
+}
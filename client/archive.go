@@ -0,0 +1,101 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// DefaultArchiveDepth is the number of recent blocks pruned nodes usually keep the full state of.
+const DefaultArchiveDepth = 128
+
+type archive struct {
+	// head is the latest block number seen, accessed atomically.
+	// It is the first field to keep it 64 bit aligned on 32 bit platforms.
+	head   uint64
+	client ethClientGetter
+	depth  uint64
+}
+
+// AttachArchiveClient routes the historical queries to a separate archive endpoint:
+// log queries always, and block reads when the block is more than depth blocks behind the latest seen head.
+// The latest state, transactions and subscriptions keep using the primary endpoint.
+// Not thread safe, call before using the blockchain.
+func (bc *Blockchain) AttachArchiveClient(client ethClientGetter, depth uint64) {
+	bc.archive = &archive{client: client, depth: depth}
+}
+
+// logClient returns the client log queries are sent to.
+func (bc *Blockchain) logClient() *ethclient.Client {
+	if bc.archive == nil {
+		return bc.ethClient.Client()
+	}
+	return bc.archive.client.Client()
+}
+
+// blockClient returns the client reads of the given block are sent to.
+func (bc *Blockchain) blockClient(number *big.Int) ethClientGetter {
+	if bc.archive == nil || number == nil || !number.IsUint64() {
+		return bc.ethClient
+	}
+	head := atomic.LoadUint64(&bc.archive.head)
+	if head > bc.archive.depth && number.Uint64() < head-bc.archive.depth {
+		return bc.archive.client
+	}
+	return bc.ethClient
+}
+
+// observeHead records the latest head, it decides which block reads are historical.
+func (bc *Blockchain) observeHead(header *types.Header) {
+	if bc.archive == nil || header == nil || header.Number == nil || !header.Number.IsUint64() {
+		return
+	}
+	n := header.Number.Uint64()
+	for {
+		current := atomic.LoadUint64(&bc.archive.head)
+		if n <= current || atomic.CompareAndSwapUint64(&bc.archive.head, current, n) {
+			return
+		}
+	}
+}
+
+// archiveLogStreamClient subscribes through the primary client and backfills through the archive client.
+type archiveLogStreamClient struct {
+	*ethclient.Client
+	archive *ethclient.Client
+}
+
+func (c archiveLogStreamClient) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	return c.archive.FilterLogs(ctx, q)
+}
+
+func (bc *Blockchain) logStreamClient() logStreamClient {
+	var client logStreamClient = bc.ethClient.Client()
+	if bc.archive != nil {
+		client = archiveLogStreamClient{Client: bc.ethClient.Client(), archive: bc.archive.client.Client()}
+	}
+	if bc.polling != nil {
+		return NewPollingFilterer(client, bc.bcTimeout, *bc.polling)
+	}
+	return client
+}
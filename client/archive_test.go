@@ -0,0 +1,92 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+type ethService struct {
+	name string
+	head int64
+}
+
+func (es *ethService) GetBlockByNumber(number string, full bool) *types.Header {
+	n := big.NewInt(es.head)
+	if number != "latest" {
+		n, _ = new(big.Int).SetString(number[2:], 16)
+	}
+	return &types.Header{Number: n, Difficulty: big.NewInt(1), Extra: []byte(es.name)}
+}
+
+func (es *ethService) GetLogs(q map[string]interface{}) []types.Log {
+	return []types.Log{{Address: common.HexToAddress("0x1"), Topics: []common.Hash{}, Data: []byte(es.name)}}
+}
+
+func newInProcClient(t *testing.T, svc *ethService) (*ReconnectableEthClient, *rpc.Server) {
+	server := rpc.NewServer()
+	assert.NoError(t, server.RegisterName("eth", svc))
+	client, err := NewReconnectableEthClientWithDialer(InProcDialer(server))
+	assert.NoError(t, err)
+	return client, server
+}
+
+func TestArchiveRouting(t *testing.T) {
+	primary := &ethService{name: "primary", head: 1000}
+	archive := &ethService{name: "archive", head: 1000}
+	primaryClient, primaryServer := newInProcClient(t, primary)
+	defer primaryServer.Stop()
+	archiveClient, archiveServer := newInProcClient(t, archive)
+	defer archiveServer.Stop()
+	bc := NewBlockchain(primaryClient, time.Second)
+
+	// without an archive everything goes to the primary endpoint
+	logs, err := bc.FilterLogs(ethereum.FilterQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, "primary", string(logs[0].Data))
+
+	bc.AttachArchiveClient(archiveClient, DefaultArchiveDepth)
+
+	logs, err = bc.FilterLogs(ethereum.FilterQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, "archive", string(logs[0].Data))
+
+	// old blocks are only recognised once the head is known
+	h, err := bc.HeaderByNumber(big.NewInt(10))
+	assert.NoError(t, err)
+	assert.Equal(t, "primary", string(h.Extra))
+
+	h, err = bc.HeaderByNumber(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "primary", string(h.Extra))
+
+	h, err = bc.HeaderByNumber(big.NewInt(10))
+	assert.NoError(t, err)
+	assert.Equal(t, "archive", string(h.Extra))
+
+	h, err = bc.HeaderByNumber(big.NewInt(900))
+	assert.NoError(t, err)
+	assert.Equal(t, "primary", string(h.Extra))
+}
@@ -0,0 +1,81 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/bindings"
+)
+
+// The AtBlock variants read the contract state as of the given block, e.g. for dispute investigation and historical accounting.
+// Blocks older than the state kept by pruned nodes require an archive client, see AttachArchiveClient.
+// A nil block reads the latest state.
+
+// GetProviderChannelAtBlock returns the provider channel as of the given block.
+func (bc *Blockchain) GetProviderChannelAtBlock(hermesAddress, addressToCheck common.Address, block *big.Int) (ProviderChannel, error) {
+	addressBytes, err := bc.getProviderChannelAddressBytes(hermesAddress, addressToCheck)
+	if err != nil {
+		return ProviderChannel{}, wrap(err, "could not calculate provider channel address")
+	}
+	caller, err := bindings.NewHermesImplementationCaller(hermesAddress, bc.blockClient(block).Client())
+	if err != nil {
+		return ProviderChannel{}, wrap(err, "could not create hermes caller")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+
+	ch, err := caller.Channels(&bind.CallOpts{
+		BlockNumber: block,
+		Context:     ctx,
+	}, addressBytes)
+	return ch, wrap(err, "could not get provider channel from bc")
+}
+
+// GetMystBalanceAtBlock returns the myst balance of the address as of the given block.
+func (bc *Blockchain) GetMystBalanceAtBlock(mystAddress, address common.Address, block *big.Int) (*big.Int, error) {
+	c, err := bindings.NewMystTokenCaller(mystAddress, bc.blockClient(block).Client())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+	return c.BalanceOf(&bind.CallOpts{
+		BlockNumber: block,
+		Context:     ctx,
+	}, address)
+}
+
+// GetBeneficiaryAtBlock returns the beneficiary of the identity as of the given block.
+func (bc *Blockchain) GetBeneficiaryAtBlock(registryAddress, identity common.Address, block *big.Int) (common.Address, error) {
+	caller, err := bindings.NewRegistryCaller(registryAddress, bc.blockClient(block).Client())
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+	return caller.GetBeneficiary(&bind.CallOpts{
+		BlockNumber: block,
+		Context:     ctx,
+	}, identity)
+}
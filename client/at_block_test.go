@@ -0,0 +1,90 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// stateService answers every call with the block number the call was made at, offset by the service offset.
+type stateService struct {
+	head   int64
+	offset int64
+}
+
+func (ss *stateService) GetBlockByNumber(number string, full bool) *types.Header {
+	return &types.Header{Number: big.NewInt(ss.head), Difficulty: big.NewInt(1)}
+}
+
+func (ss *stateService) Call(msg map[string]interface{}, block string) hexutil.Bytes {
+	n := big.NewInt(ss.head)
+	if block != "latest" {
+		n, _ = new(big.Int).SetString(block[2:], 16)
+	}
+	return common.LeftPadBytes(new(big.Int).Add(n, big.NewInt(ss.offset)).Bytes(), 32)
+}
+
+func newStateClient(t *testing.T, svc *stateService) (*ReconnectableEthClient, *rpc.Server) {
+	server := rpc.NewServer()
+	assert.NoError(t, server.RegisterName("eth", svc))
+	client, err := NewReconnectableEthClientWithDialer(InProcDialer(server))
+	assert.NoError(t, err)
+	return client, server
+}
+
+func TestGetMystBalanceAtBlock(t *testing.T) {
+	primaryClient, primaryServer := newStateClient(t, &stateService{head: 1000})
+	defer primaryServer.Stop()
+	archiveClient, archiveServer := newStateClient(t, &stateService{head: 1000, offset: 1e6})
+	defer archiveServer.Stop()
+
+	bc := NewBlockchain(primaryClient, time.Second)
+	token, address := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+
+	balance, err := bc.GetMystBalanceAtBlock(token, address, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1000), balance)
+
+	balance, err = bc.GetMystBalanceAtBlock(token, address, big.NewInt(10))
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(10), balance)
+
+	// once the head is known, blocks beyond the archive depth are read from the archive
+	bc.AttachArchiveClient(archiveClient, DefaultArchiveDepth)
+	_, err = bc.HeaderByNumber(nil)
+	assert.NoError(t, err)
+
+	balance, err = bc.GetMystBalanceAtBlock(token, address, big.NewInt(10))
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1e6+10), balance)
+
+	balance, err = bc.GetMystBalanceAtBlock(token, address, big.NewInt(990))
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(990), balance)
+
+	beneficiary, err := bc.GetBeneficiaryAtBlock(common.HexToAddress("0x3"), address, big.NewInt(990))
+	assert.NoError(t, err)
+	assert.Equal(t, common.BigToAddress(big.NewInt(990)), beneficiary)
+}
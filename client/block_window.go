@@ -0,0 +1,103 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import "sync"
+
+// DefaultMaxBackfillBatchSize is the largest block window backfills grow to.
+const DefaultMaxBackfillBatchSize = 100000
+
+// DefaultBackfillTargetLogs is the number of logs per query the backfill block window aims for.
+const DefaultBackfillTargetLogs = 5000
+
+// BlockWindow sizes the block ranges of log queries.
+// It grows while queries return few logs and shrinks when they return too many or fail,
+// which keeps archival nodes busy with large ranges while staying under the response limits of public endpoints.
+//
+// The current size can be persisted and used as the initial size later to warm start scanning.
+type BlockWindow struct {
+	lock       sync.Mutex
+	size       uint64
+	min        uint64
+	max        uint64
+	targetLogs int
+}
+
+// NewBlockWindow returns a new block window starting at the initial size.
+func NewBlockWindow(initial, min, max uint64, targetLogs int) *BlockWindow {
+	if min == 0 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	w := &BlockWindow{
+		min:        min,
+		max:        max,
+		targetLogs: targetLogs,
+	}
+	w.size = w.clamp(initial)
+	return w
+}
+
+// NewDefaultBlockWindow returns the block window used by log stream backfills.
+func NewDefaultBlockWindow() *BlockWindow {
+	return NewBlockWindow(DefaultBackfillBatchSize, 1, DefaultMaxBackfillBatchSize, DefaultBackfillTargetLogs)
+}
+
+// Size returns the number of blocks to query next.
+func (w *BlockWindow) Size() uint64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.size
+}
+
+// Success adjusts the window after a query of the given size returned the given number of logs.
+func (w *BlockWindow) Success(size uint64, logs int) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	switch {
+	case logs > w.targetLogs:
+		w.size = w.clamp(size / 2)
+	case logs < w.targetLogs/2 && size >= w.size:
+		w.size = w.clamp(size * 2)
+	}
+}
+
+// Failure halves the window after a failed query of the given size.
+// It returns false if the window is already at its minimum, in which case the error is not caused by the range size.
+func (w *BlockWindow) Failure(size uint64) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if size <= w.min {
+		return false
+	}
+	w.size = w.clamp(size / 2)
+	return true
+}
+
+func (w *BlockWindow) clamp(size uint64) uint64 {
+	if size < w.min {
+		return w.min
+	}
+	if size > w.max {
+		return w.max
+	}
+	return size
+}
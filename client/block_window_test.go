@@ -0,0 +1,55 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockWindow(t *testing.T) {
+	w := NewBlockWindow(100, 10, 1000, 50)
+	assert.Equal(t, uint64(100), w.Size())
+
+	w.Success(100, 10)
+	assert.Equal(t, uint64(200), w.Size())
+
+	// a stale result of a smaller query does not grow the window again
+	w.Success(100, 10)
+	assert.Equal(t, uint64(200), w.Size())
+
+	w.Success(200, 100)
+	assert.Equal(t, uint64(100), w.Size())
+
+	w.Success(100, 40)
+	assert.Equal(t, uint64(100), w.Size())
+
+	assert.True(t, w.Failure(100))
+	assert.True(t, w.Failure(50))
+	assert.Equal(t, uint64(25), w.Size())
+	assert.True(t, w.Failure(25))
+	assert.Equal(t, uint64(12), w.Size())
+	assert.True(t, w.Failure(12))
+	assert.Equal(t, uint64(10), w.Size())
+	assert.False(t, w.Failure(10))
+
+	for i := 0; i < 20; i++ {
+		w.Success(w.Size(), 0)
+	}
+	assert.Equal(t, uint64(1000), w.Size())
+}
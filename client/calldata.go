@@ -0,0 +1,100 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+// Calldata is a fully encoded contract call that can be broadcast by an external relayer or custodial signer.
+type Calldata struct {
+	From common.Address
+	To   common.Address
+	Data []byte
+}
+
+// ForwardRequest wraps the call into an ERC-2771 meta-transaction to be signed by From and executed by a trusted forwarder.
+func (c Calldata) ForwardRequest(gas uint64, nonce *big.Int) crypto.ForwardRequest {
+	return crypto.ForwardRequest{
+		From:  c.From,
+		To:    c.To,
+		Value: new(big.Int),
+		Gas:   gas,
+		Nonce: nonce,
+		Data:  c.Data,
+	}
+}
+
+// PackRegisterIdentity returns the calldata of the identity registration.
+// The beneficiary is checked against the policy, if one is given.
+func PackRegisterIdentity(rr RegistrationRequest, policy AddressPolicy) (Calldata, error) {
+	if policy != nil {
+		if err := policy.CheckAddress(rr.Beneficiary); err != nil {
+			return Calldata{}, err
+		}
+	}
+
+	return packCall(rr.RegistryAddress, bindings.RegistryABI, rr.toEstimateOps())
+}
+
+// PackSettlePromise returns the calldata of the promise settlement on a consumer channel.
+func PackSettlePromise(req SettleRequest) (Calldata, error) {
+	return packCall(req.ChannelID, bindings.ChannelImplementationABI, req.toEstimateOps())
+}
+
+// PackSettleAndRebalance returns the calldata of the hermes promise settlement.
+func PackSettleAndRebalance(req SettleAndRebalanceRequest) (Calldata, error) {
+	return packCall(req.HermesID, bindings.HermesImplementationABI, req.toEstimateOps())
+}
+
+// PackSettleWithBeneficiary returns the calldata of the hermes promise settlement that also sets the beneficiary.
+// The beneficiary is checked against the policy, if one is given.
+func PackSettleWithBeneficiary(req SettleWithBeneficiaryRequest, policy AddressPolicy) (Calldata, error) {
+	if policy != nil {
+		if err := policy.CheckAddress(req.Beneficiary); err != nil {
+			return Calldata{}, err
+		}
+	}
+
+	return packCall(req.HermesID, bindings.HermesImplementationABI, req.toEstimateOps())
+}
+
+// PackSettleIntoStake returns the calldata of the hermes promise settlement into the provider stake.
+func PackSettleIntoStake(req SettleIntoStakeRequest) (Calldata, error) {
+	return packCall(req.HermesID, bindings.HermesImplementationABI, req.toEstimateOps())
+}
+
+func packCall(to common.Address, contractABI string, opts *bindings.EstimateOpts) (Calldata, error) {
+	parsed, err := abi.JSON(strings.NewReader(contractABI))
+	if err != nil {
+		return Calldata{}, err
+	}
+
+	data, err := parsed.Pack(opts.Method, opts.Params...)
+	if err != nil {
+		return Calldata{}, fmt.Errorf("could not pack %v: %w", opts.Method, err)
+	}
+
+	return Calldata{From: opts.From, To: to, Data: data}, nil
+}
@@ -0,0 +1,179 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackSettleAndRebalance(t *testing.T) {
+	req := SettleAndRebalanceRequest{
+		WriteRequest: WriteRequest{Identity: common.HexToAddress("0x3")},
+		HermesID:     common.HexToAddress("0x1"),
+		ProviderID:   common.HexToAddress("0x2"),
+		Promise: crypto.Promise{
+			Amount:    big.NewInt(100),
+			Fee:       big.NewInt(1),
+			R:         []byte{7},
+			Signature: []byte{1, 2, 3},
+		},
+	}
+
+	call, err := PackSettleAndRebalance(req)
+	assert.NoError(t, err)
+	assert.Equal(t, req.HermesID, call.To)
+	assert.Equal(t, req.Identity, call.From)
+
+	parsed, err := abi.JSON(strings.NewReader(bindings.HermesImplementationABI))
+	assert.NoError(t, err)
+	method := parsed.Methods["settlePromise"]
+	assert.Equal(t, method.ID, call.Data[:4])
+
+	values, err := method.Inputs.UnpackValues(call.Data[4:])
+	assert.NoError(t, err)
+	assert.Equal(t, req.ProviderID, values[0])
+	assert.Equal(t, big.NewInt(100), values[1])
+	assert.Equal(t, []byte{1, 2, 3}, values[4])
+
+	fr := call.ForwardRequest(200000, big.NewInt(4))
+	assert.Equal(t, req.Identity, fr.From)
+	assert.Equal(t, req.HermesID, fr.To)
+	assert.Equal(t, call.Data, fr.Data)
+}
+
+func TestPackRegisterIdentity(t *testing.T) {
+	rr := RegistrationRequest{
+		WriteRequest:    WriteRequest{Identity: common.HexToAddress("0x3")},
+		RegistryAddress: common.HexToAddress("0x5"),
+		HermesID:        common.HexToAddress("0x1"),
+		Stake:           big.NewInt(0),
+		TransactorFee:   big.NewInt(10),
+		Beneficiary:     common.HexToAddress("0x4"),
+		Signature:       []byte{1},
+	}
+
+	_, err := PackRegisterIdentity(rr, denyAddresses{rr.Beneficiary})
+	assert.Error(t, err)
+
+	call, err := PackRegisterIdentity(rr, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, rr.RegistryAddress, call.To)
+
+	parsed, err := abi.JSON(strings.NewReader(bindings.RegistryABI))
+	assert.NoError(t, err)
+	assert.Equal(t, parsed.Methods["registerIdentity"].ID, call.Data[:4])
+}
+
+type denyAddresses []common.Address
+
+func (d denyAddresses) CheckAddress(address common.Address) error {
+	for _, a := range d {
+		if a == address {
+			return errors.New("address denied")
+		}
+	}
+	return nil
+}
+
+func TestPackSettleWithBeneficiaryChecksPolicy(t *testing.T) {
+	req := SettleWithBeneficiaryRequest{
+		WriteRequest: WriteRequest{Identity: common.HexToAddress("0x3")},
+		HermesID:     common.HexToAddress("0x1"),
+		ProviderID:   common.HexToAddress("0x2"),
+		Beneficiary:  common.HexToAddress("0xbe"),
+		Promise: crypto.Promise{
+			Amount:    big.NewInt(100),
+			Fee:       big.NewInt(1),
+			R:         []byte{7},
+			Signature: []byte{1, 2, 3},
+		},
+		Signature: []byte{4},
+	}
+
+	_, err := PackSettleWithBeneficiary(req, denyAddresses{req.Beneficiary})
+	assert.Error(t, err)
+
+	call, err := PackSettleWithBeneficiary(req, nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, CheckCalldata(denyAddresses{common.HexToAddress("0xaa")}, call.Data))
+	assert.Error(t, CheckCalldata(denyAddresses{req.Beneficiary}, call.Data))
+	assert.NoError(t, CheckCalldata(nil, call.Data))
+}
+
+func TestCheckCalldata(t *testing.T) {
+	policy := denyAddresses{common.HexToAddress("0xbad")}
+
+	parsed, err := abi.JSON(strings.NewReader(bindings.ChannelImplementationABI))
+	assert.NoError(t, err)
+	data, err := parsed.Pack("setFundsDestination", common.HexToAddress("0xbad"))
+	assert.NoError(t, err)
+	assert.Error(t, CheckCalldata(policy, data))
+
+	data, err = parsed.Pack("setFundsDestination", common.HexToAddress("0x600d"))
+	assert.NoError(t, err)
+	assert.NoError(t, CheckCalldata(policy, data))
+
+	call, err := PackSettleAndRebalance(SettleAndRebalanceRequest{
+		Promise: crypto.Promise{Amount: big.NewInt(1), Fee: big.NewInt(0)},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, CheckCalldata(policy, call.Data))
+
+	assert.True(t, errors.Is(CheckCalldata(policy, []byte{1, 2, 3, 4}), ErrUncheckableCalldata))
+	assert.True(t, errors.Is(CheckCalldata(policy, nil), ErrUncheckableCalldata))
+}
+
+func TestAddressPolicyOnDirectCalls(t *testing.T) {
+	denied := common.HexToAddress("0xbad")
+	bc := &Blockchain{}
+	bc.AttachAddressPolicy(denyAddresses{denied})
+
+	_, err := bc.RegisterIdentity(RegistrationRequest{Beneficiary: denied})
+	assert.EqualError(t, err, "address denied", "RegisterIdentity")
+
+	_, err = bc.WithdrawHermesBalance(HermesWithdrawRequest{Beneficiary: denied})
+	assert.EqualError(t, err, "address denied", "WithdrawHermesBalance")
+
+	_, err = bc.RequestChannelExit(RequestChannelExitRequest{Beneficiary: denied})
+	assert.EqualError(t, err, "address denied", "RequestChannelExit")
+
+	_, err = bc.SetHermesFundsDestination(SetHermesFundsDestinationRequest{Destination: denied})
+	assert.EqualError(t, err, "address denied", "SetHermesFundsDestination")
+
+	_, err = bc.SettleWithBeneficiary(SettleWithBeneficiaryRequest{Beneficiary: denied})
+	assert.EqualError(t, err, "address denied", "SettleWithBeneficiary")
+
+	_, err = bc.RegisterIdentities(context.Background(), []common.Address{{1}}, BatchRegistrationOpts{
+		Beneficiary:    denied,
+		IdentitySigner: multiKeySigner{},
+	})
+	assert.EqualError(t, err, "address denied", "RegisterIdentities")
+
+	_, err = bc.ExecuteMetaTx(MetaTxRequest{})
+	assert.True(t, errors.Is(err, ErrUncheckableCalldata), "ExecuteMetaTx")
+}
@@ -0,0 +1,182 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Capability is a feature whose availability depends on the connected chain and the deployed contracts.
+type Capability string
+
+const (
+	// CapabilityEIP1559 is available if the chain has a base fee, so dynamic fee transactions can be sent.
+	CapabilityEIP1559 Capability = "eip1559"
+	// CapabilityPermit is available if the myst token supports EIP-2612 permit approvals.
+	CapabilityPermit Capability = "permit"
+	// CapabilityBatchedSettlement is available if hermes supports multicall, so several promises can be settled in one transaction.
+	CapabilityBatchedSettlement Capability = "batched_settlement"
+	// CapabilityCustomErrors is available if hermes is compiled with solidity 0.8.4 or newer and can revert with custom errors.
+	CapabilityCustomErrors Capability = "custom_errors"
+)
+
+// AllCapabilities lists the capabilities reported by Capabilities.
+var AllCapabilities = []Capability{CapabilityEIP1559, CapabilityPermit, CapabilityBatchedSettlement, CapabilityCustomErrors}
+
+var (
+	permitSelector    = crypto.Keccak256([]byte("permit(address,address,uint256,uint256,uint8,bytes32,bytes32)"))[:4]
+	multicallSelector = crypto.Keccak256([]byte("multicall(bytes[])"))[:4]
+	// solcMetadataKey is the CBOR encoded "solc" key of the metadata appended to the runtime code.
+	solcMetadataKey = common.FromHex("0x64736f6c6343")
+)
+
+// CapabilityContracts are the contracts capabilities are detected for.
+// Capabilities of a zero address contract are reported as unavailable.
+type CapabilityContracts struct {
+	MystToken common.Address
+	HermesID  common.Address
+}
+
+// CapabilityStatus is the state of a single capability.
+type CapabilityStatus struct {
+	// Available is true if the chain and the contracts support the capability.
+	Available bool
+	// Enabled is true if the capability is available and not disabled by a feature flag.
+	Enabled bool
+	// Reason explains why the capability is not available.
+	Reason string
+}
+
+// Capabilities maps capabilities to their status.
+type Capabilities map[Capability]CapabilityStatus
+
+// Enabled returns true if the capability is available and enabled.
+func (c Capabilities) Enabled(capability Capability) bool {
+	return c[capability].Enabled
+}
+
+// AttachFeatureFlags sets feature flags, a capability flagged false is reported as disabled even if it is available.
+// Not thread safe, call before getting capabilities.
+func (bc *Blockchain) AttachFeatureFlags(flags map[Capability]bool) {
+	bc.featureFlags = make(map[Capability]bool, len(flags))
+	for c, enabled := range flags {
+		bc.featureFlags[c] = enabled
+	}
+}
+
+// Capabilities reports which features are available on the connected chain with the given contracts.
+func (bc *Blockchain) Capabilities(contracts CapabilityContracts) (Capabilities, error) {
+	res := make(Capabilities, len(AllCapabilities))
+
+	eip1559, err := bc.hasBaseFee()
+	switch {
+	case errors.Is(err, ErrFeeHistoryUnsupported):
+		res[CapabilityEIP1559] = CapabilityStatus{Reason: "raw rpc calls are not supported by the client"}
+	case err != nil:
+		return nil, fmt.Errorf("could not get latest block: %w", err)
+	case !eip1559:
+		res[CapabilityEIP1559] = CapabilityStatus{Reason: "chain has no base fee"}
+	default:
+		res[CapabilityEIP1559] = CapabilityStatus{Available: true}
+	}
+
+	if contracts.MystToken == (common.Address{}) {
+		res[CapabilityPermit] = CapabilityStatus{Reason: "myst token not configured"}
+	} else {
+		code, err := bc.implementationCode(contracts.MystToken)
+		if err != nil {
+			return nil, fmt.Errorf("could not get myst token code: %w", err)
+		}
+		res[CapabilityPermit] = selectorStatus(code, permitSelector, "myst token has no permit")
+	}
+
+	if contracts.HermesID == (common.Address{}) {
+		res[CapabilityBatchedSettlement] = CapabilityStatus{Reason: "hermes not configured"}
+		res[CapabilityCustomErrors] = CapabilityStatus{Reason: "hermes not configured"}
+	} else {
+		code, err := bc.implementationCode(contracts.HermesID)
+		if err != nil {
+			return nil, fmt.Errorf("could not get hermes code: %w", err)
+		}
+		res[CapabilityBatchedSettlement] = selectorStatus(code, multicallSelector, "hermes has no multicall")
+		res[CapabilityCustomErrors] = customErrorsStatus(code)
+	}
+
+	for c, status := range res {
+		enabled, ok := bc.featureFlags[c]
+		status.Enabled = status.Available && (!ok || enabled)
+		res[c] = status
+	}
+	return res, nil
+}
+
+func (bc *Blockchain) hasBaseFee() (bool, error) {
+	getter, ok := bc.ethClient.(rpcClientGetter)
+	if !ok {
+		return false, ErrFeeHistoryUnsupported
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+
+	var block map[string]interface{}
+	if err := getter.RPCClient().CallContext(ctx, &block, "eth_getBlockByNumber", "latest", false); err != nil {
+		return false, err
+	}
+	return block["baseFeePerGas"] != nil, nil
+}
+
+// implementationCode returns the runtime code of the contract, or of its implementation if it is a proxy.
+func (bc *Blockchain) implementationCode(address common.Address) ([]byte, error) {
+	impl, err := bc.GetProxyImplementation(address)
+	if errors.Is(err, ErrNotProxy) {
+		impl = address
+	} else if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+	return bc.ethClient.Client().CodeAt(ctx, impl, nil)
+}
+
+// selectorStatus looks for the PUSH4 of the selector in the function dispatcher of the code.
+func selectorStatus(code, selector []byte, reason string) CapabilityStatus {
+	if bytes.Contains(code, append([]byte{0x63}, selector...)) {
+		return CapabilityStatus{Available: true}
+	}
+	return CapabilityStatus{Reason: reason}
+}
+
+func customErrorsStatus(code []byte) CapabilityStatus {
+	i := bytes.LastIndex(code, solcMetadataKey)
+	if i < 0 || len(code) < i+len(solcMetadataKey)+3 {
+		return CapabilityStatus{Reason: "hermes compiler version unknown"}
+	}
+
+	v := code[i+len(solcMetadataKey) : i+len(solcMetadataKey)+3]
+	if v[0] > 0 || v[1] > 8 || (v[1] == 8 && v[2] >= 4) {
+		return CapabilityStatus{Available: true}
+	}
+	return CapabilityStatus{Reason: fmt.Sprintf("hermes compiled with solidity %v.%v.%v", v[0], v[1], v[2])}
+}
@@ -0,0 +1,98 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+type capabilitiesService struct {
+	baseFee bool
+	code    map[common.Address]hexutil.Bytes
+}
+
+func (cs *capabilitiesService) GetBlockByNumber(number string, full bool) map[string]interface{} {
+	block := map[string]interface{}{"number": "0x10"}
+	if cs.baseFee {
+		block["baseFeePerGas"] = "0x7"
+	}
+	return block
+}
+
+func (cs *capabilitiesService) GetCode(address common.Address, block string) hexutil.Bytes {
+	return cs.code[address]
+}
+
+func (cs *capabilitiesService) GetStorageAt(address common.Address, key string, block string) hexutil.Bytes {
+	return make([]byte, 32)
+}
+
+func push4(selector []byte) []byte {
+	return append([]byte{0x63}, selector...)
+}
+
+func solcMetadata(major, minor, patch byte) []byte {
+	return append(append([]byte{}, solcMetadataKey...), major, minor, patch, 0x00, 0x33)
+}
+
+func TestCapabilities(t *testing.T) {
+	token, hermes, hermesImpl := common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")
+	proxyCode := append(append(append([]byte{}, minimalProxyPrefix...), hermesImpl.Bytes()...), minimalProxySuffix...)
+
+	service := &capabilitiesService{
+		baseFee: true,
+		code: map[common.Address]hexutil.Bytes{
+			token:      push4(permitSelector),
+			hermes:     proxyCode,
+			hermesImpl: append(push4(multicallSelector), solcMetadata(0, 8, 4)...),
+		},
+	}
+	server := rpc.NewServer()
+	defer server.Stop()
+	assert.NoError(t, server.RegisterName("eth", service))
+
+	client, err := NewReconnectableEthClientWithDialer(InProcDialer(server))
+	assert.NoError(t, err)
+	bc := NewBlockchain(client, time.Second)
+
+	caps, err := bc.Capabilities(CapabilityContracts{MystToken: token, HermesID: hermes})
+	assert.NoError(t, err)
+	for _, c := range AllCapabilities {
+		assert.True(t, caps.Enabled(c), c)
+	}
+
+	bc.AttachFeatureFlags(map[Capability]bool{CapabilityPermit: false})
+	service.baseFee = false
+	service.code[hermesImpl] = solcMetadata(0, 7, 6)
+
+	caps, err = bc.Capabilities(CapabilityContracts{MystToken: token})
+	assert.NoError(t, err)
+	assert.Equal(t, CapabilityStatus{Reason: "chain has no base fee"}, caps[CapabilityEIP1559])
+	assert.Equal(t, CapabilityStatus{Available: true}, caps[CapabilityPermit])
+	assert.Equal(t, CapabilityStatus{Reason: "hermes not configured"}, caps[CapabilityBatchedSettlement])
+
+	caps, err = bc.Capabilities(CapabilityContracts{HermesID: hermes})
+	assert.NoError(t, err)
+	assert.Equal(t, CapabilityStatus{Reason: "hermes has no multicall"}, caps[CapabilityBatchedSettlement])
+	assert.Equal(t, CapabilityStatus{Reason: "hermes compiled with solidity 0.7.6"}, caps[CapabilityCustomErrors])
+}
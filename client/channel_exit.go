@@ -0,0 +1,140 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/bindings"
+)
+
+// ChannelExit is the pending exit request of a consumer channel.
+type ChannelExit struct {
+	// Timelock is the block the exit can be finalized at, zero if no exit was requested.
+	Timelock    *big.Int
+	Beneficiary common.Address
+}
+
+// GetLatestChannelImplementation returns the channel implementation new channels of the registry are deployed with.
+func (bc *Blockchain) GetLatestChannelImplementation(registryAddress common.Address) (common.Address, error) {
+	caller, err := bindings.NewRegistryCaller(registryAddress, bc.ethClient.Client())
+	if err != nil {
+		return common.Address{}, fmt.Errorf("could not create registry caller: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+
+	return caller.GetChannelImplementation0(&bind.CallOpts{Context: ctx})
+}
+
+// GetChannelExit returns the pending exit request of the consumer channel.
+func (bc *Blockchain) GetChannelExit(channelAddress common.Address) (ChannelExit, error) {
+	caller, err := bindings.NewChannelImplementationCaller(channelAddress, bc.ethClient.Client())
+	if err != nil {
+		return ChannelExit{}, fmt.Errorf("could not create channel caller: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+
+	res, err := caller.ExitRequest(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return ChannelExit{}, err
+	}
+	return ChannelExit{Timelock: res.Timelock, Beneficiary: res.Beneficiary}, nil
+}
+
+// RequestChannelExitRequest represents all the parameters required to request the exit of a consumer channel.
+// The signature is the identity signature of the crypto.ExitRequest.
+type RequestChannelExitRequest struct {
+	WriteRequest
+	ChannelAddress common.Address
+	Beneficiary    common.Address
+	ValidUntil     *big.Int
+	Signature      []byte
+}
+
+func (r RequestChannelExitRequest) toEstimator(ethClient ethClientGetter) (*bindings.ContractEstimator, error) {
+	return bindings.NewContractEstimator(r.ChannelAddress, bindings.ChannelImplementationABI, ethClient.Client())
+}
+
+func (r RequestChannelExitRequest) toEstimateOps() *bindings.EstimateOpts {
+	return &bindings.EstimateOpts{
+		From:   r.Identity,
+		Method: "requestExit",
+		Params: []interface{}{r.Beneficiary, r.ValidUntil, r.Signature},
+	}
+}
+
+// RequestChannelExit starts the exit of all the channel funds to the beneficiary, it can be finalized once the timelock passes.
+func (bc *Blockchain) RequestChannelExit(req RequestChannelExitRequest) (*types.Transaction, error) {
+	if err := bc.checkAddressPolicy(req.Beneficiary); err != nil {
+		return nil, err
+	}
+
+	t, err := bindings.NewChannelImplementationTransactor(req.ChannelAddress, bc.transactBackend())
+	if err != nil {
+		return nil, err
+	}
+
+	transactor, cancel, err := bc.getTransactorFromRequest(req.WriteRequest)
+	defer cancel()
+	if err != nil {
+		return nil, fmt.Errorf("could not get transactor: %w", err)
+	}
+
+	return t.RequestExit(transactor, req.Beneficiary, req.ValidUntil, req.Signature)
+}
+
+// FinalizeChannelExitRequest represents all the parameters required to finalize the exit of a consumer channel.
+type FinalizeChannelExitRequest struct {
+	WriteRequest
+	ChannelAddress common.Address
+}
+
+func (r FinalizeChannelExitRequest) toEstimator(ethClient ethClientGetter) (*bindings.ContractEstimator, error) {
+	return bindings.NewContractEstimator(r.ChannelAddress, bindings.ChannelImplementationABI, ethClient.Client())
+}
+
+func (r FinalizeChannelExitRequest) toEstimateOps() *bindings.EstimateOpts {
+	return &bindings.EstimateOpts{
+		From:   r.Identity,
+		Method: "finalizeExit",
+	}
+}
+
+// FinalizeChannelExit transfers the channel funds to the beneficiary of the requested exit.
+func (bc *Blockchain) FinalizeChannelExit(req FinalizeChannelExitRequest) (*types.Transaction, error) {
+	t, err := bindings.NewChannelImplementationTransactor(req.ChannelAddress, bc.transactBackend())
+	if err != nil {
+		return nil, err
+	}
+
+	transactor, cancel, err := bc.getTransactorFromRequest(req.WriteRequest)
+	defer cancel()
+	if err != nil {
+		return nil, fmt.Errorf("could not get transactor: %w", err)
+	}
+
+	return t.FinalizeExit(transactor)
+}
@@ -0,0 +1,84 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// LastChequeNonceFunc returns the last cheque nonce accepted on chain for the channel.
+type LastChequeNonceFunc func(channel common.Address) (*big.Int, error)
+
+// ChequeNonceTracker hands out the nonces of the beneficiary cheques signed for setFundsDestinationByCheque.
+// The chain only accepts a cheque with a nonce bigger than the last one, so the nonce is synced from chain
+// and the nonces handed out locally but not yet mined are never reused.
+type ChequeNonceTracker struct {
+	lastNonce LastChequeNonceFunc
+
+	lock   sync.Mutex
+	nonces map[common.Address]*big.Int
+}
+
+// NewChequeNonceTracker returns a new cheque nonce tracker.
+func NewChequeNonceTracker(lastNonce LastChequeNonceFunc) *ChequeNonceTracker {
+	return &ChequeNonceTracker{
+		lastNonce: lastNonce,
+		nonces:    make(map[common.Address]*big.Int),
+	}
+}
+
+// NextChequeNonce returns the nonce to sign the next cheque of the channel with.
+// It is bigger than both the last nonce on chain and every nonce handed out before.
+func (t *ChequeNonceTracker) NextChequeNonce(channel common.Address) (*big.Int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	onChain, err := t.lastNonce(channel)
+	if err != nil {
+		return nil, fmt.Errorf("could not get last cheque nonce: %w", err)
+	}
+
+	next := new(big.Int).Set(onChain)
+	if local, ok := t.nonces[channel]; ok && local.Cmp(next) > 0 {
+		next.Set(local)
+	}
+	next.Add(next, big.NewInt(1))
+
+	t.nonces[channel] = next
+	return new(big.Int).Set(next), nil
+}
+
+// Observe records a cheque nonce used for the channel elsewhere, e.g. seen in a signed request.
+func (t *ChequeNonceTracker) Observe(channel common.Address, nonce *big.Int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if local, ok := t.nonces[channel]; !ok || nonce.Cmp(local) > 0 {
+		t.nonces[channel] = new(big.Int).Set(nonce)
+	}
+}
+
+// Forget drops the local nonce of the channel, the next nonce is then based on the chain only.
+func (t *ChequeNonceTracker) Forget(channel common.Address) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.nonces, channel)
+}
@@ -0,0 +1,59 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChequeNonceTracker(t *testing.T) {
+	chA, chB := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	onChain := map[common.Address]int64{chA: 5}
+	var chainErr error
+	tracker := NewChequeNonceTracker(func(channel common.Address) (*big.Int, error) {
+		return big.NewInt(onChain[channel]), chainErr
+	})
+
+	next := func(ch common.Address) int64 {
+		n, err := tracker.NextChequeNonce(ch)
+		assert.NoError(t, err)
+		return n.Int64()
+	}
+
+	assert.Equal(t, int64(6), next(chA))
+	assert.Equal(t, int64(7), next(chA), "pending nonces are not reused")
+	assert.Equal(t, int64(1), next(chB))
+
+	onChain[chA] = 10
+	assert.Equal(t, int64(11), next(chA), "nonces used elsewhere are skipped")
+
+	tracker.Observe(chB, big.NewInt(20))
+	tracker.Observe(chB, big.NewInt(3))
+	assert.Equal(t, int64(21), next(chB))
+
+	tracker.Forget(chB)
+	assert.Equal(t, int64(1), next(chB))
+
+	chainErr = errors.New("boom")
+	_, err := tracker.NextChequeNonce(chA)
+	assert.True(t, errors.Is(err, chainErr))
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"math/big"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
@@ -31,8 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/mysteriumnetwork/payments/bindings"
 	"github.com/mysteriumnetwork/payments/crypto"
-	"github.com/pkg/errors"
-	"github.com/rs/zerolog/log"
+	"github.com/mysteriumnetwork/payments/internal/singleflight"
 )
 
 // DefaultBackoff is the default backoff for the client
@@ -55,6 +55,25 @@ type Blockchain struct {
 	ethClient ethClientGetter
 	bcTimeout time.Duration
 	nonceFunc nonceFunc
+	feeGuard  *FeeGuard
+
+	addressPolicy        AddressPolicy
+	subscriptionObserver SubscriptionObserver
+	featureFlags         map[Capability]bool
+
+	// reads deduplicates identical concurrent calls of hot read methods.
+	reads singleflight.Group
+
+	archive    *archive
+	polling    *PollingOpts
+	validation *EventValidationOpts
+	simulation SimulationRecorder
+	versions   *ContractVersionDetector
+
+	// decimals caches the token decimals by token address.
+	decimals sync.Map
+
+	tracing traceDetector
 }
 
 type nonceFunc func(ctx context.Context, account common.Address) (uint64, error)
@@ -80,10 +99,21 @@ func NewBlockchainWithCustomNonceTracker(ethClient ethClientGetter, timeout time
 }
 
 // GetHermesFee fetches the hermes fee from blockchain
+// Identical concurrent calls are collapsed into a single RPC call.
 func (bc *Blockchain) GetHermesFee(hermesAddress common.Address) (uint16, error) {
+	res, err := bc.reads.Do("GetHermesFee"+hermesAddress.Hex(), func() (interface{}, error) {
+		return bc.getHermesFee(hermesAddress)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return res.(uint16), nil
+}
+
+func (bc *Blockchain) getHermesFee(hermesAddress common.Address) (uint16, error) {
 	caller, err := bindings.NewHermesImplementationCaller(hermesAddress, bc.ethClient.Client())
 	if err != nil {
-		return 0, errors.Wrap(err, "could not create hermes implementation caller")
+		return 0, wrap(err, "could not create hermes implementation caller")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
@@ -93,7 +123,7 @@ func (bc *Blockchain) GetHermesFee(hermesAddress common.Address) (uint16, error)
 		Context: ctx,
 	})
 	if err != nil {
-		return 0, errors.Wrap(err, "could not get hermes fee")
+		return 0, wrap(err, "could not get hermes fee")
 	}
 
 	return res.Value, err
@@ -103,7 +133,7 @@ func (bc *Blockchain) GetHermesFee(hermesAddress common.Address) (uint16, error)
 func (bc *Blockchain) CalculateHermesFee(hermesAddress common.Address, value *big.Int) (*big.Int, error) {
 	caller, err := bindings.NewHermesImplementationCaller(hermesAddress, bc.ethClient.Client())
 	if err != nil {
-		return nil, errors.Wrap(err, "could not create hermes implementation caller")
+		return nil, wrap(err, "could not create hermes implementation caller")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
@@ -118,7 +148,7 @@ func (bc *Blockchain) CalculateHermesFee(hermesAddress common.Address, value *bi
 func (bc *Blockchain) IsRegisteredAsProvider(hermesAddress, registryAddress, addressToCheck common.Address) (bool, error) {
 	registered, err := bc.IsRegistered(registryAddress, addressToCheck)
 	if err != nil {
-		return false, errors.Wrap(err, "could not check registration status")
+		return false, wrap(err, "could not check registration status")
 	}
 
 	if !registered {
@@ -127,45 +157,41 @@ func (bc *Blockchain) IsRegisteredAsProvider(hermesAddress, registryAddress, add
 
 	res, err := bc.getProviderChannelStake(hermesAddress, addressToCheck)
 	if err != nil {
-		return false, errors.Wrap(err, "could not get provider channel stake amount")
+		return false, wrap(err, "could not get provider channel stake amount")
 	}
 
 	return res.Cmp(big.NewInt(0)) == 1, nil
 }
 
 // SubscribeToMystTokenTransfers subscribes to myst token transfers
-func (bc *Blockchain) SubscribeToMystTokenTransfers(mystSCAddress common.Address) (chan *bindings.MystTokenTransfer, func(), error) {
+func (bc *Blockchain) SubscribeToMystTokenTransfers(mystSCAddress common.Address) (chan *bindings.MystTokenTransfer, *Subscription, error) {
 	sink := make(chan *bindings.MystTokenTransfer)
-	mtc, err := bindings.NewMystTokenFilterer(mystSCAddress, bc.ethClient.Client())
+	mtc, err := bindings.NewMystTokenFilterer(mystSCAddress, bc.subscriptionFilterer())
 	if err != nil {
 		return sink, nil, err
 	}
+	watch, closeSink := bc.validatedSink("Transfer", mystSCAddress, sink)
 	sub := event.Resubscribe(DefaultBackoff, func(ctx context.Context) (event.Subscription, error) {
 		return mtc.WatchTransfer(&bind.WatchOpts{
 			Context: ctx,
-		}, sink, []common.Address{}, []common.Address{})
+		}, watch.(chan *bindings.MystTokenTransfer), []common.Address{}, []common.Address{})
 	})
-	go func() {
-		subErr := <-sub.Err()
-		if subErr != nil {
-			log.Error().Err(err).Msg("subscription error")
-		}
-		close(sink)
-	}()
 
-	return sink, sub.Unsubscribe, nil
+	return sink, bc.newSubscription("Transfer", mystSCAddress, sub, closeSink), nil
 }
 
 // SubscribeToConsumerBalanceEvent subscribes to balance change events in blockchain
-func (bc *Blockchain) SubscribeToConsumerBalanceEvent(channel, mystSCAddress common.Address, timeout time.Duration) (chan *bindings.MystTokenTransfer, func(), error) {
+func (bc *Blockchain) SubscribeToConsumerBalanceEvent(channel, mystSCAddress common.Address, timeout time.Duration) (chan *bindings.MystTokenTransfer, *Subscription, error) {
 	sink := make(chan *bindings.MystTokenTransfer)
-	mtc, err := bindings.NewMystTokenFilterer(mystSCAddress, bc.ethClient.Client())
+	mtc, err := bindings.NewMystTokenFilterer(mystSCAddress, bc.subscriptionFilterer())
 	if err != nil {
 		return sink, nil, err
 	}
 
-	sub, err := mtc.WatchTransfer(&bind.WatchOpts{}, sink, []common.Address{}, []common.Address{channel})
+	watch, closeSink := bc.validatedSink("Transfer", mystSCAddress, sink)
+	sub, err := mtc.WatchTransfer(&bind.WatchOpts{}, watch.(chan *bindings.MystTokenTransfer), []common.Address{}, []common.Address{channel})
 	if err != nil {
+		closeSink()
 		return sink, nil, err
 	}
 
@@ -176,25 +202,18 @@ func (bc *Blockchain) SubscribeToConsumerBalanceEvent(channel, mystSCAddress com
 		}
 	}()
 
-	go func() {
-		subErr := <-sub.Err()
-		if subErr != nil {
-			log.Error().Err(err).Msg("subscription error")
-		}
-		close(sink)
-	}()
-	return sink, sub.Unsubscribe, nil
+	return sink, bc.newSubscription("Transfer", mystSCAddress, sub, closeSink), nil
 }
 
 // GetProviderChannel returns the provider channel
 func (bc *Blockchain) GetProviderChannel(hermesAddress common.Address, addressToCheck common.Address, pending bool) (ProviderChannel, error) {
 	addressBytes, err := bc.getProviderChannelAddressBytes(hermesAddress, addressToCheck)
 	if err != nil {
-		return ProviderChannel{}, errors.Wrap(err, "could not calculate provider channel address")
+		return ProviderChannel{}, wrap(err, "could not calculate provider channel address")
 	}
 	caller, err := bindings.NewHermesImplementationCaller(hermesAddress, bc.ethClient.Client())
 	if err != nil {
-		return ProviderChannel{}, errors.Wrap(err, "could not create hermes caller")
+		return ProviderChannel{}, wrap(err, "could not create hermes caller")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
@@ -204,12 +223,12 @@ func (bc *Blockchain) GetProviderChannel(hermesAddress common.Address, addressTo
 		Pending: pending,
 		Context: ctx,
 	}, addressBytes)
-	return ch, errors.Wrap(err, "could not get provider channel from bc")
+	return ch, wrap(err, "could not get provider channel from bc")
 }
 
 func (bc *Blockchain) getProviderChannelStake(hermesAddress common.Address, addressToCheck common.Address) (*big.Int, error) {
 	ch, err := bc.GetProviderChannel(hermesAddress, addressToCheck, false)
-	return ch.Stake, errors.Wrap(err, "could not get provider channel from bc")
+	return ch.Stake, wrap(err, "could not get provider channel from bc")
 }
 
 func (bc *Blockchain) TransactionReceipt(hash common.Hash) (*types.Receipt, error) {
@@ -223,7 +242,7 @@ func (bc *Blockchain) getProviderChannelAddressBytes(hermesAddress, addressToChe
 
 	addr, err := crypto.GenerateProviderChannelID(addressToCheck.Hex(), hermesAddress.Hex())
 	if err != nil {
-		return addressBytes, errors.Wrap(err, "could not generate channel address")
+		return addressBytes, wrap(err, "could not generate channel address")
 	}
 
 	copy(addressBytes[:], crypto.Pad(common.Hex2Bytes(strings.TrimPrefix(addr, "0x")), 32))
@@ -232,10 +251,10 @@ func (bc *Blockchain) getProviderChannelAddressBytes(hermesAddress, addressToChe
 }
 
 // SubscribeToPromiseSettledEvent subscribes to promise settled events
-func (bc *Blockchain) SubscribeToPromiseSettledEvent(providerID, hermesID common.Address) (sink chan *bindings.HermesImplementationPromiseSettled, cancel func(), err error) {
+func (bc *Blockchain) SubscribeToPromiseSettledEvent(providerID, hermesID common.Address) (sink chan *bindings.HermesImplementationPromiseSettled, sub *Subscription, err error) {
 	addr, err := bc.getProviderChannelAddressBytes(hermesID, providerID)
 	if err != nil {
-		return sink, cancel, errors.Wrap(err, "could not get provider channel address")
+		return sink, nil, wrap(err, "could not get provider channel address")
 	}
 	return bc.SubscribeToPromiseSettledEventByChannelID(hermesID, [][32]byte{addr})
 }
@@ -244,7 +263,7 @@ func (bc *Blockchain) SubscribeToPromiseSettledEvent(providerID, hermesID common
 func (bc *Blockchain) IsRegistered(registryAddress, addressToCheck common.Address) (bool, error) {
 	caller, err := bindings.NewRegistryCaller(registryAddress, bc.ethClient.Client())
 	if err != nil {
-		return false, errors.Wrap(err, "could not create registry caller")
+		return false, wrap(err, "could not create registry caller")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
@@ -253,7 +272,7 @@ func (bc *Blockchain) IsRegistered(registryAddress, addressToCheck common.Addres
 	res, err := caller.IsRegistered(&bind.CallOpts{
 		Context: ctx,
 	}, addressToCheck)
-	return res, errors.Wrap(err, "could not check registration status")
+	return res, wrap(err, "could not check registration status")
 }
 
 // GetMystBalance returns myst balance
@@ -297,10 +316,33 @@ func (r RegistrationRequest) toEstimateOps() *bindings.EstimateOpts {
 // WriteRequest contains the required params for a write request
 type WriteRequest struct {
 	Identity common.Address
-	Signer   bind.SignerFn
+	Signer   bind.SignerFn `json:"-"`
 	GasLimit uint64
 	GasPrice *big.Int
 	Nonce    *big.Int
+
+	// FeeGuardValue is the native token value of the operation, required by the fee guard percentage cap.
+	FeeGuardValue *big.Int
+	// OverrideFeeGuard explicitly allows the transaction to exceed the fee guard cap.
+	OverrideFeeGuard bool
+
+	// TransactOptsMutator is applied to the transaction options right before submission.
+	TransactOptsMutator TransactOptsMutator `json:"-"`
+}
+
+// TransactOptsMutator tweaks the transaction options the library filled in, e.g. to set a value or a custom signer,
+// for the knobs the request structs do not model. The fee guard wraps the signer it leaves in place.
+type TransactOptsMutator func(opts *bind.TransactOpts)
+
+// transactOpts sets the request signer on the options and applies the request mutator, the fee guard wraps the final signer.
+func (bc *Blockchain) transactOpts(req WriteRequest, opts *bind.TransactOpts) *bind.TransactOpts {
+	opts.Signer = req.Signer
+	if req.TransactOptsMutator != nil {
+		req.TransactOptsMutator(opts)
+	}
+	req.Signer = opts.Signer
+	opts.Signer = bc.guardSigner(req)
+	return opts
 }
 
 // getGasLimit returns the gas limit
@@ -310,7 +352,11 @@ func (wr WriteRequest) getGasLimit() uint64 {
 
 // RegisterIdentity registers the given identity on blockchain
 func (bc *Blockchain) RegisterIdentity(rr RegistrationRequest) (*types.Transaction, error) {
-	transactor, err := bindings.NewRegistryTransactor(rr.RegistryAddress, bc.ethClient.Client())
+	if err := bc.checkAddressPolicy(rr.Beneficiary); err != nil {
+		return nil, err
+	}
+
+	transactor, err := bindings.NewRegistryTransactor(rr.RegistryAddress, bc.transactBackend())
 	if err != nil {
 		return nil, err
 	}
@@ -322,18 +368,17 @@ func (bc *Blockchain) RegisterIdentity(rr RegistrationRequest) (*types.Transacti
 	if nonce == nil {
 		nonceUint, err := bc.getNonce(rr.Identity)
 		if err != nil {
-			return nil, errors.Wrap(err, "could not get nonce")
+			return nil, wrap(err, "could not get nonce")
 		}
 		nonce = big.NewInt(0).SetUint64(nonceUint)
 	}
-	tx, err := transactor.RegisterIdentity(&bind.TransactOpts{
+	tx, err := transactor.RegisterIdentity(bc.transactOpts(rr.WriteRequest, &bind.TransactOpts{
 		From:     rr.Identity,
-		Signer:   rr.Signer,
 		Context:  ctx,
 		GasLimit: rr.GasLimit,
 		GasPrice: rr.GasPrice,
 		Nonce:    nonce,
-	},
+	}),
 		rr.HermesID,
 		rr.Stake,
 		rr.TransactorFee,
@@ -365,23 +410,18 @@ func (r TransferRequest) toEstimateOps() *bindings.EstimateOpts {
 
 // TransferMyst transfers myst
 func (bc *Blockchain) TransferMyst(req TransferRequest) (tx *types.Transaction, err error) {
-	transactor, err := bindings.NewMystTokenTransactor(req.MystAddress, bc.ethClient.Client())
+	transactor, err := bindings.NewMystTokenTransactor(req.MystAddress, bc.transactBackend())
 	if err != nil {
 		return tx, err
 	}
 
-	nonce, err := bc.getNonce(req.Identity)
+	transactOpts, cancel, err := bc.getTransactorFromRequest(req.WriteRequest)
+	defer cancel()
 	if err != nil {
-		return nil, errors.Wrap(err, "could not get nonce")
+		return nil, err
 	}
 
-	return transactor.Transfer(&bind.TransactOpts{
-		From:     req.Identity,
-		Signer:   req.Signer,
-		GasPrice: req.GasPrice,
-		GasLimit: req.GasLimit,
-		Nonce:    big.NewInt(0).SetUint64(nonce),
-	}, req.Recipient, req.Amount)
+	return transactor.Transfer(transactOpts, req.Recipient, req.Amount)
 }
 
 // IsHermesRegistered checks if given hermes is registered and returns true or false.
@@ -421,7 +461,7 @@ func (r ProviderStakeIncreaseRequest) toEstimateOps() *bindings.EstimateOpts {
 
 // IncreaseProviderStake increases the provider stake.
 func (bc *Blockchain) IncreaseProviderStake(req ProviderStakeIncreaseRequest) (*types.Transaction, error) {
-	t, err := bindings.NewHermesImplementationTransactor(req.HermesID, bc.ethClient.Client())
+	t, err := bindings.NewHermesImplementationTransactor(req.HermesID, bc.transactBackend())
 	if err != nil {
 		return nil, err
 	}
@@ -463,7 +503,11 @@ func (r SettleIntoStakeRequest) toEstimateOps() *bindings.EstimateOpts {
 
 // SettleIntoStake settles the hermes promise into stake increase.
 func (bc *Blockchain) SettleIntoStake(req SettleIntoStakeRequest) (*types.Transaction, error) {
-	t, err := bindings.NewHermesImplementationTransactor(req.HermesID, bc.ethClient.Client())
+	if err := bc.checkHermesPromise(req.HermesID, req.Promise); err != nil {
+		return nil, err
+	}
+
+	t, err := bindings.NewHermesImplementationTransactor(req.HermesID, bc.transactBackend())
 	if err != nil {
 		return nil, err
 	}
@@ -521,7 +565,7 @@ func (bc *Blockchain) GetBeneficiary(registryAddress, identity common.Address) (
 
 // DecreaseProviderStake decreases provider stake.
 func (bc *Blockchain) DecreaseProviderStake(req DecreaseProviderStakeRequest) (*types.Transaction, error) {
-	t, err := bindings.NewHermesImplementationTransactor(req.Request.HermesID, bc.ethClient.Client())
+	t, err := bindings.NewHermesImplementationTransactor(req.Request.HermesID, bc.transactBackend())
 	if err != nil {
 		return nil, err
 	}
@@ -542,19 +586,18 @@ func (bc *Blockchain) getTransactorFromRequest(req WriteRequest) (*bind.Transact
 	if req.Nonce == nil {
 		nonce, err := bc.getNonce(req.Identity)
 		if err != nil {
-			return nil, cancel, errors.Wrap(err, "could not get nonce")
+			return nil, cancel, wrap(err, "could not get nonce")
 		}
 		req.Nonce = big.NewInt(0).SetUint64(nonce)
 	}
 
-	return &bind.TransactOpts{
+	return bc.transactOpts(req, &bind.TransactOpts{
 		From:     req.Identity,
-		Signer:   req.Signer,
 		Context:  ctx,
 		GasLimit: req.GasLimit,
 		GasPrice: req.GasPrice,
 		Nonce:    req.Nonce,
-	}, cancel, nil
+	}), cancel, nil
 }
 
 // GetHermesOperator returns operator address of given hermes
@@ -595,7 +638,11 @@ func (r SettleAndRebalanceRequest) toEstimateOps() *bindings.EstimateOpts {
 
 // SettleAndRebalance is settling given hermes issued promise
 func (bc *Blockchain) SettleAndRebalance(req SettleAndRebalanceRequest) (*types.Transaction, error) {
-	transactor, err := bindings.NewHermesImplementationTransactor(req.HermesID, bc.ethClient.Client())
+	if err := bc.checkHermesPromise(req.HermesID, req.Promise); err != nil {
+		return nil, err
+	}
+
+	transactor, err := bindings.NewHermesImplementationTransactor(req.HermesID, bc.transactBackend())
 	if err != nil {
 		return nil, err
 	}
@@ -604,17 +651,16 @@ func (bc *Blockchain) SettleAndRebalance(req SettleAndRebalanceRequest) (*types.
 
 	nonce, err := bc.getNonce(req.Identity)
 	if err != nil {
-		return nil, errors.Wrap(err, "could not get nonce")
+		return nil, wrap(err, "could not get nonce")
 	}
 
-	return transactor.SettlePromise(&bind.TransactOpts{
+	return transactor.SettlePromise(bc.transactOpts(req.WriteRequest, &bind.TransactOpts{
 		From:     req.Identity,
-		Signer:   req.Signer,
 		Context:  ctx,
 		GasLimit: req.GasLimit,
 		GasPrice: req.GasPrice,
 		Nonce:    big.NewInt(0).SetUint64(nonce),
-	},
+	}),
 		req.ProviderID,
 		req.Promise.Amount,
 		req.Promise.Fee,
@@ -694,48 +740,36 @@ func (bc *Blockchain) GetConsumerChannelOperator(channelAddress common.Address)
 }
 
 // SubscribeToIdentityRegistrationEvents subscribes to identity registration events
-func (bc *Blockchain) SubscribeToIdentityRegistrationEvents(registryAddress common.Address) (sink chan *bindings.RegistryRegisteredIdentity, cancel func(), err error) {
-	filterer, err := bindings.NewRegistryFilterer(registryAddress, bc.ethClient.Client())
+func (bc *Blockchain) SubscribeToIdentityRegistrationEvents(registryAddress common.Address) (sink chan *bindings.RegistryRegisteredIdentity, sub *Subscription, err error) {
+	filterer, err := bindings.NewRegistryFilterer(registryAddress, bc.subscriptionFilterer())
 	if err != nil {
-		return sink, cancel, errors.Wrap(err, "could not create registry filterer")
+		return sink, nil, wrap(err, "could not create registry filterer")
 	}
 	sink = make(chan *bindings.RegistryRegisteredIdentity)
-	sub := event.Resubscribe(DefaultBackoff, func(ctx context.Context) (event.Subscription, error) {
+	watch, closeSink := bc.validatedSink("RegisteredIdentity", registryAddress, sink)
+	resub := event.Resubscribe(DefaultBackoff, func(ctx context.Context) (event.Subscription, error) {
 		return filterer.WatchRegisteredIdentity(&bind.WatchOpts{
 			Context: ctx,
-		}, sink, nil)
+		}, watch.(chan *bindings.RegistryRegisteredIdentity), nil)
 	})
-	go func() {
-		subErr := <-sub.Err()
-		if subErr != nil {
-			log.Error().Err(err).Msg("subscription error")
-		}
-		close(sink)
-	}()
-	return sink, sub.Unsubscribe, nil
+	return sink, bc.newSubscription("RegisteredIdentity", registryAddress, resub, closeSink), nil
 }
 
 // SubscribeToConsumerChannelBalanceUpdate subscribes to consumer channel balance update events
-func (bc *Blockchain) SubscribeToConsumerChannelBalanceUpdate(mystSCAddress common.Address, channelAddresses []common.Address) (sink chan *bindings.MystTokenTransfer, cancel func(), err error) {
-	filterer, err := bindings.NewMystTokenFilterer(mystSCAddress, bc.ethClient.Client())
+func (bc *Blockchain) SubscribeToConsumerChannelBalanceUpdate(mystSCAddress common.Address, channelAddresses []common.Address) (sink chan *bindings.MystTokenTransfer, sub *Subscription, err error) {
+	filterer, err := bindings.NewMystTokenFilterer(mystSCAddress, bc.subscriptionFilterer())
 	if err != nil {
-		return sink, cancel, errors.Wrap(err, "could not create myst token filterer")
+		return sink, nil, wrap(err, "could not create myst token filterer")
 	}
 
 	sink = make(chan *bindings.MystTokenTransfer)
-	sub := event.Resubscribe(DefaultBackoff, func(ctx context.Context) (event.Subscription, error) {
+	watch, closeSink := bc.validatedSink("Transfer", mystSCAddress, sink)
+	resub := event.Resubscribe(DefaultBackoff, func(ctx context.Context) (event.Subscription, error) {
 		return filterer.WatchTransfer(&bind.WatchOpts{
 			Context: ctx,
-		}, sink, nil, channelAddresses)
+		}, watch.(chan *bindings.MystTokenTransfer), nil, channelAddresses)
 	})
-	go func() {
-		subErr := <-sub.Err()
-		if subErr != nil {
-			log.Error().Err(err).Msg("subscription error")
-		}
-		close(sink)
-	}()
-	return sink, sub.Unsubscribe, nil
+	return sink, bc.newSubscription("Transfer", mystSCAddress, resub, closeSink), nil
 }
 
 // SettleRequest represents all the parameters required for settle
@@ -762,7 +796,11 @@ func (r SettleRequest) toEstimateOps() *bindings.EstimateOpts {
 
 // SettlePromise is settling the given consumer issued promise
 func (bc *Blockchain) SettlePromise(req SettleRequest) (*types.Transaction, error) {
-	transactor, err := bindings.NewChannelImplementationTransactor(req.ChannelID, bc.ethClient.Client())
+	if err := bc.checkChannelPromise(req.ChannelID, req.Promise); err != nil {
+		return nil, err
+	}
+
+	transactor, err := bindings.NewChannelImplementationTransactor(req.ChannelID, bc.transactBackend())
 	if err != nil {
 		return nil, err
 	}
@@ -776,17 +814,16 @@ func (bc *Blockchain) SettlePromise(req SettleRequest) (*types.Transaction, erro
 
 	nonce, err := bc.getNonce(req.Identity)
 	if err != nil {
-		return nil, errors.Wrap(err, "could not get nonce")
+		return nil, wrap(err, "could not get nonce")
 	}
 
-	return transactor.SettlePromise(&bind.TransactOpts{
+	return transactor.SettlePromise(bc.transactOpts(req.WriteRequest, &bind.TransactOpts{
 		From:     req.Identity,
-		Signer:   req.Signer,
 		Context:  ctx,
 		GasLimit: req.GasLimit,
 		GasPrice: req.GasPrice,
 		Nonce:    big.NewInt(0).SetUint64(nonce),
-	},
+	}),
 		amount, fee, lock, req.Promise.Signature,
 	)
 }
@@ -817,28 +854,21 @@ func (bc *Blockchain) GetHermesURL(registryID, hermesID common.Address) (string,
 }
 
 // SubscribeToPromiseSettledEventByChannelID subscribes to promise settled events
-func (bc *Blockchain) SubscribeToPromiseSettledEventByChannelID(hermesID common.Address, providerAddresses [][32]byte) (sink chan *bindings.HermesImplementationPromiseSettled, cancel func(), err error) {
-	caller, err := bindings.NewHermesImplementationFilterer(hermesID, bc.ethClient.Client())
+func (bc *Blockchain) SubscribeToPromiseSettledEventByChannelID(hermesID common.Address, providerAddresses [][32]byte) (sink chan *bindings.HermesImplementationPromiseSettled, sub *Subscription, err error) {
+	caller, err := bindings.NewHermesImplementationFilterer(hermesID, bc.subscriptionFilterer())
 	if err != nil {
-		return sink, cancel, errors.Wrap(err, "could not create hermes caller")
+		return sink, nil, wrap(err, "could not create hermes caller")
 	}
 	sink = make(chan *bindings.HermesImplementationPromiseSettled)
+	watch, closeSink := bc.validatedSink("PromiseSettled", hermesID, sink)
 
-	sub := event.Resubscribe(DefaultBackoff, func(ctx context.Context) (event.Subscription, error) {
+	resub := event.Resubscribe(DefaultBackoff, func(ctx context.Context) (event.Subscription, error) {
 		return caller.WatchPromiseSettled(&bind.WatchOpts{
 			Context: ctx,
-		}, sink, providerAddresses, []common.Address{})
+		}, watch.(chan *bindings.HermesImplementationPromiseSettled), providerAddresses, []common.Address{})
 	})
-	go func() {
-		subErr := <-sub.Err()
-		if subErr != nil {
-			log.Error().Err(err).Msg("subscription error")
-		}
-		cancel()
-		close(sink)
-	}()
 
-	return sink, sub.Unsubscribe, nil
+	return sink, bc.newSubscription("PromiseSettled", hermesID, resub, closeSink), nil
 }
 
 // GetEthBalance gets the current ethereum balance for the address.
@@ -870,13 +900,21 @@ func (bc *Blockchain) TransferEth(etr EthTransferRequest) (*types.Transaction, e
 		return nil, fmt.Errorf("could not get nonce: %w", err)
 	}
 
-	tx := types.NewTransaction(nonceUint, etr.To, etr.Amount, etr.GasLimit, etr.GasPrice, nil)
-	signedTx, err := etr.Signer(types.NewEIP155Signer(id), etr.Identity, tx)
+	opts := bc.transactOpts(etr.WriteRequest, &bind.TransactOpts{
+		From:     etr.Identity,
+		Context:  ctx,
+		GasLimit: etr.GasLimit,
+		GasPrice: etr.GasPrice,
+		Nonce:    new(big.Int).SetUint64(nonceUint),
+		Value:    etr.Amount,
+	})
+	tx := types.NewTransaction(opts.Nonce.Uint64(), etr.To, opts.Value, opts.GasLimit, opts.GasPrice, nil)
+	signedTx, err := opts.Signer(types.NewEIP155Signer(id), opts.From, tx)
 	if err != nil {
 		return nil, fmt.Errorf("could not sign tx: %w", err)
 	}
 
-	err = bc.ethClient.Client().SendTransaction(ctx, signedTx)
+	err = bc.transactBackend().SendTransaction(ctx, signedTx)
 	if err != nil {
 		return nil, fmt.Errorf("could not send transaction: %w", err)
 	}
@@ -888,7 +926,7 @@ func (bc *Blockchain) TransferEth(etr EthTransferRequest) (*types.Transaction, e
 func (bc *Blockchain) FilterLogs(q ethereum.FilterQuery) ([]types.Log, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
 	defer cancel()
-	return bc.ethClient.Client().FilterLogs(ctx, q)
+	return bc.logClient().FilterLogs(ctx, q)
 }
 
 // HeaderByNumber returns a block header from the current canonical chain. If number is
@@ -896,7 +934,11 @@ func (bc *Blockchain) FilterLogs(q ethereum.FilterQuery) ([]types.Log, error) {
 func (bc *Blockchain) HeaderByNumber(number *big.Int) (*types.Header, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
 	defer cancel()
-	return bc.ethClient.Client().HeaderByNumber(ctx, number)
+	header, err := bc.blockClient(number).Client().HeaderByNumber(ctx, number)
+	if err == nil && number == nil {
+		bc.observeHead(header)
+	}
+	return header, err
 }
 
 func (bc *Blockchain) SuggestGasPrice() (*big.Int, error) {
@@ -906,10 +948,17 @@ func (bc *Blockchain) SuggestGasPrice() (*big.Int, error) {
 }
 
 // NetworkID returns the network id
+// Identical concurrent calls are collapsed into a single RPC call.
 func (bc *Blockchain) NetworkID() (*big.Int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
-	defer cancel()
-	return bc.ethClient.Client().NetworkID(ctx)
+	res, err := bc.reads.Do("NetworkID", func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+		defer cancel()
+		return bc.ethClient.Client().NetworkID(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).Set(res.(*big.Int)), nil
 }
 
 // ConsumerChannel represents the consumer channel
@@ -967,7 +1016,7 @@ func (r SettleWithBeneficiaryRequest) toEstimateOps() *bindings.EstimateOpts {
 func (bc *Blockchain) GetHermessAvailableBalance(hermesAddress common.Address) (*big.Int, error) {
 	caller, err := bindings.NewHermesImplementationCaller(hermesAddress, bc.ethClient.Client())
 	if err != nil {
-		return nil, errors.Wrap(err, "could not create hermes implementation caller")
+		return nil, wrap(err, "could not create hermes implementation caller")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
@@ -977,7 +1026,7 @@ func (bc *Blockchain) GetHermessAvailableBalance(hermesAddress common.Address) (
 		Context: ctx,
 	})
 	if err != nil {
-		return nil, errors.Wrap(err, "could not get hermes available balance")
+		return nil, wrap(err, "could not get hermes available balance")
 	}
 
 	return res, nil
@@ -985,7 +1034,14 @@ func (bc *Blockchain) GetHermessAvailableBalance(hermesAddress common.Address) (
 
 // SettleWithBeneficiary sets new beneficiary for the provided identity and settles lastest promise into new beneficiary address.
 func (bc *Blockchain) SettleWithBeneficiary(req SettleWithBeneficiaryRequest) (*types.Transaction, error) {
-	transactor, err := bindings.NewHermesImplementationTransactor(req.HermesID, bc.ethClient.Client())
+	if err := bc.checkAddressPolicy(req.Beneficiary); err != nil {
+		return nil, err
+	}
+	if err := bc.checkHermesPromise(req.HermesID, req.Promise); err != nil {
+		return nil, err
+	}
+
+	transactor, err := bindings.NewHermesImplementationTransactor(req.HermesID, bc.transactBackend())
 	if err != nil {
 		return nil, err
 	}
@@ -995,17 +1051,16 @@ func (bc *Blockchain) SettleWithBeneficiary(req SettleWithBeneficiaryRequest) (*
 
 	nonce, err := bc.getNonce(req.Identity)
 	if err != nil {
-		return nil, errors.Wrap(err, "could not get nonce")
+		return nil, wrap(err, "could not get nonce")
 	}
 
-	return transactor.SettleWithBeneficiary(&bind.TransactOpts{
+	return transactor.SettleWithBeneficiary(bc.transactOpts(req.WriteRequest, &bind.TransactOpts{
 		From:     req.Identity,
-		Signer:   req.Signer,
 		Context:  ctx,
 		GasLimit: req.GasLimit,
 		GasPrice: req.GasPrice,
 		Nonce:    big.NewInt(0).SetUint64(nonce),
-	},
+	}),
 		req.ProviderID,
 		req.Promise.Amount,
 		req.Promise.Fee,
@@ -1017,7 +1072,20 @@ func (bc *Blockchain) SettleWithBeneficiary(req SettleWithBeneficiaryRequest) (*
 }
 
 // GetStakeThresholds returns the stake tresholds for the given hermes.
+// Identical concurrent calls are collapsed into a single RPC call.
 func (bc *Blockchain) GetStakeThresholds(hermesID common.Address) (min, max *big.Int, err error) {
+	res, err := bc.reads.Do("GetStakeThresholds"+hermesID.Hex(), func() (interface{}, error) {
+		min, max, err := bc.getStakeThresholds(hermesID)
+		return [2]*big.Int{min, max}, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	thresholds := res.([2]*big.Int)
+	return new(big.Int).Set(thresholds[0]), new(big.Int).Set(thresholds[1]), nil
+}
+
+func (bc *Blockchain) getStakeThresholds(hermesID common.Address) (min, max *big.Int, err error) {
 	caller, err := bindings.NewHermesImplementationCaller(hermesID, bc.ethClient.Client())
 	if err != nil {
 		return nil, nil, err
@@ -1032,9 +1100,23 @@ func (bc *Blockchain) GetStakeThresholds(hermesID common.Address) (min, max *big
 }
 
 // SendTransaction sends a transaction to the blockchain.
+// It is checked by the fee guard without the operation value, see SendTransactionWithFeeGuard.
 func (bc *Blockchain) SendTransaction(tx *types.Transaction) error {
+	return bc.SendTransactionWithFeeGuard(tx, nil, false)
+}
+
+// SendTransactionWithFeeGuard sends a transaction to the blockchain.
+// Like with the FeeGuardValue and OverrideFeeGuard of write requests, the fee guard checks it
+// against the native token value of the operation, unless override is set.
+func (bc *Blockchain) SendTransactionWithFeeGuard(tx *types.Transaction, value *big.Int, override bool) error {
+	if bc.feeGuard != nil && !override {
+		if err := bc.feeGuard.Check(tx, value); err != nil {
+			return err
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
 	defer cancel()
 
-	return bc.ethClient.Client().SendTransaction(ctx, tx)
+	return bc.transactBackend().SendTransaction(ctx, tx)
 }
@@ -0,0 +1,153 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrCrossCheckMismatch is returned when two RPC providers disagree on a critical read.
+var ErrCrossCheckMismatch = errors.New("rpc providers returned different results")
+
+// CriticalReader serves the reads settlement decisions are based on.
+// The blockchain client and its wrappers can be used.
+type CriticalReader interface {
+	GetBeneficiary(registryAddress, identity common.Address) (common.Address, error)
+	GetProviderChannel(hermesAddress common.Address, addressToCheck common.Address, pending bool) (ProviderChannel, error)
+	GetConsumerChannelsHermes(channelAddress common.Address) (ConsumersHermes, error)
+}
+
+// Mismatch describes a disagreement of the providers.
+type Mismatch struct {
+	Method    string
+	Args      []interface{}
+	Primary   interface{}
+	Secondary interface{}
+}
+
+// MismatchFunc is called for every mismatch, e.g. to raise an alert.
+type MismatchFunc func(m Mismatch)
+
+// CrossChecker issues critical reads to two independent RPC providers and refuses to return results they disagree on.
+// Since the providers might lag behind each other by a block or two, mismatching reads are repeated once after
+// the given delay before being reported.
+type CrossChecker struct {
+	primary    CriticalReader
+	secondary  CriticalReader
+	retryDelay time.Duration
+	onMismatch MismatchFunc
+}
+
+// NewCrossChecker returns a new cross checker.
+func NewCrossChecker(primary, secondary CriticalReader, retryDelay time.Duration) *CrossChecker {
+	return &CrossChecker{
+		primary:    primary,
+		secondary:  secondary,
+		retryDelay: retryDelay,
+		onMismatch: func(Mismatch) {},
+	}
+}
+
+// AttachMismatchFunc attaches a func called for every mismatch.
+// Not thread safe, call before reading.
+func (cc *CrossChecker) AttachMismatchFunc(f MismatchFunc) {
+	cc.onMismatch = f
+}
+
+// GetBeneficiary returns the beneficiary of the identity if both providers agree on it.
+func (cc *CrossChecker) GetBeneficiary(registryAddress, identity common.Address) (common.Address, error) {
+	res, err := cc.check("GetBeneficiary", []interface{}{registryAddress, identity}, func(r CriticalReader) (interface{}, error) {
+		return r.GetBeneficiary(registryAddress, identity)
+	}, func(a, b interface{}) bool {
+		return a.(common.Address) == b.(common.Address)
+	})
+	if err != nil {
+		return common.Address{}, err
+	}
+	return res.(common.Address), nil
+}
+
+// GetProviderChannel returns the provider channel if both providers agree on its settled amount and stake.
+func (cc *CrossChecker) GetProviderChannel(hermesAddress common.Address, addressToCheck common.Address, pending bool) (ProviderChannel, error) {
+	res, err := cc.check("GetProviderChannel", []interface{}{hermesAddress, addressToCheck, pending}, func(r CriticalReader) (interface{}, error) {
+		return r.GetProviderChannel(hermesAddress, addressToCheck, pending)
+	}, func(a, b interface{}) bool {
+		x, y := a.(ProviderChannel), b.(ProviderChannel)
+		return equalBig(x.Settled, y.Settled) && equalBig(x.Stake, y.Stake)
+	})
+	if err != nil {
+		return ProviderChannel{}, err
+	}
+	return res.(ProviderChannel), nil
+}
+
+// GetConsumerChannelsHermes returns the consumer channel hermes if both providers agree on the settled amount.
+func (cc *CrossChecker) GetConsumerChannelsHermes(channelAddress common.Address) (ConsumersHermes, error) {
+	res, err := cc.check("GetConsumerChannelsHermes", []interface{}{channelAddress}, func(r CriticalReader) (interface{}, error) {
+		return r.GetConsumerChannelsHermes(channelAddress)
+	}, func(a, b interface{}) bool {
+		x, y := a.(ConsumersHermes), b.(ConsumersHermes)
+		return x.Operator == y.Operator && x.ContractAddress == y.ContractAddress && equalBig(x.Settled, y.Settled)
+	})
+	if err != nil {
+		return ConsumersHermes{}, err
+	}
+	return res.(ConsumersHermes), nil
+}
+
+func (cc *CrossChecker) check(method string, args []interface{}, read func(CriticalReader) (interface{}, error), equal func(a, b interface{}) bool) (interface{}, error) {
+	var primary, secondary interface{}
+	for attempt := 0; attempt < 2; attempt++ {
+		if attempt > 0 {
+			time.Sleep(cc.retryDelay)
+		}
+
+		var err error
+		primary, err = read(cc.primary)
+		if err != nil {
+			return nil, fmt.Errorf("primary provider: %w", err)
+		}
+		secondary, err = read(cc.secondary)
+		if err != nil {
+			return nil, fmt.Errorf("secondary provider: %w", err)
+		}
+
+		if equal(primary, secondary) {
+			return primary, nil
+		}
+	}
+
+	cc.onMismatch(Mismatch{
+		Method:    method,
+		Args:      args,
+		Primary:   primary,
+		Secondary: secondary,
+	})
+	return nil, fmt.Errorf("%w: %v(%v): %v != %v", ErrCrossCheckMismatch, method, args, primary, secondary)
+}
+
+func equalBig(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(b) == 0
+}
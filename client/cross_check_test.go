@@ -0,0 +1,73 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockCriticalReader struct {
+	beneficiaries []common.Address
+	settled       *big.Int
+}
+
+func (m *mockCriticalReader) GetBeneficiary(registryAddress, identity common.Address) (common.Address, error) {
+	b := m.beneficiaries[0]
+	if len(m.beneficiaries) > 1 {
+		m.beneficiaries = m.beneficiaries[1:]
+	}
+	return b, nil
+}
+
+func (m *mockCriticalReader) GetProviderChannel(hermesAddress common.Address, addressToCheck common.Address, pending bool) (ProviderChannel, error) {
+	return ProviderChannel{Settled: m.settled, Stake: big.NewInt(0)}, nil
+}
+
+func (m *mockCriticalReader) GetConsumerChannelsHermes(channelAddress common.Address) (ConsumersHermes, error) {
+	return ConsumersHermes{Settled: m.settled}, nil
+}
+
+func TestCrossChecker(t *testing.T) {
+	a, b := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	primary := &mockCriticalReader{beneficiaries: []common.Address{a}, settled: big.NewInt(10)}
+	// the secondary provider lags behind a block
+	secondary := &mockCriticalReader{beneficiaries: []common.Address{b, a}, settled: big.NewInt(10)}
+
+	var mismatches []Mismatch
+	cc := NewCrossChecker(primary, secondary, 0)
+	cc.AttachMismatchFunc(func(m Mismatch) { mismatches = append(mismatches, m) })
+
+	res, err := cc.GetBeneficiary(common.Address{}, common.Address{})
+	assert.NoError(t, err)
+	assert.Equal(t, a, res)
+
+	ch, err := cc.GetProviderChannel(common.Address{}, common.Address{}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(10), ch.Settled)
+	assert.Len(t, mismatches, 0)
+
+	secondary.settled = big.NewInt(1)
+	_, err = cc.GetConsumerChannelsHermes(common.Address{})
+	assert.True(t, errors.Is(err, ErrCrossCheckMismatch))
+	assert.Len(t, mismatches, 1)
+	assert.Equal(t, "GetConsumerChannelsHermes", mismatches[0].Method)
+}
@@ -0,0 +1,48 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/bindings"
+)
+
+// GetTokenDecimals returns the decimals of the ERC-20 token, e.g. 18 for myst or 6 for USDC.
+// Token decimals never change, so they are read once per token and cached.
+func (bc *Blockchain) GetTokenDecimals(token common.Address) (uint8, error) {
+	if cached, ok := bc.decimals.Load(token); ok {
+		return cached.(uint8), nil
+	}
+
+	c, err := bindings.NewMystTokenCaller(token, bc.ethClient.Client())
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+
+	decimals, err := c.Decimals(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return 0, wrap(err, "could not get token decimals")
+	}
+
+	bc.decimals.Store(token, decimals)
+	return decimals, nil
+}
@@ -0,0 +1,45 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTokenDecimalsIsCached(t *testing.T) {
+	svc := &stateService{head: 6}
+	client, server := newStateClient(t, svc)
+	defer server.Stop()
+
+	bc := NewBlockchain(client, time.Second)
+	decimals, err := bc.GetTokenDecimals(common.HexToAddress("0x1"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(6), decimals)
+
+	svc.head = 18
+	decimals, err = bc.GetTokenDecimals(common.HexToAddress("0x1"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(6), decimals)
+
+	decimals, err = bc.GetTokenDecimals(common.HexToAddress("0x2"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(18), decimals)
+}
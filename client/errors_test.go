@@ -0,0 +1,37 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrap(t *testing.T) {
+	assert.Nil(t, wrap(nil, "could not get hermes fee"))
+
+	err := wrap(wrap(bind.ErrNoCode, "could not get hermes fee"), "could not get hermes fee")
+	assert.Equal(t, "could not get hermes fee: could not get hermes fee: no contract code at given address", err.Error())
+	assert.True(t, errors.Is(err, bind.ErrNoCode))
+
+	var clientErr *Error
+	assert.True(t, errors.As(err, &clientErr))
+	assert.Equal(t, "could not get hermes fee", clientErr.Op)
+}
@@ -18,30 +18,121 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
 )
 
+// DialFunc connects to an ethereum node.
+// Besides the dialers below, node.Node.Attach of an in-process geth node can be used directly.
+type DialFunc func() (*rpc.Client, error)
+
+// AddressDialer dials the node at the given http(s), ws(s) or IPC address.
+func AddressDialer(address string) DialFunc {
+	return func() (*rpc.Client, error) {
+		return rpc.DialContext(context.Background(), address)
+	}
+}
+
+// IPCDialer dials the node over the IPC endpoint at the given path.
+func IPCDialer(path string) DialFunc {
+	return func() (*rpc.Client, error) {
+		return rpc.DialIPC(context.Background(), path)
+	}
+}
+
+// InProcDialer attaches to the RPC server of an in-process node, e.g. an embedded light client.
+func InProcDialer(server *rpc.Server) DialFunc {
+	return func() (*rpc.Client, error) {
+		return rpc.DialInProc(server), nil
+	}
+}
+
+// DefaultFailoverCooldown is how long a failed endpoint is skipped when reconnecting.
+const DefaultFailoverCooldown = time.Minute
+
+// DefaultHealthCheckTimeout limits the health check of a single endpoint.
+const DefaultHealthCheckTimeout = 5 * time.Second
+
+// failoverGrace protects a fresh connection from being failed over by calls that failed on the previous one.
+const failoverGrace = time.Second
+
 // NewReconnectableEthClient creates new ethereum client that can reconnect.
 func NewReconnectableEthClient(address string) (*ReconnectableEthClient, error) {
-	ec, err := ethclient.Dial(address)
-	if err != nil {
-		return nil, fmt.Errorf("ethereum client failed to connect: %w", err)
+	return NewReconnectableEthClientWithDialer(AddressDialer(address))
+}
+
+// NewReconnectableEthClientWithDialer creates new ethereum client that connects, and reconnects, using the given dial func.
+func NewReconnectableEthClientWithDialer(dial DialFunc) (*ReconnectableEthClient, error) {
+	return NewReconnectableEthClientWithDialers(dial)
+}
+
+// NewReconnectableEthClientWithFailover creates new ethereum client that connects to the first available of the given addresses.
+// The addresses are given in priority order, see NewReconnectableEthClientWithDialers.
+func NewReconnectableEthClientWithFailover(addresses ...string) (*ReconnectableEthClient, error) {
+	dials := make([]DialFunc, len(addresses))
+	for i, address := range addresses {
+		dials[i] = AddressDialer(address)
+	}
+	return NewReconnectableEthClientWithDialers(dials...)
+}
+
+// NewReconnectableEthClientWithDialers creates new ethereum client that connects to the first endpoint that can be dialed.
+// The endpoints are given in priority order. Endpoints that fail are skipped for the failover cooldown,
+// the client fails over to the next one and returns to the preferred endpoints once health checks find them healthy.
+func NewReconnectableEthClientWithDialers(dials ...DialFunc) (*ReconnectableEthClient, error) {
+	if len(dials) == 0 {
+		return nil, errors.New("ethereum client needs at least one endpoint")
 	}
 
-	return &ReconnectableEthClient{
-		address: address,
-		client:  ec,
-	}, nil
+	c := &ReconnectableEthClient{
+		cooldown:           DefaultFailoverCooldown,
+		healthCheckTimeout: DefaultHealthCheckTimeout,
+		now:                time.Now,
+	}
+	for _, dial := range dials {
+		c.endpoints = append(c.endpoints, &endpoint{dial: dial})
+	}
+
+	if err := c.connect(); err != nil {
+		return nil, fmt.Errorf("ethereum client failed to connect: %w", err)
+	}
+	return c, nil
 }
 
 // ReconnectableEthClient is a ethereum client that can reconnect.
 type ReconnectableEthClient struct {
-	address string
-	mu      sync.Mutex
-	client  *ethclient.Client
+	endpoints          []*endpoint
+	cooldown           time.Duration
+	healthCheckTimeout time.Duration
+	now                func() time.Time
+
+	mu          sync.Mutex
+	current     int
+	connectedAt time.Time
+	rpcClient   *rpc.Client
+	client      *ethclient.Client
+}
+
+// endpoint is a node the client can connect to.
+type endpoint struct {
+	dial DialFunc
+	// failedAt is the time of the last failure, zero while the endpoint is healthy.
+	failedAt time.Time
+}
+
+// SetFailoverCooldown sets how long a failed endpoint is skipped when reconnecting.
+// Not thread safe, call before using the client.
+func (c *ReconnectableEthClient) SetFailoverCooldown(cooldown time.Duration) {
+	c.cooldown = cooldown
 }
 
 // Client returns the currently connected ethereum client.
@@ -52,18 +143,164 @@ func (c *ReconnectableEthClient) Client() *ethclient.Client {
 	return c.client
 }
 
+// RPCClient returns the raw RPC client of the current connection.
+// It allows calling the node methods ethclient does not expose.
+func (c *ReconnectableEthClient) RPCClient() *rpc.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.rpcClient
+}
+
+// Endpoint returns the index of the endpoint the client is connected to.
+func (c *ReconnectableEthClient) Endpoint() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.current
+}
+
 // Reconnect creates new ethereum client and replaces the current one.
+// The endpoints are dialed in priority order, skipping the ones that failed within the cooldown.
 func (c *ReconnectableEthClient) Reconnect() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	client, err := ethclient.Dial(c.address)
-	if err != nil {
+	if err := c.connect(); err != nil {
 		return fmt.Errorf("ethereum client failed to dial: %w", err)
 	}
+	return nil
+}
+
+// Failover marks the current endpoint failed and connects to the next available one.
+// It is a no-op right after connecting, so calls that failed on the previous connection do not skip the new one.
+func (c *ReconnectableEthClient) Failover() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	c.client.Close()
-	c.client = client
+	if c.now().Sub(c.connectedAt) < failoverGrace {
+		return nil
+	}
 
+	c.endpoints[c.current].failedAt = c.now()
+	if err := c.connect(); err != nil {
+		return fmt.Errorf("ethereum client failed to fail over: %w", err)
+	}
 	return nil
 }
+
+// connect dials the endpoints in priority order and replaces the current connection with the first one that succeeds.
+// Endpoints in cooldown are only dialed if all the others fail.
+func (c *ReconnectableEthClient) connect() error {
+	var available, cooling []int
+	for i, e := range c.endpoints {
+		if c.inCooldown(e) {
+			cooling = append(cooling, i)
+		} else {
+			available = append(available, i)
+		}
+	}
+
+	var errs []string
+	var lastErr error
+	for _, i := range append(available, cooling...) {
+		e := c.endpoints[i]
+		rc, err := e.dial()
+		if err != nil {
+			e.failedAt = c.now()
+			errs = append(errs, fmt.Sprintf("endpoint %v: %v", i, err))
+			lastErr = err
+			continue
+		}
+
+		e.failedAt = time.Time{}
+		if c.client != nil {
+			c.client.Close()
+		}
+		c.current = i
+		c.connectedAt = c.now()
+		c.rpcClient = rc
+		c.client = ethclient.NewClient(rc)
+		return nil
+	}
+	if len(errs) == 1 {
+		return lastErr
+	}
+	return errors.New(strings.Join(errs, "; "))
+}
+
+func (c *ReconnectableEthClient) inCooldown(e *endpoint) bool {
+	return !e.failedAt.IsZero() && c.now().Sub(e.failedAt) < c.cooldown
+}
+
+// CheckHealth checks every endpoint by requesting its latest block number.
+// It fails over if the current endpoint is unhealthy and returns to a preferred endpoint once it is healthy again.
+func (c *ReconnectableEthClient) CheckHealth() error {
+	c.mu.Lock()
+	current, rc := c.current, c.rpcClient
+	c.mu.Unlock()
+
+	healthy := make([]bool, len(c.endpoints))
+	for i, e := range c.endpoints {
+		if i == current {
+			healthy[i] = c.ping(rc) == nil
+			continue
+		}
+
+		erc, err := e.dial()
+		if err != nil {
+			continue
+		}
+		healthy[i] = c.ping(erc) == nil
+		erc.Close()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, e := range c.endpoints {
+		if !healthy[i] {
+			if e.failedAt.IsZero() {
+				e.failedAt = c.now()
+			}
+		} else {
+			e.failedAt = time.Time{}
+		}
+	}
+
+	if c.current != current {
+		// reconnected meanwhile
+		return nil
+	}
+	for i := 0; i < current; i++ {
+		if healthy[i] {
+			return c.connect()
+		}
+	}
+	if !healthy[current] {
+		return c.connect()
+	}
+	return nil
+}
+
+// RunHealthChecks checks the health of the endpoints every interval until the stop channel is closed.
+func (c *ReconnectableEthClient) RunHealthChecks(stop <-chan struct{}, interval time.Duration) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+			if err := c.CheckHealth(); err != nil {
+				log.Warn().Err(err).Msg("ethereum client health check failed")
+			}
+		}
+	}
+}
+
+func (c *ReconnectableEthClient) ping(rc *rpc.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.healthCheckTimeout)
+	defer cancel()
+
+	var block hexutil.Uint64
+	return rc.CallContext(ctx, &block, "eth_blockNumber")
+}
@@ -18,8 +18,15 @@
 package client
 
 import (
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -37,3 +44,116 @@ func TestReconnectableEthClientCreatesNewClient(t *testing.T) {
 	c3 := client.Client()
 	assert.NotEqual(t, c1, c3)
 }
+
+type netService struct{}
+
+func (netService) Version() string {
+	return "5"
+}
+
+func TestReconnectableEthClientInProc(t *testing.T) {
+	server := rpc.NewServer()
+	defer server.Stop()
+	assert.NoError(t, server.RegisterName("net", netService{}))
+
+	client, err := NewReconnectableEthClientWithDialer(InProcDialer(server))
+	assert.NoError(t, err)
+
+	id, err := NewBlockchain(client, time.Second).NetworkID()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), id.Int64())
+
+	assert.NoError(t, client.Reconnect())
+	id, err = NewBlockchain(client, time.Second).NetworkID()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), id.Int64())
+}
+
+type blockNumberService struct {
+	down int32
+}
+
+func (bs *blockNumberService) BlockNumber() (hexutil.Uint64, error) {
+	if atomic.LoadInt32(&bs.down) == 1 {
+		return 0, errors.New("node is syncing")
+	}
+	return 1, nil
+}
+
+// flakyEndpoint is an in-process node whose dials and calls can be failed.
+type flakyEndpoint struct {
+	server *rpc.Server
+	svc    *blockNumberService
+	dials  int32
+}
+
+func newFlakyEndpoint(t *testing.T) *flakyEndpoint {
+	fe := &flakyEndpoint{server: rpc.NewServer(), svc: &blockNumberService{}}
+	assert.NoError(t, fe.server.RegisterName("eth", fe.svc))
+	return fe
+}
+
+func (fe *flakyEndpoint) setDown(down bool) {
+	v := int32(0)
+	if down {
+		v = 1
+	}
+	atomic.StoreInt32(&fe.svc.down, v)
+}
+
+func (fe *flakyEndpoint) dial() (*rpc.Client, error) {
+	atomic.AddInt32(&fe.dials, 1)
+	if atomic.LoadInt32(&fe.svc.down) == 1 {
+		return nil, errors.New("connection refused")
+	}
+	return rpc.DialInProc(fe.server), nil
+}
+
+func TestReconnectableEthClientFailover(t *testing.T) {
+	primary, secondary := newFlakyEndpoint(t), newFlakyEndpoint(t)
+	defer primary.server.Stop()
+	defer secondary.server.Stop()
+
+	primary.setDown(true)
+	client, err := NewReconnectableEthClientWithDialers(primary.dial, secondary.dial)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, client.Endpoint())
+
+	now := time.Now()
+	client.now = func() time.Time { return now }
+
+	// the failed primary is in cooldown, reconnecting stays on the secondary
+	primary.setDown(false)
+	dials := atomic.LoadInt32(&primary.dials)
+	assert.NoError(t, client.Reconnect())
+	assert.Equal(t, 1, client.Endpoint())
+	assert.Equal(t, dials, atomic.LoadInt32(&primary.dials))
+
+	// the health check finds the primary healthy again and returns to it
+	assert.NoError(t, client.CheckHealth())
+	assert.Equal(t, 0, client.Endpoint())
+
+	// calls failed on the previous connection do not fail the fresh one over
+	assert.NoError(t, client.Failover())
+	assert.Equal(t, 0, client.Endpoint())
+
+	now = now.Add(failoverGrace)
+	assert.NoError(t, client.Failover())
+	assert.Equal(t, 1, client.Endpoint())
+
+	// an unhealthy secondary fails over to the primary, the health check ended its cooldown
+	secondary.setDown(true)
+	assert.NoError(t, client.CheckHealth())
+	assert.Equal(t, 0, client.Endpoint())
+
+	primary.setDown(true)
+	_, err = NewReconnectableEthClientWithDialers(primary.dial, secondary.dial)
+	assert.Error(t, err)
+}
+
+func TestIsConnectionError(t *testing.T) {
+	assert.True(t, isConnectionError(wrap(io.EOF, "could not get balance")))
+	assert.True(t, isConnectionError(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.False(t, isConnectionError(revertError{}))
+	assert.False(t, isConnectionError(errors.New("abi: could not unpack")))
+}
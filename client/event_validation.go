@@ -0,0 +1,251 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrInvalidEvent is wrapped by the errors of events failing validation.
+var ErrInvalidEvent = errors.New("invalid event")
+
+// EventValidationOpts configures the validation of subscription events.
+type EventValidationOpts struct {
+	// MaxAmount bounds every amount of a delivered event. Amounts are only required to be non-negative if nil.
+	MaxAmount *big.Int
+	// Quarantine receives the events failing validation. They are dropped if it is nil or full.
+	Quarantine chan<- QuarantinedEvent
+}
+
+// QuarantinedEvent is a subscription event that failed validation and was not delivered to the sink.
+type QuarantinedEvent struct {
+	Event    string
+	Contract common.Address
+	// Payload is the decoded event, e.g. *bindings.MystTokenTransfer.
+	Payload interface{}
+	Err     error
+}
+
+// AttachEventValidation validates the events of subscriptions made afterwards before delivering them to the sinks.
+// Not thread safe, call before subscribing.
+func (bc *Blockchain) AttachEventValidation(opts EventValidationOpts) {
+	bc.validation = &opts
+}
+
+// zeroAddressAllowed lists the event fields that legitimately hold the zero address.
+var zeroAddressAllowed = map[reflect.Type]map[string]bool{
+	// mints are transfers from and burns are transfers to the zero address
+	reflect.TypeOf(bindings.MystTokenTransfer{}): {"From": true, "To": true},
+	// identities can be registered without a beneficiary
+	reflect.TypeOf(bindings.RegistryRegisteredIdentity{}): {"Beneficiary": true},
+}
+
+// eventABIs maps the binding event type prefixes to the ABIs the events are declared in.
+var eventABIs = map[string]string{
+	"ChannelImplementation": bindings.ChannelImplementationABI,
+	"HermesImplementation":  bindings.HermesImplementationABI,
+	"MystToken":             bindings.MystTokenABI,
+	"Registry":              bindings.RegistryABI,
+}
+
+var (
+	parsedEventABIs     map[string]abi.ABI
+	parsedEventABIsOnce sync.Once
+)
+
+// ValidateEvent checks the decoded event emitted by the contract against basic invariants:
+// the log was emitted by the contract and its topics match the decoded fields,
+// addresses are not zero and amounts are not negative nor above maxAmount, if given.
+func ValidateEvent(contract common.Address, ev interface{}, maxAmount *big.Int) error {
+	v := reflect.ValueOf(ev)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return fmt.Errorf("%w: nil event", ErrInvalidEvent)
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: unexpected payload %T", ErrInvalidEvent, ev)
+	}
+
+	raw, ok := v.FieldByName("Raw").Interface().(types.Log)
+	if !ok {
+		return fmt.Errorf("%w: %v has no raw log", ErrInvalidEvent, v.Type().Name())
+	}
+	if raw.Address != contract {
+		return fmt.Errorf("%w: emitted by %v instead of %v", ErrInvalidEvent, raw.Address.Hex(), contract.Hex())
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		switch f := v.Field(i).Interface().(type) {
+		case common.Address:
+			if f == (common.Address{}) && !zeroAddressAllowed[v.Type()][name] {
+				return fmt.Errorf("%w: zero address %v", ErrInvalidEvent, name)
+			}
+		case *big.Int:
+			if err := checkAmount(f, maxAmount); err != nil {
+				return fmt.Errorf("%w: amount %v %v", ErrInvalidEvent, name, err)
+			}
+		}
+	}
+
+	return checkTopics(v, raw)
+}
+
+func checkAmount(amount, max *big.Int) error {
+	switch {
+	case amount == nil:
+		return errors.New("is missing")
+	case amount.Sign() < 0:
+		return fmt.Errorf("is negative: %v", amount)
+	case max != nil && amount.Cmp(max) > 0:
+		return fmt.Errorf("exceeds %v: %v", max, amount)
+	}
+	return nil
+}
+
+// checkTopics compares the indexed addresses of the raw log with the decoded ones.
+// Events not declared in the known ABIs are only checked to have a signature topic.
+func checkTopics(v reflect.Value, raw types.Log) error {
+	if len(raw.Topics) == 0 {
+		return fmt.Errorf("%w: log without topics", ErrInvalidEvent)
+	}
+
+	ev, ok := eventDefinition(v.Type().Name())
+	if !ok {
+		return nil
+	}
+	if raw.Topics[0] != ev.ID {
+		return fmt.Errorf("%w: topic %v is not the %v signature", ErrInvalidEvent, raw.Topics[0].Hex(), ev.Name)
+	}
+
+	topic := 1
+	for _, input := range ev.Inputs {
+		if !input.Indexed {
+			continue
+		}
+		if topic >= len(raw.Topics) {
+			return fmt.Errorf("%w: missing topic of %v", ErrInvalidEvent, input.Name)
+		}
+		if input.Type.T == abi.AddressTy {
+			field := v.FieldByName(abi.ToCamelCase(input.Name))
+			addr, ok := field.Interface().(common.Address)
+			if ok && common.BytesToAddress(raw.Topics[topic].Bytes()) != addr {
+				return fmt.Errorf("%w: %v does not match its topic", ErrInvalidEvent, input.Name)
+			}
+		}
+		topic++
+	}
+	return nil
+}
+
+func eventDefinition(typeName string) (abi.Event, bool) {
+	parsedEventABIsOnce.Do(func() {
+		parsedEventABIs = make(map[string]abi.ABI, len(eventABIs))
+		for prefix, def := range eventABIs {
+			parsed, err := abi.JSON(strings.NewReader(def))
+			if err != nil {
+				log.Error().Err(err).Msgf("could not parse %v abi", prefix)
+				continue
+			}
+			parsedEventABIs[prefix] = parsed
+		}
+	})
+
+	for prefix, parsed := range parsedEventABIs {
+		if !strings.HasPrefix(typeName, prefix) {
+			continue
+		}
+		ev, ok := parsed.Events[strings.TrimPrefix(typeName, prefix)]
+		if ok {
+			return ev, true
+		}
+	}
+	return abi.Event{}, false
+}
+
+// admitEvent validates the event if validation is attached. Invalid events are quarantined and false is returned.
+func (bc *Blockchain) admitEvent(eventName string, contract common.Address, ev interface{}) bool {
+	if bc.validation == nil {
+		return true
+	}
+	err := ValidateEvent(contract, ev, bc.validation.MaxAmount)
+	if err == nil {
+		return true
+	}
+
+	log.Warn().Err(err).Str("event", eventName).Str("contract", contract.Hex()).Msg("quarantining event")
+	select {
+	case bc.validation.Quarantine <- QuarantinedEvent{Event: eventName, Contract: contract, Payload: ev, Err: err}:
+	default:
+		log.Warn().Str("event", eventName).Msg("event quarantine full, dropping event")
+	}
+	return false
+}
+
+// validatedSink returns the channel the events should be watched into and the func closing the sink.
+// With validation attached, the events are passed to the sink only if they are valid.
+func (bc *Blockchain) validatedSink(eventName string, contract common.Address, sink interface{}) (interface{}, func()) {
+	out := reflect.ValueOf(sink)
+	if bc.validation == nil {
+		return sink, func() { out.Close() }
+	}
+
+	in := reflect.MakeChan(out.Type(), 0)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			chosen, ev, _ := reflect.Select([]reflect.SelectCase{
+				{Dir: reflect.SelectRecv, Chan: in},
+				{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(stop)},
+			})
+			if chosen == 1 {
+				return
+			}
+			if !bc.admitEvent(eventName, contract, ev.Interface()) {
+				continue
+			}
+			chosen, _, _ = reflect.Select([]reflect.SelectCase{
+				{Dir: reflect.SelectSend, Chan: out, Send: ev},
+				{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(stop)},
+			})
+			if chosen == 1 {
+				return
+			}
+		}
+	}()
+
+	return in.Interface(), func() {
+		close(stop)
+		<-stopped
+		out.Close()
+	}
+}
@@ -0,0 +1,118 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/stretchr/testify/assert"
+)
+
+var transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+func testTransfer(token, from, to common.Address, value *big.Int) *bindings.MystTokenTransfer {
+	return &bindings.MystTokenTransfer{
+		From:  from,
+		To:    to,
+		Value: value,
+		Raw: types.Log{
+			Address: token,
+			Topics:  []common.Hash{transferTopic, from.Hash(), to.Hash()},
+		},
+	}
+}
+
+func TestValidateEvent(t *testing.T) {
+	token := common.HexToAddress("0x1")
+	from, to := common.HexToAddress("0x2"), common.HexToAddress("0x3")
+
+	assert.NoError(t, ValidateEvent(token, testTransfer(token, from, to, big.NewInt(10)), big.NewInt(10)))
+	assert.NoError(t, ValidateEvent(token, testTransfer(token, common.Address{}, to, big.NewInt(10)), nil), "mints are valid")
+
+	invalid := map[string]*bindings.MystTokenTransfer{
+		"other contract":  testTransfer(common.HexToAddress("0x4"), from, to, big.NewInt(1)),
+		"negative amount": testTransfer(token, from, to, big.NewInt(-1)),
+		"amount too big":  testTransfer(token, from, to, big.NewInt(11)),
+		"missing amount":  testTransfer(token, from, to, nil),
+	}
+	mismatch := testTransfer(token, from, to, big.NewInt(1))
+	mismatch.To = common.HexToAddress("0x5")
+	invalid["topic mismatch"] = mismatch
+	wrongSig := testTransfer(token, from, to, big.NewInt(1))
+	wrongSig.Raw.Topics[0] = common.HexToHash("0x6")
+	invalid["wrong signature"] = wrongSig
+
+	for name, ev := range invalid {
+		assert.True(t, errors.Is(ValidateEvent(token, ev, big.NewInt(10)), ErrInvalidEvent), name)
+	}
+
+	withdraw := &bindings.ChannelImplementationWithdraw{
+		Amount: big.NewInt(1),
+		Raw:    types.Log{Address: token, Topics: []common.Hash{{1}}},
+	}
+	assert.True(t, errors.Is(ValidateEvent(token, withdraw, nil), ErrInvalidEvent), "zero beneficiary")
+}
+
+func TestValidatedSinkQuarantinesInvalidEvents(t *testing.T) {
+	token := common.HexToAddress("0x1")
+	quarantine := make(chan QuarantinedEvent, 1)
+	bc := &Blockchain{}
+	bc.AttachEventValidation(EventValidationOpts{MaxAmount: big.NewInt(100), Quarantine: quarantine})
+
+	sink := make(chan *bindings.MystTokenTransfer)
+	watch, closeSink := bc.validatedSink("Transfer", token, sink)
+	in := watch.(chan *bindings.MystTokenTransfer)
+
+	bad := testTransfer(token, common.HexToAddress("0x2"), common.HexToAddress("0x3"), big.NewInt(101))
+	good := testTransfer(token, common.HexToAddress("0x2"), common.HexToAddress("0x3"), big.NewInt(100))
+	go func() {
+		in <- bad
+		in <- good
+	}()
+
+	assert.Equal(t, good, <-sink)
+	q := <-quarantine
+	assert.Equal(t, "Transfer", q.Event)
+	assert.Equal(t, bad, q.Payload)
+	assert.True(t, errors.Is(q.Err, ErrInvalidEvent))
+
+	closeSink()
+	_, ok := <-sink
+	assert.False(t, ok)
+}
+
+func TestValidatedSinkClosedOnFailedSubscription(t *testing.T) {
+	client, server := newTraceClient(t, map[string]interface{}{})
+	defer server.Stop()
+
+	bc := NewBlockchain(client, time.Second)
+	bc.AttachEventValidation(EventValidationOpts{MaxAmount: big.NewInt(100), Quarantine: make(chan QuarantinedEvent, 1)})
+
+	sink, _, err := bc.SubscribeToConsumerBalanceEvent(common.HexToAddress("0x1"), common.HexToAddress("0x2"), time.Second)
+	assert.Error(t, err)
+
+	// the validating goroutine is stopped and the sink closed
+	_, ok := <-sink
+	assert.False(t, ok)
+}
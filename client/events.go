@@ -0,0 +1,266 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/mysteriumnetwork/payments/bindings"
+)
+
+// SubscribeToProviderStakeEvents subscribes to provider channel stake updates, both increases and decreases.
+// The subscription is resumed after connection failures until cancelled.
+func (bc *Blockchain) SubscribeToProviderStakeEvents(hermesID common.Address, channelIDs [][32]byte) (sink chan *bindings.HermesImplementationNewStake, sub *Subscription, err error) {
+	filterer, err := bindings.NewHermesImplementationFilterer(hermesID, bc.subscriptionFilterer())
+	if err != nil {
+		return nil, nil, wrap(err, "could not create hermes filterer")
+	}
+
+	sink = make(chan *bindings.HermesImplementationNewStake)
+	watch, closeSink := bc.validatedSink("NewStake", hermesID, sink)
+	resub := event.Resubscribe(DefaultBackoff, func(ctx context.Context) (event.Subscription, error) {
+		return filterer.WatchNewStake(&bind.WatchOpts{Context: ctx}, watch.(chan *bindings.HermesImplementationNewStake), channelIDs)
+	})
+
+	return sink, bc.newSubscription("NewStake", hermesID, resub, closeSink), nil
+}
+
+// FilterProviderStakeEvents returns the provider channel stake updates, both increases and decreases in the given block range. A nil end block means the latest block.
+func (bc *Blockchain) FilterProviderStakeEvents(hermesID common.Address, channelIDs [][32]byte, start uint64, end *uint64) ([]*bindings.HermesImplementationNewStake, error) {
+	filterer, err := bindings.NewHermesImplementationFilterer(hermesID, bc.logClient())
+	if err != nil {
+		return nil, wrap(err, "could not create hermes filterer")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+
+	it, err := filterer.FilterNewStake(&bind.FilterOpts{Start: start, End: end, Context: ctx}, channelIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var res []*bindings.HermesImplementationNewStake
+	for it.Next() {
+		res = append(res, it.Event)
+	}
+	return res, it.Error()
+}
+
+// SubscribeToHermesStakeIncreasedEvents subscribes to hermes stake increase events.
+// The subscription is resumed after connection failures until cancelled.
+func (bc *Blockchain) SubscribeToHermesStakeIncreasedEvents(hermesID common.Address) (sink chan *bindings.HermesImplementationHermesStakeIncreased, sub *Subscription, err error) {
+	filterer, err := bindings.NewHermesImplementationFilterer(hermesID, bc.subscriptionFilterer())
+	if err != nil {
+		return nil, nil, wrap(err, "could not create hermes filterer")
+	}
+
+	sink = make(chan *bindings.HermesImplementationHermesStakeIncreased)
+	watch, closeSink := bc.validatedSink("HermesStakeIncreased", hermesID, sink)
+	resub := event.Resubscribe(DefaultBackoff, func(ctx context.Context) (event.Subscription, error) {
+		return filterer.WatchHermesStakeIncreased(&bind.WatchOpts{Context: ctx}, watch.(chan *bindings.HermesImplementationHermesStakeIncreased))
+	})
+
+	return sink, bc.newSubscription("HermesStakeIncreased", hermesID, resub, closeSink), nil
+}
+
+// FilterHermesStakeIncreasedEvents returns the hermes stake increase events in the given block range. A nil end block means the latest block.
+func (bc *Blockchain) FilterHermesStakeIncreasedEvents(hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationHermesStakeIncreased, error) {
+	filterer, err := bindings.NewHermesImplementationFilterer(hermesID, bc.logClient())
+	if err != nil {
+		return nil, wrap(err, "could not create hermes filterer")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+
+	it, err := filterer.FilterHermesStakeIncreased(&bind.FilterOpts{Start: start, End: end, Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var res []*bindings.HermesImplementationHermesStakeIncreased
+	for it.Next() {
+		res = append(res, it.Event)
+	}
+	return res, it.Error()
+}
+
+// SubscribeToHermesFeeUpdatedEvents subscribes to hermes fee update events.
+// The subscription is resumed after connection failures until cancelled.
+func (bc *Blockchain) SubscribeToHermesFeeUpdatedEvents(hermesID common.Address) (sink chan *bindings.HermesImplementationHermesFeeUpdated, sub *Subscription, err error) {
+	filterer, err := bindings.NewHermesImplementationFilterer(hermesID, bc.subscriptionFilterer())
+	if err != nil {
+		return nil, nil, wrap(err, "could not create hermes filterer")
+	}
+
+	sink = make(chan *bindings.HermesImplementationHermesFeeUpdated)
+	watch, closeSink := bc.validatedSink("HermesFeeUpdated", hermesID, sink)
+	resub := event.Resubscribe(DefaultBackoff, func(ctx context.Context) (event.Subscription, error) {
+		return filterer.WatchHermesFeeUpdated(&bind.WatchOpts{Context: ctx}, watch.(chan *bindings.HermesImplementationHermesFeeUpdated))
+	})
+
+	return sink, bc.newSubscription("HermesFeeUpdated", hermesID, resub, closeSink), nil
+}
+
+// FilterHermesFeeUpdatedEvents returns the hermes fee update events in the given block range. A nil end block means the latest block.
+func (bc *Blockchain) FilterHermesFeeUpdatedEvents(hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationHermesFeeUpdated, error) {
+	filterer, err := bindings.NewHermesImplementationFilterer(hermesID, bc.logClient())
+	if err != nil {
+		return nil, wrap(err, "could not create hermes filterer")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+
+	it, err := filterer.FilterHermesFeeUpdated(&bind.FilterOpts{Start: start, End: end, Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var res []*bindings.HermesImplementationHermesFeeUpdated
+	for it.Next() {
+		res = append(res, it.Event)
+	}
+	return res, it.Error()
+}
+
+// SubscribeToHermesFundsWithdrawnEvents subscribes to hermes funds withdrawal events.
+// The subscription is resumed after connection failures until cancelled.
+func (bc *Blockchain) SubscribeToHermesFundsWithdrawnEvents(hermesID common.Address) (sink chan *bindings.HermesImplementationFundsWithdrawned, sub *Subscription, err error) {
+	filterer, err := bindings.NewHermesImplementationFilterer(hermesID, bc.subscriptionFilterer())
+	if err != nil {
+		return nil, nil, wrap(err, "could not create hermes filterer")
+	}
+
+	sink = make(chan *bindings.HermesImplementationFundsWithdrawned)
+	watch, closeSink := bc.validatedSink("FundsWithdrawned", hermesID, sink)
+	resub := event.Resubscribe(DefaultBackoff, func(ctx context.Context) (event.Subscription, error) {
+		return filterer.WatchFundsWithdrawned(&bind.WatchOpts{Context: ctx}, watch.(chan *bindings.HermesImplementationFundsWithdrawned))
+	})
+
+	return sink, bc.newSubscription("FundsWithdrawned", hermesID, resub, closeSink), nil
+}
+
+// FilterHermesFundsWithdrawnEvents returns the hermes funds withdrawal events in the given block range. A nil end block means the latest block.
+func (bc *Blockchain) FilterHermesFundsWithdrawnEvents(hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationFundsWithdrawned, error) {
+	filterer, err := bindings.NewHermesImplementationFilterer(hermesID, bc.logClient())
+	if err != nil {
+		return nil, wrap(err, "could not create hermes filterer")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+
+	it, err := filterer.FilterFundsWithdrawned(&bind.FilterOpts{Start: start, End: end, Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var res []*bindings.HermesImplementationFundsWithdrawned
+	for it.Next() {
+		res = append(res, it.Event)
+	}
+	return res, it.Error()
+}
+
+// SubscribeToBeneficiaryChangedEvents subscribes to identity beneficiary change events.
+// The subscription is resumed after connection failures until cancelled.
+func (bc *Blockchain) SubscribeToBeneficiaryChangedEvents(registryAddress common.Address, identities []common.Address) (sink chan *bindings.RegistryBeneficiaryChanged, sub *Subscription, err error) {
+	filterer, err := bindings.NewRegistryFilterer(registryAddress, bc.subscriptionFilterer())
+	if err != nil {
+		return nil, nil, wrap(err, "could not create registry filterer")
+	}
+
+	sink = make(chan *bindings.RegistryBeneficiaryChanged)
+	watch, closeSink := bc.validatedSink("BeneficiaryChanged", registryAddress, sink)
+	resub := event.Resubscribe(DefaultBackoff, func(ctx context.Context) (event.Subscription, error) {
+		return filterer.WatchBeneficiaryChanged(&bind.WatchOpts{Context: ctx}, watch.(chan *bindings.RegistryBeneficiaryChanged), identities)
+	})
+
+	return sink, bc.newSubscription("BeneficiaryChanged", registryAddress, resub, closeSink), nil
+}
+
+// FilterBeneficiaryChangedEvents returns the identity beneficiary change events in the given block range. A nil end block means the latest block.
+func (bc *Blockchain) FilterBeneficiaryChangedEvents(registryAddress common.Address, identities []common.Address, start uint64, end *uint64) ([]*bindings.RegistryBeneficiaryChanged, error) {
+	filterer, err := bindings.NewRegistryFilterer(registryAddress, bc.logClient())
+	if err != nil {
+		return nil, wrap(err, "could not create registry filterer")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+
+	it, err := filterer.FilterBeneficiaryChanged(&bind.FilterOpts{Start: start, End: end, Context: ctx}, identities)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var res []*bindings.RegistryBeneficiaryChanged
+	for it.Next() {
+		res = append(res, it.Event)
+	}
+	return res, it.Error()
+}
+
+// SubscribeToChannelWithdrawEvents subscribes to consumer channel withdrawal events.
+// The subscription is resumed after connection failures until cancelled.
+func (bc *Blockchain) SubscribeToChannelWithdrawEvents(channelAddress common.Address) (sink chan *bindings.ChannelImplementationWithdraw, sub *Subscription, err error) {
+	filterer, err := bindings.NewChannelImplementationFilterer(channelAddress, bc.subscriptionFilterer())
+	if err != nil {
+		return nil, nil, wrap(err, "could not create channel filterer")
+	}
+
+	sink = make(chan *bindings.ChannelImplementationWithdraw)
+	watch, closeSink := bc.validatedSink("Withdraw", channelAddress, sink)
+	resub := event.Resubscribe(DefaultBackoff, func(ctx context.Context) (event.Subscription, error) {
+		return filterer.WatchWithdraw(&bind.WatchOpts{Context: ctx}, watch.(chan *bindings.ChannelImplementationWithdraw))
+	})
+
+	return sink, bc.newSubscription("Withdraw", channelAddress, resub, closeSink), nil
+}
+
+// FilterChannelWithdrawEvents returns the consumer channel withdrawal events in the given block range. A nil end block means the latest block.
+func (bc *Blockchain) FilterChannelWithdrawEvents(channelAddress common.Address, start uint64, end *uint64) ([]*bindings.ChannelImplementationWithdraw, error) {
+	filterer, err := bindings.NewChannelImplementationFilterer(channelAddress, bc.logClient())
+	if err != nil {
+		return nil, wrap(err, "could not create channel filterer")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+
+	it, err := filterer.FilterWithdraw(&bind.FilterOpts{Start: start, End: end, Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var res []*bindings.ChannelImplementationWithdraw
+	for it.Next() {
+		res = append(res, it.Event)
+	}
+	return res, it.Error()
+}
@@ -0,0 +1,215 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/mysteriumnetwork/payments/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// simulatedLogService serves the log queries and subscriptions of the eth namespace from a simulated backend.
+type simulatedLogService struct {
+	backend *testutil.SimulatedBackend
+}
+
+type filterArgs struct {
+	Address   []common.Address `json:"address"`
+	Topics    [][]common.Hash  `json:"topics"`
+	FromBlock string           `json:"fromBlock"`
+	ToBlock   string           `json:"toBlock"`
+}
+
+func (fa filterArgs) query() ethereum.FilterQuery {
+	q := ethereum.FilterQuery{Addresses: fa.Address, Topics: fa.Topics}
+	if fa.FromBlock != "" && fa.FromBlock != "latest" {
+		q.FromBlock = hexutil.MustDecodeBig(fa.FromBlock)
+	}
+	if fa.ToBlock != "" && fa.ToBlock != "latest" {
+		q.ToBlock = hexutil.MustDecodeBig(fa.ToBlock)
+	}
+	return q
+}
+
+func (s *simulatedLogService) GetLogs(args filterArgs) ([]types.Log, error) {
+	logs, err := s.backend.FilterLogs(context.Background(), args.query())
+	if logs == nil {
+		logs = []types.Log{}
+	}
+	return logs, err
+}
+
+func (s *simulatedLogService) Logs(ctx context.Context, args filterArgs) (*rpc.Subscription, error) {
+	notifier, _ := rpc.NotifierFromContext(ctx)
+	rpcSub := notifier.CreateSubscription()
+
+	logs := make(chan types.Log)
+	sub, err := s.backend.SubscribeFilterLogs(context.Background(), args.query(), logs)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case l := <-logs:
+				notifier.Notify(rpcSub.ID, l)
+			case <-rpcSub.Err():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+type hermesFixture struct {
+	backend *testutil.SimulatedBackend
+	opts    *bind.TransactOpts
+	hermes  *bindings.HermesImplementation
+	address common.Address
+	token   *bindings.MystToken
+	myst    common.Address
+	bc      *Blockchain
+}
+
+func newHermesFixture(t *testing.T) (*hermesFixture, func()) {
+	key, _ := crypto.GenerateKey()
+	opts := bind.NewKeyedTransactor(key)
+	backend := testutil.NewSimulatedBackend(core.GenesisAlloc{opts.From: {Balance: big.NewInt(0).Exp(big.NewInt(10), big.NewInt(20), nil)}}, 10000000)
+
+	oldMyst, _, _, err := bindings.DeployOldMystToken(opts, backend)
+	assert.NoError(t, err)
+	myst, _, token, err := bindings.DeployMystToken(opts, backend, oldMyst)
+	assert.NoError(t, err)
+	_, err = token.Mint(opts, opts.From, big.NewInt(1000))
+	assert.NoError(t, err)
+
+	address, _, hermes, err := bindings.DeployHermesImplementation(opts, backend)
+	assert.NoError(t, err)
+	_, err = hermes.Initialize(opts, myst, opts.From, 100, big.NewInt(0), big.NewInt(1000), common.HexToAddress("0xde"))
+	assert.NoError(t, err)
+
+	client, server := newTraceClient(t, map[string]interface{}{"eth": &simulatedLogService{backend: backend}})
+	f := &hermesFixture{
+		backend: backend,
+		opts:    opts,
+		hermes:  hermes,
+		address: address,
+		token:   token,
+		myst:    myst,
+		bc:      NewBlockchain(client, time.Second),
+	}
+	return f, func() {
+		server.Stop()
+		backend.Close()
+	}
+}
+
+func TestFilterHermesEvents(t *testing.T) {
+	f, cleanup := newHermesFixture(t)
+	defer cleanup()
+
+	_, err := f.hermes.SetHermesFee(f.opts, 200)
+	assert.NoError(t, err)
+	_, err = f.token.Approve(f.opts, f.address, big.NewInt(500))
+	assert.NoError(t, err)
+	stakeTx, err := f.hermes.IncreaseHermesStake(f.opts, big.NewInt(500))
+	assert.NoError(t, err)
+	_, err = f.token.Transfer(f.opts, f.address, big.NewInt(100))
+	assert.NoError(t, err)
+	_, err = f.hermes.Withdraw(f.opts, common.HexToAddress("0x1"), big.NewInt(10))
+	assert.NoError(t, err)
+	_, err = f.token.Approve(f.opts, f.address, big.NewInt(10))
+	assert.NoError(t, err)
+	_, err = f.hermes.OpenChannel(f.opts, common.HexToAddress("0x2"), big.NewInt(10))
+	assert.NoError(t, err)
+
+	fees, err := f.bc.FilterHermesFeeUpdatedEvents(f.address, 0, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, fees, 1) {
+		assert.Equal(t, uint16(200), fees[0].NewFee)
+	}
+
+	stakes, err := f.bc.FilterHermesStakeIncreasedEvents(f.address, 0, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, stakes, 1) {
+		assert.Equal(t, big.NewInt(500), stakes[0].NewStake)
+	}
+
+	receipt, err := f.backend.TransactionReceipt(context.Background(), stakeTx.Hash())
+	assert.NoError(t, err)
+	end := receipt.BlockNumber.Uint64() - 1
+	stakes, err = f.bc.FilterHermesStakeIncreasedEvents(f.address, 0, &end)
+	assert.NoError(t, err)
+	assert.Len(t, stakes, 0)
+
+	withdrawals, err := f.bc.FilterHermesFundsWithdrawnEvents(f.address, 0, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, withdrawals, 1) {
+		assert.Equal(t, common.HexToAddress("0x1"), withdrawals[0].Beneficiary)
+		assert.Equal(t, big.NewInt(10), withdrawals[0].Amount)
+	}
+
+	providerStakes, err := f.bc.FilterProviderStakeEvents(f.address, nil, 0, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, providerStakes, 1) {
+		assert.Equal(t, big.NewInt(10), providerStakes[0].StakeAmount)
+
+		providerStakes, err = f.bc.FilterProviderStakeEvents(f.address, [][32]byte{providerStakes[0].ChannelId}, 0, nil)
+		assert.NoError(t, err)
+		assert.Len(t, providerStakes, 1)
+	}
+
+	providerStakes, err = f.bc.FilterProviderStakeEvents(f.address, [][32]byte{{1}}, 0, nil)
+	assert.NoError(t, err)
+	assert.Len(t, providerStakes, 0)
+}
+
+func TestSubscribeToHermesFeeUpdatedEvents(t *testing.T) {
+	f, cleanup := newHermesFixture(t)
+	defer cleanup()
+
+	sink, sub, err := f.bc.SubscribeToHermesFeeUpdatedEvents(f.address)
+	assert.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	// the subscription is established asynchronously, keep updating the fee until it is observed
+	for i := uint16(1); i < 50; i++ {
+		_, err = f.hermes.SetHermesFee(f.opts, 200+i)
+		assert.NoError(t, err)
+		select {
+		case ev := <-sink:
+			assert.True(t, ev.NewFee > 200)
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	t.Fatal("fee update not received")
+}
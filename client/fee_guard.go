@@ -0,0 +1,96 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrFeeCapExceeded is returned when the transaction cost exceeds the configured fee cap.
+var ErrFeeCapExceeded = errors.New("transaction fee exceeds the configured cap")
+
+// ErrFeeGuardValueMissing is returned when the percentage cap is set but the operation value is not given.
+var ErrFeeGuardValueMissing = errors.New("operation value required by the fee cap is missing")
+
+// FeeGuard refuses transactions whose maximum cost, gas limit times gas price, is above the configured cap.
+type FeeGuard struct {
+	maxCost    *big.Int
+	maxPercent float64
+}
+
+// NewFeeGuard returns a new fee guard.
+// maxCost is the absolute cap in the native token, nil disables it.
+// maxPercentOfValue caps the cost relative to the value of the operation given in the request, zero disables it.
+// While it is set, requests without FeeGuardValue are refused unless they set OverrideFeeGuard.
+func NewFeeGuard(maxCost *big.Int, maxPercentOfValue float64) *FeeGuard {
+	return &FeeGuard{
+		maxCost:    maxCost,
+		maxPercent: maxPercentOfValue,
+	}
+}
+
+// Check returns ErrFeeCapExceeded if the transaction cost is above the cap.
+// The value is the native token value of the operation, it may only be nil if no percentage cap is set.
+func (fg *FeeGuard) Check(tx *types.Transaction, value *big.Int) error {
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas()), tx.GasPrice())
+
+	if fg.maxCost != nil && cost.Cmp(fg.maxCost) > 0 {
+		return fmt.Errorf("%w: cost %v is above %v", ErrFeeCapExceeded, cost, fg.maxCost)
+	}
+
+	if fg.maxPercent > 0 {
+		if value == nil {
+			return fmt.Errorf("%w: cost %v, cap %v%% of value", ErrFeeGuardValueMissing, cost, fg.maxPercent)
+		}
+		limit, _ := new(big.Float).Mul(new(big.Float).SetInt(value), big.NewFloat(fg.maxPercent/100)).Int(nil)
+		if cost.Cmp(limit) > 0 {
+			return fmt.Errorf("%w: cost %v is above %v%% of value %v", ErrFeeCapExceeded, cost, fg.maxPercent, value)
+		}
+	}
+
+	return nil
+}
+
+// WrapSigner returns a signer that checks the transaction before signing it.
+func (fg *FeeGuard) WrapSigner(signer bind.SignerFn, value *big.Int) bind.SignerFn {
+	return func(s types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		if err := fg.Check(tx, value); err != nil {
+			return nil, err
+		}
+		return signer(s, address, tx)
+	}
+}
+
+// AttachFeeGuard makes the blockchain refuse transactions exceeding the fee cap,
+// unless the request explicitly sets OverrideFeeGuard.
+// Not thread safe, call before sending any transactions.
+func (bc *Blockchain) AttachFeeGuard(fg *FeeGuard) {
+	bc.feeGuard = fg
+}
+
+func (bc *Blockchain) guardSigner(req WriteRequest) bind.SignerFn {
+	if bc.feeGuard == nil || req.OverrideFeeGuard || req.Signer == nil {
+		return req.Signer
+	}
+	return bc.feeGuard.WrapSigner(req.Signer, req.FeeGuardValue)
+}
@@ -0,0 +1,108 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeeGuard(t *testing.T) {
+	tx := types.NewTransaction(0, common.Address{}, nil, 100000, big.NewInt(10), nil)
+
+	assert.NoError(t, NewFeeGuard(big.NewInt(1000000), 0).Check(tx, nil))
+	assert.True(t, errors.Is(NewFeeGuard(big.NewInt(999999), 0).Check(tx, nil), ErrFeeCapExceeded))
+
+	assert.NoError(t, NewFeeGuard(nil, 10).Check(tx, big.NewInt(10000000)))
+	assert.True(t, errors.Is(NewFeeGuard(nil, 10).Check(tx, big.NewInt(9999999)), ErrFeeCapExceeded))
+	assert.True(t, errors.Is(NewFeeGuard(nil, 10).Check(tx, nil), ErrFeeGuardValueMissing))
+
+	bc := &Blockchain{}
+	bc.AttachFeeGuard(NewFeeGuard(big.NewInt(1), 0))
+	signer := func(types.Signer, common.Address, *types.Transaction) (*types.Transaction, error) { return tx, nil }
+
+	_, err := bc.guardSigner(WriteRequest{Signer: signer})(nil, common.Address{}, tx)
+	assert.True(t, errors.Is(err, ErrFeeCapExceeded))
+
+	_, err = bc.guardSigner(WriteRequest{Signer: signer, OverrideFeeGuard: true})(nil, common.Address{}, tx)
+	assert.NoError(t, err)
+
+	bc.AttachFeeGuard(NewFeeGuard(nil, 10))
+	_, err = bc.guardSigner(WriteRequest{Signer: signer})(nil, common.Address{}, tx)
+	assert.True(t, errors.Is(err, ErrFeeGuardValueMissing))
+	_, err = bc.guardSigner(WriteRequest{Signer: signer, FeeGuardValue: big.NewInt(10000000)})(nil, common.Address{}, tx)
+	assert.NoError(t, err)
+}
+
+func TestSendTransactionFeeGuard(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{}, nil, 100000, big.NewInt(10), nil), types.HomesteadSigner{}, key)
+	assert.NoError(t, err)
+
+	svc := &registryService{}
+	client, server := newTraceClient(t, map[string]interface{}{"eth": svc})
+	defer server.Stop()
+
+	bc := NewBlockchain(client, time.Second)
+	bc.AttachFeeGuard(NewFeeGuard(nil, 10))
+
+	assert.True(t, errors.Is(bc.SendTransaction(tx), ErrFeeGuardValueMissing))
+	assert.True(t, errors.Is(bc.SendTransactionWithFeeGuard(tx, big.NewInt(9999999), false), ErrFeeCapExceeded))
+	assert.Len(t, svc.sent, 0)
+
+	assert.NoError(t, bc.SendTransactionWithFeeGuard(tx, big.NewInt(10000000), false))
+	assert.NoError(t, bc.SendTransactionWithFeeGuard(tx, nil, true))
+	assert.Len(t, svc.sent, 2)
+}
+
+func TestTransactOptsMutatorSignerIsGuarded(t *testing.T) {
+	tx := types.NewTransaction(0, common.Address{}, nil, 100000, big.NewInt(10), nil)
+	bc := &Blockchain{}
+	bc.AttachFeeGuard(NewFeeGuard(big.NewInt(1), 0))
+
+	custom := false
+	req := WriteRequest{
+		Identity: common.HexToAddress("0x1"),
+		TransactOptsMutator: func(opts *bind.TransactOpts) {
+			opts.Value = big.NewInt(5)
+			opts.Signer = func(types.Signer, common.Address, *types.Transaction) (*types.Transaction, error) {
+				custom = true
+				return tx, nil
+			}
+		},
+	}
+	opts := bc.transactOpts(req, &bind.TransactOpts{From: req.Identity})
+	assert.Equal(t, big.NewInt(5), opts.Value)
+
+	_, err := opts.Signer(nil, req.Identity, tx)
+	assert.True(t, errors.Is(err, ErrFeeCapExceeded))
+	assert.False(t, custom)
+
+	req.OverrideFeeGuard = true
+	_, err = bc.transactOpts(req, &bind.TransactOpts{From: req.Identity}).Signer(nil, req.Identity, tx)
+	assert.NoError(t, err)
+	assert.True(t, custom)
+}
@@ -0,0 +1,158 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ErrFeeHistoryUnsupported is returned when the eth client does not expose the raw RPC connection needed for eth_feeHistory.
+var ErrFeeHistoryUnsupported = errors.New("fee history is not supported by the eth client")
+
+// FeeHistory is the result of eth_feeHistory.
+// BaseFee contains one more entry than the other fields: the base fee of the block following the newest one.
+type FeeHistory struct {
+	OldestBlock  *big.Int
+	BaseFee      []*big.Int
+	GasUsedRatio []float64
+	// Reward holds the priority fees at the requested percentiles for each block.
+	Reward [][]*big.Int
+}
+
+type rpcFeeHistory struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+type rpcClientGetter interface {
+	RPCClient() *rpc.Client
+}
+
+// FeeHistory returns the base fees and priority fee percentiles of blockCount blocks ending with the newest block.
+// If newest is nil, the history ends at the latest block.
+func (bc *Blockchain) FeeHistory(blockCount uint64, newest *big.Int, percentiles []float64) (*FeeHistory, error) {
+	getter, ok := bc.blockClient(newest).(rpcClientGetter)
+	if !ok {
+		return nil, ErrFeeHistoryUnsupported
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+
+	block := "latest"
+	if newest != nil {
+		block = hexutil.EncodeBig(newest)
+	}
+	if percentiles == nil {
+		percentiles = []float64{}
+	}
+
+	var res rpcFeeHistory
+	err := getter.RPCClient().CallContext(ctx, &res, "eth_feeHistory", hexutil.Uint64(blockCount), block, percentiles)
+	if err != nil {
+		return nil, err
+	}
+	return res.toFeeHistory(), nil
+}
+
+func (r rpcFeeHistory) toFeeHistory() *FeeHistory {
+	fh := &FeeHistory{
+		OldestBlock:  (*big.Int)(r.OldestBlock),
+		BaseFee:      make([]*big.Int, len(r.BaseFee)),
+		GasUsedRatio: r.GasUsedRatio,
+		Reward:       make([][]*big.Int, len(r.Reward)),
+	}
+	for i, b := range r.BaseFee {
+		fh.BaseFee[i] = (*big.Int)(b)
+	}
+	for i, rewards := range r.Reward {
+		fh.Reward[i] = make([]*big.Int, len(rewards))
+		for j, reward := range rewards {
+			fh.Reward[i][j] = (*big.Int)(reward)
+		}
+	}
+	return fh
+}
+
+// Blocks returns the number of blocks in the history.
+func (fh *FeeHistory) Blocks() int {
+	return len(fh.GasUsedRatio)
+}
+
+// NextBaseFee returns the base fee of the block following the newest block in the history.
+func (fh *FeeHistory) NextBaseFee() *big.Int {
+	if len(fh.BaseFee) == 0 {
+		return nil
+	}
+	return new(big.Int).Set(fh.BaseFee[len(fh.BaseFee)-1])
+}
+
+// Rewards returns the priority fee of every block at the given index of the requested percentiles.
+func (fh *FeeHistory) Rewards(percentileIndex int) []*big.Int {
+	res := make([]*big.Int, 0, len(fh.Reward))
+	for _, rewards := range fh.Reward {
+		if percentileIndex < len(rewards) && rewards[percentileIndex] != nil {
+			res = append(res, rewards[percentileIndex])
+		}
+	}
+	return res
+}
+
+// GasPrices returns the gas price of every block, i.e. the block base fee plus the priority fee at the given percentile index.
+func (fh *FeeHistory) GasPrices(percentileIndex int) []*big.Int {
+	res := make([]*big.Int, 0, fh.Blocks())
+	for i := 0; i < fh.Blocks() && i < len(fh.BaseFee); i++ {
+		price := new(big.Int).Set(fh.BaseFee[i])
+		if i < len(fh.Reward) && percentileIndex < len(fh.Reward[i]) && fh.Reward[i][percentileIndex] != nil {
+			price.Add(price, fh.Reward[i][percentileIndex])
+		}
+		res = append(res, price)
+	}
+	return res
+}
+
+// Percentile returns the value at the given percentile, from 0 to 100, of the values using nearest rank.
+// It returns nil for no values.
+func Percentile(values []*big.Int, percentile float64) *big.Int {
+	if len(values) == 0 {
+		return nil
+	}
+	sorted := make([]*big.Int, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+
+	if percentile <= 0 {
+		return new(big.Int).Set(sorted[0])
+	}
+	if percentile >= 100 {
+		return new(big.Int).Set(sorted[len(sorted)-1])
+	}
+	rank := int(math.Ceil(percentile/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return new(big.Int).Set(sorted[rank])
+}
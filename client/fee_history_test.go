@@ -0,0 +1,79 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+type feeHistoryService struct{}
+
+func (feeHistoryService) FeeHistory(blocks hexutil.Uint64, newest string, percentiles []float64) rpcFeeHistory {
+	return rpcFeeHistory{
+		OldestBlock:  (*hexutil.Big)(big.NewInt(10)),
+		BaseFee:      []*hexutil.Big{(*hexutil.Big)(big.NewInt(100)), (*hexutil.Big)(big.NewInt(110)), (*hexutil.Big)(big.NewInt(120))},
+		GasUsedRatio: []float64{0.5, 0.9},
+		Reward:       [][]*hexutil.Big{{(*hexutil.Big)(big.NewInt(1))}, {(*hexutil.Big)(big.NewInt(2))}},
+	}
+}
+
+func TestFeeHistory(t *testing.T) {
+	server := rpc.NewServer()
+	defer server.Stop()
+	assert.NoError(t, server.RegisterName("eth", feeHistoryService{}))
+
+	client, err := NewReconnectableEthClientWithDialer(InProcDialer(server))
+	assert.NoError(t, err)
+
+	fh, err := NewBlockchain(client, time.Second).FeeHistory(2, nil, []float64{50})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fh.Blocks())
+	assert.Equal(t, big.NewInt(10), fh.OldestBlock)
+	assert.Equal(t, big.NewInt(120), fh.NextBaseFee())
+	assert.Equal(t, []*big.Int{big.NewInt(1), big.NewInt(2)}, fh.Rewards(0))
+	assert.Equal(t, []*big.Int{big.NewInt(101), big.NewInt(112)}, fh.GasPrices(0))
+}
+
+type plainEthClient struct{}
+
+func (plainEthClient) Client() *ethclient.Client {
+	return nil
+}
+
+func TestFeeHistoryUnsupported(t *testing.T) {
+	_, err := NewBlockchain(plainEthClient{}, time.Second).FeeHistory(2, nil, nil)
+	assert.Equal(t, ErrFeeHistoryUnsupported, err)
+}
+
+func TestPercentile(t *testing.T) {
+	values := []*big.Int{big.NewInt(5), big.NewInt(1), big.NewInt(4), big.NewInt(2), big.NewInt(3)}
+
+	assert.Nil(t, Percentile(nil, 50))
+	assert.Equal(t, big.NewInt(1), Percentile(values, 0))
+	assert.Equal(t, big.NewInt(1), Percentile(values, 20))
+	assert.Equal(t, big.NewInt(3), Percentile(values, 50))
+	assert.Equal(t, big.NewInt(5), Percentile(values, 90))
+	assert.Equal(t, big.NewInt(5), Percentile(values, 100))
+	assert.Equal(t, big.NewInt(5), values[0])
+}
@@ -0,0 +1,158 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+// forwarderABI is the ABI of an ERC-2771 trusted forwarder compatible with the OpenZeppelin MinimalForwarder.
+const forwarderABI = `[
+{"inputs":[{"internalType":"address","name":"from","type":"address"}],"name":"getNonce","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},
+{"inputs":[{"components":[{"internalType":"address","name":"from","type":"address"},{"internalType":"address","name":"to","type":"address"},{"internalType":"uint256","name":"value","type":"uint256"},{"internalType":"uint256","name":"gas","type":"uint256"},{"internalType":"uint256","name":"nonce","type":"uint256"},{"internalType":"bytes","name":"data","type":"bytes"}],"internalType":"struct MinimalForwarder.ForwardRequest","name":"req","type":"tuple"},{"internalType":"bytes","name":"signature","type":"bytes"}],"name":"verify","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"view","type":"function"},
+{"inputs":[{"components":[{"internalType":"address","name":"from","type":"address"},{"internalType":"address","name":"to","type":"address"},{"internalType":"uint256","name":"value","type":"uint256"},{"internalType":"uint256","name":"gas","type":"uint256"},{"internalType":"uint256","name":"nonce","type":"uint256"},{"internalType":"bytes","name":"data","type":"bytes"}],"internalType":"struct MinimalForwarder.ForwardRequest","name":"req","type":"tuple"},{"internalType":"bytes","name":"signature","type":"bytes"}],"name":"execute","outputs":[{"internalType":"bool","name":"","type":"bool"},{"internalType":"bytes","name":"","type":"bytes"}],"stateMutability":"payable","type":"function"}
+]`
+
+// forwardRequestTuple is the abi representation of crypto.ForwardRequest.
+type forwardRequestTuple struct {
+	From  common.Address
+	To    common.Address
+	Value *big.Int
+	Gas   *big.Int
+	Nonce *big.Int
+	Data  []byte
+}
+
+func toForwardRequestTuple(fr crypto.ForwardRequest) forwardRequestTuple {
+	return forwardRequestTuple{
+		From:  fr.From,
+		To:    fr.To,
+		Value: bigOrZero(fr.Value),
+		Gas:   new(big.Int).SetUint64(fr.Gas),
+		Nonce: bigOrZero(fr.Nonce),
+		Data:  fr.Data,
+	}
+}
+
+func bigOrZero(i *big.Int) *big.Int {
+	if i == nil {
+		return new(big.Int)
+	}
+	return i
+}
+
+// MetaTxRequest represents a meta-transaction signed by the request sender and relayed through a trusted forwarder.
+// The embedded WriteRequest describes the relayer, which pays for the gas.
+type MetaTxRequest struct {
+	WriteRequest
+	Forwarder common.Address
+	Request   crypto.ForwardRequest
+	Signature []byte
+}
+
+func (r MetaTxRequest) toEstimator(ethClient ethClientGetter) (*bindings.ContractEstimator, error) {
+	return bindings.NewContractEstimator(r.Forwarder, forwarderABI, ethClient.Client())
+}
+
+func (r MetaTxRequest) toEstimateOps() *bindings.EstimateOpts {
+	return &bindings.EstimateOpts{
+		From:   r.Identity,
+		Method: "execute",
+		Params: []interface{}{toForwardRequestTuple(r.Request), r.Signature},
+	}
+}
+
+func (bc *Blockchain) forwarder(address common.Address) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(forwarderABI))
+	if err != nil {
+		return nil, err
+	}
+	client := bc.ethClient.Client()
+	return bind.NewBoundContract(address, parsed, client, bc.transactBackend(), client), nil
+}
+
+// GetForwarderNonce returns the next meta-transaction nonce of the sender on the given forwarder.
+func (bc *Blockchain) GetForwarderNonce(forwarder, from common.Address) (*big.Int, error) {
+	contract, err := bc.forwarder(forwarder)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+
+	res := new(*big.Int)
+	err = contract.Call(&bind.CallOpts{Context: ctx}, res, "getNonce", from)
+	return *res, err
+}
+
+// NewForwardRequest wraps the call into a forward request using the current forwarder nonce of the caller.
+// The returned request has to be signed by call.From, see crypto.ForwardRequest.CreateSignature.
+func (bc *Blockchain) NewForwardRequest(forwarder common.Address, call Calldata, gas uint64) (crypto.ForwardRequest, error) {
+	nonce, err := bc.GetForwarderNonce(forwarder, call.From)
+	if err != nil {
+		return crypto.ForwardRequest{}, err
+	}
+	return call.ForwardRequest(gas, nonce), nil
+}
+
+// VerifyMetaTx checks with the forwarder that the meta-transaction signature and nonce are valid.
+func (bc *Blockchain) VerifyMetaTx(req MetaTxRequest) (bool, error) {
+	contract, err := bc.forwarder(req.Forwarder)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+
+	res := new(bool)
+	err = contract.Call(&bind.CallOpts{Context: ctx}, res, "verify", toForwardRequestTuple(req.Request), req.Signature)
+	return *res, err
+}
+
+// ExecuteMetaTx relays the signed meta-transaction through the forwarder.
+// The target contract sees req.Request.From as the sender, given that it trusts the forwarder.
+// If an address policy is attached, the relayed call is checked with CheckCalldata.
+func (bc *Blockchain) ExecuteMetaTx(req MetaTxRequest) (*types.Transaction, error) {
+	if err := CheckCalldata(bc.addressPolicy, req.Request.Data); err != nil {
+		return nil, err
+	}
+
+	contract, err := bc.forwarder(req.Forwarder)
+	if err != nil {
+		return nil, err
+	}
+
+	transactor, cancel, err := bc.getTransactorFromRequest(req.WriteRequest)
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+	transactor.Value = req.Request.Value
+
+	return contract.Transact(transactor, "execute", toForwardRequestTuple(req.Request), req.Signature)
+}
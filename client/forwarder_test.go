@@ -0,0 +1,56 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetaTxRequestPacksForwarderExecute(t *testing.T) {
+	req := MetaTxRequest{
+		Forwarder: common.HexToAddress("0xf"),
+		Request: crypto.ForwardRequest{
+			From:  common.HexToAddress("0x1"),
+			To:    common.HexToAddress("0x2"),
+			Gas:   100000,
+			Nonce: big.NewInt(7),
+			Data:  []byte{1, 2},
+		},
+		Signature: []byte{3},
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(forwarderABI))
+	assert.NoError(t, err)
+
+	ops := req.toEstimateOps()
+	data, err := parsed.Pack(ops.Method, ops.Params...)
+	assert.NoError(t, err)
+	// selector of the MinimalForwarder execute((address,address,uint256,uint256,uint256,bytes),bytes)
+	assert.Equal(t, "0x47153f82", hexutil.Encode(data[:4]))
+
+	values, err := parsed.Methods["execute"].Inputs.UnpackValues(data[4:])
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{3}, values[1])
+}
@@ -0,0 +1,161 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/bindings"
+)
+
+// AddressPolicy decides whether an address may receive funds.
+type AddressPolicy interface {
+	CheckAddress(address common.Address) error
+}
+
+// AttachAddressPolicy makes the blockchain refuse settlements, registrations, withdrawals, exits and funds destination changes
+// targeting addresses rejected by the policy, including the ones relayed as meta-transactions.
+// Not thread safe, call before sending any transactions.
+func (bc *Blockchain) AttachAddressPolicy(p AddressPolicy) {
+	bc.addressPolicy = p
+}
+
+func (bc *Blockchain) checkAddressPolicy(address common.Address) error {
+	if bc.addressPolicy == nil {
+		return nil
+	}
+	return bc.addressPolicy.CheckAddress(address)
+}
+
+// ErrUncheckableCalldata is returned when calldata is refused because the address receiving the funds can not be found in it.
+var ErrUncheckableCalldata = errors.New("calldata is not a known payments contract call")
+
+// fundsReceivers maps the payments contract methods that move funds, or change where they go,
+// to the name of the argument holding the receiving address.
+var fundsReceivers = map[string]string{
+	"settleWithBeneficiary":       "_newBeneficiary",
+	"setBeneficiary":              "_newBeneficiary",
+	"registerIdentity":            "_beneficiary",
+	"setFundsDestination":         "_newDestination",
+	"setFundsDestinationByCheque": "_newDestination",
+	"requestExit":                 "_beneficiary",
+	"fastExit":                    "_beneficiary",
+	"withdraw":                    "_beneficiary",
+	"getStakeBack":                "_beneficiary",
+	"transferCollectedFeeTo":      "_beneficiary",
+}
+
+// parsedPolicyABIs are the parsed ABIs of the contracts CheckCalldata understands.
+var (
+	parsedPolicyABIsOnce sync.Once
+	parsedPolicyABIs     []abi.ABI
+	parsedPolicyABIsErr  error
+)
+
+// CheckCalldata checks the address receiving the funds of a registry, hermes or channel call against the policy.
+// Calls of other contracts are refused with ErrUncheckableCalldata, as the policy can not be applied to them.
+func CheckCalldata(policy AddressPolicy, data []byte) error {
+	if policy == nil {
+		return nil
+	}
+	if len(data) < 4 {
+		return ErrUncheckableCalldata
+	}
+
+	parsedPolicyABIsOnce.Do(func() {
+		for _, def := range []string{bindings.RegistryABI, bindings.HermesImplementationABI, bindings.ChannelImplementationABI} {
+			parsed, err := abi.JSON(strings.NewReader(def))
+			if err != nil {
+				parsedPolicyABIsErr = err
+				return
+			}
+			parsedPolicyABIs = append(parsedPolicyABIs, parsed)
+		}
+	})
+	if parsedPolicyABIsErr != nil {
+		return parsedPolicyABIsErr
+	}
+
+	for _, parsed := range parsedPolicyABIs {
+		method, err := parsed.MethodById(data[:4])
+		if err != nil {
+			continue
+		}
+
+		receiver, ok := fundsReceivers[method.RawName]
+		if !ok {
+			return nil
+		}
+
+		values, err := method.Inputs.UnpackValues(data[4:])
+		if err != nil {
+			return fmt.Errorf("could not unpack %v: %w", method.RawName, err)
+		}
+		for i, input := range method.Inputs {
+			if input.Name == receiver {
+				return policy.CheckAddress(values[i].(common.Address))
+			}
+		}
+	}
+
+	return ErrUncheckableCalldata
+}
+
+// SetHermesFundsDestinationRequest represents all the parameters required to change the hermes funds destination.
+type SetHermesFundsDestinationRequest struct {
+	WriteRequest
+	HermesID    common.Address
+	Destination common.Address
+}
+
+func (r SetHermesFundsDestinationRequest) toEstimator(ethClient ethClientGetter) (*bindings.ContractEstimator, error) {
+	return bindings.NewContractEstimator(r.HermesID, bindings.HermesImplementationABI, ethClient.Client())
+}
+
+func (r SetHermesFundsDestinationRequest) toEstimateOps() *bindings.EstimateOpts {
+	return &bindings.EstimateOpts{
+		From:   r.Identity,
+		Method: "setFundsDestination",
+		Params: []interface{}{r.Destination},
+	}
+}
+
+// SetHermesFundsDestination sets the address that receives the funds claimed from hermes.
+func (bc *Blockchain) SetHermesFundsDestination(req SetHermesFundsDestinationRequest) (*types.Transaction, error) {
+	if err := bc.checkAddressPolicy(req.Destination); err != nil {
+		return nil, err
+	}
+
+	t, err := bindings.NewHermesImplementationTransactor(req.HermesID, bc.transactBackend())
+	if err != nil {
+		return nil, err
+	}
+
+	transactor, cancel, err := bc.getTransactorFromRequest(req.WriteRequest)
+	defer cancel()
+	if err != nil {
+		return nil, fmt.Errorf("could not get transactor: %w", err)
+	}
+
+	return t.SetFundsDestination(transactor, req.Destination)
+}
@@ -0,0 +1,168 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/bindings"
+)
+
+// PauseChannelOpeningRequest represents all the parameters required to pause channel opening in hermes.
+type PauseChannelOpeningRequest struct {
+	WriteRequest
+	HermesID common.Address
+}
+
+func (r PauseChannelOpeningRequest) toEstimator(ethClient ethClientGetter) (*bindings.ContractEstimator, error) {
+	return bindings.NewContractEstimator(r.HermesID, bindings.HermesImplementationABI, ethClient.Client())
+}
+
+func (r PauseChannelOpeningRequest) toEstimateOps() *bindings.EstimateOpts {
+	return &bindings.EstimateOpts{
+		From:   r.Identity,
+		Method: "pauseChannelOpening",
+	}
+}
+
+// PauseChannelOpening stops hermes from accepting new channels. Only the hermes operator can call it.
+func (bc *Blockchain) PauseChannelOpening(req PauseChannelOpeningRequest) (*types.Transaction, error) {
+	t, err := bindings.NewHermesImplementationTransactor(req.HermesID, bc.transactBackend())
+	if err != nil {
+		return nil, err
+	}
+
+	transactor, cancel, err := bc.getTransactorFromRequest(req.WriteRequest)
+	defer cancel()
+	if err != nil {
+		return nil, fmt.Errorf("could not get transactor: %w", err)
+	}
+
+	return t.PauseChannelOpening(transactor)
+}
+
+// ActivateChannelOpeningRequest represents all the parameters required to resume channel opening in hermes.
+type ActivateChannelOpeningRequest struct {
+	WriteRequest
+	HermesID common.Address
+}
+
+func (r ActivateChannelOpeningRequest) toEstimator(ethClient ethClientGetter) (*bindings.ContractEstimator, error) {
+	return bindings.NewContractEstimator(r.HermesID, bindings.HermesImplementationABI, ethClient.Client())
+}
+
+func (r ActivateChannelOpeningRequest) toEstimateOps() *bindings.EstimateOpts {
+	return &bindings.EstimateOpts{
+		From:   r.Identity,
+		Method: "activateChannelOpening",
+	}
+}
+
+// ActivateChannelOpening resumes channel opening in hermes. Only the hermes operator can call it.
+func (bc *Blockchain) ActivateChannelOpening(req ActivateChannelOpeningRequest) (*types.Transaction, error) {
+	t, err := bindings.NewHermesImplementationTransactor(req.HermesID, bc.transactBackend())
+	if err != nil {
+		return nil, err
+	}
+
+	transactor, cancel, err := bc.getTransactorFromRequest(req.WriteRequest)
+	defer cancel()
+	if err != nil {
+		return nil, fmt.Errorf("could not get transactor: %w", err)
+	}
+
+	return t.ActivateChannelOpening(transactor)
+}
+
+// HermesWithdrawRequest represents all the parameters required to withdraw the available hermes balance.
+type HermesWithdrawRequest struct {
+	WriteRequest
+	HermesID    common.Address
+	Beneficiary common.Address
+	Amount      *big.Int
+}
+
+func (r HermesWithdrawRequest) toEstimator(ethClient ethClientGetter) (*bindings.ContractEstimator, error) {
+	return bindings.NewContractEstimator(r.HermesID, bindings.HermesImplementationABI, ethClient.Client())
+}
+
+func (r HermesWithdrawRequest) toEstimateOps() *bindings.EstimateOpts {
+	return &bindings.EstimateOpts{
+		From:   r.Identity,
+		Method: "withdraw",
+		Params: []interface{}{r.Beneficiary, r.Amount},
+	}
+}
+
+// WithdrawHermesBalance withdraws the given amount of the available hermes balance to the beneficiary.
+// Only the hermes operator can call it.
+func (bc *Blockchain) WithdrawHermesBalance(req HermesWithdrawRequest) (*types.Transaction, error) {
+	if err := bc.checkAddressPolicy(req.Beneficiary); err != nil {
+		return nil, err
+	}
+
+	t, err := bindings.NewHermesImplementationTransactor(req.HermesID, bc.transactBackend())
+	if err != nil {
+		return nil, err
+	}
+
+	transactor, cancel, err := bc.getTransactorFromRequest(req.WriteRequest)
+	defer cancel()
+	if err != nil {
+		return nil, fmt.Errorf("could not get transactor: %w", err)
+	}
+
+	return t.Withdraw(transactor, req.Beneficiary, req.Amount)
+}
+
+// SetHermesMinStakeRequest represents all the parameters required to set the minimal channel stake of hermes.
+type SetHermesMinStakeRequest struct {
+	WriteRequest
+	HermesID common.Address
+	MinStake *big.Int
+}
+
+func (r SetHermesMinStakeRequest) toEstimator(ethClient ethClientGetter) (*bindings.ContractEstimator, error) {
+	return bindings.NewContractEstimator(r.HermesID, bindings.HermesImplementationABI, ethClient.Client())
+}
+
+func (r SetHermesMinStakeRequest) toEstimateOps() *bindings.EstimateOpts {
+	return &bindings.EstimateOpts{
+		From:   r.Identity,
+		Method: "setMinStake",
+		Params: []interface{}{r.MinStake},
+	}
+}
+
+// SetHermesMinStake sets the minimal channel stake of hermes. Only the hermes operator can call it.
+func (bc *Blockchain) SetHermesMinStake(req SetHermesMinStakeRequest) (*types.Transaction, error) {
+	t, err := bindings.NewHermesImplementationTransactor(req.HermesID, bc.transactBackend())
+	if err != nil {
+		return nil, err
+	}
+
+	transactor, cancel, err := bc.getTransactorFromRequest(req.WriteRequest)
+	defer cancel()
+	if err != nil {
+		return nil, fmt.Errorf("could not get transactor: %w", err)
+	}
+
+	return t.SetMinStake(transactor, req.MinStake)
+}
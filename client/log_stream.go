@@ -0,0 +1,318 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultBackfillBatchSize is the initial number of blocks queried at once while backfilling a log stream.
+// The window adapts to the amount of returned logs and provider errors afterwards, see BlockWindow.
+const DefaultBackfillBatchSize = 1000
+
+// LogCursor points at the last log delivered by a log stream.
+type LogCursor struct {
+	BlockNumber uint64
+	Index       uint
+}
+
+// Before returns true if the cursor is positioned before the given log.
+func (lc LogCursor) Before(l types.Log) bool {
+	return lc.BlockNumber < l.BlockNumber || (lc.BlockNumber == l.BlockNumber && lc.Index < l.Index)
+}
+
+// LogStream delivers logs matching a filter query.
+// Historical logs are backfilled first, then new logs are delivered as they are mined.
+// On subscription failures the stream reconnects and backfills the gap from the cursor.
+type LogStream struct {
+	logs   chan types.Log
+	window *BlockWindow
+
+	name    string
+	offsets OffsetStore
+
+	lock    sync.Mutex
+	cursor  LogCursor
+	started bool
+}
+
+// Logs returns the channel the logs are delivered on. It is closed once the stream context is done.
+func (ls *LogStream) Logs() <-chan types.Log {
+	return ls.logs
+}
+
+// Cursor returns the position of the last delivered log.
+// It can be passed to StreamLogsFrom to resume the stream later.
+func (ls *LogStream) Cursor() (LogCursor, bool) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	return ls.cursor, ls.started
+}
+
+// ErrUnnamedLogStream is returned when committing the offset of a stream not created by StreamLogsNamed.
+var ErrUnnamedLogStream = errors.New("log stream has no name to commit the offset under")
+
+// OffsetStore persists the cursors of named log streams, so they survive process restarts.
+type OffsetStore interface {
+	// GetLogStreamOffset returns nil if no offset is stored under the name.
+	GetLogStreamOffset(name string) (*LogCursor, error)
+	SaveLogStreamOffset(name string, cursor LogCursor) error
+}
+
+// Commit persists the position of a handled log, a named stream created again resumes after it.
+// Logs delivered after the last committed one are delivered again after a restart.
+func (ls *LogStream) Commit(l types.Log) error {
+	if ls.offsets == nil {
+		return ErrUnnamedLogStream
+	}
+
+	cursor := LogCursor{BlockNumber: l.BlockNumber, Index: l.Index}
+	if l.Removed {
+		// resume before the block of the removed log, so the logs of the replacing block are delivered
+		if l.BlockNumber == 0 {
+			return nil
+		}
+		cursor = LogCursor{BlockNumber: l.BlockNumber - 1, Index: ^uint(0)}
+	}
+	return ls.offsets.SaveLogStreamOffset(ls.name, cursor)
+}
+
+func (ls *LogStream) isNew(l types.Log) bool {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	return !ls.started || ls.cursor.Before(l)
+}
+
+// rewind moves the cursor before the block of the removed log, so the logs of the replacing block are delivered.
+func (ls *LogStream) rewind(l types.Log) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	if !ls.started || ls.cursor.Before(l) {
+		return
+	}
+	if l.BlockNumber == 0 {
+		ls.cursor, ls.started = LogCursor{}, false
+		return
+	}
+	ls.cursor = LogCursor{BlockNumber: l.BlockNumber - 1, Index: ^uint(0)}
+}
+
+func (ls *LogStream) advance(l types.Log) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	ls.cursor = LogCursor{BlockNumber: l.BlockNumber, Index: l.Index}
+	ls.started = true
+}
+
+type logStreamClient interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+	SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error)
+}
+
+// StreamLogs streams the logs matching the given query starting at the query FromBlock, or at the chain head if it is nil.
+// The query ToBlock is ignored. The stream runs until the context is done.
+func (bc *Blockchain) StreamLogs(ctx context.Context, q ethereum.FilterQuery) (*LogStream, error) {
+	return bc.streamLogs(ctx, q, nil)
+}
+
+// StreamLogsFrom resumes a log stream after the given cursor.
+func (bc *Blockchain) StreamLogsFrom(ctx context.Context, q ethereum.FilterQuery, cursor LogCursor) (*LogStream, error) {
+	q.FromBlock = new(big.Int).SetUint64(cursor.BlockNumber)
+	return bc.streamLogs(ctx, q, &cursor)
+}
+
+// StreamLogsNamed streams the logs matching the given query, resuming after the offset committed under the name.
+// The missed blocks are backfilled first. Without a committed offset the stream starts like StreamLogs.
+func (bc *Blockchain) StreamLogsNamed(ctx context.Context, name string, q ethereum.FilterQuery, offsets OffsetStore) (*LogStream, error) {
+	cursor, err := offsets.GetLogStreamOffset(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not get offset of log stream %v: %w", name, err)
+	}
+
+	ls := newLogStream(cursor)
+	ls.name = name
+	ls.offsets = offsets
+	if cursor != nil {
+		q.FromBlock = new(big.Int).SetUint64(cursor.BlockNumber)
+	}
+	return bc.startLogStream(ctx, q, ls)
+}
+
+func newLogStream(cursor *LogCursor) *LogStream {
+	ls := &LogStream{logs: make(chan types.Log), window: NewDefaultBlockWindow()}
+	if cursor != nil {
+		ls.cursor = *cursor
+		ls.started = true
+	}
+	return ls
+}
+
+func (bc *Blockchain) streamLogs(ctx context.Context, q ethereum.FilterQuery, cursor *LogCursor) (*LogStream, error) {
+	return bc.startLogStream(ctx, q, newLogStream(cursor))
+}
+
+func (bc *Blockchain) startLogStream(ctx context.Context, q ethereum.FilterQuery, ls *LogStream) (*LogStream, error) {
+	next, err := bc.streamStart(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer close(ls.logs)
+		bc.runLogStream(ctx, ls, q, next, bc.logStreamClient)
+	}()
+	return ls, nil
+}
+
+func (bc *Blockchain) streamStart(ctx context.Context, q ethereum.FilterQuery) (uint64, error) {
+	if q.FromBlock != nil {
+		return q.FromBlock.Uint64(), nil
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, bc.bcTimeout)
+	defer cancel()
+	head, err := bc.ethClient.Client().HeaderByNumber(tctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	return head.Number.Uint64() + 1, nil
+}
+
+func (bc *Blockchain) runLogStream(ctx context.Context, ls *LogStream, q ethereum.FilterQuery, next uint64, getClient func() logStreamClient) {
+	for {
+		var err error
+		next, err = bc.streamLogsOnce(ctx, ls, q, next, getClient())
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn().Err(err).Msgf("log stream interrupted, resuming from block %v", next)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(DefaultBackoff):
+		}
+	}
+}
+
+// streamLogsOnce subscribes to new logs, backfills the logs mined since next and then delivers the subscribed logs until failure.
+// It returns the block the stream should be resumed from.
+func (bc *Blockchain) streamLogsOnce(ctx context.Context, ls *LogStream, q ethereum.FilterQuery, next uint64, client logStreamClient) (uint64, error) {
+	sink := make(chan types.Log, 128)
+	subQuery := q
+	subQuery.FromBlock, subQuery.ToBlock = nil, nil
+	sub, err := client.SubscribeFilterLogs(ctx, subQuery, sink)
+	if err != nil {
+		return next, err
+	}
+	defer sub.Unsubscribe()
+
+	next, err = bc.backfillLogs(ctx, ls, q, next, client)
+	if err != nil {
+		return next, err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return next, ctx.Err()
+		case err := <-sub.Err():
+			return next, err
+		case l := <-sink:
+			if !l.Removed && !ls.isNew(l) {
+				continue
+			}
+			if !bc.deliverLog(ctx, ls, l) {
+				return next, ctx.Err()
+			}
+			if l.Removed && l.BlockNumber < next {
+				next = l.BlockNumber
+			} else if !l.Removed && l.BlockNumber > next {
+				next = l.BlockNumber
+			}
+		}
+	}
+}
+
+func (bc *Blockchain) backfillLogs(ctx context.Context, ls *LogStream, q ethereum.FilterQuery, next uint64, client logStreamClient) (uint64, error) {
+	tctx, cancel := context.WithTimeout(ctx, bc.bcTimeout)
+	head, err := client.HeaderByNumber(tctx, nil)
+	cancel()
+	if err != nil {
+		return next, err
+	}
+
+	for from := next; from <= head.Number.Uint64(); {
+		size := ls.window.Size()
+		to := from + size - 1
+		if to > head.Number.Uint64() {
+			to = head.Number.Uint64()
+		}
+
+		batch := q
+		batch.FromBlock = new(big.Int).SetUint64(from)
+		batch.ToBlock = new(big.Int).SetUint64(to)
+
+		tctx, cancel := context.WithTimeout(ctx, bc.bcTimeout)
+		logs, err := client.FilterLogs(tctx, batch)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && ls.window.Failure(to-from+1) {
+				log.Debug().Err(err).Uint64("size", ls.window.Size()).Msg("shrinking log backfill window")
+				continue
+			}
+			return from, err
+		}
+		ls.window.Success(to-from+1, len(logs))
+
+		for _, l := range logs {
+			if !ls.isNew(l) {
+				continue
+			}
+			if !bc.deliverLog(ctx, ls, l) {
+				return from, ctx.Err()
+			}
+		}
+		from = to + 1
+	}
+
+	return head.Number.Uint64(), nil
+}
+
+func (bc *Blockchain) deliverLog(ctx context.Context, ls *LogStream, l types.Log) bool {
+	select {
+	case ls.logs <- l:
+		if l.Removed {
+			ls.rewind(l)
+		} else {
+			ls.advance(l)
+		}
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,145 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSubscription struct {
+	err chan error
+}
+
+func (ms *mockSubscription) Unsubscribe()      {}
+func (ms *mockSubscription) Err() <-chan error { return ms.err }
+
+type mockLogStreamClient struct {
+	head uint64
+	logs []types.Log
+	sub  *mockSubscription
+	sink chan<- types.Log
+}
+
+func (m *mockLogStreamClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: new(big.Int).SetUint64(m.head)}, nil
+}
+
+func (m *mockLogStreamClient) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	var res []types.Log
+	for _, l := range m.logs {
+		if l.BlockNumber >= q.FromBlock.Uint64() && l.BlockNumber <= q.ToBlock.Uint64() {
+			res = append(res, l)
+		}
+	}
+	return res, nil
+}
+
+func (m *mockLogStreamClient) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	m.sub = &mockSubscription{err: make(chan error, 1)}
+	m.sink = ch
+	return m.sub, nil
+}
+
+func TestStreamLogsOnce(t *testing.T) {
+	bc := &Blockchain{bcTimeout: time.Second}
+	ls := &LogStream{logs: make(chan types.Log, 10), window: NewDefaultBlockWindow()}
+	client := &mockLogStreamClient{
+		head: 5,
+		logs: []types.Log{{BlockNumber: 2, Index: 0}, {BlockNumber: 5, Index: 1}},
+	}
+
+	done := make(chan uint64)
+	go func() {
+		next, err := bc.streamLogsOnce(context.Background(), ls, ethereum.FilterQuery{}, 1, client)
+		assert.Error(t, err)
+		done <- next
+	}()
+
+	assert.Equal(t, uint64(2), (<-ls.logs).BlockNumber)
+	assert.Equal(t, uint64(5), (<-ls.logs).BlockNumber)
+
+	// duplicate of a backfilled log is skipped
+	client.sink <- types.Log{BlockNumber: 5, Index: 1}
+	client.sink <- types.Log{BlockNumber: 6, Index: 0}
+	assert.Equal(t, uint64(6), (<-ls.logs).BlockNumber)
+
+	// reorg rewinds the cursor so the replacing log is delivered
+	client.sink <- types.Log{BlockNumber: 6, Index: 0, Removed: true}
+	assert.True(t, (<-ls.logs).Removed)
+	client.sink <- types.Log{BlockNumber: 6, Index: 0}
+	assert.Equal(t, uint64(6), (<-ls.logs).BlockNumber)
+
+	client.sub.err <- errors.New("connection lost")
+	assert.Equal(t, uint64(6), <-done)
+
+	cursor, ok := ls.Cursor()
+	assert.True(t, ok)
+	assert.Equal(t, LogCursor{BlockNumber: 6, Index: 0}, cursor)
+}
+
+type memoryOffsets map[string]LogCursor
+
+func (mo memoryOffsets) GetLogStreamOffset(name string) (*LogCursor, error) {
+	if c, ok := mo[name]; ok {
+		return &c, nil
+	}
+	return nil, nil
+}
+
+func (mo memoryOffsets) SaveLogStreamOffset(name string, cursor LogCursor) error {
+	mo[name] = cursor
+	return nil
+}
+
+func TestNamedLogStreamResumesAfterCommittedOffset(t *testing.T) {
+	offsets := memoryOffsets{"settlements": {BlockNumber: 3, Index: 1}}
+	cursor, err := offsets.GetLogStreamOffset("settlements")
+	assert.NoError(t, err)
+
+	bc := &Blockchain{bcTimeout: time.Second}
+	ls := newLogStream(cursor)
+	ls.name, ls.offsets = "settlements", offsets
+	client := &mockLogStreamClient{
+		head: 6,
+		logs: []types.Log{{BlockNumber: 3, Index: 0}, {BlockNumber: 3, Index: 1}, {BlockNumber: 3, Index: 2}, {BlockNumber: 5, Index: 0}},
+	}
+
+	go bc.streamLogsOnce(context.Background(), ls, ethereum.FilterQuery{}, cursor.BlockNumber, client)
+
+	l := <-ls.logs
+	assert.Equal(t, types.Log{BlockNumber: 3, Index: 2}, l)
+	assert.NoError(t, ls.Commit(l))
+	assert.Equal(t, LogCursor{BlockNumber: 3, Index: 2}, offsets["settlements"])
+
+	l = <-ls.logs
+	assert.Equal(t, types.Log{BlockNumber: 5, Index: 0}, l)
+	assert.NoError(t, ls.Commit(l))
+	assert.Equal(t, LogCursor{BlockNumber: 5, Index: 0}, offsets["settlements"])
+
+	assert.NoError(t, ls.Commit(types.Log{BlockNumber: 5, Index: 0, Removed: true}))
+	assert.Equal(t, LogCursor{BlockNumber: 4, Index: ^uint(0)}, offsets["settlements"])
+
+	assert.Equal(t, ErrUnnamedLogStream, newLogStream(nil).Commit(l))
+}
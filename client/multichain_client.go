@@ -17,6 +17,9 @@
 package client
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math/big"
 	"time"
 
@@ -24,7 +27,6 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/mysteriumnetwork/payments/bindings"
-	"github.com/pkg/errors"
 )
 
 type MultichainBlockchainClient struct {
@@ -91,13 +93,14 @@ func (mbc *MultichainBlockchainClient) IsRegistered(chainID int64, registryAddre
 	return bc.IsRegistered(registryAddress, addressToCheck)
 }
 
-func (mbc *MultichainBlockchainClient) SubscribeToPromiseSettledEvent(chainID int64, providerID, hermesID common.Address) (sink chan *bindings.HermesImplementationPromiseSettled, cancel func(), err error) {
+func (mbc *MultichainBlockchainClient) SubscribeToPromiseSettledEvent(chainID int64, providerID, hermesID common.Address) (sink chan *bindings.HermesImplementationPromiseSettled, sub *Subscription, err error) {
 	bc, err := mbc.getClientByChain(chainID)
 	if err != nil {
-		return nil, func() {}, err
+		return nil, nil, err
 	}
 
-	return bc.SubscribeToPromiseSettledEvent(providerID, hermesID)
+	s, sub, err := bc.SubscribeToPromiseSettledEvent(providerID, hermesID)
+	return s, withChainID(sub, chainID), err
 }
 
 func (mbc *MultichainBlockchainClient) GetMystBalance(chainID int64, mystSCAddress, address common.Address) (*big.Int, error) {
@@ -109,22 +112,24 @@ func (mbc *MultichainBlockchainClient) GetMystBalance(chainID int64, mystSCAddre
 	return bc.GetMystBalance(mystSCAddress, address)
 }
 
-func (mbc *MultichainBlockchainClient) SubscribeToConsumerBalanceEvent(chainID int64, channel, mystSCAddress common.Address, timeout time.Duration) (chan *bindings.MystTokenTransfer, func(), error) {
+func (mbc *MultichainBlockchainClient) SubscribeToConsumerBalanceEvent(chainID int64, channel, mystSCAddress common.Address, timeout time.Duration) (chan *bindings.MystTokenTransfer, *Subscription, error) {
 	bc, err := mbc.getClientByChain(chainID)
 	if err != nil {
-		return nil, func() {}, err
+		return nil, nil, err
 	}
 
-	return bc.SubscribeToConsumerBalanceEvent(channel, mystSCAddress, timeout)
+	s, sub, err := bc.SubscribeToConsumerBalanceEvent(channel, mystSCAddress, timeout)
+	return s, withChainID(sub, chainID), err
 }
 
-func (mbc *MultichainBlockchainClient) SubscribeToIdentityRegistrationEvents(chainID int64, registryAddress common.Address) (sink chan *bindings.RegistryRegisteredIdentity, cancel func(), err error) {
+func (mbc *MultichainBlockchainClient) SubscribeToIdentityRegistrationEvents(chainID int64, registryAddress common.Address) (sink chan *bindings.RegistryRegisteredIdentity, sub *Subscription, err error) {
 	bc, err := mbc.getClientByChain(chainID)
 	if err != nil {
-		return nil, func() {}, err
+		return nil, nil, err
 	}
 
-	return bc.SubscribeToIdentityRegistrationEvents(registryAddress)
+	s, sub, err := bc.SubscribeToIdentityRegistrationEvents(registryAddress)
+	return s, withChainID(sub, chainID), err
 }
 
 func (mbc *MultichainBlockchainClient) SuggestGasPrice(chainID int64) (*big.Int, error) {
@@ -135,30 +140,33 @@ func (mbc *MultichainBlockchainClient) SuggestGasPrice(chainID int64) (*big.Int,
 	return bc.SuggestGasPrice()
 }
 
-func (mbc *MultichainBlockchainClient) SubscribeToConsumerChannelBalanceUpdate(chainID int64, mystSCAddress common.Address, channelAddresses []common.Address) (sink chan *bindings.MystTokenTransfer, cancel func(), err error) {
+func (mbc *MultichainBlockchainClient) SubscribeToConsumerChannelBalanceUpdate(chainID int64, mystSCAddress common.Address, channelAddresses []common.Address) (sink chan *bindings.MystTokenTransfer, sub *Subscription, err error) {
 	bc, err := mbc.getClientByChain(chainID)
 	if err != nil {
-		return nil, func() {}, err
+		return nil, nil, err
 	}
 
-	return bc.SubscribeToConsumerChannelBalanceUpdate(mystSCAddress, channelAddresses)
+	s, sub, err := bc.SubscribeToConsumerChannelBalanceUpdate(mystSCAddress, channelAddresses)
+	return s, withChainID(sub, chainID), err
 }
-func (mbc *MultichainBlockchainClient) SubscribeToPromiseSettledEventByChannelID(chainID int64, hermesID common.Address, providerAddresses [][32]byte) (sink chan *bindings.HermesImplementationPromiseSettled, cancel func(), err error) {
+func (mbc *MultichainBlockchainClient) SubscribeToPromiseSettledEventByChannelID(chainID int64, hermesID common.Address, providerAddresses [][32]byte) (sink chan *bindings.HermesImplementationPromiseSettled, sub *Subscription, err error) {
 	bc, err := mbc.getClientByChain(chainID)
 	if err != nil {
-		return nil, func() {}, err
+		return nil, nil, err
 	}
 
-	return bc.SubscribeToPromiseSettledEventByChannelID(hermesID, providerAddresses)
+	s, sub, err := bc.SubscribeToPromiseSettledEventByChannelID(hermesID, providerAddresses)
+	return s, withChainID(sub, chainID), err
 }
 
-func (mbc *MultichainBlockchainClient) SubscribeToMystTokenTransfers(chainID int64, mystSCAddress common.Address) (chan *bindings.MystTokenTransfer, func(), error) {
+func (mbc *MultichainBlockchainClient) SubscribeToMystTokenTransfers(chainID int64, mystSCAddress common.Address) (chan *bindings.MystTokenTransfer, *Subscription, error) {
 	bc, err := mbc.getClientByChain(chainID)
 	if err != nil {
-		return nil, func() {}, err
+		return nil, nil, err
 	}
 
-	return bc.SubscribeToMystTokenTransfers(mystSCAddress)
+	s, sub, err := bc.SubscribeToMystTokenTransfers(mystSCAddress)
+	return s, withChainID(sub, chainID), err
 }
 
 func (mbc *MultichainBlockchainClient) RegisterIdentity(chainID int64, rr RegistrationRequest) (*types.Transaction, error) {
@@ -223,6 +231,36 @@ func (mbc *MultichainBlockchainClient) GetBeneficiary(chainID int64, registryAdd
 	return bc.GetBeneficiary(registryAddress, identity)
 }
 
+// GetProviderChannelAtBlock returns the provider channel as of the given block.
+func (mbc *MultichainBlockchainClient) GetProviderChannelAtBlock(chainID int64, hermesAddress, addressToCheck common.Address, block *big.Int) (ProviderChannel, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return ProviderChannel{}, err
+	}
+
+	return bc.GetProviderChannelAtBlock(hermesAddress, addressToCheck, block)
+}
+
+// GetMystBalanceAtBlock returns the myst balance as of the given block.
+func (mbc *MultichainBlockchainClient) GetMystBalanceAtBlock(chainID int64, mystSCAddress, address common.Address, block *big.Int) (*big.Int, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.GetMystBalanceAtBlock(mystSCAddress, address, block)
+}
+
+// GetBeneficiaryAtBlock returns the beneficiary as of the given block.
+func (mbc *MultichainBlockchainClient) GetBeneficiaryAtBlock(chainID int64, registryAddress, identity common.Address, block *big.Int) (common.Address, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return bc.GetBeneficiaryAtBlock(registryAddress, identity, block)
+}
+
 func (mbc *MultichainBlockchainClient) SettleWithBeneficiary(req SettleWithBeneficiaryRequest) (*types.Transaction, error) {
 	bc, err := mbc.getClientByChain(req.Promise.ChainID)
 	if err != nil {
@@ -358,6 +396,316 @@ func (mbc *MultichainBlockchainClient) GetHermesURL(chainID int64, registryID, h
 	return bc.GetHermesURL(registryID, hermesID)
 }
 
+// PauseChannelOpening pauses channel opening in hermes.
+func (mbc *MultichainBlockchainClient) PauseChannelOpening(chainID int64, req PauseChannelOpeningRequest) (*types.Transaction, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.PauseChannelOpening(req)
+}
+
+// ActivateChannelOpening resumes channel opening in hermes.
+func (mbc *MultichainBlockchainClient) ActivateChannelOpening(chainID int64, req ActivateChannelOpeningRequest) (*types.Transaction, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.ActivateChannelOpening(req)
+}
+
+// WithdrawHermesBalance withdraws the available hermes balance.
+func (mbc *MultichainBlockchainClient) WithdrawHermesBalance(chainID int64, req HermesWithdrawRequest) (*types.Transaction, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.WithdrawHermesBalance(req)
+}
+
+// GetLatestChannelImplementation returns the channel implementation new channels are deployed with.
+func (mbc *MultichainBlockchainClient) GetLatestChannelImplementation(chainID int64, registryAddress common.Address) (common.Address, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return bc.GetLatestChannelImplementation(registryAddress)
+}
+
+// GetChannelExit returns the pending exit request of the consumer channel.
+func (mbc *MultichainBlockchainClient) GetChannelExit(chainID int64, channelAddress common.Address) (ChannelExit, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return ChannelExit{}, err
+	}
+
+	return bc.GetChannelExit(channelAddress)
+}
+
+// RequestChannelExit starts the exit of all the channel funds to the beneficiary.
+func (mbc *MultichainBlockchainClient) RequestChannelExit(chainID int64, req RequestChannelExitRequest) (*types.Transaction, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.RequestChannelExit(req)
+}
+
+// FinalizeChannelExit transfers the channel funds to the beneficiary of the requested exit.
+func (mbc *MultichainBlockchainClient) FinalizeChannelExit(chainID int64, req FinalizeChannelExitRequest) (*types.Transaction, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.FinalizeChannelExit(req)
+}
+
+// SetHermesMinStake sets the minimal channel stake of hermes.
+func (mbc *MultichainBlockchainClient) SetHermesMinStake(chainID int64, req SetHermesMinStakeRequest) (*types.Transaction, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.SetHermesMinStake(req)
+}
+
+// SetHermesFundsDestination sets the address that receives the funds claimed from hermes.
+func (mbc *MultichainBlockchainClient) SetHermesFundsDestination(chainID int64, req SetHermesFundsDestinationRequest) (*types.Transaction, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.SetHermesFundsDestination(req)
+}
+
+// SubscribeToProviderStakeEvents subscribes to provider channel stake updates, both increases and decreases.
+func (mbc *MultichainBlockchainClient) SubscribeToProviderStakeEvents(chainID int64, hermesID common.Address, channelIDs [][32]byte) (chan *bindings.HermesImplementationNewStake, *Subscription, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, sub, err := bc.SubscribeToProviderStakeEvents(hermesID, channelIDs)
+	return s, withChainID(sub, chainID), err
+}
+
+// FilterProviderStakeEvents returns the provider channel stake updates, both increases and decreases in the given block range.
+func (mbc *MultichainBlockchainClient) FilterProviderStakeEvents(chainID int64, hermesID common.Address, channelIDs [][32]byte, start uint64, end *uint64) ([]*bindings.HermesImplementationNewStake, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.FilterProviderStakeEvents(hermesID, channelIDs, start, end)
+}
+
+// SubscribeToHermesStakeIncreasedEvents subscribes to hermes stake increase events.
+func (mbc *MultichainBlockchainClient) SubscribeToHermesStakeIncreasedEvents(chainID int64, hermesID common.Address) (chan *bindings.HermesImplementationHermesStakeIncreased, *Subscription, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, sub, err := bc.SubscribeToHermesStakeIncreasedEvents(hermesID)
+	return s, withChainID(sub, chainID), err
+}
+
+// FilterHermesStakeIncreasedEvents returns the hermes stake increase events in the given block range.
+func (mbc *MultichainBlockchainClient) FilterHermesStakeIncreasedEvents(chainID int64, hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationHermesStakeIncreased, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.FilterHermesStakeIncreasedEvents(hermesID, start, end)
+}
+
+// SubscribeToHermesFeeUpdatedEvents subscribes to hermes fee update events.
+func (mbc *MultichainBlockchainClient) SubscribeToHermesFeeUpdatedEvents(chainID int64, hermesID common.Address) (chan *bindings.HermesImplementationHermesFeeUpdated, *Subscription, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, sub, err := bc.SubscribeToHermesFeeUpdatedEvents(hermesID)
+	return s, withChainID(sub, chainID), err
+}
+
+// FilterHermesFeeUpdatedEvents returns the hermes fee update events in the given block range.
+func (mbc *MultichainBlockchainClient) FilterHermesFeeUpdatedEvents(chainID int64, hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationHermesFeeUpdated, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.FilterHermesFeeUpdatedEvents(hermesID, start, end)
+}
+
+// SubscribeToHermesFundsWithdrawnEvents subscribes to hermes funds withdrawal events.
+func (mbc *MultichainBlockchainClient) SubscribeToHermesFundsWithdrawnEvents(chainID int64, hermesID common.Address) (chan *bindings.HermesImplementationFundsWithdrawned, *Subscription, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, sub, err := bc.SubscribeToHermesFundsWithdrawnEvents(hermesID)
+	return s, withChainID(sub, chainID), err
+}
+
+// FilterHermesFundsWithdrawnEvents returns the hermes funds withdrawal events in the given block range.
+func (mbc *MultichainBlockchainClient) FilterHermesFundsWithdrawnEvents(chainID int64, hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationFundsWithdrawned, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.FilterHermesFundsWithdrawnEvents(hermesID, start, end)
+}
+
+// SubscribeToBeneficiaryChangedEvents subscribes to identity beneficiary change events.
+func (mbc *MultichainBlockchainClient) SubscribeToBeneficiaryChangedEvents(chainID int64, registryAddress common.Address, identities []common.Address) (chan *bindings.RegistryBeneficiaryChanged, *Subscription, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, sub, err := bc.SubscribeToBeneficiaryChangedEvents(registryAddress, identities)
+	return s, withChainID(sub, chainID), err
+}
+
+// FilterBeneficiaryChangedEvents returns the identity beneficiary change events in the given block range.
+func (mbc *MultichainBlockchainClient) FilterBeneficiaryChangedEvents(chainID int64, registryAddress common.Address, identities []common.Address, start uint64, end *uint64) ([]*bindings.RegistryBeneficiaryChanged, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.FilterBeneficiaryChangedEvents(registryAddress, identities, start, end)
+}
+
+// SubscribeToChannelWithdrawEvents subscribes to consumer channel withdrawal events.
+func (mbc *MultichainBlockchainClient) SubscribeToChannelWithdrawEvents(chainID int64, channelAddress common.Address) (chan *bindings.ChannelImplementationWithdraw, *Subscription, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, sub, err := bc.SubscribeToChannelWithdrawEvents(channelAddress)
+	return s, withChainID(sub, chainID), err
+}
+
+// FilterChannelWithdrawEvents returns the consumer channel withdrawal events in the given block range.
+func (mbc *MultichainBlockchainClient) FilterChannelWithdrawEvents(chainID int64, channelAddress common.Address, start uint64, end *uint64) ([]*bindings.ChannelImplementationWithdraw, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.FilterChannelWithdrawEvents(channelAddress, start, end)
+}
+
+func (mbc *MultichainBlockchainClient) FeeHistory(chainID int64, blockCount uint64, newest *big.Int, percentiles []float64) (*FeeHistory, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+	return bc.FeeHistory(blockCount, newest, percentiles)
+}
+
+func (mbc *MultichainBlockchainClient) GetForwarderNonce(chainID int64, forwarder, from common.Address) (*big.Int, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+	return bc.GetForwarderNonce(forwarder, from)
+}
+
+func (mbc *MultichainBlockchainClient) VerifyMetaTx(chainID int64, req MetaTxRequest) (bool, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return false, err
+	}
+	return bc.VerifyMetaTx(req)
+}
+
+func (mbc *MultichainBlockchainClient) ExecuteMetaTx(chainID int64, req MetaTxRequest) (*types.Transaction, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+	return bc.ExecuteMetaTx(req)
+}
+
+// StreamLogs streams the logs matching the given query.
+func (mbc *MultichainBlockchainClient) StreamLogs(ctx context.Context, chainID int64, q ethereum.FilterQuery) (*LogStream, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.StreamLogs(ctx, q)
+}
+
+// StreamLogsFrom resumes a log stream after the given cursor.
+func (mbc *MultichainBlockchainClient) StreamLogsFrom(ctx context.Context, chainID int64, q ethereum.FilterQuery, cursor LogCursor) (*LogStream, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.StreamLogsFrom(ctx, q, cursor)
+}
+
+// StreamLogsNamed streams the logs matching the given query, resuming after the offset committed under the name.
+// The offsets are stored under the name prefixed with the chain id, so the same name can be used on every chain.
+func (mbc *MultichainBlockchainClient) StreamLogsNamed(ctx context.Context, chainID int64, name string, q ethereum.FilterQuery, offsets OffsetStore) (*LogStream, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.StreamLogsNamed(ctx, fmt.Sprintf("%v/%v", chainID, name), q, offsets)
+}
+
+// GetProxyImplementation returns the implementation address of the given proxy.
+func (mbc *MultichainBlockchainClient) GetProxyImplementation(chainID int64, proxy common.Address) (common.Address, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return bc.GetProxyImplementation(proxy)
+}
+
+// Capabilities reports which features are available on the given chain with the given contracts.
+func (mbc *MultichainBlockchainClient) Capabilities(chainID int64, contracts CapabilityContracts) (Capabilities, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.Capabilities(contracts)
+}
+
+// SubscribeToProxyUpgradedEvents subscribes to proxy implementation upgrade events.
+func (mbc *MultichainBlockchainClient) SubscribeToProxyUpgradedEvents(chainID int64, proxy common.Address) (sink chan *ProxyUpgraded, sub *Subscription, err error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, sub, err := bc.SubscribeToProxyUpgradedEvents(proxy)
+	return s, withChainID(sub, chainID), err
+}
+
 // GetStakeThresholds returns the stake tresholds for the given hermes.
 func (mbc *MultichainBlockchainClient) GetStakeThresholds(chainID int64, hermesID common.Address) (min, max *big.Int, err error) {
 	bc, err := mbc.getClientByChain(chainID)
@@ -394,3 +742,22 @@ func (mbc *MultichainBlockchainClient) SendTransaction(chainID int64, tx *types.
 	}
 	return bc.SendTransaction(tx)
 }
+
+// SendTransactionWithFeeGuard sends the transaction checking it against the given operation value.
+func (mbc *MultichainBlockchainClient) SendTransactionWithFeeGuard(chainID int64, tx *types.Transaction, value *big.Int, override bool) error {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return err
+	}
+	return bc.SendTransactionWithFeeGuard(tx, value, override)
+}
+
+// GetTokenDecimals returns the decimals of the ERC-20 token.
+func (mbc *MultichainBlockchainClient) GetTokenDecimals(chainID int64, token common.Address) (uint8, error) {
+	bc, err := mbc.getClientByChain(chainID)
+	if err != nil {
+		return 0, err
+	}
+
+	return bc.GetTokenDecimals(token)
+}
@@ -0,0 +1,171 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// DefaultPollingInterval is the default interval between polls of a polling subscription.
+const DefaultPollingInterval = 15 * time.Second
+
+// PollingOpts configures the polling subscriptions.
+type PollingOpts struct {
+	// Interval is the time between polls, DefaultPollingInterval if zero.
+	Interval time.Duration
+	// BatchSize is the number of blocks queried at once, DefaultBackfillBatchSize if zero.
+	BatchSize uint64
+	// MaxBatchesPerPoll throttles catching up after falling behind, zero means no limit.
+	MaxBatchesPerPoll int
+}
+
+// PollingClient is the part of the eth client a polling subscription needs.
+type PollingClient interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// PollingFilterer implements log subscriptions by polling eth_getLogs, for providers without websocket support.
+// Subscriptions fail with the first failed poll, like a dropped websocket subscription.
+type PollingFilterer struct {
+	PollingClient
+	opts    PollingOpts
+	timeout time.Duration
+}
+
+var _ bind.ContractFilterer = (*PollingFilterer)(nil)
+
+// NewPollingFilterer returns a new polling filterer, timeout limits each request made.
+func NewPollingFilterer(client PollingClient, timeout time.Duration, opts PollingOpts) *PollingFilterer {
+	if opts.Interval == 0 {
+		opts.Interval = DefaultPollingInterval
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = DefaultBackfillBatchSize
+	}
+	return &PollingFilterer{PollingClient: client, opts: opts, timeout: timeout}
+}
+
+// SubscribeFilterLogs polls the logs matching the query, starting at the query FromBlock or at the next block if it is nil.
+// The query ToBlock is ignored.
+func (pf *PollingFilterer) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	next, err := pf.start(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		for {
+			var err error
+			next, err = pf.poll(ctx, q, next, ch, quit)
+			if err != nil {
+				return err
+			}
+
+			select {
+			case <-quit:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(pf.opts.Interval):
+			}
+		}
+	}), nil
+}
+
+func (pf *PollingFilterer) start(ctx context.Context, q ethereum.FilterQuery) (uint64, error) {
+	if q.FromBlock != nil {
+		return q.FromBlock.Uint64(), nil
+	}
+	head, err := pf.head(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return head + 1, nil
+}
+
+func (pf *PollingFilterer) head(ctx context.Context) (uint64, error) {
+	tctx, cancel := context.WithTimeout(ctx, pf.timeout)
+	defer cancel()
+	head, err := pf.HeaderByNumber(tctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	return head.Number.Uint64(), nil
+}
+
+// poll delivers the logs of the blocks from next up to the head, in batches, and returns the next block to poll.
+func (pf *PollingFilterer) poll(ctx context.Context, q ethereum.FilterQuery, next uint64, ch chan<- types.Log, quit <-chan struct{}) (uint64, error) {
+	head, err := pf.head(ctx)
+	if err != nil {
+		return next, err
+	}
+
+	for batches := 0; next <= head; batches++ {
+		if pf.opts.MaxBatchesPerPoll > 0 && batches == pf.opts.MaxBatchesPerPoll {
+			break
+		}
+
+		to := next + pf.opts.BatchSize - 1
+		if to > head {
+			to = head
+		}
+		batch := q
+		batch.FromBlock = new(big.Int).SetUint64(next)
+		batch.ToBlock = new(big.Int).SetUint64(to)
+
+		tctx, cancel := context.WithTimeout(ctx, pf.timeout)
+		logs, err := pf.FilterLogs(tctx, batch)
+		cancel()
+		if err != nil {
+			return next, err
+		}
+
+		for _, l := range logs {
+			select {
+			case ch <- l:
+			case <-quit:
+				return next, nil
+			case <-ctx.Done():
+				return next, ctx.Err()
+			}
+		}
+		next = to + 1
+	}
+	return next, nil
+}
+
+// AttachPollingSubscriptions makes the subscriptions and log streams poll for logs instead of using the websocket subscription.
+// Not thread safe, call before subscribing.
+func (bc *Blockchain) AttachPollingSubscriptions(opts PollingOpts) {
+	bc.polling = &opts
+}
+
+// subscriptionFilterer returns the filterer subscriptions are made through.
+func (bc *Blockchain) subscriptionFilterer() bind.ContractFilterer {
+	if bc.polling == nil {
+		return bc.ethClient.Client()
+	}
+	return NewPollingFilterer(bc.ethClient.Client(), bc.bcTimeout, *bc.polling)
+}
@@ -0,0 +1,123 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockPollingClient struct {
+	lock    sync.Mutex
+	head    uint64
+	logs    []types.Log
+	queries []ethereum.FilterQuery
+	err     error
+}
+
+func (m *mockPollingClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return &types.Header{Number: new(big.Int).SetUint64(m.head)}, nil
+}
+
+func (m *mockPollingClient) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.queries = append(m.queries, q)
+	var res []types.Log
+	for _, l := range m.logs {
+		if l.BlockNumber >= q.FromBlock.Uint64() && l.BlockNumber <= q.ToBlock.Uint64() {
+			res = append(res, l)
+		}
+	}
+	return res, nil
+}
+
+func (m *mockPollingClient) set(head uint64, logs ...types.Log) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.head = head
+	m.logs = append(m.logs, logs...)
+}
+
+func receiveLog(t *testing.T, ch <-chan types.Log) types.Log {
+	select {
+	case l := <-ch:
+		return l
+	case <-time.After(time.Second):
+		t.Fatal("no log received")
+		return types.Log{}
+	}
+}
+
+func TestPollingFilterer(t *testing.T) {
+	client := &mockPollingClient{head: 5, logs: []types.Log{{BlockNumber: 1}, {BlockNumber: 4}, {BlockNumber: 5}}}
+	pf := NewPollingFilterer(client, time.Second, PollingOpts{Interval: time.Millisecond, BatchSize: 2, MaxBatchesPerPoll: 1})
+
+	ch := make(chan types.Log)
+	sub, err := pf.SubscribeFilterLogs(context.Background(), ethereum.FilterQuery{FromBlock: big.NewInt(1)}, ch)
+	assert.NoError(t, err)
+
+	assert.Equal(t, uint64(1), receiveLog(t, ch).BlockNumber)
+	assert.Equal(t, uint64(4), receiveLog(t, ch).BlockNumber)
+	assert.Equal(t, uint64(5), receiveLog(t, ch).BlockNumber)
+
+	client.set(7, types.Log{BlockNumber: 7})
+	assert.Equal(t, uint64(7), receiveLog(t, ch).BlockNumber)
+
+	sub.Unsubscribe()
+	_, ok := <-sub.Err()
+	assert.False(t, ok)
+
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	for _, q := range client.queries {
+		assert.True(t, q.ToBlock.Uint64()-q.FromBlock.Uint64() < 2)
+	}
+}
+
+func TestPollingFiltererFails(t *testing.T) {
+	client := &mockPollingClient{head: 5}
+	pf := NewPollingFilterer(client, time.Second, PollingOpts{Interval: time.Millisecond})
+
+	sub, err := pf.SubscribeFilterLogs(context.Background(), ethereum.FilterQuery{}, make(chan types.Log))
+	assert.NoError(t, err)
+
+	client.lock.Lock()
+	client.head = 6
+	client.err = errors.New("rate limited")
+	client.lock.Unlock()
+
+	select {
+	case err := <-sub.Err():
+		assert.EqualError(t, err, "rate limited")
+	case <-time.After(time.Second):
+		t.Fatal("subscription did not fail")
+	}
+}
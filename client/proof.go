@@ -0,0 +1,225 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// ErrProofMismatch is returned when an RPC response does not match its Merkle proof.
+var ErrProofMismatch = errors.New("rpc response does not match the merkle proof")
+
+// TrustedHeaderFunc returns the header the proofs are verified against, nil number means the latest header.
+// The verification is only as good as the header source, e.g. an own light client or a checkpoint service.
+type TrustedHeaderFunc func(ctx context.Context, number *big.Int) (*types.Header, error)
+
+// HeadersFrom returns a header func reading headers from the given client.
+// Use a client of a trusted node, e.g. an own light client, for the proofs to be meaningful.
+func HeadersFrom(ethClient ethClientGetter) TrustedHeaderFunc {
+	return func(ctx context.Context, number *big.Int) (*types.Header, error) {
+		return ethClient.Client().HeaderByNumber(ctx, number)
+	}
+}
+
+// AccountProof is the eth_getProof response.
+type AccountProof struct {
+	Address      common.Address `json:"address"`
+	AccountProof []string       `json:"accountProof"`
+	Balance      *hexutil.Big   `json:"balance"`
+	CodeHash     common.Hash    `json:"codeHash"`
+	Nonce        hexutil.Uint64 `json:"nonce"`
+	StorageHash  common.Hash    `json:"storageHash"`
+	StorageProof []StorageProof `json:"storageProof"`
+}
+
+// StorageProof is the proof of a single storage slot.
+type StorageProof struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+	Proof []string     `json:"proof"`
+}
+
+// stateAccount is the consensus encoding of an account in the state trie.
+type stateAccount struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// ProofVerifiedReader reads balances and channel state verified against Merkle proofs of a trusted state root
+// instead of trusting the RPC provider.
+type ProofVerifiedReader struct {
+	rpc     *rpc.Client
+	headers TrustedHeaderFunc
+	timeout time.Duration
+}
+
+// NewProofVerifiedReader returns a new proof verified reader. The node behind the rpc client must support eth_getProof.
+func NewProofVerifiedReader(rpcClient *rpc.Client, headers TrustedHeaderFunc, timeout time.Duration) *ProofVerifiedReader {
+	return &ProofVerifiedReader{
+		rpc:     rpcClient,
+		headers: headers,
+		timeout: timeout,
+	}
+}
+
+// GetProof fetches and verifies the account and storage proofs at the given block, nil meaning the latest block.
+func (r *ProofVerifiedReader) GetProof(account common.Address, slots []common.Hash, block *big.Int) (*AccountProof, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	header, err := r.headers(ctx, block)
+	if err != nil {
+		return nil, fmt.Errorf("could not get trusted header: %w", err)
+	}
+
+	keys := make([]string, len(slots))
+	for i, slot := range slots {
+		keys[i] = slot.Hex()
+	}
+
+	var res AccountProof
+	if err := r.rpc.CallContext(ctx, &res, "eth_getProof", account, keys, hexutil.EncodeBig(header.Number)); err != nil {
+		return nil, fmt.Errorf("could not get proof: %w", err)
+	}
+
+	if err := VerifyAccountProof(header.Root, account, slots, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetEthBalance returns the verified ether balance of the address.
+func (r *ProofVerifiedReader) GetEthBalance(address common.Address, block *big.Int) (*big.Int, error) {
+	res, err := r.GetProof(address, nil, block)
+	if err != nil {
+		return nil, err
+	}
+	return res.Balance.ToInt(), nil
+}
+
+// GetMystBalance returns the verified token balance of the holder.
+// balancesSlot is the storage slot index of the token balances mapping.
+func (r *ProofVerifiedReader) GetMystBalance(mystSCAddress, holder common.Address, balancesSlot uint64, block *big.Int) (*big.Int, error) {
+	slot := MappingSlot(common.BytesToHash(holder.Bytes()), balancesSlot)
+	res, err := r.GetProof(mystSCAddress, []common.Hash{slot}, block)
+	if err != nil {
+		return nil, err
+	}
+	return res.StorageProof[0].Value.ToInt(), nil
+}
+
+// GetProviderChannel returns the verified provider channel state.
+// channelsSlot is the storage slot index of the hermes channels mapping.
+func (r *ProofVerifiedReader) GetProviderChannel(hermesID common.Address, channelID [32]byte, channelsSlot uint64, block *big.Int) (ProviderChannel, error) {
+	base := MappingSlot(channelID, channelsSlot).Big()
+	slots := make([]common.Hash, 4)
+	for i := range slots {
+		slots[i] = common.BigToHash(new(big.Int).Add(base, big.NewInt(int64(i))))
+	}
+
+	res, err := r.GetProof(hermesID, slots, block)
+	if err != nil {
+		return ProviderChannel{}, err
+	}
+
+	return ProviderChannel{
+		Settled:       res.StorageProof[0].Value.ToInt(),
+		Stake:         res.StorageProof[1].Value.ToInt(),
+		LastUsedNonce: res.StorageProof[2].Value.ToInt(),
+		Timelock:      res.StorageProof[3].Value.ToInt(),
+	}, nil
+}
+
+// MappingSlot returns the storage slot of the key in a solidity mapping stored at the given slot index.
+func MappingSlot(key common.Hash, slot uint64) common.Hash {
+	return crypto.Keccak256Hash(key.Bytes(), math.U256Bytes(new(big.Int).SetUint64(slot)))
+}
+
+// VerifyAccountProof checks the proof response against the state root.
+func VerifyAccountProof(root common.Hash, account common.Address, slots []common.Hash, res *AccountProof) error {
+	if res.Address != account || len(res.StorageProof) != len(slots) {
+		return fmt.Errorf("%w: unexpected response shape", ErrProofMismatch)
+	}
+
+	value, err := trie.VerifyProof(root, crypto.Keccak256(account.Bytes()), proofDB(res.AccountProof))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrProofMismatch, err)
+	}
+
+	acc := stateAccount{Balance: new(big.Int), Root: types.EmptyRootHash, CodeHash: crypto.Keccak256(nil)}
+	if value != nil {
+		if err := rlp.DecodeBytes(value, &acc); err != nil {
+			return fmt.Errorf("%w: could not decode account: %v", ErrProofMismatch, err)
+		}
+	}
+
+	if res.Balance == nil || acc.Balance.Cmp(res.Balance.ToInt()) != 0 || acc.Nonce != uint64(res.Nonce) ||
+		acc.Root != res.StorageHash || !bytes.Equal(acc.CodeHash, res.CodeHash.Bytes()) {
+		return fmt.Errorf("%w: account %v", ErrProofMismatch, account.Hex())
+	}
+
+	for i, sp := range res.StorageProof {
+		if common.HexToHash(sp.Key) != slots[i] {
+			return fmt.Errorf("%w: unexpected storage key %v", ErrProofMismatch, sp.Key)
+		}
+
+		value, err := trie.VerifyProof(acc.Root, crypto.Keccak256(slots[i].Bytes()), proofDB(sp.Proof))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrProofMismatch, err)
+		}
+
+		stored := new(big.Int)
+		if value != nil {
+			var content []byte
+			if err := rlp.DecodeBytes(value, &content); err != nil {
+				return fmt.Errorf("%w: could not decode storage value: %v", ErrProofMismatch, err)
+			}
+			stored.SetBytes(content)
+		}
+		if sp.Value == nil || stored.Cmp(sp.Value.ToInt()) != 0 {
+			return fmt.Errorf("%w: storage slot %v", ErrProofMismatch, slots[i].Hex())
+		}
+	}
+
+	return nil
+}
+
+func proofDB(proof []string) *memorydb.Database {
+	db := memorydb.New()
+	for _, node := range proof {
+		blob := common.FromHex(node)
+		db.Put(crypto.Keccak256(blob), blob)
+	}
+	return db
+}
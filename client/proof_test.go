@@ -0,0 +1,79 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyAccountProof(t *testing.T) {
+	token := common.HexToAddress("0x1")
+	holder := common.HexToAddress("0x2")
+	slot := MappingSlot(common.BytesToHash(holder.Bytes()), 0)
+
+	db, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	assert.NoError(t, err)
+	db.SetBalance(token, big.NewInt(7))
+	db.SetState(token, slot, common.BigToHash(big.NewInt(100)))
+	root, err := db.Commit(false)
+	assert.NoError(t, err)
+
+	db, err = state.New(root, db.Database(), nil)
+	assert.NoError(t, err)
+	accountProof, err := db.GetProof(token)
+	assert.NoError(t, err)
+	storageProof, err := db.GetStorageProof(token, slot)
+	assert.NoError(t, err)
+
+	res := &AccountProof{
+		Address:      token,
+		AccountProof: toHex(accountProof),
+		Balance:      (*hexutil.Big)(big.NewInt(7)),
+		CodeHash:     db.GetCodeHash(token),
+		Nonce:        0,
+		StorageHash:  db.StorageTrie(token).Hash(),
+		StorageProof: []StorageProof{{
+			Key:   slot.Hex(),
+			Value: (*hexutil.Big)(big.NewInt(100)),
+			Proof: toHex(storageProof),
+		}},
+	}
+	assert.NoError(t, VerifyAccountProof(root, token, []common.Hash{slot}, res))
+
+	res.StorageProof[0].Value = (*hexutil.Big)(big.NewInt(1000))
+	assert.True(t, errors.Is(VerifyAccountProof(root, token, []common.Hash{slot}, res), ErrProofMismatch))
+
+	res.StorageProof[0].Value = (*hexutil.Big)(big.NewInt(100))
+	res.Balance = (*hexutil.Big)(big.NewInt(8))
+	assert.True(t, errors.Is(VerifyAccountProof(root, token, []common.Hash{slot}, res), ErrProofMismatch))
+}
+
+func toHex(proof [][]byte) []string {
+	res := make([]string, len(proof))
+	for i, p := range proof {
+		res[i] = hexutil.Encode(p)
+	}
+	return res
+}
@@ -0,0 +1,153 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// ErrNotProxy is returned when the contract at the given address is not a recognised proxy.
+var ErrNotProxy = errors.New("contract is not a proxy")
+
+// EIP1967ImplementationSlot is the storage slot holding the implementation address of EIP-1967 proxies.
+var EIP1967ImplementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+
+// EIP1967AdminSlot is the storage slot holding the admin address of EIP-1967 proxies.
+var EIP1967AdminSlot = common.HexToHash("0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103")
+
+// ProxyUpgradedTopic is the topic of the EIP-1967 Upgraded(address) event.
+var ProxyUpgradedTopic = common.HexToHash("0xbc7cd75a20ee27fd9adebab32041f755214dbc6bffa90cc0225b39da2e5c2d3b")
+
+// minimal proxy (EIP-1167) code is prefix + implementation address + suffix.
+// Hermes and channel proxies deployed by the registry use it.
+var (
+	minimalProxyPrefix = common.FromHex("0x363d3d373d3d3d363d73")
+	minimalProxySuffix = common.FromHex("0x5af43d82803e903d91602b57fd5bf3")
+)
+
+// ProxyUpgraded represents an upgrade of the proxy implementation.
+type ProxyUpgraded struct {
+	Proxy          common.Address
+	Implementation common.Address
+	Raw            types.Log
+}
+
+// ParseMinimalProxyCode returns the implementation address if the given runtime code is an EIP-1167 minimal proxy.
+func ParseMinimalProxyCode(code []byte) (common.Address, bool) {
+	if len(code) != len(minimalProxyPrefix)+common.AddressLength+len(minimalProxySuffix) {
+		return common.Address{}, false
+	}
+	if !bytes.HasPrefix(code, minimalProxyPrefix) || !bytes.HasSuffix(code, minimalProxySuffix) {
+		return common.Address{}, false
+	}
+	return common.BytesToAddress(code[len(minimalProxyPrefix) : len(minimalProxyPrefix)+common.AddressLength]), true
+}
+
+// GetProxyImplementation returns the implementation address of an EIP-1967 or EIP-1167 proxy.
+// ErrNotProxy is returned for other contracts.
+func (bc *Blockchain) GetProxyImplementation(proxy common.Address) (common.Address, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+
+	slot, err := bc.ethClient.Client().StorageAt(ctx, proxy, EIP1967ImplementationSlot, nil)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("could not get implementation slot: %w", err)
+	}
+	if impl := common.BytesToAddress(slot); impl != (common.Address{}) {
+		return impl, nil
+	}
+
+	code, err := bc.ethClient.Client().CodeAt(ctx, proxy, nil)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("could not get code: %w", err)
+	}
+	if impl, ok := ParseMinimalProxyCode(code); ok {
+		return impl, nil
+	}
+
+	return common.Address{}, ErrNotProxy
+}
+
+// SubscribeToProxyUpgradedEvents subscribes to EIP-1967 Upgraded events of the given proxy.
+func (bc *Blockchain) SubscribeToProxyUpgradedEvents(proxy common.Address) (sink chan *ProxyUpgraded, sub *Subscription, err error) {
+	logs := make(chan types.Log)
+	sink = make(chan *ProxyUpgraded)
+	q := ethereum.FilterQuery{
+		Addresses: []common.Address{proxy},
+		Topics:    [][]common.Hash{{ProxyUpgradedTopic}},
+	}
+
+	resub := event.Resubscribe(DefaultBackoff, func(ctx context.Context) (event.Subscription, error) {
+		return bc.subscriptionFilterer().SubscribeFilterLogs(ctx, q, logs)
+	})
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case l := <-logs:
+				if len(l.Topics) < 2 {
+					continue
+				}
+				upgraded := &ProxyUpgraded{
+					Proxy:          l.Address,
+					Implementation: common.BytesToAddress(l.Topics[1].Bytes()),
+					Raw:            l,
+				}
+				if !bc.admitEvent("Upgraded", proxy, upgraded) {
+					continue
+				}
+				select {
+				case sink <- upgraded:
+				case <-stop:
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return sink, bc.newSubscription("Upgraded", proxy, resub, func() {
+		close(stop)
+		<-stopped
+		close(sink)
+	}), nil
+}
+
+// BindAtProxy binds the given implementation ABI to the proxy address,
+// so the implementation methods can be called through the proxy.
+func BindAtProxy(proxy common.Address, implementationABI string, backend bind.ContractBackend) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(implementationABI))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse implementation abi: %w", err)
+	}
+	return bind.NewBoundContract(proxy, parsed, backend, backend, backend), nil
+}
@@ -0,0 +1,213 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+// DefaultRegistrationConcurrency is the number of identities RegisterIdentities prepares at once.
+const DefaultRegistrationConcurrency = 8
+
+// IdentitySigner signs the registrations of identities, the keystore holding their keys can be used.
+type IdentitySigner interface {
+	SignHash(a accounts.Account, hash []byte) ([]byte, error)
+}
+
+// BatchRegistrationOpts configure RegisterIdentities.
+type BatchRegistrationOpts struct {
+	ChainID       int64
+	Registry      common.Address
+	HermesID      common.Address
+	Stake         *big.Int
+	TransactorFee *big.Int
+	Beneficiary   common.Address
+
+	// Transactor sends the registrations and pays for their gas, Signer signs its transactions.
+	Transactor common.Address
+	Signer     bind.SignerFn
+	// IdentitySigner signs the registration of every identity with its own key.
+	IdentitySigner IdentitySigner
+
+	GasLimit uint64
+	// GasPrice is suggested by the node once for the whole batch if nil.
+	GasPrice *big.Int
+	// Concurrency limits the identities prepared at once, DefaultRegistrationConcurrency if zero.
+	// The transactions are sent one at a time, so their nonces are consecutive.
+	Concurrency int
+}
+
+// RegistrationStatus is the outcome of the registration of a single identity.
+type RegistrationStatus string
+
+const (
+	// RegistrationSent is reported for identities whose registration transaction was sent.
+	RegistrationSent RegistrationStatus = "sent"
+	// RegistrationSkipped is reported for identities that are registered already.
+	RegistrationSkipped RegistrationStatus = "skipped"
+	// RegistrationFailed is reported for identities that could not be registered, see the result error.
+	RegistrationFailed RegistrationStatus = "failed"
+)
+
+// RegistrationResult is the outcome of the registration of a single identity.
+type RegistrationResult struct {
+	Identity common.Address
+	Status   RegistrationStatus
+	// Tx is set for sent registrations.
+	Tx  *types.Transaction
+	Err error
+}
+
+// RegisterIdentities registers the identities with the same hermes, stake and fee, for fleets onboarding many providers at once.
+// The registrations are signed by the identities and sent by the transactor with consecutive nonces.
+// The results are returned in the order of the identities. An error is only returned if the batch could not be started.
+func (bc *Blockchain) RegisterIdentities(ctx context.Context, identities []common.Address, opts BatchRegistrationOpts) ([]RegistrationResult, error) {
+	if opts.IdentitySigner == nil {
+		return nil, errors.New("identity signer must be provided")
+	}
+	if err := bc.checkAddressPolicy(opts.Beneficiary); err != nil {
+		return nil, err
+	}
+
+	gasPrice := opts.GasPrice
+	if gasPrice == nil {
+		var err error
+		gasPrice, err = bc.SuggestGasPrice()
+		if err != nil {
+			return nil, wrap(err, "could not suggest gas price")
+		}
+	}
+
+	nonce, err := bc.getNonce(opts.Transactor)
+	if err != nil {
+		return nil, wrap(err, "could not get nonce")
+	}
+
+	opts.Stake, opts.TransactorFee = bigOrZero(opts.Stake), bigOrZero(opts.TransactorFee)
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultRegistrationConcurrency
+	}
+
+	s := &registrationSender{bc: bc, opts: opts, gasPrice: gasPrice, nonce: nonce}
+	results := make([]RegistrationResult, len(identities))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, identity := range identities {
+		results[i] = RegistrationResult{Identity: identity, Status: RegistrationFailed}
+
+		select {
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		case slots <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(res *RegistrationResult) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			s.register(ctx, res)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// registrationSender sends the registrations of a batch one at a time, sequencing the transactor nonce.
+type registrationSender struct {
+	bc       *Blockchain
+	opts     BatchRegistrationOpts
+	gasPrice *big.Int
+
+	lock  sync.Mutex
+	nonce uint64
+}
+
+func (rs *registrationSender) register(ctx context.Context, res *RegistrationResult) {
+	registered, err := rs.bc.IsRegistered(rs.opts.Registry, res.Identity)
+	if err != nil {
+		res.Err = fmt.Errorf("could not check registration: %w", err)
+		return
+	}
+	if registered {
+		res.Status = RegistrationSkipped
+		return
+	}
+
+	registration := crypto.IdentityRegistration{
+		ChainID:       rs.opts.ChainID,
+		Registry:      rs.opts.Registry,
+		HermesID:      rs.opts.HermesID,
+		Stake:         rs.opts.Stake,
+		TransactorFee: rs.opts.TransactorFee,
+		Beneficiary:   rs.opts.Beneficiary,
+	}
+	signature, err := registration.CreateSignature(rs.opts.IdentitySigner, res.Identity)
+	if err != nil {
+		res.Err = fmt.Errorf("could not sign registration: %w", err)
+		return
+	}
+
+	req := RegistrationRequest{
+		WriteRequest: WriteRequest{
+			Identity: rs.opts.Transactor,
+			Signer:   rs.opts.Signer,
+			GasLimit: rs.opts.GasLimit,
+			GasPrice: rs.gasPrice,
+		},
+		HermesID:        rs.opts.HermesID,
+		Stake:           rs.opts.Stake,
+		TransactorFee:   rs.opts.TransactorFee,
+		Beneficiary:     rs.opts.Beneficiary,
+		Signature:       signature,
+		RegistryAddress: rs.opts.Registry,
+	}
+
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	if err := ctx.Err(); err != nil {
+		res.Err = err
+		return
+	}
+
+	req.Nonce = new(big.Int).SetUint64(rs.nonce)
+	tx, err := rs.bc.RegisterIdentity(req)
+	if err != nil {
+		res.Err = fmt.Errorf("could not send registration: %w", err)
+		// the failed transaction may or may not have consumed the nonce
+		if nonce, nerr := rs.bc.getNonce(rs.opts.Transactor); nerr == nil {
+			rs.nonce = nonce
+		}
+		return
+	}
+
+	rs.nonce++
+	res.Status = RegistrationSent
+	res.Tx = tx
+}
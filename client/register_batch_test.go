@@ -0,0 +1,167 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/mysteriumnetwork/payments/bindings"
+	pc "github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// registryService answers isRegistered calls and accepts registration transactions.
+type registryService struct {
+	lock       sync.Mutex
+	registered map[common.Address]bool
+	failNonce  map[uint64]bool
+	sent       []*types.Transaction
+}
+
+func (rs *registryService) Call(args map[string]interface{}, block string) (hexutil.Bytes, error) {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	input := hexutil.MustDecode(args["data"].(string))
+	res := make([]byte, 32)
+	if rs.registered[common.BytesToAddress(input[len(input)-20:])] {
+		res[31] = 1
+	}
+	return res, nil
+}
+
+func (rs *registryService) SendRawTransaction(data hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(data, tx); err != nil {
+		return common.Hash{}, err
+	}
+
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	if rs.failNonce[tx.Nonce()] {
+		delete(rs.failNonce, tx.Nonce())
+		return common.Hash{}, errors.New("transaction underpriced")
+	}
+	rs.sent = append(rs.sent, tx)
+	return tx.Hash(), nil
+}
+
+func (rs *registryService) pendingNonce(ctx context.Context, account common.Address) (uint64, error) {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	return 10 + uint64(len(rs.sent)), nil
+}
+
+type multiKeySigner map[common.Address]*ecdsa.PrivateKey
+
+func (mks multiKeySigner) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
+	return crypto.Sign(hash, mks[a.Address])
+}
+
+func TestRegisterIdentities(t *testing.T) {
+	keys := multiKeySigner{}
+	var identities []common.Address
+	for i := 0; i < 5; i++ {
+		key, err := crypto.GenerateKey()
+		assert.NoError(t, err)
+		identity := crypto.PubkeyToAddress(key.PublicKey)
+		keys[identity] = key
+		identities = append(identities, identity)
+	}
+
+	svc := &registryService{
+		registered: map[common.Address]bool{identities[1]: true},
+		failNonce:  map[uint64]bool{11: true},
+	}
+	client, server := newTraceClient(t, map[string]interface{}{"eth": svc})
+	defer server.Stop()
+
+	transactorKey, _ := crypto.GenerateKey()
+	opts := BatchRegistrationOpts{
+		ChainID:        5,
+		Registry:       common.HexToAddress("0x1"),
+		HermesID:       common.HexToAddress("0x2"),
+		Stake:          big.NewInt(100),
+		TransactorFee:  big.NewInt(1),
+		Transactor:     crypto.PubkeyToAddress(transactorKey.PublicKey),
+		IdentitySigner: keys,
+		Signer: func(signer types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			return types.SignTx(tx, types.NewEIP155Signer(big.NewInt(5)), transactorKey)
+		},
+		GasLimit:    200000,
+		GasPrice:    big.NewInt(7),
+		Concurrency: 2,
+	}
+
+	bc := NewBlockchainWithCustomNonceTracker(client, time.Second, svc.pendingNonce)
+	results, err := bc.RegisterIdentities(context.Background(), identities, opts)
+	assert.NoError(t, err)
+	assert.Len(t, results, 5)
+
+	sent, failed := 0, 0
+	for i, res := range results {
+		assert.Equal(t, identities[i], res.Identity)
+		switch res.Status {
+		case RegistrationSent:
+			sent++
+			assert.NotNil(t, res.Tx)
+		case RegistrationFailed:
+			failed++
+			assert.Error(t, res.Err)
+		}
+	}
+	assert.Equal(t, RegistrationSkipped, results[1].Status)
+	assert.Equal(t, 3, sent)
+	assert.Equal(t, 1, failed)
+
+	// the failed send does not leave a nonce gap
+	for i, tx := range svc.sent {
+		assert.Equal(t, uint64(10+i), tx.Nonce())
+		assert.Equal(t, big.NewInt(7), tx.GasPrice())
+	}
+
+	// every registration is signed by its identity
+	registryABI, err := abi.JSON(strings.NewReader(bindings.RegistryABI))
+	assert.NoError(t, err)
+	for _, tx := range svc.sent {
+		args, err := registryABI.Methods["registerIdentity"].Inputs.UnpackValues(tx.Data()[4:])
+		assert.NoError(t, err)
+		signer, err := pc.IdentityRegistration{
+			ChainID:       5,
+			Registry:      opts.Registry,
+			HermesID:      args[0].(common.Address),
+			Stake:         args[1].(*big.Int),
+			TransactorFee: args[2].(*big.Int),
+			Beneficiary:   args[3].(common.Address),
+		}.RecoverSigner(args[4].([]byte))
+		assert.NoError(t, err)
+		assert.Contains(t, identities, signer)
+	}
+}
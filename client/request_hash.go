@@ -0,0 +1,73 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var writeRequestType = reflect.TypeOf(WriteRequest{})
+
+// RequestHash returns the content hash of a request struct, e.g. a SettleRequest.
+// The hash covers the request type and the canonical JSON encoding of its fields, so it is stable across process restarts
+// and identifies the same operation in the idempotency checks, the audit log and the metrics.
+// The submission parameters of the embedded WriteRequest are left out, only its Identity is hashed,
+// so a retry with a bumped gas price or a new nonce keeps the hash of the original request.
+func RequestHash(req interface{}) (common.Hash, error) {
+	v := reflect.ValueOf(req)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return common.Hash{}, fmt.Errorf("can not hash nil %T", req)
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return common.Hash{}, fmt.Errorf("can not hash %T, requests are structs", req)
+	}
+
+	canonical := reflect.New(v.Type()).Elem()
+	canonical.Set(v)
+	if v.Type() == writeRequestType {
+		canonical.Set(reflect.ValueOf(operationIdentity(v.Interface().(WriteRequest))))
+	} else if f, ok := v.Type().FieldByName(writeRequestType.Name()); ok && f.Anonymous && f.Type == writeRequestType {
+		wr := canonical.FieldByIndex(f.Index)
+		wr.Set(reflect.ValueOf(operationIdentity(wr.Interface().(WriteRequest))))
+	}
+
+	encoded, err := json.Marshal(canonical.Interface())
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("could not encode %v: %w", v.Type(), err)
+	}
+
+	return crypto.Keccak256Hash([]byte(v.Type().String()), []byte{0}, encoded), nil
+}
+
+// operationIdentity drops the write request fields that may change between retries of the same operation.
+func operationIdentity(wr WriteRequest) WriteRequest {
+	return WriteRequest{Identity: wr.Identity}
+}
+
+// RequestHashLabel returns the shortened request hash, suitable as a metric label or a log field.
+func RequestHashLabel(h common.Hash) string {
+	return hexutil.Encode(h[:8])
+}
@@ -0,0 +1,81 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestHash(t *testing.T) {
+	req := SettleRequest{
+		WriteRequest: WriteRequest{Identity: common.HexToAddress("0x1"), GasPrice: big.NewInt(1), Nonce: big.NewInt(2)},
+		ChannelID:    common.HexToAddress("0x2"),
+		Promise:      crypto.Promise{ChainID: 5, Amount: big.NewInt(100), Fee: big.NewInt(1)},
+	}
+
+	h, err := RequestHash(req)
+	assert.NoError(t, err)
+
+	retry := req
+	retry.GasPrice, retry.Nonce, retry.GasLimit = big.NewInt(3), big.NewInt(4), 100000
+	retryHash, err := RequestHash(&retry)
+	assert.NoError(t, err)
+	assert.Equal(t, h, retryHash, "submission params are not part of the operation")
+
+	other := req
+	other.Promise.Amount = big.NewInt(101)
+	otherHash, err := RequestHash(other)
+	assert.NoError(t, err)
+	assert.NotEqual(t, h, otherHash)
+
+	otherIdentity := req
+	otherIdentity.Identity = common.HexToAddress("0x3")
+	otherHash, err = RequestHash(otherIdentity)
+	assert.NoError(t, err)
+	assert.NotEqual(t, h, otherHash)
+
+	// same content, different request type
+	settle, err := RequestHash(SettleRequest{})
+	assert.NoError(t, err)
+	transfer, err := RequestHash(EthTransferRequest{})
+	assert.NoError(t, err)
+	assert.NotEqual(t, settle, transfer)
+
+	assert.Equal(t, 18, len(RequestHashLabel(h)))
+
+	_, err = RequestHash("settle")
+	assert.Error(t, err)
+}
+
+func TestRequestHashAllRequests(t *testing.T) {
+	requests := []interface{}{
+		RegistrationRequest{}, TransferRequest{}, ProviderStakeIncreaseRequest{}, SettleIntoStakeRequest{},
+		DecreaseProviderStakeRequest{}, SettleAndRebalanceRequest{}, SettleRequest{}, EthTransferRequest{},
+		SettleWithBeneficiaryRequest{}, PauseChannelOpeningRequest{}, ActivateChannelOpeningRequest{},
+		HermesWithdrawRequest{}, SetHermesMinStakeRequest{}, SetHermesFundsDestinationRequest{}, MetaTxRequest{},
+		RequestChannelExitRequest{}, FinalizeChannelExitRequest{},
+	}
+	for _, req := range requests {
+		_, err := RequestHash(req)
+		assert.NoError(t, err, "%T", req)
+	}
+}
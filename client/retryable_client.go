@@ -17,15 +17,19 @@
 package client
 
 import (
+	"context"
+	"errors"
+	"io"
 	"math/big"
+	"net"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/mysteriumnetwork/payments/bindings"
-	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
@@ -35,9 +39,10 @@ type BC interface {
 	IsRegisteredAsProvider(hermesAddress, registryAddress, addressToCheck common.Address) (bool, error)
 	GetProviderChannel(hermesAddress common.Address, addressToCheck common.Address, pending bool) (ProviderChannel, error)
 	IsRegistered(registryAddress, addressToCheck common.Address) (bool, error)
-	SubscribeToPromiseSettledEvent(providerID, hermesID common.Address) (sink chan *bindings.HermesImplementationPromiseSettled, cancel func(), err error)
+	SubscribeToPromiseSettledEvent(providerID, hermesID common.Address) (sink chan *bindings.HermesImplementationPromiseSettled, sub *Subscription, err error)
 	GetMystBalance(mystSCAddress, address common.Address) (*big.Int, error)
-	SubscribeToConsumerBalanceEvent(channel, mystSCAddress common.Address, timeout time.Duration) (chan *bindings.MystTokenTransfer, func(), error)
+	GetTokenDecimals(token common.Address) (uint8, error)
+	SubscribeToConsumerBalanceEvent(channel, mystSCAddress common.Address, timeout time.Duration) (chan *bindings.MystTokenTransfer, *Subscription, error)
 	RegisterIdentity(rr RegistrationRequest) (*types.Transaction, error)
 	TransferMyst(req TransferRequest) (tx *types.Transaction, err error)
 	IsHermesRegistered(registryAddress, acccountantID common.Address) (bool, error)
@@ -47,11 +52,11 @@ type BC interface {
 	GetConsumerChannelsHermes(channelAddress common.Address) (ConsumersHermes, error)
 	GetConsumerChannelOperator(channelAddress common.Address) (common.Address, error)
 	GetProviderChannelByID(acc common.Address, chID []byte) (ProviderChannel, error)
-	SubscribeToIdentityRegistrationEvents(registryAddress common.Address) (sink chan *bindings.RegistryRegisteredIdentity, cancel func(), err error)
-	SubscribeToConsumerChannelBalanceUpdate(mystSCAddress common.Address, channelAddresses []common.Address) (sink chan *bindings.MystTokenTransfer, cancel func(), err error)
+	SubscribeToIdentityRegistrationEvents(registryAddress common.Address) (sink chan *bindings.RegistryRegisteredIdentity, sub *Subscription, err error)
+	SubscribeToConsumerChannelBalanceUpdate(mystSCAddress common.Address, channelAddresses []common.Address) (sink chan *bindings.MystTokenTransfer, sub *Subscription, err error)
 	SettlePromise(req SettleRequest) (*types.Transaction, error)
-	SubscribeToPromiseSettledEventByChannelID(hermesID common.Address, providerAddresses [][32]byte) (sink chan *bindings.HermesImplementationPromiseSettled, cancel func(), err error)
-	SubscribeToMystTokenTransfers(mystSCAddress common.Address) (chan *bindings.MystTokenTransfer, func(), error)
+	SubscribeToPromiseSettledEventByChannelID(hermesID common.Address, providerAddresses [][32]byte) (sink chan *bindings.HermesImplementationPromiseSettled, sub *Subscription, err error)
+	SubscribeToMystTokenTransfers(mystSCAddress common.Address) (chan *bindings.MystTokenTransfer, *Subscription, error)
 	NetworkID() (*big.Int, error)
 	GetConsumerChannel(addr common.Address, mystSCAddress common.Address) (ConsumerChannel, error)
 	GetEthBalance(address common.Address) (*big.Int, error)
@@ -64,11 +69,46 @@ type BC interface {
 	GetHermesURL(registryID, hermesID common.Address) (string, error)
 	GetStakeThresholds(hermesID common.Address) (min, max *big.Int, err error)
 	GetBeneficiary(registryAddress, identity common.Address) (common.Address, error)
+	GetProviderChannelAtBlock(hermesAddress, addressToCheck common.Address, block *big.Int) (ProviderChannel, error)
+	GetMystBalanceAtBlock(mystSCAddress, address common.Address, block *big.Int) (*big.Int, error)
+	GetBeneficiaryAtBlock(registryAddress, identity common.Address, block *big.Int) (common.Address, error)
+	FeeHistory(blockCount uint64, newest *big.Int, percentiles []float64) (*FeeHistory, error)
+	GetForwarderNonce(forwarder, from common.Address) (*big.Int, error)
+	VerifyMetaTx(req MetaTxRequest) (bool, error)
+	ExecuteMetaTx(req MetaTxRequest) (*types.Transaction, error)
 	SuggestGasPrice() (*big.Int, error)
 	FilterLogs(q ethereum.FilterQuery) ([]types.Log, error)
 	HeaderByNumber(number *big.Int) (*types.Header, error)
 	GetLastRegistryNonce(registry common.Address) (*big.Int, error)
 	SendTransaction(tx *types.Transaction) error
+	SendTransactionWithFeeGuard(tx *types.Transaction, value *big.Int, override bool) error
+	GetProxyImplementation(proxy common.Address) (common.Address, error)
+	Capabilities(contracts CapabilityContracts) (Capabilities, error)
+	SubscribeToProxyUpgradedEvents(proxy common.Address) (sink chan *ProxyUpgraded, sub *Subscription, err error)
+	PauseChannelOpening(req PauseChannelOpeningRequest) (*types.Transaction, error)
+	ActivateChannelOpening(req ActivateChannelOpeningRequest) (*types.Transaction, error)
+	WithdrawHermesBalance(req HermesWithdrawRequest) (*types.Transaction, error)
+	GetLatestChannelImplementation(registryAddress common.Address) (common.Address, error)
+	GetChannelExit(channelAddress common.Address) (ChannelExit, error)
+	RequestChannelExit(req RequestChannelExitRequest) (*types.Transaction, error)
+	FinalizeChannelExit(req FinalizeChannelExitRequest) (*types.Transaction, error)
+	SetHermesMinStake(req SetHermesMinStakeRequest) (*types.Transaction, error)
+	SetHermesFundsDestination(req SetHermesFundsDestinationRequest) (*types.Transaction, error)
+	SubscribeToProviderStakeEvents(hermesID common.Address, channelIDs [][32]byte) (sink chan *bindings.HermesImplementationNewStake, sub *Subscription, err error)
+	FilterProviderStakeEvents(hermesID common.Address, channelIDs [][32]byte, start uint64, end *uint64) ([]*bindings.HermesImplementationNewStake, error)
+	SubscribeToHermesStakeIncreasedEvents(hermesID common.Address) (sink chan *bindings.HermesImplementationHermesStakeIncreased, sub *Subscription, err error)
+	FilterHermesStakeIncreasedEvents(hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationHermesStakeIncreased, error)
+	SubscribeToHermesFeeUpdatedEvents(hermesID common.Address) (sink chan *bindings.HermesImplementationHermesFeeUpdated, sub *Subscription, err error)
+	FilterHermesFeeUpdatedEvents(hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationHermesFeeUpdated, error)
+	SubscribeToHermesFundsWithdrawnEvents(hermesID common.Address) (sink chan *bindings.HermesImplementationFundsWithdrawned, sub *Subscription, err error)
+	FilterHermesFundsWithdrawnEvents(hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationFundsWithdrawned, error)
+	SubscribeToBeneficiaryChangedEvents(registryAddress common.Address, identities []common.Address) (sink chan *bindings.RegistryBeneficiaryChanged, sub *Subscription, err error)
+	FilterBeneficiaryChangedEvents(registryAddress common.Address, identities []common.Address, start uint64, end *uint64) ([]*bindings.RegistryBeneficiaryChanged, error)
+	SubscribeToChannelWithdrawEvents(channelAddress common.Address) (sink chan *bindings.ChannelImplementationWithdraw, sub *Subscription, err error)
+	FilterChannelWithdrawEvents(channelAddress common.Address, start uint64, end *uint64) ([]*bindings.ChannelImplementationWithdraw, error)
+	StreamLogs(ctx context.Context, q ethereum.FilterQuery) (*LogStream, error)
+	StreamLogsFrom(ctx context.Context, q ethereum.FilterQuery, cursor LogCursor) (*LogStream, error)
+	StreamLogsNamed(ctx context.Context, name string, q ethereum.FilterQuery, offsets OffsetStore) (*LogStream, error)
 }
 
 // BlockchainWithRetries takes in the plain blockchain implementation and exposes methods that will retry the underlying bc methods before giving up.
@@ -79,6 +119,31 @@ type BlockchainWithRetries struct {
 	bc         BC
 	stop       chan struct{}
 	once       sync.Once
+
+	failover Failoverer
+}
+
+// Failoverer switches to another endpoint, ReconnectableEthClient can be used.
+type Failoverer interface {
+	Failover() error
+}
+
+// AttachFailover makes failed calls fail the client over to the next endpoint before they are retried.
+// Only connection failures fail over, errors returned by the node do not.
+// Not thread safe, call before making calls.
+func (bwr *BlockchainWithRetries) AttachFailover(f Failoverer) {
+	bwr.failover = f
+}
+
+// isConnectionError returns true if the error is caused by the connection to the node rather than by the node.
+func isConnectionError(err error) bool {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.Is(err, rpc.ErrClientQuit)
 }
 
 // ErrStopped represents an error when a call is interrupted
@@ -99,7 +164,7 @@ func (bwr *BlockchainWithRetries) FilterLogs(q ethereum.FilterQuery) ([]types.Lo
 	err := bwr.callWithRetry(func() error {
 		r, err := bwr.bc.FilterLogs(q)
 		if err != nil {
-			return errors.Wrap(err, "could not filter logs")
+			return wrap(err, "could not filter logs")
 		}
 		res = r
 		return nil
@@ -112,7 +177,7 @@ func (bwr *BlockchainWithRetries) GetLastRegistryNonce(registry common.Address)
 	err := bwr.callWithRetry(func() error {
 		r, err := bwr.bc.GetLastRegistryNonce(registry)
 		if err != nil {
-			return errors.Wrap(err, "could not get registry nonce")
+			return wrap(err, "could not get registry nonce")
 		}
 		res = r
 		return nil
@@ -127,7 +192,7 @@ func (bwr *BlockchainWithRetries) HeaderByNumber(number *big.Int) (*types.Header
 	err := bwr.callWithRetry(func() error {
 		r, err := bwr.bc.HeaderByNumber(number)
 		if err != nil {
-			return errors.Wrap(err, "could not get header by number")
+			return wrap(err, "could not get header by number")
 		}
 		res = r
 		return nil
@@ -140,7 +205,7 @@ func (bwr *BlockchainWithRetries) SuggestGasPrice() (*big.Int, error) {
 	err := bwr.callWithRetry(func() error {
 		r, err := bwr.bc.SuggestGasPrice()
 		if err != nil {
-			return errors.Wrap(err, "could not get gas price")
+			return wrap(err, "could not get gas price")
 		}
 		res = r
 		return nil
@@ -157,6 +222,11 @@ func (bwr *BlockchainWithRetries) callWithRetry(f func() error) error {
 		if i == bwr.maxRetries {
 			return err
 		}
+		if bwr.failover != nil && isConnectionError(err) {
+			if ferr := bwr.failover.Failover(); ferr != nil {
+				log.Warn().Err(ferr).Msg("could not fail over")
+			}
+		}
 
 		log.Warn().Err(err).Msgf("retry %v of %v", i+1, bwr.maxRetries)
 		select {
@@ -169,19 +239,19 @@ func (bwr *BlockchainWithRetries) callWithRetry(f func() error) error {
 }
 
 // SubscribeToMystTokenTransfers subscribes to myst token transfer events
-func (bwr *BlockchainWithRetries) SubscribeToMystTokenTransfers(mystSCAddress common.Address) (chan *bindings.MystTokenTransfer, func(), error) {
+func (bwr *BlockchainWithRetries) SubscribeToMystTokenTransfers(mystSCAddress common.Address) (chan *bindings.MystTokenTransfer, *Subscription, error) {
 	var sink chan *bindings.MystTokenTransfer
-	var cancel func()
+	var sub *Subscription
 	err := bwr.callWithRetry(func() error {
-		s, c, err := bwr.bc.SubscribeToMystTokenTransfers(mystSCAddress)
+		s, su, err := bwr.bc.SubscribeToMystTokenTransfers(mystSCAddress)
 		if err != nil {
-			return errors.Wrap(err, "could not subscribe to settlement events")
+			return wrap(err, "could not subscribe to settlement events")
 		}
 		sink = s
-		cancel = c
+		sub = su
 		return nil
 	})
-	return sink, cancel, err
+	return sink, sub, err
 }
 
 // GetHermesFee fetches the hermes fee from blockchain
@@ -190,7 +260,7 @@ func (bwr *BlockchainWithRetries) GetHermesFee(hermesAddress common.Address) (ui
 	err := bwr.callWithRetry(func() error {
 		r, err := bwr.bc.GetHermesFee(hermesAddress)
 		if err != nil {
-			return errors.Wrap(err, "could not get hermes fee")
+			return wrap(err, "could not get hermes fee")
 		}
 		res = r
 		return nil
@@ -203,7 +273,7 @@ func (bwr *BlockchainWithRetries) GetBeneficiary(registryAddress, identity commo
 	err := bwr.callWithRetry(func() error {
 		r, err := bwr.bc.GetBeneficiary(registryAddress, identity)
 		if err != nil {
-			return errors.Wrap(err, "could not get beneficiary")
+			return wrap(err, "could not get beneficiary")
 		}
 		res = r
 		return nil
@@ -217,7 +287,7 @@ func (bwr *BlockchainWithRetries) CalculateHermesFee(hermesAddress common.Addres
 	err := bwr.callWithRetry(func() error {
 		r, err := bwr.bc.CalculateHermesFee(hermesAddress, value)
 		if err != nil {
-			return errors.Wrap(err, "could not calculate hermes fee")
+			return wrap(err, "could not calculate hermes fee")
 		}
 		res = r
 		return nil
@@ -231,7 +301,7 @@ func (bwr *BlockchainWithRetries) IsRegisteredAsProvider(hermesAddress, registry
 	err := bwr.callWithRetry(func() error {
 		r, err := bwr.bc.IsRegisteredAsProvider(hermesAddress, registryAddress, addressToCheck)
 		if err != nil {
-			return errors.Wrap(err, "could not check if registered as provider")
+			return wrap(err, "could not check if registered as provider")
 		}
 		res = r
 		return nil
@@ -245,7 +315,7 @@ func (bwr *BlockchainWithRetries) GetProviderChannel(hermesAddress, addressToChe
 	err := bwr.callWithRetry(func() error {
 		r, err := bwr.bc.GetProviderChannel(hermesAddress, addressToCheck, pending)
 		if err != nil {
-			return errors.Wrap(err, "could not get provider channel")
+			return wrap(err, "could not get provider channel")
 		}
 		res = r
 		return nil
@@ -255,35 +325,35 @@ func (bwr *BlockchainWithRetries) GetProviderChannel(hermesAddress, addressToChe
 }
 
 // SubscribeToPromiseSettledEvent subscribes to promise settled events
-func (bwr *BlockchainWithRetries) SubscribeToPromiseSettledEvent(providerID, hermesID common.Address) (chan *bindings.HermesImplementationPromiseSettled, func(), error) {
+func (bwr *BlockchainWithRetries) SubscribeToPromiseSettledEvent(providerID, hermesID common.Address) (chan *bindings.HermesImplementationPromiseSettled, *Subscription, error) {
 	var sink chan *bindings.HermesImplementationPromiseSettled
-	var cancel func()
+	var sub *Subscription
 	err := bwr.callWithRetry(func() error {
-		s, c, err := bwr.bc.SubscribeToPromiseSettledEvent(providerID, hermesID)
+		s, su, err := bwr.bc.SubscribeToPromiseSettledEvent(providerID, hermesID)
 		if err != nil {
-			return errors.Wrap(err, "could not subscribe to settlement events")
+			return wrap(err, "could not subscribe to settlement events")
 		}
 		sink = s
-		cancel = c
+		sub = su
 		return nil
 	})
-	return sink, cancel, err
+	return sink, sub, err
 }
 
 // SubscribeToConsumerBalanceEvent subscribes to the consumer balance change events
-func (bwr *BlockchainWithRetries) SubscribeToConsumerBalanceEvent(channel, mystSCAddress common.Address, timeout time.Duration) (chan *bindings.MystTokenTransfer, func(), error) {
+func (bwr *BlockchainWithRetries) SubscribeToConsumerBalanceEvent(channel, mystSCAddress common.Address, timeout time.Duration) (chan *bindings.MystTokenTransfer, *Subscription, error) {
 	var sink chan *bindings.MystTokenTransfer
-	var cancel func()
+	var sub *Subscription
 	err := bwr.callWithRetry(func() error {
-		s, c, err := bwr.bc.SubscribeToConsumerBalanceEvent(channel, mystSCAddress, timeout)
+		s, su, err := bwr.bc.SubscribeToConsumerBalanceEvent(channel, mystSCAddress, timeout)
 		if err != nil {
-			return errors.Wrap(err, "could not subscribe to settlement events")
+			return wrap(err, "could not subscribe to settlement events")
 		}
 		sink = s
-		cancel = c
+		sub = su
 		return nil
 	})
-	return sink, cancel, err
+	return sink, sub, err
 }
 
 // IsRegistered checks wether the given identity is registered or not
@@ -292,7 +362,7 @@ func (bwr *BlockchainWithRetries) IsRegistered(registryAddress, addressToCheck c
 	err := bwr.callWithRetry(func() error {
 		r, err := bwr.bc.IsRegistered(registryAddress, addressToCheck)
 		if err != nil {
-			return errors.Wrap(err, "check registration status")
+			return wrap(err, "check registration status")
 		}
 		res = r
 		return nil
@@ -306,7 +376,7 @@ func (bwr *BlockchainWithRetries) GetMystBalance(mystSCAddress, channel common.A
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.GetMystBalance(mystSCAddress, channel)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not get myst balance")
+			return wrap(bcErr, "could not get myst balance")
 		}
 		res = result
 		return nil
@@ -320,7 +390,7 @@ func (bwr *BlockchainWithRetries) RegisterIdentity(rr RegistrationRequest) (*typ
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.RegisterIdentity(rr)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not register identity")
+			return wrap(bcErr, "could not register identity")
 		}
 		res = result
 		return nil
@@ -333,7 +403,7 @@ func (bwr *BlockchainWithRetries) TransferMyst(req TransferRequest) (tx *types.T
 	err = bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.TransferMyst(req)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not transfer myst")
+			return wrap(bcErr, "could not transfer myst")
 		}
 		tx = result
 		return nil
@@ -347,7 +417,7 @@ func (bwr *BlockchainWithRetries) IsHermesRegistered(registryAddress, acccountan
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.IsHermesRegistered(registryAddress, acccountantID)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not check if hermes is registered")
+			return wrap(bcErr, "could not check if hermes is registered")
 		}
 		res = result
 		return nil
@@ -361,7 +431,7 @@ func (bwr *BlockchainWithRetries) GetHermesOperator(hermesID common.Address) (co
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.GetHermesOperator(hermesID)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not get hermes operator")
+			return wrap(bcErr, "could not get hermes operator")
 		}
 		res = result
 		return nil
@@ -375,7 +445,7 @@ func (bwr *BlockchainWithRetries) SettleAndRebalance(req SettleAndRebalanceReque
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.SettleAndRebalance(req)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not settle and rebalance")
+			return wrap(bcErr, "could not settle and rebalance")
 		}
 		res = result
 		return nil
@@ -389,7 +459,49 @@ func (bwr *BlockchainWithRetries) GetProviderChannelByID(acc common.Address, chI
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.GetProviderChannelByID(acc, chID)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not register identity")
+			return wrap(bcErr, "could not register identity")
+		}
+		res = result
+		return nil
+	})
+	return res, err
+}
+
+// GetProviderChannelAtBlock returns the provider channel as of the given block.
+func (bwr *BlockchainWithRetries) GetProviderChannelAtBlock(hermesAddress, addressToCheck common.Address, block *big.Int) (ProviderChannel, error) {
+	var res ProviderChannel
+	err := bwr.callWithRetry(func() error {
+		result, bcErr := bwr.bc.GetProviderChannelAtBlock(hermesAddress, addressToCheck, block)
+		if bcErr != nil {
+			return wrap(bcErr, "could not get provider channel at block")
+		}
+		res = result
+		return nil
+	})
+	return res, err
+}
+
+// GetMystBalanceAtBlock returns the myst balance as of the given block.
+func (bwr *BlockchainWithRetries) GetMystBalanceAtBlock(mystSCAddress, address common.Address, block *big.Int) (*big.Int, error) {
+	var res *big.Int
+	err := bwr.callWithRetry(func() error {
+		result, bcErr := bwr.bc.GetMystBalanceAtBlock(mystSCAddress, address, block)
+		if bcErr != nil {
+			return wrap(bcErr, "could not get myst balance at block")
+		}
+		res = result
+		return nil
+	})
+	return res, err
+}
+
+// GetBeneficiaryAtBlock returns the beneficiary as of the given block.
+func (bwr *BlockchainWithRetries) GetBeneficiaryAtBlock(registryAddress, identity common.Address, block *big.Int) (common.Address, error) {
+	var res common.Address
+	err := bwr.callWithRetry(func() error {
+		result, bcErr := bwr.bc.GetBeneficiaryAtBlock(registryAddress, identity, block)
+		if bcErr != nil {
+			return wrap(bcErr, "could not get beneficiary at block")
 		}
 		res = result
 		return nil
@@ -403,7 +515,7 @@ func (bwr *BlockchainWithRetries) GetConsumerChannelsHermes(channelAddress commo
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.GetConsumerChannelsHermes(channelAddress)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not get consumers hermes")
+			return wrap(bcErr, "could not get consumers hermes")
 		}
 		res = result
 		return nil
@@ -417,7 +529,7 @@ func (bwr *BlockchainWithRetries) GetConsumerChannelOperator(channelAddress comm
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.GetConsumerChannelOperator(channelAddress)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not get consumer's operator")
+			return wrap(bcErr, "could not get consumer's operator")
 		}
 		res = result
 		return nil
@@ -430,7 +542,7 @@ func (bwr *BlockchainWithRetries) TransactionReceipt(hash common.Hash) (*types.R
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.TransactionReceipt(hash)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not get transaction receipt")
+			return wrap(bcErr, "could not get transaction receipt")
 		}
 		res = result
 		return nil
@@ -439,35 +551,456 @@ func (bwr *BlockchainWithRetries) TransactionReceipt(hash common.Hash) (*types.R
 }
 
 // SubscribeToIdentityRegistrationEvents subscribes to identity registration events
-func (bwr *BlockchainWithRetries) SubscribeToIdentityRegistrationEvents(registryAddress common.Address) (chan *bindings.RegistryRegisteredIdentity, func(), error) {
+func (bwr *BlockchainWithRetries) SubscribeToIdentityRegistrationEvents(registryAddress common.Address) (chan *bindings.RegistryRegisteredIdentity, *Subscription, error) {
 	var sink chan *bindings.RegistryRegisteredIdentity
-	var cancel func()
+	var sub *Subscription
 	err := bwr.callWithRetry(func() error {
-		s, c, err := bwr.bc.SubscribeToIdentityRegistrationEvents(registryAddress)
+		s, su, err := bwr.bc.SubscribeToIdentityRegistrationEvents(registryAddress)
 		if err != nil {
-			return errors.Wrap(err, "could not subscribe to registration events")
+			return wrap(err, "could not subscribe to registration events")
 		}
 		sink = s
-		cancel = c
+		sub = su
+		return nil
+	})
+	return sink, sub, err
+}
+
+// PauseChannelOpening pauses channel opening in hermes.
+func (bwr *BlockchainWithRetries) PauseChannelOpening(req PauseChannelOpeningRequest) (*types.Transaction, error) {
+	var res *types.Transaction
+	err := bwr.callWithRetry(func() error {
+		result, bcErr := bwr.bc.PauseChannelOpening(req)
+		if bcErr != nil {
+			return wrap(bcErr, "could not pause channel opening")
+		}
+		res = result
+		return nil
+	})
+	return res, err
+}
+
+// ActivateChannelOpening resumes channel opening in hermes.
+func (bwr *BlockchainWithRetries) ActivateChannelOpening(req ActivateChannelOpeningRequest) (*types.Transaction, error) {
+	var res *types.Transaction
+	err := bwr.callWithRetry(func() error {
+		result, bcErr := bwr.bc.ActivateChannelOpening(req)
+		if bcErr != nil {
+			return wrap(bcErr, "could not activate channel opening")
+		}
+		res = result
+		return nil
+	})
+	return res, err
+}
+
+// WithdrawHermesBalance withdraws the available hermes balance.
+func (bwr *BlockchainWithRetries) WithdrawHermesBalance(req HermesWithdrawRequest) (*types.Transaction, error) {
+	var res *types.Transaction
+	err := bwr.callWithRetry(func() error {
+		result, bcErr := bwr.bc.WithdrawHermesBalance(req)
+		if bcErr != nil {
+			return wrap(bcErr, "could not withdraw hermes balance")
+		}
+		res = result
+		return nil
+	})
+	return res, err
+}
+
+// GetLatestChannelImplementation returns the channel implementation new channels are deployed with.
+func (bwr *BlockchainWithRetries) GetLatestChannelImplementation(registryAddress common.Address) (common.Address, error) {
+	var res common.Address
+	err := bwr.callWithRetry(func() error {
+		result, bcErr := bwr.bc.GetLatestChannelImplementation(registryAddress)
+		if bcErr != nil {
+			return wrap(bcErr, "could not get latest channel implementation")
+		}
+		res = result
 		return nil
 	})
-	return sink, cancel, err
+	return res, err
+}
+
+// GetChannelExit returns the pending exit request of the consumer channel.
+func (bwr *BlockchainWithRetries) GetChannelExit(channelAddress common.Address) (ChannelExit, error) {
+	var res ChannelExit
+	err := bwr.callWithRetry(func() error {
+		result, bcErr := bwr.bc.GetChannelExit(channelAddress)
+		if bcErr != nil {
+			return wrap(bcErr, "could not get channel exit")
+		}
+		res = result
+		return nil
+	})
+	return res, err
+}
+
+// RequestChannelExit starts the exit of all the channel funds to the beneficiary.
+func (bwr *BlockchainWithRetries) RequestChannelExit(req RequestChannelExitRequest) (*types.Transaction, error) {
+	var res *types.Transaction
+	err := bwr.callWithRetry(func() error {
+		result, bcErr := bwr.bc.RequestChannelExit(req)
+		if bcErr != nil {
+			return wrap(bcErr, "could not request channel exit")
+		}
+		res = result
+		return nil
+	})
+	return res, err
+}
+
+// FinalizeChannelExit transfers the channel funds to the beneficiary of the requested exit.
+func (bwr *BlockchainWithRetries) FinalizeChannelExit(req FinalizeChannelExitRequest) (*types.Transaction, error) {
+	var res *types.Transaction
+	err := bwr.callWithRetry(func() error {
+		result, bcErr := bwr.bc.FinalizeChannelExit(req)
+		if bcErr != nil {
+			return wrap(bcErr, "could not finalize channel exit")
+		}
+		res = result
+		return nil
+	})
+	return res, err
+}
+
+// SetHermesMinStake sets the minimal channel stake of hermes.
+func (bwr *BlockchainWithRetries) SetHermesMinStake(req SetHermesMinStakeRequest) (*types.Transaction, error) {
+	var res *types.Transaction
+	err := bwr.callWithRetry(func() error {
+		result, bcErr := bwr.bc.SetHermesMinStake(req)
+		if bcErr != nil {
+			return wrap(bcErr, "could not set hermes min stake")
+		}
+		res = result
+		return nil
+	})
+	return res, err
+}
+
+// SetHermesFundsDestination sets the address that receives the funds claimed from hermes.
+func (bwr *BlockchainWithRetries) SetHermesFundsDestination(req SetHermesFundsDestinationRequest) (*types.Transaction, error) {
+	var res *types.Transaction
+	err := bwr.callWithRetry(func() error {
+		result, bcErr := bwr.bc.SetHermesFundsDestination(req)
+		if bcErr != nil {
+			return wrap(bcErr, "could not set hermes funds destination")
+		}
+		res = result
+		return nil
+	})
+	return res, err
+}
+
+// SubscribeToProviderStakeEvents subscribes to provider channel stake updates, both increases and decreases.
+func (bwr *BlockchainWithRetries) SubscribeToProviderStakeEvents(hermesID common.Address, channelIDs [][32]byte) (chan *bindings.HermesImplementationNewStake, *Subscription, error) {
+	var sink chan *bindings.HermesImplementationNewStake
+	var sub *Subscription
+	err := bwr.callWithRetry(func() error {
+		s, su, err := bwr.bc.SubscribeToProviderStakeEvents(hermesID, channelIDs)
+		if err != nil {
+			return wrap(err, "could not subscribe to provider channel stake updates, both increases and decreases")
+		}
+		sink = s
+		sub = su
+		return nil
+	})
+	return sink, sub, err
+}
+
+// FilterProviderStakeEvents returns the provider channel stake updates, both increases and decreases in the given block range.
+func (bwr *BlockchainWithRetries) FilterProviderStakeEvents(hermesID common.Address, channelIDs [][32]byte, start uint64, end *uint64) ([]*bindings.HermesImplementationNewStake, error) {
+	var res []*bindings.HermesImplementationNewStake
+	err := bwr.callWithRetry(func() error {
+		r, err := bwr.bc.FilterProviderStakeEvents(hermesID, channelIDs, start, end)
+		if err != nil {
+			return wrap(err, "could not filter provider channel stake updates, both increases and decreases")
+		}
+		res = r
+		return nil
+	})
+	return res, err
+}
+
+// SubscribeToHermesStakeIncreasedEvents subscribes to hermes stake increase events.
+func (bwr *BlockchainWithRetries) SubscribeToHermesStakeIncreasedEvents(hermesID common.Address) (chan *bindings.HermesImplementationHermesStakeIncreased, *Subscription, error) {
+	var sink chan *bindings.HermesImplementationHermesStakeIncreased
+	var sub *Subscription
+	err := bwr.callWithRetry(func() error {
+		s, su, err := bwr.bc.SubscribeToHermesStakeIncreasedEvents(hermesID)
+		if err != nil {
+			return wrap(err, "could not subscribe to hermes stake increase events")
+		}
+		sink = s
+		sub = su
+		return nil
+	})
+	return sink, sub, err
+}
+
+// FilterHermesStakeIncreasedEvents returns the hermes stake increase events in the given block range.
+func (bwr *BlockchainWithRetries) FilterHermesStakeIncreasedEvents(hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationHermesStakeIncreased, error) {
+	var res []*bindings.HermesImplementationHermesStakeIncreased
+	err := bwr.callWithRetry(func() error {
+		r, err := bwr.bc.FilterHermesStakeIncreasedEvents(hermesID, start, end)
+		if err != nil {
+			return wrap(err, "could not filter hermes stake increase events")
+		}
+		res = r
+		return nil
+	})
+	return res, err
+}
+
+// SubscribeToHermesFeeUpdatedEvents subscribes to hermes fee update events.
+func (bwr *BlockchainWithRetries) SubscribeToHermesFeeUpdatedEvents(hermesID common.Address) (chan *bindings.HermesImplementationHermesFeeUpdated, *Subscription, error) {
+	var sink chan *bindings.HermesImplementationHermesFeeUpdated
+	var sub *Subscription
+	err := bwr.callWithRetry(func() error {
+		s, su, err := bwr.bc.SubscribeToHermesFeeUpdatedEvents(hermesID)
+		if err != nil {
+			return wrap(err, "could not subscribe to hermes fee update events")
+		}
+		sink = s
+		sub = su
+		return nil
+	})
+	return sink, sub, err
+}
+
+// FilterHermesFeeUpdatedEvents returns the hermes fee update events in the given block range.
+func (bwr *BlockchainWithRetries) FilterHermesFeeUpdatedEvents(hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationHermesFeeUpdated, error) {
+	var res []*bindings.HermesImplementationHermesFeeUpdated
+	err := bwr.callWithRetry(func() error {
+		r, err := bwr.bc.FilterHermesFeeUpdatedEvents(hermesID, start, end)
+		if err != nil {
+			return wrap(err, "could not filter hermes fee update events")
+		}
+		res = r
+		return nil
+	})
+	return res, err
+}
+
+// SubscribeToHermesFundsWithdrawnEvents subscribes to hermes funds withdrawal events.
+func (bwr *BlockchainWithRetries) SubscribeToHermesFundsWithdrawnEvents(hermesID common.Address) (chan *bindings.HermesImplementationFundsWithdrawned, *Subscription, error) {
+	var sink chan *bindings.HermesImplementationFundsWithdrawned
+	var sub *Subscription
+	err := bwr.callWithRetry(func() error {
+		s, su, err := bwr.bc.SubscribeToHermesFundsWithdrawnEvents(hermesID)
+		if err != nil {
+			return wrap(err, "could not subscribe to hermes funds withdrawal events")
+		}
+		sink = s
+		sub = su
+		return nil
+	})
+	return sink, sub, err
+}
+
+// FilterHermesFundsWithdrawnEvents returns the hermes funds withdrawal events in the given block range.
+func (bwr *BlockchainWithRetries) FilterHermesFundsWithdrawnEvents(hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationFundsWithdrawned, error) {
+	var res []*bindings.HermesImplementationFundsWithdrawned
+	err := bwr.callWithRetry(func() error {
+		r, err := bwr.bc.FilterHermesFundsWithdrawnEvents(hermesID, start, end)
+		if err != nil {
+			return wrap(err, "could not filter hermes funds withdrawal events")
+		}
+		res = r
+		return nil
+	})
+	return res, err
+}
+
+// SubscribeToBeneficiaryChangedEvents subscribes to identity beneficiary change events.
+func (bwr *BlockchainWithRetries) SubscribeToBeneficiaryChangedEvents(registryAddress common.Address, identities []common.Address) (chan *bindings.RegistryBeneficiaryChanged, *Subscription, error) {
+	var sink chan *bindings.RegistryBeneficiaryChanged
+	var sub *Subscription
+	err := bwr.callWithRetry(func() error {
+		s, su, err := bwr.bc.SubscribeToBeneficiaryChangedEvents(registryAddress, identities)
+		if err != nil {
+			return wrap(err, "could not subscribe to identity beneficiary change events")
+		}
+		sink = s
+		sub = su
+		return nil
+	})
+	return sink, sub, err
+}
+
+// FilterBeneficiaryChangedEvents returns the identity beneficiary change events in the given block range.
+func (bwr *BlockchainWithRetries) FilterBeneficiaryChangedEvents(registryAddress common.Address, identities []common.Address, start uint64, end *uint64) ([]*bindings.RegistryBeneficiaryChanged, error) {
+	var res []*bindings.RegistryBeneficiaryChanged
+	err := bwr.callWithRetry(func() error {
+		r, err := bwr.bc.FilterBeneficiaryChangedEvents(registryAddress, identities, start, end)
+		if err != nil {
+			return wrap(err, "could not filter identity beneficiary change events")
+		}
+		res = r
+		return nil
+	})
+	return res, err
+}
+
+// SubscribeToChannelWithdrawEvents subscribes to consumer channel withdrawal events.
+func (bwr *BlockchainWithRetries) SubscribeToChannelWithdrawEvents(channelAddress common.Address) (chan *bindings.ChannelImplementationWithdraw, *Subscription, error) {
+	var sink chan *bindings.ChannelImplementationWithdraw
+	var sub *Subscription
+	err := bwr.callWithRetry(func() error {
+		s, su, err := bwr.bc.SubscribeToChannelWithdrawEvents(channelAddress)
+		if err != nil {
+			return wrap(err, "could not subscribe to consumer channel withdrawal events")
+		}
+		sink = s
+		sub = su
+		return nil
+	})
+	return sink, sub, err
+}
+
+// FilterChannelWithdrawEvents returns the consumer channel withdrawal events in the given block range.
+func (bwr *BlockchainWithRetries) FilterChannelWithdrawEvents(channelAddress common.Address, start uint64, end *uint64) ([]*bindings.ChannelImplementationWithdraw, error) {
+	var res []*bindings.ChannelImplementationWithdraw
+	err := bwr.callWithRetry(func() error {
+		r, err := bwr.bc.FilterChannelWithdrawEvents(channelAddress, start, end)
+		if err != nil {
+			return wrap(err, "could not filter consumer channel withdrawal events")
+		}
+		res = r
+		return nil
+	})
+	return res, err
+}
+
+func (bwr *BlockchainWithRetries) FeeHistory(blockCount uint64, newest *big.Int, percentiles []float64) (*FeeHistory, error) {
+	var res *FeeHistory
+	err := bwr.callWithRetry(func() error {
+		r, err := bwr.bc.FeeHistory(blockCount, newest, percentiles)
+		if err != nil {
+			return wrap(err, "could not get fee history")
+		}
+		res = r
+		return nil
+	})
+	return res, err
+}
+
+// Capabilities reports which features are available on the connected chain with the given contracts.
+func (bwr *BlockchainWithRetries) Capabilities(contracts CapabilityContracts) (Capabilities, error) {
+	var res Capabilities
+	err := bwr.callWithRetry(func() error {
+		r, err := bwr.bc.Capabilities(contracts)
+		if err != nil {
+			return wrap(err, "could not get capabilities")
+		}
+		res = r
+		return nil
+	})
+	return res, err
+}
+
+// GetForwarderNonce returns the next meta-transaction nonce of the sender on the given forwarder.
+func (bwr *BlockchainWithRetries) GetForwarderNonce(forwarder, from common.Address) (*big.Int, error) {
+	var res *big.Int
+	err := bwr.callWithRetry(func() error {
+		r, err := bwr.bc.GetForwarderNonce(forwarder, from)
+		if err != nil {
+			return wrap(err, "could not get forwarder nonce")
+		}
+		res = r
+		return nil
+	})
+	return res, err
+}
+
+// VerifyMetaTx checks with the forwarder that the meta-transaction signature and nonce are valid.
+func (bwr *BlockchainWithRetries) VerifyMetaTx(req MetaTxRequest) (bool, error) {
+	var res bool
+	err := bwr.callWithRetry(func() error {
+		r, err := bwr.bc.VerifyMetaTx(req)
+		if err != nil {
+			return wrap(err, "could not verify meta transaction")
+		}
+		res = r
+		return nil
+	})
+	return res, err
+}
+
+// ExecuteMetaTx relays the signed meta-transaction through the forwarder.
+func (bwr *BlockchainWithRetries) ExecuteMetaTx(req MetaTxRequest) (*types.Transaction, error) {
+	var res *types.Transaction
+	err := bwr.callWithRetry(func() error {
+		result, bcErr := bwr.bc.ExecuteMetaTx(req)
+		if bcErr != nil {
+			return wrap(bcErr, "could not execute meta transaction")
+		}
+		res = result
+		return nil
+	})
+	return res, err
+}
+
+// StreamLogs streams the logs matching the given query.
+// The stream reconnects on its own, so the call is not retried.
+func (bwr *BlockchainWithRetries) StreamLogs(ctx context.Context, q ethereum.FilterQuery) (*LogStream, error) {
+	return bwr.bc.StreamLogs(ctx, q)
+}
+
+// StreamLogsFrom resumes a log stream after the given cursor.
+func (bwr *BlockchainWithRetries) StreamLogsFrom(ctx context.Context, q ethereum.FilterQuery, cursor LogCursor) (*LogStream, error) {
+	return bwr.bc.StreamLogsFrom(ctx, q, cursor)
+}
+
+// StreamLogsNamed streams the logs matching the given query, resuming after the offset committed under the name.
+func (bwr *BlockchainWithRetries) StreamLogsNamed(ctx context.Context, name string, q ethereum.FilterQuery, offsets OffsetStore) (*LogStream, error) {
+	return bwr.bc.StreamLogsNamed(ctx, name, q, offsets)
+}
+
+// GetProxyImplementation returns the implementation address of the given proxy.
+func (bwr *BlockchainWithRetries) GetProxyImplementation(proxy common.Address) (common.Address, error) {
+	var res common.Address
+	err := bwr.callWithRetry(func() error {
+		result, bcErr := bwr.bc.GetProxyImplementation(proxy)
+		if bcErr != nil {
+			return wrap(bcErr, "could not get proxy implementation")
+		}
+		res = result
+		return nil
+	})
+	return res, err
+}
+
+// SubscribeToProxyUpgradedEvents subscribes to proxy implementation upgrade events.
+func (bwr *BlockchainWithRetries) SubscribeToProxyUpgradedEvents(proxy common.Address) (chan *ProxyUpgraded, *Subscription, error) {
+	var sink chan *ProxyUpgraded
+	var sub *Subscription
+	err := bwr.callWithRetry(func() error {
+		s, su, err := bwr.bc.SubscribeToProxyUpgradedEvents(proxy)
+		if err != nil {
+			return wrap(err, "could not subscribe to proxy upgraded events")
+		}
+		sink = s
+		sub = su
+		return nil
+	})
+	return sink, sub, err
 }
 
 // SubscribeToConsumerChannelBalanceUpdate subscribes to consumer channel balance update events
-func (bwr *BlockchainWithRetries) SubscribeToConsumerChannelBalanceUpdate(mystSCAddress common.Address, channelAddresses []common.Address) (chan *bindings.MystTokenTransfer, func(), error) {
+func (bwr *BlockchainWithRetries) SubscribeToConsumerChannelBalanceUpdate(mystSCAddress common.Address, channelAddresses []common.Address) (chan *bindings.MystTokenTransfer, *Subscription, error) {
 	var sink chan *bindings.MystTokenTransfer
-	var cancel func()
+	var sub *Subscription
 	err := bwr.callWithRetry(func() error {
-		s, c, err := bwr.bc.SubscribeToConsumerChannelBalanceUpdate(mystSCAddress, channelAddresses)
+		s, su, err := bwr.bc.SubscribeToConsumerChannelBalanceUpdate(mystSCAddress, channelAddresses)
 		if err != nil {
-			return errors.Wrap(err, "could not subscribe to channel balance events")
+			return wrap(err, "could not subscribe to channel balance events")
 		}
 		sink = s
-		cancel = c
+		sub = su
 		return nil
 	})
-	return sink, cancel, err
+	return sink, sub, err
 }
 
 // SettlePromise is settling the given consumer issued promise
@@ -476,7 +1009,7 @@ func (bwr *BlockchainWithRetries) SettlePromise(req SettleRequest) (*types.Trans
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.SettlePromise(req)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not settle promise")
+			return wrap(bcErr, "could not settle promise")
 		}
 		res = result
 		return nil
@@ -490,7 +1023,7 @@ func (bwr *BlockchainWithRetries) GetHermessAvailableBalance(hermesAddress commo
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.GetHermessAvailableBalance(hermesAddress)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not get balance")
+			return wrap(bcErr, "could not get balance")
 		}
 		res = result
 		return nil
@@ -504,7 +1037,7 @@ func (bwr *BlockchainWithRetries) GetEthBalance(address common.Address) (*big.In
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.GetEthBalance(address)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not get balance")
+			return wrap(bcErr, "could not get balance")
 		}
 		res = result
 		return nil
@@ -518,7 +1051,7 @@ func (bwr *BlockchainWithRetries) GetHermesURL(registryID, hermesID common.Addre
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.GetHermesURL(registryID, hermesID)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not get hermes url")
+			return wrap(bcErr, "could not get hermes url")
 		}
 		res = result
 		return nil
@@ -532,7 +1065,7 @@ func (bwr *BlockchainWithRetries) TransferEth(etr EthTransferRequest) (*types.Tr
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.TransferEth(etr)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not transfer ethereum")
+			return wrap(bcErr, "could not transfer ethereum")
 		}
 		res = result
 		return nil
@@ -541,19 +1074,19 @@ func (bwr *BlockchainWithRetries) TransferEth(etr EthTransferRequest) (*types.Tr
 }
 
 // SubscribeToPromiseSettledEventByChannelID subscribes to promise settled events
-func (bwr *BlockchainWithRetries) SubscribeToPromiseSettledEventByChannelID(hermesID common.Address, providerAddresses [][32]byte) (chan *bindings.HermesImplementationPromiseSettled, func(), error) {
+func (bwr *BlockchainWithRetries) SubscribeToPromiseSettledEventByChannelID(hermesID common.Address, providerAddresses [][32]byte) (chan *bindings.HermesImplementationPromiseSettled, *Subscription, error) {
 	var sink chan *bindings.HermesImplementationPromiseSettled
-	var cancel func()
+	var sub *Subscription
 	err := bwr.callWithRetry(func() error {
-		s, c, err := bwr.bc.SubscribeToPromiseSettledEventByChannelID(hermesID, providerAddresses)
+		s, su, err := bwr.bc.SubscribeToPromiseSettledEventByChannelID(hermesID, providerAddresses)
 		if err != nil {
-			return errors.Wrap(err, "could not subscribe to settlement events")
+			return wrap(err, "could not subscribe to settlement events")
 		}
 		sink = s
-		cancel = c
+		sub = su
 		return nil
 	})
-	return sink, cancel, err
+	return sink, sub, err
 }
 
 // GetConsumerChannel returns the consumer channel
@@ -562,7 +1095,7 @@ func (bwr *BlockchainWithRetries) GetConsumerChannel(addr common.Address, mystSC
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.GetConsumerChannel(addr, mystSCAddress)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not get consumers channel")
+			return wrap(bcErr, "could not get consumers channel")
 		}
 		res = result
 		return nil
@@ -576,7 +1109,7 @@ func (bwr *BlockchainWithRetries) NetworkID() (*big.Int, error) {
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.NetworkID()
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not get network ID")
+			return wrap(bcErr, "could not get network ID")
 		}
 		res = result
 		return nil
@@ -597,7 +1130,7 @@ func (bwr *BlockchainWithRetries) SettleWithBeneficiary(req SettleWithBeneficiar
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.SettleWithBeneficiary(req)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not set beneficiary")
+			return wrap(bcErr, "could not set beneficiary")
 		}
 		res = result
 		return nil
@@ -611,7 +1144,7 @@ func (bwr *BlockchainWithRetries) DecreaseProviderStake(req DecreaseProviderStak
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.DecreaseProviderStake(req)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not set beneficiary")
+			return wrap(bcErr, "could not set beneficiary")
 		}
 		res = result
 		return nil
@@ -625,7 +1158,7 @@ func (bwr *BlockchainWithRetries) SettleIntoStake(req SettleIntoStakeRequest) (*
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.SettleIntoStake(req)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not set beneficiary")
+			return wrap(bcErr, "could not set beneficiary")
 		}
 		res = result
 		return nil
@@ -639,7 +1172,7 @@ func (bwr *BlockchainWithRetries) IncreaseProviderStake(req ProviderStakeIncreas
 	err := bwr.callWithRetry(func() error {
 		result, bcErr := bwr.bc.IncreaseProviderStake(req)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not set beneficiary")
+			return wrap(bcErr, "could not set beneficiary")
 		}
 		res = result
 		return nil
@@ -652,7 +1185,7 @@ func (bwr *BlockchainWithRetries) GetStakeThresholds(hermesID common.Address) (m
 	err = bwr.callWithRetry(func() error {
 		m, ma, bcErr := bwr.bc.GetStakeThresholds(hermesID)
 		if bcErr != nil {
-			return errors.Wrap(bcErr, "could not set beneficiary")
+			return wrap(bcErr, "could not set beneficiary")
 		}
 		min = m
 		max = ma
@@ -664,8 +1197,32 @@ func (bwr *BlockchainWithRetries) GetStakeThresholds(hermesID common.Address) (m
 func (bwr *BlockchainWithRetries) SendTransaction(tx *types.Transaction) error {
 	return bwr.callWithRetry(func() error {
 		if err := bwr.bc.SendTransaction(tx); err != nil {
-			return errors.Wrap(err, "could not send transaction to bc")
+			return wrap(err, "could not send transaction to bc")
+		}
+		return nil
+	})
+}
+
+// SendTransactionWithFeeGuard sends the transaction checking it against the given operation value.
+func (bwr *BlockchainWithRetries) SendTransactionWithFeeGuard(tx *types.Transaction, value *big.Int, override bool) error {
+	return bwr.callWithRetry(func() error {
+		if err := bwr.bc.SendTransactionWithFeeGuard(tx, value, override); err != nil {
+			return wrap(err, "could not send transaction to bc")
 		}
 		return nil
 	})
 }
+
+// GetTokenDecimals returns the decimals of the ERC-20 token.
+func (bwr *BlockchainWithRetries) GetTokenDecimals(token common.Address) (uint8, error) {
+	var res uint8
+	err := bwr.callWithRetry(func() error {
+		result, bcErr := bwr.bc.GetTokenDecimals(token)
+		if bcErr != nil {
+			return wrap(bcErr, "could not get token decimals")
+		}
+		res = result
+		return nil
+	})
+	return res, err
+}
@@ -0,0 +1,195 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/bindings"
+)
+
+// ErrNothingToSettle is returned when the promise amount is already settled.
+var ErrNothingToSettle = errors.New("promise amount is already settled")
+
+// SettlementReport describes the token flows a settlement is expected to produce given the current chain state.
+type SettlementReport struct {
+	// Beneficiary is the address that receives the payout.
+	Beneficiary common.Address
+	// UnpaidAmount is the part of the promise amount that will be settled.
+	UnpaidAmount *big.Int
+	// BeneficiaryPayout is the amount transferred to the beneficiary.
+	BeneficiaryPayout *big.Int
+	// HermesFee is the amount taken by hermes.
+	HermesFee *big.Int
+	// TransactorFee is the amount paid to the transaction sender.
+	TransactorFee *big.Int
+	// StakeIncrease is the amount that goes into the channel stake instead of being paid out.
+	StakeIncrease *big.Int
+	// SettledTotal is the channel settled total after the settlement.
+	SettledTotal *big.Int
+}
+
+// DryRunSettlePromise simulates the consumer channel settlement and reports the expected token flows.
+func (cwdr *WithDryRuns) DryRunSettlePromise(req SettleRequest) (SettlementReport, error) {
+	if err := cwdr.dryRun(req); err != nil {
+		return SettlementReport{}, err
+	}
+
+	hermes, err := cwdr.bc.GetConsumerChannelsHermes(req.ChannelID)
+	if err != nil {
+		return SettlementReport{}, fmt.Errorf("could not get consumer channel hermes: %w", err)
+	}
+
+	balance, err := cwdr.getChannelBalance(req.ChannelID)
+	if err != nil {
+		return SettlementReport{}, err
+	}
+
+	return calculateConsumerSettlement(req.Promise.Amount, req.Promise.Fee, hermes, balance)
+}
+
+// DryRunSettleAndRebalance simulates the hermes promise settlement and reports the expected token flows.
+func (cwdr *WithDryRuns) DryRunSettleAndRebalance(req SettleAndRebalanceRequest) (SettlementReport, error) {
+	if err := cwdr.dryRun(req); err != nil {
+		return SettlementReport{}, err
+	}
+
+	return cwdr.hermesSettlementReport(req.HermesID, req.ProviderID, req.Promise.Amount, req.Promise.Fee, false)
+}
+
+// DryRunSettleIntoStake simulates the hermes promise settlement into stake and reports the expected token flows.
+func (cwdr *WithDryRuns) DryRunSettleIntoStake(req SettleIntoStakeRequest) (SettlementReport, error) {
+	if err := cwdr.dryRun(req); err != nil {
+		return SettlementReport{}, err
+	}
+
+	return cwdr.hermesSettlementReport(req.HermesID, req.ProviderID, req.Promise.Amount, req.Promise.Fee, true)
+}
+
+// dryRun forces the estimation even if the request has no gas limit set.
+func (cwdr *WithDryRuns) dryRun(req Estimatable) error {
+	estimator, err := req.toEstimator(cwdr.ethClient)
+	if err != nil {
+		return err
+	}
+
+	_, err = estimator.Estimate(req.toEstimateOps())
+	return cwdr.toTracedRevertError(req, err)
+}
+
+func (cwdr *WithDryRuns) getChannelBalance(channel common.Address) (*big.Int, error) {
+	caller, err := bindings.NewChannelImplementationCaller(channel, cwdr.ethClient.Client())
+	if err != nil {
+		return nil, fmt.Errorf("could not create channel implementation caller: %w", err)
+	}
+
+	token, err := caller.Token(&bind.CallOpts{Context: context.Background()})
+	if err != nil {
+		return nil, fmt.Errorf("could not get channel token: %w", err)
+	}
+
+	return cwdr.bc.GetMystBalance(token, channel)
+}
+
+func (cwdr *WithDryRuns) hermesSettlementReport(hermesID, providerID common.Address, amount, transactorFee *big.Int, intoStake bool) (SettlementReport, error) {
+	channel, err := cwdr.bc.GetProviderChannel(hermesID, providerID, false)
+	if err != nil {
+		return SettlementReport{}, fmt.Errorf("could not get provider channel: %w", err)
+	}
+
+	unpaid := new(big.Int).Sub(amount, channel.Settled)
+	if unpaid.Sign() <= 0 {
+		return SettlementReport{}, ErrNothingToSettle
+	}
+
+	hermesFee, err := cwdr.bc.CalculateHermesFee(hermesID, unpaid)
+	if err != nil {
+		return SettlementReport{}, fmt.Errorf("could not calculate hermes fee: %w", err)
+	}
+
+	report := calculateHermesSettlement(unpaid, transactorFee, hermesFee, channel, intoStake)
+	if intoStake {
+		return report, nil
+	}
+
+	hermes, err := bindings.NewHermesImplementationCaller(hermesID, cwdr.ethClient.Client())
+	if err != nil {
+		return SettlementReport{}, fmt.Errorf("could not create hermes implementation caller: %w", err)
+	}
+
+	registry, err := hermes.GetRegistry(&bind.CallOpts{Context: context.Background()})
+	if err != nil {
+		return SettlementReport{}, fmt.Errorf("could not get hermes registry: %w", err)
+	}
+
+	report.Beneficiary, err = cwdr.bc.GetBeneficiary(registry, providerID)
+	if err != nil {
+		return SettlementReport{}, fmt.Errorf("could not get beneficiary: %w", err)
+	}
+
+	return report, nil
+}
+
+// calculateConsumerSettlement mirrors the consumer channel settlement.
+// The unpaid amount is limited by the channel balance and everything except the transactor fee is sent to hermes.
+func calculateConsumerSettlement(amount, transactorFee *big.Int, hermes ConsumersHermes, balance *big.Int) (SettlementReport, error) {
+	unpaid := new(big.Int).Sub(amount, hermes.Settled)
+	if unpaid.Sign() <= 0 {
+		return SettlementReport{}, ErrNothingToSettle
+	}
+
+	if unpaid.Cmp(balance) > 0 {
+		unpaid = new(big.Int).Set(balance)
+	}
+
+	return SettlementReport{
+		Beneficiary:       hermes.ContractAddress,
+		UnpaidAmount:      unpaid,
+		BeneficiaryPayout: new(big.Int).Sub(unpaid, transactorFee),
+		HermesFee:         new(big.Int),
+		TransactorFee:     new(big.Int).Set(transactorFee),
+		StakeIncrease:     new(big.Int),
+		SettledTotal:      new(big.Int).Add(hermes.Settled, unpaid),
+	}, nil
+}
+
+// calculateHermesSettlement mirrors the hermes settlement.
+// When settling into stake, the amount left after fees increases the channel stake instead of being paid out.
+func calculateHermesSettlement(unpaid, transactorFee, hermesFee *big.Int, channel ProviderChannel, intoStake bool) SettlementReport {
+	rest := new(big.Int).Sub(unpaid, transactorFee)
+	rest.Sub(rest, hermesFee)
+
+	report := SettlementReport{
+		UnpaidAmount:      unpaid,
+		BeneficiaryPayout: rest,
+		HermesFee:         new(big.Int).Set(hermesFee),
+		TransactorFee:     new(big.Int).Set(transactorFee),
+		StakeIncrease:     new(big.Int),
+		SettledTotal:      new(big.Int).Add(channel.Settled, unpaid),
+	}
+
+	if intoStake {
+		report.StakeIncrease, report.BeneficiaryPayout = rest, new(big.Int)
+	}
+
+	return report
+}
@@ -0,0 +1,59 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateConsumerSettlement(t *testing.T) {
+	hermes := ConsumersHermes{ContractAddress: common.HexToAddress("0x1"), Settled: big.NewInt(100)}
+
+	report, err := calculateConsumerSettlement(big.NewInt(200), big.NewInt(10), hermes, big.NewInt(1000))
+	assert.NoError(t, err)
+	assert.Equal(t, hermes.ContractAddress, report.Beneficiary)
+	assert.Equal(t, big.NewInt(100), report.UnpaidAmount)
+	assert.Equal(t, big.NewInt(90), report.BeneficiaryPayout)
+	assert.Equal(t, big.NewInt(200), report.SettledTotal)
+
+	// limited by channel balance
+	report, err = calculateConsumerSettlement(big.NewInt(200), big.NewInt(10), hermes, big.NewInt(50))
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(50), report.UnpaidAmount)
+	assert.Equal(t, big.NewInt(40), report.BeneficiaryPayout)
+	assert.Equal(t, big.NewInt(150), report.SettledTotal)
+
+	_, err = calculateConsumerSettlement(big.NewInt(100), big.NewInt(10), hermes, big.NewInt(50))
+	assert.Equal(t, ErrNothingToSettle, err)
+}
+
+func TestCalculateHermesSettlement(t *testing.T) {
+	channel := ProviderChannel{Settled: big.NewInt(10), Stake: big.NewInt(0)}
+
+	report := calculateHermesSettlement(big.NewInt(100), big.NewInt(5), big.NewInt(20), channel, false)
+	assert.Equal(t, big.NewInt(75), report.BeneficiaryPayout)
+	assert.Equal(t, big.NewInt(0), report.StakeIncrease)
+	assert.Equal(t, big.NewInt(110), report.SettledTotal)
+
+	report = calculateHermesSettlement(big.NewInt(100), big.NewInt(5), big.NewInt(20), channel, true)
+	assert.Equal(t, big.NewInt(0), report.BeneficiaryPayout)
+	assert.Equal(t, big.NewInt(75), report.StakeIncrease)
+}
@@ -0,0 +1,211 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// ErrUnknownOperatorAccount is returned when routing to an account that was not added to the router.
+	ErrUnknownOperatorAccount = errors.New("unknown operator account")
+	// ErrNoOperatorRoute is returned when no account is routed for the identity or channel and there is no default account.
+	ErrNoOperatorRoute = errors.New("no operator account routed")
+)
+
+// OperatorAccount is an operator key write requests can be signed with.
+type OperatorAccount struct {
+	Address common.Address
+	Signer  bind.SignerFn
+}
+
+// NonceSource hands out the transaction nonces of the operator accounts. NonceTracker can be used.
+type NonceSource interface {
+	GetNonce(ctx context.Context, account common.Address) (uint64, error)
+	ForceReloadNonce(account common.Address)
+}
+
+var _ NonceSource = (*NonceTracker)(nil)
+
+// SignerStats are the counters of a single operator account.
+type SignerStats struct {
+	// Routes is the number of identities and channels routed to the account.
+	Routes   int
+	Signed   uint64
+	Failures uint64
+	// LastNonce is the nonce of the last signed request.
+	LastNonce uint64
+}
+
+// SignerRouter manages multiple operator keys and routes every write request to the key managing
+// the identity or channel it concerns. Nonces are tracked per key, so requests of different keys never block each other.
+type SignerRouter struct {
+	nonces  NonceSource
+	timeout time.Duration
+
+	lock     sync.Mutex
+	accounts map[common.Address]OperatorAccount
+	routes   map[common.Address]common.Address
+	fallback *common.Address
+	stats    map[common.Address]*SignerStats
+}
+
+// NewSignerRouter returns a new signer router. The timeout limits fetching the nonce of a key.
+func NewSignerRouter(nonces NonceSource, timeout time.Duration) *SignerRouter {
+	return &SignerRouter{
+		nonces:   nonces,
+		timeout:  timeout,
+		accounts: make(map[common.Address]OperatorAccount),
+		routes:   make(map[common.Address]common.Address),
+		stats:    make(map[common.Address]*SignerStats),
+	}
+}
+
+// AddAccount adds an operator key to the router. Adding an account again replaces its signer.
+func (sr *SignerRouter) AddAccount(acc OperatorAccount) {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+	sr.accounts[acc.Address] = acc
+	if _, ok := sr.stats[acc.Address]; !ok {
+		sr.stats[acc.Address] = &SignerStats{}
+	}
+}
+
+// RemoveAccount removes the operator key together with its routes.
+func (sr *SignerRouter) RemoveAccount(address common.Address) {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+	delete(sr.accounts, address)
+	delete(sr.stats, address)
+	for subject, account := range sr.routes {
+		if account == address {
+			delete(sr.routes, subject)
+		}
+	}
+	if sr.fallback != nil && *sr.fallback == address {
+		sr.fallback = nil
+	}
+}
+
+// Route routes the write requests concerning the identity or channel to the operator account.
+func (sr *SignerRouter) Route(subject, account common.Address) error {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+	if _, ok := sr.accounts[account]; !ok {
+		return fmt.Errorf("%w: %v", ErrUnknownOperatorAccount, account.Hex())
+	}
+	sr.routes[subject] = account
+	return nil
+}
+
+// SetDefault sets the operator account used for identities and channels without a route.
+func (sr *SignerRouter) SetDefault(account common.Address) error {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+	if _, ok := sr.accounts[account]; !ok {
+		return fmt.Errorf("%w: %v", ErrUnknownOperatorAccount, account.Hex())
+	}
+	sr.fallback = &account
+	return nil
+}
+
+// AccountFor returns the operator account routed for the identity or channel.
+func (sr *SignerRouter) AccountFor(subject common.Address) (OperatorAccount, error) {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+	return sr.accountFor(subject)
+}
+
+func (sr *SignerRouter) accountFor(subject common.Address) (OperatorAccount, error) {
+	address, ok := sr.routes[subject]
+	if !ok {
+		if sr.fallback == nil {
+			return OperatorAccount{}, fmt.Errorf("%w: %v", ErrNoOperatorRoute, subject.Hex())
+		}
+		address = *sr.fallback
+	}
+	return sr.accounts[address], nil
+}
+
+// Sign fills in the sender, signer and nonce of the write request from the operator account routed for the identity or channel.
+// A nonce already set on the request is kept.
+func (sr *SignerRouter) Sign(subject common.Address, wr WriteRequest) (WriteRequest, error) {
+	acc, err := sr.AccountFor(subject)
+	if err != nil {
+		return wr, err
+	}
+
+	wr.Identity = acc.Address
+	wr.Signer = acc.Signer
+	if wr.Nonce == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), sr.timeout)
+		defer cancel()
+		nonce, err := sr.nonces.GetNonce(ctx, acc.Address)
+		if err != nil {
+			sr.record(acc.Address, func(s *SignerStats) { s.Failures++ })
+			return wr, fmt.Errorf("could not get nonce of %v: %w", acc.Address.Hex(), err)
+		}
+		wr.Nonce = new(big.Int).SetUint64(nonce)
+	}
+
+	nonce := wr.Nonce.Uint64()
+	sr.record(acc.Address, func(s *SignerStats) {
+		s.Signed++
+		s.LastNonce = nonce
+	})
+	return wr, nil
+}
+
+// ReportFailure records a failed transaction of the operator account and reloads its nonce,
+// so the nonce of a transaction that never reached the network is reused.
+func (sr *SignerRouter) ReportFailure(account common.Address) {
+	sr.nonces.ForceReloadNonce(account)
+	sr.record(account, func(s *SignerStats) { s.Failures++ })
+}
+
+// Stats returns the counters of every operator account.
+func (sr *SignerRouter) Stats() map[common.Address]SignerStats {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+
+	res := make(map[common.Address]SignerStats, len(sr.stats))
+	for address, s := range sr.stats {
+		res[address] = *s
+	}
+	for _, account := range sr.routes {
+		s := res[account]
+		s.Routes++
+		res[account] = s
+	}
+	return res
+}
+
+func (sr *SignerRouter) record(account common.Address, f func(s *SignerStats)) {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+	if s, ok := sr.stats[account]; ok {
+		f(s)
+	}
+}
@@ -0,0 +1,104 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockNonceSource struct {
+	nonces map[common.Address]uint64
+}
+
+func (m *mockNonceSource) GetNonce(ctx context.Context, account common.Address) (uint64, error) {
+	n := m.nonces[account]
+	m.nonces[account] = n + 1
+	return n, nil
+}
+
+func (m *mockNonceSource) ForceReloadNonce(account common.Address) {
+	m.nonces[account]--
+}
+
+func TestSignerRouter(t *testing.T) {
+	keyA, keyB := common.HexToAddress("0xa"), common.HexToAddress("0xb")
+	provider1, provider2, channel := common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")
+	signer := func(a common.Address) OperatorAccount {
+		return OperatorAccount{Address: a, Signer: func(types.Signer, common.Address, *types.Transaction) (*types.Transaction, error) {
+			return nil, nil
+		}}
+	}
+
+	nonces := &mockNonceSource{nonces: map[common.Address]uint64{keyA: 5, keyB: 0}}
+	sr := NewSignerRouter(nonces, time.Second)
+
+	assert.True(t, errors.Is(sr.Route(provider1, keyA), ErrUnknownOperatorAccount))
+
+	sr.AddAccount(signer(keyA))
+	sr.AddAccount(signer(keyB))
+	assert.NoError(t, sr.Route(provider1, keyA))
+	assert.NoError(t, sr.Route(channel, keyB))
+
+	_, err := sr.Sign(provider2, WriteRequest{})
+	assert.True(t, errors.Is(err, ErrNoOperatorRoute))
+
+	wr, err := sr.Sign(provider1, WriteRequest{GasLimit: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, keyA, wr.Identity)
+	assert.NotNil(t, wr.Signer)
+	assert.Equal(t, big.NewInt(5), wr.Nonce)
+	assert.Equal(t, uint64(100), wr.GasLimit)
+
+	wr, err = sr.Sign(provider1, WriteRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(6), wr.Nonce)
+
+	wr, err = sr.Sign(channel, WriteRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, keyB, wr.Identity)
+	assert.Equal(t, big.NewInt(0), wr.Nonce)
+
+	sr.ReportFailure(keyA)
+	wr, err = sr.Sign(provider1, WriteRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(6), wr.Nonce)
+
+	assert.NoError(t, sr.SetDefault(keyB))
+	wr, err = sr.Sign(provider2, WriteRequest{Nonce: big.NewInt(42)})
+	assert.NoError(t, err)
+	assert.Equal(t, keyB, wr.Identity)
+	assert.Equal(t, big.NewInt(42), wr.Nonce)
+
+	stats := sr.Stats()
+	assert.Equal(t, SignerStats{Routes: 1, Signed: 3, Failures: 1, LastNonce: 6}, stats[keyA])
+	assert.Equal(t, SignerStats{Routes: 1, Signed: 2, LastNonce: 42}, stats[keyB])
+
+	sr.RemoveAccount(keyA)
+	_, err = sr.Sign(provider1, WriteRequest{})
+	assert.NoError(t, err)
+	acc, err := sr.AccountFor(provider1)
+	assert.NoError(t, err)
+	assert.Equal(t, keyB, acc.Address)
+}
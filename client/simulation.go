@@ -0,0 +1,135 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SimulatedTx is a transaction that was signed but withheld in simulation mode.
+type SimulatedTx struct {
+	Hash common.Hash
+	From common.Address
+	// To is nil for contract creations.
+	To       *common.Address
+	Data     []byte
+	Value    *big.Int
+	Gas      uint64
+	GasPrice *big.Int
+	Nonce    uint64
+	// EstimatedGas is the gas the node estimated for the transaction against the current state, zero if it failed.
+	EstimatedGas uint64
+	// Err is the estimation error, e.g. a revert the transaction would have been mined with.
+	Err  error
+	Time time.Time
+}
+
+// SimulationRecorder receives the transactions withheld in simulation mode.
+type SimulationRecorder interface {
+	RecordSimulatedTx(tx SimulatedTx)
+}
+
+// AttachSimulationMode switches the client into simulation mode: every write is signed and estimated against
+// the current chain state as usual, but handed to the recorder instead of being broadcast.
+// The write methods return the signed transactions as if they were sent, so staging environments can run against mainnet state.
+// Not thread safe, call before writing.
+func (bc *Blockchain) AttachSimulationMode(recorder SimulationRecorder) {
+	bc.simulation = recorder
+}
+
+// transactBackend returns the backend the write paths send transactions through.
+func (bc *Blockchain) transactBackend() bind.ContractBackend {
+	if bc.simulation == nil {
+		return bc.ethClient.Client()
+	}
+	return &simulatingBackend{ContractBackend: bc.ethClient.Client(), recorder: bc.simulation, bc: bc}
+}
+
+// simulatingBackend estimates and records the transactions instead of sending them.
+type simulatingBackend struct {
+	bind.ContractBackend
+	recorder SimulationRecorder
+	bc       *Blockchain
+}
+
+func (sb *simulatingBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	rec := SimulatedTx{
+		Hash:     tx.Hash(),
+		To:       tx.To(),
+		Data:     tx.Data(),
+		Value:    tx.Value(),
+		Gas:      tx.Gas(),
+		GasPrice: tx.GasPrice(),
+		Nonce:    tx.Nonce(),
+		Time:     time.Now(),
+	}
+
+	var signer types.Signer = types.HomesteadSigner{}
+	if tx.Protected() {
+		signer = types.NewEIP155Signer(tx.ChainId())
+	}
+	rec.From, rec.Err = types.Sender(signer, tx)
+	if rec.Err == nil {
+		msg := ethereum.CallMsg{
+			From:     rec.From,
+			To:       rec.To,
+			GasPrice: rec.GasPrice,
+			Value:    rec.Value,
+			Data:     rec.Data,
+		}
+		rec.EstimatedGas, rec.Err = sb.EstimateGas(ctx, msg)
+		rec.Err = withRevertTrace(toRevertError(rec.Err), func() (*CallTrace, error) {
+			return sb.bc.TraceCall(msg)
+		})
+	}
+
+	sb.recorder.RecordSimulatedTx(rec)
+	return nil
+}
+
+// SimulationLog keeps the simulated transactions in memory.
+type SimulationLog struct {
+	lock sync.Mutex
+	txs  []SimulatedTx
+}
+
+// NewSimulationLog returns a new in memory simulation log.
+func NewSimulationLog() *SimulationLog {
+	return &SimulationLog{}
+}
+
+// RecordSimulatedTx appends the transaction to the log.
+func (sl *SimulationLog) RecordSimulatedTx(tx SimulatedTx) {
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+	sl.txs = append(sl.txs, tx)
+}
+
+// Transactions returns the simulated transactions, oldest first.
+func (sl *SimulationLog) Transactions() []SimulatedTx {
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+	return append([]SimulatedTx(nil), sl.txs...)
+}
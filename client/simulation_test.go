@@ -0,0 +1,87 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type estimatingBackend struct {
+	bind.ContractBackend
+	err error
+	msg ethereum.CallMsg
+}
+
+func (eb *estimatingBackend) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	eb.msg = msg
+	if eb.err != nil {
+		return 0, eb.err
+	}
+	return 21000, nil
+}
+
+func TestSimulatingBackendRecordsInsteadOfSending(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	to := common.HexToAddress("0x1")
+
+	tx := types.NewTransaction(3, to, big.NewInt(5), 50000, big.NewInt(10), []byte{1, 2})
+	signed, err := types.SignTx(tx, types.NewEIP155Signer(big.NewInt(5)), key)
+	assert.NoError(t, err)
+
+	backend := &estimatingBackend{}
+	simLog := NewSimulationLog()
+	sb := &simulatingBackend{ContractBackend: backend, recorder: simLog}
+	assert.NoError(t, sb.SendTransaction(context.Background(), signed))
+
+	txs := simLog.Transactions()
+	assert.Len(t, txs, 1)
+	assert.Equal(t, signed.Hash(), txs[0].Hash)
+	assert.Equal(t, from, txs[0].From)
+	assert.Equal(t, &to, txs[0].To)
+	assert.Equal(t, []byte{1, 2}, txs[0].Data)
+	assert.Equal(t, uint64(50000), txs[0].Gas)
+	assert.Equal(t, uint64(3), txs[0].Nonce)
+	assert.Equal(t, uint64(21000), txs[0].EstimatedGas)
+	assert.NoError(t, txs[0].Err)
+	assert.Equal(t, from, backend.msg.From)
+
+	backend.err = errors.New("execution reverted")
+	assert.NoError(t, sb.SendTransaction(context.Background(), signed))
+	assert.Error(t, simLog.Transactions()[1].Err)
+}
+
+func TestTransactBackend(t *testing.T) {
+	bc := NewBlockchain(plainEthClient{}, time.Second)
+	_, simulating := bc.transactBackend().(*simulatingBackend)
+	assert.False(t, simulating)
+
+	bc.AttachSimulationMode(NewSimulationLog())
+	_, simulating = bc.transactBackend().(*simulatingBackend)
+	assert.True(t, simulating)
+}
@@ -0,0 +1,134 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/rs/zerolog/log"
+)
+
+// SubscriptionMeta describes what a subscription is listening to.
+type SubscriptionMeta struct {
+	// Event is the name of the contract event, e.g. PromiseSettled.
+	Event    string
+	Contract common.Address
+	// ChainID is only known for subscriptions made through the multichain client.
+	ChainID int64
+	Started time.Time
+}
+
+// SubscriptionObserver is notified about the subscription lifecycle, e.g. to export metrics.
+// The labels identify the subscription and are suitable as metric labels.
+type SubscriptionObserver interface {
+	SubscriptionStarted(labels map[string]string)
+	SubscriptionEnded(labels map[string]string, err error)
+}
+
+// Subscription is an event subscription returned by the SubscribeTo* methods.
+// The event sink is closed before Done is closed.
+type Subscription struct {
+	meta SubscriptionMeta
+	sub  event.Subscription
+	err  chan error
+	done chan struct{}
+}
+
+// AttachSubscriptionObserver attaches an observer notified about every subscription made.
+// Not thread safe, call before subscribing.
+func (bc *Blockchain) AttachSubscriptionObserver(o SubscriptionObserver) {
+	bc.subscriptionObserver = o
+}
+
+// newSubscription wraps the underlying subscription. closeSink is called once the subscription ends.
+func (bc *Blockchain) newSubscription(eventName string, contract common.Address, sub event.Subscription, closeSink func()) *Subscription {
+	s := &Subscription{
+		meta: SubscriptionMeta{
+			Event:    eventName,
+			Contract: contract,
+			Started:  time.Now(),
+		},
+		sub:  sub,
+		err:  make(chan error, 1),
+		done: make(chan struct{}),
+	}
+
+	s.watch(bc.subscriptionObserver, closeSink)
+	return s
+}
+
+// watch waits for the underlying subscription to end, then closes the sink and reports the error.
+func (s *Subscription) watch(observer SubscriptionObserver, closeSink func()) {
+	if observer != nil {
+		observer.SubscriptionStarted(s.Labels())
+	}
+
+	go func() {
+		err := <-s.sub.Err()
+		closeSink()
+		if err != nil {
+			log.Error().Err(err).Str("event", s.meta.Event).Msg("subscription error")
+			s.err <- err
+		}
+		close(s.err)
+		if observer != nil {
+			observer.SubscriptionEnded(s.Labels(), err)
+		}
+		close(s.done)
+	}()
+}
+
+// Unsubscribe cancels the subscription and waits until the event sink is closed.
+// It can be called multiple times.
+func (s *Subscription) Unsubscribe() {
+	s.sub.Unsubscribe()
+	<-s.done
+}
+
+// Err returns a channel receiving the error that ended the subscription, if any.
+// The channel is closed once the subscription ends.
+func (s *Subscription) Err() <-chan error {
+	return s.err
+}
+
+// Done returns a channel closed once the subscription has ended and the event sink was closed.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Meta returns the subscription metadata.
+func (s *Subscription) Meta() SubscriptionMeta {
+	return s.meta
+}
+
+// Labels returns the metric labels identifying the subscription.
+func (s *Subscription) Labels() map[string]string {
+	return map[string]string{
+		"event":    s.meta.Event,
+		"contract": s.meta.Contract.Hex(),
+	}
+}
+
+// withChainID sets the chain id of subscriptions made through the multichain client.
+func withChainID(s *Subscription, chainID int64) *Subscription {
+	if s != nil {
+		s.meta.ChainID = chainID
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockObserver struct {
+	lock    sync.Mutex
+	started int
+	ended   []error
+}
+
+func (mo *mockObserver) SubscriptionStarted(labels map[string]string) {
+	mo.lock.Lock()
+	defer mo.lock.Unlock()
+	mo.started++
+}
+
+func (mo *mockObserver) SubscriptionEnded(labels map[string]string, err error) {
+	mo.lock.Lock()
+	defer mo.lock.Unlock()
+	mo.ended = append(mo.ended, err)
+}
+
+func TestSubscription(t *testing.T) {
+	observer := &mockObserver{}
+	bc := &Blockchain{}
+	bc.AttachSubscriptionObserver(observer)
+
+	sink := make(chan int)
+	inner := event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+	sub := bc.newSubscription("Transfer", common.HexToAddress("0x1"), inner, func() { close(sink) })
+	assert.Equal(t, "Transfer", sub.Labels()["event"])
+
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	_, open := <-sink
+	assert.False(t, open)
+	<-sub.Done()
+	assert.NoError(t, <-sub.Err())
+
+	failure := errors.New("connection lost")
+	inner = event.NewSubscription(func(quit <-chan struct{}) error {
+		return failure
+	})
+	sub = withChainID(bc.newSubscription("Transfer", common.Address{}, inner, func() {}), 5)
+	assert.Equal(t, failure, <-sub.Err())
+	<-sub.Done()
+	assert.Equal(t, int64(5), sub.Meta().ChainID)
+
+	observer.lock.Lock()
+	defer observer.lock.Unlock()
+	assert.Equal(t, 2, observer.started)
+	assert.Equal(t, []error{nil, failure}, observer.ended)
+}
@@ -0,0 +1,376 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ErrTracingUnsupported is returned when the connected node exposes none of the supported trace APIs.
+var ErrTracingUnsupported = errors.New("node does not support tracing")
+
+// methodNotFoundCode is the json-rpc error code of calls to unknown methods.
+const methodNotFoundCode = -32601
+
+// CallTrace is a traced call and the internal calls it made.
+type CallTrace struct {
+	// Type is the call type, e.g. CALL, DELEGATECALL, STATICCALL or CREATE.
+	Type   string
+	From   common.Address
+	To     common.Address
+	Input  []byte
+	Output []byte
+	// Error is the failure reported by the node, e.g. "execution reverted". It is empty for successful calls.
+	Error string
+	// RevertReason is the message of the failed require(), if the call reverted with one.
+	RevertReason string
+	Calls        []CallTrace
+}
+
+// FailurePath returns the failed calls from the outer call down to the innermost failed call,
+// which is the call whose require() failed. It is empty if the call succeeded.
+func (ct *CallTrace) FailurePath() []*CallTrace {
+	if ct.Error == "" {
+		return nil
+	}
+
+	path := []*CallTrace{ct}
+	for i := len(ct.Calls) - 1; i >= 0; i-- {
+		if ct.Calls[i].Error != "" {
+			return append(path, ct.Calls[i].FailurePath()...)
+		}
+	}
+	return path
+}
+
+// FailedCall returns the innermost failed call, nil if the call succeeded.
+func (ct *CallTrace) FailedCall() *CallTrace {
+	path := ct.FailurePath()
+	if len(path) == 0 {
+		return nil
+	}
+	return path[len(path)-1]
+}
+
+// String describes the failure, e.g. `call to 0x..1 reverted: "insufficient balance" (via 0x..2)`.
+func (ct *CallTrace) String() string {
+	failed := ct.FailedCall()
+	if failed == nil {
+		return fmt.Sprintf("%v to %v succeeded", strings.ToLower(ct.Type), ct.To.Hex())
+	}
+
+	res := fmt.Sprintf("%v to %v failed: %v", strings.ToLower(failed.Type), failed.To.Hex(), failed.Error)
+	if failed.RevertReason != "" {
+		res += fmt.Sprintf(" %q", failed.RevertReason)
+	}
+	if failed != ct {
+		res += fmt.Sprintf(" (via %v)", ct.To.Hex())
+	}
+	return res
+}
+
+// traceMethod is a trace api method and the conversion of its result.
+type traceMethod struct {
+	name   string
+	params func(target interface{}) []interface{}
+	decode func(json.RawMessage) (*CallTrace, error)
+}
+
+// traceDetector remembers the trace methods the node does not support, so they are not called again.
+// The zero value is ready to use.
+type traceDetector struct {
+	lock        sync.Mutex
+	unsupported map[string]bool
+}
+
+func (td *traceDetector) supported(method string) bool {
+	td.lock.Lock()
+	defer td.lock.Unlock()
+	return !td.unsupported[method]
+}
+
+func (td *traceDetector) markUnsupported(method string) {
+	td.lock.Lock()
+	defer td.lock.Unlock()
+	if td.unsupported == nil {
+		td.unsupported = make(map[string]bool)
+	}
+	td.unsupported[method] = true
+}
+
+// trace calls the first method the node supports.
+func (td *traceDetector) trace(ctx context.Context, client ethClientGetter, target interface{}, methods ...traceMethod) (*CallTrace, error) {
+	getter, ok := client.(rpcClientGetter)
+	if !ok {
+		return nil, ErrTracingUnsupported
+	}
+
+	for _, m := range methods {
+		if !td.supported(m.name) {
+			continue
+		}
+
+		var res json.RawMessage
+		err := getter.RPCClient().CallContext(ctx, &res, m.name, m.params(target)...)
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == methodNotFoundCode {
+			td.markUnsupported(m.name)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not trace with %v: %w", m.name, err)
+		}
+		return m.decode(res)
+	}
+
+	return nil, ErrTracingUnsupported
+}
+
+var callTracer = map[string]string{"tracer": "callTracer"}
+
+var traceCallMethods = []traceMethod{
+	{
+		name:   "debug_traceCall",
+		params: func(msg interface{}) []interface{} { return []interface{}{msg, "latest", callTracer} },
+		decode: decodeCallTracer,
+	},
+	{
+		name:   "trace_call",
+		params: func(msg interface{}) []interface{} { return []interface{}{msg, []string{"trace"}, "latest"} },
+		decode: decodeParityTraceCall,
+	},
+}
+
+var traceTransactionMethods = []traceMethod{
+	{
+		name:   "debug_traceTransaction",
+		params: func(hash interface{}) []interface{} { return []interface{}{hash, callTracer} },
+		decode: decodeCallTracer,
+	},
+	{
+		name:   "trace_transaction",
+		params: func(hash interface{}) []interface{} { return []interface{}{hash} },
+		decode: decodeParityFrames,
+	},
+}
+
+// TraceCall traces the call on the latest block with debug_traceCall or trace_call, whichever the node supports.
+func (bc *Blockchain) TraceCall(msg ethereum.CallMsg) (*CallTrace, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+	return bc.tracing.trace(ctx, bc.ethClient, toTraceCallArg(msg), traceCallMethods...)
+}
+
+// TraceTransaction traces the mined transaction with debug_traceTransaction or trace_transaction, whichever the node supports.
+func (bc *Blockchain) TraceTransaction(hash common.Hash) (*CallTrace, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+	return bc.tracing.trace(ctx, bc.ethClient, hash, traceTransactionMethods...)
+}
+
+// withRevertTrace attaches the trace of the call to the revert error, if the node supports tracing.
+// Errors other than reverts and failed traces leave the error as it is.
+func withRevertTrace(err error, trace func() (*CallTrace, error)) error {
+	var reverted *ErrorTransactionReverted
+	if !errors.As(err, &reverted) {
+		return err
+	}
+
+	if t, terr := trace(); terr == nil {
+		reverted.Trace = t
+	}
+	return err
+}
+
+func toTraceCallArg(msg ethereum.CallMsg) map[string]interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+		"data": hexutil.Bytes(msg.Data),
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+	return arg
+}
+
+// callTracerFrame is a call in the result of the geth callTracer.
+type callTracerFrame struct {
+	Type         string            `json:"type"`
+	From         common.Address    `json:"from"`
+	To           common.Address    `json:"to"`
+	Input        hexutil.Bytes     `json:"input"`
+	Output       hexutil.Bytes     `json:"output"`
+	Error        string            `json:"error"`
+	RevertReason string            `json:"revertReason"`
+	Calls        []callTracerFrame `json:"calls"`
+}
+
+func (f callTracerFrame) toCallTrace() CallTrace {
+	ct := CallTrace{
+		Type:         f.Type,
+		From:         f.From,
+		To:           f.To,
+		Input:        f.Input,
+		Output:       f.Output,
+		Error:        f.Error,
+		RevertReason: f.RevertReason,
+	}
+	if ct.RevertReason == "" && ct.Error != "" {
+		ct.RevertReason = unpackRevertReason(ct.Output)
+	}
+	for _, c := range f.Calls {
+		ct.Calls = append(ct.Calls, c.toCallTrace())
+	}
+	return ct
+}
+
+func decodeCallTracer(res json.RawMessage) (*CallTrace, error) {
+	var frame callTracerFrame
+	if err := json.Unmarshal(res, &frame); err != nil {
+		return nil, fmt.Errorf("could not decode call trace: %w", err)
+	}
+	ct := frame.toCallTrace()
+	return &ct, nil
+}
+
+// parityFrame is a call in the flat trace format of the trace_ api of openethereum and erigon.
+type parityFrame struct {
+	Action struct {
+		CallType string         `json:"callType"`
+		From     common.Address `json:"from"`
+		To       common.Address `json:"to"`
+		Input    hexutil.Bytes  `json:"input"`
+	} `json:"action"`
+	Result *struct {
+		Output hexutil.Bytes `json:"output"`
+	} `json:"result"`
+	Error        string `json:"error"`
+	TraceAddress []int  `json:"traceAddress"`
+	Type         string `json:"type"`
+}
+
+func decodeParityTraceCall(res json.RawMessage) (*CallTrace, error) {
+	var result struct {
+		Output hexutil.Bytes `json:"output"`
+		Trace  []parityFrame `json:"trace"`
+	}
+	if err := json.Unmarshal(res, &result); err != nil {
+		return nil, fmt.Errorf("could not decode call trace: %w", err)
+	}
+
+	ct, err := parityFramesToCallTrace(result.Trace)
+	if err != nil {
+		return nil, err
+	}
+	// the revert data of the outer call is only reported as the output of the whole call
+	if ct.Error != "" && len(ct.Output) == 0 {
+		ct.Output = result.Output
+		ct.RevertReason = unpackRevertReason(result.Output)
+	}
+	return ct, nil
+}
+
+func decodeParityFrames(res json.RawMessage) (*CallTrace, error) {
+	var frames []parityFrame
+	if err := json.Unmarshal(res, &frames); err != nil {
+		return nil, fmt.Errorf("could not decode call trace: %w", err)
+	}
+	return parityFramesToCallTrace(frames)
+}
+
+// parityFramesToCallTrace builds the call tree from the trace addresses of the flat frames.
+func parityFramesToCallTrace(frames []parityFrame) (*CallTrace, error) {
+	if len(frames) == 0 {
+		return nil, errors.New("empty call trace")
+	}
+
+	// parents sort before their children, siblings in call order
+	sort.SliceStable(frames, func(i, j int) bool {
+		a, b := frames[i].TraceAddress, frames[j].TraceAddress
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	var root *CallTrace
+	for _, f := range frames {
+		ct := CallTrace{
+			Type:  strings.ToUpper(f.Action.CallType),
+			From:  f.Action.From,
+			To:    f.Action.To,
+			Input: f.Action.Input,
+			Error: f.Error,
+		}
+		if ct.Type == "" {
+			ct.Type = strings.ToUpper(f.Type)
+		}
+		if f.Result != nil {
+			ct.Output = f.Result.Output
+		}
+		if ct.Error != "" {
+			ct.RevertReason = unpackRevertReason(ct.Output)
+		}
+
+		if len(f.TraceAddress) == 0 {
+			root = &ct
+			continue
+		}
+		if root == nil {
+			return nil, errors.New("call trace has no outer call")
+		}
+
+		parent := root
+		for _, idx := range f.TraceAddress[:len(f.TraceAddress)-1] {
+			if idx >= len(parent.Calls) {
+				return nil, fmt.Errorf("call trace misses the parent of call %v", f.TraceAddress)
+			}
+			parent = &parent.Calls[idx]
+		}
+		parent.Calls = append(parent.Calls, ct)
+	}
+	return root, nil
+}
+
+func unpackRevertReason(output []byte) string {
+	reason, err := abi.UnpackRevert(output)
+	if err != nil {
+		return ""
+	}
+	return reason
+}
@@ -0,0 +1,169 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// revertData encodes the Error(string) revert of require().
+func revertData(reason string) hexutil.Bytes {
+	str, _ := abi.NewType("string", "", nil)
+	packed, _ := abi.Arguments{{Type: str}}.Pack(reason)
+	return append(crypto.Keccak256([]byte("Error(string)"))[:4], packed...)
+}
+
+type debugTraceService struct{}
+
+func (debugTraceService) TraceCall(args map[string]interface{}, block string, cfg map[string]string) (json.RawMessage, error) {
+	return json.Marshal(map[string]interface{}{
+		"type":   "CALL",
+		"from":   args["from"],
+		"to":     args["to"],
+		"input":  args["data"],
+		"output": revertData("outer"),
+		"error":  "execution reverted",
+		"calls": []map[string]interface{}{
+			{"type": "STATICCALL", "to": "0x0000000000000000000000000000000000000003", "input": "0x"},
+			{"type": "CALL", "to": "0x0000000000000000000000000000000000000004", "input": "0x", "output": revertData("not enough balance"), "error": "execution reverted"},
+		},
+	})
+}
+
+type parityTraceService struct {
+	calls int
+}
+
+func (pts *parityTraceService) Call(args map[string]interface{}, types []string, block string) (json.RawMessage, error) {
+	pts.calls++
+	return json.Marshal(map[string]interface{}{
+		"output": revertData("outer"),
+		"trace": []map[string]interface{}{
+			{"action": map[string]interface{}{"callType": "call", "to": "0x0000000000000000000000000000000000000004", "input": "0x"}, "error": "Reverted", "traceAddress": []int{0, 1}, "type": "call"},
+			{"action": map[string]interface{}{"callType": "call", "to": args["to"], "input": args["data"]}, "error": "Reverted", "traceAddress": []int{}, "type": "call"},
+			{"action": map[string]interface{}{"callType": "delegatecall", "to": "0x0000000000000000000000000000000000000003", "input": "0x"}, "error": "Reverted", "traceAddress": []int{0}, "type": "call"},
+			{"action": map[string]interface{}{"callType": "staticcall", "to": "0x0000000000000000000000000000000000000005", "input": "0x"}, "result": map[string]interface{}{"output": "0x"}, "traceAddress": []int{0, 0}, "type": "call"},
+		},
+	})
+}
+
+type revertingEthService struct{}
+
+type revertError struct{}
+
+func (revertError) Error() string  { return "execution reverted: outer" }
+func (revertError) ErrorCode() int { return 3 }
+
+func (revertingEthService) EstimateGas(args map[string]interface{}) (hexutil.Uint64, error) {
+	return 0, revertError{}
+}
+
+func newTraceClient(t *testing.T, services map[string]interface{}) (*ReconnectableEthClient, *rpc.Server) {
+	server := rpc.NewServer()
+	for name, svc := range services {
+		assert.NoError(t, server.RegisterName(name, svc))
+	}
+	client, err := NewReconnectableEthClientWithDialer(InProcDialer(server))
+	assert.NoError(t, err)
+	return client, server
+}
+
+func TestTraceCall(t *testing.T) {
+	to := common.HexToAddress("0x2")
+	msg := ethereum.CallMsg{From: common.HexToAddress("0x1"), To: &to, Data: []byte{1}}
+
+	t.Run("debug_traceCall", func(t *testing.T) {
+		client, server := newTraceClient(t, map[string]interface{}{"debug": debugTraceService{}})
+		defer server.Stop()
+
+		trace, err := NewBlockchain(client, time.Second).TraceCall(msg)
+		assert.NoError(t, err)
+		assert.Equal(t, "outer", trace.RevertReason)
+		assert.Len(t, trace.FailurePath(), 2)
+		assert.Equal(t, common.HexToAddress("0x4"), trace.FailedCall().To)
+		assert.Equal(t, "not enough balance", trace.FailedCall().RevertReason)
+		assert.Equal(t, `call to `+common.HexToAddress("0x4").Hex()+` failed: execution reverted "not enough balance" (via `+to.Hex()+`)`, trace.String())
+	})
+
+	t.Run("trace_call is used if debug_traceCall is not available", func(t *testing.T) {
+		svc := &parityTraceService{}
+		client, server := newTraceClient(t, map[string]interface{}{"trace": svc})
+		defer server.Stop()
+
+		bc := NewBlockchain(client, time.Second)
+		trace, err := bc.TraceCall(msg)
+		assert.NoError(t, err)
+		assert.Equal(t, "CALL", trace.Type)
+		assert.Equal(t, to, trace.To)
+		assert.Equal(t, "outer", trace.RevertReason)
+		if assert.Len(t, trace.Calls, 1) {
+			assert.Len(t, trace.Calls[0].Calls, 2)
+		}
+		path := trace.FailurePath()
+		assert.Len(t, path, 3)
+		assert.Equal(t, "DELEGATECALL", path[1].Type)
+		assert.Equal(t, common.HexToAddress("0x4"), trace.FailedCall().To)
+
+		_, err = bc.TraceCall(msg)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, svc.calls)
+		assert.False(t, bc.tracing.supported("debug_traceCall"))
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		client, server := newTraceClient(t, map[string]interface{}{"net": netService{}})
+		defer server.Stop()
+
+		_, err := NewBlockchain(client, time.Second).TraceCall(msg)
+		assert.True(t, errors.Is(err, ErrTracingUnsupported))
+	})
+}
+
+func TestDryRunAttachesRevertTrace(t *testing.T) {
+	client, server := newTraceClient(t, map[string]interface{}{"eth": revertingEthService{}, "debug": debugTraceService{}})
+	defer server.Stop()
+
+	err := NewWithDryRuns(nil, client).DryRun(TransferRequest{
+		MystAddress: common.HexToAddress("0x2"),
+		Recipient:   common.HexToAddress("0x3"),
+		Amount:      big.NewInt(1),
+		WriteRequest: WriteRequest{
+			Identity: common.HexToAddress("0x1"),
+			GasLimit: 100000,
+		},
+	})
+
+	var reverted *ErrorTransactionReverted
+	if assert.True(t, errors.As(err, &reverted)) {
+		assert.Equal(t, "outer", reverted.Reason)
+		if assert.NotNil(t, reverted.Trace) {
+			assert.Equal(t, "not enough balance", reverted.Trace.FailedCall().RevertReason)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/mysteriumnetwork/payments/bindings"
+	pc "github.com/mysteriumnetwork/payments/crypto"
+)
+
+// versionSelector is the selector of the `version()` getter.
+var versionSelector = crypto.Keccak256([]byte("version()"))[:4]
+
+// ErrPromiseVersionMismatch is returned when the promise is not signed by the contract operator
+// under the encoding rules of the contract version, so the contract would reject it.
+var ErrPromiseVersionMismatch = errors.New("promise does not match the contract version")
+
+// ContractVersionDetector detects the versions of deployed hermes and channel contracts.
+// The version is read from a `version()` getter if the contract has one,
+// otherwise the code hash of the implementation is matched against the registered hashes.
+// Detected versions are cached until Forget is called, e.g. on a proxy upgrade.
+// The detector also resolves the domain separators of the deployment it is connected to from the contract version.
+type ContractVersionDetector struct {
+	ethClient      ethClientGetter
+	timeout        time.Duration
+	fallback       pc.ContractVersion
+	implementation func(address common.Address) (common.Address, error)
+
+	lock       sync.Mutex
+	codeHashes map[common.Hash]pc.ContractVersion
+	cache      map[common.Address]pc.ContractVersion
+	domains    map[pc.ContractVersion]pc.DomainSeparators
+}
+
+// NewContractVersionDetector returns a new contract version detector.
+// The fallback version is returned for contracts that could not be identified,
+// pass crypto.ContractVersionUnknown to get an error instead.
+func NewContractVersionDetector(ethClient ethClientGetter, timeout time.Duration, fallback pc.ContractVersion) *ContractVersionDetector {
+	bc := NewBlockchain(ethClient, timeout)
+	return &ContractVersionDetector{
+		ethClient:      ethClient,
+		timeout:        timeout,
+		fallback:       fallback,
+		implementation: bc.GetProxyImplementation,
+		codeHashes:     make(map[common.Hash]pc.ContractVersion),
+		cache:          make(map[common.Address]pc.ContractVersion),
+		domains:        make(map[pc.ContractVersion]pc.DomainSeparators),
+	}
+}
+
+// RegisterCodeHash registers the runtime code hash of an implementation with a known version.
+func (cvd *ContractVersionDetector) RegisterCodeHash(codeHash common.Hash, version pc.ContractVersion) {
+	cvd.lock.Lock()
+	defer cvd.lock.Unlock()
+	cvd.codeHashes[codeHash] = version
+}
+
+// RegisterDomainSeparators sets the prefixes signed messages use with the contracts of the given version,
+// e.g. for forks or test deployments. Contracts of versions without registered prefixes use crypto.DefaultDomainSeparators.
+func (cvd *ContractVersionDetector) RegisterDomainSeparators(version pc.ContractVersion, ds pc.DomainSeparators) {
+	cvd.lock.Lock()
+	defer cvd.lock.Unlock()
+	cvd.domains[version] = ds
+}
+
+// GetDomainSeparators returns the prefixes signed messages use with the contract at the given address.
+// The result should be set as the Domain of exit, stake return and promise invalidation messages for the contract.
+func (cvd *ContractVersionDetector) GetDomainSeparators(address common.Address) (pc.DomainSeparators, error) {
+	v, err := cvd.GetContractVersion(address)
+	if err != nil {
+		return pc.DomainSeparators{}, err
+	}
+
+	cvd.lock.Lock()
+	defer cvd.lock.Unlock()
+	if ds, ok := cvd.domains[v]; ok {
+		return ds, nil
+	}
+	return pc.DefaultDomainSeparators, nil
+}
+
+// Forget removes the cached version of the given contract.
+func (cvd *ContractVersionDetector) Forget(address common.Address) {
+	cvd.lock.Lock()
+	defer cvd.lock.Unlock()
+	delete(cvd.cache, address)
+}
+
+// GetContractVersion returns the version of the contract at the given address.
+// The lock is not held while the contract is queried, so concurrent callers might detect the same contract twice.
+func (cvd *ContractVersionDetector) GetContractVersion(address common.Address) (pc.ContractVersion, error) {
+	cvd.lock.Lock()
+	v, ok := cvd.cache[address]
+	cvd.lock.Unlock()
+	if ok {
+		return v, nil
+	}
+
+	v, err := cvd.detect(address)
+	if err != nil {
+		return pc.ContractVersionUnknown, err
+	}
+
+	if !v.Supported() {
+		if !cvd.fallback.Supported() {
+			return pc.ContractVersionUnknown, fmt.Errorf("%w: %v at %v", pc.ErrUnsupportedContractVersion, v, address.Hex())
+		}
+		v = cvd.fallback
+	}
+
+	cvd.lock.Lock()
+	defer cvd.lock.Unlock()
+	cvd.cache[address] = v
+	return v, nil
+}
+
+func (cvd *ContractVersionDetector) detect(address common.Address) (pc.ContractVersion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cvd.timeout)
+	defer cancel()
+
+	res, err := cvd.ethClient.Client().CallContract(ctx, ethereum.CallMsg{To: &address, Data: versionSelector}, nil)
+	if err == nil && len(res) == 32 {
+		return pc.ContractVersion(new(big.Int).SetBytes(res).Uint64()), nil
+	}
+
+	impl, err := cvd.implementation(address)
+	if errors.Is(err, ErrNotProxy) {
+		impl = address
+	} else if err != nil {
+		return pc.ContractVersionUnknown, err
+	}
+
+	code, err := cvd.ethClient.Client().CodeAt(ctx, impl, nil)
+	if err != nil {
+		return pc.ContractVersionUnknown, fmt.Errorf("could not get code: %w", err)
+	}
+
+	cvd.lock.Lock()
+	defer cvd.lock.Unlock()
+	return cvd.codeHashes[crypto.Keccak256Hash(code)], nil
+}
+
+// AttachContractVersionDetector makes the settle methods check the promise against the encoding rules
+// of the detected contract version before sending it, so promises signed for another version do not fail on chain.
+// Not thread safe, call before sending any transactions.
+func (bc *Blockchain) AttachContractVersionDetector(cvd *ContractVersionDetector) {
+	bc.versions = cvd
+}
+
+// checkHermesPromise checks that the promise is signed by the hermes operator for the hermes contract version.
+func (bc *Blockchain) checkHermesPromise(hermesID common.Address, p pc.Promise) error {
+	if bc.versions == nil {
+		return nil
+	}
+	return bc.checkPromiseVersion(hermesID, p, bc.GetHermesOperator)
+}
+
+// checkChannelPromise checks that the promise is signed by the channel operator for the channel contract version.
+func (bc *Blockchain) checkChannelPromise(channelID common.Address, p pc.Promise) error {
+	if bc.versions == nil {
+		return nil
+	}
+	return bc.checkPromiseVersion(channelID, p, bc.getChannelOperator)
+}
+
+func (bc *Blockchain) checkPromiseVersion(contract common.Address, p pc.Promise, operator func(common.Address) (common.Address, error)) error {
+	v, err := bc.versions.GetContractVersion(contract)
+	if err != nil {
+		return wrap(err, "could not get contract version")
+	}
+	expected, err := operator(contract)
+	if err != nil {
+		return wrap(err, "could not get contract operator")
+	}
+	signer, err := p.RecoverSignerForVersion(v)
+	if err != nil || signer != expected {
+		return fmt.Errorf("%w: %v at %v", ErrPromiseVersionMismatch, v, contract.Hex())
+	}
+	return nil
+}
+
+func (bc *Blockchain) getChannelOperator(channelID common.Address) (common.Address, error) {
+	caller, err := bindings.NewChannelImplementationCaller(channelID, bc.ethClient.Client())
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bc.bcTimeout)
+	defer cancel()
+	return caller.Operator(&bind.CallOpts{
+		Pending: false,
+		Context: ctx,
+	})
+}
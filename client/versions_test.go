@@ -0,0 +1,149 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	pc "github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// versionService answers eth_call of the version() getter.
+type versionService struct {
+	version int64
+	calls   int64
+}
+
+func (s *versionService) Call(args map[string]interface{}, block string) (hexutil.Bytes, error) {
+	atomic.AddInt64(&s.calls, 1)
+	return common.LeftPadBytes(big.NewInt(s.version).Bytes(), 32), nil
+}
+
+func TestContractVersionDetectorDomainSeparators(t *testing.T) {
+	svc := &versionService{version: 2}
+	client, server := newTraceClient(t, map[string]interface{}{"eth": svc})
+	defer server.Stop()
+
+	cvd := NewContractVersionDetector(client, time.Second, pc.ContractVersionUnknown)
+	hermes := common.HexToAddress("0x1")
+
+	ds, err := cvd.GetDomainSeparators(hermes)
+	assert.NoError(t, err)
+	assert.Equal(t, pc.DefaultDomainSeparators, ds)
+
+	fork := pc.DomainSeparators{Exit: "Fork exit:"}
+	cvd.RegisterDomainSeparators(pc.ContractVersionV2, fork)
+	ds, err = cvd.GetDomainSeparators(hermes)
+	assert.NoError(t, err)
+	assert.Equal(t, fork, ds)
+
+	// the version is cached
+	assert.Equal(t, int64(1), atomic.LoadInt64(&svc.calls))
+}
+
+// blockingVersionService answers eth_call of the version() getter once released.
+type blockingVersionService struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingVersionService) Call(args map[string]interface{}, block string) (hexutil.Bytes, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return common.LeftPadBytes(big.NewInt(1).Bytes(), 32), nil
+}
+
+func TestContractVersionDetectorDoesNotLockDuringCalls(t *testing.T) {
+	svc := &blockingVersionService{started: make(chan struct{}, 1), release: make(chan struct{})}
+	client, server := newTraceClient(t, map[string]interface{}{"eth": svc})
+	defer server.Stop()
+
+	cvd := NewContractVersionDetector(client, time.Second, pc.ContractVersionUnknown)
+
+	done := make(chan error)
+	go func() {
+		_, err := cvd.GetContractVersion(common.HexToAddress("0x1"))
+		done <- err
+	}()
+	<-svc.started
+
+	// the cache and registrations are available while the contract is queried
+	cvd.RegisterCodeHash(common.HexToHash("0x1"), pc.ContractVersionV2)
+	cvd.Forget(common.HexToAddress("0x2"))
+
+	close(svc.release)
+	assert.NoError(t, <-done)
+	v, err := cvd.GetContractVersion(common.HexToAddress("0x1"))
+	assert.NoError(t, err)
+	assert.Equal(t, pc.ContractVersionV1, v)
+}
+
+// operatorService answers eth_call of the version() getter with v1 and every other call with the operator.
+type operatorService struct {
+	operator common.Address
+}
+
+func (s *operatorService) Call(args map[string]interface{}, block string) (hexutil.Bytes, error) {
+	data, _ := args["data"].(string)
+	if bytes.Equal(common.FromHex(data), versionSelector) {
+		return common.LeftPadBytes(big.NewInt(1).Bytes(), 32), nil
+	}
+	return common.LeftPadBytes(s.operator.Bytes(), 32), nil
+}
+
+func TestSettleChecksPromiseVersion(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	operator := crypto.PubkeyToAddress(key.PublicKey)
+	keys := multiKeySigner{operator: key}
+
+	client, server := newTraceClient(t, map[string]interface{}{"eth": &operatorService{operator: operator}})
+	defer server.Stop()
+
+	bc := NewBlockchain(client, time.Second)
+	bc.AttachContractVersionDetector(NewContractVersionDetector(client, time.Second, pc.ContractVersionUnknown))
+	hermes := common.HexToAddress("0x1")
+
+	p := pc.Promise{
+		ChannelID: common.HexToHash("0x2").Bytes(),
+		ChainID:   1,
+		Amount:    big.NewInt(10),
+		Fee:       big.NewInt(1),
+		Hashlock:  common.HexToHash("0x3").Bytes(),
+	}
+	p.Signature, err = p.CreateSignatureForVersion(pc.ContractVersionV2, keys, operator)
+	assert.NoError(t, err)
+
+	_, err = bc.SettleAndRebalance(SettleAndRebalanceRequest{HermesID: hermes, Promise: p})
+	assert.True(t, errors.Is(err, ErrPromiseVersionMismatch))
+	_, err = bc.SettlePromise(SettleRequest{ChannelID: hermes, Promise: p})
+	assert.True(t, errors.Is(err, ErrPromiseVersionMismatch))
+
+	p.Signature, err = p.CreateSignatureForVersion(pc.ContractVersionV1, keys, operator)
+	assert.NoError(t, err)
+	assert.NoError(t, bc.checkHermesPromise(hermes, p))
+	assert.NoError(t, bc.checkChannelPromise(hermes, p))
+}
@@ -17,6 +17,8 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"math/big"
 	"strings"
 	"time"
@@ -26,7 +28,6 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/mysteriumnetwork/payments/bindings"
-	"github.com/pkg/errors"
 )
 
 type Estimatable interface {
@@ -39,12 +40,22 @@ type Estimatable interface {
 type ErrorTransactionReverted struct {
 	Err    rpc.Error
 	Reason string
+	// Trace shows which internal call reverted. It is nil if the node does not support tracing.
+	Trace *CallTrace
 }
 
 func (e ErrorTransactionReverted) Error() string {
 	return e.Err.Error()
 }
 
+// Unwrap returns the rpc error the transaction was reverted with.
+func (e ErrorTransactionReverted) Unwrap() error {
+	return e.Err
+}
+
+// DefaultTraceTimeout limits the tracing of reverted dry runs.
+const DefaultTraceTimeout = 10 * time.Second
+
 // WithDryRuns forces a dry run before running a write transaction on blockchain.
 // Ethereum client will perform a dry run on a transaction with no gas limit set.
 // This component will perform a dry run if and only if the gas limit is set to a non zero value.
@@ -53,6 +64,8 @@ func (e ErrorTransactionReverted) Error() string {
 type WithDryRuns struct {
 	bc        BC
 	ethClient ethClientGetter
+
+	tracing traceDetector
 }
 
 // NewWithDryRuns creates a new instance of client with dry runs.
@@ -94,23 +107,52 @@ func (cwdr *WithDryRuns) Estimate(req Estimatable) (uint64, error) {
 	}
 
 	gas, err := estimator.Estimate(req.toEstimateOps())
-	return gas, errors.Wrap(err, "could not estimate gas")
+	return gas, wrap(err, "could not estimate gas")
 }
 
 // DryRun simulates the (paid) contract method with params as input values.
 func (cwdr *WithDryRuns) DryRun(req Estimatable) error {
 	_, err := cwdr.Estimate(req)
+	return cwdr.toTracedRevertError(req, err)
+}
+
+// toTracedRevertError extracts the error thrown in contract and attaches the call trace to it, if the node supports tracing.
+func (cwdr *WithDryRuns) toTracedRevertError(req Estimatable, err error) error {
+	return withRevertTrace(toRevertError(err), func() (*CallTrace, error) {
+		estimator, err := req.toEstimator(cwdr.ethClient)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := estimator.CallMsg(req.toEstimateOps())
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultTraceTimeout)
+		defer cancel()
+		return cwdr.tracing.trace(ctx, cwdr.ethClient, toTraceCallArg(msg), traceCallMethods...)
+	})
+}
+
+// toRevertError extracts the error thrown in contract, if any.
+func toRevertError(err error) error {
 	if err == nil {
 		return nil
 	}
 
-	// Extract error thrown in contract
-	if rpcCauseErr, hasCause := errors.Cause(err).(rpc.Error); hasCause {
+	var rpcCauseErr rpc.Error
+	if errors.As(err, &rpcCauseErr) {
 		if rpcCauseErr.ErrorCode() == -32000 && strings.Contains(rpcCauseErr.Error(), "VM Exception while processing transaction: revert") {
 			err = &ErrorTransactionReverted{
 				Err:    rpcCauseErr,
 				Reason: strings.TrimPrefix(rpcCauseErr.Error(), "VM Exception while processing transaction: revert "),
 			}
+		} else if strings.HasPrefix(rpcCauseErr.Error(), "execution reverted") {
+			// geth reports the reverts of estimations and calls this way
+			err = &ErrorTransactionReverted{
+				Err:    rpcCauseErr,
+				Reason: strings.TrimPrefix(strings.TrimPrefix(rpcCauseErr.Error(), "execution reverted"), ": "),
+			}
 		}
 	}
 
@@ -184,7 +226,7 @@ func (cwdr *WithDryRuns) IsRegistered(registryAddress, addressToCheck common.Add
 }
 
 // SubscribeToPromiseSettledEvent subscribes to promise settled events
-func (cwdr *WithDryRuns) SubscribeToPromiseSettledEvent(providerID, hermesID common.Address) (sink chan *bindings.HermesImplementationPromiseSettled, cancel func(), err error) {
+func (cwdr *WithDryRuns) SubscribeToPromiseSettledEvent(providerID, hermesID common.Address) (sink chan *bindings.HermesImplementationPromiseSettled, sub *Subscription, err error) {
 	return cwdr.bc.SubscribeToPromiseSettledEvent(providerID, hermesID)
 }
 
@@ -194,7 +236,7 @@ func (cwdr *WithDryRuns) GetMystBalance(mystSCAddress, address common.Address) (
 }
 
 // SubscribeToConsumerBalanceEvent subscribes to the consumer balance change events
-func (cwdr *WithDryRuns) SubscribeToConsumerBalanceEvent(channel, mystSCAddress common.Address, timeout time.Duration) (chan *bindings.MystTokenTransfer, func(), error) {
+func (cwdr *WithDryRuns) SubscribeToConsumerBalanceEvent(channel, mystSCAddress common.Address, timeout time.Duration) (chan *bindings.MystTokenTransfer, *Subscription, error) {
 	return cwdr.bc.SubscribeToConsumerBalanceEvent(channel, mystSCAddress, timeout)
 }
 
@@ -214,7 +256,7 @@ func (cwdr *WithDryRuns) GetConsumerChannelsHermes(channelAddress common.Address
 }
 
 // SubscribeToMystTokenTransfers subscribes to myst token transfers
-func (cwdr *WithDryRuns) SubscribeToMystTokenTransfers(mystSCAddress common.Address) (chan *bindings.MystTokenTransfer, func(), error) {
+func (cwdr *WithDryRuns) SubscribeToMystTokenTransfers(mystSCAddress common.Address) (chan *bindings.MystTokenTransfer, *Subscription, error) {
 	return cwdr.bc.SubscribeToMystTokenTransfers(mystSCAddress)
 }
 
@@ -233,18 +275,204 @@ func (cwdr *WithDryRuns) GetProviderChannelByID(acc common.Address, chID []byte)
 	return cwdr.bc.GetProviderChannelByID(acc, chID)
 }
 
+// PauseChannelOpening pauses channel opening in hermes.
+func (cwdr *WithDryRuns) PauseChannelOpening(req PauseChannelOpeningRequest) (*types.Transaction, error) {
+	if _, err := cwdr.Estimate(req); err != nil {
+		return nil, err
+	}
+
+	return cwdr.bc.PauseChannelOpening(req)
+}
+
+// ActivateChannelOpening resumes channel opening in hermes.
+func (cwdr *WithDryRuns) ActivateChannelOpening(req ActivateChannelOpeningRequest) (*types.Transaction, error) {
+	if _, err := cwdr.Estimate(req); err != nil {
+		return nil, err
+	}
+
+	return cwdr.bc.ActivateChannelOpening(req)
+}
+
+// WithdrawHermesBalance withdraws the available hermes balance.
+func (cwdr *WithDryRuns) WithdrawHermesBalance(req HermesWithdrawRequest) (*types.Transaction, error) {
+	if _, err := cwdr.Estimate(req); err != nil {
+		return nil, err
+	}
+
+	return cwdr.bc.WithdrawHermesBalance(req)
+}
+
+// GetLatestChannelImplementation returns the channel implementation new channels are deployed with.
+func (cwdr *WithDryRuns) GetLatestChannelImplementation(registryAddress common.Address) (common.Address, error) {
+	return cwdr.bc.GetLatestChannelImplementation(registryAddress)
+}
+
+// GetChannelExit returns the pending exit request of the consumer channel.
+func (cwdr *WithDryRuns) GetChannelExit(channelAddress common.Address) (ChannelExit, error) {
+	return cwdr.bc.GetChannelExit(channelAddress)
+}
+
+// RequestChannelExit starts the exit of all the channel funds to the beneficiary.
+func (cwdr *WithDryRuns) RequestChannelExit(req RequestChannelExitRequest) (*types.Transaction, error) {
+	if _, err := cwdr.Estimate(req); err != nil {
+		return nil, err
+	}
+
+	return cwdr.bc.RequestChannelExit(req)
+}
+
+// FinalizeChannelExit transfers the channel funds to the beneficiary of the requested exit.
+func (cwdr *WithDryRuns) FinalizeChannelExit(req FinalizeChannelExitRequest) (*types.Transaction, error) {
+	if _, err := cwdr.Estimate(req); err != nil {
+		return nil, err
+	}
+
+	return cwdr.bc.FinalizeChannelExit(req)
+}
+
+// SetHermesMinStake sets the minimal channel stake of hermes.
+func (cwdr *WithDryRuns) SetHermesMinStake(req SetHermesMinStakeRequest) (*types.Transaction, error) {
+	if _, err := cwdr.Estimate(req); err != nil {
+		return nil, err
+	}
+
+	return cwdr.bc.SetHermesMinStake(req)
+}
+
+// SetHermesFundsDestination sets the address that receives the funds claimed from hermes.
+func (cwdr *WithDryRuns) SetHermesFundsDestination(req SetHermesFundsDestinationRequest) (*types.Transaction, error) {
+	if _, err := cwdr.Estimate(req); err != nil {
+		return nil, err
+	}
+
+	return cwdr.bc.SetHermesFundsDestination(req)
+}
+
+// SubscribeToProviderStakeEvents subscribes to provider channel stake updates, both increases and decreases.
+func (cwdr *WithDryRuns) SubscribeToProviderStakeEvents(hermesID common.Address, channelIDs [][32]byte) (sink chan *bindings.HermesImplementationNewStake, sub *Subscription, err error) {
+	return cwdr.bc.SubscribeToProviderStakeEvents(hermesID, channelIDs)
+}
+
+// FilterProviderStakeEvents returns the provider channel stake updates, both increases and decreases in the given block range.
+func (cwdr *WithDryRuns) FilterProviderStakeEvents(hermesID common.Address, channelIDs [][32]byte, start uint64, end *uint64) ([]*bindings.HermesImplementationNewStake, error) {
+	return cwdr.bc.FilterProviderStakeEvents(hermesID, channelIDs, start, end)
+}
+
+// SubscribeToHermesStakeIncreasedEvents subscribes to hermes stake increase events.
+func (cwdr *WithDryRuns) SubscribeToHermesStakeIncreasedEvents(hermesID common.Address) (sink chan *bindings.HermesImplementationHermesStakeIncreased, sub *Subscription, err error) {
+	return cwdr.bc.SubscribeToHermesStakeIncreasedEvents(hermesID)
+}
+
+// FilterHermesStakeIncreasedEvents returns the hermes stake increase events in the given block range.
+func (cwdr *WithDryRuns) FilterHermesStakeIncreasedEvents(hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationHermesStakeIncreased, error) {
+	return cwdr.bc.FilterHermesStakeIncreasedEvents(hermesID, start, end)
+}
+
+// SubscribeToHermesFeeUpdatedEvents subscribes to hermes fee update events.
+func (cwdr *WithDryRuns) SubscribeToHermesFeeUpdatedEvents(hermesID common.Address) (sink chan *bindings.HermesImplementationHermesFeeUpdated, sub *Subscription, err error) {
+	return cwdr.bc.SubscribeToHermesFeeUpdatedEvents(hermesID)
+}
+
+// FilterHermesFeeUpdatedEvents returns the hermes fee update events in the given block range.
+func (cwdr *WithDryRuns) FilterHermesFeeUpdatedEvents(hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationHermesFeeUpdated, error) {
+	return cwdr.bc.FilterHermesFeeUpdatedEvents(hermesID, start, end)
+}
+
+// SubscribeToHermesFundsWithdrawnEvents subscribes to hermes funds withdrawal events.
+func (cwdr *WithDryRuns) SubscribeToHermesFundsWithdrawnEvents(hermesID common.Address) (sink chan *bindings.HermesImplementationFundsWithdrawned, sub *Subscription, err error) {
+	return cwdr.bc.SubscribeToHermesFundsWithdrawnEvents(hermesID)
+}
+
+// FilterHermesFundsWithdrawnEvents returns the hermes funds withdrawal events in the given block range.
+func (cwdr *WithDryRuns) FilterHermesFundsWithdrawnEvents(hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationFundsWithdrawned, error) {
+	return cwdr.bc.FilterHermesFundsWithdrawnEvents(hermesID, start, end)
+}
+
+// SubscribeToBeneficiaryChangedEvents subscribes to identity beneficiary change events.
+func (cwdr *WithDryRuns) SubscribeToBeneficiaryChangedEvents(registryAddress common.Address, identities []common.Address) (sink chan *bindings.RegistryBeneficiaryChanged, sub *Subscription, err error) {
+	return cwdr.bc.SubscribeToBeneficiaryChangedEvents(registryAddress, identities)
+}
+
+// FilterBeneficiaryChangedEvents returns the identity beneficiary change events in the given block range.
+func (cwdr *WithDryRuns) FilterBeneficiaryChangedEvents(registryAddress common.Address, identities []common.Address, start uint64, end *uint64) ([]*bindings.RegistryBeneficiaryChanged, error) {
+	return cwdr.bc.FilterBeneficiaryChangedEvents(registryAddress, identities, start, end)
+}
+
+// SubscribeToChannelWithdrawEvents subscribes to consumer channel withdrawal events.
+func (cwdr *WithDryRuns) SubscribeToChannelWithdrawEvents(channelAddress common.Address) (sink chan *bindings.ChannelImplementationWithdraw, sub *Subscription, err error) {
+	return cwdr.bc.SubscribeToChannelWithdrawEvents(channelAddress)
+}
+
+// FilterChannelWithdrawEvents returns the consumer channel withdrawal events in the given block range.
+func (cwdr *WithDryRuns) FilterChannelWithdrawEvents(channelAddress common.Address, start uint64, end *uint64) ([]*bindings.ChannelImplementationWithdraw, error) {
+	return cwdr.bc.FilterChannelWithdrawEvents(channelAddress, start, end)
+}
+
+func (cwdr *WithDryRuns) FeeHistory(blockCount uint64, newest *big.Int, percentiles []float64) (*FeeHistory, error) {
+	return cwdr.bc.FeeHistory(blockCount, newest, percentiles)
+}
+
+// GetForwarderNonce returns the next meta-transaction nonce of the sender on the given forwarder.
+func (cwdr *WithDryRuns) GetForwarderNonce(forwarder, from common.Address) (*big.Int, error) {
+	return cwdr.bc.GetForwarderNonce(forwarder, from)
+}
+
+// VerifyMetaTx checks with the forwarder that the meta-transaction signature and nonce are valid.
+func (cwdr *WithDryRuns) VerifyMetaTx(req MetaTxRequest) (bool, error) {
+	return cwdr.bc.VerifyMetaTx(req)
+}
+
+// ExecuteMetaTx relays the signed meta-transaction through the forwarder.
+func (cwdr *WithDryRuns) ExecuteMetaTx(req MetaTxRequest) (*types.Transaction, error) {
+	if _, err := cwdr.Estimate(req); err != nil {
+		return nil, err
+	}
+
+	return cwdr.bc.ExecuteMetaTx(req)
+}
+
+// StreamLogs streams the logs matching the given query.
+func (cwdr *WithDryRuns) StreamLogs(ctx context.Context, q ethereum.FilterQuery) (*LogStream, error) {
+	return cwdr.bc.StreamLogs(ctx, q)
+}
+
+// StreamLogsFrom resumes a log stream after the given cursor.
+func (cwdr *WithDryRuns) StreamLogsFrom(ctx context.Context, q ethereum.FilterQuery, cursor LogCursor) (*LogStream, error) {
+	return cwdr.bc.StreamLogsFrom(ctx, q, cursor)
+}
+
+// StreamLogsNamed streams the logs matching the given query, resuming after the offset committed under the name.
+func (cwdr *WithDryRuns) StreamLogsNamed(ctx context.Context, name string, q ethereum.FilterQuery, offsets OffsetStore) (*LogStream, error) {
+	return cwdr.bc.StreamLogsNamed(ctx, name, q, offsets)
+}
+
+// Capabilities reports which features are available on the connected chain with the given contracts.
+func (cwdr *WithDryRuns) Capabilities(contracts CapabilityContracts) (Capabilities, error) {
+	return cwdr.bc.Capabilities(contracts)
+}
+
+// GetProxyImplementation returns the implementation address of the given proxy.
+func (cwdr *WithDryRuns) GetProxyImplementation(proxy common.Address) (common.Address, error) {
+	return cwdr.bc.GetProxyImplementation(proxy)
+}
+
+// SubscribeToProxyUpgradedEvents subscribes to proxy implementation upgrade events.
+func (cwdr *WithDryRuns) SubscribeToProxyUpgradedEvents(proxy common.Address) (sink chan *ProxyUpgraded, sub *Subscription, err error) {
+	return cwdr.bc.SubscribeToProxyUpgradedEvents(proxy)
+}
+
 // SubscribeToIdentityRegistrationEvents subscribes to identity registration events
-func (cwdr *WithDryRuns) SubscribeToIdentityRegistrationEvents(registryAddress common.Address) (sink chan *bindings.RegistryRegisteredIdentity, cancel func(), err error) {
+func (cwdr *WithDryRuns) SubscribeToIdentityRegistrationEvents(registryAddress common.Address) (sink chan *bindings.RegistryRegisteredIdentity, sub *Subscription, err error) {
 	return cwdr.bc.SubscribeToIdentityRegistrationEvents(registryAddress)
 }
 
 // SubscribeToConsumerChannelBalanceUpdate subscribes to consumer channel balance update events
-func (cwdr *WithDryRuns) SubscribeToConsumerChannelBalanceUpdate(mystSCAddress common.Address, channelAddresses []common.Address) (sink chan *bindings.MystTokenTransfer, cancel func(), err error) {
+func (cwdr *WithDryRuns) SubscribeToConsumerChannelBalanceUpdate(mystSCAddress common.Address, channelAddresses []common.Address) (sink chan *bindings.MystTokenTransfer, sub *Subscription, err error) {
 	return cwdr.bc.SubscribeToConsumerChannelBalanceUpdate(mystSCAddress, channelAddresses)
 }
 
 // SubscribeToPromiseSettledEventByChannelID subscribes to promise settled events
-func (cwdr *WithDryRuns) SubscribeToPromiseSettledEventByChannelID(hermesID common.Address, providerAddresses [][32]byte) (sink chan *bindings.HermesImplementationPromiseSettled, cancel func(), err error) {
+func (cwdr *WithDryRuns) SubscribeToPromiseSettledEventByChannelID(hermesID common.Address, providerAddresses [][32]byte) (sink chan *bindings.HermesImplementationPromiseSettled, sub *Subscription, err error) {
 	return cwdr.bc.SubscribeToPromiseSettledEventByChannelID(hermesID, providerAddresses)
 }
 
@@ -280,6 +508,21 @@ func (cwdr *WithDryRuns) GetBeneficiary(registryAddress, identity common.Address
 	return cwdr.bc.GetBeneficiary(registryAddress, identity)
 }
 
+// GetProviderChannelAtBlock returns the provider channel as of the given block.
+func (cwdr *WithDryRuns) GetProviderChannelAtBlock(hermesAddress, addressToCheck common.Address, block *big.Int) (ProviderChannel, error) {
+	return cwdr.bc.GetProviderChannelAtBlock(hermesAddress, addressToCheck, block)
+}
+
+// GetMystBalanceAtBlock returns the myst balance as of the given block.
+func (cwdr *WithDryRuns) GetMystBalanceAtBlock(mystSCAddress, address common.Address, block *big.Int) (*big.Int, error) {
+	return cwdr.bc.GetMystBalanceAtBlock(mystSCAddress, address, block)
+}
+
+// GetBeneficiaryAtBlock returns the beneficiary as of the given block.
+func (cwdr *WithDryRuns) GetBeneficiaryAtBlock(registryAddress, identity common.Address, block *big.Int) (common.Address, error) {
+	return cwdr.bc.GetBeneficiaryAtBlock(registryAddress, identity, block)
+}
+
 func (cwdr *WithDryRuns) SuggestGasPrice() (*big.Int, error) {
 	return cwdr.bc.SuggestGasPrice()
 }
@@ -321,3 +564,13 @@ func (cwdr *WithDryRuns) HeaderByNumber(number *big.Int) (*types.Header, error)
 func (cwdr *WithDryRuns) SendTransaction(tx *types.Transaction) error {
 	return cwdr.bc.SendTransaction(tx)
 }
+
+// SendTransactionWithFeeGuard sends the transaction checking it against the given operation value.
+func (cwdr *WithDryRuns) SendTransactionWithFeeGuard(tx *types.Transaction, value *big.Int, override bool) error {
+	return cwdr.bc.SendTransactionWithFeeGuard(tx, value, override)
+}
+
+// GetTokenDecimals returns the decimals of the ERC-20 token.
+func (cwdr *WithDryRuns) GetTokenDecimals(token common.Address) (uint8, error) {
+	return cwdr.bc.GetTokenDecimals(token)
+}
@@ -0,0 +1,849 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/mysteriumnetwork/payments/bindings"
+)
+
+// ErrInjectedFault is returned by calls failed on purpose by WithFaults.
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultConfig describes the faults injected by WithFaults. Rates are probabilities in the [0, 1] range.
+type FaultConfig struct {
+	// ErrorRate is the probability of a call failing with ErrInjectedFault without reaching the blockchain.
+	ErrorRate float64
+	// MinLatency and MaxLatency bound the random delay added before every call.
+	MinLatency time.Duration
+	MaxLatency time.Duration
+	// DropRate is the probability of a subscription ending with ErrInjectedFault after delivering an event.
+	DropRate float64
+	// DuplicateRate is the probability of a subscription event being delivered twice.
+	DuplicateRate float64
+	// Methods limits the faults to the named BC methods, e.g. GetMystBalance. All methods are affected if empty.
+	Methods []string
+}
+
+// WithFaults injects latencies, transient errors, dropped subscriptions and duplicate events into blockchain calls.
+// It is meant for verifying the retry and reconciliation logic of applications under controlled failure scenarios.
+// Log streams only get faults injected when they are started.
+type WithFaults struct {
+	bc BC
+
+	lock    sync.Mutex
+	rand    *rand.Rand
+	cfg     FaultConfig
+	methods map[string]bool
+}
+
+var _ BC = (*WithFaults)(nil)
+
+// NewWithFaults returns a new fault injecting client. The seed makes the injected failure scenario reproducible.
+func NewWithFaults(bc BC, cfg FaultConfig, seed int64) *WithFaults {
+	wf := &WithFaults{
+		bc:   bc,
+		rand: rand.New(rand.NewSource(seed)),
+	}
+	wf.SetFaults(cfg)
+	return wf
+}
+
+// SetFaults replaces the fault config, e.g. to stop injecting faults in the middle of a test.
+// Subscriptions made before use the new config as well.
+func (wf *WithFaults) SetFaults(cfg FaultConfig) {
+	methods := make(map[string]bool, len(cfg.Methods))
+	for _, m := range cfg.Methods {
+		methods[m] = true
+	}
+
+	wf.lock.Lock()
+	defer wf.lock.Unlock()
+	wf.cfg = cfg
+	wf.methods = methods
+}
+
+// inject delays the call and returns ErrInjectedFault if it should fail.
+func (wf *WithFaults) inject(method string) error {
+	wf.lock.Lock()
+	affected := wf.affects(method)
+	var latency time.Duration
+	var fail bool
+	if affected {
+		latency = wf.cfg.MinLatency
+		if spread := wf.cfg.MaxLatency - wf.cfg.MinLatency; spread > 0 {
+			latency += time.Duration(wf.rand.Int63n(int64(spread)))
+		}
+		fail = wf.roll(wf.cfg.ErrorRate)
+	}
+	wf.lock.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if fail {
+		return ErrInjectedFault
+	}
+	return nil
+}
+
+// eventFaults decides whether a subscription event is duplicated and whether the subscription is dropped after it.
+func (wf *WithFaults) eventFaults(method string) (duplicate, drop bool) {
+	wf.lock.Lock()
+	defer wf.lock.Unlock()
+	if !wf.affects(method) {
+		return false, false
+	}
+	return wf.roll(wf.cfg.DuplicateRate), wf.roll(wf.cfg.DropRate)
+}
+
+func (wf *WithFaults) affects(method string) bool {
+	return len(wf.methods) == 0 || wf.methods[method]
+}
+
+func (wf *WithFaults) roll(rate float64) bool {
+	return rate > 0 && wf.rand.Float64() < rate
+}
+
+// wrapSubscription forwards the events of the subscription to a new sink of the same type, injecting the event faults.
+// The sink types differ per event, so the events are forwarded using reflection.
+func (wf *WithFaults) wrapSubscription(method string, sink interface{}, sub *Subscription) (interface{}, *Subscription) {
+	in := reflect.ValueOf(sink)
+	out := reflect.MakeChan(in.Type(), in.Cap())
+
+	faulty := &Subscription{
+		meta: sub.meta,
+		err:  make(chan error, 1),
+		done: make(chan struct{}),
+	}
+	faulty.sub = event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: in},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(quit)},
+		}
+		for {
+			chosen, ev, ok := reflect.Select(cases)
+			if chosen == 1 {
+				return nil
+			}
+			if !ok {
+				return <-sub.Err()
+			}
+
+			duplicate, drop := wf.eventFaults(method)
+			deliveries := 1
+			if duplicate {
+				deliveries = 2
+			}
+			for i := 0; i < deliveries; i++ {
+				send := []reflect.SelectCase{
+					{Dir: reflect.SelectSend, Chan: out, Send: ev},
+					{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(quit)},
+				}
+				if chosen, _, _ := reflect.Select(send); chosen == 1 {
+					return nil
+				}
+			}
+			if drop {
+				return ErrInjectedFault
+			}
+		}
+	})
+	faulty.watch(nil, out.Close)
+
+	return out.Interface(), faulty
+}
+
+// GetHermesFee fetches the hermes fee from blockchain
+func (wf *WithFaults) GetHermesFee(hermesAddress common.Address) (uint16, error) {
+	if err := wf.inject("GetHermesFee"); err != nil {
+		return 0, err
+	}
+	return wf.bc.GetHermesFee(hermesAddress)
+}
+
+// CalculateHermesFee fetches the hermes fee from blockchain
+func (wf *WithFaults) CalculateHermesFee(hermesAddress common.Address, value *big.Int) (*big.Int, error) {
+	if err := wf.inject("CalculateHermesFee"); err != nil {
+		return nil, err
+	}
+	return wf.bc.CalculateHermesFee(hermesAddress, value)
+}
+
+// IsRegisteredAsProvider checks if the provider is registered with the hermes properly
+func (wf *WithFaults) IsRegisteredAsProvider(hermesAddress, registryAddress, addressToCheck common.Address) (bool, error) {
+	if err := wf.inject("IsRegisteredAsProvider"); err != nil {
+		return false, err
+	}
+	return wf.bc.IsRegisteredAsProvider(hermesAddress, registryAddress, addressToCheck)
+}
+
+// GetProviderChannel returns the provider channel
+func (wf *WithFaults) GetProviderChannel(hermesAddress common.Address, addressToCheck common.Address, pending bool) (ProviderChannel, error) {
+	if err := wf.inject("GetProviderChannel"); err != nil {
+		return ProviderChannel{}, err
+	}
+	return wf.bc.GetProviderChannel(hermesAddress, addressToCheck, pending)
+}
+
+// IsRegistered checks wether the given identity is registered or not
+func (wf *WithFaults) IsRegistered(registryAddress, addressToCheck common.Address) (bool, error) {
+	if err := wf.inject("IsRegistered"); err != nil {
+		return false, err
+	}
+	return wf.bc.IsRegistered(registryAddress, addressToCheck)
+}
+
+// SubscribeToPromiseSettledEvent subscribes to promise settled events
+func (wf *WithFaults) SubscribeToPromiseSettledEvent(providerID, hermesID common.Address) (chan *bindings.HermesImplementationPromiseSettled, *Subscription, error) {
+	if err := wf.inject("SubscribeToPromiseSettledEvent"); err != nil {
+		return nil, nil, err
+	}
+	sink, sub, err := wf.bc.SubscribeToPromiseSettledEvent(providerID, hermesID)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, faulty := wf.wrapSubscription("SubscribeToPromiseSettledEvent", sink, sub)
+	return out.(chan *bindings.HermesImplementationPromiseSettled), faulty, nil
+}
+
+// GetMystBalance returns the balance in myst
+func (wf *WithFaults) GetMystBalance(mystSCAddress, address common.Address) (*big.Int, error) {
+	if err := wf.inject("GetMystBalance"); err != nil {
+		return nil, err
+	}
+	return wf.bc.GetMystBalance(mystSCAddress, address)
+}
+
+// SubscribeToConsumerBalanceEvent subscribes to the consumer balance change events
+func (wf *WithFaults) SubscribeToConsumerBalanceEvent(channel, mystSCAddress common.Address, timeout time.Duration) (chan *bindings.MystTokenTransfer, *Subscription, error) {
+	if err := wf.inject("SubscribeToConsumerBalanceEvent"); err != nil {
+		return nil, nil, err
+	}
+	sink, sub, err := wf.bc.SubscribeToConsumerBalanceEvent(channel, mystSCAddress, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, faulty := wf.wrapSubscription("SubscribeToConsumerBalanceEvent", sink, sub)
+	return out.(chan *bindings.MystTokenTransfer), faulty, nil
+}
+
+// RegisterIdentity registers the given identity on blockchain
+func (wf *WithFaults) RegisterIdentity(rr RegistrationRequest) (*types.Transaction, error) {
+	if err := wf.inject("RegisterIdentity"); err != nil {
+		return nil, err
+	}
+	return wf.bc.RegisterIdentity(rr)
+}
+
+// TransferMyst transfers myst to the provided address
+func (wf *WithFaults) TransferMyst(req TransferRequest) (*types.Transaction, error) {
+	if err := wf.inject("TransferMyst"); err != nil {
+		return nil, err
+	}
+	return wf.bc.TransferMyst(req)
+}
+
+// IsHermesRegistered checks if given hermes is registered and returns true or false.
+func (wf *WithFaults) IsHermesRegistered(registryAddress, acccountantID common.Address) (bool, error) {
+	if err := wf.inject("IsHermesRegistered"); err != nil {
+		return false, err
+	}
+	return wf.bc.IsHermesRegistered(registryAddress, acccountantID)
+}
+
+// GetHermesOperator returns operator address of given hermes
+func (wf *WithFaults) GetHermesOperator(hermesID common.Address) (common.Address, error) {
+	if err := wf.inject("GetHermesOperator"); err != nil {
+		return common.Address{}, err
+	}
+	return wf.bc.GetHermesOperator(hermesID)
+}
+
+// SettleAndRebalance is settling given hermes issued promise
+func (wf *WithFaults) SettleAndRebalance(req SettleAndRebalanceRequest) (*types.Transaction, error) {
+	if err := wf.inject("SettleAndRebalance"); err != nil {
+		return nil, err
+	}
+	return wf.bc.SettleAndRebalance(req)
+}
+
+// SettleWithBeneficiary is setting new beneficiary and settling latest promise balance into new beneficiary address.
+func (wf *WithFaults) SettleWithBeneficiary(req SettleWithBeneficiaryRequest) (*types.Transaction, error) {
+	if err := wf.inject("SettleWithBeneficiary"); err != nil {
+		return nil, err
+	}
+	return wf.bc.SettleWithBeneficiary(req)
+}
+
+// GetConsumerChannelsHermes returns the consumer channels hermes
+func (wf *WithFaults) GetConsumerChannelsHermes(channelAddress common.Address) (ConsumersHermes, error) {
+	if err := wf.inject("GetConsumerChannelsHermes"); err != nil {
+		return ConsumersHermes{}, err
+	}
+	return wf.bc.GetConsumerChannelsHermes(channelAddress)
+}
+
+// GetConsumerChannelOperator returns the consumer channel operator/identity
+func (wf *WithFaults) GetConsumerChannelOperator(channelAddress common.Address) (common.Address, error) {
+	if err := wf.inject("GetConsumerChannelOperator"); err != nil {
+		return common.Address{}, err
+	}
+	return wf.bc.GetConsumerChannelOperator(channelAddress)
+}
+
+// GetProviderChannelByID returns the given channel information
+func (wf *WithFaults) GetProviderChannelByID(acc common.Address, chID []byte) (ProviderChannel, error) {
+	if err := wf.inject("GetProviderChannelByID"); err != nil {
+		return ProviderChannel{}, err
+	}
+	return wf.bc.GetProviderChannelByID(acc, chID)
+}
+
+// SubscribeToIdentityRegistrationEvents subscribes to identity registration events
+func (wf *WithFaults) SubscribeToIdentityRegistrationEvents(registryAddress common.Address) (chan *bindings.RegistryRegisteredIdentity, *Subscription, error) {
+	if err := wf.inject("SubscribeToIdentityRegistrationEvents"); err != nil {
+		return nil, nil, err
+	}
+	sink, sub, err := wf.bc.SubscribeToIdentityRegistrationEvents(registryAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, faulty := wf.wrapSubscription("SubscribeToIdentityRegistrationEvents", sink, sub)
+	return out.(chan *bindings.RegistryRegisteredIdentity), faulty, nil
+}
+
+// SubscribeToConsumerChannelBalanceUpdate subscribes to consumer channel balance update events
+func (wf *WithFaults) SubscribeToConsumerChannelBalanceUpdate(mystSCAddress common.Address, channelAddresses []common.Address) (chan *bindings.MystTokenTransfer, *Subscription, error) {
+	if err := wf.inject("SubscribeToConsumerChannelBalanceUpdate"); err != nil {
+		return nil, nil, err
+	}
+	sink, sub, err := wf.bc.SubscribeToConsumerChannelBalanceUpdate(mystSCAddress, channelAddresses)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, faulty := wf.wrapSubscription("SubscribeToConsumerChannelBalanceUpdate", sink, sub)
+	return out.(chan *bindings.MystTokenTransfer), faulty, nil
+}
+
+// SettlePromise is settling the given consumer issued promise
+func (wf *WithFaults) SettlePromise(req SettleRequest) (*types.Transaction, error) {
+	if err := wf.inject("SettlePromise"); err != nil {
+		return nil, err
+	}
+	return wf.bc.SettlePromise(req)
+}
+
+// SubscribeToPromiseSettledEventByChannelID subscribes to promise settled events
+func (wf *WithFaults) SubscribeToPromiseSettledEventByChannelID(hermesID common.Address, providerAddresses [][32]byte) (chan *bindings.HermesImplementationPromiseSettled, *Subscription, error) {
+	if err := wf.inject("SubscribeToPromiseSettledEventByChannelID"); err != nil {
+		return nil, nil, err
+	}
+	sink, sub, err := wf.bc.SubscribeToPromiseSettledEventByChannelID(hermesID, providerAddresses)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, faulty := wf.wrapSubscription("SubscribeToPromiseSettledEventByChannelID", sink, sub)
+	return out.(chan *bindings.HermesImplementationPromiseSettled), faulty, nil
+}
+
+// SubscribeToMystTokenTransfers subscribes to myst token transfer events
+func (wf *WithFaults) SubscribeToMystTokenTransfers(mystSCAddress common.Address) (chan *bindings.MystTokenTransfer, *Subscription, error) {
+	if err := wf.inject("SubscribeToMystTokenTransfers"); err != nil {
+		return nil, nil, err
+	}
+	sink, sub, err := wf.bc.SubscribeToMystTokenTransfers(mystSCAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, faulty := wf.wrapSubscription("SubscribeToMystTokenTransfers", sink, sub)
+	return out.(chan *bindings.MystTokenTransfer), faulty, nil
+}
+
+// NetworkID returns the network id
+func (wf *WithFaults) NetworkID() (*big.Int, error) {
+	if err := wf.inject("NetworkID"); err != nil {
+		return nil, err
+	}
+	return wf.bc.NetworkID()
+}
+
+// GetConsumerChannel returns the consumer channel
+func (wf *WithFaults) GetConsumerChannel(addr common.Address, mystSCAddress common.Address) (ConsumerChannel, error) {
+	if err := wf.inject("GetConsumerChannel"); err != nil {
+		return ConsumerChannel{}, err
+	}
+	return wf.bc.GetConsumerChannel(addr, mystSCAddress)
+}
+
+// GetEthBalance gets the current ethereum balance for the address.
+func (wf *WithFaults) GetEthBalance(address common.Address) (*big.Int, error) {
+	if err := wf.inject("GetEthBalance"); err != nil {
+		return nil, err
+	}
+	return wf.bc.GetEthBalance(address)
+}
+
+// TransferEth transfers ethereum to the given address.
+func (wf *WithFaults) TransferEth(etr EthTransferRequest) (*types.Transaction, error) {
+	if err := wf.inject("TransferEth"); err != nil {
+		return nil, err
+	}
+	return wf.bc.TransferEth(etr)
+}
+
+// GetHermessAvailableBalance returns the balance that is available for hermes.
+func (wf *WithFaults) GetHermessAvailableBalance(hermesAddress common.Address) (*big.Int, error) {
+	if err := wf.inject("GetHermessAvailableBalance"); err != nil {
+		return nil, err
+	}
+	return wf.bc.GetHermessAvailableBalance(hermesAddress)
+}
+
+// DecreaseProviderStake decreases provider stake.
+func (wf *WithFaults) DecreaseProviderStake(req DecreaseProviderStakeRequest) (*types.Transaction, error) {
+	if err := wf.inject("DecreaseProviderStake"); err != nil {
+		return nil, err
+	}
+	return wf.bc.DecreaseProviderStake(req)
+}
+
+// SettleIntoStake settles the hermes promise into stake increase.
+func (wf *WithFaults) SettleIntoStake(req SettleIntoStakeRequest) (*types.Transaction, error) {
+	if err := wf.inject("SettleIntoStake"); err != nil {
+		return nil, err
+	}
+	return wf.bc.SettleIntoStake(req)
+}
+
+// IncreaseProviderStake increases the provider stake.
+func (wf *WithFaults) IncreaseProviderStake(req ProviderStakeIncreaseRequest) (*types.Transaction, error) {
+	if err := wf.inject("IncreaseProviderStake"); err != nil {
+		return nil, err
+	}
+	return wf.bc.IncreaseProviderStake(req)
+}
+
+// TransactionReceipt returns the receipt of the given transaction.
+func (wf *WithFaults) TransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	if err := wf.inject("TransactionReceipt"); err != nil {
+		return nil, err
+	}
+	return wf.bc.TransactionReceipt(hash)
+}
+
+// GetHermesURL returns the hermes URL.
+func (wf *WithFaults) GetHermesURL(registryID, hermesID common.Address) (string, error) {
+	if err := wf.inject("GetHermesURL"); err != nil {
+		return "", err
+	}
+	return wf.bc.GetHermesURL(registryID, hermesID)
+}
+
+// GetStakeThresholds returns the stake tresholds for the given hermes.
+func (wf *WithFaults) GetStakeThresholds(hermesID common.Address) (*big.Int, *big.Int, error) {
+	if err := wf.inject("GetStakeThresholds"); err != nil {
+		return nil, nil, err
+	}
+	return wf.bc.GetStakeThresholds(hermesID)
+}
+
+// GetProviderChannelAtBlock returns the provider channel as of the given block.
+func (wf *WithFaults) GetProviderChannelAtBlock(hermesAddress, addressToCheck common.Address, block *big.Int) (ProviderChannel, error) {
+	if err := wf.inject("GetProviderChannelAtBlock"); err != nil {
+		return ProviderChannel{}, err
+	}
+	return wf.bc.GetProviderChannelAtBlock(hermesAddress, addressToCheck, block)
+}
+
+// GetMystBalanceAtBlock returns the myst balance as of the given block.
+func (wf *WithFaults) GetMystBalanceAtBlock(mystSCAddress, address common.Address, block *big.Int) (*big.Int, error) {
+	if err := wf.inject("GetMystBalanceAtBlock"); err != nil {
+		return nil, err
+	}
+	return wf.bc.GetMystBalanceAtBlock(mystSCAddress, address, block)
+}
+
+// GetBeneficiaryAtBlock returns the beneficiary as of the given block.
+func (wf *WithFaults) GetBeneficiaryAtBlock(registryAddress, identity common.Address, block *big.Int) (common.Address, error) {
+	if err := wf.inject("GetBeneficiaryAtBlock"); err != nil {
+		return common.Address{}, err
+	}
+	return wf.bc.GetBeneficiaryAtBlock(registryAddress, identity, block)
+}
+
+// GetBeneficiary returns the beneficiary set for the identity.
+func (wf *WithFaults) GetBeneficiary(registryAddress, identity common.Address) (common.Address, error) {
+	if err := wf.inject("GetBeneficiary"); err != nil {
+		return common.Address{}, err
+	}
+	return wf.bc.GetBeneficiary(registryAddress, identity)
+}
+
+// FeeHistory returns the fee history of the given block range.
+func (wf *WithFaults) FeeHistory(blockCount uint64, newest *big.Int, percentiles []float64) (*FeeHistory, error) {
+	if err := wf.inject("FeeHistory"); err != nil {
+		return nil, err
+	}
+	return wf.bc.FeeHistory(blockCount, newest, percentiles)
+}
+
+// GetForwarderNonce returns the next meta-transaction nonce of the sender on the given forwarder.
+func (wf *WithFaults) GetForwarderNonce(forwarder, from common.Address) (*big.Int, error) {
+	if err := wf.inject("GetForwarderNonce"); err != nil {
+		return nil, err
+	}
+	return wf.bc.GetForwarderNonce(forwarder, from)
+}
+
+// VerifyMetaTx checks with the forwarder that the meta-transaction signature and nonce are valid.
+func (wf *WithFaults) VerifyMetaTx(req MetaTxRequest) (bool, error) {
+	if err := wf.inject("VerifyMetaTx"); err != nil {
+		return false, err
+	}
+	return wf.bc.VerifyMetaTx(req)
+}
+
+// ExecuteMetaTx relays the signed meta-transaction through the forwarder.
+func (wf *WithFaults) ExecuteMetaTx(req MetaTxRequest) (*types.Transaction, error) {
+	if err := wf.inject("ExecuteMetaTx"); err != nil {
+		return nil, err
+	}
+	return wf.bc.ExecuteMetaTx(req)
+}
+
+// SuggestGasPrice returns the suggested gas price.
+func (wf *WithFaults) SuggestGasPrice() (*big.Int, error) {
+	if err := wf.inject("SuggestGasPrice"); err != nil {
+		return nil, err
+	}
+	return wf.bc.SuggestGasPrice()
+}
+
+// FilterLogs executes a filter query.
+func (wf *WithFaults) FilterLogs(q ethereum.FilterQuery) ([]types.Log, error) {
+	if err := wf.inject("FilterLogs"); err != nil {
+		return nil, err
+	}
+	return wf.bc.FilterLogs(q)
+}
+
+// HeaderByNumber returns a block header from the current canonical chain. If number is
+// nil, the latest known header is returned.
+func (wf *WithFaults) HeaderByNumber(number *big.Int) (*types.Header, error) {
+	if err := wf.inject("HeaderByNumber"); err != nil {
+		return nil, err
+	}
+	return wf.bc.HeaderByNumber(number)
+}
+
+// GetLastRegistryNonce returns the last nonce used by the registry.
+func (wf *WithFaults) GetLastRegistryNonce(registry common.Address) (*big.Int, error) {
+	if err := wf.inject("GetLastRegistryNonce"); err != nil {
+		return nil, err
+	}
+	return wf.bc.GetLastRegistryNonce(registry)
+}
+
+// SendTransaction sends the signed transaction.
+func (wf *WithFaults) SendTransaction(tx *types.Transaction) error {
+	if err := wf.inject("SendTransaction"); err != nil {
+		return err
+	}
+	return wf.bc.SendTransaction(tx)
+}
+
+// SendTransactionWithFeeGuard sends the signed transaction checking it against the given operation value.
+func (wf *WithFaults) SendTransactionWithFeeGuard(tx *types.Transaction, value *big.Int, override bool) error {
+	if err := wf.inject("SendTransactionWithFeeGuard"); err != nil {
+		return err
+	}
+	return wf.bc.SendTransactionWithFeeGuard(tx, value, override)
+}
+
+// GetProxyImplementation returns the implementation address of the given proxy.
+func (wf *WithFaults) GetProxyImplementation(proxy common.Address) (common.Address, error) {
+	if err := wf.inject("GetProxyImplementation"); err != nil {
+		return common.Address{}, err
+	}
+	return wf.bc.GetProxyImplementation(proxy)
+}
+
+// Capabilities reports which features are available on the connected chain with the given contracts.
+func (wf *WithFaults) Capabilities(contracts CapabilityContracts) (Capabilities, error) {
+	if err := wf.inject("Capabilities"); err != nil {
+		return Capabilities{}, err
+	}
+	return wf.bc.Capabilities(contracts)
+}
+
+// SubscribeToProxyUpgradedEvents subscribes to proxy implementation upgrade events.
+func (wf *WithFaults) SubscribeToProxyUpgradedEvents(proxy common.Address) (chan *ProxyUpgraded, *Subscription, error) {
+	if err := wf.inject("SubscribeToProxyUpgradedEvents"); err != nil {
+		return nil, nil, err
+	}
+	sink, sub, err := wf.bc.SubscribeToProxyUpgradedEvents(proxy)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, faulty := wf.wrapSubscription("SubscribeToProxyUpgradedEvents", sink, sub)
+	return out.(chan *ProxyUpgraded), faulty, nil
+}
+
+// PauseChannelOpening pauses channel opening in hermes.
+func (wf *WithFaults) PauseChannelOpening(req PauseChannelOpeningRequest) (*types.Transaction, error) {
+	if err := wf.inject("PauseChannelOpening"); err != nil {
+		return nil, err
+	}
+	return wf.bc.PauseChannelOpening(req)
+}
+
+// ActivateChannelOpening resumes channel opening in hermes.
+func (wf *WithFaults) ActivateChannelOpening(req ActivateChannelOpeningRequest) (*types.Transaction, error) {
+	if err := wf.inject("ActivateChannelOpening"); err != nil {
+		return nil, err
+	}
+	return wf.bc.ActivateChannelOpening(req)
+}
+
+// WithdrawHermesBalance withdraws the available hermes balance.
+func (wf *WithFaults) WithdrawHermesBalance(req HermesWithdrawRequest) (*types.Transaction, error) {
+	if err := wf.inject("WithdrawHermesBalance"); err != nil {
+		return nil, err
+	}
+	return wf.bc.WithdrawHermesBalance(req)
+}
+
+// GetLatestChannelImplementation returns the channel implementation new channels are deployed with.
+func (wf *WithFaults) GetLatestChannelImplementation(registryAddress common.Address) (common.Address, error) {
+	if err := wf.inject("GetLatestChannelImplementation"); err != nil {
+		return common.Address{}, err
+	}
+	return wf.bc.GetLatestChannelImplementation(registryAddress)
+}
+
+// GetChannelExit returns the pending exit request of the consumer channel.
+func (wf *WithFaults) GetChannelExit(channelAddress common.Address) (ChannelExit, error) {
+	if err := wf.inject("GetChannelExit"); err != nil {
+		return ChannelExit{}, err
+	}
+	return wf.bc.GetChannelExit(channelAddress)
+}
+
+// RequestChannelExit starts the exit of all the channel funds to the beneficiary.
+func (wf *WithFaults) RequestChannelExit(req RequestChannelExitRequest) (*types.Transaction, error) {
+	if err := wf.inject("RequestChannelExit"); err != nil {
+		return nil, err
+	}
+	return wf.bc.RequestChannelExit(req)
+}
+
+// FinalizeChannelExit transfers the channel funds to the beneficiary of the requested exit.
+func (wf *WithFaults) FinalizeChannelExit(req FinalizeChannelExitRequest) (*types.Transaction, error) {
+	if err := wf.inject("FinalizeChannelExit"); err != nil {
+		return nil, err
+	}
+	return wf.bc.FinalizeChannelExit(req)
+}
+
+// SetHermesMinStake sets the minimal channel stake of hermes.
+func (wf *WithFaults) SetHermesMinStake(req SetHermesMinStakeRequest) (*types.Transaction, error) {
+	if err := wf.inject("SetHermesMinStake"); err != nil {
+		return nil, err
+	}
+	return wf.bc.SetHermesMinStake(req)
+}
+
+// SetHermesFundsDestination sets the address that receives the funds claimed from hermes.
+func (wf *WithFaults) SetHermesFundsDestination(req SetHermesFundsDestinationRequest) (*types.Transaction, error) {
+	if err := wf.inject("SetHermesFundsDestination"); err != nil {
+		return nil, err
+	}
+	return wf.bc.SetHermesFundsDestination(req)
+}
+
+// SubscribeToProviderStakeEvents subscribes to provider channel stake updates, both increases and decreases.
+func (wf *WithFaults) SubscribeToProviderStakeEvents(hermesID common.Address, channelIDs [][32]byte) (chan *bindings.HermesImplementationNewStake, *Subscription, error) {
+	if err := wf.inject("SubscribeToProviderStakeEvents"); err != nil {
+		return nil, nil, err
+	}
+	sink, sub, err := wf.bc.SubscribeToProviderStakeEvents(hermesID, channelIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, faulty := wf.wrapSubscription("SubscribeToProviderStakeEvents", sink, sub)
+	return out.(chan *bindings.HermesImplementationNewStake), faulty, nil
+}
+
+// FilterProviderStakeEvents returns the provider channel stake updates, both increases and decreases in the given block range.
+func (wf *WithFaults) FilterProviderStakeEvents(hermesID common.Address, channelIDs [][32]byte, start uint64, end *uint64) ([]*bindings.HermesImplementationNewStake, error) {
+	if err := wf.inject("FilterProviderStakeEvents"); err != nil {
+		return nil, err
+	}
+	return wf.bc.FilterProviderStakeEvents(hermesID, channelIDs, start, end)
+}
+
+// SubscribeToHermesStakeIncreasedEvents subscribes to hermes stake increase events.
+func (wf *WithFaults) SubscribeToHermesStakeIncreasedEvents(hermesID common.Address) (chan *bindings.HermesImplementationHermesStakeIncreased, *Subscription, error) {
+	if err := wf.inject("SubscribeToHermesStakeIncreasedEvents"); err != nil {
+		return nil, nil, err
+	}
+	sink, sub, err := wf.bc.SubscribeToHermesStakeIncreasedEvents(hermesID)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, faulty := wf.wrapSubscription("SubscribeToHermesStakeIncreasedEvents", sink, sub)
+	return out.(chan *bindings.HermesImplementationHermesStakeIncreased), faulty, nil
+}
+
+// FilterHermesStakeIncreasedEvents returns the hermes stake increase events in the given block range.
+func (wf *WithFaults) FilterHermesStakeIncreasedEvents(hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationHermesStakeIncreased, error) {
+	if err := wf.inject("FilterHermesStakeIncreasedEvents"); err != nil {
+		return nil, err
+	}
+	return wf.bc.FilterHermesStakeIncreasedEvents(hermesID, start, end)
+}
+
+// SubscribeToHermesFeeUpdatedEvents subscribes to hermes fee update events.
+func (wf *WithFaults) SubscribeToHermesFeeUpdatedEvents(hermesID common.Address) (chan *bindings.HermesImplementationHermesFeeUpdated, *Subscription, error) {
+	if err := wf.inject("SubscribeToHermesFeeUpdatedEvents"); err != nil {
+		return nil, nil, err
+	}
+	sink, sub, err := wf.bc.SubscribeToHermesFeeUpdatedEvents(hermesID)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, faulty := wf.wrapSubscription("SubscribeToHermesFeeUpdatedEvents", sink, sub)
+	return out.(chan *bindings.HermesImplementationHermesFeeUpdated), faulty, nil
+}
+
+// FilterHermesFeeUpdatedEvents returns the hermes fee update events in the given block range.
+func (wf *WithFaults) FilterHermesFeeUpdatedEvents(hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationHermesFeeUpdated, error) {
+	if err := wf.inject("FilterHermesFeeUpdatedEvents"); err != nil {
+		return nil, err
+	}
+	return wf.bc.FilterHermesFeeUpdatedEvents(hermesID, start, end)
+}
+
+// SubscribeToHermesFundsWithdrawnEvents subscribes to hermes funds withdrawal events.
+func (wf *WithFaults) SubscribeToHermesFundsWithdrawnEvents(hermesID common.Address) (chan *bindings.HermesImplementationFundsWithdrawned, *Subscription, error) {
+	if err := wf.inject("SubscribeToHermesFundsWithdrawnEvents"); err != nil {
+		return nil, nil, err
+	}
+	sink, sub, err := wf.bc.SubscribeToHermesFundsWithdrawnEvents(hermesID)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, faulty := wf.wrapSubscription("SubscribeToHermesFundsWithdrawnEvents", sink, sub)
+	return out.(chan *bindings.HermesImplementationFundsWithdrawned), faulty, nil
+}
+
+// FilterHermesFundsWithdrawnEvents returns the hermes funds withdrawal events in the given block range.
+func (wf *WithFaults) FilterHermesFundsWithdrawnEvents(hermesID common.Address, start uint64, end *uint64) ([]*bindings.HermesImplementationFundsWithdrawned, error) {
+	if err := wf.inject("FilterHermesFundsWithdrawnEvents"); err != nil {
+		return nil, err
+	}
+	return wf.bc.FilterHermesFundsWithdrawnEvents(hermesID, start, end)
+}
+
+// SubscribeToBeneficiaryChangedEvents subscribes to identity beneficiary change events.
+func (wf *WithFaults) SubscribeToBeneficiaryChangedEvents(registryAddress common.Address, identities []common.Address) (chan *bindings.RegistryBeneficiaryChanged, *Subscription, error) {
+	if err := wf.inject("SubscribeToBeneficiaryChangedEvents"); err != nil {
+		return nil, nil, err
+	}
+	sink, sub, err := wf.bc.SubscribeToBeneficiaryChangedEvents(registryAddress, identities)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, faulty := wf.wrapSubscription("SubscribeToBeneficiaryChangedEvents", sink, sub)
+	return out.(chan *bindings.RegistryBeneficiaryChanged), faulty, nil
+}
+
+// FilterBeneficiaryChangedEvents returns the identity beneficiary change events in the given block range.
+func (wf *WithFaults) FilterBeneficiaryChangedEvents(registryAddress common.Address, identities []common.Address, start uint64, end *uint64) ([]*bindings.RegistryBeneficiaryChanged, error) {
+	if err := wf.inject("FilterBeneficiaryChangedEvents"); err != nil {
+		return nil, err
+	}
+	return wf.bc.FilterBeneficiaryChangedEvents(registryAddress, identities, start, end)
+}
+
+// SubscribeToChannelWithdrawEvents subscribes to consumer channel withdrawal events.
+func (wf *WithFaults) SubscribeToChannelWithdrawEvents(channelAddress common.Address) (chan *bindings.ChannelImplementationWithdraw, *Subscription, error) {
+	if err := wf.inject("SubscribeToChannelWithdrawEvents"); err != nil {
+		return nil, nil, err
+	}
+	sink, sub, err := wf.bc.SubscribeToChannelWithdrawEvents(channelAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, faulty := wf.wrapSubscription("SubscribeToChannelWithdrawEvents", sink, sub)
+	return out.(chan *bindings.ChannelImplementationWithdraw), faulty, nil
+}
+
+// FilterChannelWithdrawEvents returns the consumer channel withdrawal events in the given block range.
+func (wf *WithFaults) FilterChannelWithdrawEvents(channelAddress common.Address, start uint64, end *uint64) ([]*bindings.ChannelImplementationWithdraw, error) {
+	if err := wf.inject("FilterChannelWithdrawEvents"); err != nil {
+		return nil, err
+	}
+	return wf.bc.FilterChannelWithdrawEvents(channelAddress, start, end)
+}
+
+// StreamLogs streams the logs matching the given query.
+// The stream reconnects on its own, so the call is not retried.
+func (wf *WithFaults) StreamLogs(ctx context.Context, q ethereum.FilterQuery) (*LogStream, error) {
+	if err := wf.inject("StreamLogs"); err != nil {
+		return nil, err
+	}
+	return wf.bc.StreamLogs(ctx, q)
+}
+
+// StreamLogsFrom resumes a log stream after the given cursor.
+func (wf *WithFaults) StreamLogsFrom(ctx context.Context, q ethereum.FilterQuery, cursor LogCursor) (*LogStream, error) {
+	if err := wf.inject("StreamLogsFrom"); err != nil {
+		return nil, err
+	}
+	return wf.bc.StreamLogsFrom(ctx, q, cursor)
+}
+
+// StreamLogsNamed streams the logs matching the given query, resuming after the offset committed under the name.
+func (wf *WithFaults) StreamLogsNamed(ctx context.Context, name string, q ethereum.FilterQuery, offsets OffsetStore) (*LogStream, error) {
+	if err := wf.inject("StreamLogsNamed"); err != nil {
+		return nil, err
+	}
+	return wf.bc.StreamLogsNamed(ctx, name, q, offsets)
+}
+
+// GetTokenDecimals returns the decimals of the ERC-20 token.
+func (wf *WithFaults) GetTokenDecimals(token common.Address) (uint8, error) {
+	if err := wf.inject("GetTokenDecimals"); err != nil {
+		return 0, err
+	}
+	return wf.bc.GetTokenDecimals(token)
+}
@@ -0,0 +1,101 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/stretchr/testify/assert"
+)
+
+type faultsTestBC struct {
+	BC
+	calls  int
+	events chan *bindings.MystTokenTransfer
+}
+
+func (f *faultsTestBC) GetEthBalance(address common.Address) (*big.Int, error) {
+	f.calls++
+	return big.NewInt(1), nil
+}
+
+func (f *faultsTestBC) SubscribeToMystTokenTransfers(mystSCAddress common.Address) (chan *bindings.MystTokenTransfer, *Subscription, error) {
+	sink := make(chan *bindings.MystTokenTransfer)
+	sub := event.NewSubscription(func(quit <-chan struct{}) error {
+		for {
+			select {
+			case <-quit:
+				return nil
+			case ev := <-f.events:
+				select {
+				case sink <- ev:
+				case <-quit:
+					return nil
+				}
+			}
+		}
+	})
+	return sink, (&Blockchain{}).newSubscription("Transfer", mystSCAddress, sub, func() { close(sink) }), nil
+}
+
+func TestWithFaultsErrors(t *testing.T) {
+	bc := &faultsTestBC{}
+	wf := NewWithFaults(bc, FaultConfig{ErrorRate: 1}, 1)
+
+	_, err := wf.GetEthBalance(common.Address{})
+	assert.Equal(t, ErrInjectedFault, err)
+	assert.Equal(t, 0, bc.calls)
+
+	wf.SetFaults(FaultConfig{ErrorRate: 1, Methods: []string{"GetMystBalance"}})
+	balance, err := wf.GetEthBalance(common.Address{})
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1), balance)
+
+	wf.SetFaults(FaultConfig{MinLatency: 20 * time.Millisecond, MaxLatency: 30 * time.Millisecond})
+	start := time.Now()
+	_, err = wf.GetEthBalance(common.Address{})
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+	assert.Equal(t, 2, bc.calls)
+}
+
+func TestWithFaultsSubscription(t *testing.T) {
+	bc := &faultsTestBC{events: make(chan *bindings.MystTokenTransfer)}
+	wf := NewWithFaults(bc, FaultConfig{DuplicateRate: 1}, 1)
+
+	sink, sub, err := wf.SubscribeToMystTokenTransfers(common.Address{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Transfer", sub.Meta().Event)
+
+	ev := &bindings.MystTokenTransfer{Value: big.NewInt(5)}
+	bc.events <- ev
+	assert.Equal(t, ev, <-sink)
+	assert.Equal(t, ev, <-sink)
+
+	wf.SetFaults(FaultConfig{DropRate: 1})
+	bc.events <- ev
+	assert.Equal(t, ev, <-sink)
+	assert.Equal(t, ErrInjectedFault, <-sub.Err())
+	<-sub.Done()
+	_, open := <-sink
+	assert.False(t, open)
+}
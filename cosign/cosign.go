@@ -0,0 +1,233 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package cosign gates promise issuance behind k-of-n approvals, for corporate consumer accounts.
+package cosign
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/mysteriumnetwork/payments/alerts"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+var (
+	// ErrNotApprover is returned for approvals signed by an address that is not an approver.
+	ErrNotApprover = errors.New("signer is not an approver")
+	// ErrNotEnoughApprovals is returned when finalizing a proposal below the approval threshold.
+	ErrNotEnoughApprovals = errors.New("not enough approvals")
+	// ErrProposalNotFound is returned for unknown proposals.
+	ErrProposalNotFound = errors.New("proposal not found")
+)
+
+// ApprovalRequestRule is the rule name of the alerts sent to request approvals.
+const ApprovalRequestRule = "promise_approval"
+
+// HashSigner signs hashes, the keystore can be used.
+type HashSigner interface {
+	SignHash(a accounts.Account, hash []byte) ([]byte, error)
+}
+
+// Policy requires Threshold of the Approvers to approve a promise before it is signed.
+type Policy struct {
+	Approvers []common.Address
+	Threshold int
+}
+
+// Validate checks that the threshold can be reached.
+func (p Policy) Validate() error {
+	if p.Threshold < 1 {
+		return errors.New("approval threshold must be at least 1")
+	}
+	if p.Threshold > len(p.Approvers) {
+		return fmt.Errorf("approval threshold %v is higher than the %v approvers", p.Threshold, len(p.Approvers))
+	}
+	return nil
+}
+
+func (p Policy) isApprover(addr common.Address) bool {
+	for _, a := range p.Approvers {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Proposal is a promise waiting for approvals.
+type Proposal struct {
+	// ID is the hex encoded hash of the promise.
+	ID string
+	// Promise is signed by the designated signer once the proposal is final.
+	Promise crypto.Promise
+	// Approvals are the signatures of the approvers over the promise, keyed by approver.
+	Approvals map[common.Address][]byte
+	CreatedAt time.Time
+	Final     bool
+}
+
+// Storage persists the proposals.
+type Storage interface {
+	UpsertProposal(p Proposal) error
+	// GetProposal returns nil if the proposal does not exist.
+	GetProposal(id string) (*Proposal, error)
+}
+
+// Workflow collects approvals for promises and signs them with the designated signer once the policy is met.
+// Approvers sign the very same promise message the designated signer does, with their own keys.
+type Workflow struct {
+	policy   Policy
+	storage  Storage
+	notifier alerts.Notifier
+	ks       HashSigner
+	signer   common.Address
+	now      func() time.Time
+
+	lock sync.Mutex
+}
+
+// NewWorkflow returns a new co-signing workflow. Approval requests are delivered through the notifier.
+func NewWorkflow(policy Policy, storage Storage, notifier alerts.Notifier, ks HashSigner, signer common.Address) (*Workflow, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &Workflow{
+		policy:   policy,
+		storage:  storage,
+		notifier: notifier,
+		ks:       ks,
+		signer:   signer,
+		now:      time.Now,
+	}, nil
+}
+
+// Propose stores the unsigned promise and requests the approvals. Proposing the same promise again only repeats the request.
+func (w *Workflow) Propose(promise crypto.Promise) (Proposal, error) {
+	promise.Signature = nil
+	id := hexutil.Encode(promise.GetHash())
+
+	w.lock.Lock()
+	p, err := w.storage.GetProposal(id)
+	if err == nil && p == nil {
+		p = &Proposal{
+			ID:        id,
+			Promise:   promise,
+			Approvals: make(map[common.Address][]byte),
+			CreatedAt: w.now().UTC(),
+		}
+		err = w.storage.UpsertProposal(*p)
+	}
+	w.lock.Unlock()
+	if err != nil {
+		return Proposal{}, err
+	}
+
+	if !p.Final {
+		err = w.notifier.Notify(alerts.Alert{
+			Rule:     ApprovalRequestRule,
+			Severity: alerts.SeverityWarning,
+			Message: fmt.Sprintf("promise %v of %v to channel %v needs %v of %v approvals, %v given",
+				id, promise.Amount, hexutil.Encode(promise.ChannelID), w.policy.Threshold, len(w.policy.Approvers), len(p.Approvals)),
+			Time: w.now(),
+		})
+		if err != nil {
+			return *p, fmt.Errorf("could not request approvals: %w", err)
+		}
+	}
+	return *p, nil
+}
+
+// Approve records the approval signature. Once the threshold is reached the promise is signed by the designated signer.
+func (w *Workflow) Approve(id string, signature []byte) (Proposal, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	p, err := w.get(id)
+	if err != nil {
+		return Proposal{}, err
+	}
+	if p.Final {
+		return *p, nil
+	}
+
+	signed := p.Promise
+	signed.Signature = signature
+	approver, err := signed.RecoverSigner()
+	if err != nil {
+		return Proposal{}, fmt.Errorf("could not recover approver: %w", err)
+	}
+	if !w.policy.isApprover(approver) {
+		return Proposal{}, fmt.Errorf("%w: %v", ErrNotApprover, approver.Hex())
+	}
+
+	p.Approvals[approver] = signature
+	if err := w.finalize(p); err != nil && !errors.Is(err, ErrNotEnoughApprovals) {
+		return Proposal{}, err
+	}
+	return *p, w.storage.UpsertProposal(*p)
+}
+
+// Get returns the proposal.
+func (w *Workflow) Get(id string) (Proposal, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	p, err := w.get(id)
+	if err != nil {
+		return Proposal{}, err
+	}
+	return *p, nil
+}
+
+func (w *Workflow) finalize(p *Proposal) error {
+	approved := 0
+	for approver := range p.Approvals {
+		if w.policy.isApprover(approver) {
+			approved++
+		}
+	}
+	if approved < w.policy.Threshold {
+		return fmt.Errorf("%w: %v of %v", ErrNotEnoughApprovals, approved, w.policy.Threshold)
+	}
+
+	signature, err := p.Promise.CreateSignature(w.ks, w.signer)
+	if err != nil {
+		return fmt.Errorf("could not sign promise: %w", err)
+	}
+	if err := crypto.ReformatSignatureVForBC(signature); err != nil {
+		return fmt.Errorf("failed to reformat signature: %w", err)
+	}
+	p.Promise.Signature = signature
+	p.Final = true
+	return nil
+}
+
+func (w *Workflow) get(id string) (*Proposal, error) {
+	p, err := w.storage.GetProposal(id)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, fmt.Errorf("%w: %v", ErrProposalNotFound, id)
+	}
+	return p, nil
+}
@@ -0,0 +1,117 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cosign
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/mysteriumnetwork/payments/alerts"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type keySigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (ks keySigner) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
+	return ethcrypto.Sign(hash, ks.key)
+}
+
+func newSigner(t *testing.T) (keySigner, common.Address) {
+	key, err := ethcrypto.GenerateKey()
+	assert.NoError(t, err)
+	return keySigner{key: key}, ethcrypto.PubkeyToAddress(key.PublicKey)
+}
+
+type memStorage map[string]Proposal
+
+func (m memStorage) UpsertProposal(p Proposal) error { m[p.ID] = p; return nil }
+func (m memStorage) GetProposal(id string) (*Proposal, error) {
+	p, ok := m[id]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+func TestWorkflow(t *testing.T) {
+	var approvers []keySigner
+	var policy Policy
+	for i := 0; i < 3; i++ {
+		ks, addr := newSigner(t)
+		approvers = append(approvers, ks)
+		policy.Approvers = append(policy.Approvers, addr)
+	}
+	policy.Threshold = 2
+	outsider, _ := newSigner(t)
+	designated, designatedAddr := newSigner(t)
+
+	var notified []alerts.Alert
+	notifier := alerts.NotifierFunc(func(a alerts.Alert) error {
+		notified = append(notified, a)
+		return nil
+	})
+
+	w, err := NewWorkflow(policy, memStorage{}, notifier, designated, designatedAddr)
+	assert.NoError(t, err)
+
+	promise := crypto.Promise{
+		ChannelID: common.HexToHash("0x1").Bytes(),
+		ChainID:   1,
+		Amount:    big.NewInt(100),
+		Fee:       big.NewInt(1),
+		Hashlock:  common.HexToHash("0x2").Bytes(),
+	}
+	p, err := w.Propose(promise)
+	assert.NoError(t, err)
+	assert.Len(t, notified, 1)
+	assert.Equal(t, ApprovalRequestRule, notified[0].Rule)
+
+	approve := func(ks keySigner) (Proposal, error) {
+		sig, err := promise.CreateSignature(ks, common.Address{})
+		assert.NoError(t, err)
+		return w.Approve(p.ID, sig)
+	}
+
+	_, err = approve(outsider)
+	assert.True(t, errors.Is(err, ErrNotApprover))
+
+	p, err = approve(approvers[0])
+	assert.NoError(t, err)
+	assert.False(t, p.Final)
+	assert.Nil(t, p.Promise.Signature)
+
+	// approving twice does not count twice
+	p, err = approve(approvers[0])
+	assert.NoError(t, err)
+	assert.False(t, p.Final)
+
+	p, err = approve(approvers[2])
+	assert.NoError(t, err)
+	assert.True(t, p.Final)
+	assert.True(t, p.Promise.IsPromiseValid(designatedAddr))
+
+	_, err = w.Approve("0x00", nil)
+	assert.True(t, errors.Is(err, ErrProposalNotFound))
+}
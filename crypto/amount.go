@@ -0,0 +1,153 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// MystDecimals is the number of decimals of the myst token.
+const MystDecimals = 18
+
+// MaxDecimals is the largest number of token decimals the amount helpers handle.
+const MaxDecimals = 77
+
+// ErrInvalidAmount is returned when a token amount can not be parsed.
+var ErrInvalidAmount = errors.New("invalid token amount")
+
+// RoundingMode defines how the digits beyond the formatting precision are handled.
+type RoundingMode int
+
+const (
+	// RoundHalfEven rounds to the nearest value and ties to the even one, also known as banker's rounding.
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds to the nearest value and ties away from zero.
+	RoundHalfUp
+	// RoundDown truncates the digits beyond the precision.
+	RoundDown
+)
+
+// ParseMYST parses a decimal myst amount, e.g. "12.5", into its smallest unit representation.
+// Only digits, an optional leading minus sign and a dot as the decimal separator are accepted,
+// regardless of the locale. Amounts with more than 18 decimals are rejected rather than rounded.
+func ParseMYST(s string) (*big.Int, error) {
+	return ParseAmount(s, MystDecimals)
+}
+
+// ParseAmount parses a decimal amount of a token with the given decimals, e.g. 6 for USDC, into its smallest unit representation.
+// It accepts the same format as ParseMYST, amounts with more than decimals fraction digits are rejected.
+func ParseAmount(s string, decimals uint8) (*big.Int, error) {
+	if decimals > MaxDecimals {
+		return nil, fmt.Errorf("%w: unsupported token decimals %v", ErrInvalidAmount, decimals)
+	}
+
+	raw := s
+	negative := strings.HasPrefix(s, "-")
+	if negative {
+		s = s[1:]
+	}
+
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+	if whole == "" && fraction == "" || !isDigits(whole) || !isDigits(fraction) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAmount, raw)
+	}
+	if len(fraction) > int(decimals) {
+		return nil, fmt.Errorf("%w: %q has more than %v decimals", ErrInvalidAmount, raw, decimals)
+	}
+
+	digits := whole + fraction + strings.Repeat("0", int(decimals)-len(fraction))
+	res, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAmount, raw)
+	}
+	if negative {
+		res.Neg(res)
+	}
+	return res, nil
+}
+
+// FormatMYST formats the amount in myst with exactly precision decimals, using banker's rounding.
+func FormatMYST(amount *big.Int, precision int) string {
+	return FormatMYSTWithRounding(amount, precision, RoundHalfEven)
+}
+
+// FormatMYSTWithRounding formats the amount in myst with exactly precision decimals, using the given rounding mode.
+// The precision is capped at 18 decimals. The output does not depend on the locale.
+func FormatMYSTWithRounding(amount *big.Int, precision int, mode RoundingMode) string {
+	return FormatAmount(amount, MystDecimals, precision, mode)
+}
+
+// FormatAmount formats the amount of a token with the given decimals with exactly precision decimals, using the given rounding mode.
+// The precision is capped at the token decimals. The output does not depend on the locale.
+func FormatAmount(amount *big.Int, decimals uint8, precision int, mode RoundingMode) string {
+	if precision < 0 {
+		precision = 0
+	}
+	if precision > int(decimals) {
+		precision = int(decimals)
+	}
+
+	abs := new(big.Int).Abs(bigOrZero(amount))
+	divisor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(int(decimals)-precision)), nil)
+	quotient, remainder := new(big.Int).QuoRem(abs, divisor, new(big.Int))
+	if roundUp(quotient, remainder, divisor, mode) {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+
+	digits := quotient.String()
+	if len(digits) <= precision {
+		digits = strings.Repeat("0", precision-len(digits)+1) + digits
+	}
+
+	res := digits
+	if precision > 0 {
+		res = digits[:len(digits)-precision] + "." + digits[len(digits)-precision:]
+	}
+	if amount != nil && amount.Sign() < 0 && quotient.Sign() != 0 {
+		res = "-" + res
+	}
+	return res
+}
+
+func roundUp(quotient, remainder, divisor *big.Int, mode RoundingMode) bool {
+	if remainder.Sign() == 0 || mode == RoundDown {
+		return false
+	}
+
+	cmp := new(big.Int).Lsh(remainder, 1).Cmp(divisor)
+	switch mode {
+	case RoundHalfUp:
+		return cmp >= 0
+	default:
+		return cmp > 0 || cmp == 0 && quotient.Bit(0) == 1
+	}
+}
+
+func isDigits(s string) bool {
+	for _, c := range []byte(s) {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
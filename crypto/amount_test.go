@@ -0,0 +1,82 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMYST(t *testing.T) {
+	for in, expected := range map[string]string{
+		"12.5":                  "12500000000000000000",
+		"0":                     "0",
+		".5":                    "500000000000000000",
+		"3.":                    "3000000000000000000",
+		"-1.25":                 "-1250000000000000000",
+		"0.000000000000000001":  "1",
+		"100000000000000000000": "100000000000000000000000000000000000000",
+	} {
+		res, err := ParseMYST(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, expected, res.String(), in)
+	}
+
+	for _, in := range []string{"", ".", "-", "1,5", "1e18", "0x10", " 1", "1.2.3", "0.0000000000000000001"} {
+		_, err := ParseMYST(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestFormatMYST(t *testing.T) {
+	amount := func(s string) *big.Int {
+		res, err := ParseMYST(s)
+		assert.NoError(t, err)
+		return res
+	}
+
+	assert.Equal(t, "12.50", FormatMYST(amount("12.5"), 2))
+	assert.Equal(t, "12", FormatMYST(amount("12.5"), 0))
+	assert.Equal(t, "14", FormatMYST(amount("13.5"), 0))
+	assert.Equal(t, "0.12", FormatMYST(amount("0.125"), 2))
+	assert.Equal(t, "0.13", FormatMYSTWithRounding(amount("0.125"), 2, RoundHalfUp))
+	assert.Equal(t, "0.12", FormatMYSTWithRounding(amount("0.129"), 2, RoundDown))
+	assert.Equal(t, "0.000000000000000001", FormatMYST(big.NewInt(1), 30))
+	assert.Equal(t, "-1.5", FormatMYST(amount("-1.5"), 1))
+	assert.Equal(t, "0.0", FormatMYST(amount("-0.01"), 1))
+	assert.Equal(t, "0.00", FormatMYST(nil, 2))
+}
+
+func TestAmountWithDecimals(t *testing.T) {
+	res, err := ParseAmount("12.5", 6)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(12500000), res)
+
+	_, err = ParseAmount("0.0000001", 6)
+	assert.Error(t, err)
+	_, err = ParseAmount("1", MaxDecimals+1)
+	assert.Error(t, err)
+
+	assert.Equal(t, "12.50", FormatAmount(big.NewInt(12500000), 6, 2, RoundHalfEven))
+	assert.Equal(t, "0.000001", FormatAmount(big.NewInt(1), 6, 18, RoundHalfEven))
+	assert.Equal(t, "3", FormatAmount(big.NewInt(3), 0, 2, RoundHalfEven))
+
+	assert.Equal(t, 1.5, AmountToFloat(big.NewInt(1500000), 6))
+	assert.Equal(t, big.NewInt(1500000), FloatToAmount(1.5, 6))
+}
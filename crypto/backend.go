@@ -0,0 +1,69 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Backend performs the keccak256 hashing and secp256k1 signature recovery of the package.
+// It can be replaced to use certified implementations, e.g. in FIPS environments.
+// Signing is never done by the package itself, it is delegated to the SignHash implementations passed in.
+type Backend interface {
+	Keccak256(data ...[]byte) []byte
+	// Ecrecover returns the uncompressed public key that created the 65 byte signature, with V being 0 or 1.
+	Ecrecover(hash, sig []byte) ([]byte, error)
+}
+
+// DefaultBackend is the backend based on go-ethereum.
+var DefaultBackend Backend = goEthereumBackend{}
+
+var backend = DefaultBackend
+
+// SetBackend replaces the hashing and recovery backend, nil restores the DefaultBackend.
+// Not thread safe, call before using the package.
+func SetBackend(b Backend) {
+	if b == nil {
+		b = DefaultBackend
+	}
+	backend = b
+}
+
+type goEthereumBackend struct{}
+
+func (goEthereumBackend) Keccak256(data ...[]byte) []byte {
+	return crypto.Keccak256(data...)
+}
+
+func (goEthereumBackend) Ecrecover(hash, sig []byte) ([]byte, error) {
+	return crypto.Ecrecover(hash, sig)
+}
+
+func keccak256(data ...[]byte) []byte {
+	return backend.Keccak256(data...)
+}
+
+// Keccak256 hashes the data with the configured backend.
+func Keccak256(data ...[]byte) []byte {
+	return keccak256(data...)
+}
+
+// Keccak256Hash hashes the data with the configured backend and returns it as a hash.
+func Keccak256Hash(data ...[]byte) common.Hash {
+	return common.BytesToHash(keccak256(data...))
+}
@@ -0,0 +1,69 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingBackend struct {
+	hashes, recoveries int
+}
+
+func (b *countingBackend) Keccak256(data ...[]byte) []byte {
+	b.hashes++
+	return DefaultBackend.Keccak256(data...)
+}
+
+func (b *countingBackend) Ecrecover(hash, sig []byte) ([]byte, error) {
+	b.recoveries++
+	return DefaultBackend.Ecrecover(hash, sig)
+}
+
+func TestSetBackend(t *testing.T) {
+	SetRecoveryCacheSize(0)
+	defer SetRecoveryCacheSize(DefaultRecoveryCacheSize)
+
+	b := &countingBackend{}
+	SetBackend(b)
+	defer SetBackend(nil)
+
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+
+	p, err := CreatePromise(common.HexToHash("0x1").Hex(), 1, big.NewInt(10), big.NewInt(0), common.HexToHash("0x2").Hex(), ecdsaSigner{key: key}, signer)
+	assert.NoError(t, err)
+	hashes := b.hashes
+	recovered, err := p.RecoverSigner()
+	assert.NoError(t, err)
+	assert.Equal(t, signer, recovered)
+
+	// the message hash and the address derived from the recovered public key
+	assert.Equal(t, hashes+2, b.hashes)
+	assert.Equal(t, 1, b.recoveries)
+
+	hashes = b.hashes
+	assert.Equal(t, crypto.Keccak256Hash([]byte{1}), Keccak256Hash([]byte{1}))
+	assert.Equal(t, crypto.Keccak256([]byte{1}), Keccak256([]byte{1}))
+	assert.Equal(t, hashes+2, b.hashes)
+}
@@ -0,0 +1,107 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrInvalidSignature is returned when a promise is not signed by the expected signer.
+var ErrInvalidSignature = errors.New("invalid promise signature")
+
+// SignedPromise is a promise together with the identity expected to have signed it.
+type SignedPromise struct {
+	Promise Promise
+	Signer  common.Address
+}
+
+// BatchVerificationError tells which promise of a batch failed the verification.
+type BatchVerificationError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchVerificationError) Error() string {
+	return fmt.Sprintf("promise %v: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the verification error of the promise.
+func (e *BatchVerificationError) Unwrap() error {
+	return e.Err
+}
+
+// VerifyPromises verifies the promise signatures concurrently using the given number of workers,
+// or one per CPU if workers is not positive.
+// Verification stops at the first invalid promise and a *BatchVerificationError is returned for it.
+// When several promises are invalid, which one is reported depends on scheduling.
+func VerifyPromises(promises []SignedPromise, workers int) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(promises) {
+		workers = len(promises)
+	}
+
+	var (
+		next    int64 = -1
+		aborted int32
+		once    sync.Once
+		failure error
+		wg      sync.WaitGroup
+	)
+
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&aborted) == 0 {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(promises) {
+					return
+				}
+
+				if err := verifySignedPromise(promises[i]); err != nil {
+					once.Do(func() {
+						failure = &BatchVerificationError{Index: i, Err: err}
+						atomic.StoreInt32(&aborted, 1)
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return failure
+}
+
+func verifySignedPromise(sp SignedPromise) error {
+	signer, err := sp.Promise.RecoverSigner()
+	if err != nil {
+		return err
+	}
+	if signer != sp.Signer {
+		return fmt.Errorf("%w: signed by %v instead of %v", ErrInvalidSignature, signer.Hex(), sp.Signer.Hex())
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type ecdsaSigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (s ecdsaSigner) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
+	return crypto.Sign(hash, s.key)
+}
+
+func signedPromises(t testing.TB, n int) []SignedPromise {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+
+	res := make([]SignedPromise, n)
+	for i := range res {
+		p, err := CreatePromise(common.HexToHash("0x1").Hex(), 1, big.NewInt(int64(i+1)), big.NewInt(0), common.HexToHash("0x2").Hex(), ecdsaSigner{key: key}, signer)
+		assert.NoError(t, err)
+		res[i] = SignedPromise{Promise: *p, Signer: signer}
+	}
+	return res
+}
+
+func TestVerifyPromises(t *testing.T) {
+	promises := signedPromises(t, 50)
+	assert.NoError(t, VerifyPromises(promises, 4))
+	assert.NoError(t, VerifyPromises(promises, 0))
+	assert.NoError(t, VerifyPromises(nil, 4))
+
+	promises[17].Signer = common.HexToAddress("0x3")
+	err := VerifyPromises(promises, 4)
+	var batchErr *BatchVerificationError
+	assert.True(t, errors.As(err, &batchErr))
+	assert.Equal(t, 17, batchErr.Index)
+	assert.True(t, errors.Is(err, ErrInvalidSignature))
+
+	promises[17].Signer = promises[0].Signer
+	promises[30].Promise.Signature = []byte{1}
+	err = VerifyPromises(promises, 1)
+	assert.True(t, errors.As(err, &batchErr))
+	assert.Equal(t, 30, batchErr.Index)
+}
+
+func benchmarkVerification(b *testing.B, verify func([]SignedPromise) error) {
+	promises := signedPromises(b, 256)
+	SetRecoveryCacheSize(0)
+	defer SetRecoveryCacheSize(DefaultRecoveryCacheSize)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := verify(promises); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerifyPromisesSerial(b *testing.B) {
+	benchmarkVerification(b, func(promises []SignedPromise) error {
+		for _, sp := range promises {
+			if !sp.Promise.IsPromiseValid(sp.Signer) {
+				return ErrInvalidSignature
+			}
+		}
+		return nil
+	})
+}
+
+func BenchmarkVerifyPromisesBatch(b *testing.B) {
+	benchmarkVerification(b, func(promises []SignedPromise) error {
+		return VerifyPromises(promises, 0)
+	})
+}
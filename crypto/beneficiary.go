@@ -26,7 +26,6 @@ import (
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
-	"github.com/ethereum/go-ethereum/crypto"
 )
 
 // SetBeneficiaryRequest represents a request for setting new beneficiary.
@@ -100,7 +99,7 @@ func NewBeneficiaryRequest(chainID int64, identity, registry, beneficiary string
 // CreateSignature signs set beneficiary request using keystore
 func (r SetBeneficiaryRequest) CreateSignature(ks hashSigner, signer common.Address) ([]byte, error) {
 	message := r.GetMessage()
-	hash := crypto.Keccak256(message)
+	hash := keccak256(message)
 	return ks.SignHash(
 		accounts.Account{Address: signer},
 		hash,
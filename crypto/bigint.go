@@ -0,0 +1,62 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+)
+
+// BigInt is a big.Int encoded as a decimal JSON string.
+// JSON numbers above 2^53 lose precision in consumers that parse them as floats, e.g. javascript, so amounts are never encoded as numbers.
+// Decoding accepts both decimal strings and numbers to stay compatible with previously encoded values.
+// A *big.Int converts to *BigInt and back without copying, (*BigInt)(amount).
+type BigInt big.Int
+
+// Int returns the value as a *big.Int.
+func (b *BigInt) Int() *big.Int {
+	return (*big.Int)(b)
+}
+
+// String returns the decimal representation of the value.
+func (b *BigInt) String() string {
+	return b.Int().String()
+}
+
+// MarshalJSON encodes the value as a decimal string.
+func (b *BigInt) MarshalJSON() ([]byte, error) {
+	if b == nil {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + b.Int().String() + `"`), nil
+}
+
+// UnmarshalJSON decodes a decimal string or a number.
+func (b *BigInt) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		data = data[1 : len(data)-1]
+	}
+	if _, ok := b.Int().SetString(string(data), 10); !ok {
+		return fmt.Errorf("invalid big integer %s", data)
+	}
+	return nil
+}
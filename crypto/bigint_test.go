@@ -0,0 +1,72 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBigIntJSON(t *testing.T) {
+	large, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+
+	b, err := json.Marshal((*BigInt)(large))
+	assert.NoError(t, err)
+	assert.Equal(t, `"123456789012345678901234567890"`, string(b))
+
+	for _, in := range []string{`"123456789012345678901234567890"`, `123456789012345678901234567890`} {
+		var res BigInt
+		assert.NoError(t, json.Unmarshal([]byte(in), &res))
+		assert.Equal(t, large, res.Int())
+	}
+
+	var res BigInt
+	assert.Error(t, json.Unmarshal([]byte(`"1.5"`), &res))
+
+	b, err = json.Marshal(struct{ V *BigInt }{})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"V":null}`, string(b))
+}
+
+func TestPromiseJSON(t *testing.T) {
+	large, _ := new(big.Int).SetString("900719925474099312345", 10)
+	p := Promise{ChannelID: []byte{1}, ChainID: 5, Amount: large, Fee: big.NewInt(7), Hashlock: []byte{2}}
+
+	b, err := json.Marshal(p)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"Amount":"900719925474099312345"`)
+	assert.Contains(t, string(b), `"ChainID":5`)
+
+	var decoded Promise
+	assert.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, p, decoded)
+
+	// values encoded as numbers before are still accepted
+	assert.NoError(t, json.Unmarshal([]byte(`{"ChainID":5,"Amount":900719925474099312345,"Fee":7}`), &decoded))
+	assert.Equal(t, large, decoded.Amount)
+
+	ep := ExpiringPromise{Promise: p, Validity: PromiseValidity{ValidUntilBlock: 10, ValidUntil: time.Unix(100, 0).UTC()}, ValiditySignature: []byte{3}}
+	b, err = json.Marshal(ep)
+	assert.NoError(t, err)
+	var decodedEP ExpiringPromise
+	assert.NoError(t, json.Unmarshal(b, &decodedEP))
+	assert.Equal(t, ep, decodedEP)
+}
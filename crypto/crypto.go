@@ -22,21 +22,12 @@ import (
 	"errors"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
 )
 
 // RecoverAddress recovers the address from message and signature
+// Recovered addresses are cached, see SetRecoveryCacheSize.
 func RecoverAddress(message []byte, signature []byte) (common.Address, error) {
-	publicKey, err := crypto.Ecrecover(crypto.Keccak256(message), signature)
-	if err != nil {
-		return common.Address{}, err
-	}
-	pubKey, err := crypto.UnmarshalPubkey(publicKey)
-	if err != nil {
-		return common.Address{}, err
-	}
-	recoveredAddress := crypto.PubkeyToAddress(*pubKey)
-	return recoveredAddress, nil
+	return recoveryCache.Recover(keccak256(message), signature)
 }
 
 // GetProxyCode generates bytecode of minimal proxy contract (EIP 1167)
@@ -52,8 +43,8 @@ func deriveCreate2Address(salt string, msgSender string, implementation string)
 
 	bytecode, _ := GetProxyCode(ensureNoPrefix(implementation))
 
-	input, _ := hex.DecodeString("ff" + ensureNoPrefix(msgSender) + ensureNoPrefix(salt) + common.Bytes2Hex(crypto.Keccak256(bytecode)))
-	return "0x" + common.Bytes2Hex(crypto.Keccak256(input))[24:], nil
+	input, _ := hex.DecodeString("ff" + ensureNoPrefix(msgSender) + ensureNoPrefix(salt) + common.Bytes2Hex(keccak256(bytecode)))
+	return "0x" + common.Bytes2Hex(keccak256(input))[24:], nil
 }
 
 // GenerateChannelAddress generate channel address from given identity hash
@@ -67,7 +58,7 @@ func GenerateChannelAddress(identity, hermes, registry, channelImplementation st
 		return "", err
 	}
 
-	salt := hex.EncodeToString(crypto.Keccak256(saltBytes))
+	salt := hex.EncodeToString(keccak256(saltBytes))
 	return deriveCreate2Address(salt, registry, channelImplementation)
 }
 
@@ -77,7 +68,7 @@ func GenerateProviderChannelID(providerIdentity, hermesAddress string) (string,
 		return "", errors.New("given providerIdentity and hermesAddress params have to be hex addresses")
 	}
 
-	channelID := crypto.Keccak256(append(
+	channelID := keccak256(append(
 		common.HexToAddress(providerIdentity).Bytes(),
 		common.HexToAddress(hermesAddress).Bytes()...,
 	))
@@ -87,7 +78,7 @@ func GenerateProviderChannelID(providerIdentity, hermesAddress string) (string,
 
 // GenerateProviderChannelIDBytes received provider and accountnat Address
 func GenerateProviderChannelIDBytes(providerIdentity, hermesAddress common.Address) []byte {
-	return crypto.Keccak256(append(
+	return keccak256(append(
 		providerIdentity.Bytes(),
 		hermesAddress.Bytes()...,
 	))
@@ -107,10 +98,13 @@ func GenerateHermesAddress(operator string, registry string, hermesImplementatio
 	return deriveCreate2Address(salt, registry, hermesImplementation)
 }
 
+// ErrInvalidSignatureLength is returned when reformatting a signature that is not 65 bytes long.
+var ErrInvalidSignatureLength = errors.New("the signature must be 65 bytes long")
+
 // ReformatSignatureVForBC takes in the signature and modifies its last byte to correspond to the format required for SC
 func ReformatSignatureVForBC(signature []byte) error {
 	if len(signature) != 65 {
-		return errors.New("the signature must be 65 bytes long")
+		return ErrInvalidSignatureLength
 	}
 
 	var v = 27 + (uint64(signature[len(signature)-1]) % 2)
@@ -124,7 +118,7 @@ func ReformatSignatureVForBC(signature []byte) error {
 // ReformatSignatureVForRecovery takes in  the signature and modifies its last byte to normalize V to either 0 or 1
 func ReformatSignatureVForRecovery(signature []byte) error {
 	if len(signature) != 65 {
-		return errors.New("the signature must be 65 bytes long")
+		return ErrInvalidSignatureLength
 	}
 
 	v := uint64(signature[64]) % 27
@@ -0,0 +1,249 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// describePrecision is the number of decimals amounts are rendered with in descriptions.
+const describePrecision = 6
+
+// Field is a single named value of a description.
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Description is a human readable rendering of a signed payment object, for inspection and support tooling.
+type Description struct {
+	Kind string `json:"kind"`
+	// Summary tells who pays whom and how much in a single sentence.
+	Summary string  `json:"summary"`
+	Fields  []Field `json:"fields"`
+	// Signer is the address recovered from the signature, empty if it could not be recovered.
+	Signer string `json:"signer,omitempty"`
+	// SignatureError explains why the signer could not be recovered.
+	SignatureError string `json:"signatureError,omitempty"`
+}
+
+// SignedBy checks whether the signature was recovered to the given address.
+func (d Description) SignedBy(address common.Address) bool {
+	return d.Signer != "" && common.HexToAddress(d.Signer) == address
+}
+
+// String renders the description as indented text.
+func (d Description) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%v: %v\n", d.Kind, d.Summary)
+
+	width := len("signer")
+	for _, f := range d.Fields {
+		if len(f.Name) > width {
+			width = len(f.Name)
+		}
+	}
+	for _, f := range d.Fields {
+		fmt.Fprintf(&sb, "  %-*v  %v\n", width, f.Name, f.Value)
+	}
+	if d.SignatureError != "" {
+		fmt.Fprintf(&sb, "  %-*v  invalid: %v\n", width, "signer", d.SignatureError)
+	} else {
+		fmt.Fprintf(&sb, "  %-*v  %v\n", width, "signer", d.Signer)
+	}
+	return sb.String()
+}
+
+// JSON renders the description as indented JSON.
+func (d Description) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+func (d *Description) add(name, value string) {
+	d.Fields = append(d.Fields, Field{Name: name, Value: value})
+}
+
+func (d *Description) setSigner(signature []byte, recover func() (common.Address, error)) {
+	if len(signature) == 0 || bytes.Equal(signature, make([]byte, len(signature))) {
+		d.SignatureError = "not signed"
+		return
+	}
+	signer, err := recover()
+	if err != nil {
+		d.SignatureError = err.Error()
+		return
+	}
+	d.Signer = signer.Hex()
+}
+
+func describeAmount(amount *big.Int) string {
+	if amount == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%v MYST (%v wei)", FormatMYST(amount, describePrecision), amount)
+}
+
+func shortAmount(amount *big.Int) string {
+	if amount == nil {
+		return "nothing"
+	}
+	return FormatMYST(amount, describePrecision) + " MYST"
+}
+
+// Describe renders the promise.
+func (p Promise) Describe() Description {
+	d := Description{
+		Kind:    "promise",
+		Summary: fmt.Sprintf("the signer promises channel %v a total of %v, fee %v", hexutil.Encode(p.ChannelID), shortAmount(p.Amount), shortAmount(p.Fee)),
+	}
+	d.add("channel", hexutil.Encode(p.ChannelID))
+	d.add("chain", fmt.Sprint(p.ChainID))
+	d.add("amount", describeAmount(p.Amount))
+	d.add("fee", describeAmount(p.Fee))
+	d.add("hashlock", hexutil.Encode(p.Hashlock))
+	switch {
+	case len(p.R) == 0:
+		d.add("R", "not revealed")
+	case bytes.Equal(keccak256(p.R), p.Hashlock):
+		d.add("R", hexutil.Encode(p.R)+" (matches hashlock)")
+	default:
+		d.add("R", hexutil.Encode(p.R)+" (does not match hashlock)")
+	}
+	d.setSigner(p.Signature, p.RecoverSigner)
+	return d
+}
+
+// Describe renders the expiring promise, including its validity window.
+func (ep ExpiringPromise) Describe() Description {
+	d := ep.Promise.Describe()
+	d.Kind = "expiring promise"
+
+	var bounds []string
+	if ep.Validity.ValidUntilBlock != 0 {
+		bounds = append(bounds, fmt.Sprintf("block %v", ep.Validity.ValidUntilBlock))
+	}
+	if !ep.Validity.ValidUntil.IsZero() {
+		bounds = append(bounds, ep.Validity.ValidUntil.UTC().Format(time.RFC3339))
+	}
+	if len(bounds) == 0 {
+		bounds = append(bounds, "open")
+	}
+	d.add("valid until", strings.Join(bounds, ", "))
+
+	validity := Description{}
+	validity.setSigner(ep.ValiditySignature, func() (common.Address, error) {
+		return recoverWithSignature(ep.GetValidityMessage(), ep.ValiditySignature)
+	})
+	if validity.SignatureError != "" {
+		d.add("validity signer", "invalid: "+validity.SignatureError)
+	} else {
+		d.add("validity signer", validity.Signer)
+	}
+	return d
+}
+
+// Describe renders the exchange message, the cheque a consumer sends to the provider for a promise.
+func (m ExchangeMessage) Describe() Description {
+	d := Description{
+		Kind: "exchange message",
+		Summary: fmt.Sprintf("the signer pays provider %v %v through hermes %v for agreement %v",
+			m.Provider, shortAmount(m.AgreementTotal), m.HermesID, m.AgreementID),
+	}
+	d.add("provider", m.Provider)
+	d.add("hermes", m.HermesID)
+	d.add("chain", fmt.Sprint(m.ChainID))
+	d.add("agreement id", fmt.Sprint(m.AgreementID))
+	d.add("agreement total", describeAmount(m.AgreementTotal))
+	d.add("promise channel", hexutil.Encode(m.Promise.ChannelID))
+	d.add("promise amount", describeAmount(m.Promise.Amount))
+	d.add("promise fee", describeAmount(m.Promise.Fee))
+
+	promise := m.Promise.Describe()
+	if promise.SignatureError != "" {
+		d.add("promise signer", "invalid: "+promise.SignatureError)
+	} else {
+		d.add("promise signer", promise.Signer)
+	}
+	d.setSigner(m.GetSignatureBytesRaw(), m.RecoverConsumerIdentity)
+	return d
+}
+
+// Describe renders the invalidation record.
+func (pi PromiseInvalidation) Describe() Description {
+	d := Description{
+		Kind:    "promise invalidation",
+		Summary: fmt.Sprintf("the signer voids every promise to channel %v above %v", hexutil.Encode(pi.ChannelID), shortAmount(pi.MaxAmount)),
+	}
+	d.add("channel", hexutil.Encode(pi.ChannelID))
+	d.add("chain", fmt.Sprint(pi.ChainID))
+	d.add("max amount", describeAmount(pi.MaxAmount))
+	d.setSigner(pi.Signature, pi.RecoverSigner)
+	return d
+}
+
+// Describe renders the beneficiary change request.
+func (r SetBeneficiaryRequest) Describe() Description {
+	d := Description{
+		Kind:    "set beneficiary request",
+		Summary: fmt.Sprintf("identity %v pays its earnings to %v from now on", r.Identity, r.Beneficiary),
+	}
+	d.add("identity", r.Identity)
+	d.add("beneficiary", r.Beneficiary)
+	d.add("registry", r.Registry)
+	d.add("chain", fmt.Sprint(r.ChainID))
+	d.add("nonce", fmt.Sprint(r.Nonce))
+	d.setSigner(r.GetSignatureBytesRaw(), r.RecoverSigner)
+	return d
+}
+
+// Describe renders the exit request.
+func (er ExitRequest) Describe() Description {
+	d := Description{
+		Kind:    "exit request",
+		Summary: fmt.Sprintf("channel %v exits paying its balance to %v", er.ChannelID.Hex(), er.Beneficiary.Hex()),
+	}
+	d.add("channel", er.ChannelID.Hex())
+	d.add("beneficiary", er.Beneficiary.Hex())
+	d.add("valid until block", fmt.Sprint(er.ValidUntil))
+	d.setSigner(er.Signature, er.RecoverSigner)
+	return d
+}
+
+// Describe renders the stake decrease request.
+func (dpsr DecreaseProviderStakeRequest) Describe() Description {
+	d := Description{
+		Kind: "decrease stake request",
+		Summary: fmt.Sprintf("provider channel %v takes %v out of its stake with hermes %v, paying %v to the transactor",
+			hexutil.Encode(dpsr.ChannelID[:]), shortAmount(dpsr.Amount), dpsr.HermesID.Hex(), shortAmount(dpsr.TransactorFee)),
+	}
+	d.add("channel", hexutil.Encode(dpsr.ChannelID[:]))
+	d.add("hermes", dpsr.HermesID.Hex())
+	d.add("chain", fmt.Sprint(dpsr.ChainID))
+	d.add("amount", describeAmount(dpsr.Amount))
+	d.add("transactor fee", describeAmount(dpsr.TransactorFee))
+	d.add("nonce", fmt.Sprint(dpsr.Nonce))
+	d.setSigner(dpsr.Signature, dpsr.RecoverSigner)
+	return d
+}
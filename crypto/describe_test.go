@@ -0,0 +1,61 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromiseDescribe(t *testing.T) {
+	promise := getPromise("consumer")
+	consumer := crypto.PubkeyToAddress(getPrivKey("consumer").PublicKey)
+
+	d := promise.Describe()
+	assert.Equal(t, "promise", d.Kind)
+	assert.True(t, d.SignedBy(consumer))
+	assert.Empty(t, d.SignatureError)
+	assert.Contains(t, d.Summary, "0.000000 MYST")
+	assert.Contains(t, d.String(), "1401 wei")
+	assert.Contains(t, d.String(), "not revealed")
+
+	blob, err := d.JSON()
+	assert.NoError(t, err)
+	var decoded Description
+	assert.NoError(t, json.Unmarshal(blob, &decoded))
+	assert.Equal(t, d, decoded)
+
+	promise.R = getParams("consumer").R
+	assert.Contains(t, promise.Describe().String(), "(matches hashlock)")
+
+	promise.Signature = nil
+	d = promise.Describe()
+	assert.Equal(t, "not signed", d.SignatureError)
+	assert.False(t, d.SignedBy(consumer))
+	assert.True(t, strings.HasSuffix(d.String(), "invalid: not signed\n"))
+}
+
+func TestInvalidationDescribe(t *testing.T) {
+	pi := PromiseInvalidation{ChannelID: []byte{1}, ChainID: 5, MaxAmount: big.NewInt(1500000000000000000)}
+	d := pi.Describe()
+	assert.Equal(t, "the signer voids every promise to channel 0x01 above 1.500000 MYST", d.Summary)
+}
@@ -0,0 +1,47 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+// DomainSeparators are the string prefixes mixed into signed messages to bind a signature to its purpose.
+// Forks and test deployments of the contracts may use different prefixes than the official deployments,
+// the prefixes of a deployment are resolved from its contract version, see client.ContractVersionDetector.
+// Empty prefixes fall back to the defaults.
+type DomainSeparators struct {
+	Exit                string
+	StakeReturn         string
+	PromiseInvalidation string
+}
+
+// DefaultDomainSeparators are the prefixes used by the official contract deployments.
+var DefaultDomainSeparators = DomainSeparators{
+	Exit:                ExitPrefix,
+	StakeReturn:         stakeReturnPrefix,
+	PromiseInvalidation: promiseInvalidationPrefix,
+}
+
+func (ds DomainSeparators) withDefaults() DomainSeparators {
+	if ds.Exit == "" {
+		ds.Exit = DefaultDomainSeparators.Exit
+	}
+	if ds.StakeReturn == "" {
+		ds.StakeReturn = DefaultDomainSeparators.StakeReturn
+	}
+	if ds.PromiseInvalidation == "" {
+		ds.PromiseInvalidation = DefaultDomainSeparators.PromiseInvalidation
+	}
+	return ds
+}
@@ -0,0 +1,54 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomainSeparators(t *testing.T) {
+	req := DecreaseProviderStakeRequest{Amount: big.NewInt(1), TransactorFee: big.NewInt(0), Nonce: big.NewInt(1), ChainID: 1}
+	assert.True(t, bytes.HasPrefix(req.GetMessage(), []byte(stakeReturnPrefix)))
+	req.Domain = DomainSeparators{StakeReturn: "Fork stake return"}
+	assert.True(t, bytes.HasPrefix(req.GetMessage(), []byte("Fork stake return")))
+
+	exit := NewExitRequest(common.HexToAddress("0x1"), common.HexToAddress("0x2"), big.NewInt(10))
+	assert.True(t, bytes.HasPrefix(exit.GetMessage(), []byte(ExitPrefix)))
+
+	exit.Domain = DomainSeparators{Exit: "Testnet exit:"}
+	assert.True(t, bytes.HasPrefix(exit.GetMessage(), []byte("Testnet exit:")))
+
+	// the prefix of the deployment is used when signing and recovering alike
+	key := getPrivKey("consumer")
+	sig, err := exit.CreateSignature(ecdsaSigner{key: key}, common.Address{})
+	assert.NoError(t, err)
+	exit.Signature = sig
+	recovered, err := exit.RecoverSigner()
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), recovered)
+
+	exit.Domain = DomainSeparators{}
+	recovered, err = exit.RecoverSigner()
+	assert.NoError(t, err)
+	assert.NotEqual(t, crypto.PubkeyToAddress(key.PublicKey), recovered)
+}
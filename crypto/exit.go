@@ -24,7 +24,6 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
-	"github.com/ethereum/go-ethereum/crypto"
 )
 
 const ExitPrefix = "Exit request:"
@@ -34,6 +33,8 @@ type ExitRequest struct {
 	Beneficiary common.Address
 	ValidUntil  *big.Int
 	Signature   []byte
+	// Domain holds the prefixes of the deployment, the defaults are used if not set.
+	Domain DomainSeparators
 }
 
 func NewExitRequest(channelID, beneficiary common.Address, validUntil *big.Int) *ExitRequest {
@@ -47,7 +48,7 @@ func NewExitRequest(channelID, beneficiary common.Address, validUntil *big.Int)
 
 func (er ExitRequest) GetMessage() []byte {
 	msg := []byte{}
-	msg = append(msg, []byte(ExitPrefix)...)
+	msg = append(msg, []byte(er.Domain.withDefaults().Exit)...)
 	msg = append(msg, Pad(er.ChannelID[:], 32)...)
 	msg = append(msg, Pad(er.Beneficiary[:], 32)...)
 	msg = append(msg, Pad(math.U256(er.ValidUntil).Bytes(), 32)...)
@@ -68,7 +69,7 @@ func (er ExitRequest) RecoverSigner() (common.Address, error) {
 
 func (er ExitRequest) CreateSignature(ks hashSigner, signer common.Address) ([]byte, error) {
 	message := er.GetMessage()
-	hash := crypto.Keccak256(message)
+	hash := keccak256(message)
 	return ks.SignHash(
 		accounts.Account{Address: signer},
 		hash,
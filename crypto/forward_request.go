@@ -0,0 +1,150 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core"
+)
+
+const forwardRequestPrimaryType = "ForwardRequest"
+
+var forwardRequestTypes = core.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+		{Name: "verifyingContract", Type: "address"},
+	},
+	forwardRequestPrimaryType: {
+		{Name: "from", Type: "address"},
+		{Name: "to", Type: "address"},
+		{Name: "value", Type: "uint256"},
+		{Name: "gas", Type: "uint256"},
+		{Name: "nonce", Type: "uint256"},
+		{Name: "data", Type: "bytes"},
+	},
+}
+
+// ForwarderDomain is the EIP-712 domain of an ERC-2771 trusted forwarder contract.
+type ForwarderDomain struct {
+	Name      string
+	Version   string
+	ChainID   int64
+	Forwarder common.Address
+}
+
+// ForwardRequest is a meta-transaction executed by an ERC-2771 trusted forwarder on behalf of From.
+// It follows the request layout of the OpenZeppelin MinimalForwarder.
+type ForwardRequest struct {
+	From  common.Address `json:"from"`
+	To    common.Address `json:"to"`
+	Value *big.Int       `json:"value"`
+	Gas   uint64         `json:"gas"`
+	Nonce *big.Int       `json:"nonce"`
+	Data  []byte         `json:"data"`
+}
+
+// GetTypedData returns the EIP-712 typed data representation of the request for the given forwarder.
+func (fr ForwardRequest) GetTypedData(domain ForwarderDomain) core.TypedData {
+	return core.TypedData{
+		Types:       forwardRequestTypes,
+		PrimaryType: forwardRequestPrimaryType,
+		Domain: core.TypedDataDomain{
+			Name:              domain.Name,
+			Version:           domain.Version,
+			ChainId:           math.NewHexOrDecimal256(domain.ChainID),
+			VerifyingContract: domain.Forwarder.Hex(),
+		},
+		Message: core.TypedDataMessage{
+			"from":  fr.From.Hex(),
+			"to":    fr.To.Hex(),
+			"value": bigOrZero(fr.Value).String(),
+			"gas":   new(big.Int).SetUint64(fr.Gas).String(),
+			"nonce": bigOrZero(fr.Nonce).String(),
+			"data":  hexutil.Encode(fr.Data),
+		},
+	}
+}
+
+// GetTypedDataJSON returns the EIP-712 typed data JSON of the request, for signing with eth_signTypedData_v4.
+func (fr ForwardRequest) GetTypedDataJSON(domain ForwarderDomain) ([]byte, error) {
+	return json.Marshal(fr.GetTypedData(domain))
+}
+
+// GetTypedDataHash returns the EIP-712 hash of the request.
+func (fr ForwardRequest) GetTypedDataHash(domain ForwarderDomain) ([]byte, error) {
+	typedData := fr.GetTypedData(domain)
+
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, fmt.Errorf("could not hash domain: %w", err)
+	}
+
+	messageHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, fmt.Errorf("could not hash message: %w", err)
+	}
+
+	raw := []byte{0x19, 0x01}
+	raw = append(raw, domainSeparator...)
+	raw = append(raw, messageHash...)
+	return keccak256(raw), nil
+}
+
+// CreateSignature signs the request for the given forwarder.
+// The returned signature has its V in the 27/28 form expected by the forwarder contracts.
+func (fr ForwardRequest) CreateSignature(domain ForwarderDomain, ks hashSigner, signer common.Address) ([]byte, error) {
+	hash, err := fr.GetTypedDataHash(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := ks.SignHash(accounts.Account{Address: signer}, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ReformatSignatureVForBC(signature); err != nil {
+		return nil, fmt.Errorf("failed to reformat signature: %w", err)
+	}
+	return signature, nil
+}
+
+// RecoverSigner recovers the signer address out of the request signature.
+func (fr ForwardRequest) RecoverSigner(domain ForwarderDomain, signature []byte) (common.Address, error) {
+	sig := make([]byte, 65)
+	copy(sig, signature)
+
+	if err := ReformatSignatureVForRecovery(sig); err != nil {
+		return common.Address{}, err
+	}
+
+	hash, err := fr.GetTypedDataHash(domain)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return recoverHash(hash, sig)
+}
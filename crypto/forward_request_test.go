@@ -0,0 +1,84 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwardRequestSignature(t *testing.T) {
+	dir, ks := tmpKeyStore(t, false)
+	defer os.RemoveAll(dir)
+
+	account, err := ks.ImportECDSA(getPrivKey("consumer"), "")
+	assert.Nil(t, err)
+	if err := ks.Unlock(account, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	domain := ForwarderDomain{Name: "MinimalForwarder", Version: "0.0.1", ChainID: 5, Forwarder: common.HexToAddress("0xf")}
+	req := ForwardRequest{
+		From:  account.Address,
+		To:    common.HexToAddress("0x1"),
+		Gas:   100000,
+		Nonce: big.NewInt(3),
+		Data:  []byte{1, 2, 3},
+	}
+
+	signature, err := req.CreateSignature(domain, ks, account.Address)
+	assert.NoError(t, err)
+	assert.True(t, signature[64] == 27 || signature[64] == 28)
+
+	recovered, err := req.RecoverSigner(domain, signature)
+	assert.NoError(t, err)
+	assert.Equal(t, account.Address, recovered)
+
+	domain.ChainID = 1
+	recovered, err = req.RecoverSigner(domain, signature)
+	assert.NoError(t, err)
+	assert.NotEqual(t, account.Address, recovered)
+}
+
+func TestForwardRequestTypedDataJSON(t *testing.T) {
+	domain := ForwarderDomain{Name: "MinimalForwarder", Version: "0.0.1", ChainID: 5, Forwarder: common.HexToAddress("0xf")}
+	req := ForwardRequest{From: common.HexToAddress("0x2"), To: common.HexToAddress("0x1"), Gas: 21000, Data: []byte{0xab}}
+
+	b, err := req.GetTypedDataJSON(domain)
+	assert.NoError(t, err)
+
+	var td core.TypedData
+	assert.NoError(t, json.Unmarshal(b, &td))
+	assert.Equal(t, "ForwardRequest", td.PrimaryType)
+	assert.Equal(t, "0xab", td.Message["data"])
+	assert.Equal(t, "0", td.Message["nonce"])
+
+	expected, err := req.GetTypedDataHash(domain)
+	assert.NoError(t, err)
+	sep, err := td.HashStruct("EIP712Domain", td.Domain.Map())
+	assert.NoError(t, err)
+	msg, err := td.HashStruct(td.PrimaryType, td.Message)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, crypto.Keccak256(append(append([]byte{0x19, 0x01}, sep...), msg...)))
+}
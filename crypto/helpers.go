@@ -43,6 +43,27 @@ func FloatToBigMyst(input float64) *big.Int {
 	return res
 }
 
+// AmountToFloat returns the float64 representation of the amount of a token with the given decimals.
+func AmountToFloat(input *big.Int, decimals uint8) float64 {
+	f := new(big.Float).SetInt(input)
+	divided := f.Quo(f, decimalsUnit(decimals))
+	r, _ := divided.Float64()
+	return r
+}
+
+// FloatToAmount converts the float to the smallest unit representation of a token with the given decimals.
+// For example, 1.5 becomes 1500000 for a token with 6 decimals.
+func FloatToAmount(input float64, decimals uint8) *big.Int {
+	multiplied := new(big.Float).Mul(new(big.Float).SetFloat64(input), decimalsUnit(decimals))
+	res, _ := multiplied.Int(nil)
+	return res
+}
+
+func decimalsUnit(decimals uint8) *big.Float {
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	return new(big.Float).SetInt(unit)
+}
+
 func hasHexPrefix(str string) bool {
 	return len(str) >= 2 && str[0] == '0' && (str[1] == 'x' || str[1] == 'X')
 }
@@ -20,8 +20,6 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"math/big"
-
-	"github.com/ethereum/go-ethereum/crypto"
 )
 
 // Invoice represent a payment request
@@ -45,7 +43,7 @@ func CreateInvoice(agreementID, agreementTotal, transactorFee *big.Int, r []byte
 		AgreementID:    new(big.Int).Set(agreementID),
 		AgreementTotal: new(big.Int).Set(agreementTotal),
 		TransactorFee:  new(big.Int).Set(transactorFee),
-		Hashlock:       hex.EncodeToString(crypto.Keccak256(r)),
+		Hashlock:       hex.EncodeToString(keccak256(r)),
 		ChainID:        chainID,
 	}
 }
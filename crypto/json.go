@@ -0,0 +1,253 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"encoding/json"
+)
+
+// The JSON encoding of the exported types keeps the field names of the default encoding,
+// only the amounts are encoded as BigInt decimal strings.
+
+// MarshalJSON encodes the promise with decimal string amounts.
+func (p Promise) MarshalJSON() ([]byte, error) {
+	type promise Promise
+	return json.Marshal(struct {
+		promise
+		Amount *BigInt
+		Fee    *BigInt
+	}{promise(p), (*BigInt)(p.Amount), (*BigInt)(p.Fee)})
+}
+
+// UnmarshalJSON decodes the promise, accepting decimal string and number amounts.
+func (p *Promise) UnmarshalJSON(data []byte) error {
+	type promise Promise
+	aux := struct {
+		*promise
+		Amount *BigInt
+		Fee    *BigInt
+	}{promise: (*promise)(p)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	p.Amount, p.Fee = aux.Amount.Int(), aux.Fee.Int()
+	return nil
+}
+
+// MarshalJSON encodes the expiring promise with decimal string amounts.
+// It is required as the promoted Promise encoding would drop the validity fields.
+func (ep ExpiringPromise) MarshalJSON() ([]byte, error) {
+	type promise Promise
+	return json.Marshal(struct {
+		promise
+		Amount            *BigInt
+		Fee               *BigInt
+		Validity          PromiseValidity
+		ValiditySignature []byte
+	}{promise(ep.Promise), (*BigInt)(ep.Amount), (*BigInt)(ep.Fee), ep.Validity, ep.ValiditySignature})
+}
+
+// UnmarshalJSON decodes the expiring promise, accepting decimal string and number amounts.
+func (ep *ExpiringPromise) UnmarshalJSON(data []byte) error {
+	type promise Promise
+	aux := struct {
+		*promise
+		Amount            *BigInt
+		Fee               *BigInt
+		Validity          PromiseValidity
+		ValiditySignature []byte
+	}{promise: (*promise)(&ep.Promise)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	ep.Amount, ep.Fee = aux.Amount.Int(), aux.Fee.Int()
+	ep.Validity, ep.ValiditySignature = aux.Validity, aux.ValiditySignature
+	return nil
+}
+
+// MarshalJSON encodes the exchange message with decimal string amounts.
+func (m ExchangeMessage) MarshalJSON() ([]byte, error) {
+	type exchangeMessage ExchangeMessage
+	return json.Marshal(struct {
+		exchangeMessage
+		AgreementID    *BigInt
+		AgreementTotal *BigInt
+	}{exchangeMessage(m), (*BigInt)(m.AgreementID), (*BigInt)(m.AgreementTotal)})
+}
+
+// UnmarshalJSON decodes the exchange message, accepting decimal string and number amounts.
+func (m *ExchangeMessage) UnmarshalJSON(data []byte) error {
+	type exchangeMessage ExchangeMessage
+	aux := struct {
+		*exchangeMessage
+		AgreementID    *BigInt
+		AgreementTotal *BigInt
+	}{exchangeMessage: (*exchangeMessage)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	m.AgreementID, m.AgreementTotal = aux.AgreementID.Int(), aux.AgreementTotal.Int()
+	return nil
+}
+
+// MarshalJSON encodes the invoice with decimal string amounts.
+func (i Invoice) MarshalJSON() ([]byte, error) {
+	type invoice Invoice
+	return json.Marshal(struct {
+		invoice
+		AgreementID    *BigInt
+		AgreementTotal *BigInt
+		TransactorFee  *BigInt
+	}{invoice(i), (*BigInt)(i.AgreementID), (*BigInt)(i.AgreementTotal), (*BigInt)(i.TransactorFee)})
+}
+
+// UnmarshalJSON decodes the invoice, accepting decimal string and number amounts.
+func (i *Invoice) UnmarshalJSON(data []byte) error {
+	type invoice Invoice
+	aux := struct {
+		*invoice
+		AgreementID    *BigInt
+		AgreementTotal *BigInt
+		TransactorFee  *BigInt
+	}{invoice: (*invoice)(i)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	i.AgreementID, i.AgreementTotal, i.TransactorFee = aux.AgreementID.Int(), aux.AgreementTotal.Int(), aux.TransactorFee.Int()
+	return nil
+}
+
+// MarshalJSON encodes the request with a decimal string nonce.
+func (r SetBeneficiaryRequest) MarshalJSON() ([]byte, error) {
+	type setBeneficiaryRequest SetBeneficiaryRequest
+	return json.Marshal(struct {
+		setBeneficiaryRequest
+		Nonce *BigInt `json:"nonce"`
+	}{setBeneficiaryRequest(r), (*BigInt)(r.Nonce)})
+}
+
+// UnmarshalJSON decodes the request, accepting a decimal string or number nonce.
+func (r *SetBeneficiaryRequest) UnmarshalJSON(data []byte) error {
+	type setBeneficiaryRequest SetBeneficiaryRequest
+	aux := struct {
+		*setBeneficiaryRequest
+		Nonce *BigInt `json:"nonce"`
+	}{setBeneficiaryRequest: (*setBeneficiaryRequest)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.Nonce = aux.Nonce.Int()
+	return nil
+}
+
+// MarshalJSON encodes the request with a decimal string value and nonce.
+func (fr ForwardRequest) MarshalJSON() ([]byte, error) {
+	type forwardRequest ForwardRequest
+	return json.Marshal(struct {
+		forwardRequest
+		Value *BigInt `json:"value"`
+		Nonce *BigInt `json:"nonce"`
+	}{forwardRequest(fr), (*BigInt)(fr.Value), (*BigInt)(fr.Nonce)})
+}
+
+// UnmarshalJSON decodes the request, accepting a decimal string or number value and nonce.
+func (fr *ForwardRequest) UnmarshalJSON(data []byte) error {
+	type forwardRequest ForwardRequest
+	aux := struct {
+		*forwardRequest
+		Value *BigInt `json:"value"`
+		Nonce *BigInt `json:"nonce"`
+	}{forwardRequest: (*forwardRequest)(fr)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	fr.Value, fr.Nonce = aux.Value.Int(), aux.Nonce.Int()
+	return nil
+}
+
+// MarshalJSON encodes the request with decimal string amounts.
+func (r DecreaseProviderStakeRequest) MarshalJSON() ([]byte, error) {
+	type decreaseProviderStakeRequest DecreaseProviderStakeRequest
+	return json.Marshal(struct {
+		decreaseProviderStakeRequest
+		Amount        *BigInt
+		TransactorFee *BigInt
+		Nonce         *BigInt
+	}{decreaseProviderStakeRequest(r), (*BigInt)(r.Amount), (*BigInt)(r.TransactorFee), (*BigInt)(r.Nonce)})
+}
+
+// UnmarshalJSON decodes the request, accepting decimal string and number amounts.
+func (r *DecreaseProviderStakeRequest) UnmarshalJSON(data []byte) error {
+	type decreaseProviderStakeRequest DecreaseProviderStakeRequest
+	aux := struct {
+		*decreaseProviderStakeRequest
+		Amount        *BigInt
+		TransactorFee *BigInt
+		Nonce         *BigInt
+	}{decreaseProviderStakeRequest: (*decreaseProviderStakeRequest)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.Amount, r.TransactorFee, r.Nonce = aux.Amount.Int(), aux.TransactorFee.Int(), aux.Nonce.Int()
+	return nil
+}
+
+// MarshalJSON encodes the request with a decimal string validity.
+func (r ExitRequest) MarshalJSON() ([]byte, error) {
+	type exitRequest ExitRequest
+	return json.Marshal(struct {
+		exitRequest
+		ValidUntil *BigInt
+	}{exitRequest(r), (*BigInt)(r.ValidUntil)})
+}
+
+// UnmarshalJSON decodes the request, accepting a decimal string or number validity.
+func (r *ExitRequest) UnmarshalJSON(data []byte) error {
+	type exitRequest ExitRequest
+	aux := struct {
+		*exitRequest
+		ValidUntil *BigInt
+	}{exitRequest: (*exitRequest)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.ValidUntil = aux.ValidUntil.Int()
+	return nil
+}
+
+// MarshalJSON encodes the invalidation with a decimal string amount.
+func (pi PromiseInvalidation) MarshalJSON() ([]byte, error) {
+	type promiseInvalidation PromiseInvalidation
+	return json.Marshal(struct {
+		promiseInvalidation
+		MaxAmount *BigInt
+	}{promiseInvalidation(pi), (*BigInt)(pi.MaxAmount)})
+}
+
+// UnmarshalJSON decodes the invalidation, accepting a decimal string or number amount.
+func (pi *PromiseInvalidation) UnmarshalJSON(data []byte) error {
+	type promiseInvalidation PromiseInvalidation
+	aux := struct {
+		*promiseInvalidation
+		MaxAmount *BigInt
+	}{promiseInvalidation: (*promiseInvalidation)(pi)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	pi.MaxAmount = aux.MaxAmount.Int()
+	return nil
+}
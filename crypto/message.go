@@ -26,7 +26,6 @@ import (
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
-	"github.com/ethereum/go-ethereum/crypto"
 )
 
 // ExchangeMessage represent a promise exchange message
@@ -136,7 +135,7 @@ func (m ExchangeMessage) GetMessage() []byte {
 
 // GetMessageHash returns a keccak of exchange message params
 func (m ExchangeMessage) GetMessageHash() []byte {
-	return crypto.Keccak256(m.GetMessage())
+	return keccak256(m.GetMessage())
 }
 
 // CreateSignature signs promise using keystore
@@ -19,6 +19,7 @@ package crypto
 import (
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
 
@@ -26,10 +27,26 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/pkg/errors"
 )
 
+// ErrInvalidPromiseHex is returned when the promise channel id or hashlock is not a hex string.
+var ErrInvalidPromiseHex = errors.New("channelID and hashlock have to be proper hex strings")
+
+// DecodeError is returned when a hex encoded promise field can not be decoded.
+type DecodeError struct {
+	Field string
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	return "Problem in decoding " + e.Field + ": " + e.Err.Error()
+}
+
+// Unwrap returns the decoding error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
 // Promise is payment promise object
 type Promise struct {
 	ChannelID []byte
@@ -52,17 +69,17 @@ func CreatePromise(channelID string, chainID int64, amount *big.Int, fee *big.In
 	}
 
 	if !isHex(channelID) || !isHex(hashlock) {
-		return nil, errors.New("channelID and hashlock have to be proper hex strings")
+		return nil, ErrInvalidPromiseHex
 	}
 
 	chID, err := hex.DecodeString(channelID)
 	if err != nil {
-		return nil, errors.Wrap(err, "Problem in decoding channelID")
+		return nil, &DecodeError{Field: "channelID", Err: err}
 	}
 
 	hl, err := hex.DecodeString(hashlock)
 	if err != nil {
-		return nil, errors.Wrap(err, "Problem in decoding hashlock")
+		return nil, &DecodeError{Field: "hashlock", Err: err}
 	}
 
 	promise := Promise{
@@ -100,26 +117,26 @@ func NewPromise(chainID int64, channelID string, amount, fee *big.Int, preimage
 
 	chID, err := hex.DecodeString(channelID)
 	if err != nil {
-		return nil, errors.Wrap(err, "Problem in decoding channelID")
+		return nil, &DecodeError{Field: "channelID", Err: err}
 	}
 
 	r, err := hex.DecodeString(preimage)
 	if err != nil {
-		return nil, errors.Wrap(err, "Problem in decoding preimage")
+		return nil, &DecodeError{Field: "preimage", Err: err}
 	}
 
 	sig, err := hex.DecodeString(signature)
 	if err != nil {
-		return nil, errors.Wrap(err, "Problem in decoding signature")
+		return nil, &DecodeError{Field: "signature", Err: err}
 	}
 
-	// hashlock := crypto.Keccak256(r)
+	// hashlock := keccak256(r)
 
 	promise := Promise{
 		ChannelID: chID,
 		Amount:    new(big.Int).Set(amount),
 		Fee:       new(big.Int).Set(fee),
-		Hashlock:  crypto.Keccak256(r),
+		Hashlock:  keccak256(r),
 		R:         r,
 		Signature: sig,
 		ChainID:   chainID,
@@ -140,17 +157,17 @@ func NewRawPromise(chainID int64, channelID string, amount, fee *big.Int, hashlo
 
 	chID, err := hex.DecodeString(channelID)
 	if err != nil {
-		return nil, errors.Wrap(err, "Problem in decoding channelID")
+		return nil, &DecodeError{Field: "channelID", Err: err}
 	}
 
 	hl, err := hex.DecodeString(hashlock)
 	if err != nil {
-		return nil, errors.Wrap(err, "Problem in decoding preimage")
+		return nil, &DecodeError{Field: "preimage", Err: err}
 	}
 
 	sig, err := hex.DecodeString(signature)
 	if err != nil {
-		return nil, errors.Wrap(err, "Problem in decoding signature")
+		return nil, &DecodeError{Field: "signature", Err: err}
 	}
 
 	promise := Promise{
@@ -196,13 +213,13 @@ func (p Promise) GetMessage() []byte {
 
 // GetHash returns a keccak of payment promise message
 func (p Promise) GetHash() []byte {
-	return crypto.Keccak256(p.GetMessage())
+	return keccak256(p.GetMessage())
 }
 
 // CreateSignature signs promise using keystore
 func (p Promise) CreateSignature(ks hashSigner, signer common.Address) ([]byte, error) {
 	message := p.GetMessage()
-	hash := crypto.Keccak256(message)
+	hash := keccak256(message)
 	return ks.SignHash(
 		accounts.Account{Address: signer},
 		hash,
@@ -19,6 +19,7 @@ package crypto
 import (
 	"crypto/ecdsa"
 	"encoding/hex"
+	"errors"
 	"io/ioutil"
 	"math/big"
 	"os"
@@ -294,3 +295,17 @@ func getParams(userType string) Params {
 		Provider:                 provider,
 	}
 }
+
+func TestPromiseErrors(t *testing.T) {
+	_, err := CreatePromise("zz", 1, big.NewInt(1), big.NewInt(0), "00", nil, common.Address{})
+	assert.Equal(t, ErrInvalidPromiseHex, err)
+
+	_, err = NewPromise(1, "0x0", big.NewInt(1), big.NewInt(0), "00", "00")
+	var decodeErr *DecodeError
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, "channelID", decodeErr.Field)
+	assert.True(t, errors.Is(err, hex.ErrLength))
+	assert.Equal(t, "Problem in decoding channelID: encoding/hex: odd length hex string", err.Error())
+
+	assert.Equal(t, ErrInvalidSignatureLength, ReformatSignatureVForBC([]byte{1}))
+}
@@ -0,0 +1,134 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultRecoveryCacheSize is the number of recovered signers kept by the cache used in RecoverAddress.
+const DefaultRecoveryCacheSize = 4096
+
+var recoveryCache = NewRecoveryCache(DefaultRecoveryCacheSize)
+
+// SetRecoveryCacheSize replaces the cache used in RecoverAddress with a cache of the given size.
+// A size of zero disables caching. Not thread safe, call before verifying any signatures.
+func SetRecoveryCacheSize(size int) {
+	recoveryCache = NewRecoveryCache(size)
+}
+
+type recoveryKey struct {
+	hash      common.Hash
+	signature [65]byte
+}
+
+type recoveryEntry struct {
+	key     recoveryKey
+	address common.Address
+}
+
+// RecoveryCache is a concurrency safe LRU cache of the addresses recovered out of hash and signature pairs.
+// Verifying the same promise repeatedly then costs a map lookup instead of a secp256k1 public key recovery.
+type RecoveryCache struct {
+	size int
+
+	lock    sync.Mutex
+	entries map[recoveryKey]*list.Element
+	order   *list.List
+}
+
+// NewRecoveryCache returns a new cache holding up to size recovered addresses.
+func NewRecoveryCache(size int) *RecoveryCache {
+	return &RecoveryCache{
+		size:    size,
+		entries: make(map[recoveryKey]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Recover returns the address that signed the hash, recovering it only if it is not cached yet.
+// Failed recoveries are not cached.
+func (rc *RecoveryCache) Recover(hash, signature []byte) (common.Address, error) {
+	if rc.size <= 0 || len(signature) != 65 {
+		return recoverHash(hash, signature)
+	}
+
+	key := recoveryKey{hash: common.BytesToHash(hash)}
+	copy(key.signature[:], signature)
+
+	if address, ok := rc.get(key); ok {
+		return address, nil
+	}
+
+	address, err := recoverHash(hash, signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	rc.add(key, address)
+	return address, nil
+}
+
+// Len returns the number of cached addresses.
+func (rc *RecoveryCache) Len() int {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	return rc.order.Len()
+}
+
+func (rc *RecoveryCache) get(key recoveryKey) (common.Address, bool) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	el, ok := rc.entries[key]
+	if !ok {
+		return common.Address{}, false
+	}
+	rc.order.MoveToFront(el)
+	return el.Value.(*recoveryEntry).address, true
+}
+
+func (rc *RecoveryCache) add(key recoveryKey, address common.Address) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	if el, ok := rc.entries[key]; ok {
+		rc.order.MoveToFront(el)
+		return
+	}
+
+	rc.entries[key] = rc.order.PushFront(&recoveryEntry{key: key, address: address})
+	for rc.order.Len() > rc.size {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.entries, oldest.Value.(*recoveryEntry).key)
+	}
+}
+
+func recoverHash(hash, signature []byte) (common.Address, error) {
+	publicKey, err := backend.Ecrecover(hash, signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(publicKey) != 65 || publicKey[0] != 4 {
+		return common.Address{}, errors.New("invalid recovered public key")
+	}
+	return common.BytesToAddress(backend.Keccak256(publicKey[1:])[12:]), nil
+}
@@ -0,0 +1,89 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoveryCache(t *testing.T) {
+	key := getPrivKey("consumer")
+	expected := crypto.PubkeyToAddress(key.PublicKey)
+
+	hashes := make([][]byte, 3)
+	sigs := make([][]byte, 3)
+	for i := range hashes {
+		hashes[i] = crypto.Keccak256([]byte{byte(i)})
+		sig, err := crypto.Sign(hashes[i], key)
+		assert.NoError(t, err)
+		sigs[i] = sig
+	}
+
+	rc := NewRecoveryCache(2)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			address, err := rc.Recover(hashes[0], sigs[0])
+			assert.NoError(t, err)
+			assert.Equal(t, expected, address)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, rc.Len())
+
+	for i := range hashes {
+		address, err := rc.Recover(hashes[i], sigs[i])
+		assert.NoError(t, err)
+		assert.Equal(t, expected, address)
+	}
+	assert.Equal(t, 2, rc.Len())
+	_, cached := rc.get(recoveryKey{hash: toHash(hashes[0]), signature: toSig(sigs[0])})
+	assert.False(t, cached, "least recently used entry must be evicted")
+
+	_, err := rc.Recover(hashes[0], make([]byte, 65))
+	assert.Error(t, err)
+	assert.Equal(t, 2, rc.Len())
+}
+
+func TestRecoveryCacheDisabled(t *testing.T) {
+	key := getPrivKey("consumer")
+	hash := crypto.Keccak256([]byte("msg"))
+	sig, err := crypto.Sign(hash, key)
+	assert.NoError(t, err)
+
+	rc := NewRecoveryCache(0)
+	address, err := rc.Recover(hash, sig)
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), address)
+	assert.Equal(t, 0, rc.Len())
+}
+
+func toHash(b []byte) (res [32]byte) {
+	copy(res[:], b)
+	return res
+}
+
+func toSig(b []byte) (res [65]byte) {
+	copy(res[:], b)
+	return res
+}
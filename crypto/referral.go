@@ -23,9 +23,11 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
 )
 
+// ErrIdentityMismatch is returned when the referral token was not signed by its identity.
+var ErrIdentityMismatch = errors.New("identities do not match")
+
 type ReferralTokenRequest struct {
 	Identity  common.Address
 	Signature string
@@ -34,7 +36,7 @@ type ReferralTokenRequest struct {
 // CreateSignature signs promise using keystore
 func CreateReferralTokenRequest(ks hashSigner, signer common.Address) (ReferralTokenRequest, error) {
 	message := signer.Bytes()
-	hash := crypto.Keccak256(message)
+	hash := keccak256(message)
 	signature, err := ks.SignHash(
 		accounts.Account{Address: signer},
 		hash,
@@ -55,19 +57,13 @@ func (rtr ReferralTokenRequest) IsValid() error {
 		return err
 	}
 
-	k, err := crypto.Ecrecover(crypto.Keccak256(rtr.Identity.Bytes()), b)
+	recoveredAddress, err := recoverHash(keccak256(rtr.Identity.Bytes()), b)
 	if err != nil {
 		return err
 	}
-	pubKey, err := crypto.UnmarshalPubkey(k)
-	if err != nil {
-		return err
-	}
-
-	recoveredAddress := crypto.PubkeyToAddress(*pubKey)
 
 	if !bytes.Equal(rtr.Identity.Bytes(), recoveredAddress.Bytes()) {
-		return errors.New("identities do not match")
+		return ErrIdentityMismatch
 	}
 
 	return nil
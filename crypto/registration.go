@@ -0,0 +1,73 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// IdentityRegistration is the registration of an identity in the registry, signed by the identity itself.
+type IdentityRegistration struct {
+	ChainID       int64
+	Registry      common.Address
+	HermesID      common.Address
+	Stake         *big.Int
+	TransactorFee *big.Int
+	Beneficiary   common.Address
+}
+
+// GetMessage forms the message the registry recovers the registered identity from.
+func (r IdentityRegistration) GetMessage() []byte {
+	message := []byte{}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(r.ChainID))
+	message = append(message, Pad(b, 32)...)
+	message = append(message, r.Registry.Bytes()...)
+	message = append(message, r.HermesID.Bytes()...)
+	message = append(message, Pad(math.U256(new(big.Int).Set(bigOrZero(r.Stake))).Bytes(), 32)...)
+	message = append(message, Pad(math.U256(new(big.Int).Set(bigOrZero(r.TransactorFee))).Bytes(), 32)...)
+	message = append(message, r.Beneficiary.Bytes()...)
+	return message
+}
+
+// CreateSignature signs the registration with the key of the identity, the signature is formatted for the blockchain.
+func (r IdentityRegistration) CreateSignature(ks hashSigner, identity common.Address) ([]byte, error) {
+	signature, err := ks.SignHash(accounts.Account{Address: identity}, keccak256(r.GetMessage()))
+	if err != nil {
+		return nil, err
+	}
+	if err := ReformatSignatureVForBC(signature); err != nil {
+		return nil, fmt.Errorf("failed to reformat signature: %w", err)
+	}
+	return signature, nil
+}
+
+// RecoverSigner recovers the identity from the registration signature.
+func (r IdentityRegistration) RecoverSigner(signature []byte) (common.Address, error) {
+	sig := make([]byte, len(signature))
+	copy(sig, signature)
+	if err := ReformatSignatureVForRecovery(sig); err != nil {
+		return common.Address{}, err
+	}
+	return RecoverAddress(r.GetMessage(), sig)
+}
@@ -25,7 +25,6 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
-	"github.com/ethereum/go-ethereum/crypto"
 )
 
 const stakeReturnPrefix = "Stake return request"
@@ -39,12 +38,14 @@ type DecreaseProviderStakeRequest struct {
 	Nonce         *big.Int
 	ChainID       int64
 	Signature     []byte
+	// Domain holds the prefixes of the deployment, the defaults are used if not set.
+	Domain DomainSeparators
 }
 
 // CreateSignature signs promise using keystore
 func (dpsr DecreaseProviderStakeRequest) CreateSignature(ks hashSigner, signer common.Address) ([]byte, error) {
 	message := dpsr.GetMessage()
-	hash := crypto.Keccak256(message)
+	hash := keccak256(message)
 	return ks.SignHash(
 		accounts.Account{Address: signer},
 		hash,
@@ -70,7 +71,7 @@ func (dpsr *DecreaseProviderStakeRequest) Sign(ks *keystore.KeyStore, signer com
 // GetMessage gets a message representation of the DecreaseProviderStakeRequest.
 func (dpsr DecreaseProviderStakeRequest) GetMessage() []byte {
 	msg := []byte{}
-	msg = append(msg, []byte(stakeReturnPrefix)...)
+	msg = append(msg, []byte(dpsr.Domain.withDefaults().StakeReturn)...)
 	b := make([]byte, 8)
 	binary.BigEndian.PutUint64(b, uint64(dpsr.ChainID))
 	msg = append(msg, Pad(b, 32)...)
@@ -0,0 +1,264 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core"
+)
+
+const (
+	// TypedDataDomainName is the EIP-712 domain name used for mysterium payment messages.
+	TypedDataDomainName = "Mysterium"
+	// TypedDataDomainVersion is the EIP-712 domain version used for mysterium payment messages.
+	TypedDataDomainVersion = "1"
+
+	promiseTypedDataPrimaryType  = "Promise"
+	exchangeTypedDataPrimaryType = "Exchange"
+)
+
+var promiseTypedDataTypes = core.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+	},
+	promiseTypedDataPrimaryType: {
+		{Name: "channelID", Type: "bytes32"},
+		{Name: "amount", Type: "uint256"},
+		{Name: "fee", Type: "uint256"},
+		{Name: "hashlock", Type: "bytes32"},
+	},
+}
+
+var exchangeTypedDataTypes = core.Types{
+	"EIP712Domain":              promiseTypedDataTypes["EIP712Domain"],
+	promiseTypedDataPrimaryType: promiseTypedDataTypes[promiseTypedDataPrimaryType],
+	exchangeTypedDataPrimaryType: {
+		{Name: "promise", Type: promiseTypedDataPrimaryType},
+		{Name: "agreementID", Type: "uint256"},
+		{Name: "agreementTotal", Type: "uint256"},
+		{Name: "provider", Type: "address"},
+		{Name: "hermesID", Type: "address"},
+	},
+}
+
+// GetTypedData returns the EIP-712 typed data representation of the promise.
+// The result can be passed to eth_signTypedData_v4 compatible signers such as MetaMask or WalletConnect.
+func (p Promise) GetTypedData() core.TypedData {
+	return core.TypedData{
+		Types:       promiseTypedDataTypes,
+		PrimaryType: promiseTypedDataPrimaryType,
+		Domain: core.TypedDataDomain{
+			Name:    TypedDataDomainName,
+			Version: TypedDataDomainVersion,
+			ChainId: math.NewHexOrDecimal256(p.ChainID),
+		},
+		Message: core.TypedDataMessage{
+			"channelID": hexutil.Encode(Pad(p.ChannelID, 32)),
+			"amount":    bigOrZero(p.Amount).String(),
+			"fee":       bigOrZero(p.Fee).String(),
+			"hashlock":  hexutil.Encode(Pad(p.Hashlock, 32)),
+		},
+	}
+}
+
+// GetTypedDataJSON returns the EIP-712 typed data JSON of the promise.
+func (p Promise) GetTypedDataJSON() ([]byte, error) {
+	return json.Marshal(p.GetTypedData())
+}
+
+// GetTypedDataHash returns the EIP-712 hash of the promise, e.g. keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(promise)).
+// It is not the hash the hermes and channel contracts check, see TypedDataPromise.
+func (p Promise) GetTypedDataHash() ([]byte, error) {
+	return typedDataHash(p.GetTypedData())
+}
+
+// GetTypedData returns the EIP-712 typed data representation of the exchange message, the cheque of the promise.
+// The result can be passed to eth_signTypedData_v4 compatible signers such as MetaMask or WalletConnect.
+func (m ExchangeMessage) GetTypedData() core.TypedData {
+	promise := m.Promise.GetTypedData()
+	return core.TypedData{
+		Types:       exchangeTypedDataTypes,
+		PrimaryType: exchangeTypedDataPrimaryType,
+		Domain: core.TypedDataDomain{
+			Name:    TypedDataDomainName,
+			Version: TypedDataDomainVersion,
+			ChainId: math.NewHexOrDecimal256(m.ChainID),
+		},
+		Message: core.TypedDataMessage{
+			"promise":        map[string]interface{}(promise.Message),
+			"agreementID":    bigOrZero(m.AgreementID).String(),
+			"agreementTotal": bigOrZero(m.AgreementTotal).String(),
+			"provider":       common.HexToAddress(m.Provider).Hex(),
+			"hermesID":       common.HexToAddress(m.HermesID).Hex(),
+		},
+	}
+}
+
+// GetTypedDataJSON returns the EIP-712 typed data JSON of the exchange message.
+func (m ExchangeMessage) GetTypedDataJSON() ([]byte, error) {
+	return json.Marshal(m.GetTypedData())
+}
+
+// GetTypedDataHash returns the EIP-712 hash of the exchange message.
+// It is not the hash hermes checks, see TypedDataExchangeMessage.
+func (m ExchangeMessage) GetTypedDataHash() ([]byte, error) {
+	return typedDataHash(m.GetTypedData())
+}
+
+func typedDataHash(typedData core.TypedData) ([]byte, error) {
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, fmt.Errorf("could not hash domain: %w", err)
+	}
+
+	messageHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, fmt.Errorf("could not hash message: %w", err)
+	}
+
+	raw := []byte{0x19, 0x01}
+	raw = append(raw, domainSeparator...)
+	raw = append(raw, messageHash...)
+	return keccak256(raw), nil
+}
+
+// SignerMismatchError is returned when a signature was created by an unexpected signer.
+type SignerMismatchError struct {
+	Recovered common.Address
+	Expected  common.Address
+}
+
+func (e *SignerMismatchError) Error() string {
+	return fmt.Sprintf("signature was created by %v, expected %v", e.Recovered.Hex(), e.Expected.Hex())
+}
+
+// TypedDataPromise is a promise signed by an external EIP-712 signer.
+// The hermes and channel contracts verify promise signatures against keccak(GetMessage()), not the EIP-712 hash,
+// so a typed data promise can not be settled until the contracts accept EIP-712 signatures.
+// The signature is kept apart from Promise.Signature for that reason.
+type TypedDataPromise struct {
+	Promise   Promise
+	Signature []byte
+}
+
+// AttachTypedDataSignature pairs the promise with the signature returned by an external EIP-712 signer.
+// The signature is checked to be created by the expected signer.
+func (p Promise) AttachTypedDataSignature(signature string, expectedSigner common.Address) (*TypedDataPromise, error) {
+	hash, err := p.GetTypedDataHash()
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := checkTypedDataSignature(hash, signature, expectedSigner)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedDataPromise{Promise: p, Signature: sig}, nil
+}
+
+// RecoverSigner recovers the signer address out of the EIP-712 promise signature.
+func (tp TypedDataPromise) RecoverSigner() (common.Address, error) {
+	hash, err := tp.Promise.GetTypedDataHash()
+	if err != nil {
+		return common.Address{}, err
+	}
+	return recoverTypedDataSigner(hash, tp.Signature)
+}
+
+// TypedDataExchangeMessage is an exchange message signed by an external EIP-712 signer.
+// Hermes verifies exchange messages against keccak(GetMessage()), so it does not accept it
+// until it supports EIP-712 signatures. The signature is kept apart from ExchangeMessage.Signature for that reason.
+type TypedDataExchangeMessage struct {
+	Message   ExchangeMessage
+	Signature []byte
+}
+
+// AttachTypedDataSignature pairs the exchange message with the signature returned by an external EIP-712 signer.
+// The signature is checked to be created by the expected signer.
+func (m ExchangeMessage) AttachTypedDataSignature(signature string, expectedSigner common.Address) (*TypedDataExchangeMessage, error) {
+	hash, err := m.GetTypedDataHash()
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := checkTypedDataSignature(hash, signature, expectedSigner)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedDataExchangeMessage{Message: m, Signature: sig}, nil
+}
+
+// RecoverSigner recovers the signer address out of the EIP-712 exchange message signature.
+func (tm TypedDataExchangeMessage) RecoverSigner() (common.Address, error) {
+	hash, err := tm.Message.GetTypedDataHash()
+	if err != nil {
+		return common.Address{}, err
+	}
+	return recoverTypedDataSigner(hash, tm.Signature)
+}
+
+// checkTypedDataSignature decodes the hex signature, checks its signer and returns it formatted for the blockchain.
+func checkTypedDataSignature(hash []byte, signature string, expectedSigner common.Address) ([]byte, error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode signature: %w", err)
+	}
+
+	recovered, err := recoverTypedDataSigner(hash, sig)
+	if err != nil {
+		return nil, err
+	}
+	if recovered != expectedSigner {
+		return nil, &SignerMismatchError{Recovered: recovered, Expected: expectedSigner}
+	}
+
+	// wallets return V as 27/28, crypto.Sign as 0/1, normalize before converting.
+	if err := ReformatSignatureVForRecovery(sig); err != nil {
+		return nil, fmt.Errorf("failed to reformat signature: %w", err)
+	}
+	if err := ReformatSignatureVForBC(sig); err != nil {
+		return nil, fmt.Errorf("failed to reformat signature: %w", err)
+	}
+	return sig, nil
+}
+
+func recoverTypedDataSigner(hash, signature []byte) (common.Address, error) {
+	sig := make([]byte, 65)
+	copy(sig, signature)
+
+	if err := ReformatSignatureVForRecovery(sig); err != nil {
+		return common.Address{}, err
+	}
+	return recoverHash(hash, sig)
+}
+
+func bigOrZero(i *big.Int) *big.Int {
+	if i == nil {
+		return new(big.Int)
+	}
+	return i
+}
@@ -0,0 +1,138 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromiseTypedDataJSON(t *testing.T) {
+	promise := getPromise("consumer")
+
+	b, err := promise.GetTypedDataJSON()
+	assert.NoError(t, err)
+
+	var td core.TypedData
+	assert.NoError(t, json.Unmarshal(b, &td))
+	assert.Equal(t, "Promise", td.PrimaryType)
+	assert.Equal(t, TypedDataDomainName, td.Domain.Name)
+	assert.Equal(t, "1401", td.Message["amount"])
+	assert.Equal(t, "0x000000000000000000000000d2c94475763fa7e81076ab0bde4dc4b902191498", td.Message["channelID"])
+
+	// hash must survive the json round trip, otherwise external signers would sign something else
+	expected, err := promise.GetTypedDataHash()
+	assert.NoError(t, err)
+	sep, err := td.HashStruct("EIP712Domain", td.Domain.Map())
+	assert.NoError(t, err)
+	msg, err := td.HashStruct(td.PrimaryType, td.Message)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, crypto.Keccak256(append(append([]byte{0x19, 0x01}, sep...), msg...)))
+}
+
+func TestAttachTypedDataSignature(t *testing.T) {
+	promise := getPromise("consumer")
+	key := getPrivKey("consumer")
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+
+	hash, err := promise.GetTypedDataHash()
+	assert.NoError(t, err)
+	sig, err := crypto.Sign(hash, key)
+	assert.NoError(t, err)
+
+	_, err = promise.AttachTypedDataSignature("0x"+hex.EncodeToString(sig), common.HexToAddress("0x1"))
+	assert.Error(t, err)
+
+	signed, err := promise.AttachTypedDataSignature("0x"+hex.EncodeToString(sig), signer)
+	assert.NoError(t, err)
+	assert.True(t, signed.Signature[64] == 27 || signed.Signature[64] == 28)
+	// the settleable signature of the promise is left alone
+	assert.Equal(t, promise.Signature, signed.Promise.Signature)
+
+	recovered, err := signed.RecoverSigner()
+	assert.NoError(t, err)
+	assert.Equal(t, signer, recovered)
+}
+
+func TestAttachTypedDataSignatureWalletV(t *testing.T) {
+	key := getPrivKey("consumer")
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+
+	// walletSig signs the promise the way wallets return it, with V being 27 or 28.
+	walletSig := func(p Promise) []byte {
+		hash, err := p.GetTypedDataHash()
+		assert.NoError(t, err)
+		sig, err := crypto.Sign(hash, key)
+		assert.NoError(t, err)
+		sig[64] += 27
+		return sig
+	}
+
+	seen := make(map[byte]bool)
+	for i := int64(0); len(seen) < 2 && i < 100; i++ {
+		promise := getPromise("consumer")
+		promise.Amount = big.NewInt(i + 1)
+		sig := walletSig(promise)
+
+		signed, err := promise.AttachTypedDataSignature("0x"+hex.EncodeToString(sig), signer)
+		assert.NoError(t, err, "V=%v", sig[64])
+		assert.Equal(t, sig[64], signed.Signature[64])
+
+		recovered, err := signed.RecoverSigner()
+		assert.NoError(t, err)
+		assert.Equal(t, signer, recovered, "V=%v", sig[64])
+		seen[sig[64]] = true
+	}
+	assert.True(t, seen[27] && seen[28], "both V values are covered")
+}
+
+func TestExchangeMessageTypedData(t *testing.T) {
+	key := getPrivKey("consumer")
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	msg := ExchangeMessage{
+		Promise:        getPromise("consumer"),
+		AgreementID:    big.NewInt(1),
+		AgreementTotal: big.NewInt(1401),
+		Provider:       "0xf10021d1e7b6b8aab5ca8cb4fbd5d8b4bd5b5d5e",
+		HermesID:       "0x1",
+		ChainID:        1,
+	}
+
+	b, err := msg.GetTypedDataJSON()
+	assert.NoError(t, err)
+	var td core.TypedData
+	assert.NoError(t, json.Unmarshal(b, &td))
+	assert.Equal(t, "Exchange", td.PrimaryType)
+
+	hash, err := msg.GetTypedDataHash()
+	assert.NoError(t, err)
+	sig, err := crypto.Sign(hash, key)
+	assert.NoError(t, err)
+
+	signed, err := msg.AttachTypedDataSignature(hex.EncodeToString(sig), signer)
+	assert.NoError(t, err)
+	recovered, err := signed.RecoverSigner()
+	assert.NoError(t, err)
+	assert.Equal(t, signer, recovered)
+}
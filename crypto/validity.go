@@ -0,0 +1,208 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// ErrPromiseExpired is returned when a promise is used past its validity window.
+var ErrPromiseExpired = errors.New("promise expired")
+
+// ErrPromiseInvalidated is returned when a promise exceeds the cap of an invalidation record.
+var ErrPromiseInvalidated = errors.New("promise invalidated")
+
+// ErrInvalidValiditySignature is returned when the validity window of a promise was not signed by the promise signer.
+var ErrInvalidValiditySignature = errors.New("invalid validity signature")
+
+// ErrMissingMaxAmount is returned when an invalidation record is created without the max amount.
+var ErrMissingMaxAmount = errors.New("missing max amount")
+
+// PromiseValidity limits the time a promise can be settled in. Zero values leave the respective bound open.
+//
+// The contracts are not aware of validity windows, they are honoured off chain by the parties validating
+// and scheduling promises.
+type PromiseValidity struct {
+	ValidUntilBlock uint64
+	ValidUntil      time.Time
+}
+
+// Expired checks whether the validity window is over at the given block and time.
+func (v PromiseValidity) Expired(block uint64, now time.Time) bool {
+	if v.ValidUntilBlock != 0 && block > v.ValidUntilBlock {
+		return true
+	}
+	return !v.ValidUntil.IsZero() && now.After(v.ValidUntil)
+}
+
+// ExpiringPromise is a promise with a validity window signed by the promise issuer.
+type ExpiringPromise struct {
+	Promise
+	Validity          PromiseValidity
+	ValiditySignature []byte
+}
+
+// NewExpiringPromise signs the validity window of the given promise.
+func NewExpiringPromise(p Promise, validity PromiseValidity, ks hashSigner, signer common.Address) (*ExpiringPromise, error) {
+	ep := ExpiringPromise{Promise: p, Validity: validity}
+
+	sig, err := ks.SignHash(accounts.Account{Address: signer}, keccak256(ep.GetValidityMessage()))
+	if err != nil {
+		return nil, err
+	}
+	if err := ReformatSignatureVForBC(sig); err != nil {
+		return nil, fmt.Errorf("failed to reformat signature: %w", err)
+	}
+	ep.ValiditySignature = sig
+
+	return &ep, nil
+}
+
+// GetValidityMessage forms the message binding the validity window to the promise.
+func (ep ExpiringPromise) GetValidityMessage() []byte {
+	message := ep.GetHash()
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, ep.Validity.ValidUntilBlock)
+	message = append(message, Pad(b, 32)...)
+	var until int64
+	if !ep.Validity.ValidUntil.IsZero() {
+		until = ep.Validity.ValidUntil.Unix()
+	}
+	binary.BigEndian.PutUint64(b, uint64(until))
+	message = append(message, Pad(b, 32)...)
+	return message
+}
+
+// Validate checks both signatures, the validity window and the given invalidation records.
+func (ep ExpiringPromise) Validate(expectedSigner common.Address, block uint64, now time.Time, invalidations ...PromiseInvalidation) error {
+	if !ep.IsPromiseValid(expectedSigner) {
+		return ErrInvalidSignature
+	}
+
+	signer, err := recoverWithSignature(ep.GetValidityMessage(), ep.ValiditySignature)
+	if err != nil {
+		return fmt.Errorf("could not recover validity signer: %w", err)
+	}
+	if signer != expectedSigner {
+		return ErrInvalidValiditySignature
+	}
+
+	if ep.Validity.Expired(block, now) {
+		return ErrPromiseExpired
+	}
+
+	return CheckInvalidations(ep.Promise, expectedSigner, invalidations...)
+}
+
+// PromiseInvalidation is an issuer signed record voiding every promise of the channel above MaxAmount.
+// Consumers issue it on abandoned sessions to cap their exposure.
+type PromiseInvalidation struct {
+	ChannelID []byte
+	ChainID   int64
+	MaxAmount *big.Int
+	Signature []byte
+	// Domain holds the prefixes of the deployment, the defaults are used if not set.
+	Domain DomainSeparators
+}
+
+// CreatePromiseInvalidation creates and signs a new invalidation record using the default prefixes.
+func CreatePromiseInvalidation(channelID []byte, chainID int64, maxAmount *big.Int, ks hashSigner, signer common.Address) (*PromiseInvalidation, error) {
+	if maxAmount == nil {
+		return nil, ErrMissingMaxAmount
+	}
+	pi := PromiseInvalidation{
+		ChannelID: channelID,
+		ChainID:   chainID,
+		MaxAmount: maxAmount,
+	}
+
+	sig, err := ks.SignHash(accounts.Account{Address: signer}, keccak256(pi.GetMessage()))
+	if err != nil {
+		return nil, err
+	}
+	if err := ReformatSignatureVForBC(sig); err != nil {
+		return nil, fmt.Errorf("failed to reformat signature: %w", err)
+	}
+	pi.Signature = sig
+
+	return &pi, nil
+}
+
+const promiseInvalidationPrefix = "invalidate"
+
+// GetMessage forms the message of the invalidation record, a nil MaxAmount is encoded as zero.
+func (pi PromiseInvalidation) GetMessage() []byte {
+	maxAmount := new(big.Int)
+	if pi.MaxAmount != nil {
+		maxAmount.Set(pi.MaxAmount)
+	}
+
+	message := []byte(pi.Domain.withDefaults().PromiseInvalidation)
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(pi.ChainID))
+	message = append(message, Pad(b, 32)...)
+	message = append(message, Pad(pi.ChannelID, 32)...)
+	message = append(message, Pad(math.U256(maxAmount).Bytes(), 32)...)
+	return message
+}
+
+// RecoverSigner recovers the signer of the invalidation record.
+func (pi PromiseInvalidation) RecoverSigner() (common.Address, error) {
+	return recoverWithSignature(pi.GetMessage(), pi.Signature)
+}
+
+// Covers checks whether the promise is voided by the invalidation record.
+// Records without MaxAmount are malformed and cover nothing.
+func (pi PromiseInvalidation) Covers(p Promise) bool {
+	return pi.MaxAmount != nil && p.Amount != nil &&
+		pi.ChainID == p.ChainID &&
+		common.BytesToHash(pi.ChannelID) == common.BytesToHash(p.ChannelID) &&
+		p.Amount.Cmp(pi.MaxAmount) > 0
+}
+
+// CheckInvalidations returns ErrPromiseInvalidated if any invalidation record signed by the signer covers the promise.
+func CheckInvalidations(p Promise, signer common.Address, invalidations ...PromiseInvalidation) error {
+	for _, pi := range invalidations {
+		if !pi.Covers(p) {
+			continue
+		}
+		recovered, err := pi.RecoverSigner()
+		if err != nil || recovered != signer {
+			continue
+		}
+		return fmt.Errorf("%w above %v", ErrPromiseInvalidated, pi.MaxAmount)
+	}
+	return nil
+}
+
+func recoverWithSignature(message, signature []byte) (common.Address, error) {
+	sig := make([]byte, 65)
+	copy(sig, signature)
+
+	if err := ReformatSignatureVForRecovery(sig); err != nil {
+		return common.Address{}, err
+	}
+	return RecoverAddress(message, sig)
+}
@@ -0,0 +1,76 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiringPromise(t *testing.T) {
+	dir, ks := tmpKeyStore(t, false)
+	defer os.RemoveAll(dir)
+
+	account, err := ks.ImportECDSA(getPrivKey("consumer"), "")
+	assert.NoError(t, err)
+	assert.NoError(t, ks.Unlock(account, ""))
+	signer := account.Address
+
+	p := Promise{
+		ChannelID: make([]byte, 32),
+		ChainID:   5,
+		Amount:    big.NewInt(100),
+		Fee:       big.NewInt(1),
+		Hashlock:  crypto.Keccak256([]byte("r")),
+	}
+	assert.NoError(t, p.Sign(ks, signer))
+
+	until := time.Unix(2000, 0)
+	ep, err := NewExpiringPromise(p, PromiseValidity{ValidUntilBlock: 10, ValidUntil: until}, ks, signer)
+	assert.NoError(t, err)
+
+	assert.NoError(t, ep.Validate(signer, 10, until))
+	assert.Equal(t, ErrPromiseExpired, ep.Validate(signer, 11, until))
+	assert.Equal(t, ErrPromiseExpired, ep.Validate(signer, 10, until.Add(time.Second)))
+
+	tampered := *ep
+	tampered.Validity.ValidUntilBlock = 100
+	assert.Error(t, tampered.Validate(signer, 50, until))
+
+	pi, err := CreatePromiseInvalidation(p.ChannelID, p.ChainID, big.NewInt(50), ks, signer)
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(ep.Validate(signer, 10, until, *pi), ErrPromiseInvalidated))
+
+	pi, err = CreatePromiseInvalidation(p.ChannelID, p.ChainID, big.NewInt(100), ks, signer)
+	assert.NoError(t, err)
+	assert.NoError(t, ep.Validate(signer, 10, until, *pi))
+
+	_, err = CreatePromiseInvalidation(p.ChannelID, p.ChainID, nil, ks, signer)
+	assert.Equal(t, ErrMissingMaxAmount, err)
+
+	malformed := *pi
+	malformed.MaxAmount = nil
+	assert.NotPanics(t, func() { malformed.GetMessage() })
+	assert.False(t, malformed.Covers(p))
+	assert.NoError(t, ep.Validate(signer, 10, until, malformed))
+}
@@ -0,0 +1,150 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// ContractVersion identifies the encoding and hashing rules of hermes and channel implementations.
+type ContractVersion uint64
+
+const (
+	// ContractVersionUnknown is used when the version could not be detected.
+	ContractVersionUnknown ContractVersion = 0
+	// ContractVersionV1 contracts verify promises and exchange messages that are not bound to a chain.
+	ContractVersionV1 ContractVersion = 1
+	// ContractVersionV2 contracts verify promises and exchange messages that include the chain id.
+	ContractVersionV2 ContractVersion = 2
+)
+
+// LatestContractVersion is the version used by GetMessage and GetHash.
+const LatestContractVersion = ContractVersionV2
+
+// ErrUnsupportedContractVersion is returned when no encoding rules exist for the contract version.
+var ErrUnsupportedContractVersion = errors.New("unsupported contract version")
+
+// Supported returns true if encoding rules exist for the version.
+func (v ContractVersion) Supported() bool {
+	return v == ContractVersionV1 || v == ContractVersionV2
+}
+
+func (v ContractVersion) String() string {
+	return fmt.Sprintf("v%d", uint64(v))
+}
+
+// GetMessageForVersion forms the message of payment promise for the given contract version.
+func (p Promise) GetMessageForVersion(v ContractVersion) ([]byte, error) {
+	switch v {
+	case ContractVersionV1:
+		message := []byte{}
+		message = append(message, Pad(p.ChannelID, 32)...)
+		message = append(message, Pad(math.U256(p.Amount).Bytes(), 32)...)
+		message = append(message, Pad(math.U256(p.Fee).Bytes(), 32)...)
+		message = append(message, Pad(p.Hashlock, 32)...)
+		return message, nil
+	case ContractVersionV2:
+		return p.GetMessage(), nil
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedContractVersion, v)
+	}
+}
+
+// GetHashForVersion returns a keccak of payment promise message for the given contract version.
+func (p Promise) GetHashForVersion(v ContractVersion) ([]byte, error) {
+	message, err := p.GetMessageForVersion(v)
+	if err != nil {
+		return nil, err
+	}
+	return keccak256(message), nil
+}
+
+// CreateSignatureForVersion signs promise for the given contract version.
+func (p Promise) CreateSignatureForVersion(v ContractVersion, ks hashSigner, signer common.Address) ([]byte, error) {
+	hash, err := p.GetHashForVersion(v)
+	if err != nil {
+		return nil, err
+	}
+	return ks.SignHash(accounts.Account{Address: signer}, hash)
+}
+
+// RecoverSignerForVersion recovers signer address out of promise signature for the given contract version.
+func (p Promise) RecoverSignerForVersion(v ContractVersion) (common.Address, error) {
+	message, err := p.GetMessageForVersion(v)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	sig := make([]byte, 65)
+	copy(sig, p.Signature)
+	if err := ReformatSignatureVForRecovery(sig); err != nil {
+		return common.Address{}, err
+	}
+	return RecoverAddress(message, sig)
+}
+
+// GetMessageForVersion forms the message of promise exchange request for the given contract version.
+func (m ExchangeMessage) GetMessageForVersion(v ContractVersion) ([]byte, error) {
+	promiseHash, err := m.Promise.GetHashForVersion(v)
+	if err != nil {
+		return nil, err
+	}
+
+	message := []byte{}
+	if v != ContractVersionV1 {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, uint64(m.ChainID))
+		message = append(message, Pad(b, 32)...)
+	}
+	message = append(message, promiseHash...)
+	message = append(message, Pad(math.U256(m.AgreementID).Bytes(), 32)...)
+	message = append(message, Pad(math.U256(m.AgreementTotal).Bytes(), 32)...)
+	message = append(message, common.HexToAddress(m.Provider).Bytes()...)
+	if m.HermesID != "" {
+		message = append(message, common.HexToAddress(m.HermesID).Bytes()...)
+	}
+	return message, nil
+}
+
+// CreateSignatureForVersion signs exchange message for the given contract version.
+func (m ExchangeMessage) CreateSignatureForVersion(v ContractVersion, ks hashSigner, signer common.Address) ([]byte, error) {
+	message, err := m.GetMessageForVersion(v)
+	if err != nil {
+		return nil, err
+	}
+	return ks.SignHash(accounts.Account{Address: signer}, keccak256(message))
+}
+
+// RecoverConsumerIdentityForVersion recovers the identity from the exchange message for the given contract version.
+func (m ExchangeMessage) RecoverConsumerIdentityForVersion(v ContractVersion) (common.Address, error) {
+	message, err := m.GetMessageForVersion(v)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	signature := m.GetSignatureBytesRaw()
+	if err := ReformatSignatureVForRecovery(signature); err != nil {
+		return common.Address{}, err
+	}
+	return RecoverAddress(message, signature)
+}
@@ -0,0 +1,65 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package crypto
+
+import (
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromiseVersions(t *testing.T) {
+	dir, ks := tmpKeyStore(t, false)
+	defer os.RemoveAll(dir)
+
+	account, err := ks.ImportECDSA(getPrivKey("consumer"), "")
+	assert.NoError(t, err)
+	assert.NoError(t, ks.Unlock(account, ""))
+	signer := account.Address
+
+	p := Promise{
+		ChannelID: make([]byte, 32),
+		ChainID:   5,
+		Amount:    big.NewInt(100),
+		Fee:       big.NewInt(1),
+		Hashlock:  crypto.Keccak256([]byte("r")),
+	}
+
+	v2, err := p.GetHashForVersion(ContractVersionV2)
+	assert.NoError(t, err)
+	assert.Equal(t, p.GetHash(), v2)
+
+	v1, err := p.GetHashForVersion(ContractVersionV1)
+	assert.NoError(t, err)
+	assert.NotEqual(t, v1, v2)
+
+	_, err = p.GetHashForVersion(ContractVersionUnknown)
+	assert.Error(t, err)
+
+	sig, err := p.CreateSignatureForVersion(ContractVersionV1, ks, signer)
+	assert.NoError(t, err)
+	assert.NoError(t, ReformatSignatureVForBC(sig))
+	p.Signature = sig
+
+	recovered, err := p.RecoverSignerForVersion(ContractVersionV1)
+	assert.NoError(t, err)
+	assert.Equal(t, signer, recovered)
+	assert.False(t, p.IsPromiseValid(signer))
+}
@@ -0,0 +1,261 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package deploy deploys the full payments contract suite to private networks.
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/mysteriumnetwork/payments/client"
+)
+
+// Backend is the blockchain backend contracts are deployed to.
+type Backend interface {
+	bind.ContractBackend
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// Params describes the deployed suite.
+type Params struct {
+	// InitialSupply is the amount of myst minted to the deployer.
+	InitialSupply *big.Int
+	// DEXRate is the eth to myst rate of the DEX stub.
+	DEXRate *big.Int
+	// MinimalHermesStake is the minimal stake the registry requires from hermeses.
+	MinimalHermesStake *big.Int
+
+	// HermesOperator is the operator of the registered hermes. The deployer is used if empty.
+	HermesOperator  common.Address
+	HermesStake     *big.Int
+	HermesFee       uint16
+	MinChannelStake *big.Int
+	MaxChannelStake *big.Int
+	HermesURL       string
+}
+
+// DefaultParams returns params suitable for local development networks.
+func DefaultParams() Params {
+	myst := new(big.Int).SetUint64(1_000_000_000_000_000_000)
+	return Params{
+		InitialSupply:      new(big.Int).Mul(big.NewInt(1_000_000), myst),
+		DEXRate:            big.NewInt(1),
+		MinimalHermesStake: new(big.Int).Mul(big.NewInt(100), myst),
+		HermesStake:        new(big.Int).Mul(big.NewInt(100), myst),
+		HermesFee:          400,
+		MinChannelStake:    big.NewInt(0),
+		MaxChannelStake:    new(big.Int).Mul(big.NewInt(1000), myst),
+		HermesURL:          "http://localhost:8889/api/v2",
+	}
+}
+
+// Suite contains the addresses of the deployed contracts.
+type Suite struct {
+	client.SmartContractAddresses
+	OldMyst common.Address
+	DEX     common.Address
+	// Verifications hold the block explorer verification data of every deployed contract, in deployment order.
+	// The hermes proxy is deployed by the registry and is not included.
+	Verifications []Verification
+}
+
+// AddressKeeper returns an address keeper holding the deployed addresses for the given chain.
+func (s Suite) AddressKeeper(chainID int64) *client.MultiChainAddressKeeper {
+	return client.NewMultiChainAddressKeeper(map[int64]client.SmartContractAddresses{
+		chainID: s.SmartContractAddresses,
+	})
+}
+
+// Deployer deploys the contract suite.
+type Deployer struct {
+	backend Backend
+	opts    *bind.TransactOpts
+	timeout time.Duration
+}
+
+// NewDeployer returns a new deployer that sends transactions using the given transact opts.
+// The timeout is applied when waiting for each transaction to be mined.
+func NewDeployer(backend Backend, opts *bind.TransactOpts, timeout time.Duration) *Deployer {
+	return &Deployer{
+		backend: backend,
+		opts:    opts,
+		timeout: timeout,
+	}
+}
+
+// Deploy deploys the token, the DEX stub, the channel and hermes implementations and the registry, in that order,
+// and then registers a hermes, which deploys its proxy.
+func (d *Deployer) Deploy(p Params) (Suite, error) {
+	var s Suite
+
+	oldMystAddress, tx, _, err := bindings.DeployOldMystToken(d.opts, d.backend)
+	if err := d.waitDeployed("old myst token", tx, err); err != nil {
+		return s, err
+	}
+	s.OldMyst = oldMystAddress
+	if err := s.addVerification("OldMystToken", oldMystAddress, bindings.OldMystTokenABI, bindings.OldMystTokenBin); err != nil {
+		return s, err
+	}
+
+	mystAddress, tx, myst, err := bindings.DeployMystToken(d.opts, d.backend, oldMystAddress)
+	if err := d.waitDeployed("myst token", tx, err); err != nil {
+		return s, err
+	}
+	s.Myst = mystAddress
+	if err := s.addVerification("MystToken", mystAddress, bindings.MystTokenABI, bindings.MystTokenBin, oldMystAddress); err != nil {
+		return s, err
+	}
+
+	if err := d.mint(myst, p.InitialSupply); err != nil {
+		return s, err
+	}
+
+	dexAddress, tx, dex, err := bindings.DeployMystDEX(d.opts, d.backend)
+	if err := d.waitDeployed("dex", tx, err); err != nil {
+		return s, err
+	}
+	s.DEX = dexAddress
+	if err := s.addVerification("MystDEX", dexAddress, bindings.MystDEXABI, bindings.MystDEXBin); err != nil {
+		return s, err
+	}
+
+	tx, err = dex.Initialise(d.opts, d.opts.From, mystAddress, p.DEXRate)
+	if err := d.waitMined("initialise dex", tx, err); err != nil {
+		return s, err
+	}
+
+	s.ChannelImplementation, tx, _, err = bindings.DeployChannelImplementation(d.opts, d.backend)
+	if err := d.waitDeployed("channel implementation", tx, err); err != nil {
+		return s, err
+	}
+	if err := s.addVerification("ChannelImplementation", s.ChannelImplementation, bindings.ChannelImplementationABI, bindings.ChannelImplementationBin); err != nil {
+		return s, err
+	}
+
+	s.HermesImplementation, tx, _, err = bindings.DeployHermesImplementation(d.opts, d.backend)
+	if err := d.waitDeployed("hermes implementation", tx, err); err != nil {
+		return s, err
+	}
+	if err := s.addVerification("HermesImplementation", s.HermesImplementation, bindings.HermesImplementationABI, bindings.HermesImplementationBin); err != nil {
+		return s, err
+	}
+
+	registryAddress, tx, registry, err := bindings.DeployRegistry(d.opts, d.backend, mystAddress, dexAddress, p.MinimalHermesStake, s.ChannelImplementation, s.HermesImplementation)
+	if err := d.waitDeployed("registry", tx, err); err != nil {
+		return s, err
+	}
+	s.Registry = registryAddress
+	if err := s.addVerification("Registry", registryAddress, bindings.RegistryABI, bindings.RegistryBin, mystAddress, dexAddress, p.MinimalHermesStake, s.ChannelImplementation, s.HermesImplementation); err != nil {
+		return s, err
+	}
+
+	hermes, err := d.registerHermes(registry, registryAddress, mystAddress, p)
+	if err != nil {
+		return s, err
+	}
+	s.Hermes = hermes
+
+	return s, nil
+}
+
+func (s *Suite) addVerification(contract string, address common.Address, abiJSON, bin string, args ...interface{}) error {
+	v, err := NewVerification(contract, address, abiJSON, bin, args...)
+	if err != nil {
+		return err
+	}
+	s.Verifications = append(s.Verifications, v)
+	return nil
+}
+
+// mint mints the initial supply to the deployer.
+func (d *Deployer) mint(myst *bindings.MystToken, amount *big.Int) error {
+	if amount == nil || amount.Sign() == 0 {
+		return nil
+	}
+
+	tx, err := myst.Mint(d.opts, d.opts.From, amount)
+	if err := d.waitMined("mint", tx, err); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *Deployer) registerHermes(registry *bindings.Registry, registryAddress, mystAddress common.Address, p Params) (common.Address, error) {
+	operator := p.HermesOperator
+	if operator == (common.Address{}) {
+		operator = d.opts.From
+	}
+
+	myst, err := bindings.NewMystToken(mystAddress, d.backend)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	tx, err := myst.Approve(d.opts, registryAddress, p.HermesStake)
+	if err := d.waitMined("approve hermes stake", tx, err); err != nil {
+		return common.Address{}, err
+	}
+
+	tx, err = registry.RegisterHermes(d.opts, operator, p.HermesStake, p.HermesFee, p.MinChannelStake, p.MaxChannelStake, []byte(p.HermesURL))
+	if err := d.waitMined("register hermes", tx, err); err != nil {
+		return common.Address{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	return registry.GetHermesAddress0(&bind.CallOpts{Context: ctx}, operator)
+}
+
+func (d *Deployer) waitDeployed(name string, tx *types.Transaction, err error) error {
+	if err != nil {
+		return fmt.Errorf("could not deploy %v: %w", name, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	if _, err := bind.WaitDeployed(ctx, d.backend, tx); err != nil {
+		return fmt.Errorf("could not deploy %v: %w", name, err)
+	}
+	return nil
+}
+
+func (d *Deployer) waitMined(name string, tx *types.Transaction, err error) error {
+	if err != nil {
+		return fmt.Errorf("could not %v: %w", name, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	receipt, err := bind.WaitMined(ctx, d.backend, tx)
+	if err != nil {
+		return fmt.Errorf("could not %v: %w", name, err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("could not %v: %w", name, errTxFailed)
+	}
+	return nil
+}
+
+var errTxFailed = errors.New("transaction failed")
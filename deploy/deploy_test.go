@@ -0,0 +1,62 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package deploy
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/mysteriumnetwork/payments/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeploy(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	opts := bind.NewKeyedTransactor(key)
+	backend := testutil.NewSimulatedBackend(core.GenesisAlloc{opts.From: {Balance: big.NewInt(0).Exp(big.NewInt(10), big.NewInt(20), nil)}}, 10000000)
+	defer backend.Close()
+
+	suite, err := NewDeployer(backend, opts, time.Second*5).Deploy(DefaultParams())
+	assert.NoError(t, err)
+
+	registry, err := bindings.NewRegistryCaller(suite.Registry, backend)
+	assert.NoError(t, err)
+	isHermes, err := registry.IsHermes(nil, suite.Hermes)
+	assert.NoError(t, err)
+	assert.True(t, isHermes)
+
+	hermes, err := suite.AddressKeeper(1337).GetActiveHermes(1337)
+	assert.NoError(t, err)
+	assert.Equal(t, suite.Hermes, hermes)
+
+	assert.Len(t, suite.Verifications, 6)
+	token := suite.Verifications[1]
+	assert.Equal(t, "MystToken", token.Contract)
+	assert.Equal(t, suite.Myst, token.Address)
+	assert.Equal(t, "0.7.4", token.CompilerVersion)
+	assert.Equal(t, common.LeftPadBytes(suite.OldMyst.Bytes(), 32), token.ConstructorArgs)
+
+	registryVerification := suite.Verifications[5]
+	assert.Equal(t, suite.Registry, registryVerification.Address)
+	assert.Len(t, registryVerification.ConstructorArgs, 5*32)
+}
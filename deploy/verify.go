@@ -0,0 +1,152 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package deploy
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrNoCompilerVersion is returned when the bytecode carries no solc metadata.
+var ErrNoCompilerVersion = errors.New("no compiler version in bytecode metadata")
+
+// solcMetadataKey is the CBOR encoded "solc" key of the metadata appended to the bytecode by the compiler.
+const solcMetadataKey = "64736f6c6343"
+
+// Verification holds what block explorers need to verify a deployed contract.
+type Verification struct {
+	// Contract is the name of the deployed contract.
+	Contract string         `json:"contract"`
+	Address  common.Address `json:"address"`
+	// CompilerVersion is the solc version read from the bytecode metadata, e.g. 0.7.4.
+	CompilerVersion string `json:"compilerVersion"`
+	// ConstructorArgs are the ABI encoded constructor arguments appended to the deployed bytecode.
+	ConstructorArgs []byte          `json:"-"`
+	ABI             json.RawMessage `json:"abi"`
+}
+
+// ConstructorArgsHex returns the constructor arguments as hex without the 0x prefix, as expected by etherscan and polygonscan.
+func (v Verification) ConstructorArgsHex() string {
+	return hex.EncodeToString(v.ConstructorArgs)
+}
+
+// MarshalJSON includes the hex encoded constructor arguments.
+func (v Verification) MarshalJSON() ([]byte, error) {
+	type verification Verification
+	return json.Marshal(struct {
+		verification
+		ConstructorArgs string `json:"constructorArguments"`
+	}{
+		verification:    verification(v),
+		ConstructorArgs: v.ConstructorArgsHex(),
+	})
+}
+
+// CompilerSettings are the solc settings the contracts were compiled with.
+// They are not part of the bytecode, so they have to be provided by the caller.
+type CompilerSettings struct {
+	OptimizerEnabled bool
+	OptimizerRuns    int
+	EVMVersion       string
+}
+
+// StandardJSONInput returns the solc standard-json input used for verification.
+// The sources, keyed by their path, come from the contracts repository.
+func (v Verification) StandardJSONInput(sources map[string]string, settings CompilerSettings) ([]byte, error) {
+	type source struct {
+		Content string `json:"content"`
+	}
+	type optimizer struct {
+		Enabled bool `json:"enabled"`
+		Runs    int  `json:"runs"`
+	}
+	type solcSettings struct {
+		Optimizer       optimizer                      `json:"optimizer"`
+		EVMVersion      string                         `json:"evmVersion,omitempty"`
+		OutputSelection map[string]map[string][]string `json:"outputSelection"`
+	}
+	input := struct {
+		Language string            `json:"language"`
+		Sources  map[string]source `json:"sources"`
+		Settings solcSettings      `json:"settings"`
+	}{
+		Language: "Solidity",
+		Sources:  make(map[string]source, len(sources)),
+		Settings: solcSettings{
+			Optimizer:  optimizer{Enabled: settings.OptimizerEnabled, Runs: settings.OptimizerRuns},
+			EVMVersion: settings.EVMVersion,
+			OutputSelection: map[string]map[string][]string{
+				"*": {"*": {"abi", "evm.bytecode", "evm.deployedBytecode", "metadata"}},
+			},
+		},
+	}
+	for path, content := range sources {
+		input.Sources[path] = source{Content: content}
+	}
+	return json.Marshal(input)
+}
+
+// NewVerification returns the verification data of a contract deployed with the given constructor arguments.
+func NewVerification(contract string, address common.Address, abiJSON, bin string, args ...interface{}) (Verification, error) {
+	packed, err := EncodeConstructorArgs(abiJSON, args...)
+	if err != nil {
+		return Verification{}, fmt.Errorf("could not encode %v constructor arguments: %w", contract, err)
+	}
+
+	version, err := CompilerVersion(bin)
+	if err != nil {
+		return Verification{}, fmt.Errorf("could not get %v compiler version: %w", contract, err)
+	}
+
+	return Verification{
+		Contract:        contract,
+		Address:         address,
+		CompilerVersion: version,
+		ConstructorArgs: packed,
+		ABI:             json.RawMessage(abiJSON),
+	}, nil
+}
+
+// EncodeConstructorArgs ABI encodes the constructor arguments of the given contract ABI.
+func EncodeConstructorArgs(abiJSON string, args ...interface{}) ([]byte, error) {
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		return nil, err
+	}
+	return parsed.Pack("", args...)
+}
+
+// CompilerVersion reads the solc version from the metadata appended to the given hex bytecode.
+func CompilerVersion(bin string) (string, error) {
+	bin = strings.TrimPrefix(bin, "0x")
+	i := strings.LastIndex(bin, solcMetadataKey)
+	if i < 0 || len(bin) < i+len(solcMetadataKey)+6 {
+		return "", ErrNoCompilerVersion
+	}
+
+	version, err := hex.DecodeString(bin[i+len(solcMetadataKey) : i+len(solcMetadataKey)+6])
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v.%v.%v", version[0], version[1], version[2]), nil
+}
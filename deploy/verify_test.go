@@ -0,0 +1,60 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package deploy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompilerVersion(t *testing.T) {
+	version, err := CompilerVersion(bindings.OldMystTokenBin)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.6.12", version)
+
+	_, err = CompilerVersion("0x6080")
+	assert.Equal(t, ErrNoCompilerVersion, err)
+}
+
+func TestVerificationJSON(t *testing.T) {
+	old := common.HexToAddress("0x1")
+	v, err := NewVerification("MystToken", common.HexToAddress("0x2"), bindings.MystTokenABI, bindings.MystTokenBin, old)
+	assert.NoError(t, err)
+
+	_, err = NewVerification("MystToken", common.HexToAddress("0x2"), bindings.MystTokenABI, bindings.MystTokenBin)
+	assert.Error(t, err)
+
+	out, err := json.Marshal(v)
+	assert.NoError(t, err)
+
+	var res map[string]interface{}
+	assert.NoError(t, json.Unmarshal(out, &res))
+	assert.Equal(t, "MystToken", res["contract"])
+	assert.Equal(t, "0.7.4", res["compilerVersion"])
+	assert.Equal(t, "0000000000000000000000000000000000000000000000000000000000000001", res["constructorArguments"])
+	assert.NotEmpty(t, res["abi"])
+
+	input, err := v.StandardJSONInput(map[string]string{"contracts/MystToken.sol": "contract MystToken {}"}, CompilerSettings{OptimizerEnabled: true, OptimizerRuns: 200})
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(input, &res))
+	assert.Equal(t, "Solidity", res["language"])
+	assert.Contains(t, res["sources"], "contracts/MystToken.sol")
+}
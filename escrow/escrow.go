@@ -0,0 +1,272 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package escrow holds issued promises for marketplace integrations until the delivery of the goods is confirmed.
+// Promises are issued with a hashlock whose R only the escrow knows, so the receiver can not settle them before
+// the escrow releases the R.
+package escrow
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+var (
+	// ErrDealNotFound is returned for unknown deals.
+	ErrDealNotFound = errors.New("deal not found")
+	// ErrInvalidPromise is returned when depositing a promise not matching the deal.
+	ErrInvalidPromise = errors.New("invalid promise")
+	// ErrNotDelivered is returned when releasing a deal whose delivery is not confirmed by the condition.
+	ErrNotDelivered = errors.New("delivery not confirmed")
+	// ErrInvalidState is returned when the deal is not in the state the operation requires.
+	ErrInvalidState = errors.New("invalid deal state")
+)
+
+// State is the state of a deal.
+type State string
+
+const (
+	// StateOpen deals wait for the promise of the payer.
+	StateOpen State = "open"
+	// StateHeld deals hold the promise until the delivery is confirmed.
+	StateHeld State = "held"
+	// StateReleased deals released the R of the promise to the receiver.
+	StateReleased State = "released"
+	// StateCancelled deals will never be released, the promise can not be settled.
+	StateCancelled State = "cancelled"
+)
+
+// Deal is a payment held by the escrow.
+type Deal struct {
+	// ID is the hex encoded hashlock of the promise.
+	ID string
+	// Reference identifies the order of the marketplace the payment is for.
+	Reference string
+	Payer     common.Address
+	Receiver  common.Address
+	// Promise is the promise deposited by the payer, its R is set once the deal is released.
+	Promise *crypto.Promise
+	// R is the preimage of the hashlock, only known to the escrow until the deal is released.
+	R         []byte
+	State     State
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Storage persists the deals.
+type Storage interface {
+	UpsertDeal(d Deal) error
+	// GetDeal returns nil if the deal does not exist.
+	GetDeal(id string) (*Deal, error)
+}
+
+// Condition confirms the delivery of the deal, e.g. by asking the marketplace.
+// The deal is released if it returns true.
+type Condition func(d Deal) (bool, error)
+
+// DeliverFunc hands the released promise, settleable with its R, over to the receiver.
+type DeliverFunc func(receiver common.Address, promise crypto.Promise) error
+
+// Opts configure the escrow.
+type Opts struct {
+	// Condition confirms the delivery of deals before they are released.
+	Condition Condition
+	// Deliver is optional, released promises are only returned by Release without it.
+	Deliver DeliverFunc
+}
+
+// Escrow holds promises until an external condition confirms delivery.
+type Escrow struct {
+	opts    Opts
+	storage Storage
+	now     func() time.Time
+
+	lock sync.Mutex
+}
+
+// New returns a new escrow.
+func New(storage Storage, opts Opts) (*Escrow, error) {
+	if opts.Condition == nil {
+		return nil, errors.New("escrow condition must be provided")
+	}
+	return &Escrow{
+		opts:    opts,
+		storage: storage,
+		now:     time.Now,
+	}, nil
+}
+
+// Open starts a deal and returns it. The payer issues the promise with the hashlock of the deal, see Hashlock.
+func (e *Escrow) Open(payer, receiver common.Address, reference string) (Deal, error) {
+	r := make([]byte, 32)
+	if _, err := rand.Read(r); err != nil {
+		return Deal{}, fmt.Errorf("could not generate R: %w", err)
+	}
+
+	now := e.now().UTC()
+	d := Deal{
+		ID:        hexutil.Encode(ethcrypto.Keccak256(r)),
+		Reference: reference,
+		Payer:     payer,
+		Receiver:  receiver,
+		R:         r,
+		State:     StateOpen,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := e.storage.UpsertDeal(d); err != nil {
+		return Deal{}, err
+	}
+	return d, nil
+}
+
+// Hashlock returns the hashlock the promise of the deal must be issued with.
+func (d Deal) Hashlock() []byte {
+	return hexutil.MustDecode(d.ID)
+}
+
+// Deposit stores the promise of the payer. It must be signed by the payer and carry the hashlock of the deal.
+// Depositing the same promise again is a no-op.
+func (e *Escrow) Deposit(id string, promise crypto.Promise) (Deal, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	d, err := e.get(id)
+	if err != nil {
+		return Deal{}, err
+	}
+	if d.State == StateHeld && d.Promise != nil && bytes.Equal(d.Promise.Signature, promise.Signature) {
+		return *d, nil
+	}
+	if d.State != StateOpen {
+		return Deal{}, fmt.Errorf("%w: deal %v is %v", ErrInvalidState, id, d.State)
+	}
+
+	if !bytes.Equal(promise.Hashlock, d.Hashlock()) {
+		return Deal{}, fmt.Errorf("%w: hashlock does not match the deal", ErrInvalidPromise)
+	}
+	if promise.Amount == nil || promise.Amount.Sign() <= 0 {
+		return Deal{}, fmt.Errorf("%w: amount must be positive", ErrInvalidPromise)
+	}
+	promise.R = nil
+	if !promise.IsPromiseValid(d.Payer) {
+		return Deal{}, fmt.Errorf("%w: promise is not signed by %v", ErrInvalidPromise, d.Payer.Hex())
+	}
+
+	d.Promise = &promise
+	d.State = StateHeld
+	return e.update(d)
+}
+
+// Release checks the condition and releases the held promise with its R once the delivery is confirmed.
+// The promise is handed to the Deliver func, if one is configured, and returned.
+// Releasing a released deal delivers it again.
+func (e *Escrow) Release(id string) (crypto.Promise, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	d, err := e.get(id)
+	if err != nil {
+		return crypto.Promise{}, err
+	}
+
+	switch d.State {
+	case StateHeld:
+		delivered, err := e.opts.Condition(*d)
+		if err != nil {
+			return crypto.Promise{}, fmt.Errorf("could not check delivery: %w", err)
+		}
+		if !delivered {
+			return crypto.Promise{}, fmt.Errorf("%w: deal %v", ErrNotDelivered, id)
+		}
+
+		d.Promise.R = d.R
+		d.State = StateReleased
+		if _, err := e.update(d); err != nil {
+			return crypto.Promise{}, err
+		}
+	case StateReleased:
+	default:
+		return crypto.Promise{}, fmt.Errorf("%w: deal %v is %v", ErrInvalidState, id, d.State)
+	}
+
+	if e.opts.Deliver != nil {
+		if err := e.opts.Deliver(d.Receiver, *d.Promise); err != nil {
+			return *d.Promise, fmt.Errorf("could not deliver promise: %w", err)
+		}
+	}
+	return *d.Promise, nil
+}
+
+// Cancel cancels a deal that was not released. The R is never revealed, so its promise can not be settled.
+func (e *Escrow) Cancel(id string) (Deal, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	d, err := e.get(id)
+	if err != nil {
+		return Deal{}, err
+	}
+	switch d.State {
+	case StateCancelled:
+		return *d, nil
+	case StateReleased:
+		return Deal{}, fmt.Errorf("%w: deal %v is %v", ErrInvalidState, id, d.State)
+	}
+
+	d.State = StateCancelled
+	return e.update(d)
+}
+
+// Get returns the deal.
+func (e *Escrow) Get(id string) (Deal, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	d, err := e.get(id)
+	if err != nil {
+		return Deal{}, err
+	}
+	return *d, nil
+}
+
+func (e *Escrow) update(d *Deal) (Deal, error) {
+	d.UpdatedAt = e.now().UTC()
+	if err := e.storage.UpsertDeal(*d); err != nil {
+		return Deal{}, err
+	}
+	return *d, nil
+}
+
+func (e *Escrow) get(id string) (*Deal, error) {
+	d, err := e.storage.GetDeal(id)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, fmt.Errorf("%w: %v", ErrDealNotFound, id)
+	}
+	return d, nil
+}
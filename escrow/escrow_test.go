@@ -0,0 +1,125 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package escrow
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type keySigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (ks keySigner) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
+	return ethcrypto.Sign(hash, ks.key)
+}
+
+type memStorage map[string]Deal
+
+func (m memStorage) UpsertDeal(d Deal) error { m[d.ID] = d; return nil }
+func (m memStorage) GetDeal(id string) (*Deal, error) {
+	d, ok := m[id]
+	if !ok {
+		return nil, nil
+	}
+	return &d, nil
+}
+
+func TestEscrow(t *testing.T) {
+	key, err := ethcrypto.GenerateKey()
+	assert.NoError(t, err)
+	payer, ks := ethcrypto.PubkeyToAddress(key.PublicKey), keySigner{key: key}
+	receiver := common.HexToAddress("0x2")
+
+	delivered := false
+	var deliveries []crypto.Promise
+	e, err := New(memStorage{}, Opts{
+		Condition: func(d Deal) (bool, error) { return delivered && d.Reference == "order-1", nil },
+		Deliver: func(to common.Address, p crypto.Promise) error {
+			assert.Equal(t, receiver, to)
+			deliveries = append(deliveries, p)
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+
+	d, err := e.Open(payer, receiver, "order-1")
+	assert.NoError(t, err)
+	assert.Equal(t, StateOpen, d.State)
+	assert.Equal(t, ethcrypto.Keccak256(d.R), d.Hashlock())
+
+	issue := func(hashlock []byte) crypto.Promise {
+		p, err := crypto.CreatePromise(common.HexToHash("0x1").Hex(), 1, big.NewInt(100), big.NewInt(0), hexutil.Encode(hashlock), ks, payer)
+		assert.NoError(t, err)
+		return *p
+	}
+
+	_, err = e.Deposit(d.ID, issue(common.HexToHash("0x3").Bytes()))
+	assert.True(t, errors.Is(err, ErrInvalidPromise))
+
+	promise := issue(d.Hashlock())
+	d, err = e.Deposit(d.ID, promise)
+	assert.NoError(t, err)
+	assert.Equal(t, StateHeld, d.State)
+	assert.Nil(t, d.Promise.R)
+
+	_, err = e.Release(d.ID)
+	assert.True(t, errors.Is(err, ErrNotDelivered))
+	assert.Len(t, deliveries, 0)
+
+	delivered = true
+	released, err := e.Release(d.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, d.R, released.R)
+	assert.Equal(t, promise.Signature, released.Signature)
+	assert.Len(t, deliveries, 1)
+
+	d, err = e.Get(d.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StateReleased, d.State)
+
+	_, err = e.Cancel(d.ID)
+	assert.True(t, errors.Is(err, ErrInvalidState))
+
+	_, err = e.Release("0x00")
+	assert.True(t, errors.Is(err, ErrDealNotFound))
+}
+
+func TestEscrowCancel(t *testing.T) {
+	e, err := New(memStorage{}, Opts{Condition: func(d Deal) (bool, error) { return true, nil }})
+	assert.NoError(t, err)
+
+	d, err := e.Open(common.HexToAddress("0x1"), common.HexToAddress("0x2"), "order-2")
+	assert.NoError(t, err)
+
+	d, err = e.Cancel(d.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StateCancelled, d.State)
+
+	_, err = e.Release(d.ID)
+	assert.True(t, errors.Is(err, ErrInvalidState))
+}
@@ -0,0 +1,209 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package exposure limits the promised but unsettled amounts a hermes is exposed to.
+package exposure
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrExposureExceeded is returned when co-signing a promise would exceed an exposure limit.
+var ErrExposureExceeded = errors.New("promise exceeds the unsettled exposure limit")
+
+// Limits are the maximum promised but unsettled amounts. A nil limit is not enforced.
+type Limits struct {
+	PerProvider *big.Int
+	PerHermes   *big.Int
+}
+
+// Exposure is the state of a single provider channel.
+// Both amounts are cumulative, as promises and channel settlements are.
+type Exposure struct {
+	HermesID common.Address
+	Provider common.Address
+	Promised *big.Int
+	Settled  *big.Int
+}
+
+// Unsettled returns the promised amount that has not been settled yet.
+func (e Exposure) Unsettled() *big.Int {
+	res := new(big.Int).Sub(e.Promised, e.Settled)
+	if res.Sign() < 0 {
+		return new(big.Int)
+	}
+	return res
+}
+
+// Storage persists the exposures.
+type Storage interface {
+	UpsertExposure(e Exposure) error
+	GetExposures() ([]Exposure, error)
+}
+
+// Observer is notified about exposure changes, e.g. to export metrics.
+type Observer interface {
+	ExposureChanged(hermesID, provider common.Address, providerUnsettled, hermesUnsettled *big.Int)
+	ExposureRefused(hermesID, provider common.Address)
+}
+
+type noopObserver struct{}
+
+func (noopObserver) ExposureChanged(hermesID, provider common.Address, providerUnsettled, hermesUnsettled *big.Int) {
+}
+func (noopObserver) ExposureRefused(hermesID, provider common.Address) {}
+
+type channelKey struct {
+	hermesID common.Address
+	provider common.Address
+}
+
+// Limiter tracks the unsettled amounts per provider and per hermes and refuses promises beyond the limits.
+type Limiter struct {
+	storage  Storage
+	limits   Limits
+	observer Observer
+
+	lock      sync.Mutex
+	exposures map[channelKey]Exposure
+	totals    map[common.Address]*big.Int
+}
+
+// NewLimiter returns a new limiter loaded from the storage.
+func NewLimiter(storage Storage, limits Limits) (*Limiter, error) {
+	l := &Limiter{
+		storage:   storage,
+		limits:    limits,
+		observer:  noopObserver{},
+		exposures: make(map[channelKey]Exposure),
+		totals:    make(map[common.Address]*big.Int),
+	}
+
+	exposures, err := storage.GetExposures()
+	if err != nil {
+		return nil, fmt.Errorf("could not load exposures: %w", err)
+	}
+	for _, e := range exposures {
+		l.set(e)
+	}
+	return l, nil
+}
+
+// AttachObserver attaches an observer notified about exposure changes.
+// Not thread safe, call before using the limiter.
+func (l *Limiter) AttachObserver(o Observer) {
+	l.observer = o
+}
+
+// Reserve records the promise of the given cumulative amount about to be co-signed for the provider.
+// ErrExposureExceeded is returned, and nothing is recorded, if the promise would exceed a limit.
+// Promises not increasing the promised amount are always allowed.
+func (l *Limiter) Reserve(hermesID, provider common.Address, amount *big.Int) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	current := l.get(hermesID, provider)
+	if amount.Cmp(current.Promised) <= 0 {
+		return nil
+	}
+
+	next := current
+	next.Promised = new(big.Int).Set(amount)
+
+	delta := new(big.Int).Sub(next.Unsettled(), current.Unsettled())
+	hermesTotal := new(big.Int).Add(l.total(hermesID), delta)
+	if exceeds(next.Unsettled(), l.limits.PerProvider) {
+		l.observer.ExposureRefused(hermesID, provider)
+		return fmt.Errorf("%w: provider %v would owe %v", ErrExposureExceeded, provider.Hex(), next.Unsettled())
+	}
+	if exceeds(hermesTotal, l.limits.PerHermes) {
+		l.observer.ExposureRefused(hermesID, provider)
+		return fmt.Errorf("%w: hermes %v would owe %v", ErrExposureExceeded, hermesID.Hex(), hermesTotal)
+	}
+
+	return l.store(next)
+}
+
+// Settled records the cumulative settled amount of the provider channel, releasing the exposure.
+func (l *Limiter) Settled(hermesID, provider common.Address, settled *big.Int) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	current := l.get(hermesID, provider)
+	if settled.Cmp(current.Settled) <= 0 {
+		return nil
+	}
+
+	next := current
+	next.Settled = new(big.Int).Set(settled)
+	return l.store(next)
+}
+
+// ProviderExposure returns the unsettled amount of the provider.
+func (l *Limiter) ProviderExposure(hermesID, provider common.Address) *big.Int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.get(hermesID, provider).Unsettled()
+}
+
+// HermesExposure returns the unsettled amount of all the providers of the hermes.
+func (l *Limiter) HermesExposure(hermesID common.Address) *big.Int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return new(big.Int).Set(l.total(hermesID))
+}
+
+func (l *Limiter) store(e Exposure) error {
+	if err := l.storage.UpsertExposure(e); err != nil {
+		return fmt.Errorf("could not store exposure: %w", err)
+	}
+	l.set(e)
+	l.observer.ExposureChanged(e.HermesID, e.Provider, e.Unsettled(), new(big.Int).Set(l.total(e.HermesID)))
+	return nil
+}
+
+func (l *Limiter) get(hermesID, provider common.Address) Exposure {
+	if e, ok := l.exposures[channelKey{hermesID: hermesID, provider: provider}]; ok {
+		return e
+	}
+	return Exposure{HermesID: hermesID, Provider: provider, Promised: new(big.Int), Settled: new(big.Int)}
+}
+
+func (l *Limiter) set(e Exposure) {
+	previous := l.get(e.HermesID, e.Provider)
+	total := l.total(e.HermesID)
+	total.Sub(total, previous.Unsettled())
+	total.Add(total, e.Unsettled())
+	l.exposures[channelKey{hermesID: e.HermesID, provider: e.Provider}] = e
+}
+
+func (l *Limiter) total(hermesID common.Address) *big.Int {
+	if t, ok := l.totals[hermesID]; ok {
+		return t
+	}
+	t := new(big.Int)
+	l.totals[hermesID] = t
+	return t
+}
+
+func exceeds(amount, limit *big.Int) bool {
+	return limit != nil && amount.Cmp(limit) > 0
+}
@@ -0,0 +1,96 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package exposure
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockStorage struct {
+	exposures map[common.Address]Exposure
+	fail      bool
+}
+
+func (ms *mockStorage) UpsertExposure(e Exposure) error {
+	if ms.fail {
+		return errors.New("storage down")
+	}
+	ms.exposures[e.Provider] = e
+	return nil
+}
+
+func (ms *mockStorage) GetExposures() ([]Exposure, error) {
+	var res []Exposure
+	for _, e := range ms.exposures {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+type mockObserver struct {
+	refused int
+	hermes  *big.Int
+}
+
+func (mo *mockObserver) ExposureChanged(hermesID, provider common.Address, providerUnsettled, hermesUnsettled *big.Int) {
+	mo.hermes = hermesUnsettled
+}
+
+func (mo *mockObserver) ExposureRefused(hermesID, provider common.Address) {
+	mo.refused++
+}
+
+func TestLimiter(t *testing.T) {
+	hermes := common.HexToAddress("0x1")
+	p1, p2 := common.HexToAddress("0x2"), common.HexToAddress("0x3")
+	storage := &mockStorage{exposures: make(map[common.Address]Exposure)}
+
+	l, err := NewLimiter(storage, Limits{PerProvider: big.NewInt(100), PerHermes: big.NewInt(150)})
+	assert.NoError(t, err)
+	observer := &mockObserver{}
+	l.AttachObserver(observer)
+
+	assert.NoError(t, l.Reserve(hermes, p1, big.NewInt(100)))
+	assert.True(t, errors.Is(l.Reserve(hermes, p1, big.NewInt(101)), ErrExposureExceeded))
+	assert.NoError(t, l.Reserve(hermes, p1, big.NewInt(90)), "older promises are allowed")
+
+	assert.NoError(t, l.Reserve(hermes, p2, big.NewInt(50)))
+	assert.True(t, errors.Is(l.Reserve(hermes, p2, big.NewInt(60)), ErrExposureExceeded))
+	assert.Equal(t, 2, observer.refused)
+	assert.Equal(t, big.NewInt(150), l.HermesExposure(hermes))
+	assert.Equal(t, big.NewInt(150), observer.hermes)
+
+	assert.NoError(t, l.Settled(hermes, p1, big.NewInt(80)))
+	assert.Equal(t, big.NewInt(20), l.ProviderExposure(hermes, p1))
+	assert.NoError(t, l.Reserve(hermes, p2, big.NewInt(60)))
+	assert.Equal(t, big.NewInt(80), l.HermesExposure(hermes))
+
+	storage.fail = true
+	assert.Error(t, l.Reserve(hermes, p2, big.NewInt(70)))
+	assert.Equal(t, big.NewInt(60), l.ProviderExposure(hermes, p2))
+	storage.fail = false
+
+	reloaded, err := NewLimiter(storage, Limits{})
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(80), reloaded.HermesExposure(hermes))
+	assert.NoError(t, reloaded.Reserve(hermes, p2, big.NewInt(1000)))
+}
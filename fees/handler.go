@@ -34,7 +34,8 @@ type TransactionHandler struct {
 	txWatchers map[int64]TxWatcherIface
 	inc        GasPriceIncremenetorIface
 
-	logFn func(error)
+	logFn      func(error)
+	progressFn ProgressFunc
 }
 
 // HandlerOpts are given when sending
@@ -43,6 +44,9 @@ type HandlerOpts struct {
 	ChainID         int64
 	InitGasPrice    *big.Int
 	GasPriceIncOpts TransactionOpts
+	// ProgressID identifies the progress events of the transaction.
+	// Set it to correlate the events with a UI operation, a random one is generated otherwise.
+	ProgressID string
 }
 
 // TxWatcherIface abstract any transaction watcher.
@@ -76,6 +80,14 @@ func (t *TransactionHandler) AttachLogger(fn func(err error)) {
 	t.logFn = fn
 }
 
+// AttachProgressFunc attaches a func receiving the progress events of sent transactions.
+// Attach the same func to the gas price incrementor to receive the events after the transaction is handed over to it.
+//
+// This method is not thread safe and should be called before `SendWithGasPriceHandling`.
+func (t *TransactionHandler) AttachProgressFunc(fn ProgressFunc) {
+	t.progressFn = fn
+}
+
 // SendWithGasPriceHandling given a new watchable transaction with options will send the transaction
 // and increase the gas price accordingly.
 //
@@ -93,9 +105,25 @@ func (t *TransactionHandler) SendWithGasPriceHandling(stop <-chan struct{}, wt W
 		return nil, fmt.Errorf("no tx watcher for chain ID %v", opts.ChainID)
 	}
 
+	id := opts.ProgressID
+	if id == "" && t.progressFn != nil {
+		id = newProgressID()
+	}
+	progress := func(stage ProgressStage, txHash string, reason string) {
+		emitProgress(t.progressFn, ProgressEvent{ID: id, ChainID: opts.ChainID, Stage: stage, TxHash: txHash, Reason: reason})
+	}
+	progress(StageQueued, "", "")
+	tracked := func(gasPrice *big.Int) (*types.Transaction, error) {
+		tx, err := wt(gasPrice)
+		if err == nil {
+			progress(StageBroadcast, tx.Hash().Hex(), "")
+		}
+		return tx, err
+	}
+
 	resChan := make(chan res)
 	go func() {
-		tx, c, err := watcher.EnsureTransactionSuccess(wt, opts.InitGasPrice)
+		tx, c, err := watcher.EnsureTransactionSuccess(tracked, opts.InitGasPrice)
 		cancel = c
 		resChan <- res{
 			tx:  tx,
@@ -108,10 +136,13 @@ func (t *TransactionHandler) SendWithGasPriceHandling(stop <-chan struct{}, wt W
 		return nil, ErrOperationStopped
 	case r := <-resChan:
 		if r.err != nil {
+			progress(StageFailed, "", r.err.Error())
 			return r.tx, r.err
 		}
 
-		if err := t.inc.InsertInitial(r.tx, opts.GasPriceIncOpts, opts.ChainID); err != nil {
+		incOpts := opts.GasPriceIncOpts
+		incOpts.ProgressID = id
+		if err := t.inc.InsertInitial(r.tx, incOpts, opts.ChainID); err != nil {
 			t.log(fmt.Errorf("failed to insert initial entry for gas price incremenetor: %w", err))
 		}
 
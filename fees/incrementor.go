@@ -17,6 +17,7 @@
 package fees
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 	"sync"
@@ -36,6 +37,8 @@ type GasPriceIncremenetor struct {
 	sign    SignatureFunc
 	logFn   LogFunc
 
+	progressFn ProgressFunc
+
 	syncer *syncer
 
 	stop chan struct{}
@@ -86,12 +89,11 @@ func NewGasPriceIncremenetor(pullInterval time.Duration, storage Storage, cl Mul
 func (i *GasPriceIncremenetor) Run() {
 	process := func(txs []Transaction) {
 		for _, tx := range txs {
-			switch tx.State {
-			case TxStateFailed, TxStateSucceed:
+			if tx.State.final() {
 				// Force skip transactions that are finalized.
-			default:
-				i.tryWatch(tx)
+				continue
 			}
+			i.tryWatch(tx)
 		}
 	}
 
@@ -119,6 +121,14 @@ func (i *GasPriceIncremenetor) AttachLogFunc(logFn LogFunc) {
 	i.logFn = logFn
 }
 
+// AttachProgressFunc attaches a func receiving the progress events of the watched transactions.
+// Events are only emitted for transactions inserted with a progress id, see TransactionHandler.
+//
+// This method is not thread safe and should be called before Run.
+func (i *GasPriceIncremenetor) AttachProgressFunc(fn ProgressFunc) {
+	i.progressFn = fn
+}
+
 // Stop stops the execution of GasPriceIncrementer thread created by the Run method.
 func (i *GasPriceIncremenetor) Stop() {
 	i.once.Do(func() {
@@ -144,25 +154,36 @@ func (i *GasPriceIncremenetor) InsertInitial(tx *types.Transaction, opts Transac
 // tryWatch will try to watch a transaction.
 // If a transaction is already being watched, it will get skipped.
 func (i *GasPriceIncremenetor) tryWatch(tx Transaction) {
-	if i.syncer.txBeingWatched(tx) {
-		// Already watching
-		return
-	}
 	if err := tx.Opts.validate(); err != nil {
 		i.log(tx, fmt.Errorf("can't increment gas price, got wrong tx opts: %w", err))
 		return
 	}
 
-	i.syncer.txMarkBeingWatched(tx)
+	w, ok := i.syncer.txMarkBeingWatched(tx)
+	if !ok {
+		// Already watching or being replaced
+		return
+	}
 	go func() {
-		defer i.syncer.txRemoveWatched(tx)
-		if err := i.watchAndIncrement(tx); err != nil {
+		defer i.syncer.txRemoveWatched(tx.UniqueID, w)
+
+		// the transaction might have been replaced since it was listed
+		current, err := i.pendingByID(tx.UniqueID)
+		if errors.Is(err, ErrTransactionNotPending) {
+			return
+		}
+		if err != nil {
 			i.log(tx, err)
+			return
+		}
+
+		if err := i.watchAndIncrement(current, w.stop); err != nil {
+			i.log(current, err)
 		}
 	}()
 }
 
-func (i *GasPriceIncremenetor) watchAndIncrement(tx Transaction) error {
+func (i *GasPriceIncremenetor) watchAndIncrement(tx Transaction, stop <-chan struct{}) error {
 	timeout := time.After(tx.Opts.Timeout)
 	incTimer := time.NewTicker(tx.Opts.IncreaseInterval)
 	defer incTimer.Stop()
@@ -170,25 +191,43 @@ func (i *GasPriceIncremenetor) watchAndIncrement(tx Transaction) error {
 	checkTimer := time.NewTicker(tx.Opts.CheckInterval)
 	defer checkTimer.Stop()
 
+	var mined uint64
 	for {
 		select {
 		case <-i.stop:
 			return nil
+		case <-stop:
+			return nil
 		case <-checkTimer.C:
 			receipt, err := i.getReceipt(tx)
 			if err != nil {
 				return err
 			}
-			if receipt.Status == types.ReceiptStatusSuccessful {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				continue
+			}
+
+			confirmations := i.confirmations(tx, receipt)
+			if confirmations > mined {
+				mined = confirmations
+				i.progress(tx, tx.TxHashHex, StageMined, confirmations, "")
+			}
+			if confirmations >= tx.Opts.Confirmations {
+				i.progress(tx, tx.TxHashHex, StageFinalized, confirmations, "")
 				return i.transactionSuccess(tx)
 			}
 		case <-incTimer.C:
+			if mined > 0 {
+				// waiting for confirmations, the transaction must not be replaced anymore
+				continue
+			}
 			newTx, err := i.increaseGasPrice(tx)
 			if err != nil {
 				return err
 			}
 			tx = newTx
 		case <-timeout:
+			i.progress(tx, tx.TxHashHex, StageFailed, 0, "timed out")
 			return i.transactionFailed(tx)
 		}
 	}
@@ -206,6 +245,7 @@ func (i *GasPriceIncremenetor) increaseGasPrice(tx Transaction) (Transaction, er
 	).Int(nil)
 
 	if newGasPrice.Cmp(tx.Opts.MaxPrice) > 0 {
+		i.progress(tx, tx.TxHashHex, StageFailed, 0, "gas price limit reached")
 		if err := i.transactionFailed(tx); err != nil {
 			return Transaction{}, err
 		}
@@ -214,11 +254,13 @@ func (i *GasPriceIncremenetor) increaseGasPrice(tx Transaction) (Transaction, er
 	}
 
 	newTx := tx.rebuiledWithNewGasPrice(org, newGasPrice)
-	if err := i.signAndSend(newTx, tx.ChainID); err != nil {
+	signedTx, err := i.signAndSend(newTx, tx.ChainID)
+	if err != nil {
 		return Transaction{}, err
 	}
+	i.progress(tx, signedTx.Hash().Hex(), StageBroadcast, 0, "")
 
-	return i.transactionPriceIncreased(tx, newTx)
+	return i.transactionPriceIncreased(tx, signedTx)
 }
 
 func (i *GasPriceIncremenetor) getReceipt(tx Transaction) (*types.Receipt, error) {
@@ -229,17 +271,48 @@ func (i *GasPriceIncremenetor) getReceipt(tx Transaction) (*types.Receipt, error
 	return receipt, nil
 }
 
-func (i *GasPriceIncremenetor) signAndSend(tx *types.Transaction, chainID int64) error {
+func (i *GasPriceIncremenetor) signAndSend(tx *types.Transaction, chainID int64) (*types.Transaction, error) {
 	signedTx, err := i.sign(tx, chainID)
 	if err != nil {
-		return fmt.Errorf("failed to sign a transaction: %w", err)
+		return nil, fmt.Errorf("failed to sign a transaction: %w", err)
 	}
 
 	if err := i.bc.SendTransaction(chainID, signedTx); err != nil {
-		return fmt.Errorf("failed send a transaction: %w", err)
+		return nil, fmt.Errorf("failed send a transaction: %w", err)
 	}
 
-	return nil
+	return signedTx, nil
+}
+
+type headerClient interface {
+	HeaderByNumber(chainID int64, number *big.Int) (*types.Header, error)
+}
+
+// confirmations returns the confirmation count of the mined transaction.
+// Without a header client or a block number in the receipt, a mined transaction counts as confirmed once.
+func (i *GasPriceIncremenetor) confirmations(tx Transaction, receipt *types.Receipt) uint64 {
+	hc, ok := i.bc.(headerClient)
+	if !ok || receipt.BlockNumber == nil || tx.Opts.Confirmations <= 1 {
+		return 1
+	}
+
+	head, err := hc.HeaderByNumber(tx.ChainID, nil)
+	if err != nil || head.Number.Cmp(receipt.BlockNumber) < 0 {
+		return 1
+	}
+	return new(big.Int).Sub(head.Number, receipt.BlockNumber).Uint64() + 1
+}
+
+// progress emits a progress event of the transaction attempt with the given hash.
+func (i *GasPriceIncremenetor) progress(tx Transaction, txHash string, stage ProgressStage, confirmations uint64, reason string) {
+	emitProgress(i.progressFn, ProgressEvent{
+		ID:            tx.Opts.ProgressID,
+		ChainID:       tx.ChainID,
+		Stage:         stage,
+		TxHash:        txHash,
+		Confirmations: confirmations,
+		Reason:        reason,
+	})
 }
 
 func (i *GasPriceIncremenetor) transactionFailed(tx Transaction) error {
@@ -262,6 +335,7 @@ func (i *GasPriceIncremenetor) transactionSuccess(tx Transaction) error {
 func (i *GasPriceIncremenetor) transactionPriceIncreased(tx Transaction, newTx *types.Transaction) (Transaction, error) {
 	var err error
 	tx.State = TxStatePriceIncreased
+	tx.Bumps++
 	tx.OriginalTx, err = newTx.MarshalJSON()
 	if err != nil {
 		return Transaction{}, fmt.Errorf("failed to marshal internal transaction object: %w", err)
@@ -283,18 +357,31 @@ func (i *GasPriceIncremenetor) log(tx Transaction, err error) {
 // syncer is used to sync Incremeter so that
 // we dont start tracking the same transaction multiple times.
 type syncer struct {
-	txs map[string]struct{}
+	txs map[string]*watch
 	m   sync.Mutex
 }
 
+// watch is a running watcher of a single transaction.
+type watch struct {
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
 func newSyncer() *syncer {
-	return &syncer{txs: make(map[string]struct{})}
+	return &syncer{txs: make(map[string]*watch)}
 }
 
-func (s *syncer) txMarkBeingWatched(tx Transaction) {
+// txMarkBeingWatched marks the transaction watched, it returns false if it already is.
+func (s *syncer) txMarkBeingWatched(tx Transaction) (*watch, bool) {
 	s.m.Lock()
 	defer s.m.Unlock()
-	s.txs[tx.UniqueID] = struct{}{}
+	if _, ok := s.txs[tx.UniqueID]; ok {
+		return nil, false
+	}
+	w := &watch{stop: make(chan struct{}), done: make(chan struct{})}
+	s.txs[tx.UniqueID] = w
+	return w, true
 }
 
 func (s *syncer) txBeingWatched(tx Transaction) bool {
@@ -304,8 +391,30 @@ func (s *syncer) txBeingWatched(tx Transaction) bool {
 	return ok
 }
 
-func (s *syncer) txRemoveWatched(tx Transaction) {
+func (s *syncer) txRemoveWatched(uniqueID string, w *watch) {
 	s.m.Lock()
 	defer s.m.Unlock()
-	delete(s.txs, tx.UniqueID)
+	if s.txs[uniqueID] == w {
+		delete(s.txs, uniqueID)
+	}
+	close(w.done)
+}
+
+// txReserve stops the watcher of the transaction, if any, and waits for it to return.
+// The transaction stays reserved, so no new watcher is started for it until release is called.
+func (s *syncer) txReserve(uniqueID string) (release func()) {
+	for {
+		s.m.Lock()
+		w, ok := s.txs[uniqueID]
+		if !ok {
+			reserved := &watch{stop: make(chan struct{}), done: make(chan struct{})}
+			s.txs[uniqueID] = reserved
+			s.m.Unlock()
+			return func() { s.txRemoveWatched(uniqueID, reserved) }
+		}
+		s.m.Unlock()
+
+		w.once.Do(func() { close(w.stop) })
+		<-w.done
+	}
 }
@@ -140,6 +140,41 @@ func TestGasPriceIncrementor(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, new(big.Int).SetInt64(4), altered.GasPrice(), "gas price should be increased once")
 	})
+	t.Run("progress events until finalized", func(t *testing.T) {
+		org := types.NewTransaction(1, common.HexToAddress("0x1"), big.NewInt(1), 1, big.NewInt(1), []byte{})
+		opts := defaultOpts()
+		opts.ProgressID = "op-1"
+		opts.Confirmations = 3
+		st := &mockStorage{}
+		c := &confirmingClient{mockClient: newClient(big.NewInt(2)), head: 10}
+
+		var m sync.Mutex
+		var events []ProgressEvent
+		sg := signer{}
+		inc := NewGasPriceIncremenetor(time.Millisecond, st, c, sg.SignatureFunc)
+		inc.AttachProgressFunc(func(e ProgressEvent) {
+			m.Lock()
+			defer m.Unlock()
+			events = append(events, e)
+		})
+		go inc.Run()
+		inc.InsertInitial(org, opts, chid)
+		assert.Eventually(t, func() bool {
+			txs, _ := st.GetIncrementorTransactionsToCheck()
+			return len(txs) > 0 && TxStateSucceed == txs[0].State
+		}, time.Second*2, time.Millisecond*10)
+		inc.Stop()
+
+		m.Lock()
+		defer m.Unlock()
+		stages := []ProgressStage{}
+		for _, e := range events {
+			assert.Equal(t, "op-1", e.ID)
+			stages = append(stages, e.Stage)
+		}
+		assert.Equal(t, []ProgressStage{StageBroadcast, StageMined, StageMined, StageMined, StageFinalized}, stages)
+		assert.Equal(t, uint64(3), events[len(events)-1].Confirmations)
+	})
 	t.Run("invalid opts, not inserted", func(t *testing.T) {
 		org := types.NewTransaction(1, common.HexToAddress("0x1"), big.NewInt(1), 1, big.NewInt(1), []byte{})
 
@@ -156,10 +191,18 @@ func Test_syncer(t *testing.T) {
 	s := newSyncer()
 
 	tx := Transaction{UniqueID: "0x0"}
-	s.txMarkBeingWatched(tx)
+	w, ok := s.txMarkBeingWatched(tx)
+	assert.True(t, ok)
 	assert.True(t, s.txBeingWatched(tx), "transaction should be watched")
-	s.txRemoveWatched(tx)
+	_, ok = s.txMarkBeingWatched(tx)
+	assert.False(t, ok, "transaction should not be watched twice")
+	s.txRemoveWatched(tx.UniqueID, w)
 	assert.False(t, s.txBeingWatched(tx), "transaction should no longer be watched")
+
+	release := s.txReserve(tx.UniqueID)
+	assert.True(t, s.txBeingWatched(tx), "reserved transaction should not be watched again")
+	release()
+	assert.False(t, s.txBeingWatched(tx), "transaction should no longer be reserved")
 }
 
 func defaultOpts() TransactionOpts {
@@ -244,3 +287,59 @@ func (s *signer) SignatureFunc(tx *types.Transaction, chainID int64) (*types.Tra
 	s.signed = true
 	return tx, nil
 }
+
+// confirmingClient mines the transaction at block 10 and advances the head by one block on every query.
+type confirmingClient struct {
+	*mockClient
+	m    sync.Mutex
+	head int64
+}
+
+func (c *confirmingClient) TransactionReceipt(chainID int64, hash common.Hash) (*types.Receipt, error) {
+	r, err := c.mockClient.TransactionReceipt(chainID, hash)
+	if r != nil {
+		r.BlockNumber = big.NewInt(10)
+	}
+	return r, err
+}
+
+func (c *confirmingClient) HeaderByNumber(chainID int64, number *big.Int) (*types.Header, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	head := &types.Header{Number: big.NewInt(c.head)}
+	c.head++
+	return head, nil
+}
+
+func TestGasPriceIncrementorListPendingTransactions(t *testing.T) {
+	org := types.NewTransaction(3, common.HexToAddress("0x1"), big.NewInt(1), 21000, big.NewInt(10), []byte{})
+	opts := defaultOpts()
+	opts.RequestType = "settle"
+	st := &mockStorage{}
+	inc := NewGasPriceIncremenetor(time.Millisecond, st, newClient(big.NewInt(0)), (&signer{}).SignatureFunc)
+
+	assert.NoError(t, inc.InsertInitial(org, opts, 5))
+	tx := st.tx
+	tx.CreatedAt = tx.CreatedAt.Add(-time.Minute)
+	_, err := inc.transactionPriceIncreased(tx, tx.rebuiledWithNewGasPrice(org, big.NewInt(20)))
+	assert.NoError(t, err)
+
+	pending, err := inc.ListPendingTransactions()
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	p := pending[0]
+	assert.Equal(t, int64(5), p.ChainID)
+	assert.Equal(t, org.Hash().Hex(), p.TxHash)
+	assert.Equal(t, "settle", p.RequestType)
+	assert.Equal(t, TxStatePriceIncreased, p.State)
+	assert.Equal(t, uint64(3), p.Nonce)
+	assert.Equal(t, uint64(21000), p.Gas)
+	assert.Equal(t, big.NewInt(20), p.GasPrice)
+	assert.Equal(t, 1, p.Bumps)
+	assert.True(t, p.Age >= time.Minute)
+
+	assert.NoError(t, inc.transactionSuccess(st.tx))
+	pending, err = inc.ListPendingTransactions()
+	assert.NoError(t, err)
+	assert.Len(t, pending, 0)
+}
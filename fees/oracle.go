@@ -0,0 +1,161 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fees
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/rs/zerolog/log"
+)
+
+// EWMA is an exponentially weighted moving average of big integers.
+type EWMA struct {
+	alpha float64
+	value *big.Float
+}
+
+// NewEWMA returns a new moving average. Alpha, between 0 and 1, is the weight of a new sample:
+// the smaller it is, the longer a change has to persist before it moves the average.
+func NewEWMA(alpha float64) *EWMA {
+	return &EWMA{alpha: alpha}
+}
+
+// Add adds a sample to the average.
+func (e *EWMA) Add(sample *big.Int) {
+	s := new(big.Float).SetInt(sample)
+	if e.value == nil {
+		e.value = s
+		return
+	}
+
+	// value = value + alpha * (sample - value)
+	delta := new(big.Float).Sub(s, e.value)
+	delta.Mul(delta, big.NewFloat(e.alpha))
+	e.value.Add(e.value, delta)
+}
+
+// Value returns the current average, or nil if no samples were added yet.
+func (e *EWMA) Value() *big.Int {
+	if e.value == nil {
+		return nil
+	}
+	res, _ := e.value.Int(nil)
+	return res
+}
+
+type feeHistoryClient interface {
+	FeeHistory(blockCount uint64, newest *big.Int, percentiles []float64) (*client.FeeHistory, error)
+	SuggestGasPrice() (*big.Int, error)
+}
+
+// GasOracle suggests a gas price smoothed over the recent blocks,
+// so that callers react to sustained gas price trends rather than to single block spikes.
+// Each block contributes its base fee plus the priority fee at the configured percentile.
+// On chains without eth_feeHistory the node suggested gas price is sampled instead.
+type GasOracle struct {
+	client     feeHistoryClient
+	blocks     uint64
+	percentile float64
+
+	lock      sync.Mutex
+	ewma      *EWMA
+	latest    *big.Int
+	nextBlock *big.Int
+}
+
+// NewGasOracle returns a new gas oracle that reads up to blocks blocks of fee history per update.
+func NewGasOracle(client feeHistoryClient, blocks uint64, percentile, alpha float64) *GasOracle {
+	return &GasOracle{
+		client:     client,
+		blocks:     blocks,
+		percentile: percentile,
+		ewma:       NewEWMA(alpha),
+	}
+}
+
+// Update feeds the blocks mined since the previous update into the moving average.
+func (o *GasOracle) Update() error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	fh, err := o.client.FeeHistory(o.blocks, nil, []float64{o.percentile})
+	if err != nil {
+		log.Debug().Err(err).Msg("fee history unavailable, sampling the suggested gas price")
+		return o.sampleSuggested()
+	}
+
+	prices := fh.GasPrices(0)
+	if len(prices) == 0 {
+		return o.sampleSuggested()
+	}
+	for i, price := range prices {
+		block := new(big.Int).Add(fh.OldestBlock, big.NewInt(int64(i)))
+		if o.nextBlock != nil && block.Cmp(o.nextBlock) < 0 {
+			continue
+		}
+		o.add(price)
+	}
+	o.nextBlock = new(big.Int).Add(fh.OldestBlock, big.NewInt(int64(len(prices))))
+	return nil
+}
+
+func (o *GasOracle) sampleSuggested() error {
+	price, err := o.client.SuggestGasPrice()
+	if err != nil {
+		return err
+	}
+	o.add(price)
+	return nil
+}
+
+func (o *GasOracle) add(price *big.Int) {
+	o.ewma.Add(price)
+	o.latest = price
+}
+
+// SuggestGasPrice updates the oracle and returns the smoothed gas price.
+// If the update fails the last smoothed price is returned, as long as there is one.
+func (o *GasOracle) SuggestGasPrice() (*big.Int, error) {
+	err := o.Update()
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	price := o.ewma.Value()
+	if price == nil {
+		if err == nil {
+			err = errors.New("no gas price samples")
+		}
+		return nil, err
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("could not update gas oracle, using the last smoothed price")
+	}
+	return price, nil
+}
+
+// Latest returns the price of the most recent sample, or nil if there are no samples.
+func (o *GasOracle) Latest() *big.Int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.latest == nil {
+		return nil
+	}
+	return new(big.Int).Set(o.latest)
+}
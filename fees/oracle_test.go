@@ -0,0 +1,99 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fees
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockFeeHistory struct {
+	history    *client.FeeHistory
+	historyErr error
+	suggested  *big.Int
+}
+
+func (m *mockFeeHistory) FeeHistory(blockCount uint64, newest *big.Int, percentiles []float64) (*client.FeeHistory, error) {
+	return m.history, m.historyErr
+}
+
+func (m *mockFeeHistory) SuggestGasPrice() (*big.Int, error) {
+	if m.suggested == nil {
+		return nil, errors.New("unavailable")
+	}
+	return m.suggested, nil
+}
+
+func TestEWMA(t *testing.T) {
+	e := NewEWMA(0.5)
+	assert.Nil(t, e.Value())
+
+	e.Add(big.NewInt(100))
+	assert.Equal(t, big.NewInt(100), e.Value())
+	e.Add(big.NewInt(200))
+	assert.Equal(t, big.NewInt(150), e.Value())
+	e.Add(big.NewInt(150))
+	assert.Equal(t, big.NewInt(150), e.Value())
+}
+
+func TestGasOracleSmoothsSpikes(t *testing.T) {
+	m := &mockFeeHistory{history: &client.FeeHistory{
+		OldestBlock:  big.NewInt(1),
+		BaseFee:      []*big.Int{big.NewInt(100), big.NewInt(100), big.NewInt(100)},
+		GasUsedRatio: []float64{0.5, 0.5},
+		Reward:       [][]*big.Int{{big.NewInt(0)}, {big.NewInt(0)}},
+	}}
+	o := NewGasOracle(m, 2, 50, 0.2)
+
+	price, err := o.SuggestGasPrice()
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(100), price)
+
+	// only the new block is sampled
+	m.history = &client.FeeHistory{
+		OldestBlock:  big.NewInt(2),
+		BaseFee:      []*big.Int{big.NewInt(100), big.NewInt(600), big.NewInt(600)},
+		GasUsedRatio: []float64{0.5, 1},
+		Reward:       [][]*big.Int{{big.NewInt(0)}, {big.NewInt(0)}},
+	}
+	price, err = o.SuggestGasPrice()
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(200), price)
+	assert.Equal(t, big.NewInt(600), o.Latest())
+}
+
+func TestGasOracleFallsBackToSuggestedPrice(t *testing.T) {
+	m := &mockFeeHistory{historyErr: client.ErrFeeHistoryUnsupported, suggested: big.NewInt(50)}
+	o := NewGasOracle(m, 10, 50, 0.5)
+
+	price, err := o.SuggestGasPrice()
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(50), price)
+
+	// the last smoothed price is used while the node is unavailable
+	m.suggested = nil
+	price, err = o.SuggestGasPrice()
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(50), price)
+
+	_, err = NewGasOracle(m, 10, 50, 0.5).SuggestGasPrice()
+	assert.Error(t, err)
+}
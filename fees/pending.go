@@ -0,0 +1,82 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fees
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// PendingTransaction describes a transaction that was broadcast but is not confirmed yet.
+type PendingTransaction struct {
+	ChainID int64
+	// TxHash is the hash of the first broadcast transaction, gas price bumps replace it under the same id.
+	TxHash      string
+	RequestType string
+	State       TransactionState
+	Nonce       uint64
+	Gas         uint64
+	// GasPrice is the gas price of the latest broadcast attempt.
+	GasPrice *big.Int
+	// Age is the time since the transaction was first inserted. It is zero if the creation time is unknown.
+	Age   time.Duration
+	Bumps int
+}
+
+// ListPendingTransactions returns all the transactions the incrementor watches that are not finalized yet,
+// the oldest first, so stuck transactions can be inspected.
+func (i *GasPriceIncremenetor) ListPendingTransactions() ([]PendingTransaction, error) {
+	txs, err := i.storage.GetIncrementorTransactionsToCheck()
+	if err != nil {
+		return nil, fmt.Errorf("could not get transactions: %w", err)
+	}
+
+	now := time.Now()
+	res := make([]PendingTransaction, 0, len(txs))
+	for _, tx := range txs {
+		if tx.State.final() {
+			continue
+		}
+
+		org, err := tx.getOriginal()
+		if err != nil {
+			return nil, fmt.Errorf("could not decode transaction %v: %w", tx.UniqueID, err)
+		}
+
+		p := PendingTransaction{
+			ChainID:     tx.ChainID,
+			TxHash:      tx.TxHashHex,
+			RequestType: tx.Opts.RequestType,
+			State:       tx.State,
+			Nonce:       org.Nonce(),
+			Gas:         org.Gas(),
+			GasPrice:    org.GasPrice(),
+			Bumps:       tx.Bumps,
+		}
+		if !tx.CreatedAt.IsZero() {
+			p.Age = now.Sub(tx.CreatedAt)
+		}
+		res = append(res, p)
+	}
+
+	sort.SliceStable(res, func(a, b int) bool {
+		return res[a].Age > res[b].Age
+	})
+	return res, nil
+}
@@ -0,0 +1,85 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fees
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/mysteriumnetwork/payments/bus"
+)
+
+// TopicTxProgress is the bus topic progress events are published to, see PublishProgress.
+const TopicTxProgress bus.Topic = "fees.tx_progress"
+
+// ProgressStage is the stage of a submitted transaction, meant to be mapped directly to wallet UI states.
+type ProgressStage string
+
+const (
+	// StageQueued is emitted before the transaction is sent for the first time.
+	StageQueued ProgressStage = "queued"
+	// StageBroadcast is emitted every time a transaction, or its gas bumped replacement, is sent.
+	StageBroadcast ProgressStage = "broadcast"
+	// StageMined is emitted once the transaction is mined and every time its confirmation count grows.
+	StageMined ProgressStage = "mined"
+	// StageFinalized is emitted once the transaction has the required number of confirmations.
+	StageFinalized ProgressStage = "finalized"
+	// StageFailed is emitted when the transaction will not be retried anymore.
+	StageFailed ProgressStage = "failed"
+)
+
+// ProgressEvent reports the progress of a submitted transaction.
+// The ID stays the same across retries and gas bumps, while TxHash is the hash of the latest attempt.
+type ProgressEvent struct {
+	ID            string
+	ChainID       int64
+	Stage         ProgressStage
+	TxHash        string
+	Confirmations uint64
+	Reason        string
+	Time          time.Time
+}
+
+// Topic implements bus.Event.
+func (ProgressEvent) Topic() bus.Topic {
+	return TopicTxProgress
+}
+
+// ProgressFunc receives the progress events. It must not block.
+type ProgressFunc func(ProgressEvent)
+
+// PublishProgress returns a progress func publishing the events to the given bus.
+func PublishProgress(b *bus.Bus) ProgressFunc {
+	return func(e ProgressEvent) {
+		b.Publish(e)
+	}
+}
+
+func newProgressID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func emitProgress(f ProgressFunc, e ProgressEvent) {
+	if f == nil || e.ID == "" {
+		return
+	}
+	e.Time = time.Now().UTC()
+	f(e)
+}
@@ -0,0 +1,169 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fees
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// cancelGasLimit is the gas limit of the self transfer cancelling a transaction.
+const cancelGasLimit = 21000
+
+// minReplacementBump is the minimal gas price increase, in percent, nodes accept for a replacement transaction.
+const minReplacementBump = 10
+
+var (
+	// ErrTransactionNotPending is returned when cancelling or replacing a transaction that is not watched or already finalized.
+	ErrTransactionNotPending = errors.New("transaction is not pending")
+	// ErrInvalidReplacement is returned when the replacement transaction does not reuse the nonce or underprices the original.
+	ErrInvalidReplacement = errors.New("invalid replacement transaction")
+)
+
+// ReplacementTransaction builds the signed transaction replacing a stuck one.
+// It has to use the given nonce and at least the given gas price.
+type ReplacementTransaction func(nonce uint64, minGasPrice *big.Int) (*types.Transaction, error)
+
+// CancelTransaction cancels a pending transaction by sending a zero value self transfer
+// with the same nonce and a higher gas price. The hash of any broadcast attempt of the transaction can be given.
+//
+// The cancelled transaction is marked replaced and the self transfer is watched instead, with the same opts.
+func (i *GasPriceIncremenetor) CancelTransaction(chainID int64, txHash common.Hash) (*types.Transaction, error) {
+	return i.replace(chainID, txHash, func(org *types.Transaction, gasPrice *big.Int) (*types.Transaction, error) {
+		from, err := types.Sender(types.NewEIP155Signer(big.NewInt(chainID)), org)
+		if err != nil {
+			return nil, fmt.Errorf("could not recover the sender of the transaction: %w", err)
+		}
+
+		return i.sign(types.NewTransaction(org.Nonce(), from, big.NewInt(0), cancelGasLimit, gasPrice, nil), chainID)
+	})
+}
+
+// ReplaceTransaction replaces a pending transaction with the one built by the given func,
+// e.g. to re-submit a request with corrected parameters. The hash of any broadcast attempt of the transaction can be given.
+//
+// The replaced transaction is marked replaced and the new one is watched instead, with the same opts.
+func (i *GasPriceIncremenetor) ReplaceTransaction(chainID int64, txHash common.Hash, build ReplacementTransaction) (*types.Transaction, error) {
+	return i.replace(chainID, txHash, func(org *types.Transaction, gasPrice *big.Int) (*types.Transaction, error) {
+		return build(org.Nonce(), gasPrice)
+	})
+}
+
+func (i *GasPriceIncremenetor) replace(chainID int64, txHash common.Hash, build func(org *types.Transaction, gasPrice *big.Int) (*types.Transaction, error)) (*types.Transaction, error) {
+	found, err := i.findPending(chainID, txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// the watcher must not bump the transaction while it is being replaced, nor be restarted
+	// until the replaced state is stored. It might have bumped the transaction before stopping, so it is read again.
+	release := i.syncer.txReserve(found.UniqueID)
+	defer release()
+
+	tx, err := i.pendingByID(found.UniqueID)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := tx.getOriginal()
+	if err != nil {
+		return nil, fmt.Errorf("could not decode transaction: %w", err)
+	}
+
+	gasPrice := replacementGasPrice(org.GasPrice(), tx.Opts.PriceMultiplier)
+	replacement, err := build(org, gasPrice)
+	if err != nil {
+		return nil, fmt.Errorf("could not build replacement transaction: %w", err)
+	}
+	if replacement.Nonce() != org.Nonce() {
+		return nil, fmt.Errorf("%w: nonce %v, expected %v", ErrInvalidReplacement, replacement.Nonce(), org.Nonce())
+	}
+	if replacement.GasPrice().Cmp(gasPrice) < 0 {
+		return nil, fmt.Errorf("%w: gas price %v, expected at least %v", ErrInvalidReplacement, replacement.GasPrice(), gasPrice)
+	}
+
+	if err := i.bc.SendTransaction(chainID, replacement); err != nil {
+		return nil, fmt.Errorf("failed send a transaction: %w", err)
+	}
+	i.progress(tx, replacement.Hash().Hex(), StageBroadcast, 0, "")
+
+	tx.State = TxStateReplaced
+	if err := i.storage.UpsertIncrementorTransaction(tx); err != nil {
+		return nil, fmt.Errorf("failed marking transaction as replaced: %w", err)
+	}
+
+	if err := i.InsertInitial(replacement, tx.Opts, chainID); err != nil {
+		return nil, fmt.Errorf("failed to insert replacement transaction: %w", err)
+	}
+	return replacement, nil
+}
+
+// findPending returns the pending transaction with the given first or latest broadcast hash.
+func (i *GasPriceIncremenetor) findPending(chainID int64, txHash common.Hash) (Transaction, error) {
+	txs, err := i.storage.GetIncrementorTransactionsToCheck()
+	if err != nil {
+		return Transaction{}, fmt.Errorf("could not get transactions: %w", err)
+	}
+
+	for _, tx := range txs {
+		if tx.ChainID != chainID || tx.State.final() {
+			continue
+		}
+		if common.HexToHash(tx.TxHashHex) == txHash {
+			return tx, nil
+		}
+		if org, err := tx.getOriginal(); err == nil && org.Hash() == txHash {
+			return tx, nil
+		}
+	}
+
+	return Transaction{}, fmt.Errorf("%w: %v on chain %v", ErrTransactionNotPending, txHash.Hex(), chainID)
+}
+
+// pendingByID returns the pending transaction with the given unique id.
+func (i *GasPriceIncremenetor) pendingByID(uniqueID string) (Transaction, error) {
+	txs, err := i.storage.GetIncrementorTransactionsToCheck()
+	if err != nil {
+		return Transaction{}, fmt.Errorf("could not get transactions: %w", err)
+	}
+
+	for _, tx := range txs {
+		if tx.UniqueID == uniqueID && !tx.State.final() {
+			return tx, nil
+		}
+	}
+
+	return Transaction{}, fmt.Errorf("%w: %v", ErrTransactionNotPending, uniqueID)
+}
+
+// replacementGasPrice returns the gas price of a transaction replacing one with the given gas price.
+// It is increased by the multiplier, but at least by the minimal bump nodes accept.
+func replacementGasPrice(gasPrice *big.Int, multiplier float64) *big.Int {
+	min := new(big.Int).Mul(gasPrice, big.NewInt(100+minReplacementBump))
+	min.Add(min, big.NewInt(99))
+	min.Div(min, big.NewInt(100))
+
+	bumped, _ := new(big.Float).Mul(big.NewFloat(multiplier), new(big.Float).SetInt(gasPrice)).Int(nil)
+	if bumped.Cmp(min) < 0 {
+		return min
+	}
+	return bumped
+}
@@ -0,0 +1,193 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fees
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGasPriceIncrementorCancelTransaction(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	sign := func(tx *types.Transaction, chainID int64) (*types.Transaction, error) {
+		return types.SignTx(tx, types.NewEIP155Signer(big.NewInt(chainID)), key)
+	}
+
+	org, err := sign(types.NewTransaction(7, common.HexToAddress("0x1"), big.NewInt(1), 100000, big.NewInt(10), []byte{1}), 5)
+	assert.NoError(t, err)
+
+	st := &mockStorage{}
+	c := newClient(big.NewInt(100))
+	inc := NewGasPriceIncremenetor(time.Millisecond, st, c, sign)
+	assert.NoError(t, inc.InsertInitial(org, defaultOpts(), 5))
+
+	_, err = inc.CancelTransaction(1, org.Hash())
+	assert.True(t, errors.Is(err, ErrTransactionNotPending))
+
+	cancel, err := inc.CancelTransaction(5, org.Hash())
+	assert.NoError(t, err)
+	assert.True(t, c.sent)
+	assert.Equal(t, uint64(7), cancel.Nonce())
+	assert.Equal(t, from, *cancel.To())
+	assert.Equal(t, big.NewInt(0), cancel.Value())
+	assert.Equal(t, big.NewInt(20), cancel.GasPrice())
+	assert.Equal(t, []TransactionState{TxStateCreated, TxStateReplaced, TxStateCreated}, st.stateHistory)
+	assert.Equal(t, cancel.Hash().Hex(), st.tx.TxHashHex)
+}
+
+func TestGasPriceIncrementorReplaceTransaction(t *testing.T) {
+	org := types.NewTransaction(7, common.HexToAddress("0x1"), big.NewInt(1), 100000, big.NewInt(10), []byte{1})
+	opts := defaultOpts()
+	opts.PriceMultiplier = 1.05
+
+	st := &mockStorage{}
+	c := newClient(big.NewInt(100))
+	inc := NewGasPriceIncremenetor(time.Millisecond, st, c, (&signer{}).SignatureFunc)
+	assert.NoError(t, inc.InsertInitial(org, opts, 5))
+
+	_, err := inc.ReplaceTransaction(5, org.Hash(), func(nonce uint64, minGasPrice *big.Int) (*types.Transaction, error) {
+		return types.NewTransaction(nonce+1, common.HexToAddress("0x1"), big.NewInt(2), 100000, minGasPrice, []byte{2}), nil
+	})
+	assert.True(t, errors.Is(err, ErrInvalidReplacement))
+	assert.False(t, c.sent)
+
+	replacement, err := inc.ReplaceTransaction(5, org.Hash(), func(nonce uint64, minGasPrice *big.Int) (*types.Transaction, error) {
+		// the minimal bump nodes accept is larger than the multiplier
+		assert.Equal(t, big.NewInt(11), minGasPrice)
+		return types.NewTransaction(nonce, common.HexToAddress("0x1"), big.NewInt(2), 100000, minGasPrice, []byte{2}), nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, c.sent)
+	assert.Equal(t, []TransactionState{TxStateCreated, TxStateReplaced, TxStateCreated}, st.stateHistory)
+	assert.Equal(t, replacement.Hash().Hex(), st.tx.TxHashHex)
+	assert.Equal(t, opts, st.tx.Opts)
+}
+
+// mapStorage keeps every transaction and the states each of them went through.
+type mapStorage struct {
+	m       sync.Mutex
+	txs     map[string]Transaction
+	history map[string][]TransactionState
+}
+
+func (s *mapStorage) UpsertIncrementorTransaction(tx Transaction) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.txs[tx.UniqueID] = tx
+	s.history[tx.UniqueID] = append(s.history[tx.UniqueID], tx.State)
+	return nil
+}
+
+func (s *mapStorage) GetIncrementorTransactionsToCheck() ([]Transaction, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	var res []Transaction
+	for _, tx := range s.txs {
+		if !tx.State.final() {
+			res = append(res, tx)
+		}
+	}
+	return res, nil
+}
+
+// pendingClient never mines anything and records the sent transactions.
+type pendingClient struct {
+	m    sync.Mutex
+	sent []*types.Transaction
+}
+
+func (c *pendingClient) TransactionReceipt(chainID int64, hash common.Hash) (*types.Receipt, error) {
+	return &types.Receipt{Status: types.ReceiptStatusFailed}, nil
+}
+
+func (c *pendingClient) SendTransaction(chainID int64, tx *types.Transaction) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.sent = append(c.sent, tx)
+	return nil
+}
+
+func TestGasPriceIncrementorReplaceWhileRunning(t *testing.T) {
+	org := types.NewTransaction(7, common.HexToAddress("0x1"), big.NewInt(1), 100000, big.NewInt(1), []byte{1})
+	opts := defaultOpts()
+	opts.MaxPrice = big.NewInt(1000000)
+	opts.IncreaseInterval = time.Millisecond
+	opts.CheckInterval = time.Millisecond
+
+	st := &mapStorage{txs: make(map[string]Transaction), history: make(map[string][]TransactionState)}
+	c := &pendingClient{}
+	inc := NewGasPriceIncremenetor(time.Millisecond, st, c, (&signer{}).SignatureFunc)
+	assert.NoError(t, inc.InsertInitial(org, opts, 5))
+
+	go inc.Run()
+	defer inc.Stop()
+
+	// let the watcher bump the transaction a few times
+	time.Sleep(20 * time.Millisecond)
+
+	replacement, err := inc.ReplaceTransaction(5, org.Hash(), func(nonce uint64, minGasPrice *big.Int) (*types.Transaction, error) {
+		return types.NewTransaction(nonce, common.HexToAddress("0x2"), big.NewInt(0), 21000, minGasPrice, nil), nil
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c.m.Lock()
+	var lastBump *types.Transaction
+	for _, tx := range c.sent {
+		if tx.Hash() != replacement.Hash() && tx.To() != nil && *tx.To() == *org.To() {
+			lastBump = tx
+		}
+	}
+	c.m.Unlock()
+	// the replacement outbids the latest bump, not the stale transaction
+	if lastBump != nil {
+		assert.True(t, replacement.GasPrice().Cmp(lastBump.GasPrice()) > 0)
+	}
+
+	// the original is neither bumped nor re-watched after being replaced
+	time.Sleep(20 * time.Millisecond)
+	st.m.Lock()
+	orgHistory := st.history[TransactionUniqueID(org.Hash().Hex(), 5)]
+	st.m.Unlock()
+	if assert.NotEmpty(t, orgHistory) {
+		assert.Equal(t, TxStateReplaced, orgHistory[len(orgHistory)-1])
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	var afterReplacement bool
+	for _, tx := range c.sent {
+		if tx.Hash() == replacement.Hash() {
+			afterReplacement = true
+			continue
+		}
+		if afterReplacement {
+			assert.NotEqual(t, *org.To(), *tx.To(), "the original was bumped after the replacement")
+		}
+	}
+}
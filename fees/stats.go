@@ -0,0 +1,265 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fees
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+)
+
+// Windows over which gas price statistics are usually queried.
+const (
+	WindowHour = time.Hour
+	WindowDay  = 24 * time.Hour
+	WindowWeek = 7 * 24 * time.Hour
+)
+
+// ErrNoGasSamples is returned when there are no recorded samples in the queried window.
+var ErrNoGasSamples = errors.New("no gas samples in the window")
+
+// GasSample is the fee observed in a single block.
+type GasSample struct {
+	ChainID     int64
+	BlockNumber uint64
+	Time        time.Time
+	BaseFee     *big.Int
+	// PriorityFee is the priority fee at the percentile configured in the recorder.
+	PriorityFee *big.Int
+}
+
+// GasPrice returns the gas price paid in the block, the base fee plus the priority fee.
+func (gs GasSample) GasPrice() *big.Int {
+	res := new(big.Int)
+	if gs.BaseFee != nil {
+		res.Add(res, gs.BaseFee)
+	}
+	if gs.PriorityFee != nil {
+		res.Add(res, gs.PriorityFee)
+	}
+	return res
+}
+
+// GasStatsStorage stores the observed gas samples.
+type GasStatsStorage interface {
+	// InsertGasSamples stores the samples. Samples of blocks that are already stored are replaced.
+	InsertGasSamples(samples []GasSample) error
+	// GetGasSamples returns the samples of the chain in the [from, to) time window.
+	GetGasSamples(chainID int64, from, to time.Time) ([]GasSample, error)
+}
+
+type gasStatsClient interface {
+	FeeHistory(blockCount uint64, newest *big.Int, percentiles []float64) (*client.FeeHistory, error)
+	HeaderByNumber(number *big.Int) (*types.Header, error)
+}
+
+// GasStatsRecorder records the fees of the mined blocks of a single chain.
+type GasStatsRecorder struct {
+	chainID    int64
+	client     gasStatsClient
+	storage    GasStatsStorage
+	blocks     uint64
+	percentile float64
+
+	nextBlock *big.Int
+}
+
+// NewGasStatsRecorder returns a new recorder reading up to blocks blocks of fee history per call to Record.
+func NewGasStatsRecorder(chainID int64, client gasStatsClient, storage GasStatsStorage, blocks uint64, percentile float64) *GasStatsRecorder {
+	return &GasStatsRecorder{
+		chainID:    chainID,
+		client:     client,
+		storage:    storage,
+		blocks:     blocks,
+		percentile: percentile,
+	}
+}
+
+// Record stores the samples of the blocks mined since the previous call.
+// Only the first and last block headers are read, the time of the blocks in between is interpolated.
+func (r *GasStatsRecorder) Record() (int, error) {
+	fh, err := r.client.FeeHistory(r.blocks, nil, []float64{r.percentile})
+	if err != nil {
+		return 0, err
+	}
+	if fh.Blocks() == 0 {
+		return 0, nil
+	}
+
+	first, err := r.client.HeaderByNumber(fh.OldestBlock)
+	if err != nil {
+		return 0, err
+	}
+	newest := new(big.Int).Add(fh.OldestBlock, big.NewInt(int64(fh.Blocks()-1)))
+	last, err := r.client.HeaderByNumber(newest)
+	if err != nil {
+		return 0, err
+	}
+
+	rewards := fh.Rewards(0)
+	samples := make([]GasSample, 0, fh.Blocks())
+	for i := 0; i < fh.Blocks() && i < len(fh.BaseFee); i++ {
+		block := new(big.Int).Add(fh.OldestBlock, big.NewInt(int64(i)))
+		if r.nextBlock != nil && block.Cmp(r.nextBlock) < 0 {
+			continue
+		}
+		s := GasSample{
+			ChainID:     r.chainID,
+			BlockNumber: block.Uint64(),
+			Time:        interpolateTime(first.Time, last.Time, i, fh.Blocks()),
+			BaseFee:     new(big.Int).Set(fh.BaseFee[i]),
+			PriorityFee: new(big.Int),
+		}
+		if i < len(rewards) {
+			s.PriorityFee.Set(rewards[i])
+		}
+		samples = append(samples, s)
+	}
+	if len(samples) > 0 {
+		if err := r.storage.InsertGasSamples(samples); err != nil {
+			return 0, err
+		}
+	}
+
+	r.nextBlock = new(big.Int).Add(newest, big.NewInt(1))
+	return len(samples), nil
+}
+
+func interpolateTime(first, last uint64, i, n int) time.Time {
+	ts := first
+	if n > 1 && last > first {
+		ts += (last - first) * uint64(i) / uint64(n-1)
+	}
+	return time.Unix(int64(ts), 0).UTC()
+}
+
+// HourStats is the gas price percentile of a single hour of the day.
+type HourStats struct {
+	// Hour of the day in UTC.
+	Hour     int
+	GasPrice *big.Int
+	Samples  int
+}
+
+// GasStats answers percentile queries over the recorded gas samples.
+type GasStats struct {
+	storage GasStatsStorage
+	now     func() time.Time
+}
+
+// NewGasStats returns a new gas statistics reader.
+func NewGasStats(storage GasStatsStorage) *GasStats {
+	return &GasStats{storage: storage, now: time.Now}
+}
+
+// Percentile returns the gas price at the given percentile, from 0 to 100, over the window ending now.
+func (gs *GasStats) Percentile(chainID int64, window time.Duration, percentile float64) (*big.Int, error) {
+	samples, err := gs.samples(chainID, window)
+	if err != nil {
+		return nil, err
+	}
+	prices := make([]*big.Int, len(samples))
+	for i := range samples {
+		prices[i] = samples[i].GasPrice()
+	}
+	return client.Percentile(prices, percentile), nil
+}
+
+// BaseFeePercentile returns the base fee at the given percentile over the window ending now.
+func (gs *GasStats) BaseFeePercentile(chainID int64, window time.Duration, percentile float64) (*big.Int, error) {
+	samples, err := gs.samples(chainID, window)
+	if err != nil {
+		return nil, err
+	}
+	fees := make([]*big.Int, len(samples))
+	for i := range samples {
+		fees[i] = samples[i].BaseFee
+	}
+	return client.Percentile(fees, percentile), nil
+}
+
+// HourlyStats groups the samples of the window ending now by the hour of the day
+// and returns the gas price percentile of every hour that has samples, cheapest first.
+func (gs *GasStats) HourlyStats(chainID int64, window time.Duration, percentile float64) ([]HourStats, error) {
+	samples, err := gs.samples(chainID, window)
+	if err != nil {
+		return nil, err
+	}
+
+	byHour := make(map[int][]*big.Int)
+	for _, s := range samples {
+		h := s.Time.UTC().Hour()
+		byHour[h] = append(byHour[h], s.GasPrice())
+	}
+
+	res := make([]HourStats, 0, len(byHour))
+	for h, prices := range byHour {
+		res = append(res, HourStats{Hour: h, GasPrice: client.Percentile(prices, percentile), Samples: len(prices)})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if c := res[i].GasPrice.Cmp(res[j].GasPrice); c != 0 {
+			return c < 0
+		}
+		return res[i].Hour < res[j].Hour
+	})
+	return res, nil
+}
+
+// NextCheapHour returns the start of the next hour that is among the count cheapest hours of the day over the window.
+// If the current hour is one of them, the current time is returned, so a scheduler can act right away.
+func (gs *GasStats) NextCheapHour(chainID int64, window time.Duration, percentile float64, count int) (time.Time, error) {
+	stats, err := gs.HourlyStats(chainID, window, percentile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if count > len(stats) {
+		count = len(stats)
+	}
+	cheap := make(map[int]bool, count)
+	for _, s := range stats[:count] {
+		cheap[s.Hour] = true
+	}
+
+	now := gs.now().UTC()
+	if cheap[now.Hour()] {
+		return now, nil
+	}
+	start := now.Truncate(time.Hour)
+	for i := 1; i <= 24; i++ {
+		next := start.Add(time.Duration(i) * time.Hour)
+		if cheap[next.Hour()] {
+			return next, nil
+		}
+	}
+	return now, nil
+}
+
+func (gs *GasStats) samples(chainID int64, window time.Duration) ([]GasSample, error) {
+	now := gs.now()
+	samples, err := gs.storage.GetGasSamples(chainID, now.Add(-window), now)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, ErrNoGasSamples
+	}
+	return samples, nil
+}
@@ -0,0 +1,124 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fees
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/stretchr/testify/assert"
+)
+
+type memGasSamples []GasSample
+
+func (m *memGasSamples) InsertGasSamples(samples []GasSample) error {
+	*m = append(*m, samples...)
+	return nil
+}
+
+func (m *memGasSamples) GetGasSamples(chainID int64, from, to time.Time) ([]GasSample, error) {
+	var res []GasSample
+	for _, s := range *m {
+		if s.ChainID == chainID && !s.Time.Before(from) && s.Time.Before(to) {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+type mockStatsClient struct {
+	history *client.FeeHistory
+	times   map[uint64]uint64
+}
+
+func (m *mockStatsClient) FeeHistory(blockCount uint64, newest *big.Int, percentiles []float64) (*client.FeeHistory, error) {
+	return m.history, nil
+}
+
+func (m *mockStatsClient) HeaderByNumber(number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: number, Time: m.times[number.Uint64()]}, nil
+}
+
+func TestGasStatsRecorder(t *testing.T) {
+	m := &mockStatsClient{
+		history: &client.FeeHistory{
+			OldestBlock:  big.NewInt(10),
+			BaseFee:      []*big.Int{big.NewInt(100), big.NewInt(110), big.NewInt(120), big.NewInt(130)},
+			GasUsedRatio: []float64{0.5, 0.5, 0.5},
+			Reward:       [][]*big.Int{{big.NewInt(1)}, {big.NewInt(2)}, {big.NewInt(3)}},
+		},
+		times: map[uint64]uint64{10: 1000, 12: 1030},
+	}
+	storage := &memGasSamples{}
+	r := NewGasStatsRecorder(5, m, storage, 3, 50)
+
+	n, err := r.Record()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, uint64(11), (*storage)[1].BlockNumber)
+	assert.Equal(t, int64(1015), (*storage)[1].Time.Unix())
+	assert.Equal(t, big.NewInt(112), (*storage)[1].GasPrice())
+
+	// the same blocks are not recorded twice
+	n, err = r.Record()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Len(t, *storage, 3)
+}
+
+func TestGasStats(t *testing.T) {
+	now := time.Date(2021, 6, 1, 10, 30, 0, 0, time.UTC)
+	storage := &memGasSamples{}
+	for h := 0; h < 24; h++ {
+		price := int64(100)
+		if h == 3 || h == 4 {
+			price = 10
+		}
+		at := time.Date(2021, 5, 31, h, 0, 0, 0, time.UTC)
+		assert.NoError(t, storage.InsertGasSamples([]GasSample{
+			{ChainID: 1, BlockNumber: uint64(h), Time: at, BaseFee: big.NewInt(price), PriorityFee: big.NewInt(1)},
+		}))
+	}
+
+	stats := NewGasStats(storage)
+	stats.now = func() time.Time { return now }
+
+	price, err := stats.Percentile(1, WindowDay, 50)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(101), price)
+
+	_, err = stats.Percentile(1, WindowHour, 50)
+	assert.Equal(t, ErrNoGasSamples, err)
+
+	hours, err := stats.HourlyStats(1, WindowWeek, 50)
+	assert.NoError(t, err)
+	assert.Len(t, hours, 24)
+	assert.Equal(t, 3, hours[0].Hour)
+	assert.Equal(t, 4, hours[1].Hour)
+
+	next, err := stats.NextCheapHour(1, WindowWeek, 50, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2021, 6, 2, 3, 0, 0, 0, time.UTC), next)
+
+	stats.now = func() time.Time { return time.Date(2021, 6, 2, 4, 10, 0, 0, time.UTC) }
+	next, err = stats.NextCheapHour(1, WindowWeek, 50, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2021, 6, 2, 4, 10, 0, 0, time.UTC), next)
+}
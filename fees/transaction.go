@@ -40,8 +40,16 @@ const (
 	// TxStateSucceed is given to transactions which have
 	// succeeded and should not be retried.
 	TxStateSucceed TransactionState = "succeed"
+	// TxStateReplaced is given to transactions which were cancelled
+	// or replaced by another transaction with the same nonce.
+	TxStateReplaced TransactionState = "replaced"
 )
 
+// final returns true if the transaction should not be watched anymore.
+func (s TransactionState) final() bool {
+	return s == TxStateFailed || s == TxStateSucceed || s == TxStateReplaced
+}
+
 // Transaction objects is used when handling transactions.
 type Transaction struct {
 	// UniqueID is a combination of Tx hash and chainID.
@@ -51,6 +59,11 @@ type Transaction struct {
 	TxHashHex  string
 	ChainID    int64
 	OriginalTx []byte
+
+	// CreatedAt is the time the transaction was first inserted.
+	CreatedAt time.Time
+	// Bumps is the number of times the gas price of the transaction was increased.
+	Bumps int
 }
 
 // TransactionOpts are provided when creating a new transaction.
@@ -61,6 +74,16 @@ type TransactionOpts struct {
 	Timeout          time.Duration
 	IncreaseInterval time.Duration
 	CheckInterval    time.Duration
+
+	// ProgressID identifies the progress events of the transaction across gas bumps.
+	// No events are emitted by the incrementor if it is empty.
+	ProgressID string
+	// Confirmations is the number of confirmations after which the transaction is reported finalized.
+	// It is only waited for if the client also implements HeaderByNumber.
+	Confirmations uint64
+	// RequestType describes the request that created the transaction, e.g. "settle".
+	// It is only informational and reported by ListPendingTransactions.
+	RequestType string
 }
 
 // TransactionUniqueID returns a unique ID for a transaction.
@@ -103,6 +126,7 @@ func newTransaction(tx *types.Transaction, chainID int64, opts TransactionOpts)
 		TxHashHex:  hash,
 		ChainID:    chainID,
 		OriginalTx: marshaled,
+		CreatedAt:  time.Now().UTC(),
 	}, nil
 }
 
@@ -25,6 +25,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/bus"
 	"github.com/rs/zerolog/log"
 )
 
@@ -33,6 +34,21 @@ type watchClient interface {
 	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
 }
 
+// TopicTxSeen is the bus topic TxSeen events are published to.
+const TopicTxSeen bus.Topic = "fees.tx_seen"
+
+// TxSeen is published once a watched transaction is visible on BC.
+type TxSeen struct {
+	Tx *types.Transaction
+	// Attempt is the number of times the transaction was sent, starting at 1.
+	Attempt int
+}
+
+// Topic implements bus.Event.
+func (TxSeen) Topic() bus.Topic {
+	return TopicTxSeen
+}
+
 // TxWatcher makes sure that transactions actually get sent to the network.
 // It retries for the given amount of times to send the TX, each time increasing the gas price by given percentage.
 type TxWatcher struct {
@@ -42,6 +58,7 @@ type TxWatcher struct {
 
 	client        watchClient
 	clientTimeout time.Duration
+	bus           *bus.Bus
 }
 
 // NewTxWatcher returns a new instance of tx watcher
@@ -55,6 +72,12 @@ func NewTxWatcher(client watchClient, clientTimeout time.Duration, maxRetries in
 	}
 }
 
+// AttachBus sets the bus the watcher publishes TxSeen events to.
+// Not thread safe, call before watching transactions.
+func (tw *TxWatcher) AttachBus(b *bus.Bus) {
+	tw.bus = b
+}
+
 // WatchableTransaction represents a transaction that the txwatcher can keep track of
 type WatchableTransaction func(gasPrice *big.Int) (*types.Transaction, error)
 
@@ -90,6 +113,7 @@ func (tw *TxWatcher) watchTx(wt WatchableTransaction, initialGasPrice *big.Int,
 		}
 
 		if pulledTx != nil {
+			tw.bus.Publish(TxSeen{Tx: pulledTx, Attempt: i})
 			return pulledTx, nil
 		}
 
@@ -0,0 +1,125 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package finality decides when settlements are final, waiting for Polygon checkpoints or Ethereum beacon finality
+// for high value settlements instead of a fixed number of confirmations.
+package finality
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ErrFinalizedUnsupported is returned when the node does not know the finalized block tag.
+var ErrFinalizedUnsupported = errors.New("node does not support the finalized block tag")
+
+// Oracle returns the highest finalized block of a chain.
+type Oracle interface {
+	FinalizedBlock() (uint64, error)
+}
+
+// rootChainABI is the part of the Polygon PoS RootChain contract ABI used to read checkpoints.
+const rootChainABI = `[{"inputs":[],"name":"getLastChildBlock","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+
+// PolygonCheckpoints reads the last Polygon block checkpointed to the RootChain contract on Ethereum.
+// Blocks up to the checkpoint can only be reverted together with Ethereum.
+type PolygonCheckpoints struct {
+	caller    bind.ContractCaller
+	rootChain common.Address
+	timeout   time.Duration
+	abi       abi.ABI
+}
+
+// NewPolygonCheckpoints returns a new checkpoint oracle. The caller must be connected to Ethereum, not Polygon.
+func NewPolygonCheckpoints(ethereumCaller bind.ContractCaller, rootChain common.Address, timeout time.Duration) (*PolygonCheckpoints, error) {
+	parsed, err := abi.JSON(strings.NewReader(rootChainABI))
+	if err != nil {
+		return nil, err
+	}
+	return &PolygonCheckpoints{
+		caller:    ethereumCaller,
+		rootChain: rootChain,
+		timeout:   timeout,
+		abi:       parsed,
+	}, nil
+}
+
+// FinalizedBlock returns the last checkpointed Polygon block.
+func (pc *PolygonCheckpoints) FinalizedBlock() (uint64, error) {
+	data, err := pc.abi.Pack("getLastChildBlock")
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pc.timeout)
+	defer cancel()
+	out, err := pc.caller.CallContract(ctx, ethereum.CallMsg{To: &pc.rootChain, Data: data}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not get last checkpointed block: %w", err)
+	}
+
+	var last *big.Int
+	if err := pc.abi.Unpack(&last, "getLastChildBlock", out); err != nil {
+		return 0, fmt.Errorf("could not unpack last checkpointed block: %w", err)
+	}
+	return last.Uint64(), nil
+}
+
+// RPCCaller makes raw JSON-RPC calls, the go-ethereum rpc client can be used.
+type RPCCaller interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// BeaconFinality reads the finalized block of a proof of stake Ethereum node.
+type BeaconFinality struct {
+	rpc     RPCCaller
+	timeout time.Duration
+}
+
+// NewBeaconFinality returns a new beacon finality oracle.
+func NewBeaconFinality(rpc RPCCaller, timeout time.Duration) *BeaconFinality {
+	return &BeaconFinality{
+		rpc:     rpc,
+		timeout: timeout,
+	}
+}
+
+// FinalizedBlock returns the number of the block tagged finalized.
+func (bf *BeaconFinality) FinalizedBlock() (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), bf.timeout)
+	defer cancel()
+
+	var head *struct {
+		Number *hexutil.Big `json:"number"`
+	}
+	if err := bf.rpc.CallContext(ctx, &head, "eth_getBlockByNumber", "finalized", false); err != nil {
+		return 0, fmt.Errorf("could not get finalized block: %w", err)
+	}
+	if head == nil || head.Number == nil {
+		return 0, ErrFinalizedUnsupported
+	}
+	return head.Number.ToInt().Uint64(), nil
+}
@@ -0,0 +1,254 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package finality
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Status is the finality status of a tracked settlement.
+type Status string
+
+const (
+	// StatusPending is the status of settlements that are not mined yet.
+	StatusPending Status = "pending"
+	// StatusMined is the status of mined settlements waiting for finality.
+	StatusMined Status = "mined"
+	// StatusFinalized is the status of final settlements, they are marked settled in the local stores.
+	StatusFinalized Status = "finalized"
+	// StatusFailed is the status of reverted settlements.
+	StatusFailed Status = "failed"
+)
+
+// Settlement is a settlement transaction tracked until it is final.
+type Settlement struct {
+	ChainID int64
+	TxHash  common.Hash
+	// ID identifies the settled promise in the local stores, e.g. the channel id.
+	ID string
+	// Value is compared against the high value threshold.
+	Value *big.Int
+
+	Status      Status
+	BlockNumber uint64
+	// HighValue is set if the settlement waits for the finality oracle instead of confirmations.
+	HighValue   bool
+	FinalizedAt time.Time
+}
+
+// Marker marks promises settled in the local stores once their settlement is final.
+type Marker interface {
+	MarkSettled(s Settlement) error
+}
+
+// Client reads receipts and the chain head.
+type Client interface {
+	TransactionReceipt(chainID int64, hash common.Hash) (*types.Receipt, error)
+	HeaderByNumber(chainID int64, number *big.Int) (*types.Header, error)
+}
+
+// LogFunc is called with errors encountered while checking the tracked settlements.
+type LogFunc func(error)
+
+// Tracker waits for settlements to become final before marking them settled.
+// Settlements worth at least the threshold wait for the finality oracle of their chain, a Polygon checkpoint
+// or Ethereum beacon finality. The rest, and chains without an oracle, wait for the given number of confirmations.
+type Tracker struct {
+	client        Client
+	marker        Marker
+	threshold     *big.Int
+	confirmations uint64
+	interval      time.Duration
+	oracles       map[int64]Oracle
+	logFunc       LogFunc
+	now           func() time.Time
+
+	lock        sync.Mutex
+	settlements map[common.Hash]*Settlement
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewTracker returns a new finality tracker. A nil threshold makes every settlement wait for the oracle.
+func NewTracker(client Client, marker Marker, threshold *big.Int, confirmations uint64, interval time.Duration) *Tracker {
+	return &Tracker{
+		client:        client,
+		marker:        marker,
+		threshold:     threshold,
+		confirmations: confirmations,
+		interval:      interval,
+		oracles:       make(map[int64]Oracle),
+		logFunc:       func(error) {},
+		now:           time.Now,
+		settlements:   make(map[common.Hash]*Settlement),
+		stop:          make(chan struct{}),
+	}
+}
+
+// AttachOracle sets the finality oracle of the chain.
+// Not thread safe, call before Run.
+func (t *Tracker) AttachOracle(chainID int64, oracle Oracle) {
+	t.oracles[chainID] = oracle
+}
+
+// AttachLogFunc sets the function check errors are reported to.
+// Not thread safe, call before Run.
+func (t *Tracker) AttachLogFunc(f LogFunc) {
+	t.logFunc = f
+}
+
+// Track starts tracking the settlement transaction.
+func (t *Tracker) Track(s Settlement) {
+	s.Status = StatusPending
+	_, hasOracle := t.oracles[s.ChainID]
+	s.HighValue = hasOracle && (t.threshold == nil || (s.Value != nil && s.Value.Cmp(t.threshold) >= 0))
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.settlements[s.TxHash] = &s
+}
+
+// Status returns the tracked settlement.
+func (t *Tracker) Status(txHash common.Hash) (Settlement, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	s, ok := t.settlements[txHash]
+	if !ok {
+		return Settlement{}, false
+	}
+	return *s, true
+}
+
+// Forget stops tracking the settlement.
+func (t *Tracker) Forget(txHash common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.settlements, txHash)
+}
+
+// Run checks the tracked settlements every interval until stopped.
+func (t *Tracker) Run() {
+	for {
+		t.Check()
+
+		select {
+		case <-t.stop:
+			return
+		case <-time.After(t.interval):
+		}
+	}
+}
+
+// Stop stops the tracker.
+func (t *Tracker) Stop() {
+	t.once.Do(func() {
+		close(t.stop)
+	})
+}
+
+// Check updates the status of the tracked settlements and marks the final ones settled.
+func (t *Tracker) Check() {
+	t.lock.Lock()
+	var open []Settlement
+	for _, s := range t.settlements {
+		if s.Status == StatusPending || s.Status == StatusMined {
+			open = append(open, *s)
+		}
+	}
+	t.lock.Unlock()
+
+	finalized := make(map[int64]uint64)
+	for _, s := range open {
+		next, err := t.check(s, finalized)
+		if err != nil {
+			t.logFunc(fmt.Errorf("could not check settlement %v: %w", s.TxHash.Hex(), err))
+			continue
+		}
+
+		t.lock.Lock()
+		if _, ok := t.settlements[s.TxHash]; ok {
+			t.settlements[s.TxHash] = &next
+		}
+		t.lock.Unlock()
+	}
+}
+
+func (t *Tracker) check(s Settlement, finalized map[int64]uint64) (Settlement, error) {
+	receipt, err := t.client.TransactionReceipt(s.ChainID, s.TxHash)
+	if errors.Is(err, ethereum.NotFound) {
+		// not mined yet, or reorged out
+		s.Status = StatusPending
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		s.Status = StatusFailed
+		return s, nil
+	}
+	s.Status = StatusMined
+	s.BlockNumber = receipt.BlockNumber.Uint64()
+
+	final, err := t.isFinal(s, finalized)
+	if err != nil || !final {
+		return s, err
+	}
+
+	s.Status = StatusFinalized
+	s.FinalizedAt = t.now().UTC()
+	if err := t.marker.MarkSettled(s); err != nil {
+		s.Status, s.FinalizedAt = StatusMined, time.Time{}
+		return s, fmt.Errorf("could not mark settled: %w", err)
+	}
+	return s, nil
+}
+
+func (t *Tracker) isFinal(s Settlement, finalized map[int64]uint64) (bool, error) {
+	if s.HighValue {
+		block, ok := finalized[s.ChainID]
+		if !ok {
+			var err error
+			block, err = t.oracles[s.ChainID].FinalizedBlock()
+			if err != nil {
+				return false, err
+			}
+			finalized[s.ChainID] = block
+		}
+		return block >= s.BlockNumber, nil
+	}
+
+	head, err := t.client.HeaderByNumber(s.ChainID, nil)
+	if err != nil {
+		return false, fmt.Errorf("could not get chain head: %w", err)
+	}
+	if head.Number.Uint64() < s.BlockNumber {
+		return false, nil
+	}
+	return head.Number.Uint64()-s.BlockNumber+1 >= t.confirmations, nil
+}
@@ -0,0 +1,153 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package finality
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockClient struct {
+	head     uint64
+	receipts map[common.Hash]*types.Receipt
+}
+
+func (mc *mockClient) TransactionReceipt(chainID int64, hash common.Hash) (*types.Receipt, error) {
+	r, ok := mc.receipts[hash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return r, nil
+}
+
+func (mc *mockClient) HeaderByNumber(chainID int64, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: new(big.Int).SetUint64(mc.head)}, nil
+}
+
+type mockOracle struct {
+	finalized uint64
+}
+
+func (mo *mockOracle) FinalizedBlock() (uint64, error) {
+	return mo.finalized, nil
+}
+
+type mockMarker struct {
+	marked []Settlement
+}
+
+func (mm *mockMarker) MarkSettled(s Settlement) error {
+	mm.marked = append(mm.marked, s)
+	return nil
+}
+
+func TestTracker(t *testing.T) {
+	small := common.HexToHash("0x1")
+	large := common.HexToHash("0x2")
+	reverted := common.HexToHash("0x3")
+	client := &mockClient{head: 100, receipts: map[common.Hash]*types.Receipt{}}
+	oracle := &mockOracle{finalized: 50}
+	marker := &mockMarker{}
+
+	tr := NewTracker(client, marker, big.NewInt(1000), 12, time.Second)
+	tr.AttachOracle(137, oracle)
+	tr.Track(Settlement{ChainID: 137, TxHash: small, Value: big.NewInt(10)})
+	tr.Track(Settlement{ChainID: 137, TxHash: large, Value: big.NewInt(1000)})
+	tr.Track(Settlement{ChainID: 137, TxHash: reverted, Value: big.NewInt(10)})
+
+	tr.Check()
+	s, ok := tr.Status(large)
+	assert.True(t, ok)
+	assert.Equal(t, StatusPending, s.Status)
+	assert.True(t, s.HighValue)
+
+	client.receipts[small] = &types.Receipt{Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(90)}
+	client.receipts[large] = &types.Receipt{Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(90)}
+	client.receipts[reverted] = &types.Receipt{Status: types.ReceiptStatusFailed, BlockNumber: big.NewInt(90)}
+	tr.Check()
+
+	s, _ = tr.Status(small)
+	assert.Equal(t, StatusMined, s.Status)
+	s, _ = tr.Status(reverted)
+	assert.Equal(t, StatusFailed, s.Status)
+
+	// enough confirmations for the small settlement, but no checkpoint for the large one
+	client.head = 101
+	tr.Check()
+	s, _ = tr.Status(small)
+	assert.Equal(t, StatusFinalized, s.Status)
+	s, _ = tr.Status(large)
+	assert.Equal(t, StatusMined, s.Status)
+	assert.Len(t, marker.marked, 1)
+
+	oracle.finalized = 95
+	tr.Check()
+	s, _ = tr.Status(large)
+	assert.Equal(t, StatusFinalized, s.Status)
+	assert.Equal(t, uint64(90), s.BlockNumber)
+	assert.Len(t, marker.marked, 2)
+
+	tr.Forget(large)
+	_, ok = tr.Status(large)
+	assert.False(t, ok)
+}
+
+type mockRPC struct {
+	result string
+}
+
+func (m mockRPC) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	return json.Unmarshal([]byte(m.result), result)
+}
+
+func TestBeaconFinality(t *testing.T) {
+	block, err := NewBeaconFinality(mockRPC{result: `{"number":"0x10"}`}, time.Second).FinalizedBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(16), block)
+
+	_, err = NewBeaconFinality(mockRPC{result: `null`}, time.Second).FinalizedBlock()
+	assert.Equal(t, ErrFinalizedUnsupported, err)
+}
+
+type mockCaller struct {
+	out []byte
+}
+
+func (mc mockCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (mc mockCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return mc.out, nil
+}
+
+func TestPolygonCheckpoints(t *testing.T) {
+	pc, err := NewPolygonCheckpoints(mockCaller{out: common.BigToHash(big.NewInt(12345)).Bytes()}, common.HexToAddress("0x1"), time.Second)
+	assert.NoError(t, err)
+
+	block, err := pc.FinalizedBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(12345), block)
+}
@@ -0,0 +1,193 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package hermes
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	pc "github.com/mysteriumnetwork/payments/crypto"
+	"github.com/mysteriumnetwork/payments/exposure"
+	"github.com/rs/zerolog/log"
+)
+
+// maxBodySize limits the size of the request bodies.
+const maxBodySize = 1 << 20
+
+// RequestPromiseRequest is the body of the request promise endpoint.
+type RequestPromiseRequest struct {
+	ExchangeMessage pc.ExchangeMessage `json:"exchange_message"`
+}
+
+// PromiseResponse carries an issued promise.
+type PromiseResponse struct {
+	ChainID   int64  `json:"chain_id"`
+	ChannelID string `json:"channel_id"`
+	Amount    string `json:"amount"`
+	Fee       string `json:"fee"`
+	Hashlock  string `json:"hashlock"`
+	Signature string `json:"signature"`
+}
+
+// RevealRRequest is the body of the reveal R endpoint.
+type RevealRRequest struct {
+	Provider string `json:"provider"`
+	R        string `json:"r"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler serves the hermes API:
+//
+//	POST /request_promise           exchanges a consumer promise for a provider promise
+//	POST /reveal_r                  stores the R of an issued promise
+//	GET  /latest_promise?provider=  returns the latest promise of the provider
+type Handler struct {
+	issuer *Issuer
+	mux    *http.ServeMux
+}
+
+// NewHandler returns a new hermes API handler. Mount it with http.StripPrefix to serve it under a path.
+func NewHandler(issuer *Issuer) *Handler {
+	h := &Handler{issuer: issuer, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/request_promise", h.requestPromise)
+	h.mux.HandleFunc("/reveal_r", h.revealR)
+	h.mux.HandleFunc("/latest_promise", h.latestPromise)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) requestPromise(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req RequestPromiseRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	promise, err := h.issuer.IssuePromise(req.ExchangeMessage)
+	if err != nil {
+		writeIssuerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, NewPromiseResponse(*promise))
+}
+
+func (h *Handler) revealR(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req RevealRRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !common.IsHexAddress(req.Provider) {
+		writeError(w, http.StatusBadRequest, "invalid provider")
+		return
+	}
+	rb, err := hex.DecodeString(strings.TrimPrefix(req.R, "0x"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid r")
+		return
+	}
+
+	if err := h.issuer.RevealR(common.HexToAddress(req.Provider), rb); err != nil {
+		writeIssuerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) latestPromise(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	provider := r.URL.Query().Get("provider")
+	if !common.IsHexAddress(provider) {
+		writeError(w, http.StatusBadRequest, "invalid provider")
+		return
+	}
+
+	promise, err := h.issuer.LatestPromise(common.HexToAddress(provider))
+	if err != nil {
+		writeIssuerError(w, err)
+		return
+	}
+	if promise == nil {
+		writeError(w, http.StatusNotFound, "no promise issued")
+		return
+	}
+	writeJSON(w, http.StatusOK, NewPromiseResponse(*promise))
+}
+
+// NewPromiseResponse converts the promise to its API representation.
+func NewPromiseResponse(p pc.Promise) PromiseResponse {
+	return PromiseResponse{
+		ChainID:   p.ChainID,
+		ChannelID: "0x" + hex.EncodeToString(p.ChannelID),
+		Amount:    p.Amount.String(),
+		Fee:       p.Fee.String(),
+		Hashlock:  "0x" + hex.EncodeToString(p.Hashlock),
+		Signature: "0x" + hex.EncodeToString(p.Signature),
+	}
+}
+
+// writeIssuerError maps the issuer error to a fixed client message, internal errors are only logged.
+func writeIssuerError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidMessage):
+		writeError(w, http.StatusBadRequest, ErrInvalidMessage.Error())
+	case errors.Is(err, ErrStalePromise):
+		writeError(w, http.StatusConflict, ErrStalePromise.Error())
+	case errors.Is(err, ErrUnknownHashlock):
+		writeError(w, http.StatusNotFound, ErrUnknownHashlock.Error())
+	case errors.Is(err, exposure.ErrExposureExceeded):
+		writeError(w, http.StatusPaymentRequired, exposure.ErrExposureExceeded.Error())
+	default:
+		log.Error().Err(err).Msg("hermes request failed")
+		writeError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,263 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package hermes provides the server side of the promise exchange, for anyone running their own hermes.
+// Consumers send exchange messages, the hermes validates them and issues promises to the provider channels.
+package hermes
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	pc "github.com/mysteriumnetwork/payments/crypto"
+)
+
+var (
+	// ErrInvalidMessage is returned for exchange messages that fail validation, the cause is wrapped along.
+	ErrInvalidMessage = errors.New("invalid exchange message")
+	// ErrStalePromise is returned for consumer promises not exceeding the previous one.
+	ErrStalePromise = errors.New("stale consumer promise")
+	// ErrUnknownHashlock is returned when revealing an R no promise was issued for.
+	ErrUnknownHashlock = errors.New("unknown hashlock")
+)
+
+// HashSigner signs hashes with the hermes operator key.
+type HashSigner interface {
+	SignHash(a accounts.Account, hash []byte) ([]byte, error)
+}
+
+// ExposureLimiter refuses promises beyond the exposure limits. The exposure.Limiter can be used.
+type ExposureLimiter interface {
+	Reserve(hermesID, provider common.Address, amount *big.Int) error
+}
+
+// Issuance is a promise issued to a provider in exchange for a consumer promise.
+type Issuance struct {
+	ChainID        int64
+	Consumer       common.Address
+	Provider       common.Address
+	AgreementID    *big.Int
+	AgreementTotal *big.Int
+	// ConsumerPromise is the promise to the hermes, it can be settled once R is revealed.
+	ConsumerPromise pc.Promise
+	ProviderPromise pc.Promise
+	CreatedAt       time.Time
+}
+
+// Revealed returns true once the provider revealed the R of the hashlock.
+func (i Issuance) Revealed() bool {
+	return len(i.ConsumerPromise.R) > 0
+}
+
+// Storage persists the issued promises.
+type Storage interface {
+	// GetLatestConsumerPromise returns nil if the consumer channel has no promise yet.
+	GetLatestConsumerPromise(chainID int64, channel common.Address) (*pc.Promise, error)
+	// GetLatestProviderPromise returns nil if no promise was issued to the provider yet.
+	GetLatestProviderPromise(chainID int64, provider common.Address) (*pc.Promise, error)
+	// GetAgreementTotal returns the total of the last exchange of the agreement, nil if there was none.
+	GetAgreementTotal(chainID int64, provider common.Address, agreementID *big.Int) (*big.Int, error)
+	InsertIssuance(i Issuance) error
+	// GetIssuance returns nil if no promise with the hashlock was issued.
+	GetIssuance(chainID int64, hashlock []byte) (*Issuance, error)
+	// UpdateR sets the R of both promises of the issuance with the hashlock.
+	UpdateR(chainID int64, hashlock, r []byte) error
+}
+
+// Config describes the hermes.
+type Config struct {
+	ChainID               int64
+	HermesID              common.Address
+	Operator              common.Address
+	Registry              common.Address
+	ChannelImplementation common.Address
+	// MaxFee is the highest transactor fee accepted in consumer promises, nil means no limit.
+	MaxFee *big.Int
+}
+
+// Issuer validates exchange messages and issues promises to the provider channels.
+type Issuer struct {
+	cfg      Config
+	storage  Storage
+	exposure ExposureLimiter
+	ks       HashSigner
+	now      func() time.Time
+
+	lock sync.Mutex
+}
+
+// NewIssuer returns a new promise issuer signing with the operator key. The exposure limiter can be nil.
+func NewIssuer(cfg Config, storage Storage, exposure ExposureLimiter, ks HashSigner) *Issuer {
+	return &Issuer{
+		cfg:      cfg,
+		storage:  storage,
+		exposure: exposure,
+		ks:       ks,
+		now:      time.Now,
+	}
+}
+
+// Validate checks the exchange message is signed by the owner of the consumer channel and addressed to this hermes.
+// It returns the consumer identity.
+func (is *Issuer) Validate(m pc.ExchangeMessage) (common.Address, error) {
+	if m.ChainID != is.cfg.ChainID || m.Promise.ChainID != is.cfg.ChainID {
+		return common.Address{}, fmt.Errorf("%w: unexpected chain %v", ErrInvalidMessage, m.ChainID)
+	}
+	if !common.IsHexAddress(m.HermesID) || common.HexToAddress(m.HermesID) != is.cfg.HermesID {
+		return common.Address{}, fmt.Errorf("%w: unexpected hermes %q", ErrInvalidMessage, m.HermesID)
+	}
+	if !common.IsHexAddress(m.Provider) {
+		return common.Address{}, fmt.Errorf("%w: invalid provider %q", ErrInvalidMessage, m.Provider)
+	}
+	if m.Promise.Amount == nil || m.AgreementID == nil || m.AgreementTotal == nil || m.AgreementTotal.Sign() <= 0 {
+		return common.Address{}, fmt.Errorf("%w: missing amounts", ErrInvalidMessage)
+	}
+	if len(m.Promise.Hashlock) != 32 {
+		return common.Address{}, fmt.Errorf("%w: invalid hashlock", ErrInvalidMessage)
+	}
+	if is.cfg.MaxFee != nil && m.Promise.Fee != nil && m.Promise.Fee.Cmp(is.cfg.MaxFee) > 0 {
+		return common.Address{}, fmt.Errorf("%w: fee %v exceeds %v", ErrInvalidMessage, m.Promise.Fee, is.cfg.MaxFee)
+	}
+
+	consumer, err := m.RecoverConsumerIdentity()
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: could not recover signer: %v", ErrInvalidMessage, err)
+	}
+	if !m.Promise.IsPromiseValid(consumer) {
+		return common.Address{}, fmt.Errorf("%w: promise is not signed by %v", ErrInvalidMessage, consumer.Hex())
+	}
+
+	channel, err := pc.GenerateChannelAddress(consumer.Hex(), is.cfg.HermesID.Hex(), is.cfg.Registry.Hex(), is.cfg.ChannelImplementation.Hex())
+	if err != nil {
+		return common.Address{}, err
+	}
+	if common.BytesToAddress(m.Promise.ChannelID) != common.HexToAddress(channel) {
+		return common.Address{}, fmt.Errorf("%w: channel does not belong to %v", ErrInvalidMessage, consumer.Hex())
+	}
+
+	return consumer, nil
+}
+
+// IssuePromise validates the exchange message and issues a promise with the same hashlock to the provider channel.
+// The provider promise grows by the agreement increase, which the consumer promise increase has to cover.
+func (is *Issuer) IssuePromise(m pc.ExchangeMessage) (*pc.Promise, error) {
+	consumer, err := is.Validate(m)
+	if err != nil {
+		return nil, err
+	}
+	provider := common.HexToAddress(m.Provider)
+	channel := common.BytesToAddress(m.Promise.ChannelID)
+
+	is.lock.Lock()
+	defer is.lock.Unlock()
+
+	prevConsumer, err := is.storage.GetLatestConsumerPromise(is.cfg.ChainID, channel)
+	if err != nil {
+		return nil, fmt.Errorf("could not get consumer promise: %w", err)
+	}
+	consumerIncrease := new(big.Int).Set(m.Promise.Amount)
+	if prevConsumer != nil {
+		consumerIncrease.Sub(consumerIncrease, prevConsumer.Amount)
+	}
+	if consumerIncrease.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: amount %v does not exceed %v", ErrStalePromise, m.Promise.Amount, prevConsumer.Amount)
+	}
+
+	prevTotal, err := is.storage.GetAgreementTotal(is.cfg.ChainID, provider, m.AgreementID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get agreement total: %w", err)
+	}
+	agreementIncrease := new(big.Int).Set(m.AgreementTotal)
+	if prevTotal != nil {
+		agreementIncrease.Sub(agreementIncrease, prevTotal)
+	}
+	if agreementIncrease.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: agreement total %v does not exceed %v", ErrStalePromise, m.AgreementTotal, prevTotal)
+	}
+	if consumerIncrease.Cmp(agreementIncrease) < 0 {
+		return nil, fmt.Errorf("%w: promise increase %v does not cover agreement increase %v", ErrInvalidMessage, consumerIncrease, agreementIncrease)
+	}
+
+	prevProvider, err := is.storage.GetLatestProviderPromise(is.cfg.ChainID, provider)
+	if err != nil {
+		return nil, fmt.Errorf("could not get provider promise: %w", err)
+	}
+	amount := new(big.Int).Set(agreementIncrease)
+	if prevProvider != nil {
+		amount.Add(amount, prevProvider.Amount)
+	}
+
+	if is.exposure != nil {
+		if err := is.exposure.Reserve(is.cfg.HermesID, provider, amount); err != nil {
+			return nil, err
+		}
+	}
+
+	channelID := pc.GenerateProviderChannelIDBytes(provider, is.cfg.HermesID)
+	promise, err := pc.CreatePromise(hex.EncodeToString(channelID), is.cfg.ChainID, amount, new(big.Int), hex.EncodeToString(m.Promise.Hashlock), is.ks, is.cfg.Operator)
+	if err != nil {
+		return nil, fmt.Errorf("could not sign provider promise: %w", err)
+	}
+
+	err = is.storage.InsertIssuance(Issuance{
+		ChainID:         is.cfg.ChainID,
+		Consumer:        consumer,
+		Provider:        provider,
+		AgreementID:     new(big.Int).Set(m.AgreementID),
+		AgreementTotal:  new(big.Int).Set(m.AgreementTotal),
+		ConsumerPromise: m.Promise,
+		ProviderPromise: *promise,
+		CreatedAt:       is.now().UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not store issuance: %w", err)
+	}
+
+	return promise, nil
+}
+
+// RevealR stores the R the provider revealed, making the consumer promise of its hashlock settleable.
+func (is *Issuer) RevealR(provider common.Address, r []byte) error {
+	hashlock := crypto.Keccak256(r)
+
+	is.lock.Lock()
+	defer is.lock.Unlock()
+
+	issuance, err := is.storage.GetIssuance(is.cfg.ChainID, hashlock)
+	if err != nil {
+		return err
+	}
+	if issuance == nil || issuance.Provider != provider {
+		return fmt.Errorf("%w: 0x%v", ErrUnknownHashlock, hex.EncodeToString(hashlock))
+	}
+	if issuance.Revealed() && bytes.Equal(issuance.ConsumerPromise.R, r) {
+		return nil
+	}
+	return is.storage.UpdateR(is.cfg.ChainID, hashlock, r)
+}
+
+// LatestPromise returns the latest promise issued to the provider, or nil if there is none.
+func (is *Issuer) LatestPromise(provider common.Address) (*pc.Promise, error) {
+	return is.storage.GetLatestProviderPromise(is.cfg.ChainID, provider)
+}
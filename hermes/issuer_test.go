@@ -0,0 +1,270 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package hermes
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	pc "github.com/mysteriumnetwork/payments/crypto"
+	"github.com/mysteriumnetwork/payments/exposure"
+	"github.com/stretchr/testify/assert"
+)
+
+type keySigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (ks keySigner) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
+	return crypto.Sign(hash, ks.key)
+}
+
+func newSigner(t *testing.T) (keySigner, common.Address) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	return keySigner{key: key}, crypto.PubkeyToAddress(key.PublicKey)
+}
+
+type memStorage struct {
+	issuances []Issuance
+}
+
+func (ms *memStorage) GetLatestConsumerPromise(chainID int64, channel common.Address) (*pc.Promise, error) {
+	for i := len(ms.issuances) - 1; i >= 0; i-- {
+		if common.BytesToAddress(ms.issuances[i].ConsumerPromise.ChannelID) == channel {
+			return &ms.issuances[i].ConsumerPromise, nil
+		}
+	}
+	return nil, nil
+}
+
+func (ms *memStorage) GetLatestProviderPromise(chainID int64, provider common.Address) (*pc.Promise, error) {
+	for i := len(ms.issuances) - 1; i >= 0; i-- {
+		if ms.issuances[i].Provider == provider {
+			return &ms.issuances[i].ProviderPromise, nil
+		}
+	}
+	return nil, nil
+}
+
+func (ms *memStorage) GetAgreementTotal(chainID int64, provider common.Address, agreementID *big.Int) (*big.Int, error) {
+	for i := len(ms.issuances) - 1; i >= 0; i-- {
+		if ms.issuances[i].Provider == provider && ms.issuances[i].AgreementID.Cmp(agreementID) == 0 {
+			return ms.issuances[i].AgreementTotal, nil
+		}
+	}
+	return nil, nil
+}
+
+func (ms *memStorage) InsertIssuance(i Issuance) error {
+	ms.issuances = append(ms.issuances, i)
+	return nil
+}
+
+func (ms *memStorage) GetIssuance(chainID int64, hashlock []byte) (*Issuance, error) {
+	for i := range ms.issuances {
+		if bytes.Equal(ms.issuances[i].ConsumerPromise.Hashlock, hashlock) {
+			return &ms.issuances[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (ms *memStorage) UpdateR(chainID int64, hashlock, r []byte) error {
+	for i := range ms.issuances {
+		if bytes.Equal(ms.issuances[i].ConsumerPromise.Hashlock, hashlock) {
+			ms.issuances[i].ConsumerPromise.R = r
+			ms.issuances[i].ProviderPromise.R = r
+		}
+	}
+	return nil
+}
+
+type memExposure map[common.Address]exposure.Exposure
+
+func (m memExposure) UpsertExposure(e exposure.Exposure) error { m[e.Provider] = e; return nil }
+func (m memExposure) GetExposures() ([]exposure.Exposure, error) {
+	return nil, nil
+}
+
+type fixture struct {
+	cfg      Config
+	consumer keySigner
+	identity common.Address
+	channel  string
+	provider common.Address
+}
+
+func (f fixture) exchange(t *testing.T, amount, agreementTotal int64, r []byte) pc.ExchangeMessage {
+	invoice := pc.CreateInvoice(big.NewInt(1), big.NewInt(agreementTotal), big.NewInt(0), r, f.cfg.ChainID)
+	invoice.Provider = f.provider.Hex()
+	m, err := pc.CreateExchangeMessage(f.cfg.ChainID, invoice, big.NewInt(amount), f.channel, f.cfg.HermesID.Hex(), f.consumer, f.identity)
+	assert.NoError(t, err)
+	return *m
+}
+
+func newFixture(t *testing.T) fixture {
+	consumer, identity := newSigner(t)
+	cfg := Config{
+		ChainID:               137,
+		HermesID:              common.HexToAddress("0x1"),
+		Registry:              common.HexToAddress("0x2"),
+		ChannelImplementation: common.HexToAddress("0x3"),
+	}
+	channel, err := pc.GenerateChannelAddress(identity.Hex(), cfg.HermesID.Hex(), cfg.Registry.Hex(), cfg.ChannelImplementation.Hex())
+	assert.NoError(t, err)
+	return fixture{cfg: cfg, consumer: consumer, identity: identity, channel: channel, provider: common.HexToAddress("0x4")}
+}
+
+func TestIssuer(t *testing.T) {
+	f := newFixture(t)
+	operator, operatorAddr := newSigner(t)
+	f.cfg.Operator = operatorAddr
+	limiter, err := exposure.NewLimiter(memExposure{}, exposure.Limits{PerProvider: big.NewInt(100)})
+	assert.NoError(t, err)
+	storage := &memStorage{}
+	is := NewIssuer(f.cfg, storage, limiter, operator)
+
+	r := bytes.Repeat([]byte{1}, 32)
+	promise, err := is.IssuePromise(f.exchange(t, 15, 10, r))
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(10), promise.Amount)
+	assert.True(t, promise.IsPromiseValid(operatorAddr))
+	assert.Equal(t, pc.GenerateProviderChannelIDBytes(f.provider, f.cfg.HermesID), promise.ChannelID)
+
+	// the same promise again
+	_, err = is.IssuePromise(f.exchange(t, 15, 20, r))
+	assert.True(t, errors.Is(err, ErrStalePromise))
+
+	// the consumer must cover the agreement increase
+	_, err = is.IssuePromise(f.exchange(t, 20, 30, r))
+	assert.True(t, errors.Is(err, ErrInvalidMessage))
+
+	promise, err = is.IssuePromise(f.exchange(t, 35, 30, r))
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(30), promise.Amount)
+
+	_, err = is.IssuePromise(f.exchange(t, 205, 200, r))
+	assert.True(t, errors.Is(err, exposure.ErrExposureExceeded))
+
+	// messages of other hermeses are refused
+	other := f.exchange(t, 300, 300, r)
+	other.HermesID = common.HexToAddress("0x5").Hex()
+	_, err = is.IssuePromise(other)
+	assert.True(t, errors.Is(err, ErrInvalidMessage))
+
+	// R of the hashlock
+	assert.True(t, errors.Is(is.RevealR(f.provider, []byte("wrong")), ErrUnknownHashlock))
+	assert.True(t, errors.Is(is.RevealR(common.HexToAddress("0x5"), r), ErrUnknownHashlock))
+	assert.NoError(t, is.RevealR(f.provider, r))
+	assert.True(t, storage.issuances[0].Revealed())
+}
+
+func TestIssuerRefusesForeignChannels(t *testing.T) {
+	f := newFixture(t)
+	operator, operatorAddr := newSigner(t)
+	f.cfg.Operator = operatorAddr
+	is := NewIssuer(f.cfg, &memStorage{}, nil, operator)
+
+	f.channel = common.HexToAddress("0x6").Hex()
+	_, err := is.IssuePromise(f.exchange(t, 15, 10, nil))
+	assert.True(t, errors.Is(err, ErrInvalidMessage))
+}
+
+func TestHandler(t *testing.T) {
+	f := newFixture(t)
+	operator, operatorAddr := newSigner(t)
+	f.cfg.Operator = operatorAddr
+	server := httptest.NewServer(NewHandler(NewIssuer(f.cfg, &memStorage{}, nil, operator)))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/latest_promise?provider=" + f.provider.Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+
+	r := bytes.Repeat([]byte{2}, 32)
+	body, err := json.Marshal(RequestPromiseRequest{ExchangeMessage: f.exchange(t, 10, 10, r)})
+	assert.NoError(t, err)
+	resp, err = http.Post(server.URL+"/request_promise", "application/json", bytes.NewReader(body))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var promise PromiseResponse
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&promise))
+	resp.Body.Close()
+	assert.Equal(t, "10", promise.Amount)
+
+	resp, err = http.Post(server.URL+"/request_promise", "application/json", bytes.NewReader(body))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	resp.Body.Close()
+
+	body, err = json.Marshal(RevealRRequest{Provider: f.provider.Hex(), R: "0x" + hex.EncodeToString(r)})
+	assert.NoError(t, err)
+	resp, err = http.Post(server.URL+"/reveal_r", "application/json", bytes.NewReader(body))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + "/latest_promise?provider=" + f.provider.Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+}
+
+type failingStorage struct {
+	memStorage
+}
+
+func (fs *failingStorage) GetLatestProviderPromise(chainID int64, provider common.Address) (*pc.Promise, error) {
+	return nil, errors.New("connection to 10.0.0.1 refused")
+}
+
+func TestHandlerErrors(t *testing.T) {
+	f := newFixture(t)
+	operator, operatorAddr := newSigner(t)
+	f.cfg.Operator = operatorAddr
+	server := httptest.NewServer(NewHandler(NewIssuer(f.cfg, &failingStorage{}, nil, operator)))
+	defer server.Close()
+
+	var res errorResponse
+	resp, err := http.Get(server.URL + "/latest_promise?provider=" + f.provider.Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	resp.Body.Close()
+	assert.Equal(t, "internal error", res.Error)
+
+	body := append([]byte(`{"provider":"`), bytes.Repeat([]byte{'0'}, maxBodySize)...)
+	body = append(body, []byte(`"}`)...)
+	resp, err = http.Post(server.URL+"/reveal_r", "application/json", bytes.NewReader(body))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	resp.Body.Close()
+	assert.Equal(t, "invalid request body", res.Error)
+}
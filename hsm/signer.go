@@ -0,0 +1,172 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package hsm signs hashes with secp256k1 keys kept in PKCS#11 hardware security modules.
+// The signer can be passed wherever the library expects a SignHash implementation, e.g. crypto.CreatePromise.
+package hsm
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ObjectHandle is the PKCS#11 handle of a key object.
+type ObjectHandle uint
+
+// Session is the subset of a PKCS#11 session used by the signer.
+// It is implemented on top of the PKCS#11 bindings of choice, e.g. github.com/miekg/pkcs11.
+type Session interface {
+	// FindKeys returns the handles of the secp256k1 private keys available in the token.
+	FindKeys() ([]ObjectHandle, error)
+	// ECPoint returns the CKA_EC_POINT attribute of the public key matching the private key.
+	ECPoint(key ObjectHandle) ([]byte, error)
+	// Sign signs the hash using the CKM_ECDSA mechanism and returns the 64 byte r||s signature.
+	Sign(key ObjectHandle, hash []byte) ([]byte, error)
+}
+
+// ErrUnknownAccount is returned when signing with an account that has no key in the token.
+var ErrUnknownAccount = errors.New("no key for account in the token")
+
+// ErrInvalidSignature is returned when the token returns a signature that does not match the key.
+var ErrInvalidSignature = errors.New("token returned an invalid signature")
+
+var (
+	secp256k1N     = crypto.S256().Params().N
+	secp256k1HalfN = new(big.Int).Rsh(secp256k1N, 1)
+)
+
+type key struct {
+	handle ObjectHandle
+	public []byte
+}
+
+// Signer signs hashes with the keys of a PKCS#11 token, producing signatures in the format of the go-ethereum keystore.
+type Signer struct {
+	lock    sync.Mutex
+	session Session
+	keys    map[common.Address]key
+	order   []common.Address
+}
+
+// NewSigner returns a signer using the keys found in the session.
+func NewSigner(session Session) (*Signer, error) {
+	handles, err := session.FindKeys()
+	if err != nil {
+		return nil, fmt.Errorf("could not find keys: %w", err)
+	}
+
+	s := &Signer{
+		session: session,
+		keys:    make(map[common.Address]key, len(handles)),
+	}
+	for _, h := range handles {
+		point, err := session.ECPoint(h)
+		if err != nil {
+			return nil, fmt.Errorf("could not get public key of object %v: %w", h, err)
+		}
+		public, err := ParseECPoint(point)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse public key of object %v: %w", h, err)
+		}
+		pub, err := crypto.UnmarshalPubkey(public)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse public key of object %v: %w", h, err)
+		}
+
+		address := crypto.PubkeyToAddress(*pub)
+		if _, ok := s.keys[address]; !ok {
+			s.order = append(s.order, address)
+		}
+		s.keys[address] = key{handle: h, public: public}
+	}
+	return s, nil
+}
+
+// Accounts returns the accounts of the keys in the token.
+func (s *Signer) Accounts() []accounts.Account {
+	res := make([]accounts.Account, len(s.order))
+	for i, address := range s.order {
+		res[i] = accounts.Account{Address: address}
+	}
+	return res
+}
+
+// SignHash signs the hash with the key of the account. The returned signature is 65 bytes long with V being 0 or 1.
+// PKCS#11 sessions are not safe for concurrent use, so signatures are created one at a time.
+func (s *Signer) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
+	k, ok := s.keys[a.Address]
+	if !ok {
+		return nil, ErrUnknownAccount
+	}
+
+	s.lock.Lock()
+	rs, err := s.session.Sign(k.handle, hash)
+	s.lock.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("could not sign: %w", err)
+	}
+
+	return toRecoverable(hash, rs, k.public)
+}
+
+// toRecoverable converts the r||s signature to the canonical low S form and appends the recovery id.
+func toRecoverable(hash, rs, public []byte) ([]byte, error) {
+	if len(rs) != 64 {
+		return nil, fmt.Errorf("%w: length %v", ErrInvalidSignature, len(rs))
+	}
+
+	sig := make([]byte, 65)
+	copy(sig, rs[:32])
+	s := new(big.Int).SetBytes(rs[32:])
+	if s.Cmp(secp256k1HalfN) > 0 {
+		s.Sub(secp256k1N, s)
+	}
+	copy(sig[32:64], common.LeftPadBytes(s.Bytes(), 32))
+
+	for v := byte(0); v < 2; v++ {
+		sig[64] = v
+		recovered, err := crypto.Ecrecover(hash, sig)
+		if err == nil && string(recovered) == string(public) {
+			return sig, nil
+		}
+	}
+	return nil, ErrInvalidSignature
+}
+
+// ParseECPoint parses the CKA_EC_POINT attribute into an uncompressed public key.
+// The attribute is a DER encoded octet string, some tokens return the raw point instead.
+func ParseECPoint(point []byte) ([]byte, error) {
+	if len(point) == 65 && point[0] == 4 {
+		return point, nil
+	}
+
+	var raw []byte
+	rest, err := asn1.Unmarshal(point, &raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 || len(raw) != 65 || raw[0] != 4 {
+		return nil, errors.New("not an uncompressed secp256k1 point")
+	}
+	return raw, nil
+}
@@ -0,0 +1,128 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package hsm
+
+import (
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	pc "github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSession imitates a token returning r||s signatures with an arbitrary S.
+type fakeSession struct {
+	keys  []*ecdsa.PrivateKey
+	highS bool
+}
+
+func (fs *fakeSession) FindKeys() ([]ObjectHandle, error) {
+	res := make([]ObjectHandle, len(fs.keys))
+	for i := range fs.keys {
+		res[i] = ObjectHandle(i)
+	}
+	return res, nil
+}
+
+func (fs *fakeSession) ECPoint(key ObjectHandle) ([]byte, error) {
+	return asn1.Marshal(crypto.FromECDSAPub(&fs.keys[key].PublicKey))
+}
+
+func (fs *fakeSession) Sign(key ObjectHandle, hash []byte) ([]byte, error) {
+	sig, err := crypto.Sign(hash, fs.keys[key])
+	if err != nil {
+		return nil, err
+	}
+	if fs.highS {
+		s := new(big.Int).Sub(secp256k1N, new(big.Int).SetBytes(sig[32:64]))
+		copy(sig[32:64], common.LeftPadBytes(s.Bytes(), 32))
+	}
+	return sig[:64], nil
+}
+
+func TestSigner(t *testing.T) {
+	k1, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	k2, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	session := &fakeSession{keys: []*ecdsa.PrivateKey{k1, k2}}
+
+	signer, err := NewSigner(session)
+	assert.NoError(t, err)
+	accs := signer.Accounts()
+	assert.Equal(t, []accounts.Account{
+		{Address: crypto.PubkeyToAddress(k1.PublicKey)},
+		{Address: crypto.PubkeyToAddress(k2.PublicKey)},
+	}, accs)
+
+	for _, highS := range []bool{false, true} {
+		session.highS = highS
+		p, err := pc.CreatePromise(common.HexToHash("0x1").Hex(), 1, big.NewInt(10), big.NewInt(0), common.HexToHash("0x2").Hex(), signer, accs[1].Address)
+		assert.NoError(t, err)
+
+		recovered, err := p.RecoverSigner()
+		assert.NoError(t, err)
+		assert.Equal(t, accs[1].Address, recovered)
+		assert.True(t, new(big.Int).SetBytes(p.Signature[32:64]).Cmp(secp256k1HalfN) <= 0)
+	}
+
+	_, err = signer.SignHash(accounts.Account{Address: common.HexToAddress("0x1")}, make([]byte, 32))
+	assert.True(t, errors.Is(err, ErrUnknownAccount))
+}
+
+func TestToRecoverableRejectsForeignSignature(t *testing.T) {
+	k1, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	k2, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+
+	hash := crypto.Keccak256([]byte("hash"))
+	sig, err := crypto.Sign(hash, k1)
+	assert.NoError(t, err)
+
+	_, err = toRecoverable(hash, sig[:64], crypto.FromECDSAPub(&k2.PublicKey))
+	assert.True(t, errors.Is(err, ErrInvalidSignature))
+	_, err = toRecoverable(hash, sig, crypto.FromECDSAPub(&k1.PublicKey))
+	assert.True(t, errors.Is(err, ErrInvalidSignature))
+}
+
+func TestParseECPoint(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	raw := crypto.FromECDSAPub(&key.PublicKey)
+
+	parsed, err := ParseECPoint(raw)
+	assert.NoError(t, err)
+	assert.Equal(t, raw, parsed)
+
+	der, err := asn1.Marshal(raw)
+	assert.NoError(t, err)
+	parsed, err = ParseECPoint(der)
+	assert.NoError(t, err)
+	assert.Equal(t, raw, parsed)
+
+	der, err = asn1.Marshal(raw[:33])
+	assert.NoError(t, err)
+	_, err = ParseECPoint(der)
+	assert.Error(t, err)
+}
@@ -0,0 +1,319 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package indexer scans the blockchain for contract events and stores them using a caller provided storage.
+package indexer
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/bus"
+	"github.com/mysteriumnetwork/payments/client"
+)
+
+// ErrReorgTooDeep is returned when none of the stored checkpoints are on the canonical chain anymore.
+var ErrReorgTooDeep = errors.New("chain reorganization is deeper than the stored checkpoints")
+
+// errChainMoved is returned when the chain changed while a block range was being processed.
+var errChainMoved = errors.New("chain changed while processing block range")
+
+// errWindowShrunk is returned when filtering a block range failed and the range will be retried with a smaller window.
+var errWindowShrunk = errors.New("block window shrunk after a failed query")
+
+// Topics of the events published by the indexer.
+const (
+	TopicBlocksIndexed bus.Topic = "indexer.blocks_indexed"
+	TopicRolledBack    bus.Topic = "indexer.rolled_back"
+)
+
+// BlocksIndexed is published once a block range and its logs are stored.
+type BlocksIndexed struct {
+	From uint64
+	To   uint64
+	// Hash is the hash of the last block of the range.
+	Hash common.Hash
+	Logs []types.Log
+}
+
+// Topic implements bus.Event.
+func (BlocksIndexed) Topic() bus.Topic {
+	return TopicBlocksIndexed
+}
+
+// RolledBack is published when the logs of orphaned blocks are removed after a reorg.
+type RolledBack struct {
+	// From is the first removed block.
+	From uint64
+}
+
+// Topic implements bus.Event.
+func (RolledBack) Topic() bus.Topic {
+	return TopicRolledBack
+}
+
+// Checkpoint marks a processed block.
+type Checkpoint struct {
+	Number uint64
+	Hash   common.Hash
+}
+
+// Client handles calls to BC.
+type Client interface {
+	FilterLogs(q ethereum.FilterQuery) ([]types.Log, error)
+	HeaderByNumber(number *big.Int) (*types.Header, error)
+}
+
+// Storage is given to the Indexer to persist events and checkpoints.
+type Storage interface {
+	// InsertIndexerBlocks stores the logs of a processed block range together with its checkpoint.
+	// Both should be stored atomically, otherwise the range might be processed twice.
+	InsertIndexerBlocks(cp Checkpoint, logs []types.Log) error
+
+	// GetIndexerCheckpoints returns the stored checkpoints.
+	GetIndexerCheckpoints() ([]Checkpoint, error)
+
+	// PruneIndexerCheckpoints removes all but the latest keep checkpoints.
+	PruneIndexerCheckpoints(keep int) error
+
+	// RollbackIndexer removes all logs and checkpoints of blocks greater than or equal to the given block number.
+	RollbackIndexer(from uint64) error
+}
+
+// LogFunc can be attached to Indexer to enable logging.
+type LogFunc func(error)
+
+// Opts are given when creating a new Indexer.
+type Opts struct {
+	// Query selects the contracts and topics to index. Block range fields are ignored.
+	Query ethereum.FilterQuery
+	// StartBlock is the first block to index if nothing was indexed yet.
+	StartBlock uint64
+	// BatchSize is the amount of blocks processed in a single filter logs call.
+	BatchSize uint64
+	// Window adapts the amount of blocks processed in a single filter logs call to the returned logs and
+	// provider errors. BatchSize is ignored if set.
+	Window *client.BlockWindow
+	// Checkpoints is the amount of checkpoints kept for reorg detection.
+	Checkpoints int
+	// PullInterval is the wait between sync attempts once the indexer reaches the chain head.
+	PullInterval time.Duration
+}
+
+// Indexer scans the chain for logs matching the given query.
+// It keeps hashes of the last processed block ranges and when the chain reorganizes,
+// it rolls back the logs of the orphaned blocks and processes them again.
+type Indexer struct {
+	client  Client
+	storage Storage
+	opts    Opts
+	logFn   LogFunc
+	bus     *bus.Bus
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewIndexer returns a new indexer instance.
+func NewIndexer(client Client, storage Storage, opts Opts) *Indexer {
+	if opts.BatchSize == 0 {
+		opts.BatchSize = 1
+	}
+	if opts.Checkpoints <= 0 {
+		opts.Checkpoints = 1
+	}
+
+	return &Indexer{
+		client:  client,
+		storage: storage,
+		opts:    opts,
+		stop:    make(chan struct{}),
+	}
+}
+
+// AttachLogFunc attaches a new log func to the indexer.
+// The given log func is called every time the running indexer encounters an error.
+//
+// This method is not thread safe and should be called before Run.
+func (i *Indexer) AttachLogFunc(logFn LogFunc) {
+	i.logFn = logFn
+}
+
+// AttachBus sets the bus the indexer publishes its events to.
+//
+// This method is not thread safe and should be called before Run.
+func (i *Indexer) AttachBus(b *bus.Bus) {
+	i.bus = b
+}
+
+// Run keeps the indexer in sync with the chain until stopped.
+func (i *Indexer) Run() {
+	for {
+		caughtUp, err := i.Sync()
+		if err != nil {
+			i.log(err)
+		}
+
+		wait := time.Duration(0)
+		if caughtUp || err != nil {
+			wait = i.opts.PullInterval
+		}
+
+		select {
+		case <-i.stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Stop stops the indexer thread created by the Run method.
+func (i *Indexer) Stop() {
+	i.once.Do(func() {
+		close(i.stop)
+	})
+}
+
+// Sync processes the next block range.
+// It returns true if the indexer has reached the chain head.
+func (i *Indexer) Sync() (bool, error) {
+	from, err := i.verifyCheckpoints()
+	if err != nil {
+		return false, err
+	}
+
+	head, err := i.client.HeaderByNumber(nil)
+	if err != nil {
+		return false, fmt.Errorf("could not get chain head: %w", err)
+	}
+
+	headNumber := head.Number.Uint64()
+	if from > headNumber {
+		return true, nil
+	}
+
+	size := i.opts.BatchSize
+	if i.opts.Window != nil {
+		size = i.opts.Window.Size()
+	}
+
+	to := from + size - 1
+	if to > headNumber {
+		to = headNumber
+	}
+
+	if err := i.processRange(from, to); err != nil {
+		if errors.Is(err, errChainMoved) || errors.Is(err, errWindowShrunk) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return to == headNumber, nil
+}
+
+// verifyCheckpoints checks the stored checkpoints against the chain, rolls back orphaned blocks
+// and returns the number of the next block to process.
+func (i *Indexer) verifyCheckpoints() (uint64, error) {
+	cps, err := i.storage.GetIndexerCheckpoints()
+	if err != nil {
+		return 0, fmt.Errorf("could not get checkpoints: %w", err)
+	}
+
+	if len(cps) == 0 {
+		return i.opts.StartBlock, nil
+	}
+
+	sort.Slice(cps, func(a, b int) bool {
+		return cps[a].Number > cps[b].Number
+	})
+
+	for n, cp := range cps {
+		header, err := i.client.HeaderByNumber(new(big.Int).SetUint64(cp.Number))
+		if err != nil {
+			return 0, fmt.Errorf("could not get header %v: %w", cp.Number, err)
+		}
+
+		if header.Hash() != cp.Hash {
+			continue
+		}
+
+		if n > 0 {
+			if err := i.storage.RollbackIndexer(cp.Number + 1); err != nil {
+				return 0, fmt.Errorf("could not roll back to block %v: %w", cp.Number, err)
+			}
+			i.bus.Publish(RolledBack{From: cp.Number + 1})
+		}
+
+		return cp.Number + 1, nil
+	}
+
+	return 0, ErrReorgTooDeep
+}
+
+func (i *Indexer) processRange(from, to uint64) error {
+	before, err := i.client.HeaderByNumber(new(big.Int).SetUint64(to))
+	if err != nil {
+		return fmt.Errorf("could not get header %v: %w", to, err)
+	}
+
+	q := i.opts.Query
+	q.BlockHash = nil
+	q.FromBlock = new(big.Int).SetUint64(from)
+	q.ToBlock = new(big.Int).SetUint64(to)
+	logs, err := i.client.FilterLogs(q)
+	if err != nil {
+		if i.opts.Window != nil && i.opts.Window.Failure(to-from+1) {
+			i.log(fmt.Errorf("could not filter logs from %v to %v, shrinking window: %w", from, to, err))
+			return errWindowShrunk
+		}
+		return fmt.Errorf("could not filter logs from %v to %v: %w", from, to, err)
+	}
+	if i.opts.Window != nil {
+		i.opts.Window.Success(to-from+1, len(logs))
+	}
+
+	// Block hashes are chained, so if the last block of the range did not change,
+	// none of the blocks the logs were taken from changed either.
+	after, err := i.client.HeaderByNumber(new(big.Int).SetUint64(to))
+	if err != nil {
+		return fmt.Errorf("could not get header %v: %w", to, err)
+	}
+	if before.Hash() != after.Hash() {
+		return errChainMoved
+	}
+
+	if err := i.storage.InsertIndexerBlocks(Checkpoint{Number: to, Hash: after.Hash()}, logs); err != nil {
+		return fmt.Errorf("could not store blocks from %v to %v: %w", from, to, err)
+	}
+
+	i.bus.Publish(BlocksIndexed{From: from, To: to, Hash: after.Hash(), Logs: logs})
+
+	return i.storage.PruneIndexerCheckpoints(i.opts.Checkpoints)
+}
+
+func (i *Indexer) log(err error) {
+	if i.logFn != nil {
+		i.logFn(err)
+	}
+}
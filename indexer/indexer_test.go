@@ -0,0 +1,160 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package indexer
+
+import (
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/bus"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockChain struct {
+	headers []*types.Header
+}
+
+func newMockChain(length int, fork byte) *mockChain {
+	c := &mockChain{}
+	c.extend(length, fork)
+	return c
+}
+
+func (c *mockChain) extend(length int, fork byte) {
+	for n := len(c.headers); n < length; n++ {
+		h := &types.Header{Number: big.NewInt(int64(n)), Extra: []byte{fork}}
+		if n > 0 {
+			h.ParentHash = c.headers[n-1].Hash()
+		}
+		c.headers = append(c.headers, h)
+	}
+}
+
+func (c *mockChain) reorg(from int, fork byte) {
+	length := len(c.headers)
+	c.headers = c.headers[:from]
+	c.extend(length, fork)
+}
+
+func (c *mockChain) HeaderByNumber(number *big.Int) (*types.Header, error) {
+	if number == nil {
+		return c.headers[len(c.headers)-1], nil
+	}
+	return c.headers[number.Int64()], nil
+}
+
+func (c *mockChain) FilterLogs(q ethereum.FilterQuery) ([]types.Log, error) {
+	var logs []types.Log
+	for n := q.FromBlock.Int64(); n <= q.ToBlock.Int64(); n++ {
+		logs = append(logs, types.Log{BlockNumber: uint64(n), BlockHash: c.headers[n].Hash()})
+	}
+	return logs, nil
+}
+
+type mockStorage struct {
+	logs        []types.Log
+	checkpoints []Checkpoint
+}
+
+func (ms *mockStorage) InsertIndexerBlocks(cp Checkpoint, logs []types.Log) error {
+	ms.logs = append(ms.logs, logs...)
+	ms.checkpoints = append(ms.checkpoints, cp)
+	return nil
+}
+
+func (ms *mockStorage) GetIndexerCheckpoints() ([]Checkpoint, error) {
+	return append([]Checkpoint{}, ms.checkpoints...), nil
+}
+
+func (ms *mockStorage) PruneIndexerCheckpoints(keep int) error {
+	sort.Slice(ms.checkpoints, func(a, b int) bool { return ms.checkpoints[a].Number < ms.checkpoints[b].Number })
+	if len(ms.checkpoints) > keep {
+		ms.checkpoints = ms.checkpoints[len(ms.checkpoints)-keep:]
+	}
+	return nil
+}
+
+func (ms *mockStorage) RollbackIndexer(from uint64) error {
+	var logs []types.Log
+	for _, l := range ms.logs {
+		if l.BlockNumber < from {
+			logs = append(logs, l)
+		}
+	}
+	ms.logs = logs
+
+	var cps []Checkpoint
+	for _, cp := range ms.checkpoints {
+		if cp.Number < from {
+			cps = append(cps, cp)
+		}
+	}
+	ms.checkpoints = cps
+	return nil
+}
+
+func syncToHead(t *testing.T, i *Indexer) {
+	for {
+		caughtUp, err := i.Sync()
+		assert.NoError(t, err)
+		if caughtUp {
+			return
+		}
+	}
+}
+
+func TestIndexerRollsBackOrphanedBlocks(t *testing.T) {
+	chain := newMockChain(20, 0)
+	st := &mockStorage{}
+	i := NewIndexer(chain, st, Opts{BatchSize: 3, Checkpoints: 5})
+
+	b := bus.New()
+	var indexed []BlocksIndexed
+	var rolledBack []RolledBack
+	b.Subscribe(TopicBlocksIndexed, func(e bus.Event) { indexed = append(indexed, e.(BlocksIndexed)) })
+	b.Subscribe(TopicRolledBack, func(e bus.Event) { rolledBack = append(rolledBack, e.(RolledBack)) })
+	i.AttachBus(b)
+
+	syncToHead(t, i)
+	assert.Len(t, st.logs, 20)
+	assert.Len(t, indexed, 7)
+	assert.Equal(t, BlocksIndexed{From: 18, To: 19, Hash: chain.headers[19].Hash(), Logs: st.logs[18:]}, indexed[6])
+
+	chain.reorg(15, 1)
+	syncToHead(t, i)
+	assert.Equal(t, []RolledBack{{From: 15}}, rolledBack)
+
+	assert.Len(t, st.logs, 20)
+	for _, l := range st.logs {
+		assert.Equal(t, chain.headers[l.BlockNumber].Hash(), l.BlockHash, "block %v is orphaned", l.BlockNumber)
+	}
+}
+
+func TestIndexerFailsOnTooDeepReorg(t *testing.T) {
+	chain := newMockChain(20, 0)
+	st := &mockStorage{}
+	i := NewIndexer(chain, st, Opts{BatchSize: 3, Checkpoints: 2})
+
+	syncToHead(t, i)
+
+	chain.reorg(5, 1)
+	_, err := i.Sync()
+	assert.Equal(t, ErrReorgTooDeep, err)
+}
@@ -0,0 +1,339 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package ledger turns myst token transfers of tracked channel and beneficiary addresses into double-entry ledger entries.
+package ledger
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/analytics"
+	"github.com/mysteriumnetwork/payments/bindings"
+)
+
+// EntryKind classifies a ledger entry.
+type EntryKind string
+
+const (
+	// KindTopUp is a transfer into a tracked address from anyone but a hermes.
+	KindTopUp EntryKind = "top_up"
+	// KindSettlementPayout is a transfer from a hermes into a tracked address.
+	KindSettlementPayout EntryKind = "settlement_payout"
+	// KindSettlement is a transfer from a tracked address to a hermes, e.g. a consumer channel settlement.
+	KindSettlement EntryKind = "settlement"
+	// KindFee is a transfer from a tracked address to a transactor.
+	KindFee EntryKind = "fee"
+	// KindWithdrawal is any other transfer out of a tracked address.
+	KindWithdrawal EntryKind = "withdrawal"
+)
+
+// Role is the role of a tracked address.
+type Role string
+
+const (
+	// RoleChannel is a consumer channel.
+	RoleChannel Role = "channel"
+	// RoleBeneficiary is a provider beneficiary.
+	RoleBeneficiary Role = "beneficiary"
+)
+
+const (
+	accountHermes     = "hermes"
+	accountTransactor = "transactor"
+	accountExternal   = "external"
+)
+
+// Entry is a single ledger entry of an identity. The amount is debited to the receiving account and credited to the sending one.
+type Entry struct {
+	Identity      common.Address
+	TxHash        common.Hash
+	LogIndex      uint
+	BlockNumber   uint64
+	Time          time.Time
+	Kind          EntryKind
+	From          common.Address
+	To            common.Address
+	Amount        *big.Int
+	DebitAccount  string
+	CreditAccount string
+}
+
+// Line is one side of a double-entry ledger entry.
+type Line struct {
+	Account string
+	Debit   *big.Int
+	Credit  *big.Int
+}
+
+// Lines returns the balanced debit and credit lines of the entry.
+func (e Entry) Lines() []Line {
+	return []Line{
+		{Account: e.DebitAccount, Debit: new(big.Int).Set(e.Amount), Credit: new(big.Int)},
+		{Account: e.CreditAccount, Debit: new(big.Int), Credit: new(big.Int).Set(e.Amount)},
+	}
+}
+
+// Storage stores the ledger entries.
+type Storage interface {
+	// InsertLedgerEntry stores the entry. Entries that are already stored are ignored.
+	InsertLedgerEntry(e Entry) error
+	// DeleteLedgerEntries deletes the entries created for the given log, e.g. after a chain reorganisation.
+	DeleteLedgerEntries(txHash common.Hash, logIndex uint) error
+	// GetLedgerEntries returns the entries of the identity in the [from, to) time window, oldest first.
+	GetLedgerEntries(identity common.Address, from, to time.Time) ([]Entry, error)
+}
+
+// LogFunc is called with the errors of background work.
+type LogFunc func(error)
+
+type trackedAddress struct {
+	identity common.Address
+	role     Role
+}
+
+// Ledger reconciles myst token transfers into ledger entries.
+type Ledger struct {
+	storage     Storage
+	headers     analytics.HeaderGetter
+	hermeses    map[common.Address]struct{}
+	transactors map[common.Address]struct{}
+	logFunc     LogFunc
+
+	lock    sync.Mutex
+	tracked map[common.Address]trackedAddress
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewLedger returns a new ledger. Transfers from and to the given hermeses and transactors are classified as settlements and fees.
+func NewLedger(storage Storage, headers analytics.HeaderGetter, hermeses, transactors []common.Address) *Ledger {
+	return &Ledger{
+		storage:     storage,
+		headers:     headers,
+		hermeses:    toSet(hermeses),
+		transactors: toSet(transactors),
+		logFunc:     func(error) {},
+		tracked:     make(map[common.Address]trackedAddress),
+		stop:        make(chan struct{}),
+	}
+}
+
+// AttachLogFunc attaches a log func to the ledger.
+// Not thread safe, call before Consume.
+func (l *Ledger) AttachLogFunc(f LogFunc) {
+	l.logFunc = f
+}
+
+// Track adds the address of the identity to the ledger. Only transfers involving tracked addresses are recorded.
+func (l *Ledger) Track(identity, address common.Address, role Role) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.tracked[address] = trackedAddress{identity: identity, role: role}
+}
+
+// Process records the transfer. Removed transfers delete the entries recorded before.
+func (l *Ledger) Process(ev *bindings.MystTokenTransfer) error {
+	if ev.Raw.Removed {
+		return l.storage.DeleteLedgerEntries(ev.Raw.TxHash, ev.Raw.Index)
+	}
+
+	entries := l.classify(ev)
+	if len(entries) == 0 {
+		return nil
+	}
+
+	header, err := l.headers.HeaderByNumber(new(big.Int).SetUint64(ev.Raw.BlockNumber))
+	if err != nil {
+		return fmt.Errorf("could not get block %v: %w", ev.Raw.BlockNumber, err)
+	}
+
+	for _, e := range entries {
+		e.Time = time.Unix(int64(header.Time), 0).UTC()
+		if err := l.storage.InsertLedgerEntry(e); err != nil {
+			return fmt.Errorf("could not store ledger entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// Consume processes the transfers until the channel is closed or the ledger is stopped.
+// The sink of client.SubscribeToConsumerChannelBalanceUpdate can be consumed directly.
+func (l *Ledger) Consume(events <-chan *bindings.MystTokenTransfer) {
+	for {
+		select {
+		case <-l.stop:
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := l.Process(ev); err != nil {
+				l.logFunc(err)
+			}
+		}
+	}
+}
+
+// Stop stops consuming transfers.
+func (l *Ledger) Stop() {
+	l.once.Do(func() {
+		close(l.stop)
+	})
+}
+
+func (l *Ledger) classify(ev *bindings.MystTokenTransfer) []Entry {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	base := Entry{
+		TxHash:      ev.Raw.TxHash,
+		LogIndex:    ev.Raw.Index,
+		BlockNumber: ev.Raw.BlockNumber,
+		From:        ev.From,
+		To:          ev.To,
+		Amount:      new(big.Int).Set(ev.Value),
+	}
+	base.DebitAccount = l.account(ev.To)
+	base.CreditAccount = l.account(ev.From)
+
+	var res []Entry
+	if to, ok := l.tracked[ev.To]; ok {
+		e := base
+		e.Identity = to.identity
+		e.Kind = KindTopUp
+		if _, ok := l.hermeses[ev.From]; ok {
+			e.Kind = KindSettlementPayout
+		}
+		res = append(res, e)
+	}
+	if from, ok := l.tracked[ev.From]; ok && (len(res) == 0 || from.identity != res[0].Identity) {
+		e := base
+		e.Identity = from.identity
+		e.Kind = KindWithdrawal
+		if _, ok := l.hermeses[ev.To]; ok {
+			e.Kind = KindSettlement
+		} else if _, ok := l.transactors[ev.To]; ok {
+			e.Kind = KindFee
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+func (l *Ledger) account(address common.Address) string {
+	if t, ok := l.tracked[address]; ok {
+		return string(t.role) + ":" + address.Hex()
+	}
+	if _, ok := l.hermeses[address]; ok {
+		return accountHermes + ":" + address.Hex()
+	}
+	if _, ok := l.transactors[address]; ok {
+		return accountTransactor + ":" + address.Hex()
+	}
+	return accountExternal + ":" + address.Hex()
+}
+
+// Statement is the bank statement style summary of an identity over a time window.
+type Statement struct {
+	Identity common.Address
+	From     time.Time
+	To       time.Time
+	Entries  []Entry
+	// Totals sums the entry amounts per kind.
+	Totals map[EntryKind]*big.Int
+}
+
+// Statement returns the entries of the identity in the [from, to) time window.
+func (l *Ledger) Statement(identity common.Address, from, to time.Time) (Statement, error) {
+	entries, err := l.storage.GetLedgerEntries(identity, from, to)
+	if err != nil {
+		return Statement{}, err
+	}
+
+	res := Statement{
+		Identity: identity,
+		From:     from,
+		To:       to,
+		Entries:  entries,
+		Totals:   make(map[EntryKind]*big.Int),
+	}
+	for _, e := range entries {
+		if res.Totals[e.Kind] == nil {
+			res.Totals[e.Kind] = new(big.Int)
+		}
+		res.Totals[e.Kind].Add(res.Totals[e.Kind], e.Amount)
+	}
+	return res, nil
+}
+
+// StatementColumns is the stable column schema of exported ledger lines.
+// New columns may only be appended to keep existing pipelines working.
+var StatementColumns = []string{
+	"time",
+	"block_number",
+	"tx_hash",
+	"log_index",
+	"kind",
+	"account",
+	"debit",
+	"credit",
+}
+
+// ExportStatement writes the ledger lines of the identity in the [from, to) time window, two lines per entry.
+func (l *Ledger) ExportStatement(w analytics.RowWriter, identity common.Address, from, to time.Time) error {
+	if err := w.WriteHeader(StatementColumns); err != nil {
+		return fmt.Errorf("could not write header: %w", err)
+	}
+
+	entries, err := l.storage.GetLedgerEntries(identity, from, to)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		for _, line := range e.Lines() {
+			row := []string{
+				e.Time.UTC().Format(time.RFC3339),
+				strconv.FormatUint(e.BlockNumber, 10),
+				e.TxHash.Hex(),
+				strconv.FormatUint(uint64(e.LogIndex), 10),
+				string(e.Kind),
+				line.Account,
+				line.Debit.String(),
+				line.Credit.String(),
+			}
+			if err := w.WriteRow(row); err != nil {
+				return fmt.Errorf("could not write row: %w", err)
+			}
+		}
+	}
+
+	return w.Flush()
+}
+
+func toSet(addresses []common.Address) map[common.Address]struct{} {
+	res := make(map[common.Address]struct{}, len(addresses))
+	for _, a := range addresses {
+		res[a] = struct{}{}
+	}
+	return res
+}
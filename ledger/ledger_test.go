@@ -0,0 +1,132 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ledger
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/analytics"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockHeaders struct{}
+
+func (mockHeaders) HeaderByNumber(number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: number, Time: 1000 + number.Uint64()*10}, nil
+}
+
+type mockStorage struct {
+	entries []Entry
+}
+
+func (ms *mockStorage) InsertLedgerEntry(e Entry) error {
+	for _, existing := range ms.entries {
+		if existing.TxHash == e.TxHash && existing.LogIndex == e.LogIndex && existing.Identity == e.Identity {
+			return nil
+		}
+	}
+	ms.entries = append(ms.entries, e)
+	return nil
+}
+
+func (ms *mockStorage) DeleteLedgerEntries(txHash common.Hash, logIndex uint) error {
+	var res []Entry
+	for _, e := range ms.entries {
+		if e.TxHash != txHash || e.LogIndex != logIndex {
+			res = append(res, e)
+		}
+	}
+	ms.entries = res
+	return nil
+}
+
+func (ms *mockStorage) GetLedgerEntries(identity common.Address, from, to time.Time) ([]Entry, error) {
+	var res []Entry
+	for _, e := range ms.entries {
+		if e.Identity == identity && !e.Time.Before(from) && e.Time.Before(to) {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+func transfer(from, to common.Address, value int64, block uint64, index uint) *bindings.MystTokenTransfer {
+	return &bindings.MystTokenTransfer{
+		From:  from,
+		To:    to,
+		Value: big.NewInt(value),
+		Raw:   types.Log{BlockNumber: block, Index: index, TxHash: common.BigToHash(big.NewInt(int64(block)))},
+	}
+}
+
+func TestLedger(t *testing.T) {
+	identity := common.HexToAddress("0x1")
+	channel := common.HexToAddress("0xc")
+	beneficiary := common.HexToAddress("0xb")
+	hermes := common.HexToAddress("0xa")
+	transactor := common.HexToAddress("0xf")
+	other := common.HexToAddress("0xe")
+
+	storage := &mockStorage{}
+	l := NewLedger(storage, mockHeaders{}, []common.Address{hermes}, []common.Address{transactor})
+	l.Track(identity, channel, RoleChannel)
+	l.Track(identity, beneficiary, RoleBeneficiary)
+
+	events := []*bindings.MystTokenTransfer{
+		transfer(other, channel, 100, 1, 0),
+		transfer(channel, hermes, 30, 2, 0),
+		transfer(channel, transactor, 1, 2, 1),
+		transfer(hermes, beneficiary, 20, 3, 0),
+		transfer(other, hermes, 5, 3, 1),
+		transfer(hermes, beneficiary, 20, 3, 0),
+	}
+	for _, ev := range events {
+		assert.NoError(t, l.Process(ev))
+	}
+
+	s, err := l.Statement(identity, time.Unix(0, 0), time.Unix(2000, 0))
+	assert.NoError(t, err)
+	assert.Len(t, s.Entries, 4)
+	assert.Equal(t, big.NewInt(100), s.Totals[KindTopUp])
+	assert.Equal(t, big.NewInt(30), s.Totals[KindSettlement])
+	assert.Equal(t, big.NewInt(1), s.Totals[KindFee])
+	assert.Equal(t, big.NewInt(20), s.Totals[KindSettlementPayout])
+	assert.Equal(t, "channel:"+channel.Hex(), s.Entries[0].DebitAccount)
+	assert.Equal(t, "external:"+other.Hex(), s.Entries[0].CreditAccount)
+
+	removed := transfer(hermes, beneficiary, 20, 3, 0)
+	removed.Raw.Removed = true
+	assert.NoError(t, l.Process(removed))
+	s, err = l.Statement(identity, time.Unix(0, 0), time.Unix(2000, 0))
+	assert.NoError(t, err)
+	assert.Len(t, s.Entries, 3)
+
+	var buf bytes.Buffer
+	assert.NoError(t, l.ExportStatement(analytics.NewCSVWriter(&buf), identity, time.Unix(0, 0), time.Unix(2000, 0)))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 7)
+	assert.Equal(t, strings.Join(StatementColumns, ","), lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], ",top_up,channel:"+channel.Hex()+",100,0"))
+	assert.True(t, strings.HasSuffix(lines[2], ",top_up,external:"+other.Hex()+",0,100"))
+}
@@ -0,0 +1,251 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrSubsidyCapExceeded is returned when sponsoring a transaction would exceed the subsidy cap of the identity.
+var ErrSubsidyCapExceeded = errors.New("identity subsidy cap exceeded")
+
+// ErrTxNotMined is returned when recording the sponsorship of a transaction that has no receipt yet.
+var ErrTxNotMined = errors.New("sponsored transaction is not mined")
+
+// Sponsorship records the gas a sponsor, e.g. a transactor or a relayer, paid on behalf of an identity.
+type Sponsorship struct {
+	Sponsor     common.Address
+	Identity    common.Address
+	TxHash      common.Hash
+	BlockNumber uint64
+	Time        time.Time
+	// Operation describes the sponsored transaction, e.g. register or settle.
+	Operation string
+	GasUsed   uint64
+	GasPrice  *big.Int
+	// Cost is the gas cost paid by the sponsor, in wei.
+	Cost *big.Int
+	// Fee is the myst fee the identity paid the sponsor for the transaction, if any.
+	Fee *big.Int
+	// Failed is set for reverted transactions, the sponsor pays the gas for those as well.
+	Failed bool
+}
+
+// SponsorshipStorage stores the sponsorships.
+type SponsorshipStorage interface {
+	// InsertSponsorship stores the sponsorship. Sponsorships of already stored transactions are ignored.
+	InsertSponsorship(s Sponsorship) error
+	// DeleteSponsorship deletes the sponsorship of the transaction, e.g. after a chain reorganisation.
+	DeleteSponsorship(txHash common.Hash) error
+	// GetSponsorships returns the sponsorships of the sponsor in the [from, to) time window, oldest first.
+	GetSponsorships(sponsor common.Address, from, to time.Time) ([]Sponsorship, error)
+	// GetIdentitySponsorships returns the sponsorships of the identity by the sponsor in the [from, to) time window, oldest first.
+	GetIdentitySponsorships(sponsor, identity common.Address, from, to time.Time) ([]Sponsorship, error)
+}
+
+// ReceiptGetter returns the receipts of mined transactions. The BC client can be used.
+type ReceiptGetter interface {
+	TransactionReceipt(hash common.Hash) (*types.Receipt, error)
+	HeaderByNumber(number *big.Int) (*types.Header, error)
+}
+
+// SponsoredTx is a transaction a sponsor sent on behalf of an identity.
+type SponsoredTx struct {
+	Tx        *types.Transaction
+	Sponsor   common.Address
+	Identity  common.Address
+	Operation string
+	Fee       *big.Int
+}
+
+// SubsidyCap limits the gas cost a sponsor pays for a single identity in a rolling time window.
+type SubsidyCap struct {
+	Amount *big.Int
+	Window time.Duration
+}
+
+// Sponsorships accounts the gas paid by sponsors on behalf of identities.
+type Sponsorships struct {
+	storage  SponsorshipStorage
+	receipts ReceiptGetter
+
+	lock sync.Mutex
+	caps map[common.Address]SubsidyCap
+	now  func() time.Time
+}
+
+// NewSponsorships returns a new sponsorship accounting.
+func NewSponsorships(storage SponsorshipStorage, receipts ReceiptGetter) *Sponsorships {
+	return &Sponsorships{
+		storage:  storage,
+		receipts: receipts,
+		caps:     make(map[common.Address]SubsidyCap),
+		now:      time.Now,
+	}
+}
+
+// Record stores the sponsorship of the mined transaction. The gas cost is taken from the transaction receipt.
+func (s *Sponsorships) Record(st SponsoredTx) (Sponsorship, error) {
+	receipt, err := s.receipts.TransactionReceipt(st.Tx.Hash())
+	if err != nil {
+		return Sponsorship{}, fmt.Errorf("could not get receipt of %v: %w", st.Tx.Hash().Hex(), err)
+	}
+	if receipt == nil || receipt.BlockNumber == nil {
+		return Sponsorship{}, ErrTxNotMined
+	}
+
+	header, err := s.receipts.HeaderByNumber(receipt.BlockNumber)
+	if err != nil {
+		return Sponsorship{}, fmt.Errorf("could not get block %v: %w", receipt.BlockNumber, err)
+	}
+
+	fee := new(big.Int)
+	if st.Fee != nil {
+		fee.Set(st.Fee)
+	}
+
+	sp := Sponsorship{
+		Sponsor:     st.Sponsor,
+		Identity:    st.Identity,
+		TxHash:      st.Tx.Hash(),
+		BlockNumber: receipt.BlockNumber.Uint64(),
+		Time:        time.Unix(int64(header.Time), 0).UTC(),
+		Operation:   st.Operation,
+		GasUsed:     receipt.GasUsed,
+		GasPrice:    new(big.Int).Set(st.Tx.GasPrice()),
+		Cost:        new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), st.Tx.GasPrice()),
+		Fee:         fee,
+		Failed:      receipt.Status != types.ReceiptStatusSuccessful,
+	}
+	if err := s.storage.InsertSponsorship(sp); err != nil {
+		return Sponsorship{}, fmt.Errorf("could not store sponsorship: %w", err)
+	}
+	return sp, nil
+}
+
+// Remove deletes the sponsorship of the transaction, e.g. after it was dropped by a chain reorganisation.
+func (s *Sponsorships) Remove(txHash common.Hash) error {
+	return s.storage.DeleteSponsorship(txHash)
+}
+
+// SetCap sets the per identity subsidy cap of the sponsor. A nil cap amount removes the cap.
+func (s *Sponsorships) SetCap(sponsor common.Address, c SubsidyCap) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if c.Amount == nil {
+		delete(s.caps, sponsor)
+		return
+	}
+	s.caps[sponsor] = c
+}
+
+// Allow checks whether the sponsor may pay the estimated gas cost for the identity without exceeding its subsidy cap.
+// ErrSubsidyCapExceeded is returned if it may not.
+func (s *Sponsorships) Allow(sponsor, identity common.Address, estimatedCost *big.Int) error {
+	s.lock.Lock()
+	c, ok := s.caps[sponsor]
+	now := s.now()
+	s.lock.Unlock()
+	if !ok {
+		return nil
+	}
+
+	spent, err := s.Subsidy(sponsor, identity, now.Add(-c.Window), now.Add(time.Nanosecond))
+	if err != nil {
+		return err
+	}
+	if new(big.Int).Add(spent, estimatedCost).Cmp(c.Amount) > 0 {
+		return fmt.Errorf("%w: spent %v of %v", ErrSubsidyCapExceeded, spent, c.Amount)
+	}
+	return nil
+}
+
+// Subsidy returns the net gas cost the sponsor paid for the identity in the [from, to) time window.
+// Fees paid by the identity are in myst and are not deducted.
+func (s *Sponsorships) Subsidy(sponsor, identity common.Address, from, to time.Time) (*big.Int, error) {
+	sponsorships, err := s.storage.GetIdentitySponsorships(sponsor, identity, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	res := new(big.Int)
+	for _, sp := range sponsorships {
+		res.Add(res, sp.Cost)
+	}
+	return res, nil
+}
+
+// InvoiceLine sums the sponsorships of a single identity.
+type InvoiceLine struct {
+	Identity     common.Address
+	Transactions int
+	GasUsed      uint64
+	Cost         *big.Int
+	Fees         *big.Int
+}
+
+// Invoice sums the sponsorships of a sponsor over a time window per identity.
+type Invoice struct {
+	Sponsor common.Address
+	From    time.Time
+	To      time.Time
+	// Lines are ordered by the first sponsored transaction of each identity.
+	Lines []InvoiceLine
+	Cost  *big.Int
+	Fees  *big.Int
+}
+
+// Invoice returns the sponsorships of the sponsor in the [from, to) time window grouped by identity.
+func (s *Sponsorships) Invoice(sponsor common.Address, from, to time.Time) (Invoice, error) {
+	sponsorships, err := s.storage.GetSponsorships(sponsor, from, to)
+	if err != nil {
+		return Invoice{}, err
+	}
+
+	res := Invoice{
+		Sponsor: sponsor,
+		From:    from,
+		To:      to,
+		Cost:    new(big.Int),
+		Fees:    new(big.Int),
+	}
+	index := make(map[common.Address]int)
+	for _, sp := range sponsorships {
+		i, ok := index[sp.Identity]
+		if !ok {
+			i = len(res.Lines)
+			index[sp.Identity] = i
+			res.Lines = append(res.Lines, InvoiceLine{Identity: sp.Identity, Cost: new(big.Int), Fees: new(big.Int)})
+		}
+
+		line := &res.Lines[i]
+		line.Transactions++
+		line.GasUsed += sp.GasUsed
+		line.Cost.Add(line.Cost, sp.Cost)
+		line.Fees.Add(line.Fees, sp.Fee)
+		res.Cost.Add(res.Cost, sp.Cost)
+		res.Fees.Add(res.Fees, sp.Fee)
+	}
+	return res, nil
+}
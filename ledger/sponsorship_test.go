@@ -0,0 +1,126 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ledger
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSponsorshipStorage struct {
+	sponsorships []Sponsorship
+}
+
+func (ms *mockSponsorshipStorage) InsertSponsorship(s Sponsorship) error {
+	ms.sponsorships = append(ms.sponsorships, s)
+	return nil
+}
+
+func (ms *mockSponsorshipStorage) DeleteSponsorship(txHash common.Hash) error {
+	var res []Sponsorship
+	for _, s := range ms.sponsorships {
+		if s.TxHash != txHash {
+			res = append(res, s)
+		}
+	}
+	ms.sponsorships = res
+	return nil
+}
+
+func (ms *mockSponsorshipStorage) GetSponsorships(sponsor common.Address, from, to time.Time) ([]Sponsorship, error) {
+	var res []Sponsorship
+	for _, s := range ms.sponsorships {
+		if s.Sponsor == sponsor && !s.Time.Before(from) && s.Time.Before(to) {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+func (ms *mockSponsorshipStorage) GetIdentitySponsorships(sponsor, identity common.Address, from, to time.Time) ([]Sponsorship, error) {
+	all, _ := ms.GetSponsorships(sponsor, from, to)
+	var res []Sponsorship
+	for _, s := range all {
+		if s.Identity == identity {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+type mockReceipts struct {
+	mockHeaders
+	receipts map[common.Hash]*types.Receipt
+}
+
+func (mr mockReceipts) TransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	return mr.receipts[hash], nil
+}
+
+func TestSponsorships(t *testing.T) {
+	sponsor := common.HexToAddress("0x1")
+	alice, bob := common.HexToAddress("0xa"), common.HexToAddress("0xb")
+
+	receipts := mockReceipts{receipts: make(map[common.Hash]*types.Receipt)}
+	storage := &mockSponsorshipStorage{}
+	sp := NewSponsorships(storage, receipts)
+
+	send := func(nonce uint64, identity common.Address, block int64, fee int64) (Sponsorship, error) {
+		tx := types.NewTransaction(nonce, identity, big.NewInt(0), 100000, big.NewInt(10), nil)
+		receipts.receipts[tx.Hash()] = &types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 1000, BlockNumber: big.NewInt(block)}
+		return sp.Record(SponsoredTx{Tx: tx, Sponsor: sponsor, Identity: identity, Operation: "settle", Fee: big.NewInt(fee)})
+	}
+
+	first, err := send(0, alice, 1, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(10000), first.Cost)
+	assert.Equal(t, time.Unix(1010, 0).UTC(), first.Time)
+	_, err = send(1, bob, 2, 0)
+	assert.NoError(t, err)
+	_, err = send(2, alice, 3, 7)
+	assert.NoError(t, err)
+
+	_, err = sp.Record(SponsoredTx{Tx: types.NewTransaction(9, alice, big.NewInt(0), 1, big.NewInt(1), nil)})
+	assert.Equal(t, ErrTxNotMined, err)
+
+	invoice, err := sp.Invoice(sponsor, time.Unix(0, 0), time.Unix(2000, 0))
+	assert.NoError(t, err)
+	assert.Len(t, invoice.Lines, 2)
+	assert.Equal(t, alice, invoice.Lines[0].Identity)
+	assert.Equal(t, 2, invoice.Lines[0].Transactions)
+	assert.Equal(t, big.NewInt(20000), invoice.Lines[0].Cost)
+	assert.Equal(t, big.NewInt(12), invoice.Lines[0].Fees)
+	assert.Equal(t, big.NewInt(30000), invoice.Cost)
+
+	sp.now = func() time.Time { return time.Unix(1030, 0) }
+	sp.SetCap(sponsor, SubsidyCap{Amount: big.NewInt(25000), Window: time.Minute})
+	assert.NoError(t, sp.Allow(sponsor, alice, big.NewInt(5000)))
+	assert.True(t, errors.Is(sp.Allow(sponsor, alice, big.NewInt(5001)), ErrSubsidyCapExceeded))
+	assert.NoError(t, sp.Allow(sponsor, bob, big.NewInt(15000)))
+
+	assert.NoError(t, sp.Remove(first.TxHash))
+	assert.NoError(t, sp.Allow(sponsor, alice, big.NewInt(15000)))
+
+	sp.SetCap(sponsor, SubsidyCap{})
+	assert.NoError(t, sp.Allow(sponsor, alice, big.NewInt(1e9)))
+}
@@ -0,0 +1,228 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package migration detects consumer channels deployed with an outdated channel implementation
+// and moves their funds to the channel of the identity on the latest implementation.
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+// ErrNothingToDo is returned when executing the next step of a channel that needs no transaction now.
+var ErrNothingToDo = errors.New("no migration transaction to send")
+
+// Chain reads the channel state. The client can be used.
+type Chain interface {
+	GetProxyImplementation(proxy common.Address) (common.Address, error)
+	GetLatestChannelImplementation(registryAddress common.Address) (common.Address, error)
+	GetMystBalance(mystAddress, identity common.Address) (*big.Int, error)
+	GetChannelExit(channelAddress common.Address) (client.ChannelExit, error)
+	HeaderByNumber(number *big.Int) (*types.Header, error)
+}
+
+// Executor sends the migration transactions. The client can be used.
+type Executor interface {
+	RequestChannelExit(req client.RequestChannelExitRequest) (*types.Transaction, error)
+	FinalizeChannelExit(req client.FinalizeChannelExitRequest) (*types.Transaction, error)
+}
+
+// HashSigner signs hashes with the identity key.
+type HashSigner interface {
+	SignHash(a accounts.Account, hash []byte) ([]byte, error)
+}
+
+// Stage is the migration state of a channel.
+type Stage string
+
+const (
+	// StageNotDeployed channels have no contract, there is nothing to migrate.
+	StageNotDeployed Stage = "not_deployed"
+	// StageUpToDate channels use the latest implementation.
+	StageUpToDate Stage = "up_to_date"
+	// StageRequestExit channels are outdated and hold funds, the exit to the new channel has to be requested.
+	StageRequestExit Stage = "request_exit"
+	// StageWaitTimelock channels have the exit requested and wait for the timelock.
+	StageWaitTimelock Stage = "wait_timelock"
+	// StageFinalizeExit channels have the timelock passed, the exit can be finalized.
+	StageFinalizeExit Stage = "finalize_exit"
+	// StageMigrated channels are outdated but empty.
+	StageMigrated Stage = "migrated"
+)
+
+// Config describes the deployment.
+type Config struct {
+	Registry  common.Address
+	MystToken common.Address
+	// ExitValidity is the number of blocks a signed exit request stays valid for.
+	ExitValidity uint64
+}
+
+// Status is the migration state of a consumer channel.
+type Status struct {
+	Identity common.Address
+	HermesID common.Address
+	Channel  common.Address
+	// Implementation is the implementation the channel was deployed with.
+	Implementation       common.Address
+	LatestImplementation common.Address
+	// NewChannel is the channel of the identity on the latest implementation, the funds are moved to it.
+	NewChannel common.Address
+	Balance    *big.Int
+	Exit       client.ChannelExit
+	Block      uint64
+	Stage      Stage
+}
+
+// Outdated returns true if the channel was deployed with an older implementation than the registry uses.
+func (s Status) Outdated() bool {
+	return s.Stage != StageNotDeployed && s.Implementation != s.LatestImplementation
+}
+
+// Guidance describes what the channel owner should do next.
+func (s Status) Guidance() string {
+	switch s.Stage {
+	case StageNotDeployed:
+		return "the channel is not deployed, nothing to migrate"
+	case StageUpToDate:
+		return "the channel uses the latest implementation"
+	case StageRequestExit:
+		return fmt.Sprintf("request the exit of %v MYST to the new channel %v", crypto.BigMystToFloat(s.Balance), s.NewChannel.Hex())
+	case StageWaitTimelock:
+		return fmt.Sprintf("wait for block %v to finalize the exit", s.Exit.Timelock)
+	case StageFinalizeExit:
+		return fmt.Sprintf("finalize the exit to %v", s.Exit.Beneficiary.Hex())
+	case StageMigrated:
+		return "the outdated channel is empty, use the new channel " + s.NewChannel.Hex()
+	default:
+		return "unknown stage"
+	}
+}
+
+// Migrator detects outdated channels and drives their migration.
+type Migrator struct {
+	chain    Chain
+	executor Executor
+	cfg      Config
+}
+
+// NewMigrator returns a new channel migrator.
+func NewMigrator(chain Chain, executor Executor, cfg Config) *Migrator {
+	return &Migrator{
+		chain:    chain,
+		executor: executor,
+		cfg:      cfg,
+	}
+}
+
+// Check returns the migration state of the consumer channel of the identity.
+func (m *Migrator) Check(identity, hermesID, channel common.Address) (Status, error) {
+	s := Status{Identity: identity, HermesID: hermesID, Channel: channel, Balance: new(big.Int)}
+
+	latest, err := m.chain.GetLatestChannelImplementation(m.cfg.Registry)
+	if err != nil {
+		return Status{}, fmt.Errorf("could not get latest channel implementation: %w", err)
+	}
+	s.LatestImplementation = latest
+
+	newChannel, err := crypto.GenerateChannelAddress(identity.Hex(), hermesID.Hex(), m.cfg.Registry.Hex(), latest.Hex())
+	if err != nil {
+		return Status{}, err
+	}
+	s.NewChannel = common.HexToAddress(newChannel)
+
+	s.Implementation, err = m.chain.GetProxyImplementation(channel)
+	if errors.Is(err, client.ErrNotProxy) {
+		s.Stage = StageNotDeployed
+		return s, nil
+	}
+	if err != nil {
+		return Status{}, fmt.Errorf("could not get channel implementation: %w", err)
+	}
+	if s.Implementation == latest {
+		s.Stage = StageUpToDate
+		return s, nil
+	}
+
+	s.Balance, err = m.chain.GetMystBalance(m.cfg.MystToken, channel)
+	if err != nil {
+		return Status{}, fmt.Errorf("could not get channel balance: %w", err)
+	}
+	s.Exit, err = m.chain.GetChannelExit(channel)
+	if err != nil {
+		return Status{}, fmt.Errorf("could not get channel exit: %w", err)
+	}
+	head, err := m.chain.HeaderByNumber(nil)
+	if err != nil {
+		return Status{}, fmt.Errorf("could not get head: %w", err)
+	}
+	s.Block = head.Number.Uint64()
+
+	switch {
+	case s.Balance.Sign() == 0:
+		s.Stage = StageMigrated
+	case s.Exit.Timelock != nil && s.Exit.Timelock.Sign() > 0:
+		if new(big.Int).SetUint64(s.Block).Cmp(s.Exit.Timelock) >= 0 {
+			s.Stage = StageFinalizeExit
+		} else {
+			s.Stage = StageWaitTimelock
+		}
+	default:
+		s.Stage = StageRequestExit
+	}
+	return s, nil
+}
+
+// Next sends the transaction of the current stage: the exit request, signed by the identity, or the exit finalization.
+// The write request sends the transaction, it does not have to be the identity.
+// ErrNothingToDo is returned for the other stages.
+func (m *Migrator) Next(s Status, wr client.WriteRequest, ks HashSigner) (*types.Transaction, error) {
+	switch s.Stage {
+	case StageRequestExit:
+		validUntil := new(big.Int).SetUint64(s.Block + m.cfg.ExitValidity)
+		exit := crypto.NewExitRequest(s.Channel, s.NewChannel, validUntil)
+		sig, err := exit.CreateSignature(ks, s.Identity)
+		if err != nil {
+			return nil, fmt.Errorf("could not sign exit request: %w", err)
+		}
+		if err := crypto.ReformatSignatureVForBC(sig); err != nil {
+			return nil, err
+		}
+
+		return m.executor.RequestChannelExit(client.RequestChannelExitRequest{
+			WriteRequest:   wr,
+			ChannelAddress: s.Channel,
+			Beneficiary:    s.NewChannel,
+			ValidUntil:     validUntil,
+			Signature:      sig,
+		})
+	case StageFinalizeExit:
+		return m.executor.FinalizeChannelExit(client.FinalizeChannelExitRequest{
+			WriteRequest:   wr,
+			ChannelAddress: s.Channel,
+		})
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrNothingToDo, s.Stage)
+	}
+}
@@ -0,0 +1,147 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package migration
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockChain struct {
+	impl    common.Address
+	latest  common.Address
+	balance *big.Int
+	exit    client.ChannelExit
+	head    uint64
+
+	exitRequests []client.RequestChannelExitRequest
+	finalized    []client.FinalizeChannelExitRequest
+}
+
+func (mc *mockChain) GetProxyImplementation(proxy common.Address) (common.Address, error) {
+	if mc.impl == (common.Address{}) {
+		return common.Address{}, client.ErrNotProxy
+	}
+	return mc.impl, nil
+}
+
+func (mc *mockChain) GetLatestChannelImplementation(registryAddress common.Address) (common.Address, error) {
+	return mc.latest, nil
+}
+
+func (mc *mockChain) GetMystBalance(mystAddress, identity common.Address) (*big.Int, error) {
+	return mc.balance, nil
+}
+
+func (mc *mockChain) GetChannelExit(channelAddress common.Address) (client.ChannelExit, error) {
+	return mc.exit, nil
+}
+
+func (mc *mockChain) HeaderByNumber(number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: new(big.Int).SetUint64(mc.head)}, nil
+}
+
+func (mc *mockChain) RequestChannelExit(req client.RequestChannelExitRequest) (*types.Transaction, error) {
+	mc.exitRequests = append(mc.exitRequests, req)
+	return types.NewTransaction(0, req.ChannelAddress, nil, 0, nil, nil), nil
+}
+
+func (mc *mockChain) FinalizeChannelExit(req client.FinalizeChannelExitRequest) (*types.Transaction, error) {
+	mc.finalized = append(mc.finalized, req)
+	return types.NewTransaction(0, req.ChannelAddress, nil, 0, nil, nil), nil
+}
+
+type keySigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (ks keySigner) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
+	return ethcrypto.Sign(hash, ks.key)
+}
+
+func TestMigrator(t *testing.T) {
+	key, err := ethcrypto.GenerateKey()
+	assert.NoError(t, err)
+	identity := ethcrypto.PubkeyToAddress(key.PublicKey)
+	ks := keySigner{key: key}
+
+	hermesID := common.HexToAddress("0x1")
+	channel := common.HexToAddress("0x2")
+	chain := &mockChain{latest: common.HexToAddress("0x4"), balance: big.NewInt(10), head: 100}
+	m := NewMigrator(chain, chain, Config{Registry: common.HexToAddress("0x5"), ExitValidity: 50})
+
+	s, err := m.Check(identity, hermesID, channel)
+	assert.NoError(t, err)
+	assert.Equal(t, StageNotDeployed, s.Stage)
+	assert.False(t, s.Outdated())
+
+	chain.impl = chain.latest
+	s, err = m.Check(identity, hermesID, channel)
+	assert.NoError(t, err)
+	assert.Equal(t, StageUpToDate, s.Stage)
+
+	chain.impl = common.HexToAddress("0x3")
+	s, err = m.Check(identity, hermesID, channel)
+	assert.NoError(t, err)
+	assert.Equal(t, StageRequestExit, s.Stage)
+	assert.True(t, s.Outdated())
+	expected, err := crypto.GenerateChannelAddress(identity.Hex(), hermesID.Hex(), "0x0000000000000000000000000000000000000005", chain.latest.Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, common.HexToAddress(expected), s.NewChannel)
+
+	_, err = m.Next(s, client.WriteRequest{}, ks)
+	assert.NoError(t, err)
+	assert.Len(t, chain.exitRequests, 1)
+	req := chain.exitRequests[0]
+	assert.Equal(t, s.NewChannel, req.Beneficiary)
+	assert.Equal(t, big.NewInt(150), req.ValidUntil)
+	exit := crypto.NewExitRequest(channel, s.NewChannel, req.ValidUntil)
+	exit.Signature = req.Signature
+	signer, err := exit.RecoverSigner()
+	assert.NoError(t, err)
+	assert.Equal(t, identity, signer)
+
+	chain.exit = client.ChannelExit{Timelock: big.NewInt(110), Beneficiary: s.NewChannel}
+	s, err = m.Check(identity, hermesID, channel)
+	assert.NoError(t, err)
+	assert.Equal(t, StageWaitTimelock, s.Stage)
+	_, err = m.Next(s, client.WriteRequest{}, ks)
+	assert.True(t, errors.Is(err, ErrNothingToDo))
+
+	chain.head = 110
+	s, err = m.Check(identity, hermesID, channel)
+	assert.NoError(t, err)
+	assert.Equal(t, StageFinalizeExit, s.Stage)
+	_, err = m.Next(s, client.WriteRequest{}, ks)
+	assert.NoError(t, err)
+	assert.Len(t, chain.finalized, 1)
+
+	chain.balance = new(big.Int)
+	s, err = m.Check(identity, hermesID, channel)
+	assert.NoError(t, err)
+	assert.Equal(t, StageMigrated, s.Stage)
+}
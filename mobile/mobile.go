@@ -0,0 +1,235 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package mobile is a flattened facade over the payments core that can be bound with gomobile
+// and embedded into Android and iOS wallets.
+// Only gomobile compatible types are used in the exported signatures: amounts are decimal strings,
+// addresses and byte arrays are 0x prefixed hex strings and durations are milliseconds.
+package mobile
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+// Wallet keeps the identity keys in an encrypted keystore directory.
+type Wallet struct {
+	ks *keystore.KeyStore
+}
+
+// NewWallet opens, or creates, the keystore in the given directory.
+// Light scrypt parameters are used, as the standard ones are too slow for mobile devices.
+func NewWallet(keystoreDir string) *Wallet {
+	return &Wallet{ks: keystore.NewKeyStore(keystoreDir, keystore.LightScryptN, keystore.LightScryptP)}
+}
+
+// NewIdentity creates a new identity protected by the passphrase and returns its address.
+func (w *Wallet) NewIdentity(passphrase string) (string, error) {
+	account, err := w.ks.NewAccount(passphrase)
+	if err != nil {
+		return "", err
+	}
+	return account.Address.Hex(), nil
+}
+
+// IdentityCount returns the number of identities in the wallet.
+func (w *Wallet) IdentityCount() int {
+	return len(w.ks.Accounts())
+}
+
+// IdentityAt returns the address of the identity at the given index.
+func (w *Wallet) IdentityAt(index int) (string, error) {
+	list := w.ks.Accounts()
+	if index < 0 || index >= len(list) {
+		return "", errors.New("identity index out of range")
+	}
+	return list[index].Address.Hex(), nil
+}
+
+// Unlock unlocks the identity for signing until Lock is called.
+func (w *Wallet) Unlock(identity, passphrase string) error {
+	account, err := w.account(identity)
+	if err != nil {
+		return err
+	}
+	return w.ks.Unlock(account, passphrase)
+}
+
+// Lock removes the unlocked identity key from memory.
+func (w *Wallet) Lock(identity string) error {
+	address, err := parseAddress(identity)
+	if err != nil {
+		return err
+	}
+	return w.ks.Lock(address)
+}
+
+func (w *Wallet) account(identity string) (accounts.Account, error) {
+	address, err := parseAddress(identity)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	return w.ks.Find(accounts.Account{Address: address})
+}
+
+// Promise is a signed payment promise.
+type Promise struct {
+	ChannelID string
+	ChainID   int64
+	Amount    string
+	Fee       string
+	Hashlock  string
+	Signature string
+}
+
+// SignPromise creates a promise of the given amount and fee, in decimal wei, signed by the unlocked identity.
+func (w *Wallet) SignPromise(identity, channelID string, chainID int64, amount, fee, hashlock string) (*Promise, error) {
+	account, err := w.account(identity)
+	if err != nil {
+		return nil, err
+	}
+	amountInt, err := parseAmount(amount)
+	if err != nil {
+		return nil, err
+	}
+	feeInt, err := parseAmount(fee)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := crypto.CreatePromise(channelID, chainID, amountInt, feeInt, hashlock, w.ks, account.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Promise{
+		ChannelID: hexutil.Encode(p.ChannelID),
+		ChainID:   p.ChainID,
+		Amount:    p.Amount.String(),
+		Fee:       p.Fee.String(),
+		Hashlock:  hexutil.Encode(p.Hashlock),
+		Signature: hexutil.Encode(p.Signature),
+	}, nil
+}
+
+// ChannelAddress returns the consumer channel address of the identity for the given hermes.
+func ChannelAddress(identity, hermesID, registry, channelImplementation string) (string, error) {
+	return crypto.GenerateChannelAddress(identity, hermesID, registry, channelImplementation)
+}
+
+// ChannelStatus is the on chain state of a consumer channel.
+type ChannelStatus struct {
+	Balance string
+	Settled string
+}
+
+// Chain reads the payment contracts over an ethereum RPC endpoint.
+type Chain struct {
+	bc *client.Blockchain
+}
+
+// NewChain connects to the ethereum RPC endpoint at the given address.
+func NewChain(rpcAddress string, timeoutMillis int64) (*Chain, error) {
+	ethClient, err := client.NewReconnectableEthClient(rpcAddress)
+	if err != nil {
+		return nil, err
+	}
+	return &Chain{bc: client.NewBlockchain(ethClient, time.Duration(timeoutMillis)*time.Millisecond)}, nil
+}
+
+// ChannelStatus returns the balance and the settled amount of the consumer channel.
+func (c *Chain) ChannelStatus(channelAddress, mystToken string) (*ChannelStatus, error) {
+	channel, err := parseAddress(channelAddress)
+	if err != nil {
+		return nil, err
+	}
+	token, err := parseAddress(mystToken)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := c.bc.GetConsumerChannel(channel, token)
+	if err != nil {
+		return nil, err
+	}
+	return &ChannelStatus{Balance: status.Balance.String(), Settled: status.Settled.String()}, nil
+}
+
+func parseAddress(s string) (common.Address, error) {
+	if !common.IsHexAddress(s) {
+		return common.Address{}, fmt.Errorf("invalid address %q", s)
+	}
+	return common.HexToAddress(s), nil
+}
+
+func parseAmount(s string) (*big.Int, error) {
+	res, ok := new(big.Int).SetString(s, 10)
+	if !ok || res.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	return res, nil
+}
+
+// ParseMYST converts a decimal myst amount, e.g. "12.5", into a decimal wei amount.
+func ParseMYST(amount string) (string, error) {
+	res, err := crypto.ParseMYST(amount)
+	if err != nil {
+		return "", err
+	}
+	return res.String(), nil
+}
+
+// FormatMYST formats a decimal wei amount in myst with the given number of decimals, using banker's rounding.
+func FormatMYST(amount string, precision int) (string, error) {
+	res, err := parseAmount(amount)
+	if err != nil {
+		return "", err
+	}
+	return crypto.FormatMYST(res, precision), nil
+}
+
+// ParseTokenAmount converts a decimal amount of a token with the given decimals, e.g. 6 for USDC, into its smallest unit amount.
+func ParseTokenAmount(amount string, decimals int) (string, error) {
+	if decimals < 0 || decimals > crypto.MaxDecimals {
+		return "", fmt.Errorf("invalid token decimals %v", decimals)
+	}
+	res, err := crypto.ParseAmount(amount, uint8(decimals))
+	if err != nil {
+		return "", err
+	}
+	return res.String(), nil
+}
+
+// FormatTokenAmount formats a smallest unit amount of a token with the given decimals, using banker's rounding.
+func FormatTokenAmount(amount string, decimals, precision int) (string, error) {
+	if decimals < 0 || decimals > crypto.MaxDecimals {
+		return "", fmt.Errorf("invalid token decimals %v", decimals)
+	}
+	res, err := parseAmount(amount)
+	if err != nil {
+		return "", err
+	}
+	return crypto.FormatAmount(res, uint8(decimals), precision, crypto.RoundHalfEven), nil
+}
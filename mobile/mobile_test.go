@@ -0,0 +1,71 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package mobile
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletSignsPromise(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mobile-wallet")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w := NewWallet(dir)
+	identity, err := w.NewIdentity("pass")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, w.IdentityCount())
+	at, err := w.IdentityAt(0)
+	assert.NoError(t, err)
+	assert.Equal(t, identity, at)
+
+	channelID := "0x000000000000000000000000d2c94475763fa7e81076ab0bde4dc4b902191498"
+	hashlock := "0x2a1b2f0d5bd64fbf6f3ea4d1d1a2d8ba1e2a1d10fd6c8bc07f1c2d5b8e3f4a11"
+	_, err = w.SignPromise(identity, channelID, 1, "100", "1", hashlock)
+	assert.Error(t, err, "locked identity must not sign")
+
+	assert.Error(t, w.Unlock(identity, "wrong"))
+	assert.NoError(t, w.Unlock(identity, "pass"))
+
+	p, err := w.SignPromise(identity, channelID, 1, "100", "1", hashlock)
+	assert.NoError(t, err)
+	assert.Equal(t, "100", p.Amount)
+	assert.Equal(t, channelID, p.ChannelID)
+
+	promise := crypto.Promise{
+		ChannelID: common.FromHex(p.ChannelID),
+		ChainID:   p.ChainID,
+		Amount:    big.NewInt(100),
+		Fee:       big.NewInt(1),
+		Hashlock:  common.FromHex(p.Hashlock),
+		Signature: common.FromHex(p.Signature),
+	}
+	signer, err := promise.RecoverSigner()
+	assert.NoError(t, err)
+	assert.Equal(t, identity, signer.Hex())
+
+	_, err = w.SignPromise(identity, channelID, 1, "-1", "1", hashlock)
+	assert.Error(t, err)
+	assert.NoError(t, w.Lock(identity))
+}
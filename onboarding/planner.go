@@ -0,0 +1,321 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package onboarding plans the transactions needed to become an active provider and estimates their total cost.
+package onboarding
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+// Chain reads the state the plan is based on. The client can be used.
+type Chain interface {
+	IsRegistered(registryAddress, addressToCheck common.Address) (bool, error)
+	GetProviderChannel(hermesAddress common.Address, addressToCheck common.Address, pending bool) (client.ProviderChannel, error)
+	GetStakeThresholds(hermesID common.Address) (min, max *big.Int, err error)
+	GetMystBalance(mystAddress, identity common.Address) (*big.Int, error)
+	SuggestGasPrice() (*big.Int, error)
+}
+
+// Estimator estimates the gas of the planned transactions. The client with dry runs can be used.
+type Estimator interface {
+	Estimate(req client.Estimatable) (uint64, error)
+}
+
+// Executor sends the planned transactions. The client can be used.
+type Executor interface {
+	TransferMyst(req client.TransferRequest) (*types.Transaction, error)
+	RegisterIdentity(req client.RegistrationRequest) (*types.Transaction, error)
+	IncreaseProviderStake(req client.ProviderStakeIncreaseRequest) (*types.Transaction, error)
+}
+
+// StepKind is the kind of a planned transaction.
+type StepKind string
+
+const (
+	// StepTopUp transfers the missing myst to the channel address the registration is paid from.
+	StepTopUp StepKind = "top_up"
+	// StepRegister registers the identity with the stake, opening the provider channel.
+	StepRegister StepKind = "register"
+	// StepIncreaseStake raises the stake of an already registered provider to the hermes minimum.
+	// The sender must have approved hermes to spend the amount.
+	StepIncreaseStake StepKind = "increase_stake"
+)
+
+// DefaultGasLimits are used for steps that can not be estimated before the previous steps are mined.
+var DefaultGasLimits = map[StepKind]uint64{
+	StepTopUp:         100000,
+	StepRegister:      800000,
+	StepIncreaseStake: 200000,
+}
+
+// ErrUnknownStep is returned when executing a step of an unknown kind.
+var ErrUnknownStep = errors.New("unknown onboarding step")
+
+// Params describe the provider to onboard.
+type Params struct {
+	Identity common.Address
+	// Funder sends the top-up, Transactor sends the other transactions, both can be the identity itself.
+	Funder     common.Address
+	Transactor common.Address
+
+	Registry              common.Address
+	ChannelImplementation common.Address
+	HermesID              common.Address
+	MystToken             common.Address
+
+	// Stake is the requested stake, it is raised to the hermes minimum stake.
+	Stake *big.Int
+	// RegistrationFee is the transactor fee paid for the registration.
+	RegistrationFee *big.Int
+	Beneficiary     common.Address
+}
+
+// Step is a single transaction of the plan. Exactly one of the requests is set, according to the kind.
+// The requests miss the signer, and the registration misses the identity signature, the executor fills them in.
+type Step struct {
+	Kind          StepKind
+	Transfer      *client.TransferRequest
+	Registration  *client.RegistrationRequest
+	IncreaseStake *client.ProviderStakeIncreaseRequest
+	// Myst is the amount of myst the step moves.
+	Myst *big.Int
+	// Estimated is false if the default gas limit is used, as the step depends on earlier steps being mined.
+	Estimated  bool
+	NetworkFee *big.Int
+}
+
+// Execute sends the step transaction.
+func (s Step) Execute(ex Executor) (*types.Transaction, error) {
+	switch s.Kind {
+	case StepTopUp:
+		return ex.TransferMyst(*s.Transfer)
+	case StepRegister:
+		return ex.RegisterIdentity(*s.Registration)
+	case StepIncreaseStake:
+		return ex.IncreaseProviderStake(*s.IncreaseStake)
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnknownStep, s.Kind)
+}
+
+// Plan lists the transactions to execute in order, along with their total cost.
+type Plan struct {
+	Identity common.Address
+	// Channel is the address the registration fee and stake are paid from.
+	Channel  common.Address
+	Stake    *big.Int
+	GasPrice *big.Int
+	Steps    []Step
+	// TotalMyst is the myst spent on the registration fee and stake.
+	TotalMyst *big.Int
+	// TotalNetworkFee is the maximum native token cost of all the steps.
+	TotalNetworkFee *big.Int
+}
+
+// Done returns true if the provider is already active and nothing is left to do.
+func (p Plan) Done() bool {
+	return len(p.Steps) == 0
+}
+
+// Planner plans provider onboarding under the current market conditions.
+type Planner struct {
+	chain     Chain
+	estimator Estimator
+}
+
+// NewPlanner returns a new onboarding planner.
+func NewPlanner(chain Chain, estimator Estimator) *Planner {
+	return &Planner{
+		chain:     chain,
+		estimator: estimator,
+	}
+}
+
+// Plan returns the steps needed for the identity to become an active provider of the hermes.
+func (p *Planner) Plan(params Params) (Plan, error) {
+	channel, err := crypto.GenerateChannelAddress(params.Identity.Hex(), params.HermesID.Hex(), params.Registry.Hex(), params.ChannelImplementation.Hex())
+	if err != nil {
+		return Plan{}, err
+	}
+
+	gasPrice, err := p.chain.SuggestGasPrice()
+	if err != nil {
+		return Plan{}, fmt.Errorf("could not get gas price: %w", err)
+	}
+
+	minStake, _, err := p.chain.GetStakeThresholds(params.HermesID)
+	if err != nil {
+		return Plan{}, fmt.Errorf("could not get stake thresholds: %w", err)
+	}
+
+	plan := Plan{
+		Identity:        params.Identity,
+		Channel:         common.HexToAddress(channel),
+		Stake:           maxBig(params.Stake, minStake),
+		GasPrice:        gasPrice,
+		TotalMyst:       new(big.Int),
+		TotalNetworkFee: new(big.Int),
+	}
+
+	registered, err := p.chain.IsRegistered(params.Registry, params.Identity)
+	if err != nil {
+		return Plan{}, fmt.Errorf("could not check registration: %w", err)
+	}
+
+	var steps []Step
+	if registered {
+		steps, err = p.planStake(params, plan)
+	} else {
+		steps, err = p.planRegistration(params, plan)
+	}
+	if err != nil {
+		return Plan{}, err
+	}
+
+	for i := range steps {
+		if err := p.estimate(&steps[i], i, gasPrice); err != nil {
+			return Plan{}, err
+		}
+		plan.TotalNetworkFee.Add(plan.TotalNetworkFee, steps[i].NetworkFee)
+		if steps[i].Kind != StepTopUp {
+			plan.TotalMyst.Add(plan.TotalMyst, steps[i].Myst)
+		}
+	}
+	plan.Steps = steps
+	return plan, nil
+}
+
+func (p *Planner) planRegistration(params Params, plan Plan) ([]Step, error) {
+	fee := bigOrZero(params.RegistrationFee)
+	needed := new(big.Int).Add(plan.Stake, fee)
+
+	balance, err := p.chain.GetMystBalance(params.MystToken, plan.Channel)
+	if err != nil {
+		return nil, fmt.Errorf("could not get channel balance: %w", err)
+	}
+
+	var steps []Step
+	if balance.Cmp(needed) < 0 {
+		missing := new(big.Int).Sub(needed, balance)
+		steps = append(steps, Step{
+			Kind: StepTopUp,
+			Transfer: &client.TransferRequest{
+				MystAddress:  params.MystToken,
+				Recipient:    plan.Channel,
+				Amount:       missing,
+				WriteRequest: client.WriteRequest{Identity: params.Funder},
+			},
+			Myst: missing,
+		})
+	}
+
+	return append(steps, Step{
+		Kind: StepRegister,
+		Registration: &client.RegistrationRequest{
+			WriteRequest:    client.WriteRequest{Identity: params.Transactor},
+			HermesID:        params.HermesID,
+			Stake:           plan.Stake,
+			TransactorFee:   fee,
+			Beneficiary:     params.Beneficiary,
+			RegistryAddress: params.Registry,
+		},
+		Myst: needed,
+	}), nil
+}
+
+func (p *Planner) planStake(params Params, plan Plan) ([]Step, error) {
+	ch, err := p.chain.GetProviderChannel(params.HermesID, params.Identity, false)
+	if err != nil {
+		return nil, fmt.Errorf("could not get provider channel: %w", err)
+	}
+
+	current := bigOrZero(ch.Stake)
+	if current.Cmp(plan.Stake) >= 0 {
+		return nil, nil
+	}
+
+	channelID, err := crypto.GenerateProviderChannelID(params.Identity.Hex(), params.HermesID.Hex())
+	if err != nil {
+		return nil, err
+	}
+
+	req := &client.ProviderStakeIncreaseRequest{
+		WriteRequest: client.WriteRequest{Identity: params.Transactor},
+		HermesID:     params.HermesID,
+		Amount:       new(big.Int).Sub(plan.Stake, current),
+	}
+	copy(req.ChannelID[:], common.FromHex(channelID))
+
+	return []Step{{
+		Kind:          StepIncreaseStake,
+		IncreaseStake: req,
+		Myst:          req.Amount,
+	}}, nil
+}
+
+// estimate fills in the gas of the step. Steps following others fall back to the default gas limit
+// if the estimation fails, as they usually revert until the earlier steps are mined.
+func (p *Planner) estimate(s *Step, index int, gasPrice *big.Int) error {
+	var req client.Estimatable
+	var wr *client.WriteRequest
+	switch s.Kind {
+	case StepTopUp:
+		req, wr = *s.Transfer, &s.Transfer.WriteRequest
+	case StepRegister:
+		req, wr = *s.Registration, &s.Registration.WriteRequest
+	case StepIncreaseStake:
+		req, wr = *s.IncreaseStake, &s.IncreaseStake.WriteRequest
+	default:
+		return fmt.Errorf("%w: %v", ErrUnknownStep, s.Kind)
+	}
+
+	gasLimit, err := p.estimator.Estimate(req)
+	switch {
+	case err == nil:
+		s.Estimated = true
+	case index > 0:
+		gasLimit = DefaultGasLimits[s.Kind]
+	default:
+		return fmt.Errorf("could not estimate %v: %w", s.Kind, err)
+	}
+
+	wr.GasLimit = gasLimit
+	wr.GasPrice = new(big.Int).Set(gasPrice)
+	s.NetworkFee = new(big.Int).Mul(new(big.Int).SetUint64(gasLimit), gasPrice)
+	return nil
+}
+
+func maxBig(a, b *big.Int) *big.Int {
+	a, b = bigOrZero(a), bigOrZero(b)
+	if a.Cmp(b) >= 0 {
+		return new(big.Int).Set(a)
+	}
+	return new(big.Int).Set(b)
+}
+
+func bigOrZero(i *big.Int) *big.Int {
+	if i == nil {
+		return new(big.Int)
+	}
+	return i
+}
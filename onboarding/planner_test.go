@@ -0,0 +1,152 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package onboarding
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockChain struct {
+	registered bool
+	stake      *big.Int
+	balance    *big.Int
+}
+
+func (mc *mockChain) IsRegistered(registryAddress, addressToCheck common.Address) (bool, error) {
+	return mc.registered, nil
+}
+
+func (mc *mockChain) GetProviderChannel(hermesAddress common.Address, addressToCheck common.Address, pending bool) (client.ProviderChannel, error) {
+	return client.ProviderChannel{Stake: mc.stake}, nil
+}
+
+func (mc *mockChain) GetStakeThresholds(hermesID common.Address) (min, max *big.Int, err error) {
+	return big.NewInt(100), big.NewInt(1000), nil
+}
+
+func (mc *mockChain) GetMystBalance(mystAddress, identity common.Address) (*big.Int, error) {
+	return mc.balance, nil
+}
+
+func (mc *mockChain) SuggestGasPrice() (*big.Int, error) {
+	return big.NewInt(2), nil
+}
+
+// mockEstimator fails to estimate registrations, like a node would before the channel is topped up.
+type mockEstimator struct{}
+
+func (mockEstimator) Estimate(req client.Estimatable) (uint64, error) {
+	switch req.(type) {
+	case client.TransferRequest:
+		return 50000, nil
+	case client.ProviderStakeIncreaseRequest:
+		return 70000, nil
+	}
+	return 0, errors.New("execution reverted")
+}
+
+type mockExecutor struct {
+	executed []string
+}
+
+func (me *mockExecutor) TransferMyst(req client.TransferRequest) (*types.Transaction, error) {
+	me.executed = append(me.executed, "transfer")
+	return nil, nil
+}
+
+func (me *mockExecutor) RegisterIdentity(req client.RegistrationRequest) (*types.Transaction, error) {
+	me.executed = append(me.executed, "register")
+	return nil, nil
+}
+
+func (me *mockExecutor) IncreaseProviderStake(req client.ProviderStakeIncreaseRequest) (*types.Transaction, error) {
+	me.executed = append(me.executed, "stake")
+	return nil, nil
+}
+
+func testParams() Params {
+	return Params{
+		Identity:              common.HexToAddress("0x1"),
+		Funder:                common.HexToAddress("0x2"),
+		Transactor:            common.HexToAddress("0x3"),
+		Registry:              common.HexToAddress("0x4"),
+		ChannelImplementation: common.HexToAddress("0x5"),
+		HermesID:              common.HexToAddress("0x6"),
+		MystToken:             common.HexToAddress("0x7"),
+		Stake:                 big.NewInt(50),
+		RegistrationFee:       big.NewInt(10),
+	}
+}
+
+func TestPlanRegistration(t *testing.T) {
+	chain := &mockChain{balance: big.NewInt(30)}
+	plan, err := NewPlanner(chain, mockEstimator{}).Plan(testParams())
+	assert.NoError(t, err)
+
+	assert.Equal(t, big.NewInt(100), plan.Stake, "stake is raised to the hermes minimum")
+	assert.Len(t, plan.Steps, 2)
+
+	topUp := plan.Steps[0]
+	assert.Equal(t, StepTopUp, topUp.Kind)
+	assert.Equal(t, big.NewInt(80), topUp.Transfer.Amount)
+	assert.Equal(t, plan.Channel, topUp.Transfer.Recipient)
+	assert.Equal(t, common.HexToAddress("0x2"), topUp.Transfer.Identity)
+	assert.True(t, topUp.Estimated)
+
+	register := plan.Steps[1]
+	assert.Equal(t, StepRegister, register.Kind)
+	assert.False(t, register.Estimated)
+	assert.Equal(t, DefaultGasLimits[StepRegister], register.Registration.GasLimit)
+	assert.Equal(t, big.NewInt(2), register.Registration.GasPrice)
+
+	assert.Equal(t, big.NewInt(110), plan.TotalMyst)
+	assert.Equal(t, new(big.Int).SetUint64(2*(50000+DefaultGasLimits[StepRegister])), plan.TotalNetworkFee)
+
+	ex := &mockExecutor{}
+	for _, s := range plan.Steps {
+		_, err := s.Execute(ex)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"transfer", "register"}, ex.executed)
+
+	chain.balance = big.NewInt(110)
+	_, err = NewPlanner(chain, mockEstimator{}).Plan(testParams())
+	assert.Error(t, err, "registration that can not be estimated is not preceded by other steps")
+}
+
+func TestPlanStake(t *testing.T) {
+	chain := &mockChain{registered: true, stake: big.NewInt(40)}
+	plan, err := NewPlanner(chain, mockEstimator{}).Plan(testParams())
+	assert.NoError(t, err)
+
+	assert.Len(t, plan.Steps, 1)
+	assert.Equal(t, StepIncreaseStake, plan.Steps[0].Kind)
+	assert.Equal(t, big.NewInt(60), plan.Steps[0].IncreaseStake.Amount)
+	assert.Equal(t, big.NewInt(140000), plan.TotalNetworkFee)
+
+	chain.stake = big.NewInt(100)
+	plan, err = NewPlanner(chain, mockEstimator{}).Plan(testParams())
+	assert.NoError(t, err)
+	assert.True(t, plan.Done())
+}
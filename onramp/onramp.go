@@ -0,0 +1,174 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package onramp turns fiat purchases into myst top-ups of consumer channels.
+package onramp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrOrderNotFound is returned for orders the provider does not know.
+var ErrOrderNotFound = errors.New("order not found")
+
+// ErrOrderFailed is returned when waiting for an order that failed.
+var ErrOrderFailed = errors.New("order failed")
+
+// OrderState is the state of a fiat purchase.
+type OrderState string
+
+const (
+	// OrderCreated orders wait for the fiat payment.
+	OrderCreated OrderState = "created"
+	// OrderPaid orders are paid and wait for the myst transfer.
+	OrderPaid OrderState = "paid"
+	// OrderDelivered orders have the myst transfer to the channel sent.
+	OrderDelivered OrderState = "delivered"
+	// OrderFailed orders will not be delivered, see Order.Error.
+	OrderFailed OrderState = "failed"
+)
+
+// OrderRequest is a fiat purchase of myst for a consumer channel.
+type OrderRequest struct {
+	ChainID int64
+	Channel common.Address
+	// Currency is an ISO 4217 code, such as "usd".
+	Currency   string
+	FiatAmount float64
+}
+
+// Order is the state of a fiat purchase.
+type Order struct {
+	ID      string
+	Request OrderRequest
+	State   OrderState
+	// MystAmount is the quoted amount until the order is delivered, the transferred amount afterwards.
+	MystAmount *big.Int
+	// PaymentURL is where the customer pays, if the provider hosts a checkout.
+	PaymentURL string
+	// TxHash is the hash of the myst transfer of delivered orders.
+	TxHash    common.Hash
+	Error     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Provider is a fiat on-ramp delivering the purchased myst to the consumer channel.
+type Provider interface {
+	CreateOrder(req OrderRequest) (Order, error)
+	OrderStatus(id string) (Order, error)
+	// HandleCallback processes a notification of the provider, e.g. a payment webhook, and returns the updated order.
+	HandleCallback(r *http.Request) (Order, error)
+}
+
+// BalanceReader returns myst balances. The client can be used.
+type BalanceReader interface {
+	GetMystBalance(mystAddress, identity common.Address) (*big.Int, error)
+}
+
+// PendingTopUp is an order whose myst has not reached the channel yet.
+type PendingTopUp struct {
+	Order Order
+	// BalanceBefore is the channel balance when the order was created.
+	BalanceBefore *big.Int
+}
+
+// Flow creates on-ramp orders and waits until the purchased myst is in the channel.
+type Flow struct {
+	provider Provider
+	balances BalanceReader
+	token    common.Address
+	interval time.Duration
+}
+
+// NewFlow returns a new top-up flow of the given myst token, polling the order and balance at the interval.
+func NewFlow(provider Provider, balances BalanceReader, token common.Address, interval time.Duration) *Flow {
+	return &Flow{
+		provider: provider,
+		balances: balances,
+		token:    token,
+		interval: interval,
+	}
+}
+
+// Start records the channel balance and creates the order.
+func (f *Flow) Start(req OrderRequest) (PendingTopUp, error) {
+	balance, err := f.balances.GetMystBalance(f.token, req.Channel)
+	if err != nil {
+		return PendingTopUp{}, err
+	}
+
+	order, err := f.provider.CreateOrder(req)
+	if err != nil {
+		return PendingTopUp{}, err
+	}
+	return PendingTopUp{Order: order, BalanceBefore: balance}, nil
+}
+
+// Wait polls the order until it is delivered and then waits for the channel top-up.
+func (f *Flow) Wait(ctx context.Context, p PendingTopUp) (Order, error) {
+	order := p.Order
+	for order.State != OrderDelivered {
+		if order.State == OrderFailed {
+			return order, fmt.Errorf("%w: %v", ErrOrderFailed, order.Error)
+		}
+		if err := sleep(ctx, f.interval); err != nil {
+			return order, err
+		}
+
+		var err error
+		order, err = f.provider.OrderStatus(order.ID)
+		if err != nil {
+			return p.Order, err
+		}
+	}
+
+	target := new(big.Int).Add(p.BalanceBefore, order.MystAmount)
+	_, err := WaitForChannelTopUp(ctx, f.balances, f.token, order.Request.Channel, target, f.interval)
+	return order, err
+}
+
+// WaitForChannelTopUp polls the channel balance until it reaches the target and returns the balance.
+func WaitForChannelTopUp(ctx context.Context, balances BalanceReader, token, channel common.Address, target *big.Int, interval time.Duration) (*big.Int, error) {
+	for {
+		balance, err := balances.GetMystBalance(token, channel)
+		if err != nil {
+			return nil, err
+		}
+		if balance.Cmp(target) >= 0 {
+			return balance, nil
+		}
+		if err := sleep(ctx, interval); err != nil {
+			return balance, err
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
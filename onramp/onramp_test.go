@@ -0,0 +1,168 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package onramp
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedPrice float64
+
+func (f fixedPrice) PriceAt(currency string, at time.Time) (float64, error) {
+	return float64(f), nil
+}
+
+// fakeChain is both the myst sender and the balance reader.
+type fakeChain struct {
+	lock      sync.Mutex
+	balances  map[common.Address]*big.Int
+	transfers []client.TransferRequest
+	err       error
+}
+
+func (fc *fakeChain) TransferMyst(req client.TransferRequest) (*types.Transaction, error) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	if fc.err != nil {
+		return nil, fc.err
+	}
+	fc.transfers = append(fc.transfers, req)
+	b := fc.balance(req.Recipient)
+	fc.balances[req.Recipient] = b.Add(b, req.Amount)
+	return types.NewTransaction(uint64(len(fc.transfers)), req.Recipient, big.NewInt(0), 0, big.NewInt(0), nil), nil
+}
+
+func (fc *fakeChain) GetMystBalance(mystAddress, identity common.Address) (*big.Int, error) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	return new(big.Int).Set(fc.balance(identity)), nil
+}
+
+func (fc *fakeChain) balance(addr common.Address) *big.Int {
+	if b, ok := fc.balances[addr]; ok {
+		return b
+	}
+	return new(big.Int)
+}
+
+var (
+	secret  = []byte("secret")
+	channel = common.HexToAddress("0x1")
+	token   = common.HexToAddress("0x2")
+)
+
+func callback(t *testing.T, tp *TreasuryProvider, cb Callback, key []byte) (Order, error) {
+	body, err := json.Marshal(cb)
+	assert.NoError(t, err)
+	r := httptest.NewRequest("POST", "/callback", bytes.NewReader(body))
+	r.Header.Set(SignatureHeader, hex.EncodeToString(SignCallback(key, body)))
+	return tp.HandleCallback(r)
+}
+
+func newTestProvider(chain *fakeChain) *TreasuryProvider {
+	return NewTreasuryProvider(chain, fixedPrice(0.5), NewMemoryOrderStorage(), TreasuryOpts{
+		ChainID:     137,
+		Token:       token,
+		Secret:      secret,
+		CheckoutURL: "https://pay.example.com/checkout",
+		Spread:      0.1,
+	})
+}
+
+func TestTreasuryProviderDeliversPaidOrders(t *testing.T) {
+	chain := &fakeChain{balances: map[common.Address]*big.Int{channel: crypto.FloatToBigMyst(1)}}
+	flow := NewFlow(newTestProvider(chain), chain, token, time.Millisecond)
+	tp := flow.provider.(*TreasuryProvider)
+
+	_, err := tp.CreateOrder(OrderRequest{ChainID: 1, Channel: channel, Currency: "usd", FiatAmount: 10})
+	assert.True(t, errors.Is(err, ErrInvalidOrder))
+
+	pending, err := flow.Start(OrderRequest{ChainID: 137, Channel: channel, Currency: "usd", FiatAmount: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, OrderCreated, pending.Order.State)
+	assert.Equal(t, crypto.FloatToBigMyst(18), pending.Order.MystAmount)
+	assert.Equal(t, "https://pay.example.com/checkout?order="+pending.Order.ID, pending.Order.PaymentURL)
+	assert.Equal(t, crypto.FloatToBigMyst(1), pending.BalanceBefore)
+
+	_, err = callback(t, tp, Callback{OrderID: pending.Order.ID, Status: "paid"}, []byte("wrong"))
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	done := make(chan Order)
+	go func() {
+		order, err := flow.Wait(context.Background(), pending)
+		assert.NoError(t, err)
+		done <- order
+	}()
+
+	order, err := callback(t, tp, Callback{OrderID: pending.Order.ID, Status: "paid"}, secret)
+	assert.NoError(t, err)
+	assert.Equal(t, OrderDelivered, order.State)
+
+	// repeated notifications are ignored
+	_, err = callback(t, tp, Callback{OrderID: pending.Order.ID, Status: "paid"}, secret)
+	assert.NoError(t, err)
+	assert.Len(t, chain.transfers, 1)
+	assert.Equal(t, channel, chain.transfers[0].Recipient)
+
+	select {
+	case order := <-done:
+		assert.Equal(t, OrderDelivered, order.State)
+		assert.NotEqual(t, common.Hash{}, order.TxHash)
+	case <-time.After(time.Second):
+		t.Fatal("top-up not observed")
+	}
+}
+
+func TestTreasuryProviderFailedTransfer(t *testing.T) {
+	chain := &fakeChain{balances: map[common.Address]*big.Int{}, err: errors.New("out of gas")}
+	flow := NewFlow(newTestProvider(chain), chain, token, time.Millisecond)
+	tp := flow.provider.(*TreasuryProvider)
+
+	pending, err := flow.Start(OrderRequest{ChainID: 137, Channel: channel, Currency: "usd", FiatAmount: 10})
+	assert.NoError(t, err)
+
+	order, err := callback(t, tp, Callback{OrderID: pending.Order.ID, Status: "paid"}, secret)
+	assert.NoError(t, err)
+	assert.Equal(t, OrderFailed, order.State)
+
+	_, err = flow.Wait(context.Background(), pending)
+	assert.True(t, errors.Is(err, ErrOrderFailed))
+}
+
+func TestWaitForChannelTopUpTimesOut(t *testing.T) {
+	chain := &fakeChain{balances: map[common.Address]*big.Int{}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := WaitForChannelTopUp(ctx, chain, token, channel, big.NewInt(1), time.Millisecond)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
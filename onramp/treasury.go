@@ -0,0 +1,271 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package onramp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/mysteriumnetwork/payments/rates"
+)
+
+// SignatureHeader carries the hex encoded HMAC-SHA256 of the callback body.
+const SignatureHeader = "X-Onramp-Signature"
+
+// ErrInvalidSignature is returned for callbacks not signed with the shared secret.
+var ErrInvalidSignature = errors.New("invalid callback signature")
+
+// ErrInvalidOrder is returned for order requests that can not be fulfilled.
+var ErrInvalidOrder = errors.New("invalid order")
+
+// MystSender transfers myst. The client can be used.
+type MystSender interface {
+	TransferMyst(req client.TransferRequest) (*types.Transaction, error)
+}
+
+// OrderStorage persists on-ramp orders.
+type OrderStorage interface {
+	SaveOrder(o Order) error
+	// GetOrder returns ErrOrderNotFound for unknown orders.
+	GetOrder(id string) (Order, error)
+}
+
+// TreasuryOpts configures the treasury provider.
+type TreasuryOpts struct {
+	ChainID int64
+	Token   common.Address
+	// Treasury sends the purchased myst, Signer must be able to sign for it.
+	Treasury client.WriteRequest
+	// Secret is shared with the payment processor and signs its callbacks.
+	Secret []byte
+	// CheckoutURL is the payment page, the order id is passed in the "order" query parameter.
+	CheckoutURL string
+	// Spread is the fraction of the fiat amount kept by the provider, e.g. 0.02.
+	Spread float64
+	// Decimals of the token, myst decimals are assumed if zero.
+	Decimals uint8
+}
+
+// TreasuryProvider is the reference provider: a payment processor notifies it of paid orders
+// and the purchased myst is transferred from a treasury account to the consumer channel.
+type TreasuryProvider struct {
+	sender  MystSender
+	prices  rates.PriceOracle
+	storage OrderStorage
+	opts    TreasuryOpts
+	now     func() time.Time
+
+	lock sync.Mutex
+}
+
+// NewTreasuryProvider returns a new treasury provider.
+func NewTreasuryProvider(sender MystSender, prices rates.PriceOracle, storage OrderStorage, opts TreasuryOpts) *TreasuryProvider {
+	return &TreasuryProvider{
+		sender:  sender,
+		prices:  prices,
+		storage: storage,
+		opts:    opts,
+		now:     time.Now,
+	}
+}
+
+// CreateOrder quotes the myst amount at the current price and stores the order.
+func (tp *TreasuryProvider) CreateOrder(req OrderRequest) (Order, error) {
+	if req.ChainID != tp.opts.ChainID {
+		return Order{}, fmt.Errorf("%w: unsupported chain %v", ErrInvalidOrder, req.ChainID)
+	}
+	if req.FiatAmount <= 0 {
+		return Order{}, fmt.Errorf("%w: fiat amount must be positive", ErrInvalidOrder)
+	}
+
+	now := tp.now()
+	price, err := tp.prices.PriceAt(req.Currency, now)
+	if err != nil {
+		return Order{}, fmt.Errorf("could not get myst price: %w", err)
+	}
+	if price <= 0 {
+		return Order{}, fmt.Errorf("invalid myst price %v", price)
+	}
+
+	id, err := newOrderID()
+	if err != nil {
+		return Order{}, err
+	}
+
+	order := Order{
+		ID:         id,
+		Request:    req,
+		State:      OrderCreated,
+		MystAmount: crypto.FloatToAmount(req.FiatAmount*(1-tp.opts.Spread)/price, tp.decimals()),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if tp.opts.CheckoutURL != "" {
+		order.PaymentURL = tp.opts.CheckoutURL + "?order=" + url.QueryEscape(id)
+	}
+
+	return order, tp.storage.SaveOrder(order)
+}
+
+// OrderStatus returns the stored order.
+func (tp *TreasuryProvider) OrderStatus(id string) (Order, error) {
+	return tp.storage.GetOrder(id)
+}
+
+// Callback is the payment processor notification.
+type Callback struct {
+	OrderID string `json:"order_id"`
+	// Status is either "paid" or "failed".
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// HandleCallback verifies the callback signature and applies it to the order.
+// Paid orders are delivered by transferring the myst from the treasury to the channel.
+func (tp *TreasuryProvider) HandleCallback(r *http.Request) (Order, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return Order{}, err
+	}
+
+	sig, err := hex.DecodeString(r.Header.Get(SignatureHeader))
+	if err != nil || !hmac.Equal(sig, SignCallback(tp.opts.Secret, body)) {
+		return Order{}, ErrInvalidSignature
+	}
+
+	var cb Callback
+	if err := json.Unmarshal(body, &cb); err != nil {
+		return Order{}, fmt.Errorf("could not parse callback: %w", err)
+	}
+
+	// serialize callbacks, so a repeated notification can not transfer the myst twice
+	tp.lock.Lock()
+	defer tp.lock.Unlock()
+
+	order, err := tp.storage.GetOrder(cb.OrderID)
+	if err != nil {
+		return Order{}, err
+	}
+	if order.State != OrderCreated {
+		return order, nil
+	}
+
+	switch cb.Status {
+	case string(OrderPaid):
+		return tp.deliver(order)
+	case string(OrderFailed):
+		return tp.update(order, OrderFailed, cb.Reason)
+	default:
+		return Order{}, fmt.Errorf("unknown callback status %q", cb.Status)
+	}
+}
+
+func (tp *TreasuryProvider) deliver(order Order) (Order, error) {
+	order, err := tp.update(order, OrderPaid, "")
+	if err != nil {
+		return order, err
+	}
+
+	tx, err := tp.sender.TransferMyst(client.TransferRequest{
+		MystAddress:  tp.opts.Token,
+		Recipient:    order.Request.Channel,
+		Amount:       order.MystAmount,
+		WriteRequest: tp.opts.Treasury,
+	})
+	if err != nil {
+		return tp.update(order, OrderFailed, fmt.Sprintf("could not transfer myst: %v", err))
+	}
+
+	order.TxHash = tx.Hash()
+	return tp.update(order, OrderDelivered, "")
+}
+
+func (tp *TreasuryProvider) update(order Order, state OrderState, reason string) (Order, error) {
+	order.State = state
+	order.Error = reason
+	order.UpdatedAt = tp.now()
+	return order, tp.storage.SaveOrder(order)
+}
+
+// SignCallback returns the HMAC-SHA256 of the callback body, the payment processor sends it hex encoded in SignatureHeader.
+func SignCallback(secret, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func newOrderID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// MemoryOrderStorage keeps orders in memory.
+type MemoryOrderStorage struct {
+	lock   sync.Mutex
+	orders map[string]Order
+}
+
+// NewMemoryOrderStorage returns a new in-memory order storage.
+func NewMemoryOrderStorage() *MemoryOrderStorage {
+	return &MemoryOrderStorage{orders: make(map[string]Order)}
+}
+
+// SaveOrder stores the order.
+func (m *MemoryOrderStorage) SaveOrder(o Order) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	o.MystAmount = new(big.Int).Set(o.MystAmount)
+	m.orders[o.ID] = o
+	return nil
+}
+
+// GetOrder returns the stored order.
+func (m *MemoryOrderStorage) GetOrder(id string) (Order, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	o, ok := m.orders[id]
+	if !ok {
+		return Order{}, ErrOrderNotFound
+	}
+	o.MystAmount = new(big.Int).Set(o.MystAmount)
+	return o, nil
+}
+
+func (tp *TreasuryProvider) decimals() uint8 {
+	if tp.opts.Decimals == 0 {
+		return crypto.MystDecimals
+	}
+	return tp.opts.Decimals
+}
@@ -0,0 +1,148 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package policy enforces allowlists and denylists of payout addresses.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// ErrAddressDenied is returned for addresses on the denylist.
+	ErrAddressDenied = errors.New("address is denied")
+	// ErrAddressNotAllowed is returned for addresses missing from the allowlist when the allowlist is enforced.
+	ErrAddressNotAllowed = errors.New("address is not allowlisted")
+)
+
+// Kind is the kind of a policy entry.
+type Kind string
+
+const (
+	// KindAllow marks allowlisted addresses.
+	KindAllow Kind = "allow"
+	// KindDeny marks denied addresses.
+	KindDeny Kind = "deny"
+)
+
+// Entry is a single allowlist or denylist entry.
+type Entry struct {
+	Address common.Address
+	Kind    Kind
+	// Source identifies where the entry comes from, e.g. "local" or the URL of a synced list.
+	Source string
+	Reason string
+}
+
+// SourceLocal is the source of manually added entries.
+const SourceLocal = "local"
+
+// Storage persists the policy entries.
+type Storage interface {
+	UpsertPolicyEntry(e Entry) error
+	DeletePolicyEntry(address common.Address, source string) error
+	GetPolicyEntries() ([]Entry, error)
+}
+
+// AddressPolicy checks addresses against the allowlist and the denylist.
+// Denied addresses are always refused. If the allowlist is enforced, only allowlisted addresses pass.
+type AddressPolicy struct {
+	storage          Storage
+	enforceAllowlist bool
+
+	lock  sync.RWMutex
+	allow map[common.Address]int
+	deny  map[common.Address]int
+}
+
+// NewAddressPolicy returns a new address policy loaded from the storage.
+func NewAddressPolicy(storage Storage, enforceAllowlist bool) (*AddressPolicy, error) {
+	ap := &AddressPolicy{
+		storage:          storage,
+		enforceAllowlist: enforceAllowlist,
+	}
+	if err := ap.Reload(); err != nil {
+		return nil, err
+	}
+	return ap, nil
+}
+
+// Reload reloads the entries from the storage.
+func (ap *AddressPolicy) Reload() error {
+	entries, err := ap.storage.GetPolicyEntries()
+	if err != nil {
+		return fmt.Errorf("could not get policy entries: %w", err)
+	}
+
+	allow := make(map[common.Address]int)
+	deny := make(map[common.Address]int)
+	for _, e := range entries {
+		switch e.Kind {
+		case KindAllow:
+			allow[e.Address]++
+		case KindDeny:
+			deny[e.Address]++
+		}
+	}
+
+	ap.lock.Lock()
+	defer ap.lock.Unlock()
+	ap.allow, ap.deny = allow, deny
+	return nil
+}
+
+// CheckAddress returns an error if the address may not receive funds.
+func (ap *AddressPolicy) CheckAddress(address common.Address) error {
+	ap.lock.RLock()
+	defer ap.lock.RUnlock()
+
+	if ap.deny[address] > 0 {
+		return fmt.Errorf("%w: %v", ErrAddressDenied, address.Hex())
+	}
+	if ap.enforceAllowlist && ap.allow[address] == 0 {
+		return fmt.Errorf("%w: %v", ErrAddressNotAllowed, address.Hex())
+	}
+	return nil
+}
+
+// Allow adds a local allowlist entry.
+func (ap *AddressPolicy) Allow(address common.Address, reason string) error {
+	return ap.upsert(Entry{Address: address, Kind: KindAllow, Source: SourceLocal, Reason: reason})
+}
+
+// Deny adds a local denylist entry.
+func (ap *AddressPolicy) Deny(address common.Address, reason string) error {
+	return ap.upsert(Entry{Address: address, Kind: KindDeny, Source: SourceLocal, Reason: reason})
+}
+
+// Remove removes the local entry of the address.
+func (ap *AddressPolicy) Remove(address common.Address) error {
+	if err := ap.storage.DeletePolicyEntry(address, SourceLocal); err != nil {
+		return fmt.Errorf("could not delete policy entry: %w", err)
+	}
+	return ap.Reload()
+}
+
+func (ap *AddressPolicy) upsert(e Entry) error {
+	if err := ap.storage.UpsertPolicyEntry(e); err != nil {
+		return fmt.Errorf("could not upsert policy entry: %w", err)
+	}
+	return ap.Reload()
+}
@@ -0,0 +1,70 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type memStorage struct {
+	entries map[string]Entry
+}
+
+func (ms *memStorage) UpsertPolicyEntry(e Entry) error {
+	ms.entries[e.Address.Hex()+e.Source] = e
+	return nil
+}
+
+func (ms *memStorage) DeletePolicyEntry(address common.Address, source string) error {
+	delete(ms.entries, address.Hex()+source)
+	return nil
+}
+
+func (ms *memStorage) GetPolicyEntries() ([]Entry, error) {
+	var res []Entry
+	for _, e := range ms.entries {
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func TestAddressPolicy(t *testing.T) {
+	a, b, c := common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")
+	storage := &memStorage{entries: make(map[string]Entry)}
+
+	ap, err := NewAddressPolicy(storage, true)
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(ap.CheckAddress(a), ErrAddressNotAllowed))
+
+	assert.NoError(t, ap.Allow(a, ""))
+	assert.NoError(t, ap.CheckAddress(a))
+
+	list := []common.Address{a, b}
+	s := NewSyncer(ap, "remote", KindDeny, func() ([]common.Address, error) { return list, nil }, 0)
+	assert.NoError(t, s.Sync())
+	assert.True(t, errors.Is(ap.CheckAddress(a), ErrAddressDenied))
+
+	list = []common.Address{c}
+	assert.NoError(t, s.Sync())
+	assert.NoError(t, ap.CheckAddress(a))
+	assert.True(t, errors.Is(ap.CheckAddress(c), ErrAddressDenied))
+	assert.Len(t, storage.entries, 2)
+}
@@ -0,0 +1,157 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package policy
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// FetchFunc returns the addresses of a remote list.
+type FetchFunc func() ([]common.Address, error)
+
+// LogFunc is called with the errors of the background sync.
+type LogFunc func(error)
+
+// HTTPList returns a fetch func reading a newline separated list of addresses from the given url.
+// Empty lines and lines starting with # are ignored.
+func HTTPList(client *http.Client, url string) FetchFunc {
+	return func() ([]common.Address, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, fmt.Errorf("could not get list: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("could not get list: unexpected status %v", resp.StatusCode)
+		}
+
+		var res []common.Address
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !common.IsHexAddress(line) {
+				return nil, fmt.Errorf("invalid address in list: %q", line)
+			}
+			res = append(res, common.HexToAddress(line))
+		}
+		return res, scanner.Err()
+	}
+}
+
+// Syncer keeps the entries of a remote list in sync with the policy storage.
+type Syncer struct {
+	policy   *AddressPolicy
+	source   string
+	kind     Kind
+	fetch    FetchFunc
+	interval time.Duration
+	logFunc  LogFunc
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewSyncer returns a new syncer for the list identified by source.
+func NewSyncer(policy *AddressPolicy, source string, kind Kind, fetch FetchFunc, interval time.Duration) *Syncer {
+	return &Syncer{
+		policy:   policy,
+		source:   source,
+		kind:     kind,
+		fetch:    fetch,
+		interval: interval,
+		logFunc:  func(error) {},
+		stop:     make(chan struct{}),
+	}
+}
+
+// AttachLogFunc attaches a log func to the syncer.
+// Not thread safe, call before Run.
+func (s *Syncer) AttachLogFunc(f LogFunc) {
+	s.logFunc = f
+}
+
+// Run syncs the list periodically until stopped.
+func (s *Syncer) Run() {
+	for {
+		if err := s.Sync(); err != nil {
+			s.logFunc(err)
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// Stop stops the syncer.
+func (s *Syncer) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+}
+
+// Sync replaces the stored entries of the list with the fetched ones.
+func (s *Syncer) Sync() error {
+	addresses, err := s.fetch()
+	if err != nil {
+		return err
+	}
+
+	entries, err := s.policy.storage.GetPolicyEntries()
+	if err != nil {
+		return fmt.Errorf("could not get policy entries: %w", err)
+	}
+
+	fetched := make(map[common.Address]struct{}, len(addresses))
+	for _, a := range addresses {
+		fetched[a] = struct{}{}
+	}
+
+	for _, e := range entries {
+		if e.Source != s.source {
+			continue
+		}
+		if _, ok := fetched[e.Address]; ok && e.Kind == s.kind {
+			delete(fetched, e.Address)
+			continue
+		}
+		if err := s.policy.storage.DeletePolicyEntry(e.Address, s.source); err != nil {
+			return fmt.Errorf("could not delete policy entry: %w", err)
+		}
+	}
+
+	for a := range fetched {
+		if err := s.policy.storage.UpsertPolicyEntry(Entry{Address: a, Kind: s.kind, Source: s.source}); err != nil {
+			return fmt.Errorf("could not upsert policy entry: %w", err)
+		}
+	}
+
+	return s.policy.Reload()
+}
@@ -0,0 +1,99 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+// DiscoveredChannel is a consumer channel of an identity found on chain.
+type DiscoveredChannel struct {
+	ChainID  int64
+	HermesID common.Address
+	Channel  common.Address
+	// Deployed is false for channels that were topped up but not registered yet.
+	Deployed bool
+	// Operator is the identity controlling the deployed channel.
+	Operator common.Address
+	Balance  *big.Int
+	Settled  *big.Int
+}
+
+// DiscoverChannels finds the consumer channels of the identity on the aggregator chains, see DiscoverChannels.
+func (a *Aggregator) DiscoverChannels(identity common.Address) ([]DiscoveredChannel, error) {
+	return DiscoverChannels(a.channels, identity, a.chains...)
+}
+
+// DiscoverChannels computes the channel address of the identity for every hermes of the given chains
+// and returns the channels that are deployed or hold a balance.
+// It only needs the identity address, so it can be used to restore a wallet from its seed without any stored metadata.
+func DiscoverChannels(channels ChannelReader, identity common.Address, chains ...Chain) ([]DiscoveredChannel, error) {
+	var res []DiscoveredChannel
+	for _, chain := range chains {
+		for _, hermesID := range chain.Hermeses {
+			ch, found, err := discoverChannel(channels, chain, hermesID, identity)
+			if err != nil {
+				return nil, fmt.Errorf("could not discover channel of hermes %v on chain %v: %w", hermesID.Hex(), chain.ChainID, err)
+			}
+			if found {
+				res = append(res, ch)
+			}
+		}
+	}
+	return res, nil
+}
+
+func discoverChannel(channels ChannelReader, chain Chain, hermesID, identity common.Address) (DiscoveredChannel, bool, error) {
+	addr, err := crypto.GenerateChannelAddress(identity.Hex(), hermesID.Hex(), chain.Registry.Hex(), chain.ChannelImplementation.Hex())
+	if err != nil {
+		return DiscoveredChannel{}, false, err
+	}
+
+	ch := DiscoveredChannel{
+		ChainID:  chain.ChainID,
+		HermesID: hermesID,
+		Channel:  common.HexToAddress(addr),
+		Deployed: true,
+		Settled:  new(big.Int),
+	}
+
+	party, err := channels.GetConsumerChannelsHermes(chain.ChainID, ch.Channel)
+	switch {
+	case errors.Is(err, bind.ErrNoCode):
+		ch.Deployed = false
+	case err != nil:
+		return DiscoveredChannel{}, false, fmt.Errorf("could not get channel state: %w", err)
+	default:
+		ch.Operator = party.Operator
+		if party.Settled != nil {
+			ch.Settled.Set(party.Settled)
+		}
+	}
+
+	ch.Balance, err = channels.GetMystBalance(chain.ChainID, chain.MystToken, ch.Channel)
+	if err != nil {
+		return DiscoveredChannel{}, false, fmt.Errorf("could not get balance: %w", err)
+	}
+
+	return ch, ch.Deployed || ch.Balance.Sign() > 0, nil
+}
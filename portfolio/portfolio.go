@@ -0,0 +1,186 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package portfolio aggregates the consumer channels of an identity across hermeses and chains.
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+// ChannelReader reads consumer channel state. The multichain client can be used.
+type ChannelReader interface {
+	GetConsumerChannelsHermes(chainID int64, channelAddress common.Address) (client.ConsumersHermes, error)
+	GetMystBalance(chainID int64, mystSCAddress, address common.Address) (*big.Int, error)
+}
+
+// PromiseStorage returns the promises issued by the consumer.
+type PromiseStorage interface {
+	// GetLatestConsumerPromise returns the promise with the highest amount issued by the consumer to the given hermes.
+	// A nil promise is returned if there are none.
+	GetLatestConsumerPromise(chainID int64, hermesID, consumer common.Address) (*crypto.Promise, error)
+}
+
+// Chain describes the contracts of a single chain.
+type Chain struct {
+	ChainID               int64
+	Registry              common.Address
+	ChannelImplementation common.Address
+	MystToken             common.Address
+	Hermeses              []common.Address
+}
+
+// Position is the state of a single consumer channel.
+type Position struct {
+	ChainID  int64
+	HermesID common.Address
+	Channel  common.Address
+	// Deployed is false for channels that were topped up but not registered yet.
+	Deployed bool
+	// Balance is the myst balance of the channel.
+	Balance *big.Int
+	// Settled is the amount hermes has already settled from the channel.
+	Settled *big.Int
+	// Promised is the amount of the latest promise issued to hermes.
+	Promised *big.Int
+	// Outstanding is the promised amount hermes can still settle, the pending settlements.
+	Outstanding *big.Int
+	// Available is the balance not yet covered by outstanding promises.
+	Available *big.Int
+}
+
+// Totals sums the positions of a single chain.
+type Totals struct {
+	Balance     *big.Int
+	Outstanding *big.Int
+	Available   *big.Int
+}
+
+// Portfolio is the aggregated view of the consumer channels of an identity.
+type Portfolio struct {
+	Identity  common.Address
+	Positions []Position
+	// Totals are keyed by chain id, amounts of different chains are never summed together.
+	Totals map[int64]Totals
+}
+
+// Aggregator builds consumer portfolios.
+type Aggregator struct {
+	channels ChannelReader
+	promises PromiseStorage
+	chains   []Chain
+}
+
+// NewAggregator returns a new portfolio aggregator for the given chains.
+func NewAggregator(channels ChannelReader, promises PromiseStorage, chains ...Chain) *Aggregator {
+	return &Aggregator{
+		channels: channels,
+		promises: promises,
+		chains:   chains,
+	}
+}
+
+// Get returns the portfolio of the identity. Channels without balance, settlements and promises are omitted.
+func (a *Aggregator) Get(identity common.Address) (Portfolio, error) {
+	res := Portfolio{
+		Identity: identity,
+		Totals:   make(map[int64]Totals),
+	}
+
+	for _, chain := range a.chains {
+		totals := Totals{
+			Balance:     new(big.Int),
+			Outstanding: new(big.Int),
+			Available:   new(big.Int),
+		}
+
+		for _, hermesID := range chain.Hermeses {
+			p, err := a.position(chain, hermesID, identity)
+			if err != nil {
+				return Portfolio{}, fmt.Errorf("could not get channel of hermes %v on chain %v: %w", hermesID.Hex(), chain.ChainID, err)
+			}
+			if p.Balance.Sign() == 0 && p.Settled.Sign() == 0 && p.Promised.Sign() == 0 {
+				continue
+			}
+
+			res.Positions = append(res.Positions, p)
+			totals.Balance.Add(totals.Balance, p.Balance)
+			totals.Outstanding.Add(totals.Outstanding, p.Outstanding)
+			totals.Available.Add(totals.Available, p.Available)
+		}
+
+		res.Totals[chain.ChainID] = totals
+	}
+
+	return res, nil
+}
+
+func (a *Aggregator) position(chain Chain, hermesID, identity common.Address) (Position, error) {
+	addr, err := crypto.GenerateChannelAddress(identity.Hex(), hermesID.Hex(), chain.Registry.Hex(), chain.ChannelImplementation.Hex())
+	if err != nil {
+		return Position{}, err
+	}
+
+	p := Position{
+		ChainID:     chain.ChainID,
+		HermesID:    hermesID,
+		Channel:     common.HexToAddress(addr),
+		Deployed:    true,
+		Settled:     new(big.Int),
+		Promised:    new(big.Int),
+		Outstanding: new(big.Int),
+		Available:   new(big.Int),
+	}
+
+	p.Balance, err = a.channels.GetMystBalance(chain.ChainID, chain.MystToken, p.Channel)
+	if err != nil {
+		return Position{}, fmt.Errorf("could not get balance: %w", err)
+	}
+
+	party, err := a.channels.GetConsumerChannelsHermes(chain.ChainID, p.Channel)
+	switch {
+	case errors.Is(err, bind.ErrNoCode):
+		p.Deployed = false
+	case err != nil:
+		return Position{}, fmt.Errorf("could not get channel state: %w", err)
+	case party.Settled != nil:
+		p.Settled.Set(party.Settled)
+	}
+
+	promise, err := a.promises.GetLatestConsumerPromise(chain.ChainID, hermesID, identity)
+	if err != nil {
+		return Position{}, fmt.Errorf("could not get latest promise: %w", err)
+	}
+	if promise != nil {
+		p.Promised.Set(promise.Amount)
+	}
+
+	if p.Promised.Cmp(p.Settled) > 0 {
+		p.Outstanding.Sub(p.Promised, p.Settled)
+	}
+	if p.Balance.Cmp(p.Outstanding) > 0 {
+		p.Available.Sub(p.Balance, p.Outstanding)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,117 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package portfolio
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockChain struct {
+	balances map[int64]*big.Int
+	settled  map[int64]*big.Int
+}
+
+func (mc *mockChain) GetConsumerChannelsHermes(chainID int64, channelAddress common.Address) (client.ConsumersHermes, error) {
+	settled, ok := mc.settled[chainID]
+	if !ok {
+		return client.ConsumersHermes{}, fmt.Errorf("could not get hermes: %w", bind.ErrNoCode)
+	}
+	return client.ConsumersHermes{Settled: settled}, nil
+}
+
+func (mc *mockChain) GetMystBalance(chainID int64, mystSCAddress, address common.Address) (*big.Int, error) {
+	if b, ok := mc.balances[chainID]; ok {
+		return b, nil
+	}
+	return new(big.Int), nil
+}
+
+type mockPromises map[int64]*big.Int
+
+func (mp mockPromises) GetLatestConsumerPromise(chainID int64, hermesID, consumer common.Address) (*crypto.Promise, error) {
+	amount, ok := mp[chainID]
+	if !ok {
+		return nil, nil
+	}
+	return &crypto.Promise{Amount: amount}, nil
+}
+
+func TestAggregator(t *testing.T) {
+	chain := func(id int64) Chain {
+		return Chain{
+			ChainID:               id,
+			Registry:              common.HexToAddress("0x1"),
+			ChannelImplementation: common.HexToAddress("0x2"),
+			Hermeses:              []common.Address{common.HexToAddress("0x3")},
+		}
+	}
+
+	reader := &mockChain{
+		balances: map[int64]*big.Int{1: big.NewInt(100), 2: big.NewInt(50)},
+		settled:  map[int64]*big.Int{1: big.NewInt(30)},
+	}
+	promises := mockPromises{1: big.NewInt(60)}
+
+	p, err := NewAggregator(reader, promises, chain(1), chain(2), chain(3)).Get(common.HexToAddress("0x4"))
+	assert.NoError(t, err)
+	assert.Len(t, p.Positions, 2)
+
+	assert.True(t, p.Positions[0].Deployed)
+	assert.Equal(t, big.NewInt(30), p.Positions[0].Outstanding)
+	assert.Equal(t, big.NewInt(70), p.Positions[0].Available)
+
+	assert.False(t, p.Positions[1].Deployed)
+	assert.Equal(t, big.NewInt(50), p.Totals[2].Available)
+	assert.Equal(t, int64(0), p.Totals[3].Balance.Int64())
+}
+
+func TestDiscoverChannels(t *testing.T) {
+	chain := func(id int64) Chain {
+		return Chain{
+			ChainID:               id,
+			Registry:              common.HexToAddress("0x1"),
+			ChannelImplementation: common.HexToAddress("0x2"),
+			Hermeses:              []common.Address{common.HexToAddress("0x3"), common.HexToAddress("0x5")},
+		}
+	}
+
+	reader := &mockChain{
+		balances: map[int64]*big.Int{2: big.NewInt(50)},
+		settled:  map[int64]*big.Int{1: big.NewInt(30)},
+	}
+
+	found, err := NewAggregator(reader, nil, chain(1), chain(2), chain(3)).DiscoverChannels(common.HexToAddress("0x4"))
+	assert.NoError(t, err)
+	assert.Len(t, found, 4)
+
+	assert.Equal(t, int64(1), found[0].ChainID)
+	assert.True(t, found[0].Deployed)
+	assert.Equal(t, big.NewInt(30), found[0].Settled)
+	assert.NotEqual(t, found[0].Channel, found[1].Channel)
+
+	assert.Equal(t, int64(2), found[2].ChainID)
+	assert.False(t, found[2].Deployed)
+	assert.Equal(t, big.NewInt(50), found[2].Balance)
+}
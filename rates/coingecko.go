@@ -0,0 +1,176 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rates
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mysteriumnetwork/payments/internal/singleflight"
+)
+
+// CoinGeckoURL is the public CoinGecko API URL.
+const CoinGeckoURL = "https://api.coingecko.com/api/v3"
+
+// CoinGeckoMystID is the CoinGecko coin id of myst.
+const CoinGeckoMystID = "mysterium"
+
+// CoinGecko is a price oracle backed by the CoinGecko API.
+// Historical prices are daily and are cached forever, current prices are cached for the given ttl.
+type CoinGecko struct {
+	client  *http.Client
+	baseURL string
+	coinID  string
+	ttl     time.Duration
+	now     func() time.Time
+
+	lock       sync.Mutex
+	historical map[string]float64
+	current    map[string]cachedPrice
+	// fetches deduplicates concurrent requests of the same price.
+	fetches singleflight.Group
+}
+
+type cachedPrice struct {
+	price   float64
+	fetched time.Time
+}
+
+// NewCoinGecko returns a new CoinGecko price oracle.
+func NewCoinGecko(client *http.Client, baseURL string, ttl time.Duration) *CoinGecko {
+	return &CoinGecko{
+		client:     client,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		coinID:     CoinGeckoMystID,
+		ttl:        ttl,
+		now:        time.Now,
+		historical: make(map[string]float64),
+		current:    make(map[string]cachedPrice),
+	}
+}
+
+// PriceAt returns the price of myst in the given currency.
+// Times within the current day return the current price, older times return the daily historical price.
+func (cg *CoinGecko) PriceAt(currency string, at time.Time) (float64, error) {
+	currency = strings.ToLower(currency)
+	now := cg.now().UTC()
+	day := at.UTC().Format("02-01-2006")
+	if day == now.Format("02-01-2006") || at.After(now) {
+		return cg.currentPrice(currency, now)
+	}
+	return cg.historicalPrice(currency, day)
+}
+
+func (cg *CoinGecko) currentPrice(currency string, now time.Time) (float64, error) {
+	cg.lock.Lock()
+	cached, ok := cg.current[currency]
+	cg.lock.Unlock()
+	if ok && now.Sub(cached.fetched) < cg.ttl {
+		return cached.price, nil
+	}
+
+	return cg.fetch("current@"+currency, func() (float64, error) {
+		q := url.Values{}
+		q.Set("ids", cg.coinID)
+		q.Set("vs_currencies", currency)
+
+		var res map[string]map[string]float64
+		if err := cg.get("/simple/price", q, &res); err != nil {
+			return 0, err
+		}
+
+		price, ok := res[cg.coinID][currency]
+		if !ok {
+			return 0, fmt.Errorf("%w: %v", ErrUnsupportedCurrency, currency)
+		}
+
+		cg.lock.Lock()
+		cg.current[currency] = cachedPrice{price: price, fetched: now}
+		cg.lock.Unlock()
+		return price, nil
+	})
+}
+
+func (cg *CoinGecko) historicalPrice(currency, day string) (float64, error) {
+	key := currency + "@" + day
+
+	cg.lock.Lock()
+	price, ok := cg.historical[key]
+	cg.lock.Unlock()
+	if ok {
+		return price, nil
+	}
+
+	return cg.fetch(key, func() (float64, error) {
+		q := url.Values{}
+		q.Set("date", day)
+		q.Set("localization", "false")
+
+		var res struct {
+			MarketData struct {
+				CurrentPrice map[string]float64 `json:"current_price"`
+			} `json:"market_data"`
+		}
+		if err := cg.get("/coins/"+cg.coinID+"/history", q, &res); err != nil {
+			return 0, err
+		}
+
+		price, ok := res.MarketData.CurrentPrice[currency]
+		if !ok {
+			return 0, fmt.Errorf("%w: %v on %v", ErrUnsupportedCurrency, currency, day)
+		}
+
+		cg.lock.Lock()
+		cg.historical[key] = price
+		cg.lock.Unlock()
+		return price, nil
+	})
+}
+
+// fetch calls fn once for all the concurrent callers asking for the same key.
+// The lock is not held while fn runs, so requests of other keys and cache hits are not blocked by it.
+func (cg *CoinGecko) fetch(key string, fn func() (float64, error)) (float64, error) {
+	res, err := cg.fetches.Do(key, func() (interface{}, error) {
+		return fn()
+	})
+	if err != nil {
+		return 0, err
+	}
+	return res.(float64), nil
+}
+
+func (cg *CoinGecko) get(path string, q url.Values, res interface{}) error {
+	resp, err := cg.client.Get(cg.baseURL + path + "?" + q.Encode())
+	if err != nil {
+		return fmt.Errorf("could not get price: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not get price: unexpected status %v", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return fmt.Errorf("could not decode price: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rates
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mysteriumnetwork/payments/analytics"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoinGecko(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/simple/price":
+			fmt.Fprint(w, `{"mysterium":{"usd":0.5}}`)
+		case "/coins/mysterium/history":
+			assert.Equal(t, "01-02-2021", r.URL.Query().Get("date"))
+			fmt.Fprint(w, `{"market_data":{"current_price":{"usd":0.25}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	cg := NewCoinGecko(srv.Client(), srv.URL, time.Minute)
+	cg.now = func() time.Time { return now }
+
+	price, err := cg.PriceAt("USD", now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, price)
+
+	_, err = cg.PriceAt("usd", now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+
+	_, err = cg.PriceAt("eur", now)
+	assert.True(t, errors.Is(err, ErrUnsupportedCurrency))
+
+	settlements, err := AnnotateSettlements(cg, "usd", []analytics.Settlement{{
+		Amount:    crypto.FloatToBigMyst(4),
+		Fees:      crypto.FloatToBigMyst(1),
+		BlockTime: time.Date(2021, 2, 1, 8, 0, 0, 0, time.UTC),
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, settlements[0].FiatAmount)
+	assert.Equal(t, 0.25, settlements[0].FiatFees)
+}
+
+func TestCoinGeckoFetchesOutsideOfLock(t *testing.T) {
+	var calls int64
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		switch r.URL.Path {
+		case "/simple/price":
+			fmt.Fprint(w, `{"mysterium":{"usd":0.5}}`)
+		case "/coins/mysterium/history":
+			started <- struct{}{}
+			<-release
+			fmt.Fprint(w, `{"market_data":{"current_price":{"usd":0.25}}}`)
+		}
+	}))
+	defer srv.Close()
+
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	cg := NewCoinGecko(srv.Client(), srv.URL, time.Minute)
+	cg.now = func() time.Time { return now }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			price, err := cg.PriceAt("usd", now.AddDate(0, -1, 0))
+			assert.NoError(t, err)
+			assert.Equal(t, 0.25, price)
+		}()
+	}
+
+	// the current price is served while the historical one is being fetched
+	<-started
+	price, err := cg.PriceAt("usd", now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, price)
+
+	close(release)
+	wg.Wait()
+	assert.LessOrEqual(t, atomic.LoadInt64(&calls), int64(4))
+
+	_, err = cg.PriceAt("usd", now.AddDate(0, -1, 0))
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, atomic.LoadInt64(&calls), int64(4))
+}
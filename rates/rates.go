@@ -0,0 +1,111 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package rates provides fiat valuation of myst amounts.
+package rates
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/mysteriumnetwork/payments/analytics"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+// ErrUnsupportedCurrency is returned when the oracle has no price for the requested currency.
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// PriceOracle returns the price of a single myst in the given fiat currency.
+type PriceOracle interface {
+	// PriceAt returns the price at the given time. Currency is an ISO 4217 code, such as "usd".
+	PriceAt(currency string, at time.Time) (float64, error)
+}
+
+// FiatValue converts the given myst amount to fiat using the given price.
+func FiatValue(amount *big.Int, price float64) float64 {
+	return TokenFiatValue(amount, crypto.MystDecimals, price)
+}
+
+// TokenFiatValue converts the amount of a token with the given decimals to fiat using the given price of a single token.
+func TokenFiatValue(amount *big.Int, decimals uint8, price float64) float64 {
+	if amount == nil {
+		return 0
+	}
+	return crypto.AmountToFloat(amount, decimals) * price
+}
+
+// FiatSettlement is a settlement annotated with its fiat value at the block time.
+type FiatSettlement struct {
+	analytics.Settlement
+	Currency   string
+	Price      float64
+	FiatAmount float64
+	FiatFees   float64
+}
+
+// AnnotateSettlements values the given settlements in fiat at their block time.
+func AnnotateSettlements(oracle PriceOracle, currency string, settlements []analytics.Settlement) ([]FiatSettlement, error) {
+	currency = strings.ToLower(currency)
+	res := make([]FiatSettlement, len(settlements))
+	for i, s := range settlements {
+		price, err := oracle.PriceAt(currency, s.BlockTime)
+		if err != nil {
+			return nil, err
+		}
+
+		res[i] = FiatSettlement{
+			Settlement: s,
+			Currency:   currency,
+			Price:      price,
+			FiatAmount: FiatValue(s.Amount, price),
+			FiatFees:   FiatValue(s.Fees, price),
+		}
+	}
+	return res, nil
+}
+
+// FiatSettlementReport is a settlement report annotated with fiat values.
+type FiatSettlementReport struct {
+	client.SettlementReport
+	Currency              string
+	Price                 float64
+	FiatBeneficiaryPayout float64
+	FiatHermesFee         float64
+	FiatTransactorFee     float64
+	FiatStakeIncrease     float64
+}
+
+// AnnotateSettlementReport values the given settlement report in fiat at the given time.
+func AnnotateSettlementReport(oracle PriceOracle, currency string, report client.SettlementReport, at time.Time) (FiatSettlementReport, error) {
+	currency = strings.ToLower(currency)
+	price, err := oracle.PriceAt(currency, at)
+	if err != nil {
+		return FiatSettlementReport{}, err
+	}
+
+	return FiatSettlementReport{
+		SettlementReport:      report,
+		Currency:              currency,
+		Price:                 price,
+		FiatBeneficiaryPayout: FiatValue(report.BeneficiaryPayout, price),
+		FiatHermesFee:         FiatValue(report.HermesFee, price),
+		FiatTransactorFee:     FiatValue(report.TransactorFee, price),
+		FiatStakeIncrease:     FiatValue(report.StakeIncrease, price),
+	}, nil
+}
@@ -0,0 +1,164 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package registration
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/bindings"
+)
+
+// RegistrationChecker checks the registration status on chain, the client can be used.
+type RegistrationChecker interface {
+	IsRegistered(registryAddress, addressToCheck common.Address) (bool, error)
+}
+
+// Status is the registration status of an identity.
+type Status struct {
+	Identity   common.Address
+	Registered bool
+	// Beneficiary, TxHash and BlockNumber are only known for registrations seen as RegisteredIdentity events.
+	Beneficiary common.Address
+	TxHash      common.Hash
+	BlockNumber uint64
+}
+
+type cachedStatus struct {
+	status    Status
+	checkedAt time.Time
+}
+
+// StatusCache keeps the registration status of identities up to date from RegisteredIdentity events,
+// so that IsRegistered is only called for identities the cache knows nothing about.
+// Identities found unregistered are rechecked after the negative TTL, in case an event was missed.
+type StatusCache struct {
+	checker     RegistrationChecker
+	registry    common.Address
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	lock     sync.Mutex
+	statuses map[common.Address]cachedStatus
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// NewStatusCache returns a new registration status cache of the given registry.
+func NewStatusCache(checker RegistrationChecker, registry common.Address, negativeTTL time.Duration) *StatusCache {
+	return &StatusCache{
+		checker:     checker,
+		registry:    registry,
+		negativeTTL: negativeTTL,
+		now:         time.Now,
+		statuses:    make(map[common.Address]cachedStatus),
+		stop:        make(chan struct{}),
+	}
+}
+
+// Status returns the registration status of the identity, from the cache if possible.
+func (sc *StatusCache) Status(identity common.Address) (Status, error) {
+	sc.lock.Lock()
+	cached, ok := sc.statuses[identity]
+	sc.lock.Unlock()
+
+	if ok && (cached.status.Registered || sc.now().Sub(cached.checkedAt) < sc.negativeTTL) {
+		return cached.status, nil
+	}
+
+	registered, err := sc.checker.IsRegistered(sc.registry, identity)
+	if err != nil {
+		return Status{}, err
+	}
+
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	// An event may have been processed while checking, it carries more details.
+	if current, ok := sc.statuses[identity]; ok && current.status.Registered {
+		return current.status, nil
+	}
+	status := Status{Identity: identity, Registered: registered}
+	sc.statuses[identity] = cachedStatus{status: status, checkedAt: sc.now()}
+	return status, nil
+}
+
+// IsRegistered returns true if the identity is registered.
+func (sc *StatusCache) IsRegistered(identity common.Address) (bool, error) {
+	status, err := sc.Status(identity)
+	return status.Registered, err
+}
+
+// Process updates the cache with the registration event. Removed events invalidate the identity.
+func (sc *StatusCache) Process(ev *bindings.RegistryRegisteredIdentity) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+
+	if ev.Raw.Removed {
+		if current, ok := sc.statuses[ev.Identity]; ok && current.status.TxHash == ev.Raw.TxHash {
+			delete(sc.statuses, ev.Identity)
+		}
+		return
+	}
+
+	sc.statuses[ev.Identity] = cachedStatus{
+		status: Status{
+			Identity:    ev.Identity,
+			Registered:  true,
+			Beneficiary: ev.Beneficiary,
+			TxHash:      ev.Raw.TxHash,
+			BlockNumber: ev.Raw.BlockNumber,
+		},
+		checkedAt: sc.now(),
+	}
+}
+
+// Reorg invalidates the registrations seen in the given block or later.
+// Use it when a reorg is detected by other means than removed events, e.g. by a checkpointing indexer.
+func (sc *StatusCache) Reorg(fromBlock uint64) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+
+	for identity, cached := range sc.statuses {
+		if cached.status.BlockNumber >= fromBlock && cached.status.TxHash != (common.Hash{}) {
+			delete(sc.statuses, identity)
+		}
+	}
+}
+
+// Consume processes the events until the channel is closed or the cache is stopped.
+// The sink of client.SubscribeToIdentityRegistrationEvents can be consumed directly.
+func (sc *StatusCache) Consume(events <-chan *bindings.RegistryRegisteredIdentity) {
+	for {
+		select {
+		case <-sc.stop:
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			sc.Process(ev)
+		}
+	}
+}
+
+// Stop stops consuming events.
+func (sc *StatusCache) Stop() {
+	sc.once.Do(func() {
+		close(sc.stop)
+	})
+}
@@ -0,0 +1,87 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package registration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockChecker struct {
+	registered map[common.Address]bool
+	calls      int
+}
+
+func (mc *mockChecker) IsRegistered(registryAddress, addressToCheck common.Address) (bool, error) {
+	mc.calls++
+	return mc.registered[addressToCheck], nil
+}
+
+func TestStatusCache(t *testing.T) {
+	identity := common.HexToAddress("0x1")
+	checker := &mockChecker{registered: map[common.Address]bool{}}
+	now := time.Now()
+	sc := NewStatusCache(checker, common.HexToAddress("0xaa"), time.Minute)
+	sc.now = func() time.Time { return now }
+
+	registered, err := sc.IsRegistered(identity)
+	assert.NoError(t, err)
+	assert.False(t, registered)
+	_, _ = sc.IsRegistered(identity)
+	assert.Equal(t, 1, checker.calls)
+
+	ev := &bindings.RegistryRegisteredIdentity{
+		Identity:    identity,
+		Beneficiary: common.HexToAddress("0x2"),
+		Raw:         types.Log{TxHash: common.HexToHash("0x3"), BlockNumber: 10},
+	}
+	sc.Process(ev)
+	status, err := sc.Status(identity)
+	assert.NoError(t, err)
+	assert.True(t, status.Registered)
+	assert.Equal(t, ev.Raw.TxHash, status.TxHash)
+	assert.Equal(t, 1, checker.calls)
+
+	// the reorged registration is checked on chain again
+	removed := *ev
+	removed.Raw.Removed = true
+	sc.Process(&removed)
+	status, err = sc.Status(identity)
+	assert.NoError(t, err)
+	assert.False(t, status.Registered)
+	assert.Equal(t, 2, checker.calls)
+
+	sc.Process(ev)
+	sc.Reorg(11)
+	assert.True(t, sc.statuses[identity].status.Registered)
+	sc.Reorg(10)
+	_, ok := sc.statuses[identity]
+	assert.False(t, ok)
+
+	// negative results expire
+	now = now.Add(2 * time.Minute)
+	checker.registered[identity] = true
+	status, err = sc.Status(identity)
+	assert.NoError(t, err)
+	assert.True(t, status.Registered)
+	assert.Equal(t, common.Hash{}, status.TxHash)
+}
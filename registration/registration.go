@@ -17,6 +17,7 @@
 package registration
 
 import (
+	"encoding/json"
 	"math/big"
 	"strings"
 
@@ -35,6 +36,31 @@ type Request struct {
 	RegistryAddress string   `json:"registryAddress"`
 }
 
+// MarshalJSON encodes the request with decimal string amounts.
+func (r Request) MarshalJSON() ([]byte, error) {
+	type request Request
+	return json.Marshal(struct {
+		request
+		Stake *crypto.BigInt `json:"stake"`
+		Fee   *crypto.BigInt `json:"fee"`
+	}{request(r), (*crypto.BigInt)(r.Stake), (*crypto.BigInt)(r.Fee)})
+}
+
+// UnmarshalJSON decodes the request, accepting decimal string and number amounts.
+func (r *Request) UnmarshalJSON(data []byte) error {
+	type request Request
+	aux := struct {
+		*request
+		Stake *crypto.BigInt `json:"stake"`
+		Fee   *crypto.BigInt `json:"fee"`
+	}{request: (*request)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.Stake, r.Fee = aux.Stake.Int(), aux.Fee.Int()
+	return nil
+}
+
 // GetStakeAmount returns a big int representation for the stake amount
 func (r Request) GetStakeAmount() *big.Int {
 	return r.Stake
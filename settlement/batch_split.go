@@ -0,0 +1,202 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+)
+
+// DefaultBlockGasFraction is the part of the block gas limit a single batched settlement transaction may use.
+// Transactions close to the block gas limit are hard to get mined, so the batches are split well below it.
+const DefaultBlockGasFraction = 0.5
+
+// ErrCallExceedsBlockGas is returned when a single settlement of a batch does not fit within the gas allowed per transaction.
+var ErrCallExceedsBlockGas = errors.New("settlement exceeds the gas allowed per transaction")
+
+// ErrPartNotSent is set on the batch parts that were not sent because a preceding part failed.
+var ErrPartNotSent = errors.New("batch part not sent, a preceding part failed")
+
+// HeaderGetter returns block headers, the client can be used.
+type HeaderGetter interface {
+	HeaderByNumber(number *big.Int) (*types.Header, error)
+}
+
+// EstimateBatchFunc estimates the gas of a batched settlement transaction executing the given calls, e.g. a hermes multicall.
+type EstimateBatchFunc func(calls []client.Calldata) (uint64, error)
+
+// SendBatchFunc submits a batched settlement transaction executing the given calls.
+type SendBatchFunc func(calls []client.Calldata, gasLimit uint64, gasPrice, nonce *big.Int) (*types.Transaction, error)
+
+// BatchPart is a single transaction of a split batched settlement.
+type BatchPart struct {
+	// Offset is the index of the first call of the part in the whole batch.
+	Offset int
+	Calls  []client.Calldata
+	Gas    uint64
+	Tx     *types.Transaction
+	Err    error
+}
+
+// BatchReport is the aggregate outcome of a batched settlement.
+type BatchReport struct {
+	// GasLimit is the gas allowed per transaction the batch was split by.
+	GasLimit uint64
+	// Parts are in the order of the calls, each was sent with the nonce following the previous one.
+	Parts []BatchPart
+}
+
+// Gas returns the sum of the gas estimates of all the parts.
+func (br BatchReport) Gas() uint64 {
+	var total uint64
+	for _, p := range br.Parts {
+		total += p.Gas
+	}
+	return total
+}
+
+// Sent returns the number of calls that were submitted.
+func (br BatchReport) Sent() int {
+	var sent int
+	for _, p := range br.Parts {
+		if p.Err == nil {
+			sent += len(p.Calls)
+		}
+	}
+	return sent
+}
+
+// Err returns the error of the first failed part, nil if all the parts were sent.
+func (br BatchReport) Err() error {
+	for _, p := range br.Parts {
+		if p.Err != nil {
+			return fmt.Errorf("batch part at offset %v: %w", p.Offset, p.Err)
+		}
+	}
+	return nil
+}
+
+// BatchSettler submits batched settlements, splitting the batches whose gas estimate exceeds
+// a safe fraction of the block gas limit into several transactions.
+// The parts are sent back to back with a single gas price and consecutive nonces, so the call order is preserved.
+type BatchSettler struct {
+	headers  HeaderGetter
+	gas      GasPricer
+	nonces   NonceFunc
+	estimate EstimateBatchFunc
+	send     SendBatchFunc
+	fraction float64
+}
+
+// NewBatchSettler returns a new batch settler allowing DefaultBlockGasFraction of the block gas limit per transaction.
+func NewBatchSettler(headers HeaderGetter, gas GasPricer, nonces NonceFunc, estimate EstimateBatchFunc, send SendBatchFunc) *BatchSettler {
+	return &BatchSettler{
+		headers:  headers,
+		gas:      gas,
+		nonces:   nonces,
+		estimate: estimate,
+		send:     send,
+		fraction: DefaultBlockGasFraction,
+	}
+}
+
+// SetBlockGasFraction sets the part of the block gas limit a single transaction may use, between 0 and 1.
+// Not thread safe, call before settling.
+func (bs *BatchSettler) SetBlockGasFraction(fraction float64) {
+	bs.fraction = fraction
+}
+
+// Settle submits the calls from the sender, split into as few transactions as the gas allowed per transaction permits.
+// An error is returned if the batch could not be planned, nothing is sent then.
+// Failures of the individual parts are reported in the BatchReport, see BatchReport.Err.
+func (bs *BatchSettler) Settle(sender common.Address, calls []client.Calldata) (BatchReport, error) {
+	if len(calls) == 0 {
+		return BatchReport{}, nil
+	}
+
+	header, err := bs.headers.HeaderByNumber(nil)
+	if err != nil {
+		return BatchReport{}, fmt.Errorf("could not get block gas limit: %w", err)
+	}
+
+	report := BatchReport{GasLimit: uint64(float64(header.GasLimit) * bs.fraction)}
+	report.Parts, err = bs.split(calls, 0, report.GasLimit)
+	if err != nil {
+		return BatchReport{}, err
+	}
+
+	gasPrice, err := bs.gas.SuggestGasPrice()
+	if err != nil {
+		return BatchReport{}, fmt.Errorf("could not get gas price: %w", err)
+	}
+
+	nonce, err := bs.nonces(sender)
+	if err != nil {
+		return BatchReport{}, fmt.Errorf("could not get nonce: %w", err)
+	}
+
+	failed := false
+	for i := range report.Parts {
+		p := &report.Parts[i]
+		if failed {
+			p.Err = ErrPartNotSent
+			continue
+		}
+
+		p.Tx, p.Err = bs.send(p.Calls, p.Gas, new(big.Int).Set(gasPrice), new(big.Int).SetUint64(nonce))
+		if p.Err != nil {
+			// the following parts would be stuck behind the nonce gap, or executed out of order
+			failed = true
+			continue
+		}
+		nonce++
+	}
+
+	return report, nil
+}
+
+// split halves the calls until every part fits within the gas limit, keeping the call order.
+// Failed estimates of several calls are split as well, batches far above the limit usually fail to estimate.
+func (bs *BatchSettler) split(calls []client.Calldata, offset int, limit uint64) ([]BatchPart, error) {
+	gas, err := bs.estimate(calls)
+	if err == nil && gas <= limit {
+		return []BatchPart{{Offset: offset, Calls: calls, Gas: gas}}, nil
+	}
+
+	if len(calls) == 1 {
+		if err != nil {
+			return nil, fmt.Errorf("could not estimate settlement at offset %v: %w", offset, err)
+		}
+		return nil, fmt.Errorf("%w: settlement at offset %v needs %v gas, %v allowed", ErrCallExceedsBlockGas, offset, gas, limit)
+	}
+
+	mid := len(calls) / 2
+	left, err := bs.split(calls[:mid], offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	right, err := bs.split(calls[mid:], offset+mid, limit)
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
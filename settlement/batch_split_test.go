@@ -0,0 +1,105 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedGasLimit uint64
+
+func (gl fixedGasLimit) HeaderByNumber(number *big.Int) (*types.Header, error) {
+	return &types.Header{GasLimit: uint64(gl)}, nil
+}
+
+// estimatePerCall estimates 100 gas per call and fails batches of more than 6 calls.
+func estimatePerCall(calls []client.Calldata) (uint64, error) {
+	if len(calls) > 6 {
+		return 0, errors.New("gas required exceeds allowance")
+	}
+	return uint64(100 * len(calls)), nil
+}
+
+func testCalls(n int) []client.Calldata {
+	calls := make([]client.Calldata, n)
+	for i := range calls {
+		calls[i] = client.Calldata{Data: []byte{byte(i)}}
+	}
+	return calls
+}
+
+func TestBatchSettlerSplits(t *testing.T) {
+	var sent [][]byte
+	var nonces []uint64
+	send := func(calls []client.Calldata, gasLimit uint64, gasPrice, nonce *big.Int) (*types.Transaction, error) {
+		assert.Equal(t, big.NewInt(7), gasPrice)
+		for _, c := range calls {
+			sent = append(sent, c.Data)
+		}
+		nonces = append(nonces, nonce.Uint64())
+		return types.NewTransaction(nonce.Uint64(), common.Address{}, nil, gasLimit, gasPrice, nil), nil
+	}
+	nonceFunc := func(sender common.Address) (uint64, error) { return 3, nil }
+
+	bs := NewBatchSettler(fixedGasLimit(1000), fixedGasPrice{}, nonceFunc, estimatePerCall, send)
+	report, err := bs.Settle(common.HexToAddress("0x1"), testCalls(10))
+	assert.NoError(t, err)
+	assert.NoError(t, report.Err())
+
+	assert.Equal(t, uint64(500), report.GasLimit)
+	assert.Len(t, report.Parts, 2)
+	assert.Equal(t, []int{0, 5}, []int{report.Parts[0].Offset, report.Parts[1].Offset})
+	assert.Equal(t, uint64(1000), report.Gas())
+	assert.Equal(t, 10, report.Sent())
+	assert.Equal(t, []uint64{3, 4}, nonces)
+	for i, data := range sent {
+		assert.Equal(t, []byte{byte(i)}, data)
+	}
+
+	bs.SetBlockGasFraction(0.05)
+	_, err = bs.Settle(common.HexToAddress("0x1"), testCalls(2))
+	assert.True(t, errors.Is(err, ErrCallExceedsBlockGas))
+}
+
+func TestBatchSettlerStopsAfterFailedPart(t *testing.T) {
+	parts := 0
+	send := func(calls []client.Calldata, gasLimit uint64, gasPrice, nonce *big.Int) (*types.Transaction, error) {
+		parts++
+		if parts == 2 {
+			return nil, errors.New("boom")
+		}
+		return types.NewTransaction(nonce.Uint64(), common.Address{}, nil, gasLimit, gasPrice, nil), nil
+	}
+	nonceFunc := func(sender common.Address) (uint64, error) { return 0, nil }
+
+	bs := NewBatchSettler(fixedGasLimit(400), fixedGasPrice{}, nonceFunc, estimatePerCall, send)
+	report, err := bs.Settle(common.HexToAddress("0x1"), testCalls(6))
+	assert.NoError(t, err)
+	assert.Len(t, report.Parts, 4)
+	assert.Error(t, report.Err())
+	assert.Equal(t, 2, parts)
+	assert.Equal(t, 1, report.Sent())
+	assert.Equal(t, ErrPartNotSent, report.Parts[2].Err)
+	assert.Equal(t, ErrPartNotSent, report.Parts[3].Err)
+}
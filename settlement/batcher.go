@@ -0,0 +1,221 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package settlement contains helpers for scheduling and submitting settlements.
+package settlement
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+// SendFunc submits the settlement transaction with the given gas price and nonce,
+// usually by setting them on the WriteRequest of a client settle request.
+type SendFunc func(gasPrice, nonce *big.Int) (*types.Transaction, error)
+
+// DoneFunc is called with the outcome of a submitted settlement.
+// It is called with ErrReplaced if a job for the same channel with a higher amount replaced it.
+type DoneFunc func(tx *types.Transaction, err error)
+
+// Job is a settlement waiting to be submitted.
+type Job struct {
+	Beneficiary common.Address
+	// Sender is the address sending the transaction, its nonces are assigned consecutively within a batch.
+	Sender    common.Address
+	ChannelID [32]byte
+	// Amount is the promise amount. Only the job with the highest amount per channel is submitted.
+	Amount *big.Int
+	// ValidUntil is the end of the promise validity window, zero if the promise does not expire.
+	// The batch is submitted early enough for the job to make it in time, expired jobs are not submitted.
+	ValidUntil time.Time
+	Send       SendFunc
+	Done       DoneFunc
+}
+
+// ErrReplaced is passed to DoneFunc of jobs replaced by a job for the same channel with a higher amount.
+var ErrReplaced = errors.New("settlement replaced by a higher promise")
+
+// ExpiryMargin is the time left before the promise expiry at which a batch is submitted.
+var ExpiryMargin = time.Minute
+
+// ErrStopped is returned when adding jobs to a stopped batcher.
+var ErrStopped = errors.New("batcher stopped")
+
+// GasPricer suggests the gas price.
+type GasPricer interface {
+	SuggestGasPrice() (*big.Int, error)
+}
+
+// NonceFunc returns the next nonce of the sender.
+type NonceFunc func(sender common.Address) (uint64, error)
+
+// Batcher holds settlements for the same beneficiary within a window and then submits them back to back
+// with a single gas price and consecutive nonces.
+type Batcher struct {
+	window time.Duration
+	gas    GasPricer
+	nonces NonceFunc
+
+	lock    sync.Mutex
+	pending map[common.Address]*batch
+	stopped bool
+}
+
+type batch struct {
+	jobs    map[[32]byte]Job
+	order   [][32]byte
+	timer   *time.Timer
+	flushAt time.Time
+}
+
+// NewBatcher returns a new settlement batcher.
+func NewBatcher(window time.Duration, gas GasPricer, nonces NonceFunc) *Batcher {
+	return &Batcher{
+		window:  window,
+		gas:     gas,
+		nonces:  nonces,
+		pending: make(map[common.Address]*batch),
+	}
+}
+
+// Add queues the settlement. The first job for a beneficiary opens its window.
+func (b *Batcher) Add(job Job) error {
+	replaced, err := b.add(job)
+	if err != nil {
+		return err
+	}
+	// done runs user callbacks, call it without holding the lock so they can use the batcher.
+	if replaced != nil {
+		done(*replaced, nil, ErrReplaced)
+	}
+	return nil
+}
+
+// add queues the job and returns the job it replaced, if any.
+func (b *Batcher) add(job Job) (*Job, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.stopped {
+		return nil, ErrStopped
+	}
+
+	bt, ok := b.pending[job.Beneficiary]
+	if !ok {
+		bt = &batch{jobs: make(map[[32]byte]Job)}
+		beneficiary := job.Beneficiary
+		bt.flushAt = time.Now().Add(b.window)
+		bt.timer = time.AfterFunc(b.window, func() { b.Flush(beneficiary) })
+		b.pending[job.Beneficiary] = bt
+	}
+
+	if !job.ValidUntil.IsZero() {
+		if deadline := job.ValidUntil.Add(-ExpiryMargin); deadline.Before(bt.flushAt) {
+			bt.flushAt = deadline
+			bt.timer.Reset(time.Until(deadline))
+		}
+	}
+
+	existing, ok := bt.jobs[job.ChannelID]
+	if !ok {
+		bt.order = append(bt.order, job.ChannelID)
+	} else if existing.Amount.Cmp(job.Amount) >= 0 {
+		return &job, nil
+	}
+	bt.jobs[job.ChannelID] = job
+	if ok {
+		return &existing, nil
+	}
+	return nil, nil
+}
+
+// Flush submits the pending settlements of the beneficiary without waiting for the window to close.
+func (b *Batcher) Flush(beneficiary common.Address) {
+	b.lock.Lock()
+	bt, ok := b.pending[beneficiary]
+	if ok {
+		bt.timer.Stop()
+		delete(b.pending, beneficiary)
+	}
+	b.lock.Unlock()
+
+	if ok {
+		b.submit(bt)
+	}
+}
+
+// Stop submits all the pending settlements and stops accepting new ones.
+func (b *Batcher) Stop() {
+	b.lock.Lock()
+	b.stopped = true
+	beneficiaries := make([]common.Address, 0, len(b.pending))
+	for beneficiary := range b.pending {
+		beneficiaries = append(beneficiaries, beneficiary)
+	}
+	b.lock.Unlock()
+
+	for _, beneficiary := range beneficiaries {
+		b.Flush(beneficiary)
+	}
+}
+
+func (b *Batcher) submit(bt *batch) {
+	gasPrice, err := b.gas.SuggestGasPrice()
+	if err != nil {
+		for _, id := range bt.order {
+			done(bt.jobs[id], nil, fmt.Errorf("could not get gas price: %w", err))
+		}
+		return
+	}
+
+	nonces := make(map[common.Address]uint64)
+	for _, id := range bt.order {
+		job := bt.jobs[id]
+		if !job.ValidUntil.IsZero() && time.Now().After(job.ValidUntil) {
+			done(job, nil, crypto.ErrPromiseExpired)
+			continue
+		}
+
+		nonce, ok := nonces[job.Sender]
+		if !ok {
+			nonce, err = b.nonces(job.Sender)
+			if err != nil {
+				done(job, nil, fmt.Errorf("could not get nonce: %w", err))
+				continue
+			}
+		}
+
+		tx, err := job.Send(new(big.Int).Set(gasPrice), new(big.Int).SetUint64(nonce))
+		if err == nil {
+			nonce++
+		}
+		nonces[job.Sender] = nonce
+		done(job, tx, err)
+	}
+}
+
+func done(job Job, tx *types.Transaction, err error) {
+	if job.Done != nil {
+		job.Done(tx, err)
+	}
+}
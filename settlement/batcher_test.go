@@ -0,0 +1,136 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedGasPrice struct{}
+
+func (fixedGasPrice) SuggestGasPrice() (*big.Int, error) {
+	return big.NewInt(7), nil
+}
+
+func TestBatcher(t *testing.T) {
+	var lock sync.Mutex
+	var sent []uint64
+	var replaced int
+
+	nonceCalls := 0
+	b := NewBatcher(time.Hour, fixedGasPrice{}, func(sender common.Address) (uint64, error) {
+		nonceCalls++
+		return 10, nil
+	})
+
+	job := func(channel byte, amount int64) Job {
+		return Job{
+			Beneficiary: common.HexToAddress("0x1"),
+			Sender:      common.HexToAddress("0x2"),
+			ChannelID:   [32]byte{channel},
+			Amount:      big.NewInt(amount),
+			Send: func(gasPrice, nonce *big.Int) (*types.Transaction, error) {
+				assert.Equal(t, big.NewInt(7), gasPrice)
+				lock.Lock()
+				defer lock.Unlock()
+				sent = append(sent, nonce.Uint64())
+				return types.NewTransaction(nonce.Uint64(), common.Address{}, nil, 0, gasPrice, nil), nil
+			},
+			Done: func(tx *types.Transaction, err error) {
+				if err == ErrReplaced {
+					replaced++
+				}
+			},
+		}
+	}
+
+	assert.NoError(t, b.Add(job(1, 10)))
+	assert.NoError(t, b.Add(job(2, 10)))
+	assert.NoError(t, b.Add(job(1, 20)))
+	assert.NoError(t, b.Add(job(1, 15)))
+
+	b.Stop()
+	assert.Equal(t, []uint64{10, 11}, sent)
+	assert.Equal(t, 2, replaced)
+	assert.Equal(t, 1, nonceCalls)
+	assert.Error(t, b.Add(job(3, 1)))
+}
+
+func TestBatcherFlushesBeforeExpiry(t *testing.T) {
+	b := NewBatcher(time.Hour, fixedGasPrice{}, func(sender common.Address) (uint64, error) {
+		return 0, nil
+	})
+
+	sent := make(chan struct{}, 1)
+	err := b.Add(Job{
+		Beneficiary: common.HexToAddress("0x1"),
+		Amount:      big.NewInt(1),
+		ValidUntil:  time.Now().Add(ExpiryMargin + 50*time.Millisecond),
+		Send: func(gasPrice, nonce *big.Int) (*types.Transaction, error) {
+			sent <- struct{}{}
+			return nil, nil
+		},
+	})
+	assert.NoError(t, err)
+
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch not flushed before promise expiry")
+	}
+}
+
+func TestBatcherReplacedCallbackCanUseBatcher(t *testing.T) {
+	b := NewBatcher(time.Hour, fixedGasPrice{}, func(sender common.Address) (uint64, error) {
+		return 0, nil
+	})
+
+	flushed := make(chan struct{})
+	job := func(amount int64) Job {
+		return Job{
+			Beneficiary: common.HexToAddress("0x1"),
+			Amount:      big.NewInt(amount),
+			Send: func(gasPrice, nonce *big.Int) (*types.Transaction, error) {
+				return nil, nil
+			},
+			Done: func(tx *types.Transaction, err error) {
+				if err == ErrReplaced {
+					b.Flush(common.HexToAddress("0x1"))
+					close(flushed)
+				}
+			},
+		}
+	}
+
+	go func() {
+		assert.NoError(t, b.Add(job(1)))
+		assert.NoError(t, b.Add(job(2)))
+	}()
+
+	select {
+	case <-flushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("replaced callback deadlocked on the batcher")
+	}
+}
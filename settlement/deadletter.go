@@ -0,0 +1,219 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrNoReplayer is returned when replaying a dead letter of a kind without a registered ReplayFunc.
+var ErrNoReplayer = errors.New("no replayer registered for dead letter kind")
+
+// DeadLetter is a settlement request that failed after exhausting its retries.
+type DeadLetter struct {
+	ID string
+	// Kind identifies the request type and the ReplayFunc used to replay it.
+	Kind string
+	// Request is the JSON encoded request.
+	Request json.RawMessage
+	// GasPrice and GasLimit override the request gas parameters on replay if set.
+	GasPrice *big.Int
+	GasLimit uint64
+	// Errors holds the error chains of every failed attempt, oldest first.
+	Errors    []string
+	Attempts  int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// DeadLetterStorage persists dead letters.
+type DeadLetterStorage interface {
+	UpsertDeadLetter(dl DeadLetter) error
+	DeleteDeadLetter(id string) error
+	// GetDeadLetter returns nil if the dead letter does not exist.
+	GetDeadLetter(id string) (*DeadLetter, error)
+	GetDeadLetters() ([]DeadLetter, error)
+}
+
+// ReplayFunc resubmits a dead letter.
+type ReplayFunc func(dl DeadLetter) (*types.Transaction, error)
+
+// DeadLetterQueue keeps failed settlements so they can be inspected, adjusted and replayed instead of being lost.
+type DeadLetterQueue struct {
+	storage DeadLetterStorage
+	now     func() time.Time
+
+	lock      sync.Mutex
+	replayers map[string]ReplayFunc
+}
+
+// NewDeadLetterQueue returns a new dead letter queue.
+func NewDeadLetterQueue(storage DeadLetterStorage) *DeadLetterQueue {
+	return &DeadLetterQueue{
+		storage:   storage,
+		now:       time.Now,
+		replayers: make(map[string]ReplayFunc),
+	}
+}
+
+// Register sets the function used to replay dead letters of the given kind.
+func (q *DeadLetterQueue) Register(kind string, replay ReplayFunc) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.replayers[kind] = replay
+}
+
+// Push stores the failed request. Pushing the same request again records another failed attempt.
+func (q *DeadLetterQueue) Push(kind string, request interface{}, attempts int, cause error) (DeadLetter, error) {
+	blob, err := json.Marshal(request)
+	if err != nil {
+		return DeadLetter{}, fmt.Errorf("could not marshal request: %w", err)
+	}
+
+	id := crypto.Keccak256Hash([]byte(kind), blob).Hex()
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	dl, err := q.storage.GetDeadLetter(id)
+	if err != nil {
+		return DeadLetter{}, err
+	}
+
+	now := q.now().UTC()
+	if dl == nil {
+		dl = &DeadLetter{
+			ID:        id,
+			Kind:      kind,
+			Request:   blob,
+			CreatedAt: now,
+		}
+	}
+	dl.Attempts += attempts
+	dl.Errors = append(dl.Errors, errorChain(cause))
+	dl.UpdatedAt = now
+
+	return *dl, q.storage.UpsertDeadLetter(*dl)
+}
+
+// List returns all the dead letters.
+func (q *DeadLetterQueue) List() ([]DeadLetter, error) {
+	return q.storage.GetDeadLetters()
+}
+
+// Get returns the dead letter with the given id, or nil if it does not exist.
+func (q *DeadLetterQueue) Get(id string) (*DeadLetter, error) {
+	return q.storage.GetDeadLetter(id)
+}
+
+// SetGas overrides the gas parameters used when replaying the dead letter.
+// Nil gas price and zero gas limit leave the request values untouched.
+func (q *DeadLetterQueue) SetGas(id string, gasPrice *big.Int, gasLimit uint64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	dl, err := q.mustGet(id)
+	if err != nil {
+		return err
+	}
+
+	dl.GasPrice = gasPrice
+	dl.GasLimit = gasLimit
+	dl.UpdatedAt = q.now().UTC()
+	return q.storage.UpsertDeadLetter(*dl)
+}
+
+// Delete drops the dead letter without replaying it.
+func (q *DeadLetterQueue) Delete(id string) error {
+	return q.storage.DeleteDeadLetter(id)
+}
+
+// Replay resubmits the dead letter. It is removed from the queue on success,
+// otherwise the failure is recorded and the dead letter is kept.
+func (q *DeadLetterQueue) Replay(id string) (*types.Transaction, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	dl, err := q.mustGet(id)
+	if err != nil {
+		return nil, err
+	}
+
+	replay, ok := q.replayers[dl.Kind]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrNoReplayer, dl.Kind)
+	}
+
+	tx, replayErr := replay(*dl)
+	if replayErr == nil {
+		return tx, q.storage.DeleteDeadLetter(id)
+	}
+
+	dl.Attempts++
+	dl.Errors = append(dl.Errors, errorChain(replayErr))
+	dl.UpdatedAt = q.now().UTC()
+	if err := q.storage.UpsertDeadLetter(*dl); err != nil {
+		return nil, err
+	}
+	return nil, replayErr
+}
+
+// DoneFunc returns a batcher DoneFunc that pushes failed jobs to the queue.
+// Replaced jobs are not dead letters and are only passed on to next, which can be nil.
+func (q *DeadLetterQueue) DoneFunc(kind string, request interface{}, next DoneFunc) DoneFunc {
+	return func(tx *types.Transaction, err error) {
+		if err != nil && !errors.Is(err, ErrReplaced) {
+			if _, pushErr := q.Push(kind, request, 1, err); pushErr != nil {
+				err = fmt.Errorf("%v, could not push to dead letter queue: %w", err, pushErr)
+			}
+		}
+		if next != nil {
+			next(tx, err)
+		}
+	}
+}
+
+func (q *DeadLetterQueue) mustGet(id string) (*DeadLetter, error) {
+	dl, err := q.storage.GetDeadLetter(id)
+	if err != nil {
+		return nil, err
+	}
+	if dl == nil {
+		return nil, fmt.Errorf("dead letter %v not found", id)
+	}
+	return dl, nil
+}
+
+// errorChain renders every error of the wrapped chain on its own line.
+func errorChain(err error) string {
+	if err == nil {
+		return ""
+	}
+	res := err.Error()
+	for err = errors.Unwrap(err); err != nil; err = errors.Unwrap(err) {
+		res += "\n" + err.Error()
+	}
+	return res
+}
@@ -0,0 +1,108 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type memDeadLetters map[string]DeadLetter
+
+func (m memDeadLetters) UpsertDeadLetter(dl DeadLetter) error { m[dl.ID] = dl; return nil }
+func (m memDeadLetters) DeleteDeadLetter(id string) error     { delete(m, id); return nil }
+func (m memDeadLetters) GetDeadLetter(id string) (*DeadLetter, error) {
+	dl, ok := m[id]
+	if !ok {
+		return nil, nil
+	}
+	return &dl, nil
+}
+func (m memDeadLetters) GetDeadLetters() ([]DeadLetter, error) {
+	var res []DeadLetter
+	for _, dl := range m {
+		res = append(res, dl)
+	}
+	return res, nil
+}
+
+type mockSettler struct {
+	err      error
+	received client.SettleWithBeneficiaryRequest
+}
+
+func (ms *mockSettler) SettleAndRebalance(req client.SettleAndRebalanceRequest) (*types.Transaction, error) {
+	return nil, ms.err
+}
+
+func (ms *mockSettler) SettleWithBeneficiary(req client.SettleWithBeneficiaryRequest) (*types.Transaction, error) {
+	ms.received = req
+	if ms.err != nil {
+		return nil, ms.err
+	}
+	return types.NewTransaction(0, req.HermesID, nil, req.GasLimit, req.GasPrice, nil), nil
+}
+
+func (ms *mockSettler) SettleIntoStake(req client.SettleIntoStakeRequest) (*types.Transaction, error) {
+	return nil, ms.err
+}
+
+func TestDeadLetterQueue(t *testing.T) {
+	storage := memDeadLetters{}
+	q := NewDeadLetterQueue(storage)
+	settler := &mockSettler{err: errors.New("still failing")}
+	RegisterClientReplayers(q, settler, nil)
+
+	req := client.SettleWithBeneficiaryRequest{
+		WriteRequest: client.WriteRequest{GasPrice: big.NewInt(1), GasLimit: 100000, Nonce: big.NewInt(5)},
+		Promise:      crypto.Promise{Amount: big.NewInt(10), Fee: big.NewInt(1)},
+		HermesID:     common.HexToAddress("0x1"),
+		Beneficiary:  common.HexToAddress("0x2"),
+	}
+	root := errors.New("underpriced")
+	dl, err := q.Push(KindSettleWithBeneficiary, req, 3, fmt.Errorf("could not settle: %w", root))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, dl.Attempts)
+	assert.Equal(t, "could not settle: underpriced\nunderpriced", dl.Errors[0])
+
+	assert.NoError(t, q.SetGas(dl.ID, big.NewInt(50), 0))
+
+	_, err = q.Replay(dl.ID)
+	assert.Equal(t, settler.err, err)
+	kept, _ := q.Get(dl.ID)
+	assert.Equal(t, 4, kept.Attempts)
+	assert.Len(t, kept.Errors, 2)
+
+	settler.err = nil
+	tx, err := q.Replay(dl.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(50), tx.GasPrice())
+	assert.Equal(t, uint64(100000), settler.received.GasLimit)
+	assert.Nil(t, settler.received.Nonce)
+	assert.Equal(t, big.NewInt(10), settler.received.Promise.Amount)
+
+	list, _ := q.List()
+	assert.Len(t, list, 0)
+}
@@ -0,0 +1,131 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/bindings"
+)
+
+// ErrNoBeneficiary is returned when none of the configured beneficiaries accepts the transfer.
+var ErrNoBeneficiary = errors.New("no beneficiary accepts the transfer")
+
+// ErrTransferRejected is returned when a simulated transfer returns false instead of reverting.
+var ErrTransferRejected = errors.New("token transfer returned false")
+
+// TransferSimulator simulates a token transfer, returning an error if the transfer would fail.
+type TransferSimulator interface {
+	SimulateTransfer(token, from, to common.Address, amount *big.Int) error
+}
+
+// CallTransferSimulator simulates ERC-20 transfers with eth_call against the latest block.
+type CallTransferSimulator struct {
+	caller  bind.ContractCaller
+	timeout time.Duration
+	abi     abi.ABI
+}
+
+// NewCallTransferSimulator returns a new eth_call based transfer simulator.
+func NewCallTransferSimulator(caller bind.ContractCaller, timeout time.Duration) (*CallTransferSimulator, error) {
+	parsed, err := abi.JSON(strings.NewReader(bindings.MystTokenABI))
+	if err != nil {
+		return nil, err
+	}
+	return &CallTransferSimulator{
+		caller:  caller,
+		timeout: timeout,
+		abi:     parsed,
+	}, nil
+}
+
+// SimulateTransfer calls transfer of the token as the given sender.
+func (s *CallTransferSimulator) SimulateTransfer(token, from, to common.Address, amount *big.Int) error {
+	data, err := s.abi.Pack("transfer", to, amount)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	out, err := s.caller.CallContract(ctx, ethereum.CallMsg{From: from, To: &token, Data: data}, nil)
+	if err != nil {
+		return err
+	}
+
+	// tokens not returning a value are treated as successful, like SafeERC20 does
+	if len(out) == 0 {
+		return nil
+	}
+	var ok bool
+	if err := s.abi.Unpack(&ok, "transfer", out); err != nil {
+		return fmt.Errorf("could not unpack transfer result: %w", err)
+	}
+	if !ok {
+		return ErrTransferRejected
+	}
+	return nil
+}
+
+// BeneficiaryFallback picks the settlement beneficiary out of an ordered list.
+// A beneficiary is skipped if the transfer to it fails in simulation, e.g. a contract rejecting the token,
+// so the settled funds do not get stuck.
+type BeneficiaryFallback struct {
+	simulator     TransferSimulator
+	token         common.Address
+	beneficiaries []common.Address
+	logFunc       LogFunc
+}
+
+// NewBeneficiaryFallback returns a new beneficiary fallback chain, the first beneficiary being the primary one.
+func NewBeneficiaryFallback(simulator TransferSimulator, token common.Address, beneficiaries ...common.Address) *BeneficiaryFallback {
+	return &BeneficiaryFallback{
+		simulator:     simulator,
+		token:         token,
+		beneficiaries: beneficiaries,
+		logFunc:       func(error) {},
+	}
+}
+
+// AttachLogFunc sets the function called when a beneficiary is skipped.
+// Not thread safe, call before choosing beneficiaries.
+func (bf *BeneficiaryFallback) AttachLogFunc(f LogFunc) {
+	bf.logFunc = f
+}
+
+// Choose returns the first beneficiary the settled amount can be transferred to.
+// The payer is the contract paying out the settlement, hermes for settleWithBeneficiary.
+// Choose before signing the settlement, as the beneficiary is part of the signed message.
+func (bf *BeneficiaryFallback) Choose(payer common.Address, amount *big.Int) (common.Address, error) {
+	for _, beneficiary := range bf.beneficiaries {
+		err := bf.simulator.SimulateTransfer(bf.token, payer, beneficiary, amount)
+		if err == nil {
+			return beneficiary, nil
+		}
+		bf.logFunc(fmt.Errorf("skipping beneficiary %v: %w", beneficiary.Hex(), err))
+	}
+	return common.Address{}, ErrNoBeneficiary
+}
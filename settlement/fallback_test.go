@@ -0,0 +1,82 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/mysteriumnetwork/payments/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type rejectingSimulator map[common.Address]error
+
+func (rs rejectingSimulator) SimulateTransfer(token, from, to common.Address, amount *big.Int) error {
+	return rs[to]
+}
+
+func TestBeneficiaryFallback(t *testing.T) {
+	primary, second, third := common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")
+	rejected := errors.New("reverted")
+	sim := rejectingSimulator{primary: rejected}
+
+	var skipped []error
+	bf := NewBeneficiaryFallback(sim, common.HexToAddress("0x4"), primary, second, third)
+	bf.AttachLogFunc(func(err error) { skipped = append(skipped, err) })
+
+	beneficiary, err := bf.Choose(common.HexToAddress("0x5"), big.NewInt(10))
+	assert.NoError(t, err)
+	assert.Equal(t, second, beneficiary)
+	assert.Len(t, skipped, 1)
+	assert.True(t, errors.Is(skipped[0], rejected))
+
+	sim[second], sim[third] = rejected, rejected
+	_, err = bf.Choose(common.HexToAddress("0x5"), big.NewInt(10))
+	assert.Equal(t, ErrNoBeneficiary, err)
+}
+
+func TestCallTransferSimulator(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	opts := bind.NewKeyedTransactor(key)
+	backend := testutil.NewSimulatedBackend(core.GenesisAlloc{opts.From: {Balance: big.NewInt(0).Exp(big.NewInt(10), big.NewInt(20), nil)}}, 10000000)
+	defer backend.Close()
+
+	oldMyst, _, _, err := bindings.DeployOldMystToken(opts, backend)
+	assert.NoError(t, err)
+	myst, _, token, err := bindings.DeployMystToken(opts, backend, oldMyst)
+	assert.NoError(t, err)
+	_, err = token.Mint(opts, opts.From, big.NewInt(500))
+	assert.NoError(t, err)
+
+	sim, err := NewCallTransferSimulator(backend, time.Second)
+	assert.NoError(t, err)
+
+	assert.NoError(t, sim.SimulateTransfer(myst, opts.From, common.HexToAddress("0x1"), big.NewInt(500)))
+	assert.Error(t, sim.SimulateTransfer(myst, opts.From, common.HexToAddress("0x1"), big.NewInt(501)))
+
+	balance, err := token.BalanceOf(&bind.CallOpts{}, opts.From)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(500), balance)
+}
@@ -0,0 +1,244 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+// HermesBalanceReader reads the state settlement planning depends on.
+type HermesBalanceReader interface {
+	GetHermessAvailableBalance(hermesAddress common.Address) (*big.Int, error)
+	GetProviderChannel(hermesAddress common.Address, addressToCheck common.Address, pending bool) (client.ProviderChannel, error)
+}
+
+// Plan describes how much of a promise can be settled right now.
+type Plan struct {
+	// Unsettled is the promise amount not settled yet.
+	Unsettled *big.Int
+	// Settleable is the part of Unsettled covered by the hermes available balance.
+	Settleable *big.Int
+	// Remainder is the part of Unsettled that has to wait for hermes to be topped up.
+	Remainder *big.Int
+}
+
+// Full checks whether the whole unsettled amount can be settled.
+func (p Plan) Full() bool {
+	return p.Remainder.Sign() == 0
+}
+
+// PlanSettlement computes the maximal currently settleable amount of the promise.
+// The channel is read in the pending state, so settlements waiting to be mined are accounted for.
+func PlanSettlement(br HermesBalanceReader, hermesID, providerID common.Address, promiseAmount *big.Int) (Plan, error) {
+	channel, err := br.GetProviderChannel(hermesID, providerID, true)
+	if err != nil {
+		return Plan{}, fmt.Errorf("could not get provider channel: %w", err)
+	}
+
+	available, err := br.GetHermessAvailableBalance(hermesID)
+	if err != nil {
+		return Plan{}, fmt.Errorf("could not get hermes available balance: %w", err)
+	}
+
+	plan := Plan{
+		Unsettled:  new(big.Int),
+		Settleable: new(big.Int),
+		Remainder:  new(big.Int),
+	}
+	if channel.Settled != nil && promiseAmount.Cmp(channel.Settled) > 0 {
+		plan.Unsettled.Sub(promiseAmount, channel.Settled)
+	} else if channel.Settled == nil {
+		plan.Unsettled.Set(promiseAmount)
+	}
+
+	if available.Cmp(plan.Unsettled) >= 0 {
+		plan.Settleable.Set(plan.Unsettled)
+	} else if available.Sign() > 0 {
+		plan.Settleable.Set(available)
+	}
+	plan.Remainder.Sub(plan.Unsettled, plan.Settleable)
+
+	return plan, nil
+}
+
+// SettleFunc submits the settlement of the latest promise.
+// Promises are cumulative, so resubmitting the same promise settles whatever was left unsettled.
+type SettleFunc func() (*types.Transaction, error)
+
+// PartialSettler settles promises as far as the hermes available balance allows and watches hermes balance
+// to settle the remainder once hermes is topped up.
+//
+// Partial settlements rely on hermes capping the transferred amount at its available balance.
+// If the hermes contract reverts instead, set allowPartial to false so that only full settlements are attempted.
+type PartialSettler struct {
+	reader       HermesBalanceReader
+	allowPartial bool
+	minPartial   *big.Int
+	interval     time.Duration
+	logFunc      LogFunc
+
+	lock    sync.Mutex
+	pending map[[32]byte]pendingSettlement
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// LogFunc is called with the errors of background work.
+type LogFunc func(error)
+
+type pendingSettlement struct {
+	hermesID   common.Address
+	providerID common.Address
+	amount     *big.Int
+	settle     SettleFunc
+}
+
+// NewPartialSettler returns a new partial settler.
+// Partial settlements smaller than minPartial are not submitted, as they would cost more than they pay.
+func NewPartialSettler(reader HermesBalanceReader, allowPartial bool, minPartial *big.Int, interval time.Duration) *PartialSettler {
+	return &PartialSettler{
+		reader:       reader,
+		allowPartial: allowPartial,
+		minPartial:   minPartial,
+		interval:     interval,
+		logFunc:      func(error) {},
+		pending:      make(map[[32]byte]pendingSettlement),
+		stop:         make(chan struct{}),
+	}
+}
+
+// AttachLogFunc attaches a log func to the settler.
+// Not thread safe, call before Run.
+func (ps *PartialSettler) AttachLogFunc(f LogFunc) {
+	ps.logFunc = f
+}
+
+// Settle settles the promise as far as possible. If a remainder is left it is scheduled
+// and settled by Run once the hermes available balance allows it.
+// A nil transaction is returned if nothing was submitted.
+func (ps *PartialSettler) Settle(hermesID, providerID common.Address, promiseAmount *big.Int, settle SettleFunc) (Plan, *types.Transaction, error) {
+	key := channelKey(hermesID, providerID)
+	ps.lock.Lock()
+	delete(ps.pending, key)
+	ps.lock.Unlock()
+
+	plan, tx, err := ps.try(hermesID, providerID, promiseAmount, settle)
+	if err != nil {
+		return plan, nil, err
+	}
+
+	if !plan.Full() {
+		ps.lock.Lock()
+		ps.pending[key] = pendingSettlement{
+			hermesID:   hermesID,
+			providerID: providerID,
+			amount:     promiseAmount,
+			settle:     settle,
+		}
+		ps.lock.Unlock()
+	}
+
+	return plan, tx, nil
+}
+
+func (ps *PartialSettler) try(hermesID, providerID common.Address, promiseAmount *big.Int, settle SettleFunc) (Plan, *types.Transaction, error) {
+	plan, err := PlanSettlement(ps.reader, hermesID, providerID, promiseAmount)
+	if err != nil {
+		return Plan{}, nil, err
+	}
+
+	if plan.Settleable.Sign() == 0 || (!plan.Full() && !ps.partialAllowed(plan.Settleable)) {
+		return plan, nil, nil
+	}
+
+	tx, err := settle()
+	if err != nil {
+		return plan, nil, fmt.Errorf("could not settle: %w", err)
+	}
+	return plan, tx, nil
+}
+
+func (ps *PartialSettler) partialAllowed(amount *big.Int) bool {
+	return ps.allowPartial && (ps.minPartial == nil || amount.Cmp(ps.minPartial) >= 0)
+}
+
+// Pending returns the number of settlements waiting for hermes balance.
+func (ps *PartialSettler) Pending() int {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	return len(ps.pending)
+}
+
+// Run watches the hermes balance and settles the pending remainders until stopped.
+func (ps *PartialSettler) Run() {
+	for {
+		select {
+		case <-ps.stop:
+			return
+		case <-time.After(ps.interval):
+			ps.retryPending()
+		}
+	}
+}
+
+// Stop stops the settler.
+func (ps *PartialSettler) Stop() {
+	ps.once.Do(func() {
+		close(ps.stop)
+	})
+}
+
+func (ps *PartialSettler) retryPending() {
+	ps.lock.Lock()
+	pending := make(map[[32]byte]pendingSettlement, len(ps.pending))
+	for k, v := range ps.pending {
+		pending[k] = v
+	}
+	ps.lock.Unlock()
+
+	for key, p := range pending {
+		plan, _, err := ps.try(p.hermesID, p.providerID, p.amount, p.settle)
+		if err != nil {
+			ps.logFunc(fmt.Errorf("could not settle remainder of %v with hermes %v: %w", p.providerID.Hex(), p.hermesID.Hex(), err))
+			continue
+		}
+
+		if plan.Full() {
+			ps.lock.Lock()
+			// Keep a newer promise scheduled by Settle in the meantime.
+			if current, ok := ps.pending[key]; ok && current.amount == p.amount {
+				delete(ps.pending, key)
+			}
+			ps.lock.Unlock()
+		}
+	}
+}
+
+func channelKey(hermesID, providerID common.Address) [32]byte {
+	var key [32]byte
+	copy(key[:], crypto.GenerateProviderChannelIDBytes(providerID, hermesID))
+	return key
+}
@@ -0,0 +1,98 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockHermes settles as much as its available balance allows.
+type mockHermes struct {
+	lock      sync.Mutex
+	available *big.Int
+	settled   *big.Int
+}
+
+func (mh *mockHermes) GetHermessAvailableBalance(hermesAddress common.Address) (*big.Int, error) {
+	mh.lock.Lock()
+	defer mh.lock.Unlock()
+	return new(big.Int).Set(mh.available), nil
+}
+
+func (mh *mockHermes) GetProviderChannel(hermesAddress common.Address, addressToCheck common.Address, pending bool) (client.ProviderChannel, error) {
+	mh.lock.Lock()
+	defer mh.lock.Unlock()
+	return client.ProviderChannel{Settled: new(big.Int).Set(mh.settled)}, nil
+}
+
+func (mh *mockHermes) settle(promise *big.Int) SettleFunc {
+	return func() (*types.Transaction, error) {
+		mh.lock.Lock()
+		defer mh.lock.Unlock()
+		amount := new(big.Int).Sub(promise, mh.settled)
+		if amount.Cmp(mh.available) > 0 {
+			amount.Set(mh.available)
+		}
+		mh.settled.Add(mh.settled, amount)
+		mh.available.Sub(mh.available, amount)
+		return types.NewTransaction(0, common.Address{}, amount, 0, nil, nil), nil
+	}
+}
+
+func TestPartialSettler(t *testing.T) {
+	hermes := &mockHermes{available: big.NewInt(40), settled: big.NewInt(10)}
+	hermesID, providerID := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	promise := big.NewInt(110)
+
+	plan, err := PlanSettlement(hermes, hermesID, providerID, promise)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(100), plan.Unsettled)
+	assert.Equal(t, big.NewInt(40), plan.Settleable)
+	assert.Equal(t, big.NewInt(60), plan.Remainder)
+
+	ps := NewPartialSettler(hermes, false, nil, 10*time.Millisecond)
+	_, tx, err := ps.Settle(hermesID, providerID, promise, hermes.settle(promise))
+	assert.NoError(t, err)
+	assert.Nil(t, tx)
+	assert.Equal(t, 1, ps.Pending())
+
+	ps = NewPartialSettler(hermes, true, big.NewInt(5), 10*time.Millisecond)
+	_, tx, err = ps.Settle(hermesID, providerID, promise, hermes.settle(promise))
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(40), tx.Value())
+	assert.Equal(t, 1, ps.Pending())
+
+	go ps.Run()
+	defer ps.Stop()
+
+	hermes.lock.Lock()
+	hermes.available.SetInt64(100)
+	hermes.lock.Unlock()
+
+	assert.Eventually(t, func() bool { return ps.Pending() == 0 }, 2*time.Second, 10*time.Millisecond)
+	hermes.lock.Lock()
+	assert.Equal(t, promise, hermes.settled)
+	hermes.lock.Unlock()
+}
@@ -0,0 +1,193 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+)
+
+// ErrPreparedNotFound is returned when confirming a prepared settlement that expired, was already confirmed or never existed.
+var ErrPreparedNotFound = errors.New("prepared settlement not found or expired")
+
+// Previewer simulates settlements. The client with dry runs can be used.
+type Previewer interface {
+	DryRunSettleAndRebalance(req client.SettleAndRebalanceRequest) (client.SettlementReport, error)
+	DryRunSettleIntoStake(req client.SettleIntoStakeRequest) (client.SettlementReport, error)
+	Estimate(req client.Estimatable) (uint64, error)
+}
+
+// Preview is the full breakdown of a prepared settlement.
+type Preview struct {
+	// Token confirms the prepared settlement, see Confirm.
+	Token  string
+	Kind   string
+	Report client.SettlementReport
+	// GasLimit and GasPrice are the values the transaction is sent with once confirmed.
+	GasLimit uint64
+	GasPrice *big.Int
+	// NetworkFee is the maximum native token cost of the transaction.
+	NetworkFee *big.Int
+	ExpiresAt  time.Time
+}
+
+type preparedSettlement struct {
+	expiresAt time.Time
+	send      func() (*types.Transaction, error)
+}
+
+// TwoPhaseSettler splits a settlement into Prepare, which returns what the settlement will do,
+// and Confirm, which broadcasts exactly the prepared transaction.
+// Prepared settlements are kept in memory and can only be confirmed once, before they expire.
+type TwoPhaseSettler struct {
+	previewer Previewer
+	settler   Settler
+	gas       GasPricer
+	ttl       time.Duration
+	now       func() time.Time
+
+	lock     sync.Mutex
+	prepared map[string]preparedSettlement
+}
+
+// NewTwoPhaseSettler returns a new two phase settler whose prepared settlements are valid for the given ttl.
+func NewTwoPhaseSettler(previewer Previewer, settler Settler, gas GasPricer, ttl time.Duration) *TwoPhaseSettler {
+	return &TwoPhaseSettler{
+		previewer: previewer,
+		settler:   settler,
+		gas:       gas,
+		ttl:       ttl,
+		now:       time.Now,
+		prepared:  make(map[string]preparedSettlement),
+	}
+}
+
+// PrepareSettleAndRebalance previews the hermes promise settlement.
+func (tps *TwoPhaseSettler) PrepareSettleAndRebalance(req client.SettleAndRebalanceRequest) (Preview, error) {
+	if err := tps.fillGas(&req.WriteRequest, req); err != nil {
+		return Preview{}, err
+	}
+
+	report, err := tps.previewer.DryRunSettleAndRebalance(req)
+	if err != nil {
+		return Preview{}, fmt.Errorf("could not preview settlement: %w", err)
+	}
+
+	return tps.store(KindSettleAndRebalance, report, req.WriteRequest, func() (*types.Transaction, error) {
+		return tps.settler.SettleAndRebalance(req)
+	})
+}
+
+// PrepareSettleIntoStake previews the hermes promise settlement into stake.
+func (tps *TwoPhaseSettler) PrepareSettleIntoStake(req client.SettleIntoStakeRequest) (Preview, error) {
+	if err := tps.fillGas(&req.WriteRequest, req); err != nil {
+		return Preview{}, err
+	}
+
+	report, err := tps.previewer.DryRunSettleIntoStake(req)
+	if err != nil {
+		return Preview{}, fmt.Errorf("could not preview settlement: %w", err)
+	}
+
+	return tps.store(KindSettleIntoStake, report, req.WriteRequest, func() (*types.Transaction, error) {
+		return tps.settler.SettleIntoStake(req)
+	})
+}
+
+// Confirm broadcasts the prepared settlement.
+func (tps *TwoPhaseSettler) Confirm(token string) (*types.Transaction, error) {
+	tps.lock.Lock()
+	ps, ok := tps.prepared[token]
+	delete(tps.prepared, token)
+	tps.lock.Unlock()
+
+	if !ok || !tps.now().Before(ps.expiresAt) {
+		return nil, ErrPreparedNotFound
+	}
+	return ps.send()
+}
+
+// Discard drops the prepared settlement.
+func (tps *TwoPhaseSettler) Discard(token string) {
+	tps.lock.Lock()
+	defer tps.lock.Unlock()
+	delete(tps.prepared, token)
+}
+
+// fillGas pins the gas limit and price of the request, so the confirmed transaction costs what was previewed.
+func (tps *TwoPhaseSettler) fillGas(wr *client.WriteRequest, req client.Estimatable) error {
+	if wr.GasLimit == 0 {
+		gasLimit, err := tps.previewer.Estimate(req)
+		if err != nil {
+			return fmt.Errorf("could not estimate gas: %w", err)
+		}
+		wr.GasLimit = gasLimit
+	}
+
+	if wr.GasPrice == nil {
+		gasPrice, err := tps.gas.SuggestGasPrice()
+		if err != nil {
+			return fmt.Errorf("could not get gas price: %w", err)
+		}
+		wr.GasPrice = gasPrice
+	}
+	return nil
+}
+
+func (tps *TwoPhaseSettler) store(kind string, report client.SettlementReport, wr client.WriteRequest, send func() (*types.Transaction, error)) (Preview, error) {
+	token, err := newToken()
+	if err != nil {
+		return Preview{}, err
+	}
+
+	now := tps.now()
+	preview := Preview{
+		Token:      token,
+		Kind:       kind,
+		Report:     report,
+		GasLimit:   wr.GasLimit,
+		GasPrice:   new(big.Int).Set(wr.GasPrice),
+		NetworkFee: new(big.Int).Mul(new(big.Int).SetUint64(wr.GasLimit), wr.GasPrice),
+		ExpiresAt:  now.Add(tps.ttl),
+	}
+
+	tps.lock.Lock()
+	defer tps.lock.Unlock()
+	for t, ps := range tps.prepared {
+		if !now.Before(ps.expiresAt) {
+			delete(tps.prepared, t)
+		}
+	}
+	tps.prepared[token] = preparedSettlement{expiresAt: preview.ExpiresAt, send: send}
+	return preview, nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,93 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockPreviewer struct {
+	report client.SettlementReport
+}
+
+func (mp *mockPreviewer) DryRunSettleAndRebalance(req client.SettleAndRebalanceRequest) (client.SettlementReport, error) {
+	return mp.report, nil
+}
+
+func (mp *mockPreviewer) DryRunSettleIntoStake(req client.SettleIntoStakeRequest) (client.SettlementReport, error) {
+	return mp.report, nil
+}
+
+func (mp *mockPreviewer) Estimate(req client.Estimatable) (uint64, error) {
+	return 90000, nil
+}
+
+type rebalanceSettler struct {
+	mockSettler
+	sent []client.SettleAndRebalanceRequest
+}
+
+func (rs *rebalanceSettler) SettleAndRebalance(req client.SettleAndRebalanceRequest) (*types.Transaction, error) {
+	rs.sent = append(rs.sent, req)
+	return types.NewTransaction(0, req.HermesID, nil, req.GasLimit, req.GasPrice, nil), nil
+}
+
+func TestTwoPhaseSettler(t *testing.T) {
+	report := client.SettlementReport{UnpaidAmount: big.NewInt(100), BeneficiaryPayout: big.NewInt(90), HermesFee: big.NewInt(8), TransactorFee: big.NewInt(2)}
+	settler := &rebalanceSettler{}
+	tps := NewTwoPhaseSettler(&mockPreviewer{report: report}, settler, &mutableGasPrice{price: big.NewInt(10)}, time.Minute)
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	tps.now = func() time.Time { return now }
+
+	req := client.SettleAndRebalanceRequest{
+		HermesID: common.HexToAddress("0x1"),
+		Promise:  crypto.Promise{Amount: big.NewInt(100), Fee: big.NewInt(2)},
+	}
+	preview, err := tps.PrepareSettleAndRebalance(req)
+	assert.NoError(t, err)
+	assert.Equal(t, KindSettleAndRebalance, preview.Kind)
+	assert.Equal(t, report, preview.Report)
+	assert.Equal(t, uint64(90000), preview.GasLimit)
+	assert.Equal(t, big.NewInt(10), preview.GasPrice)
+	assert.Equal(t, big.NewInt(900000), preview.NetworkFee)
+	assert.Equal(t, now.Add(time.Minute), preview.ExpiresAt)
+	assert.Empty(t, settler.sent)
+
+	tx, err := tps.Confirm(preview.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(90000), tx.Gas())
+	assert.Len(t, settler.sent, 1)
+	assert.Equal(t, big.NewInt(10), settler.sent[0].GasPrice)
+
+	_, err = tps.Confirm(preview.Token)
+	assert.Equal(t, ErrPreparedNotFound, err)
+
+	preview, err = tps.PrepareSettleAndRebalance(req)
+	assert.NoError(t, err)
+	now = now.Add(time.Minute)
+	_, err = tps.Confirm(preview.Token)
+	assert.Equal(t, ErrPreparedNotFound, err)
+	assert.Len(t, settler.sent, 1)
+}
@@ -0,0 +1,226 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Priority is the class of a queued transaction. Lower values are submitted first.
+type Priority int
+
+const (
+	// PriorityCritical is for transactions that must be mined before a deadline, e.g. exit finalization before the timelock expires.
+	PriorityCritical Priority = iota
+	// PriorityNormal is for settlements.
+	PriorityNormal
+	// PriorityBackground is for work that can wait, e.g. stake top-ups.
+	PriorityBackground
+
+	priorityCount = int(PriorityBackground) + 1
+)
+
+// String returns the name of the priority.
+func (p Priority) String() string {
+	switch p {
+	case PriorityCritical:
+		return "critical"
+	case PriorityNormal:
+		return "normal"
+	case PriorityBackground:
+		return "background"
+	}
+	return fmt.Sprintf("priority(%d)", int(p))
+}
+
+// ErrUnknownPriority is returned when queueing a transaction with an unknown priority.
+var ErrUnknownPriority = errors.New("unknown transaction priority")
+
+// QueuedTx is a transaction waiting in the TxQueue.
+type QueuedTx struct {
+	Sender   common.Address
+	Priority Priority
+	Send     SendFunc
+	Done     DoneFunc
+}
+
+// TxQueue submits transactions one at a time in priority lanes.
+// Nonces are assigned only when a transaction is submitted, so a transaction of a higher priority
+// preempts all queued transactions of lower priorities. Transactions already submitted are never reordered.
+// Within a lane transactions are submitted in the order they were queued.
+type TxQueue struct {
+	gas    GasPricer
+	nonces NonceFunc
+
+	lock    sync.Mutex
+	lanes   [priorityCount][]QueuedTx
+	next    map[common.Address]uint64
+	stopped bool
+
+	wake chan struct{}
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewTxQueue returns a new transaction queue.
+func NewTxQueue(gas GasPricer, nonces NonceFunc) *TxQueue {
+	return &TxQueue{
+		gas:    gas,
+		nonces: nonces,
+		next:   make(map[common.Address]uint64),
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Push queues the transaction.
+func (q *TxQueue) Push(tx QueuedTx) error {
+	if tx.Priority < 0 || int(tx.Priority) >= priorityCount {
+		return ErrUnknownPriority
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.stopped {
+		return ErrStopped
+	}
+	q.lanes[tx.Priority] = append(q.lanes[tx.Priority], tx)
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Len returns the number of queued transactions of the given priority.
+func (q *TxQueue) Len(p Priority) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if p < 0 || int(p) >= priorityCount {
+		return 0
+	}
+	return len(q.lanes[p])
+}
+
+// Run submits the queued transactions until stopped.
+func (q *TxQueue) Run() {
+	for {
+		for q.Process() {
+			select {
+			case <-q.stop:
+				return
+			default:
+			}
+		}
+
+		select {
+		case <-q.stop:
+			return
+		case <-q.wake:
+		}
+	}
+}
+
+// Stop stops the queue. The transactions still queued are done with ErrStopped.
+func (q *TxQueue) Stop() {
+	q.once.Do(func() {
+		q.lock.Lock()
+		q.stopped = true
+		var pending []QueuedTx
+		for i := range q.lanes {
+			pending = append(pending, q.lanes[i]...)
+			q.lanes[i] = nil
+		}
+		q.lock.Unlock()
+
+		close(q.stop)
+		for _, tx := range pending {
+			tx.done(nil, ErrStopped)
+		}
+	})
+}
+
+// Process submits the queued transaction of the highest priority.
+// It returns false if the queue is empty.
+func (q *TxQueue) Process() bool {
+	tx, ok := q.pop()
+	if !ok {
+		return false
+	}
+
+	gasPrice, err := q.gas.SuggestGasPrice()
+	if err != nil {
+		tx.done(nil, fmt.Errorf("could not get gas price: %w", err))
+		return true
+	}
+
+	nonce, err := q.nonce(tx.Sender)
+	if err != nil {
+		tx.done(nil, fmt.Errorf("could not get nonce: %w", err))
+		return true
+	}
+
+	sent, err := tx.Send(gasPrice, new(big.Int).SetUint64(nonce))
+	q.lock.Lock()
+	if err == nil {
+		q.next[tx.Sender] = nonce + 1
+	} else {
+		// the nonce might have been used nevertheless, reload it before the next submission
+		delete(q.next, tx.Sender)
+	}
+	q.lock.Unlock()
+
+	tx.done(sent, err)
+	return true
+}
+
+func (q *TxQueue) pop() (QueuedTx, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for i := range q.lanes {
+		if len(q.lanes[i]) > 0 {
+			tx := q.lanes[i][0]
+			q.lanes[i] = q.lanes[i][1:]
+			return tx, true
+		}
+	}
+	return QueuedTx{}, false
+}
+
+func (q *TxQueue) nonce(sender common.Address) (uint64, error) {
+	q.lock.Lock()
+	nonce, ok := q.next[sender]
+	q.lock.Unlock()
+	if ok {
+		return nonce, nil
+	}
+	return q.nonces(sender)
+}
+
+func (tx QueuedTx) done(sent *types.Transaction, err error) {
+	if tx.Done != nil {
+		tx.Done(sent, err)
+	}
+}
@@ -0,0 +1,130 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxQueuePriorities(t *testing.T) {
+	nonceCalls := 0
+	q := NewTxQueue(fixedGasPrice{}, func(sender common.Address) (uint64, error) {
+		nonceCalls++
+		return 3, nil
+	})
+
+	type submission struct {
+		name  string
+		nonce uint64
+	}
+	var sent []submission
+	push := func(name string, p Priority) {
+		assert.NoError(t, q.Push(QueuedTx{
+			Sender:   common.HexToAddress("0x1"),
+			Priority: p,
+			Send: func(gasPrice, nonce *big.Int) (*types.Transaction, error) {
+				sent = append(sent, submission{name: name, nonce: nonce.Uint64()})
+				return types.NewTransaction(nonce.Uint64(), common.Address{}, nil, 0, gasPrice, nil), nil
+			},
+		}))
+	}
+
+	push("topup-1", PriorityBackground)
+	push("settle-1", PriorityNormal)
+	push("topup-2", PriorityBackground)
+	assert.Equal(t, 2, q.Len(PriorityBackground))
+
+	assert.True(t, q.Process())
+	push("exit", PriorityCritical)
+	for q.Process() {
+	}
+
+	assert.Equal(t, []submission{
+		{"settle-1", 3},
+		{"exit", 4},
+		{"topup-1", 5},
+		{"topup-2", 6},
+	}, sent)
+	assert.Equal(t, 1, nonceCalls)
+	assert.Equal(t, ErrUnknownPriority, q.Push(QueuedTx{Priority: Priority(7)}))
+}
+
+func TestTxQueueReloadsNonceOnFailure(t *testing.T) {
+	nonce := uint64(1)
+	q := NewTxQueue(fixedGasPrice{}, func(sender common.Address) (uint64, error) {
+		return nonce, nil
+	})
+
+	var errs []error
+	var nonces []uint64
+	push := func(err error) {
+		assert.NoError(t, q.Push(QueuedTx{
+			Priority: PriorityNormal,
+			Send: func(gasPrice, n *big.Int) (*types.Transaction, error) {
+				nonces = append(nonces, n.Uint64())
+				return nil, err
+			},
+			Done: func(tx *types.Transaction, err error) {
+				errs = append(errs, err)
+			},
+		}))
+	}
+
+	sendErr := errors.New("nonce too low")
+	push(sendErr)
+	push(nil)
+	assert.True(t, q.Process())
+	nonce = 5
+	assert.True(t, q.Process())
+	assert.False(t, q.Process())
+
+	assert.Equal(t, []uint64{1, 5}, nonces)
+	assert.Equal(t, []error{sendErr, nil}, errs)
+}
+
+func TestTxQueueRun(t *testing.T) {
+	q := NewTxQueue(fixedGasPrice{}, func(sender common.Address) (uint64, error) {
+		return 0, nil
+	})
+	go q.Run()
+
+	done := make(chan error, 1)
+	assert.NoError(t, q.Push(QueuedTx{
+		Priority: PriorityCritical,
+		Send: func(gasPrice, nonce *big.Int) (*types.Transaction, error) {
+			return types.NewTransaction(nonce.Uint64(), common.Address{}, nil, 0, gasPrice, nil), nil
+		},
+		Done: func(tx *types.Transaction, err error) { done <- err },
+	}))
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("queued transaction not submitted")
+	}
+
+	q.Stop()
+	assert.Equal(t, ErrStopped, q.Push(QueuedTx{Priority: PriorityNormal}))
+}
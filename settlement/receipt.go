@@ -0,0 +1,248 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/bindings"
+	pc "github.com/mysteriumnetwork/payments/crypto"
+)
+
+// ReceiptVersion is the version of the settlement receipt format.
+const ReceiptVersion = 1
+
+var (
+	// ErrSettlementNotConfirmed is returned when issuing a receipt for a settlement without enough confirmations.
+	ErrSettlementNotConfirmed = errors.New("settlement is not confirmed yet")
+	// ErrSettlementFailed is returned when issuing a receipt for a reverted settlement transaction.
+	ErrSettlementFailed = errors.New("settlement transaction failed")
+	// ErrNoSettlementEvent is returned when the transaction did not settle a promise of the hermes.
+	ErrNoSettlementEvent = errors.New("transaction has no settlement event of the hermes")
+	// ErrInvalidReceiptSignature is returned when the receipt was not signed by the expected operator.
+	ErrInvalidReceiptSignature = errors.New("invalid receipt signature")
+)
+
+// Receipt is a machine-readable proof of a confirmed settlement, handed to counterparties as proof of payment.
+type Receipt struct {
+	Version     int            `json:"version"`
+	ChainID     int64          `json:"chainID"`
+	TxHash      common.Hash    `json:"txHash"`
+	BlockNumber uint64         `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	SettledAt   time.Time      `json:"settledAt"`
+	Hermes      common.Address `json:"hermes"`
+	ChannelID   common.Hash    `json:"channelID"`
+	Beneficiary common.Address `json:"beneficiary"`
+	// Amount is the amount sent to the beneficiary.
+	Amount *big.Int `json:"amount"`
+	// Fees are the hermes and transactor fees taken from the settled amount.
+	Fees *big.Int `json:"fees"`
+	// PromiseHash is the hash of the settled promise, empty if the promise is not known to the issuer.
+	PromiseHash common.Hash `json:"promiseHash"`
+}
+
+// MarshalJSON encodes the receipt with decimal string amounts.
+func (r Receipt) MarshalJSON() ([]byte, error) {
+	type receipt Receipt
+	return json.Marshal(struct {
+		receipt
+		Amount *pc.BigInt `json:"amount"`
+		Fees   *pc.BigInt `json:"fees"`
+	}{receipt(r), (*pc.BigInt)(r.Amount), (*pc.BigInt)(r.Fees)})
+}
+
+// UnmarshalJSON decodes the receipt, accepting decimal string and number amounts.
+func (r *Receipt) UnmarshalJSON(data []byte) error {
+	type receipt Receipt
+	aux := struct {
+		*receipt
+		Amount *pc.BigInt `json:"amount"`
+		Fees   *pc.BigInt `json:"fees"`
+	}{receipt: (*receipt)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.Amount, r.Fees = aux.Amount.Int(), aux.Fees.Int()
+	return nil
+}
+
+// SignedReceipt is a serialized receipt with a detached signature of the operator.
+type SignedReceipt struct {
+	// Payload is the receipt serialized as JSON. The signature covers these exact bytes.
+	Payload []byte
+	// Signature is the operator signature of the keccak256 hash of the payload.
+	Signature []byte
+	Signer    common.Address
+}
+
+// ReceiptSigner signs receipt hashes with the operator key.
+type ReceiptSigner interface {
+	SignHash(a accounts.Account, hash []byte) ([]byte, error)
+}
+
+// ReceiptChain returns the receipts and headers of the settlement transactions. The BC client can be used.
+type ReceiptChain interface {
+	TransactionReceipt(hash common.Hash) (*types.Receipt, error)
+	HeaderByNumber(number *big.Int) (*types.Header, error)
+}
+
+// ReceiptIssuer issues signed receipts of confirmed settlements.
+type ReceiptIssuer struct {
+	chain         ReceiptChain
+	chainID       int64
+	confirmations uint64
+	ks            ReceiptSigner
+	operator      common.Address
+}
+
+// NewReceiptIssuer returns a new receipt issuer signing with the operator key.
+// Receipts are only issued for settlements with at least the given number of confirmations.
+func NewReceiptIssuer(chain ReceiptChain, chainID int64, confirmations uint64, ks ReceiptSigner, operator common.Address) *ReceiptIssuer {
+	return &ReceiptIssuer{
+		chain:         chain,
+		chainID:       chainID,
+		confirmations: confirmations,
+		ks:            ks,
+		operator:      operator,
+	}
+}
+
+// Issue builds and signs the receipt of the settlement transaction of the hermes.
+// The promise is optional and only used for the promise hash.
+func (ri *ReceiptIssuer) Issue(txHash common.Hash, hermesID common.Address, promise *pc.Promise) (SignedReceipt, error) {
+	r, err := ri.Receipt(txHash, hermesID, promise)
+	if err != nil {
+		return SignedReceipt{}, err
+	}
+	return SignReceipt(r, ri.ks, ri.operator)
+}
+
+// Receipt builds the unsigned receipt of the settlement transaction of the hermes.
+func (ri *ReceiptIssuer) Receipt(txHash common.Hash, hermesID common.Address, promise *pc.Promise) (Receipt, error) {
+	txReceipt, err := ri.chain.TransactionReceipt(txHash)
+	if err != nil {
+		return Receipt{}, fmt.Errorf("could not get transaction receipt: %w", err)
+	}
+	if txReceipt.Status != types.ReceiptStatusSuccessful {
+		return Receipt{}, ErrSettlementFailed
+	}
+
+	head, err := ri.chain.HeaderByNumber(nil)
+	if err != nil {
+		return Receipt{}, fmt.Errorf("could not get chain head: %w", err)
+	}
+	if head.Number.Uint64() < txReceipt.BlockNumber.Uint64()+ri.confirmations {
+		return Receipt{}, ErrSettlementNotConfirmed
+	}
+
+	ev, err := findPromiseSettled(txReceipt.Logs, hermesID)
+	if err != nil {
+		return Receipt{}, err
+	}
+
+	header, err := ri.chain.HeaderByNumber(txReceipt.BlockNumber)
+	if err != nil {
+		return Receipt{}, fmt.Errorf("could not get block %v: %w", txReceipt.BlockNumber, err)
+	}
+
+	r := Receipt{
+		Version:     ReceiptVersion,
+		ChainID:     ri.chainID,
+		TxHash:      txHash,
+		BlockNumber: txReceipt.BlockNumber.Uint64(),
+		BlockHash:   txReceipt.BlockHash,
+		SettledAt:   time.Unix(int64(header.Time), 0).UTC(),
+		Hermes:      hermesID,
+		ChannelID:   common.Hash(ev.ChannelId),
+		Beneficiary: ev.Beneficiary,
+		Amount:      ev.AmountSentToBeneficiary,
+		Fees:        ev.Fees,
+	}
+	if promise != nil {
+		r.PromiseHash = common.BytesToHash(promise.GetHash())
+	}
+	return r, nil
+}
+
+func findPromiseSettled(logs []*types.Log, hermesID common.Address) (*bindings.HermesImplementationPromiseSettled, error) {
+	filterer, err := bindings.NewHermesImplementationFilterer(hermesID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range logs {
+		if l.Address != hermesID || len(l.Topics) == 0 || l.Topics[0] != bindings.HermesImplementationPromiseSettledTopic {
+			continue
+		}
+		ev, err := filterer.ParsePromiseSettled(*l)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse settlement log: %w", err)
+		}
+		return ev, nil
+	}
+	return nil, ErrNoSettlementEvent
+}
+
+// SignReceipt serializes the receipt and signs it with the operator key.
+func SignReceipt(r Receipt, ks ReceiptSigner, operator common.Address) (SignedReceipt, error) {
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return SignedReceipt{}, fmt.Errorf("could not serialize receipt: %w", err)
+	}
+
+	signature, err := ks.SignHash(accounts.Account{Address: operator}, pc.Keccak256(payload))
+	if err != nil {
+		return SignedReceipt{}, fmt.Errorf("could not sign receipt: %w", err)
+	}
+	if err := pc.ReformatSignatureVForBC(signature); err != nil {
+		return SignedReceipt{}, fmt.Errorf("could not sign receipt: %w", err)
+	}
+
+	return SignedReceipt{Payload: payload, Signature: signature, Signer: operator}, nil
+}
+
+// VerifyReceipt checks the detached signature of the payload was made by the operator and returns the receipt.
+func VerifyReceipt(payload, signature []byte, operator common.Address) (Receipt, error) {
+	sig := make([]byte, len(signature))
+	copy(sig, signature)
+	if err := pc.ReformatSignatureVForRecovery(sig); err != nil {
+		return Receipt{}, fmt.Errorf("%w: %v", ErrInvalidReceiptSignature, err)
+	}
+
+	signer, err := pc.RecoverAddress(payload, sig)
+	if err != nil {
+		return Receipt{}, fmt.Errorf("%w: %v", ErrInvalidReceiptSignature, err)
+	}
+	if !bytes.Equal(signer.Bytes(), operator.Bytes()) {
+		return Receipt{}, ErrInvalidReceiptSignature
+	}
+
+	var r Receipt
+	if err := json.Unmarshal(payload, &r); err != nil {
+		return Receipt{}, fmt.Errorf("could not parse receipt: %w", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,102 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/mysteriumnetwork/payments/bindings"
+	pc "github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockReceiptChain struct {
+	head     uint64
+	receipts map[common.Hash]*types.Receipt
+}
+
+func (m *mockReceiptChain) TransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	return m.receipts[hash], nil
+}
+
+func (m *mockReceiptChain) HeaderByNumber(number *big.Int) (*types.Header, error) {
+	if number == nil {
+		number = new(big.Int).SetUint64(m.head)
+	}
+	return &types.Header{Number: number, Time: 1000 + number.Uint64()}, nil
+}
+
+func TestReceiptIssuer(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	operator := crypto.PubkeyToAddress(key.PublicKey)
+	hermesID := common.HexToAddress("0x1")
+	beneficiary := common.HexToAddress("0x2")
+	channelID := common.HexToHash("0x3")
+	txHash := common.HexToHash("0x4")
+
+	data := append(common.LeftPadBytes(big.NewInt(90).Bytes(), 32), common.LeftPadBytes(big.NewInt(10).Bytes(), 32)...)
+	chain := &mockReceiptChain{
+		head: 10,
+		receipts: map[common.Hash]*types.Receipt{
+			txHash: {
+				Status:      types.ReceiptStatusSuccessful,
+				BlockNumber: big.NewInt(8),
+				BlockHash:   common.HexToHash("0x8"),
+				Logs: []*types.Log{{
+					Address: hermesID,
+					Topics:  []common.Hash{bindings.HermesImplementationPromiseSettledTopic, channelID, beneficiary.Hash()},
+					Data:    data,
+				}},
+			},
+		},
+	}
+
+	issuer := NewReceiptIssuer(chain, 5, 3, keySigner{key: key}, operator)
+	_, err := issuer.Issue(txHash, hermesID, nil)
+	assert.Equal(t, ErrSettlementNotConfirmed, err)
+
+	chain.head = 11
+	_, err = issuer.Issue(txHash, common.HexToAddress("0x9"), nil)
+	assert.Equal(t, ErrNoSettlementEvent, err)
+
+	promise := &pc.Promise{ChainID: 5, ChannelID: channelID.Bytes(), Amount: big.NewInt(100), Fee: big.NewInt(1), Hashlock: make([]byte, 32)}
+	signed, err := issuer.Issue(txHash, hermesID, promise)
+	assert.NoError(t, err)
+	assert.Equal(t, operator, signed.Signer)
+
+	r, err := VerifyReceipt(signed.Payload, signed.Signature, operator)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), r.ChainID)
+	assert.Equal(t, uint64(8), r.BlockNumber)
+	assert.Equal(t, channelID, r.ChannelID)
+	assert.Equal(t, beneficiary, r.Beneficiary)
+	assert.Equal(t, big.NewInt(90), r.Amount)
+	assert.Equal(t, big.NewInt(10), r.Fees)
+	assert.Equal(t, common.BytesToHash(promise.GetHash()), r.PromiseHash)
+
+	_, err = VerifyReceipt(signed.Payload, signed.Signature, hermesID)
+	assert.Equal(t, ErrInvalidReceiptSignature, err)
+
+	tampered := append([]byte{}, signed.Payload...)
+	tampered[len(tampered)-2] = 'x'
+	_, err = VerifyReceipt(tampered, signed.Signature, operator)
+	assert.Equal(t, ErrInvalidReceiptSignature, err)
+}
@@ -0,0 +1,136 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	pc "github.com/mysteriumnetwork/payments/crypto"
+)
+
+// ErrBurnedLock is returned when hermes re-issues a promise with a lock whose R was already revealed.
+var ErrBurnedLock = errors.New("promise hashlock is already burned")
+
+// ReissueRequest asks hermes to re-issue the latest promise of a provider channel under a new lock.
+type ReissueRequest struct {
+	ChainID   int64
+	HermesID  common.Address
+	ChannelID []byte
+	// Amount is the amount of the settled promise, the re-issued promise must not promise less.
+	Amount *big.Int
+	// Hashlock is the new lock, the hash of an R only known to the provider.
+	Hashlock []byte
+	// RevealedR is the R revealed by the settlement, it proves that the previous lock is burned.
+	RevealedR []byte
+}
+
+// PromiseIssuer requests promises from hermes, usually over its HTTP API.
+type PromiseIssuer interface {
+	ReissuePromise(req ReissueRequest) (pc.Promise, error)
+}
+
+// LockStorage keeps track of the hashlocks whose R was revealed on chain.
+type LockStorage interface {
+	BurnLock(chainID int64, channelID, hashlock []byte) error
+	IsLockBurned(chainID int64, channelID, hashlock []byte) (bool, error)
+}
+
+// Reissuer rotates the lock of a provider promise after its R is revealed by a settlement.
+// Every new lock is chained to the previous one: hermes must prove the previous R before it is asked to sign under the new lock,
+// and the re-issued promise is checked so the provider never keeps a promise it can no longer settle.
+type Reissuer struct {
+	issuer PromiseIssuer
+	locks  LockStorage
+}
+
+// NewReissuer returns a new promise reissuer.
+func NewReissuer(issuer PromiseIssuer, locks LockStorage) *Reissuer {
+	return &Reissuer{
+		issuer: issuer,
+		locks:  locks,
+	}
+}
+
+// Reissue burns the lock of the settled promise and requests a new promise under a freshly generated lock.
+// The returned promise carries the new R, which must be stored by the provider until the promise is settled.
+func (r *Reissuer) Reissue(hermesID, hermesSigner common.Address, settled pc.Promise) (pc.Promise, error) {
+	if len(settled.R) == 0 || !bytes.Equal(pc.Keccak256(settled.R), settled.Hashlock) {
+		return pc.Promise{}, errors.New("settled promise R does not match its hashlock")
+	}
+	if err := r.locks.BurnLock(settled.ChainID, settled.ChannelID, settled.Hashlock); err != nil {
+		return pc.Promise{}, fmt.Errorf("could not burn the settled lock: %w", err)
+	}
+
+	newR := make([]byte, 32)
+	if _, err := rand.Read(newR); err != nil {
+		return pc.Promise{}, fmt.Errorf("could not generate R: %w", err)
+	}
+
+	req := ReissueRequest{
+		ChainID:   settled.ChainID,
+		HermesID:  hermesID,
+		ChannelID: settled.ChannelID,
+		Amount:    new(big.Int).Set(settled.Amount),
+		Hashlock:  pc.Keccak256(newR),
+		RevealedR: settled.R,
+	}
+	p, err := r.issuer.ReissuePromise(req)
+	if err != nil {
+		return pc.Promise{}, fmt.Errorf("could not get the re-issued promise: %w", err)
+	}
+	if err := r.Verify(req, hermesSigner, p); err != nil {
+		return pc.Promise{}, err
+	}
+
+	p.R = newR
+	return p, nil
+}
+
+// Verify checks that the promise re-issued by hermes matches the request and is signed by the hermes signer.
+func (r *Reissuer) Verify(req ReissueRequest, hermesSigner common.Address, p pc.Promise) error {
+	if p.ChainID != req.ChainID {
+		return fmt.Errorf("re-issued promise is for chain %v, expected %v", p.ChainID, req.ChainID)
+	}
+	if !bytes.Equal(p.ChannelID, req.ChannelID) {
+		return errors.New("re-issued promise is for another channel")
+	}
+	if !bytes.Equal(p.Hashlock, req.Hashlock) {
+		burned, err := r.locks.IsLockBurned(p.ChainID, p.ChannelID, p.Hashlock)
+		if err != nil {
+			return fmt.Errorf("could not check the re-issued lock: %w", err)
+		}
+		if burned {
+			return ErrBurnedLock
+		}
+		return errors.New("re-issued promise is not locked with the requested hashlock")
+	}
+	if p.Amount == nil || p.Amount.Cmp(req.Amount) < 0 {
+		return fmt.Errorf("re-issued promise amount %v is lower than the settled amount %v", p.Amount, req.Amount)
+	}
+	if p.Fee == nil {
+		return errors.New("re-issued promise has no fee")
+	}
+	if !p.IsPromiseValid(hermesSigner) {
+		return errors.New("re-issued promise is not signed by hermes")
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	pc "github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type keySigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (ks keySigner) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
+	return crypto.Sign(hash, ks.key)
+}
+
+type memLocks map[string]bool
+
+func (m memLocks) BurnLock(chainID int64, channelID, hashlock []byte) error {
+	m[common.Bytes2Hex(hashlock)] = true
+	return nil
+}
+
+func (m memLocks) IsLockBurned(chainID int64, channelID, hashlock []byte) (bool, error) {
+	return m[common.Bytes2Hex(hashlock)], nil
+}
+
+type mockReissuingHermes struct {
+	signer keySigner
+	addr   common.Address
+	reuse  []byte
+	last   ReissueRequest
+}
+
+func (mh *mockReissuingHermes) ReissuePromise(req ReissueRequest) (pc.Promise, error) {
+	mh.last = req
+	hashlock := req.Hashlock
+	if mh.reuse != nil {
+		hashlock = mh.reuse
+	}
+	p, err := pc.CreatePromise(common.Bytes2Hex(req.ChannelID), req.ChainID, req.Amount, big.NewInt(0), common.Bytes2Hex(hashlock), mh.signer, mh.addr)
+	if err != nil {
+		return pc.Promise{}, err
+	}
+	return *p, nil
+}
+
+func TestReissuer(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	hermes := &mockReissuingHermes{signer: keySigner{key: key}, addr: crypto.PubkeyToAddress(key.PublicKey)}
+	locks := memLocks{}
+	r := NewReissuer(hermes, locks)
+
+	settledR := []byte{1, 2, 3}
+	settled := pc.Promise{
+		ChainID:   1,
+		ChannelID: common.HexToHash("0x1").Bytes(),
+		Amount:    big.NewInt(100),
+		Fee:       big.NewInt(0),
+		Hashlock:  crypto.Keccak256(settledR),
+		R:         settledR,
+	}
+	hermesID := common.HexToAddress("0x2")
+
+	p, err := r.Reissue(hermesID, hermes.addr, settled)
+	assert.NoError(t, err)
+	assert.Equal(t, settledR, hermes.last.RevealedR)
+	assert.Equal(t, crypto.Keccak256(p.R), p.Hashlock)
+	assert.NotEqual(t, settled.Hashlock, p.Hashlock)
+	assert.Equal(t, big.NewInt(100), p.Amount)
+	assert.True(t, locks[common.Bytes2Hex(settled.Hashlock)])
+
+	t.Run("burned lock is refused", func(t *testing.T) {
+		hermes.reuse = settled.Hashlock
+		defer func() { hermes.reuse = nil }()
+		_, err := r.Reissue(hermesID, hermes.addr, settled)
+		assert.Equal(t, ErrBurnedLock, err)
+	})
+
+	t.Run("wrong signer is refused", func(t *testing.T) {
+		_, err := r.Reissue(hermesID, common.HexToAddress("0x3"), settled)
+		assert.Error(t, err)
+	})
+
+	t.Run("R must match the settled lock", func(t *testing.T) {
+		bad := settled
+		bad.R = []byte{4}
+		_, err := r.Reissue(hermesID, hermes.addr, bad)
+		assert.Error(t, err)
+	})
+}
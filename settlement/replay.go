@@ -0,0 +1,139 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+// Dead letter kinds of the client settlement requests.
+const (
+	KindSettleAndRebalance    = "settle_and_rebalance"
+	KindSettleWithBeneficiary = "settle_with_beneficiary"
+	KindSettleIntoStake       = "settle_into_stake"
+)
+
+// Settler submits client settlement requests.
+type Settler interface {
+	SettleAndRebalance(req client.SettleAndRebalanceRequest) (*types.Transaction, error)
+	SettleWithBeneficiary(req client.SettleWithBeneficiaryRequest) (*types.Transaction, error)
+	SettleIntoStake(req client.SettleIntoStakeRequest) (*types.Transaction, error)
+}
+
+// RegisterClientReplayers registers replayers for the client settlement request kinds.
+// Signers are never persisted, so the given signer is set on every replayed request.
+func RegisterClientReplayers(q *DeadLetterQueue, settler Settler, signer bind.SignerFn) {
+	q.Register(KindSettleAndRebalance, func(dl DeadLetter) (*types.Transaction, error) {
+		var req client.SettleAndRebalanceRequest
+		if err := unmarshalRequest(dl, &req, &req.WriteRequest, signer); err != nil {
+			return nil, err
+		}
+		return settler.SettleAndRebalance(req)
+	})
+	q.Register(KindSettleWithBeneficiary, func(dl DeadLetter) (*types.Transaction, error) {
+		var req client.SettleWithBeneficiaryRequest
+		if err := unmarshalRequest(dl, &req, &req.WriteRequest, signer); err != nil {
+			return nil, err
+		}
+		return settler.SettleWithBeneficiary(req)
+	})
+	q.Register(KindSettleIntoStake, func(dl DeadLetter) (*types.Transaction, error) {
+		var req client.SettleIntoStakeRequest
+		if err := unmarshalRequest(dl, &req, &req.WriteRequest, signer); err != nil {
+			return nil, err
+		}
+		return settler.SettleIntoStake(req)
+	})
+}
+
+func unmarshalRequest(dl DeadLetter, req interface{}, wr *client.WriteRequest, signer bind.SignerFn) error {
+	if err := json.Unmarshal(dl.Request, req); err != nil {
+		return fmt.Errorf("could not unmarshal %v request: %w", dl.Kind, err)
+	}
+
+	wr.Signer = signer
+	// The nonce of a failed attempt is likely used or stale by now.
+	wr.Nonce = nil
+	if dl.GasPrice != nil {
+		wr.GasPrice = dl.GasPrice
+	}
+	if dl.GasLimit != 0 {
+		wr.GasLimit = dl.GasLimit
+	}
+	return nil
+}
+
+// RegisterClientExecutors registers executors for the client settlement request kinds.
+// The fee of a scheduled client settlement is the fee of its promise.
+func RegisterClientExecutors(s *Scheduler, settler Settler, signer bind.SignerFn) {
+	s.Register(KindSettleAndRebalance, func(ss ScheduledSettlement, gasPrice *big.Int) (*types.Transaction, error) {
+		var req client.SettleAndRebalanceRequest
+		if err := unmarshalRequest(scheduledLetter(ss, gasPrice), &req, &req.WriteRequest, signer); err != nil {
+			return nil, err
+		}
+		return settler.SettleAndRebalance(req)
+	}, promiseFee)
+	s.Register(KindSettleWithBeneficiary, func(ss ScheduledSettlement, gasPrice *big.Int) (*types.Transaction, error) {
+		var req client.SettleWithBeneficiaryRequest
+		if err := unmarshalRequest(scheduledLetter(ss, gasPrice), &req, &req.WriteRequest, signer); err != nil {
+			return nil, err
+		}
+		return settler.SettleWithBeneficiary(req)
+	}, promiseFee)
+	s.Register(KindSettleIntoStake, func(ss ScheduledSettlement, gasPrice *big.Int) (*types.Transaction, error) {
+		var req client.SettleIntoStakeRequest
+		if err := unmarshalRequest(scheduledLetter(ss, gasPrice), &req, &req.WriteRequest, signer); err != nil {
+			return nil, err
+		}
+		return settler.SettleIntoStake(req)
+	}, promiseFee)
+}
+
+func scheduledLetter(ss ScheduledSettlement, gasPrice *big.Int) DeadLetter {
+	return DeadLetter{ID: ss.ID, Kind: ss.Kind, Request: ss.Request, GasPrice: gasPrice}
+}
+
+func promiseFee(ss ScheduledSettlement) (*big.Int, error) {
+	var req struct {
+		Promise crypto.Promise
+	}
+	if err := json.Unmarshal(ss.Request, &req); err != nil {
+		return nil, fmt.Errorf("could not unmarshal %v request: %w", ss.Kind, err)
+	}
+	if req.Promise.Fee == nil {
+		return new(big.Int), nil
+	}
+	return req.Promise.Fee, nil
+}
+
+// promiseAmount returns the amount of the promise in the request, or nil if there is none.
+func promiseAmount(ss ScheduledSettlement) *big.Int {
+	var req struct {
+		Promise crypto.Promise
+	}
+	if err := json.Unmarshal(ss.Request, &req); err != nil {
+		return nil
+	}
+	return req.Promise.Amount
+}
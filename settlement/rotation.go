@@ -0,0 +1,292 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+var (
+	// ErrRotationInProgress is returned when the beneficiary rotation of a provider is not confirmed yet.
+	ErrRotationInProgress = errors.New("beneficiary rotation in progress")
+	// ErrRotationNotVerifying is returned when confirming a rotation whose verification settlement is not mined.
+	ErrRotationNotVerifying = errors.New("beneficiary rotation is not awaiting confirmation")
+	// ErrRotationFailed is returned for the providers whose last beneficiary rotation failed verification.
+	ErrRotationFailed = errors.New("beneficiary rotation failed")
+	// ErrVerificationAmount is returned when the verification promise would settle nothing or more than the allowed amount.
+	ErrVerificationAmount = errors.New("verification promise amount out of bounds")
+)
+
+// RotationStatus is the state of a beneficiary rotation.
+type RotationStatus string
+
+const (
+	// RotationPending means the verification settlement to the new beneficiary was sent and is not mined yet.
+	RotationPending RotationStatus = "pending"
+	// RotationVerifying means the verification settlement was mined,
+	// the owner has to confirm the verification amount arrived at the new beneficiary.
+	RotationVerifying RotationStatus = "verifying"
+	// RotationSwitched means the owner confirmed the new beneficiary and the full payouts go to it.
+	RotationSwitched RotationStatus = "switched"
+	// RotationFailed means the verification settlement reverted or did not change the beneficiary.
+	RotationFailed RotationStatus = "failed"
+)
+
+// Rotation is the record of the last beneficiary rotation of a provider.
+type Rotation struct {
+	Provider common.Address
+	HermesID common.Address
+	// Previous is the beneficiary the payouts went to before the rotation.
+	Previous    common.Address
+	Beneficiary common.Address
+	Status      RotationStatus
+	// VerificationTx is the hash of the minimal settlement to the new beneficiary.
+	VerificationTx     common.Hash
+	VerificationAmount *big.Int
+	// Error is the reason of a failed rotation.
+	Error     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// RotationStorage persists the rotation records, one per provider.
+type RotationStorage interface {
+	UpsertRotation(r Rotation) error
+	// GetRotation returns nil if the provider has no rotation.
+	GetRotation(provider common.Address) (*Rotation, error)
+}
+
+// RotationChain reads the provider channel and beneficiary state. The BC client can be used.
+type RotationChain interface {
+	GetProviderChannel(hermesAddress common.Address, addressToCheck common.Address, pending bool) (client.ProviderChannel, error)
+	GetBeneficiary(registryAddress, identity common.Address) (common.Address, error)
+	TransactionReceipt(hash common.Hash) (*types.Receipt, error)
+}
+
+// RotationSettler sends the verification settlement. The BC client can be used.
+type RotationSettler interface {
+	SettleWithBeneficiary(req client.SettleWithBeneficiaryRequest) (*types.Transaction, error)
+}
+
+// IdentitySigner signs hashes with the provider identity key.
+type IdentitySigner interface {
+	SignHash(a accounts.Account, hash []byte) ([]byte, error)
+}
+
+// RotationRequest starts a beneficiary rotation.
+type RotationRequest struct {
+	client.WriteRequest
+	HermesID    common.Address
+	ProviderID  common.Address
+	Beneficiary common.Address
+	// Promise is the hermes promise settled to the new beneficiary as verification.
+	// It must settle no more than the maximum verification amount over the already settled amount.
+	Promise crypto.Promise
+}
+
+// BeneficiaryRotator rotates provider beneficiaries in steps, minimizing the cost of a mistyped address.
+// The beneficiary change is signed and sent along a minimal settlement to the new address.
+// Once it is mined the owner confirms the minimal amount arrived, and only then the full payouts are switched
+// to the new beneficiary. Until then Ready holds them back, a mistyped beneficiary is fixed by starting a new rotation.
+type BeneficiaryRotator struct {
+	chain     RotationChain
+	settler   RotationSettler
+	storage   RotationStorage
+	ks        IdentitySigner
+	registry  common.Address
+	chainID   int64
+	maxAmount *big.Int
+	now       func() time.Time
+
+	lock sync.Mutex
+}
+
+// NewBeneficiaryRotator returns a new beneficiary rotator.
+// maxAmount is the largest amount the verification settlement may transfer to the new beneficiary.
+func NewBeneficiaryRotator(chain RotationChain, settler RotationSettler, storage RotationStorage, ks IdentitySigner, registry common.Address, chainID int64, maxAmount *big.Int) *BeneficiaryRotator {
+	return &BeneficiaryRotator{
+		chain:     chain,
+		settler:   settler,
+		storage:   storage,
+		ks:        ks,
+		registry:  registry,
+		chainID:   chainID,
+		maxAmount: maxAmount,
+		now:       time.Now,
+	}
+}
+
+// Start signs the beneficiary change and sends the verification settlement to the new beneficiary.
+// The rotation stays in RotationPending until Check observes the settlement mined.
+// A rotation awaiting confirmation is replaced, e.g. to correct a mistyped beneficiary.
+func (br *BeneficiaryRotator) Start(req RotationRequest) (Rotation, error) {
+	br.lock.Lock()
+	defer br.lock.Unlock()
+
+	if req.Beneficiary == (common.Address{}) {
+		return Rotation{}, errors.New("new beneficiary is not set")
+	}
+
+	existing, err := br.storage.GetRotation(req.ProviderID)
+	if err != nil {
+		return Rotation{}, err
+	}
+	if existing != nil && existing.Status == RotationPending {
+		return Rotation{}, fmt.Errorf("%w: %v", ErrRotationInProgress, existing.VerificationTx.Hex())
+	}
+
+	channel, err := br.chain.GetProviderChannel(req.HermesID, req.ProviderID, false)
+	if err != nil {
+		return Rotation{}, fmt.Errorf("could not get provider channel: %w", err)
+	}
+
+	amount := new(big.Int).Sub(req.Promise.Amount, channel.Settled)
+	if amount.Sign() <= 0 || amount.Cmp(br.maxAmount) > 0 {
+		return Rotation{}, fmt.Errorf("%w: settles %v, at most %v allowed", ErrVerificationAmount, amount, br.maxAmount)
+	}
+
+	previous, err := br.chain.GetBeneficiary(br.registry, req.ProviderID)
+	if err != nil {
+		return Rotation{}, fmt.Errorf("could not get current beneficiary: %w", err)
+	}
+
+	nonce := new(big.Int).Add(channel.LastUsedNonce, big.NewInt(1))
+	signed, err := crypto.CreateBeneficiaryRequest(br.chainID, req.ProviderID.Hex(), br.registry.Hex(), req.Beneficiary.Hex(), nonce, br.ks, req.ProviderID)
+	if err != nil {
+		return Rotation{}, fmt.Errorf("could not sign beneficiary change: %w", err)
+	}
+	signature, err := hex.DecodeString(signed.Signature)
+	if err != nil {
+		return Rotation{}, err
+	}
+
+	tx, err := br.settler.SettleWithBeneficiary(client.SettleWithBeneficiaryRequest{
+		WriteRequest: req.WriteRequest,
+		Promise:      req.Promise,
+		HermesID:     req.HermesID,
+		ProviderID:   req.ProviderID,
+		Beneficiary:  req.Beneficiary,
+		Signature:    signature,
+	})
+	if err != nil {
+		return Rotation{}, fmt.Errorf("could not send verification settlement: %w", err)
+	}
+
+	now := br.now()
+	r := Rotation{
+		Provider:           req.ProviderID,
+		HermesID:           req.HermesID,
+		Previous:           previous,
+		Beneficiary:        req.Beneficiary,
+		Status:             RotationPending,
+		VerificationTx:     tx.Hash(),
+		VerificationAmount: amount,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	return r, br.storage.UpsertRotation(r)
+}
+
+// Check advances the rotation of the provider once its verification settlement is mined.
+// The rotation awaits confirmation if the settlement succeeded and the registry reports the new beneficiary, it fails otherwise.
+func (br *BeneficiaryRotator) Check(provider common.Address) (Rotation, error) {
+	br.lock.Lock()
+	defer br.lock.Unlock()
+
+	r, err := br.storage.GetRotation(provider)
+	if err != nil {
+		return Rotation{}, err
+	}
+	if r == nil {
+		return Rotation{}, fmt.Errorf("no beneficiary rotation for provider %v", provider.Hex())
+	}
+	if r.Status != RotationPending {
+		return *r, nil
+	}
+
+	receipt, err := br.chain.TransactionReceipt(r.VerificationTx)
+	if errors.Is(err, ethereum.NotFound) {
+		return *r, nil
+	}
+	if err != nil {
+		return Rotation{}, fmt.Errorf("could not get verification receipt: %w", err)
+	}
+
+	r.Status = RotationVerifying
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		r.Status, r.Error = RotationFailed, "verification settlement reverted"
+	} else {
+		current, err := br.chain.GetBeneficiary(br.registry, provider)
+		if err != nil {
+			return Rotation{}, fmt.Errorf("could not get current beneficiary: %w", err)
+		}
+		if current != r.Beneficiary {
+			r.Status, r.Error = RotationFailed, fmt.Sprintf("registry reports beneficiary %v", current.Hex())
+		}
+	}
+
+	r.UpdatedAt = br.now()
+	return *r, br.storage.UpsertRotation(*r)
+}
+
+// Confirm switches the full payouts of the provider to the new beneficiary.
+// Call it once the owner confirmed the verification amount arrived at the new beneficiary.
+func (br *BeneficiaryRotator) Confirm(provider common.Address) (Rotation, error) {
+	br.lock.Lock()
+	defer br.lock.Unlock()
+
+	r, err := br.storage.GetRotation(provider)
+	if err != nil {
+		return Rotation{}, err
+	}
+	if r == nil || r.Status != RotationVerifying {
+		return Rotation{}, ErrRotationNotVerifying
+	}
+
+	r.Status = RotationSwitched
+	r.UpdatedAt = br.now()
+	return *r, br.storage.UpsertRotation(*r)
+}
+
+// Ready returns nil if the full payouts of the provider can be settled.
+// They are held back until the rotation is confirmed, and after it failed until a new rotation succeeds.
+func (br *BeneficiaryRotator) Ready(provider common.Address) error {
+	r, err := br.storage.GetRotation(provider)
+	if err != nil {
+		return err
+	}
+	switch {
+	case r == nil || r.Status == RotationSwitched:
+		return nil
+	case r.Status == RotationPending || r.Status == RotationVerifying:
+		return fmt.Errorf("%w: %v", ErrRotationInProgress, r.Status)
+	default:
+		return fmt.Errorf("%w: %v", ErrRotationFailed, r.Error)
+	}
+}
@@ -0,0 +1,155 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/mysteriumnetwork/payments/client"
+	pc "github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type memRotations map[common.Address]Rotation
+
+func (m memRotations) UpsertRotation(r Rotation) error {
+	m[r.Provider] = r
+	return nil
+}
+
+func (m memRotations) GetRotation(provider common.Address) (*Rotation, error) {
+	r, ok := m[provider]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+type mockRotationChain struct {
+	beneficiary common.Address
+	receipt     *types.Receipt
+	sent        []client.SettleWithBeneficiaryRequest
+}
+
+func (m *mockRotationChain) GetProviderChannel(hermesAddress, addressToCheck common.Address, pending bool) (client.ProviderChannel, error) {
+	return client.ProviderChannel{Settled: big.NewInt(100), LastUsedNonce: big.NewInt(4)}, nil
+}
+
+func (m *mockRotationChain) GetBeneficiary(registryAddress, identity common.Address) (common.Address, error) {
+	return m.beneficiary, nil
+}
+
+func (m *mockRotationChain) TransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	if m.receipt == nil {
+		return nil, ethereum.NotFound
+	}
+	return m.receipt, nil
+}
+
+func (m *mockRotationChain) SettleWithBeneficiary(req client.SettleWithBeneficiaryRequest) (*types.Transaction, error) {
+	m.sent = append(m.sent, req)
+	return types.NewTransaction(uint64(len(m.sent)), req.HermesID, nil, 0, nil, nil), nil
+}
+
+func TestBeneficiaryRotation(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	provider := crypto.PubkeyToAddress(key.PublicKey)
+	registry, hermes := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	previous, next := common.HexToAddress("0x3"), common.HexToAddress("0x4")
+
+	chain := &mockRotationChain{beneficiary: previous}
+	storage := memRotations{}
+	rotator := NewBeneficiaryRotator(chain, chain, storage, keySigner{key: key}, registry, 5, big.NewInt(10))
+
+	req := RotationRequest{
+		HermesID:    hermes,
+		ProviderID:  provider,
+		Beneficiary: next,
+		Promise:     pc.Promise{Amount: big.NewInt(150)},
+	}
+	_, err := rotator.Start(req)
+	assert.True(t, errors.Is(err, ErrVerificationAmount))
+
+	req.Promise.Amount = big.NewInt(105)
+	r, err := rotator.Start(req)
+	assert.NoError(t, err)
+	assert.Equal(t, RotationPending, r.Status)
+	assert.Equal(t, previous, r.Previous)
+	assert.Equal(t, big.NewInt(5), r.VerificationAmount)
+	assert.True(t, errors.Is(rotator.Ready(provider), ErrRotationInProgress))
+
+	signed := pc.SetBeneficiaryRequest{
+		ChainID:     5,
+		Registry:    registry.Hex(),
+		Identity:    provider.Hex(),
+		Beneficiary: next.Hex(),
+		Nonce:       big.NewInt(5),
+		Signature:   common.Bytes2Hex(chain.sent[0].Signature),
+	}
+	signer, err := signed.RecoverSigner()
+	assert.NoError(t, err)
+	assert.Equal(t, provider, signer)
+
+	_, err = rotator.Start(req)
+	assert.True(t, errors.Is(err, ErrRotationInProgress))
+
+	r, err = rotator.Check(provider)
+	assert.NoError(t, err)
+	assert.Equal(t, RotationPending, r.Status)
+	_, err = rotator.Confirm(provider)
+	assert.Equal(t, ErrRotationNotVerifying, err)
+
+	chain.receipt = &types.Receipt{Status: types.ReceiptStatusSuccessful}
+	chain.beneficiary = next
+	r, err = rotator.Check(provider)
+	assert.NoError(t, err)
+	assert.Equal(t, RotationVerifying, r.Status)
+	assert.True(t, errors.Is(rotator.Ready(provider), ErrRotationInProgress))
+
+	r, err = rotator.Confirm(provider)
+	assert.NoError(t, err)
+	assert.Equal(t, RotationSwitched, r.Status)
+	assert.NoError(t, rotator.Ready(provider))
+}
+
+func TestBeneficiaryRotationFailsOnRevert(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	provider := crypto.PubkeyToAddress(key.PublicKey)
+
+	chain := &mockRotationChain{beneficiary: common.HexToAddress("0x3")}
+	rotator := NewBeneficiaryRotator(chain, chain, memRotations{}, keySigner{key: key}, common.HexToAddress("0x1"), 5, big.NewInt(10))
+
+	_, err := rotator.Start(RotationRequest{
+		HermesID:    common.HexToAddress("0x2"),
+		ProviderID:  provider,
+		Beneficiary: common.HexToAddress("0x4"),
+		Promise:     pc.Promise{Amount: big.NewInt(101)},
+	})
+	assert.NoError(t, err)
+
+	chain.receipt = &types.Receipt{Status: types.ReceiptStatusFailed}
+	r, err := rotator.Check(provider)
+	assert.NoError(t, err)
+	assert.Equal(t, RotationFailed, r.Status)
+	assert.True(t, errors.Is(rotator.Ready(provider), ErrRotationFailed))
+}
@@ -0,0 +1,349 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/mysteriumnetwork/payments/bus"
+)
+
+// ErrNoExecutor is returned when scheduling a settlement of a kind without a registered ExecuteFunc.
+var ErrNoExecutor = errors.New("no executor registered for scheduled settlement kind")
+
+// Constraints must be met before a scheduled settlement is executed. Nil values are not checked.
+type Constraints struct {
+	MaxGasPrice *big.Int
+	// MaxFee is the highest settlement fee accepted, checked only for kinds registered with a FeeFunc.
+	MaxFee *big.Int
+	// Strategy names a registered SettlementStrategy that must approve the settlement.
+	Strategy string
+}
+
+// ScheduledSettlement is a settlement request waiting for its time and constraints.
+type ScheduledSettlement struct {
+	ID string
+	// Kind identifies the request type and the ExecuteFunc used to execute it.
+	Kind string
+	// Request is the JSON encoded request.
+	Request     json.RawMessage
+	NotBefore   time.Time
+	Constraints Constraints
+	// LastError is the error of the last failed execution.
+	LastError string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ScheduleStorage persists scheduled settlements.
+type ScheduleStorage interface {
+	UpsertScheduledSettlement(s ScheduledSettlement) error
+	DeleteScheduledSettlement(id string) error
+	// GetScheduledSettlement returns nil if the scheduled settlement does not exist.
+	GetScheduledSettlement(id string) (*ScheduledSettlement, error)
+	GetScheduledSettlements() ([]ScheduledSettlement, error)
+}
+
+// ExecuteFunc submits a scheduled settlement with the given gas price.
+type ExecuteFunc func(s ScheduledSettlement, gasPrice *big.Int) (*types.Transaction, error)
+
+// FeeFunc returns the current fee of a scheduled settlement.
+type FeeFunc func(s ScheduledSettlement) (*big.Int, error)
+
+// TopicScheduledSettlementExecuted is the bus topic ScheduledSettlementExecuted events are published to.
+const TopicScheduledSettlementExecuted bus.Topic = "settlement.scheduled_executed"
+
+// ScheduledSettlementExecuted is published after the scheduler attempts a due settlement.
+// Err is set if the attempt failed, the settlement is then retried on the next run.
+type ScheduledSettlementExecuted struct {
+	Settlement ScheduledSettlement
+	Tx         *types.Transaction
+	Err        error
+}
+
+// Topic implements bus.Event.
+func (ScheduledSettlementExecuted) Topic() bus.Topic {
+	return TopicScheduledSettlementExecuted
+}
+
+type executor struct {
+	execute ExecuteFunc
+	fee     FeeFunc
+}
+
+// Scheduler executes settlements once their time has come and their constraints are met.
+// Scheduled settlements survive restarts as long as the storage is persistent.
+type Scheduler struct {
+	storage  ScheduleStorage
+	gas      GasPricer
+	interval time.Duration
+	now      func() time.Time
+	logFunc  LogFunc
+	bus      *bus.Bus
+
+	lock       sync.Mutex
+	executors  map[string]executor
+	strategies map[string]SettlementStrategy
+	stop       chan struct{}
+	once       sync.Once
+}
+
+// NewScheduler returns a new settlement scheduler checking the due settlements every interval.
+func NewScheduler(storage ScheduleStorage, gas GasPricer, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		storage:    storage,
+		gas:        gas,
+		interval:   interval,
+		now:        time.Now,
+		logFunc:    func(error) {},
+		executors:  make(map[string]executor),
+		strategies: make(map[string]SettlementStrategy),
+		stop:       make(chan struct{}),
+	}
+}
+
+// AttachLogFunc sets the function failed executions are reported to.
+// Not thread safe, call before Run.
+func (s *Scheduler) AttachLogFunc(f LogFunc) {
+	s.logFunc = f
+}
+
+// AttachBus sets the bus executions are published to, see ScheduledSettlementExecuted.
+// Not thread safe, call before Run.
+func (s *Scheduler) AttachBus(b *bus.Bus) {
+	s.bus = b
+}
+
+// Register sets the functions used to execute settlements of the given kind. The fee function can be nil.
+func (s *Scheduler) Register(kind string, execute ExecuteFunc, fee FeeFunc) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.executors[kind] = executor{execute: execute, fee: fee}
+}
+
+// RegisterStrategy makes the strategy available to scheduled settlements under its name, see Constraints.Strategy.
+func (s *Scheduler) RegisterStrategy(strategy SettlementStrategy) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.strategies[strategy.Name()] = strategy
+}
+
+// ScheduleSettlement stores the request to be executed not before the given time and once the constraints are met.
+// Scheduling the same request again replaces its time and constraints.
+func (s *Scheduler) ScheduleSettlement(kind string, request interface{}, notBefore time.Time, constraints Constraints) (ScheduledSettlement, error) {
+	blob, err := json.Marshal(request)
+	if err != nil {
+		return ScheduledSettlement{}, fmt.Errorf("could not marshal request: %w", err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.executors[kind]; !ok {
+		return ScheduledSettlement{}, fmt.Errorf("%w: %v", ErrNoExecutor, kind)
+	}
+	if err := s.checkStrategy(constraints); err != nil {
+		return ScheduledSettlement{}, err
+	}
+
+	id := crypto.Keccak256Hash([]byte(kind), blob).Hex()
+	ss, err := s.storage.GetScheduledSettlement(id)
+	if err != nil {
+		return ScheduledSettlement{}, err
+	}
+
+	now := s.now().UTC()
+	if ss == nil {
+		ss = &ScheduledSettlement{
+			ID:        id,
+			Kind:      kind,
+			Request:   blob,
+			CreatedAt: now,
+		}
+	}
+	ss.NotBefore = notBefore.UTC()
+	ss.Constraints = constraints
+	ss.UpdatedAt = now
+
+	return *ss, s.storage.UpsertScheduledSettlement(*ss)
+}
+
+// Modify changes the time and constraints of the scheduled settlement.
+func (s *Scheduler) Modify(id string, notBefore time.Time, constraints Constraints) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.checkStrategy(constraints); err != nil {
+		return err
+	}
+	ss, err := s.mustGet(id)
+	if err != nil {
+		return err
+	}
+
+	ss.NotBefore = notBefore.UTC()
+	ss.Constraints = constraints
+	ss.UpdatedAt = s.now().UTC()
+	return s.storage.UpsertScheduledSettlement(*ss)
+}
+
+// Cancel drops the scheduled settlement.
+func (s *Scheduler) Cancel(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, err := s.mustGet(id); err != nil {
+		return err
+	}
+	return s.storage.DeleteScheduledSettlement(id)
+}
+
+// List returns all the scheduled settlements.
+func (s *Scheduler) List() ([]ScheduledSettlement, error) {
+	return s.storage.GetScheduledSettlements()
+}
+
+// Get returns the scheduled settlement with the given id, or nil if it does not exist.
+func (s *Scheduler) Get(id string) (*ScheduledSettlement, error) {
+	return s.storage.GetScheduledSettlement(id)
+}
+
+// Run executes the due settlements until stopped.
+func (s *Scheduler) Run() {
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(s.interval):
+			if err := s.ExecuteDue(); err != nil {
+				s.logFunc(fmt.Errorf("could not execute scheduled settlements: %w", err))
+			}
+		}
+	}
+}
+
+// Stop stops the scheduler.
+func (s *Scheduler) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+}
+
+// ExecuteDue executes the settlements whose time has come and whose constraints are met.
+// Executed settlements are removed, failed ones are kept and retried on the next run.
+func (s *Scheduler) ExecuteDue() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	all, err := s.storage.GetScheduledSettlements()
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	var gasPrice *big.Int
+	for _, ss := range all {
+		if now.Before(ss.NotBefore) {
+			continue
+		}
+
+		ex, ok := s.executors[ss.Kind]
+		if !ok {
+			s.logFunc(fmt.Errorf("%w: %v", ErrNoExecutor, ss.Kind))
+			continue
+		}
+
+		if gasPrice == nil {
+			gasPrice, err = s.gas.SuggestGasPrice()
+			if err != nil {
+				return fmt.Errorf("could not get gas price: %w", err)
+			}
+		}
+
+		met, err := s.constraintsMet(ss, ex, gasPrice)
+		if err != nil {
+			s.logFunc(fmt.Errorf("could not check constraints of scheduled settlement %v: %w", ss.ID, err))
+			continue
+		}
+		if !met {
+			continue
+		}
+
+		tx, err := ex.execute(ss, gasPrice)
+		s.bus.Publish(ScheduledSettlementExecuted{Settlement: ss, Tx: tx, Err: err})
+		if err != nil {
+			s.logFunc(fmt.Errorf("could not execute scheduled settlement %v: %w", ss.ID, err))
+			ss.LastError = errorChain(err)
+			ss.UpdatedAt = s.now().UTC()
+			if err := s.storage.UpsertScheduledSettlement(ss); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.storage.DeleteScheduledSettlement(ss.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) constraintsMet(ss ScheduledSettlement, ex executor, gasPrice *big.Int) (bool, error) {
+	c := ss.Constraints
+	if c.MaxGasPrice != nil && gasPrice.Cmp(c.MaxGasPrice) > 0 {
+		return false, nil
+	}
+
+	var fee *big.Int
+	if ex.fee != nil && (c.MaxFee != nil || c.Strategy != "") {
+		var err error
+		fee, err = ex.fee(ss)
+		if err != nil {
+			return false, err
+		}
+	}
+	if c.MaxFee != nil && fee != nil && fee.Cmp(c.MaxFee) > 0 {
+		return false, nil
+	}
+
+	if c.Strategy == "" {
+		return true, nil
+	}
+	strategy, ok := s.strategies[c.Strategy]
+	if !ok {
+		return false, fmt.Errorf("%w: %v", ErrUnknownStrategy, c.Strategy)
+	}
+	state := ChannelState{Settlement: ss, Amount: promiseAmount(ss), Now: s.now()}
+	return strategy.ShouldSettle(state, Fees{Settlement: fee}, gasPrice)
+}
+
+func (s *Scheduler) mustGet(id string) (*ScheduledSettlement, error) {
+	ss, err := s.storage.GetScheduledSettlement(id)
+	if err != nil {
+		return nil, err
+	}
+	if ss == nil {
+		return nil, fmt.Errorf("scheduled settlement %v not found", id)
+	}
+	return ss, nil
+}
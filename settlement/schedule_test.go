@@ -0,0 +1,113 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/bus"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type memSchedule map[string]ScheduledSettlement
+
+func (m memSchedule) UpsertScheduledSettlement(s ScheduledSettlement) error { m[s.ID] = s; return nil }
+func (m memSchedule) DeleteScheduledSettlement(id string) error             { delete(m, id); return nil }
+func (m memSchedule) GetScheduledSettlement(id string) (*ScheduledSettlement, error) {
+	s, ok := m[id]
+	if !ok {
+		return nil, nil
+	}
+	return &s, nil
+}
+func (m memSchedule) GetScheduledSettlements() ([]ScheduledSettlement, error) {
+	var res []ScheduledSettlement
+	for _, s := range m {
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+type mutableGasPrice struct {
+	price *big.Int
+}
+
+func (m *mutableGasPrice) SuggestGasPrice() (*big.Int, error) {
+	return m.price, nil
+}
+
+func TestScheduler(t *testing.T) {
+	storage := memSchedule{}
+	gas := &mutableGasPrice{price: big.NewInt(100)}
+	s := NewScheduler(storage, gas, time.Hour)
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	settler := &mockSettler{}
+	RegisterClientExecutors(s, settler, nil)
+	b := bus.New()
+	var executed []ScheduledSettlementExecuted
+	b.Subscribe(TopicScheduledSettlementExecuted, func(e bus.Event) {
+		executed = append(executed, e.(ScheduledSettlementExecuted))
+	})
+	s.AttachBus(b)
+
+	req := client.SettleWithBeneficiaryRequest{
+		WriteRequest: client.WriteRequest{GasLimit: 100000},
+		Promise:      crypto.Promise{Amount: big.NewInt(10), Fee: big.NewInt(3)},
+		HermesID:     common.HexToAddress("0x1"),
+		Beneficiary:  common.HexToAddress("0x2"),
+	}
+	ss, err := s.ScheduleSettlement(KindSettleWithBeneficiary, req, now.Add(7*24*time.Hour), Constraints{MaxGasPrice: big.NewInt(50)})
+	assert.NoError(t, err)
+
+	_, err = s.ScheduleSettlement("unknown", req, now, Constraints{})
+	assert.True(t, errors.Is(err, ErrNoExecutor))
+
+	// not yet due
+	assert.NoError(t, s.ExecuteDue())
+	assert.Len(t, storage, 1)
+	assert.Nil(t, settler.received.GasPrice)
+
+	// due, but gas is too expensive
+	now = now.Add(8 * 24 * time.Hour)
+	assert.NoError(t, s.ExecuteDue())
+	assert.Len(t, storage, 1)
+
+	// the fee constraint is not met
+	assert.NoError(t, s.Modify(ss.ID, ss.NotBefore, Constraints{MaxGasPrice: big.NewInt(200), MaxFee: big.NewInt(2)}))
+	assert.NoError(t, s.ExecuteDue())
+	assert.Len(t, storage, 1)
+
+	assert.NoError(t, s.Modify(ss.ID, ss.NotBefore, Constraints{MaxGasPrice: big.NewInt(200), MaxFee: big.NewInt(3)}))
+	assert.NoError(t, s.ExecuteDue())
+	assert.Len(t, storage, 0)
+	assert.Equal(t, big.NewInt(100), settler.received.GasPrice)
+	assert.Len(t, executed, 1)
+	assert.Equal(t, ss.ID, executed[0].Settlement.ID)
+	assert.NoError(t, executed[0].Err)
+
+	ss, err = s.ScheduleSettlement(KindSettleWithBeneficiary, req, now, Constraints{})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Cancel(ss.ID))
+	assert.Error(t, s.Cancel(ss.ID))
+}
@@ -0,0 +1,271 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+)
+
+// TotalBasisPoints is the sum of the shares of a valid split configuration.
+const TotalBasisPoints = 10000
+
+// ErrNoSplitConfig is returned when splitting the proceeds of a provider without a split configuration.
+var ErrNoSplitConfig = errors.New("no split configuration for provider")
+
+// Share is the part of the settlement proceeds paid to a single recipient.
+type Share struct {
+	Recipient   common.Address
+	BasisPoints uint64
+}
+
+// SplitConfig describes how the settlement proceeds of a provider are shared, e.g. 80% to the node host and 20% to the platform.
+type SplitConfig struct {
+	// Shares must add up to TotalBasisPoints. The rounding remainder goes to the first share.
+	Shares []Share
+}
+
+// Validate checks that the shares add up to the whole proceeds.
+func (sc SplitConfig) Validate() error {
+	if len(sc.Shares) == 0 {
+		return errors.New("split configuration has no shares")
+	}
+	var total uint64
+	for _, s := range sc.Shares {
+		if s.Recipient == (common.Address{}) {
+			return errors.New("split share has no recipient")
+		}
+		total += s.BasisPoints
+	}
+	if total != TotalBasisPoints {
+		return fmt.Errorf("split shares add up to %v basis points, expected %v", total, TotalBasisPoints)
+	}
+	return nil
+}
+
+// Amounts splits the proceeds according to the shares.
+func (sc SplitConfig) Amounts(proceeds *big.Int) []*big.Int {
+	res := make([]*big.Int, len(sc.Shares))
+	rest := new(big.Int).Set(proceeds)
+	for i, s := range sc.Shares {
+		res[i] = new(big.Int).Mul(proceeds, new(big.Int).SetUint64(s.BasisPoints))
+		res[i].Div(res[i], big.NewInt(TotalBasisPoints))
+		rest.Sub(rest, res[i])
+	}
+	if len(res) > 0 {
+		res[0].Add(res[0], rest)
+	}
+	return res
+}
+
+// SplitTransfer is a single post-settlement transfer.
+type SplitTransfer struct {
+	Recipient common.Address
+	Amount    *big.Int
+	// TxHash is empty for shares kept by the beneficiary, which need no transfer.
+	TxHash common.Hash
+	Done   bool
+}
+
+// Split is the accounting record of the proceeds of a single settlement.
+type Split struct {
+	// ID is the hash of the settlement transaction.
+	ID       common.Hash
+	Provider common.Address
+	// Beneficiary received the proceeds and sends the transfers.
+	Beneficiary common.Address
+	Proceeds    *big.Int
+	Transfers   []SplitTransfer
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Complete returns true once all the transfers are done.
+func (s Split) Complete() bool {
+	for _, t := range s.Transfers {
+		if !t.Done {
+			return false
+		}
+	}
+	return true
+}
+
+// SplitStorage persists the split records.
+type SplitStorage interface {
+	UpsertSplit(s Split) error
+	// GetSplit returns nil if the split does not exist.
+	GetSplit(id common.Hash) (*Split, error)
+	GetSplits(provider common.Address) ([]Split, error)
+}
+
+// MystTransferrer transfers myst, the client can be used.
+type MystTransferrer interface {
+	TransferMyst(req client.TransferRequest) (*types.Transaction, error)
+}
+
+// Splitter shares the settlement proceeds among multiple beneficiaries with post-settlement token transfers.
+// There is no splitting contract, so the transfers of a split are sent back to back with a single gas price and consecutive nonces,
+// and every transfer is recorded as it is sent: a split interrupted half way is resumed, never paid twice.
+type Splitter struct {
+	storage     SplitStorage
+	transferrer MystTransferrer
+	gas         GasPricer
+	nonces      NonceFunc
+	mystToken   common.Address
+	signer      bind.SignerFn
+	now         func() time.Time
+
+	lock    sync.Mutex
+	configs map[common.Address]SplitConfig
+}
+
+// NewSplitter returns a new settlement proceeds splitter.
+func NewSplitter(storage SplitStorage, transferrer MystTransferrer, gas GasPricer, nonces NonceFunc, mystToken common.Address, signer bind.SignerFn) *Splitter {
+	return &Splitter{
+		storage:     storage,
+		transferrer: transferrer,
+		gas:         gas,
+		nonces:      nonces,
+		mystToken:   mystToken,
+		signer:      signer,
+		now:         time.Now,
+		configs:     make(map[common.Address]SplitConfig),
+	}
+}
+
+// SetConfig sets the split configuration of the provider.
+func (s *Splitter) SetConfig(provider common.Address, config SplitConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.configs[provider] = config
+	return nil
+}
+
+// Config returns the split configuration of the provider.
+func (s *Splitter) Config(provider common.Address) (SplitConfig, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	c, ok := s.configs[provider]
+	return c, ok
+}
+
+// Split shares the proceeds the beneficiary received with the given settlement transaction.
+// Calling it again for the same settlement resumes the transfers that are not done yet.
+func (s *Splitter) Split(settlementTx common.Hash, provider, beneficiary common.Address, proceeds *big.Int) (Split, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	split, err := s.storage.GetSplit(settlementTx)
+	if err != nil {
+		return Split{}, err
+	}
+	if split == nil {
+		config, ok := s.configs[provider]
+		if !ok {
+			return Split{}, fmt.Errorf("%w: %v", ErrNoSplitConfig, provider.Hex())
+		}
+		split, err = s.newSplit(settlementTx, provider, beneficiary, proceeds, config)
+		if err != nil {
+			return Split{}, err
+		}
+	}
+
+	if split.Complete() {
+		return *split, nil
+	}
+	return *split, s.transfer(split)
+}
+
+// Splits returns the split records of the provider.
+func (s *Splitter) Splits(provider common.Address) ([]Split, error) {
+	return s.storage.GetSplits(provider)
+}
+
+func (s *Splitter) newSplit(settlementTx common.Hash, provider, beneficiary common.Address, proceeds *big.Int, config SplitConfig) (*Split, error) {
+	now := s.now().UTC()
+	split := &Split{
+		ID:          settlementTx,
+		Provider:    provider,
+		Beneficiary: beneficiary,
+		Proceeds:    new(big.Int).Set(proceeds),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	for i, amount := range config.Amounts(proceeds) {
+		recipient := config.Shares[i].Recipient
+		split.Transfers = append(split.Transfers, SplitTransfer{
+			Recipient: recipient,
+			Amount:    amount,
+			Done:      recipient == beneficiary || amount.Sign() == 0,
+		})
+	}
+	return split, s.storage.UpsertSplit(*split)
+}
+
+func (s *Splitter) transfer(split *Split) error {
+	gasPrice, err := s.gas.SuggestGasPrice()
+	if err != nil {
+		return fmt.Errorf("could not get gas price: %w", err)
+	}
+	nonce, err := s.nonces(split.Beneficiary)
+	if err != nil {
+		return fmt.Errorf("could not get nonce: %w", err)
+	}
+
+	for i := range split.Transfers {
+		t := &split.Transfers[i]
+		if t.Done {
+			continue
+		}
+
+		tx, err := s.transferrer.TransferMyst(client.TransferRequest{
+			MystAddress: s.mystToken,
+			Recipient:   t.Recipient,
+			Amount:      t.Amount,
+			WriteRequest: client.WriteRequest{
+				Identity: split.Beneficiary,
+				Signer:   s.signer,
+				GasPrice: new(big.Int).Set(gasPrice),
+				Nonce:    new(big.Int).SetUint64(nonce),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("could not transfer the share of %v: %w", t.Recipient.Hex(), err)
+		}
+		nonce++
+
+		t.TxHash = tx.Hash()
+		t.Done = true
+		split.UpdatedAt = s.now().UTC()
+		if err := s.storage.UpsertSplit(*split); err != nil {
+			return fmt.Errorf("could not record the transfer to %v: %w", t.Recipient.Hex(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/stretchr/testify/assert"
+)
+
+type memSplits map[common.Hash]Split
+
+func (m memSplits) UpsertSplit(s Split) error {
+	s.Transfers = append([]SplitTransfer(nil), s.Transfers...)
+	m[s.ID] = s
+	return nil
+}
+func (m memSplits) GetSplit(id common.Hash) (*Split, error) {
+	s, ok := m[id]
+	if !ok {
+		return nil, nil
+	}
+	s.Transfers = append([]SplitTransfer(nil), s.Transfers...)
+	return &s, nil
+}
+func (m memSplits) GetSplits(provider common.Address) ([]Split, error) {
+	var res []Split
+	for _, s := range m {
+		if s.Provider == provider {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+type mockTransferrer struct {
+	failFor common.Address
+	sent    []client.TransferRequest
+}
+
+func (mt *mockTransferrer) TransferMyst(req client.TransferRequest) (*types.Transaction, error) {
+	if req.Recipient == mt.failFor {
+		return nil, errors.New("underpriced")
+	}
+	mt.sent = append(mt.sent, req)
+	return types.NewTransaction(req.Nonce.Uint64(), req.Recipient, nil, 0, req.GasPrice, nil), nil
+}
+
+func TestSplitConfig(t *testing.T) {
+	host, platform := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	assert.Error(t, SplitConfig{Shares: []Share{{Recipient: host, BasisPoints: 8000}}}.Validate())
+
+	sc := SplitConfig{Shares: []Share{{Recipient: host, BasisPoints: 8000}, {Recipient: platform, BasisPoints: 2000}}}
+	assert.NoError(t, sc.Validate())
+	assert.Equal(t, []*big.Int{big.NewInt(81), big.NewInt(20)}, sc.Amounts(big.NewInt(101)))
+}
+
+func TestSplitter(t *testing.T) {
+	storage := memSplits{}
+	transferrer := &mockTransferrer{}
+	nonces := func(sender common.Address) (uint64, error) { return 7, nil }
+	s := NewSplitter(storage, transferrer, fixedGasPrice{}, nonces, common.HexToAddress("0xaa"), nil)
+
+	provider, beneficiary := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	host, platform := common.HexToAddress("0x3"), common.HexToAddress("0x4")
+	settlementTx := common.HexToHash("0x5")
+
+	_, err := s.Split(settlementTx, provider, beneficiary, big.NewInt(100))
+	assert.True(t, errors.Is(err, ErrNoSplitConfig))
+
+	assert.NoError(t, s.SetConfig(provider, SplitConfig{Shares: []Share{
+		{Recipient: beneficiary, BasisPoints: 5000},
+		{Recipient: host, BasisPoints: 3000},
+		{Recipient: platform, BasisPoints: 2000},
+	}}))
+
+	transferrer.failFor = platform
+	split, err := s.Split(settlementTx, provider, beneficiary, big.NewInt(100))
+	assert.Error(t, err)
+	assert.False(t, split.Complete())
+	assert.Len(t, transferrer.sent, 1)
+
+	// resuming sends only the failed transfer
+	transferrer.failFor = common.Address{}
+	split, err = s.Split(settlementTx, provider, beneficiary, big.NewInt(100))
+	assert.NoError(t, err)
+	assert.True(t, split.Complete())
+	assert.Len(t, transferrer.sent, 2)
+	assert.Equal(t, host, transferrer.sent[0].Recipient)
+	assert.Equal(t, big.NewInt(30), transferrer.sent[0].Amount)
+	assert.Equal(t, platform, transferrer.sent[1].Recipient)
+	assert.Equal(t, big.NewInt(20), transferrer.sent[1].Amount)
+	assert.Equal(t, beneficiary, transferrer.sent[1].Identity)
+
+	stored, err := s.Splits(provider)
+	assert.NoError(t, err)
+	assert.Len(t, stored, 1)
+	assert.Equal(t, common.Hash{}, stored[0].Transfers[0].TxHash)
+	assert.True(t, stored[0].Transfers[0].Done)
+}
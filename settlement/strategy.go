@@ -0,0 +1,125 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// ErrUnknownStrategy is returned when scheduling a settlement with a strategy that is not registered.
+var ErrUnknownStrategy = errors.New("unknown settlement strategy")
+
+// ChannelState describes the channel a scheduled settlement would settle.
+type ChannelState struct {
+	Settlement ScheduledSettlement
+	// Amount is the promised amount of the settlement, nil if the request carries no promise.
+	Amount *big.Int
+	Now    time.Time
+}
+
+// Fees are the costs of executing a scheduled settlement.
+type Fees struct {
+	// Settlement is the settlement fee, nil for kinds registered without a FeeFunc.
+	Settlement *big.Int
+}
+
+// SettlementStrategy decides whether a due scheduled settlement should be executed now.
+// Strategies are registered with the scheduler and selected per settlement by Constraints.Strategy,
+// so custom business logic can be plugged in without changing the scheduler.
+type SettlementStrategy interface {
+	// Name uniquely identifies the strategy.
+	Name() string
+	ShouldSettle(state ChannelState, fees Fees, gasPrice *big.Int) (bool, error)
+}
+
+// ThresholdStrategy settles once the amount is large enough and the fee is a small enough share of it.
+type ThresholdStrategy struct {
+	name        string
+	minAmount   *big.Int
+	maxFeeShare float64
+}
+
+// NewThresholdStrategy returns a new threshold strategy. Settlements without a known amount are never settled.
+// If maxFeeShare is zero, the fee share is not checked.
+func NewThresholdStrategy(name string, minAmount *big.Int, maxFeeShare float64) *ThresholdStrategy {
+	return &ThresholdStrategy{
+		name:        name,
+		minAmount:   minAmount,
+		maxFeeShare: maxFeeShare,
+	}
+}
+
+// Name returns the strategy name.
+func (ts *ThresholdStrategy) Name() string {
+	return ts.name
+}
+
+// ShouldSettle returns true if the amount reached the minimum and the fee share is within the limit.
+func (ts *ThresholdStrategy) ShouldSettle(state ChannelState, fees Fees, gasPrice *big.Int) (bool, error) {
+	if state.Amount == nil || state.Amount.Cmp(ts.minAmount) < 0 {
+		return false, nil
+	}
+	if ts.maxFeeShare == 0 || fees.Settlement == nil {
+		return true, nil
+	}
+	if state.Amount.Sign() == 0 {
+		return fees.Settlement.Sign() == 0, nil
+	}
+
+	share, _ := new(big.Float).Quo(new(big.Float).SetInt(fees.Settlement), new(big.Float).SetInt(state.Amount)).Float64()
+	return share <= ts.maxFeeShare, nil
+}
+
+// CronStrategy settles at the times of a cron schedule, e.g. "0 3 * * *" settles daily at 03:00 UTC.
+// A settlement is approved once a scheduled time passed since it was last updated, so failed executions wait for the next one.
+type CronStrategy struct {
+	name     string
+	schedule CronSchedule
+}
+
+// NewCronStrategy returns a new cron strategy for the given five field cron spec.
+func NewCronStrategy(name, spec string) (*CronStrategy, error) {
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &CronStrategy{name: name, schedule: schedule}, nil
+}
+
+// Name returns the strategy name.
+func (cs *CronStrategy) Name() string {
+	return cs.name
+}
+
+// ShouldSettle returns true if a scheduled time passed since the settlement was last updated.
+func (cs *CronStrategy) ShouldSettle(state ChannelState, fees Fees, gasPrice *big.Int) (bool, error) {
+	next, ok := cs.schedule.Next(state.Settlement.UpdatedAt)
+	return ok && !next.After(state.Now), nil
+}
+
+func (s *Scheduler) checkStrategy(c Constraints) error {
+	if c.Strategy == "" {
+		return nil
+	}
+	if _, ok := s.strategies[c.Strategy]; !ok {
+		return fmt.Errorf("%w: %v", ErrUnknownStrategy, c.Strategy)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package settlement
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type strategyFunc func(state ChannelState, fees Fees, gasPrice *big.Int) (bool, error)
+
+func (f strategyFunc) Name() string { return "custom" }
+func (f strategyFunc) ShouldSettle(state ChannelState, fees Fees, gasPrice *big.Int) (bool, error) {
+	return f(state, fees, gasPrice)
+}
+
+func TestSchedulerStrategies(t *testing.T) {
+	storage := memSchedule{}
+	s := NewScheduler(storage, &mutableGasPrice{price: big.NewInt(100)}, time.Hour)
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	RegisterClientExecutors(s, &mockSettler{}, nil)
+
+	var seen []ChannelState
+	var fees []Fees
+	approve := false
+	s.RegisterStrategy(strategyFunc(func(state ChannelState, f Fees, gasPrice *big.Int) (bool, error) {
+		seen = append(seen, state)
+		fees = append(fees, f)
+		return approve, nil
+	}))
+
+	req := client.SettleWithBeneficiaryRequest{
+		Promise:     crypto.Promise{Amount: big.NewInt(10), Fee: big.NewInt(3)},
+		HermesID:    common.HexToAddress("0x1"),
+		Beneficiary: common.HexToAddress("0x2"),
+	}
+	_, err := s.ScheduleSettlement(KindSettleWithBeneficiary, req, now, Constraints{Strategy: "unknown"})
+	assert.True(t, errors.Is(err, ErrUnknownStrategy))
+
+	_, err = s.ScheduleSettlement(KindSettleWithBeneficiary, req, now, Constraints{Strategy: "custom"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.ExecuteDue())
+	assert.Len(t, storage, 1)
+	assert.Len(t, seen, 1)
+	assert.Equal(t, big.NewInt(10), seen[0].Amount)
+	assert.Equal(t, big.NewInt(3), fees[0].Settlement)
+
+	approve = true
+	assert.NoError(t, s.ExecuteDue())
+	assert.Len(t, storage, 0)
+}
+
+func TestThresholdStrategy(t *testing.T) {
+	ts := NewThresholdStrategy("threshold", big.NewInt(100), 0.05)
+	state := func(amount int64) ChannelState { return ChannelState{Amount: big.NewInt(amount)} }
+
+	ok, err := ts.ShouldSettle(state(99), Fees{Settlement: big.NewInt(1)}, nil)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, _ = ts.ShouldSettle(state(100), Fees{Settlement: big.NewInt(6)}, nil)
+	assert.False(t, ok)
+
+	ok, _ = ts.ShouldSettle(state(100), Fees{Settlement: big.NewInt(5)}, nil)
+	assert.True(t, ok)
+
+	ok, _ = ts.ShouldSettle(state(100), Fees{}, nil)
+	assert.True(t, ok)
+
+	ok, _ = ts.ShouldSettle(ChannelState{}, Fees{}, nil)
+	assert.False(t, ok)
+}
+
+func TestCronStrategy(t *testing.T) {
+	_, err := NewCronStrategy("bad", "* * *")
+	assert.Error(t, err)
+	_, err = NewCronStrategy("bad", "60 * * * *")
+	assert.Error(t, err)
+
+	cs, err := NewCronStrategy("nightly", "30 3 * * 1-5")
+	assert.NoError(t, err)
+
+	// a saturday
+	updated := time.Date(2021, 6, 5, 12, 0, 0, 0, time.UTC)
+	next, ok := cs.schedule.Next(updated)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2021, 6, 7, 3, 30, 0, 0, time.UTC), next)
+
+	state := ChannelState{Settlement: ScheduledSettlement{UpdatedAt: updated}, Now: next.Add(-time.Second)}
+	settle, err := cs.ShouldSettle(state, Fees{}, nil)
+	assert.NoError(t, err)
+	assert.False(t, settle)
+
+	state.Now = next
+	settle, _ = cs.ShouldSettle(state, Fees{}, nil)
+	assert.True(t, settle)
+
+	steps, err := ParseCron("*/15 0,12 1 * *")
+	assert.NoError(t, err)
+	next, _ = steps.Next(time.Date(2021, 6, 1, 0, 50, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC), next)
+
+	never, err := ParseCron("0 0 30 2 *")
+	assert.NoError(t, err)
+	_, ok = never.Next(updated)
+	assert.False(t, ok)
+}
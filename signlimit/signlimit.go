@@ -0,0 +1,142 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package signlimit caps the signing throughput of identities, containing the damage of application logic
+// that issues promises in a tight loop.
+package signlimit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrRateLimited is returned when the identity exceeded its signing rate.
+var ErrRateLimited = errors.New("signing rate limit exceeded")
+
+// HashSigner signs hashes, the keystore can be used.
+type HashSigner interface {
+	SignHash(a accounts.Account, hash []byte) ([]byte, error)
+}
+
+// Limit is the allowed signing throughput.
+type Limit struct {
+	// PerSecond is the sustained amount of signatures per second.
+	PerSecond float64
+	// Burst is the amount of signatures allowed at once after a quiet period, at least 1.
+	Burst int
+}
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token bucket per identity.
+type Limiter struct {
+	defaultLimit Limit
+	now          func() time.Time
+
+	lock      sync.Mutex
+	overrides map[common.Address]Limit
+	buckets   map[common.Address]*bucket
+}
+
+// NewLimiter returns a new limiter applying the default limit to every identity without an override.
+func NewLimiter(defaultLimit Limit) *Limiter {
+	return &Limiter{
+		defaultLimit: defaultLimit,
+		now:          time.Now,
+		overrides:    make(map[common.Address]Limit),
+		buckets:      make(map[common.Address]*bucket),
+	}
+}
+
+// SetLimit overrides the limit of the identity.
+func (l *Limiter) SetLimit(identity common.Address, limit Limit) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.overrides[identity] = limit
+	if b, ok := l.buckets[identity]; ok {
+		b.limit = limit
+		if b.tokens > float64(burstOf(limit)) {
+			b.tokens = float64(burstOf(limit))
+		}
+	}
+}
+
+// Allow takes a signature from the bucket of the identity.
+// ErrRateLimited is returned, and nothing is taken, if the bucket is empty.
+func (l *Limiter) Allow(identity common.Address) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[identity]
+	if !ok {
+		limit := l.defaultLimit
+		if o, ok := l.overrides[identity]; ok {
+			limit = o
+		}
+		b = &bucket{limit: limit, tokens: float64(burstOf(limit)), last: now}
+		l.buckets[identity] = b
+	}
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.limit.PerSecond
+		if max := float64(burstOf(b.limit)); b.tokens > max {
+			b.tokens = max
+		}
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return fmt.Errorf("%w: identity %v is limited to %v signatures per second", ErrRateLimited, identity.Hex(), b.limit.PerSecond)
+	}
+	b.tokens--
+	return nil
+}
+
+// Signer wraps the hash signer, so every signature counts against the limit of the signing account.
+// It can be passed to the crypto package wherever a keystore is expected.
+func (l *Limiter) Signer(ks HashSigner) HashSigner {
+	return &limitedSigner{limiter: l, ks: ks}
+}
+
+type limitedSigner struct {
+	limiter *Limiter
+	ks      HashSigner
+}
+
+func (ls *limitedSigner) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
+	if err := ls.limiter.Allow(a.Address); err != nil {
+		return nil, err
+	}
+	return ls.ks.SignHash(a, hash)
+}
+
+func burstOf(limit Limit) int {
+	if limit.Burst < 1 {
+		return 1
+	}
+	return limit.Burst
+}
@@ -0,0 +1,68 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package signlimit
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSigner struct{}
+
+func (fakeSigner) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
+	return make([]byte, 65), nil
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLimiter(Limit{PerSecond: 2, Burst: 2})
+	l.now = func() time.Time { return now }
+
+	identity := common.HexToAddress("0x1")
+	other := common.HexToAddress("0x2")
+	ks := l.Signer(fakeSigner{})
+	channelID := common.HexToHash("0x3").Hex()
+	hashlock := common.HexToHash("0x4").Hex()
+
+	sign := func(signer common.Address) error {
+		_, err := crypto.CreatePromise(channelID, 1, big.NewInt(10), big.NewInt(0), hashlock, ks, signer)
+		return err
+	}
+
+	assert.NoError(t, sign(identity))
+	assert.NoError(t, sign(identity))
+	assert.True(t, errors.Is(sign(identity), ErrRateLimited))
+	assert.NoError(t, sign(other))
+
+	now = now.Add(500 * time.Millisecond)
+	assert.NoError(t, sign(identity))
+	assert.True(t, errors.Is(sign(identity), ErrRateLimited))
+
+	l.SetLimit(identity, Limit{PerSecond: 100, Burst: 10})
+	now = now.Add(100 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, sign(identity))
+	}
+	assert.True(t, errors.Is(sign(identity), ErrRateLimited))
+}
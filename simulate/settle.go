@@ -0,0 +1,254 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package simulate
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+// ErrChannelMismatch is returned when the promise is not issued for the checked channel.
+var ErrChannelMismatch = errors.New("promise is not issued for the channel")
+
+// offlineDeployer deploys the contracts of the offline settlement state.
+var offlineDeployer = common.HexToAddress("0x00000000000000000000000000000000000dE910")
+
+// ConsumerChannel is the on chain state a consumer promise settlement is checked against.
+type ConsumerChannel struct {
+	ChainID int64
+	// Channel is the address of the consumer channel, the promise channel id.
+	Channel  common.Address
+	Identity common.Address
+	HermesID common.Address
+	// HermesOperator is returned by the hermes stub the channel is initialized with.
+	HermesOperator common.Address
+	// Balance is the myst balance of the channel.
+	Balance *big.Int
+	// Settled is the amount hermes already settled from the channel.
+	Settled *big.Int
+}
+
+// SettlementCheck is the outcome of an offline consumer promise settlement.
+type SettlementCheck struct {
+	StepResult
+	// HermesPayout is the amount transferred to hermes.
+	HermesPayout *big.Int
+	// TransactorFee is the amount transferred to the settling transactor.
+	TransactorFee *big.Int
+	// Settled is the channel settled amount after the settlement.
+	Settled *big.Int
+}
+
+// CheckConsumerSettlement executes settlePromise of the shipped channel implementation bytecode against
+// a state constructed from the given channel, without any RPC endpoint.
+// The myst token is deployed from the shipped bytecode too, while hermes is replaced with a stub returning its operator.
+// A failed settlement is not an error, the returned check holds the EVM error and the revert reason.
+func CheckConsumerSettlement(ch ConsumerChannel, promise crypto.Promise, transactor common.Address) (SettlementCheck, error) {
+	if common.BytesToAddress(promise.ChannelID) != ch.Channel {
+		return SettlementCheck{}, ErrChannelMismatch
+	}
+
+	channelABI, err := abi.JSON(strings.NewReader(bindings.ChannelImplementationABI))
+	if err != nil {
+		return SettlementCheck{}, err
+	}
+	tokenABI, err := abi.JSON(strings.NewReader(bindings.MystTokenABI))
+	if err != nil {
+		return SettlementCheck{}, err
+	}
+
+	b, err := NewBundle(core.GenesisAlloc{ch.HermesID: {Code: constantReturnCode(ch.HermesOperator)}}, Opts{ChainID: ch.ChainID})
+	if err != nil {
+		return SettlementCheck{}, err
+	}
+
+	token, err := deployToken(b, tokenABI)
+	if err != nil {
+		return SettlementCheck{}, err
+	}
+	if err := initChannel(b, channelABI, tokenABI, token, ch); err != nil {
+		return SettlementCheck{}, err
+	}
+
+	var lock [32]byte
+	copy(lock[:], promise.R)
+	data, err := channelABI.Pack("settlePromise", promise.Amount, promise.Fee, lock, promise.Signature)
+	if err != nil {
+		return SettlementCheck{}, fmt.Errorf("could not pack settlement: %w", err)
+	}
+
+	hermesBefore, err := balanceOf(b, tokenABI, token, ch.HermesID)
+	if err != nil {
+		return SettlementCheck{}, err
+	}
+	transactorBefore, err := balanceOf(b, tokenABI, token, transactor)
+	if err != nil {
+		return SettlementCheck{}, err
+	}
+
+	res := SettlementCheck{
+		StepResult: b.Apply(Step{Name: "settle promise", From: transactor, To: &ch.Channel, Data: data}),
+	}
+
+	hermesAfter, err := balanceOf(b, tokenABI, token, ch.HermesID)
+	if err != nil {
+		return SettlementCheck{}, err
+	}
+	transactorAfter, err := balanceOf(b, tokenABI, token, transactor)
+	if err != nil {
+		return SettlementCheck{}, err
+	}
+
+	res.HermesPayout = new(big.Int).Sub(hermesAfter, hermesBefore)
+	res.TransactorFee = new(big.Int).Sub(transactorAfter, transactorBefore)
+	res.Settled, err = settledOf(b, channelABI, ch.Channel)
+	if err != nil {
+		return SettlementCheck{}, err
+	}
+	return res, nil
+}
+
+func deployToken(b *Bundle, tokenABI abi.ABI) (common.Address, error) {
+	oldToken := b.Apply(Step{Name: "deploy old myst", From: offlineDeployer, Data: common.FromHex(bindings.OldMystTokenBin)})
+	if !oldToken.Success {
+		return common.Address{}, fmt.Errorf("could not deploy old myst token: %v", oldToken.Err)
+	}
+
+	args, err := tokenABI.Pack("", oldToken.ContractAddress)
+	if err != nil {
+		return common.Address{}, err
+	}
+	token := b.Apply(Step{Name: "deploy myst", From: offlineDeployer, Data: append(common.FromHex(bindings.MystTokenBin), args...)})
+	if !token.Success {
+		return common.Address{}, fmt.Errorf("could not deploy myst token: %v", token.Err)
+	}
+	return token.ContractAddress, nil
+}
+
+// initChannel places the channel implementation code at the channel address, initializes it and funds it.
+// The settled amount is written into the storage slot following the hermes contract address.
+func initChannel(b *Bundle, channelABI, tokenABI abi.ABI, token common.Address, ch ConsumerChannel) error {
+	impl := b.Apply(Step{Name: "deploy channel implementation", From: offlineDeployer, Data: common.FromHex(bindings.ChannelImplementationBin)})
+	if !impl.Success {
+		return fmt.Errorf("could not deploy channel implementation: %v", impl.Err)
+	}
+	b.statedb.SetCode(ch.Channel, b.statedb.GetCode(impl.ContractAddress))
+
+	data, err := channelABI.Pack("initialize", token, common.Address{}, ch.Identity, ch.HermesID, new(big.Int))
+	if err != nil {
+		return err
+	}
+	if err := b.mustApply(Step{Name: "initialize channel", From: offlineDeployer, To: &ch.Channel, Data: data}); err != nil {
+		return err
+	}
+
+	if ch.Balance != nil && ch.Balance.Sign() > 0 {
+		data, err := tokenABI.Pack("mint", ch.Channel, ch.Balance)
+		if err != nil {
+			return err
+		}
+		if err := b.mustApply(Step{Name: "fund channel", From: offlineDeployer, To: &token, Data: data}); err != nil {
+			return err
+		}
+	}
+
+	if ch.Settled == nil || ch.Settled.Sign() == 0 {
+		return nil
+	}
+	return setSettled(b, channelABI, ch)
+}
+
+func setSettled(b *Bundle, channelABI abi.ABI, ch ConsumerChannel) error {
+	hermesWord := common.BytesToHash(ch.HermesID.Bytes())
+	for i := int64(0); i < 32; i++ {
+		if b.statedb.GetState(ch.Channel, common.BigToHash(big.NewInt(i))) != hermesWord {
+			continue
+		}
+
+		b.statedb.SetState(ch.Channel, common.BigToHash(big.NewInt(i+1)), common.BigToHash(ch.Settled))
+		settled, err := settledOf(b, channelABI, ch.Channel)
+		if err != nil {
+			return err
+		}
+		if settled.Cmp(ch.Settled) == 0 {
+			return nil
+		}
+		b.statedb.SetState(ch.Channel, common.BigToHash(big.NewInt(i+1)), common.Hash{})
+	}
+	return errors.New("could not locate the settled amount in the channel storage")
+}
+
+func (b *Bundle) mustApply(step Step) error {
+	res := b.Apply(step)
+	if !res.Success {
+		return fmt.Errorf("could not %v: %v", step.Name, describeFailure(res))
+	}
+	return nil
+}
+
+func balanceOf(b *Bundle, tokenABI abi.ABI, token, holder common.Address) (*big.Int, error) {
+	data, err := tokenABI.Pack("balanceOf", holder)
+	if err != nil {
+		return nil, err
+	}
+	out, err := b.Call(holder, token, data)
+	if err != nil {
+		return nil, fmt.Errorf("could not get balance: %w", err)
+	}
+	return new(big.Int).SetBytes(out), nil
+}
+
+func settledOf(b *Bundle, channelABI abi.ABI, channel common.Address) (*big.Int, error) {
+	data, err := channelABI.Pack("hermes")
+	if err != nil {
+		return nil, err
+	}
+	out, err := b.Call(offlineDeployer, channel, data)
+	if err != nil {
+		return nil, fmt.Errorf("could not get channel hermes: %w", err)
+	}
+
+	var hermes struct {
+		Operator        common.Address
+		ContractAddress common.Address
+		Settled         *big.Int
+	}
+	if err := channelABI.Unpack(&hermes, "hermes", out); err != nil {
+		return nil, fmt.Errorf("could not unpack channel hermes: %w", err)
+	}
+	return hermes.Settled, nil
+}
+
+// constantReturnCode returns runtime bytecode answering every call with the given address.
+func constantReturnCode(value common.Address) []byte {
+	code := append([]byte{0x73}, value.Bytes()...) // PUSH20 value
+	return append(code,
+		0x60, 0x00, // PUSH1 0
+		0x52,       // MSTORE
+		0x60, 0x20, // PUSH1 32
+		0x60, 0x00, // PUSH1 0
+		0xf3, // RETURN
+	)
+}
@@ -0,0 +1,91 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package simulate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckConsumerSettlement(t *testing.T) {
+	key, err := ethcrypto.GenerateKey()
+	assert.NoError(t, err)
+	identity := ethcrypto.PubkeyToAddress(key.PublicKey)
+
+	ch := ConsumerChannel{
+		ChainID:        5,
+		Channel:        common.HexToAddress("0xc0ffee"),
+		Identity:       identity,
+		HermesID:       common.HexToAddress("0x4e"),
+		HermesOperator: common.HexToAddress("0x0e"),
+		Balance:        big.NewInt(1000),
+		Settled:        big.NewInt(40),
+	}
+	transactor := common.HexToAddress("0x7a")
+
+	r := []byte("preimage preimage preimage 32byt")
+	promise, err := crypto.NewPromise(5, ch.Channel.Hex(), big.NewInt(100), big.NewInt(3), common.Bytes2Hex(r), "")
+	assert.NoError(t, err)
+	promise.Signature, err = promise.CreateSignature(signerFunc(func(hash []byte) ([]byte, error) {
+		return ethcrypto.Sign(hash, key)
+	}), identity)
+	assert.NoError(t, err)
+	assert.NoError(t, crypto.ReformatSignatureVForBC(promise.Signature))
+
+	res, err := CheckConsumerSettlement(ch, *promise, transactor)
+	assert.NoError(t, err)
+	assert.True(t, res.Success, res.RevertReason)
+	assert.Equal(t, big.NewInt(57), res.HermesPayout)
+	assert.Equal(t, big.NewInt(3), res.TransactorFee)
+	assert.Equal(t, big.NewInt(100), res.Settled)
+
+	ch.Balance = big.NewInt(20)
+	res, err = CheckConsumerSettlement(ch, *promise, transactor)
+	assert.NoError(t, err)
+	assert.True(t, res.Success, res.RevertReason)
+	assert.Equal(t, big.NewInt(17), res.HermesPayout)
+	assert.Equal(t, big.NewInt(60), res.Settled)
+
+	ch.Settled = big.NewInt(100)
+	res, err = CheckConsumerSettlement(ch, *promise, transactor)
+	assert.NoError(t, err)
+	assert.False(t, res.Success)
+	assert.NotEmpty(t, res.RevertReason)
+
+	ch.Settled = nil
+	ch.Identity = common.HexToAddress("0x1d")
+	res, err = CheckConsumerSettlement(ch, *promise, transactor)
+	assert.NoError(t, err)
+	assert.False(t, res.Success)
+	assert.Zero(t, res.Settled.Sign())
+
+	ch.Channel = common.HexToAddress("0xc1")
+	_, err = CheckConsumerSettlement(ch, *promise, transactor)
+	assert.Equal(t, ErrChannelMismatch, err)
+}
+
+type signerFunc func(hash []byte) ([]byte, error)
+
+func (f signerFunc) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
+	return f(hash)
+}
@@ -0,0 +1,273 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package simulate executes bundles of dependent transactions on an embedded EVM, so multi step flows
+// such as approve, register, top up and settle can be validated end to end before spending gas.
+package simulate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	pc "github.com/mysteriumnetwork/payments/crypto"
+)
+
+// ErrStepFailed is returned by Simulate when a step of the bundle fails.
+var ErrStepFailed = errors.New("bundle step failed")
+
+// DefaultGasLimit is the block gas limit used when none is given.
+const DefaultGasLimit = 12_000_000
+
+// Opts describe the block the bundle is executed in.
+type Opts struct {
+	ChainID     int64
+	BlockNumber uint64
+	// Time is the unix timestamp of the block.
+	Time     uint64
+	GasLimit uint64
+}
+
+// Step is a single transaction of a bundle. Senders are not required to sign, any address can be impersonated.
+type Step struct {
+	Name string
+	From common.Address
+	// To is nil for contract deployments.
+	To    *common.Address
+	Value *big.Int
+	Data  []byte
+	// GasLimit defaults to the block gas limit.
+	GasLimit uint64
+}
+
+// StepResult is the outcome of a single step.
+type StepResult struct {
+	Name    string
+	Success bool
+	GasUsed uint64
+	// ReturnData is the data returned by the call, or the revert data of a failed step.
+	ReturnData []byte
+	// RevertReason is the decoded revert reason, if the step reverted with one.
+	RevertReason string
+	// ContractAddress is the address of the deployed contract for deployments.
+	ContractAddress common.Address
+	Logs            []*types.Log
+	// Err is the EVM error of a failed step.
+	Err error
+}
+
+// Bundle holds the state the steps are applied to. Every step sees the changes of the previous ones.
+type Bundle struct {
+	opts    Opts
+	config  *params.ChainConfig
+	statedb *state.StateDB
+	steps   int
+}
+
+// NewBundle returns a new bundle starting from the given state, see Fork.
+func NewBundle(alloc core.GenesisAlloc, opts Opts) (*Bundle, error) {
+	if opts.GasLimit == 0 {
+		opts.GasLimit = DefaultGasLimit
+	}
+
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create state: %w", err)
+	}
+	for address, account := range alloc {
+		if account.Balance != nil {
+			statedb.SetBalance(address, account.Balance)
+		}
+		statedb.SetNonce(address, account.Nonce)
+		statedb.SetCode(address, account.Code)
+		for key, value := range account.Storage {
+			statedb.SetState(address, key, value)
+		}
+	}
+	statedb.Finalise(true)
+
+	config := *params.AllEthashProtocolChanges
+	config.ChainID = big.NewInt(opts.ChainID)
+
+	return &Bundle{
+		opts:    opts,
+		config:  &config,
+		statedb: statedb,
+	}, nil
+}
+
+// Apply executes the step on top of the bundle state. State changes of failed steps are reverted.
+func (b *Bundle) Apply(step Step) StepResult {
+	res := StepResult{Name: step.Name}
+
+	gasLimit := step.GasLimit
+	if gasLimit == 0 {
+		gasLimit = b.opts.GasLimit
+	}
+	value := step.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+
+	nonce := b.statedb.GetNonce(step.From)
+	if step.To == nil {
+		res.ContractAddress = crypto.CreateAddress(step.From, nonce)
+	}
+
+	txHash := pc.Keccak256Hash(big.NewInt(int64(b.steps)).Bytes(), step.From.Bytes(), step.Data)
+	b.statedb.Prepare(txHash, common.Hash{}, b.steps)
+	b.steps++
+
+	msg := types.NewMessage(step.From, step.To, nonce, value, gasLimit, new(big.Int), step.Data, false)
+	evm := vm.NewEVM(b.context(step.From), b.statedb, b.config, vm.Config{})
+	result, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(gasLimit))
+	if err != nil {
+		res.Err = err
+		res.ContractAddress = common.Address{}
+		return res
+	}
+	b.statedb.Finalise(true)
+
+	res.GasUsed = result.UsedGas
+	res.ReturnData = result.ReturnData
+	res.Err = result.Err
+	res.Success = result.Err == nil
+	if !res.Success {
+		res.ContractAddress = common.Address{}
+		if reason, err := abi.UnpackRevert(result.Revert()); err == nil {
+			res.RevertReason = reason
+		}
+		return res
+	}
+
+	res.Logs = b.statedb.GetLogs(txHash)
+	return res
+}
+
+// Call executes a read only call against the bundle state.
+func (b *Bundle) Call(from, to common.Address, data []byte) ([]byte, error) {
+	snapshot := b.statedb.Snapshot()
+	defer b.statedb.RevertToSnapshot(snapshot)
+
+	msg := types.NewMessage(from, &to, 0, new(big.Int), b.opts.GasLimit, new(big.Int), data, false)
+	evm := vm.NewEVM(b.context(from), b.statedb, b.config, vm.Config{})
+	result, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(b.opts.GasLimit))
+	if err != nil {
+		return nil, err
+	}
+	if result.Err != nil {
+		return result.Revert(), result.Err
+	}
+	return result.ReturnData, nil
+}
+
+func (b *Bundle) context(origin common.Address) vm.Context {
+	return vm.Context{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		GetHash:     func(uint64) common.Hash { return common.Hash{} },
+		Origin:      origin,
+		BlockNumber: new(big.Int).SetUint64(b.opts.BlockNumber),
+		Time:        new(big.Int).SetUint64(b.opts.Time),
+		Difficulty:  new(big.Int),
+		GasLimit:    b.opts.GasLimit,
+		GasPrice:    new(big.Int),
+	}
+}
+
+// Simulate applies the steps in order and stops at the first failed step.
+// The results of the executed steps are returned, and ErrStepFailed if one of them failed.
+func Simulate(alloc core.GenesisAlloc, opts Opts, steps ...Step) ([]StepResult, error) {
+	b, err := NewBundle(alloc, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]StepResult, 0, len(steps))
+	for i, step := range steps {
+		r := b.Apply(step)
+		res = append(res, r)
+		if !r.Success {
+			return res, fmt.Errorf("%w: step %v %q: %v", ErrStepFailed, i, step.Name, describeFailure(r))
+		}
+	}
+	return res, nil
+}
+
+func describeFailure(r StepResult) string {
+	if r.RevertReason != "" {
+		return r.RevertReason
+	}
+	return r.Err.Error()
+}
+
+// StateReader reads the state of a live chain. The go-ethereum ethclient can be used.
+type StateReader interface {
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+	StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error)
+}
+
+// Fork copies the balance, nonce and code of the given accounts, together with the listed storage slots,
+// from the live chain at the given block. A nil block forks the latest state.
+// Storage is not enumerable over RPC, so every slot the bundle reads has to be listed.
+func Fork(ctx context.Context, reader StateReader, block *big.Int, accounts map[common.Address][]common.Hash) (core.GenesisAlloc, error) {
+	alloc := make(core.GenesisAlloc, len(accounts))
+	for address, slots := range accounts {
+		var (
+			account core.GenesisAccount
+			err     error
+		)
+
+		account.Balance, err = reader.BalanceAt(ctx, address, block)
+		if err != nil {
+			return nil, fmt.Errorf("could not get balance of %v: %w", address.Hex(), err)
+		}
+		account.Nonce, err = reader.NonceAt(ctx, address, block)
+		if err != nil {
+			return nil, fmt.Errorf("could not get nonce of %v: %w", address.Hex(), err)
+		}
+		account.Code, err = reader.CodeAt(ctx, address, block)
+		if err != nil {
+			return nil, fmt.Errorf("could not get code of %v: %w", address.Hex(), err)
+		}
+
+		if len(slots) > 0 {
+			account.Storage = make(map[common.Hash]common.Hash, len(slots))
+		}
+		for _, slot := range slots {
+			value, err := reader.StorageAt(ctx, address, slot, block)
+			if err != nil {
+				return nil, fmt.Errorf("could not get storage %v of %v: %w", slot.Hex(), address.Hex(), err)
+			}
+			account.Storage[slot] = common.BytesToHash(value)
+		}
+
+		alloc[address] = account
+	}
+	return alloc, nil
+}
@@ -0,0 +1,110 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package simulate
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/mysteriumnetwork/payments/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func pack(t *testing.T, parsed abi.ABI, method string, args ...interface{}) []byte {
+	data, err := parsed.Pack(method, args...)
+	assert.NoError(t, err)
+	return data
+}
+
+func TestSimulateDependentSteps(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(bindings.MystTokenABI))
+	assert.NoError(t, err)
+
+	deployer := common.HexToAddress("0x1")
+	user := common.HexToAddress("0x2")
+	spender := common.HexToAddress("0x3")
+	oldMyst := crypto.CreateAddress(deployer, 0)
+	myst := crypto.CreateAddress(deployer, 1)
+	amount := big.NewInt(1000)
+
+	args, err := parsed.Pack("", oldMyst)
+	assert.NoError(t, err)
+
+	steps := []Step{
+		{Name: "deploy old myst", From: deployer, Data: common.FromHex(bindings.OldMystTokenBin)},
+		{Name: "deploy myst", From: deployer, Data: append(common.FromHex(bindings.MystTokenBin), args...)},
+		{Name: "mint", From: deployer, To: &myst, Data: pack(t, parsed, "mint", user, amount)},
+		{Name: "approve", From: user, To: &myst, Data: pack(t, parsed, "approve", spender, amount)},
+		{Name: "transfer", From: spender, To: &myst, Data: pack(t, parsed, "transferFrom", user, spender, amount)},
+	}
+
+	res, err := Simulate(nil, Opts{ChainID: 5, BlockNumber: 100, Time: 1600000000}, steps...)
+	assert.NoError(t, err)
+	assert.Len(t, res, 5)
+	assert.Equal(t, myst, res[1].ContractAddress)
+	for _, r := range res {
+		assert.True(t, r.Success, r.Name)
+		assert.NotZero(t, r.GasUsed)
+	}
+	assert.Len(t, res[4].Logs, 2)
+
+	steps = append(steps, Step{Name: "transfer again", From: spender, To: &myst, Data: pack(t, parsed, "transferFrom", user, spender, amount)})
+	res, err = Simulate(nil, Opts{ChainID: 5}, steps...)
+	assert.True(t, errors.Is(err, ErrStepFailed))
+	assert.Len(t, res, 6)
+	assert.False(t, res[5].Success)
+	assert.Equal(t, "MYST: transfer amount exceeds allowance", res[5].RevertReason)
+}
+
+func TestFork(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	opts := bind.NewKeyedTransactor(key)
+	backend := testutil.NewSimulatedBackend(core.GenesisAlloc{opts.From: {Balance: big.NewInt(0).Exp(big.NewInt(10), big.NewInt(20), nil)}}, 10000000)
+	defer backend.Close()
+
+	oldMyst, _, _, err := bindings.DeployOldMystToken(opts, backend)
+	assert.NoError(t, err)
+	mystAddress, _, myst, err := bindings.DeployMystToken(opts, backend, oldMyst)
+	assert.NoError(t, err)
+	_, err = myst.Mint(opts, opts.From, big.NewInt(500))
+	assert.NoError(t, err)
+
+	var slots []common.Hash
+	for i := int64(0); i < 10; i++ {
+		slots = append(slots, common.BigToHash(big.NewInt(i)))
+	}
+	alloc, err := Fork(context.Background(), backend, nil, map[common.Address][]common.Hash{mystAddress: slots, oldMyst: nil})
+	assert.NoError(t, err)
+
+	b, err := NewBundle(alloc, Opts{ChainID: 1337})
+	assert.NoError(t, err)
+
+	parsed, err := abi.JSON(strings.NewReader(bindings.MystTokenABI))
+	assert.NoError(t, err)
+	out, err := b.Call(opts.From, mystAddress, pack(t, parsed, "totalSupply"))
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(500), new(big.Int).SetBytes(out))
+}
@@ -0,0 +1,177 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package spendcap guards consumers from signing promises beyond a configured spend cap per hermes.
+package spendcap
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/crypto"
+)
+
+// ErrCapReached is returned when signing a promise would exceed the spend cap.
+// The channel stays stopped until the cap is raised.
+var ErrCapReached = errors.New("promise exceeds the spend cap")
+
+// HashSigner signs hashes, the keystore can be used.
+type HashSigner interface {
+	SignHash(a accounts.Account, hash []byte) ([]byte, error)
+}
+
+// Spend is the spending state of a consumer with a single hermes.
+type Spend struct {
+	ChainID  int64
+	Consumer common.Address
+	HermesID common.Address
+	// Promised is the cumulative amount of the latest signed promise.
+	Promised *big.Int
+	// Cap overrides the default cap if set.
+	Cap *big.Int
+	// Stopped is set once a promise was refused, and cleared when the cap is raised.
+	Stopped bool
+}
+
+// Storage persists the spending state.
+type Storage interface {
+	UpsertSpend(s Spend) error
+	GetSpends() ([]Spend, error)
+}
+
+type spendKey struct {
+	chainID  int64
+	consumer common.Address
+	hermesID common.Address
+}
+
+// Guard tracks the promised amounts of the consumers and refuses to sign promises beyond the spend cap.
+// Once a promise is refused the consumer channel is stopped, so that a runaway session cannot carry on
+// with smaller increments, until the cap is raised explicitly.
+type Guard struct {
+	storage    Storage
+	defaultCap *big.Int
+
+	lock   sync.Mutex
+	spends map[spendKey]Spend
+}
+
+// NewGuard returns a new guard loaded from the storage. A nil default cap leaves consumers without an explicit cap unlimited.
+func NewGuard(storage Storage, defaultCap *big.Int) (*Guard, error) {
+	g := &Guard{
+		storage:    storage,
+		defaultCap: defaultCap,
+		spends:     make(map[spendKey]Spend),
+	}
+
+	spends, err := storage.GetSpends()
+	if err != nil {
+		return nil, fmt.Errorf("could not load spends: %w", err)
+	}
+	for _, s := range spends {
+		g.spends[keyOf(s)] = s
+	}
+	return g, nil
+}
+
+// Reserve records the cumulative promise amount about to be signed.
+// ErrCapReached is returned, and the channel is stopped, if the amount exceeds the cap.
+// Promises not increasing the promised amount are allowed unless the channel is stopped.
+func (g *Guard) Reserve(chainID int64, consumer, hermesID common.Address, amount *big.Int) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	current := g.get(chainID, consumer, hermesID)
+	if current.Stopped {
+		return fmt.Errorf("%w: spending with hermes %v is stopped", ErrCapReached, hermesID.Hex())
+	}
+	if amount.Cmp(current.Promised) <= 0 {
+		return nil
+	}
+
+	next := current
+	if limit := g.capOf(current); limit != nil && amount.Cmp(limit) > 0 {
+		next.Stopped = true
+		if err := g.store(next); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %v would exceed the cap of %v with hermes %v", ErrCapReached, amount, limit, hermesID.Hex())
+	}
+
+	next.Promised = new(big.Int).Set(amount)
+	return g.store(next)
+}
+
+// CreatePromise reserves the promise amount and signs the promise if the cap allows it.
+func (g *Guard) CreatePromise(hermesID common.Address, channelID string, chainID int64, amount, fee *big.Int, hashlock string, ks HashSigner, signer common.Address) (*crypto.Promise, error) {
+	if err := g.Reserve(chainID, signer, hermesID, amount); err != nil {
+		return nil, err
+	}
+	return crypto.CreatePromise(channelID, chainID, amount, fee, hashlock, ks, signer)
+}
+
+// Raise sets the cap of the consumer with the hermes and resumes a stopped channel.
+func (g *Guard) Raise(chainID int64, consumer, hermesID common.Address, limit *big.Int) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	next := g.get(chainID, consumer, hermesID)
+	next.Cap = new(big.Int).Set(limit)
+	next.Stopped = false
+	return g.store(next)
+}
+
+// Get returns the spending state of the consumer with the hermes.
+func (g *Guard) Get(chainID int64, consumer, hermesID common.Address) Spend {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	s := g.get(chainID, consumer, hermesID)
+	if s.Cap == nil && g.defaultCap != nil {
+		s.Cap = new(big.Int).Set(g.defaultCap)
+	}
+	return s
+}
+
+func (g *Guard) capOf(s Spend) *big.Int {
+	if s.Cap != nil {
+		return s.Cap
+	}
+	return g.defaultCap
+}
+
+func (g *Guard) store(s Spend) error {
+	if err := g.storage.UpsertSpend(s); err != nil {
+		return fmt.Errorf("could not store spend: %w", err)
+	}
+	g.spends[keyOf(s)] = s
+	return nil
+}
+
+func (g *Guard) get(chainID int64, consumer, hermesID common.Address) Spend {
+	if s, ok := g.spends[spendKey{chainID: chainID, consumer: consumer, hermesID: hermesID}]; ok {
+		return s
+	}
+	return Spend{ChainID: chainID, Consumer: consumer, HermesID: hermesID, Promised: new(big.Int)}
+}
+
+func keyOf(s Spend) spendKey {
+	return spendKey{chainID: s.ChainID, consumer: s.Consumer, hermesID: s.HermesID}
+}
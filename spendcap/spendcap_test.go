@@ -0,0 +1,79 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package spendcap
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type memStorage struct {
+	spends map[spendKey]Spend
+}
+
+func (ms *memStorage) UpsertSpend(s Spend) error {
+	ms.spends[keyOf(s)] = s
+	return nil
+}
+
+func (ms *memStorage) GetSpends() ([]Spend, error) {
+	var res []Spend
+	for _, s := range ms.spends {
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+func TestGuard(t *testing.T) {
+	storage := &memStorage{spends: make(map[spendKey]Spend)}
+	consumer := common.HexToAddress("0x1")
+	hermesID := common.HexToAddress("0x2")
+	otherHermes := common.HexToAddress("0x3")
+
+	g, err := NewGuard(storage, big.NewInt(100))
+	assert.NoError(t, err)
+
+	assert.NoError(t, g.Reserve(1, consumer, hermesID, big.NewInt(60)))
+	assert.NoError(t, g.Reserve(1, consumer, hermesID, big.NewInt(100)))
+
+	err = g.Reserve(1, consumer, hermesID, big.NewInt(101))
+	assert.True(t, errors.Is(err, ErrCapReached))
+	assert.Equal(t, big.NewInt(100), g.Get(1, consumer, hermesID).Promised)
+
+	// the hard stop refuses even promises within the cap
+	err = g.Reserve(1, consumer, hermesID, big.NewInt(100))
+	assert.True(t, errors.Is(err, ErrCapReached))
+
+	// other hermeses are not affected
+	assert.NoError(t, g.Reserve(1, consumer, otherHermes, big.NewInt(50)))
+
+	// the stop survives restarts
+	g, err = NewGuard(storage, big.NewInt(100))
+	assert.NoError(t, err)
+	assert.True(t, g.Get(1, consumer, hermesID).Stopped)
+
+	assert.NoError(t, g.Raise(1, consumer, hermesID, big.NewInt(200)))
+	assert.NoError(t, g.Reserve(1, consumer, hermesID, big.NewInt(150)))
+	s := g.Get(1, consumer, hermesID)
+	assert.False(t, s.Stopped)
+	assert.Equal(t, big.NewInt(200), s.Cap)
+	assert.Equal(t, big.NewInt(150), s.Promised)
+}
@@ -0,0 +1,80 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/addressbook"
+)
+
+const addressBookMigrationSet = "address_book"
+
+// AddressBookStore is a SQL backed address book storage.
+type AddressBookStore struct {
+	db *sql.DB
+}
+
+// NewAddressBookStore returns a new instance of address book store.
+func NewAddressBookStore(db *sql.DB, migrate bool) (*AddressBookStore, error) {
+	if migrate {
+		if err := Migrate(db, addressBookMigrationSet, AddressBookMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &AddressBookStore{db: db}, nil
+}
+
+// UpsertAddressBookEntry inserts a new entry or updates the address of the existing entry with the same label.
+func (as *AddressBookStore) UpsertAddressBookEntry(e addressbook.Entry) error {
+	_, err := as.db.Exec(
+		`INSERT INTO address_book_entries (label, address) VALUES ($1, $2)
+		ON CONFLICT (label) DO UPDATE SET address = EXCLUDED.address`,
+		e.Label, e.Address.Hex(),
+	)
+	return err
+}
+
+// DeleteAddressBookEntry deletes the entry with the given label.
+func (as *AddressBookStore) DeleteAddressBookEntry(label string) error {
+	_, err := as.db.Exec(`DELETE FROM address_book_entries WHERE label = $1`, label)
+	return err
+}
+
+// GetAddressBookEntries returns all the entries ordered by label.
+func (as *AddressBookStore) GetAddressBookEntries() ([]addressbook.Entry, error) {
+	rows, err := as.db.Query(`SELECT label, address FROM address_book_entries ORDER BY label`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []addressbook.Entry
+	for rows.Next() {
+		var e addressbook.Entry
+		var address string
+		if err := rows.Scan(&e.Label, &address); err != nil {
+			return nil, err
+		}
+		e.Address = common.HexToAddress(address)
+		res = append(res, e)
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,54 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/addressbook"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressBookStore(t *testing.T) {
+	db, fdb := newFakeDB(t)
+	store, err := NewAddressBookStore(db, true)
+	assert.NoError(t, err)
+	assert.Len(t, fdb.migrations[addressBookMigrationSet], len(AddressBookMigrations))
+
+	ab := addressbook.NewAddressBook(store)
+	_, err = ab.Add("hermes", "0x0000000000000000000000000000000000000001")
+	assert.NoError(t, err)
+	_, err = ab.Add("beneficiary", "0x0000000000000000000000000000000000000002")
+	assert.NoError(t, err)
+	_, err = ab.Add("hermes", "0x0000000000000000000000000000000000000003")
+	assert.NoError(t, err)
+
+	entries, err := ab.Entries()
+	assert.NoError(t, err)
+	assert.Equal(t, []addressbook.Entry{
+		{Label: "beneficiary", Address: common.HexToAddress("0x2")},
+		{Label: "hermes", Address: common.HexToAddress("0x3")},
+	}, entries)
+
+	assert.NoError(t, ab.Remove("beneficiary"))
+	_, err = ab.Resolve("beneficiary")
+	assert.Equal(t, addressbook.ErrLabelNotFound, err)
+	addr, err := ab.Resolve("hermes")
+	assert.NoError(t, err)
+	assert.Equal(t, common.HexToAddress("0x3"), addr)
+}
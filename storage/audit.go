@@ -0,0 +1,104 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/audit"
+)
+
+const auditMigrationSet = "audit"
+
+// AuditStore is a SQL backed audit log storage.
+// Records are only ever inserted, the sequence primary key rejects concurrent appends of the same record.
+type AuditStore struct {
+	db *sql.DB
+}
+
+// NewAuditStore returns a new instance of audit store.
+func NewAuditStore(db *sql.DB, migrate bool) (*AuditStore, error) {
+	if migrate {
+		if err := Migrate(db, auditMigrationSet, AuditMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &AuditStore{db: db}, nil
+}
+
+// AppendAuditRecord stores a new record.
+func (as *AuditStore) AppendAuditRecord(r audit.Record) error {
+	params, err := json.Marshal(r.Params)
+	if err != nil {
+		return err
+	}
+
+	_, err = as.db.Exec(
+		`INSERT INTO audit_records (sequence, created_at, actor, action, tx_hash, params, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		r.Sequence, r.Time, r.Actor.Hex(), string(r.Action), r.TxHash.Hex(), string(params), r.PrevHash.Hex(), r.Hash.Hex(),
+	)
+	return err
+}
+
+// GetLastAuditRecord returns the record with the highest sequence, or nil if the log is empty.
+func (as *AuditStore) GetLastAuditRecord() (*audit.Record, error) {
+	records, err := as.query(`SELECT sequence, created_at, actor, action, tx_hash, params, prev_hash, hash
+		FROM audit_records ORDER BY sequence DESC LIMIT 1`)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return &records[0], nil
+}
+
+// GetAuditRecords returns up to limit records starting from the given sequence, ordered by sequence.
+func (as *AuditStore) GetAuditRecords(fromSequence uint64, limit int) ([]audit.Record, error) {
+	return as.query(`SELECT sequence, created_at, actor, action, tx_hash, params, prev_hash, hash
+		FROM audit_records WHERE sequence >= $1 ORDER BY sequence LIMIT $2`, fromSequence, limit)
+}
+
+func (as *AuditStore) query(query string, args ...interface{}) ([]audit.Record, error) {
+	rows, err := as.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []audit.Record
+	for rows.Next() {
+		var r audit.Record
+		var actor, action, txHash, params, prevHash, hash string
+		if err := rows.Scan(&r.Sequence, &r.Time, &actor, &action, &txHash, &params, &prevHash, &hash); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(params), &r.Params); err != nil {
+			return nil, err
+		}
+		r.Time = r.Time.UTC()
+		r.Actor = common.HexToAddress(actor)
+		r.Action = audit.Action(action)
+		r.TxHash = common.HexToHash(txHash)
+		r.PrevHash = common.HexToHash(prevHash)
+		r.Hash = common.HexToHash(hash)
+		res = append(res, r)
+	}
+
+	return res, rows.Err()
+}
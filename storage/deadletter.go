@@ -0,0 +1,114 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math/big"
+
+	"github.com/mysteriumnetwork/payments/settlement"
+)
+
+const deadLetterMigrationSet = "dead_letter"
+
+// DeadLetterStore is a SQL backed settlement dead letter storage.
+type DeadLetterStore struct {
+	db *sql.DB
+}
+
+// NewDeadLetterStore returns a new instance of dead letter store.
+func NewDeadLetterStore(db *sql.DB, migrate bool) (*DeadLetterStore, error) {
+	if migrate {
+		if err := Migrate(db, deadLetterMigrationSet, DeadLetterMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &DeadLetterStore{db: db}, nil
+}
+
+// UpsertDeadLetter inserts a new dead letter or updates the existing one.
+func (ds *DeadLetterStore) UpsertDeadLetter(dl settlement.DeadLetter) error {
+	errs, err := json.Marshal(dl.Errors)
+	if err != nil {
+		return err
+	}
+
+	gasPrice := ""
+	if dl.GasPrice != nil {
+		gasPrice = dl.GasPrice.String()
+	}
+
+	_, err = ds.db.Exec(
+		`INSERT INTO dead_letters (id, kind, request, gas_price, gas_limit, errors, attempts, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (id) DO UPDATE SET gas_price = EXCLUDED.gas_price, gas_limit = EXCLUDED.gas_limit,
+		errors = EXCLUDED.errors, attempts = EXCLUDED.attempts, updated_at = EXCLUDED.updated_at`,
+		dl.ID, dl.Kind, string(dl.Request), gasPrice, dl.GasLimit, string(errs), dl.Attempts, dl.CreatedAt, dl.UpdatedAt,
+	)
+	return err
+}
+
+// DeleteDeadLetter deletes the dead letter.
+func (ds *DeadLetterStore) DeleteDeadLetter(id string) error {
+	_, err := ds.db.Exec(`DELETE FROM dead_letters WHERE id = $1`, id)
+	return err
+}
+
+// GetDeadLetter returns the dead letter or nil if it does not exist.
+func (ds *DeadLetterStore) GetDeadLetter(id string) (*settlement.DeadLetter, error) {
+	res, err := ds.query(`SELECT id, kind, request, gas_price, gas_limit, errors, attempts, created_at, updated_at
+		FROM dead_letters WHERE id = $1`, id)
+	if err != nil || len(res) == 0 {
+		return nil, err
+	}
+	return &res[0], nil
+}
+
+// GetDeadLetters returns all the dead letters, oldest first.
+func (ds *DeadLetterStore) GetDeadLetters() ([]settlement.DeadLetter, error) {
+	return ds.query(`SELECT id, kind, request, gas_price, gas_limit, errors, attempts, created_at, updated_at
+		FROM dead_letters ORDER BY created_at`)
+}
+
+func (ds *DeadLetterStore) query(query string, args ...interface{}) ([]settlement.DeadLetter, error) {
+	rows, err := ds.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []settlement.DeadLetter
+	for rows.Next() {
+		var dl settlement.DeadLetter
+		var request, gasPrice, errs string
+		if err := rows.Scan(&dl.ID, &dl.Kind, &request, &gasPrice, &dl.GasLimit, &errs, &dl.Attempts, &dl.CreatedAt, &dl.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(errs), &dl.Errors); err != nil {
+			return nil, err
+		}
+		if gasPrice != "" {
+			dl.GasPrice, _ = new(big.Int).SetString(gasPrice, 10)
+		}
+		dl.Request = json.RawMessage(request)
+		res = append(res, dl)
+	}
+
+	return res, rows.Err()
+}
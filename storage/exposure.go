@@ -0,0 +1,80 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/exposure"
+)
+
+const exposureMigrationSet = "exposure"
+
+// ExposureStore is a SQL backed unsettled exposure storage.
+type ExposureStore struct {
+	db *sql.DB
+}
+
+// NewExposureStore returns a new instance of exposure store.
+func NewExposureStore(db *sql.DB, migrate bool) (*ExposureStore, error) {
+	if migrate {
+		if err := Migrate(db, exposureMigrationSet, ExposureMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ExposureStore{db: db}, nil
+}
+
+// UpsertExposure inserts or updates the exposure of the provider channel.
+func (es *ExposureStore) UpsertExposure(e exposure.Exposure) error {
+	_, err := es.db.Exec(
+		`INSERT INTO exposures (hermes_id, provider, promised, settled)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (hermes_id, provider) DO UPDATE SET promised = EXCLUDED.promised, settled = EXCLUDED.settled`,
+		e.HermesID.Hex(), e.Provider.Hex(), e.Promised.String(), e.Settled.String(),
+	)
+	return err
+}
+
+// GetExposures returns the exposures of all provider channels.
+func (es *ExposureStore) GetExposures() ([]exposure.Exposure, error) {
+	rows, err := es.db.Query(`SELECT hermes_id, provider, promised, settled FROM exposures`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []exposure.Exposure
+	for rows.Next() {
+		var hermesID, provider, promised, settled string
+		if err := rows.Scan(&hermesID, &provider, &promised, &settled); err != nil {
+			return nil, err
+		}
+		e := exposure.Exposure{
+			HermesID: common.HexToAddress(hermesID),
+			Provider: common.HexToAddress(provider),
+		}
+		e.Promised, _ = new(big.Int).SetString(promised, 10)
+		e.Settled, _ = new(big.Int).SetString(settled, 10)
+		res = append(res, e)
+	}
+
+	return res, rows.Err()
+}
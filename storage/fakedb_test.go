@@ -0,0 +1,316 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeDriver is an in memory database/sql driver that understands the statements
+// of the migrations, the indexer store and the incrementor store.
+type fakeDriver struct {
+	mu  sync.Mutex
+	dbs map[string]*fakeDB
+}
+
+var fakeDrv = &fakeDriver{dbs: make(map[string]*fakeDB)}
+
+var fakeDBCounter int64
+
+func init() {
+	sql.Register("fakedb", fakeDrv)
+}
+
+type fakeDB struct {
+	mu sync.Mutex
+	// advisory is the pg_advisory_lock of the database.
+	advisory sync.Mutex
+	locks    int
+	unlocks  int
+
+	migrations map[string]map[int64]string
+	// executed holds the statements of the applied migrations.
+	executed []string
+
+	logs        [][]driver.Value
+	checkpoints map[int64]string
+	txs         map[string][]driver.Value
+	labels      map[string]string
+}
+
+func newFakeDB(t *testing.T) (*sql.DB, *fakeDB) {
+	dsn := fmt.Sprintf("db%d", atomic.AddInt64(&fakeDBCounter, 1))
+	fdb := &fakeDB{
+		migrations:  make(map[string]map[int64]string),
+		checkpoints: make(map[int64]string),
+		txs:         make(map[string][]driver.Value),
+		labels:      make(map[string]string),
+	}
+	fakeDrv.mu.Lock()
+	fakeDrv.dbs[dsn] = fdb
+	fakeDrv.mu.Unlock()
+
+	db, err := sql.Open("fakedb", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, fdb
+}
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	db, ok := d.dbs[dsn]
+	if !ok {
+		return nil, fmt.Errorf("unknown database %v", dsn)
+	}
+	return &fakeConn{db: db}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	q := s.query
+	db := s.db
+
+	// the advisory lock blocks, so it is taken outside of the database mutex.
+	switch {
+	case strings.Contains(q, "pg_advisory_lock"):
+		db.advisory.Lock()
+		db.mu.Lock()
+		db.locks++
+		db.mu.Unlock()
+		return driver.RowsAffected(0), nil
+	case strings.Contains(q, "pg_advisory_unlock"):
+		db.mu.Lock()
+		db.unlocks++
+		db.mu.Unlock()
+		db.advisory.Unlock()
+		return driver.RowsAffected(0), nil
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	switch {
+	case strings.Contains(q, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+	case strings.HasPrefix(q, "INSERT INTO schema_migrations"):
+		set := args[0].(string)
+		if db.migrations[set] == nil {
+			db.migrations[set] = make(map[int64]string)
+		}
+		if _, ok := db.migrations[set][args[1].(int64)]; ok {
+			return nil, errors.New("duplicate key value violates unique constraint")
+		}
+		db.migrations[set][args[1].(int64)] = args[2].(string)
+	case strings.HasPrefix(q, "INSERT INTO indexer_logs"):
+		for _, l := range db.logs {
+			if l[0] == args[0] && l[4] == args[4] {
+				return driver.RowsAffected(0), nil
+			}
+		}
+		db.logs = append(db.logs, args)
+	case strings.HasPrefix(q, "INSERT INTO indexer_checkpoints"):
+		db.checkpoints[args[0].(int64)] = args[1].(string)
+	case strings.HasPrefix(q, "DELETE FROM indexer_checkpoints WHERE block_number NOT IN"):
+		numbers := db.checkpointNumbers()
+		for i, n := range numbers {
+			if int64(i) >= args[0].(int64) {
+				delete(db.checkpoints, n)
+			}
+		}
+	case strings.HasPrefix(q, "DELETE FROM indexer_logs"):
+		var kept [][]driver.Value
+		for _, l := range db.logs {
+			if l[0].(int64) < args[0].(int64) {
+				kept = append(kept, l)
+			}
+		}
+		db.logs = kept
+	case strings.HasPrefix(q, "DELETE FROM indexer_checkpoints"):
+		for n := range db.checkpoints {
+			if n >= args[0].(int64) {
+				delete(db.checkpoints, n)
+			}
+		}
+	case strings.HasPrefix(q, "INSERT INTO incrementor_transactions"):
+		if existing, ok := db.txs[args[0].(string)]; ok {
+			// created_at is not updated on conflict.
+			args[6] = existing[6]
+		}
+		db.txs[args[0].(string)] = args
+	case strings.HasPrefix(q, "INSERT INTO address_book_entries"):
+		db.labels[args[0].(string)] = args[1].(string)
+	case strings.HasPrefix(q, "DELETE FROM address_book_entries"):
+		delete(db.labels, args[0].(string))
+	default:
+		db.executed = append(db.executed, q)
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := s.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	q := s.query
+	switch {
+	case strings.HasPrefix(q, "SELECT COUNT(*) FROM schema_migrations"):
+		_, ok := db.migrations[args[0].(string)][args[1].(int64)]
+		count := int64(0)
+		if ok {
+			count = 1
+		}
+		return &fakeRows{cols: []string{"count"}, rows: [][]driver.Value{{count}}}, nil
+	case strings.Contains(q, "FROM indexer_checkpoints"):
+		res := &fakeRows{cols: []string{"block_number", "block_hash"}}
+		for _, n := range db.checkpointNumbers() {
+			res.rows = append(res.rows, []driver.Value{n, db.checkpoints[n]})
+		}
+		return res, nil
+	case strings.Contains(q, "FROM indexer_logs"):
+		return db.filterLogs(q, args), nil
+	case strings.Contains(q, "FROM incrementor_transactions"):
+		res := &fakeRows{cols: make([]string, 8)}
+		var ids []string
+		for id := range db.txs {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			tx := db.txs[id]
+			if tx[2] == args[0] || tx[2] == args[1] || tx[2] == args[2] {
+				continue
+			}
+			res.rows = append(res.rows, tx)
+		}
+		return res, nil
+	case strings.Contains(q, "FROM address_book_entries"):
+		res := &fakeRows{cols: []string{"label", "address"}}
+		var labels []string
+		for label := range db.labels {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			res.rows = append(res.rows, []driver.Value{label, db.labels[label]})
+		}
+		return res, nil
+	}
+
+	return nil, fmt.Errorf("unexpected query: %v", q)
+}
+
+// checkpointNumbers returns the stored checkpoint numbers, latest first.
+func (db *fakeDB) checkpointNumbers() []int64 {
+	var res []int64
+	for n := range db.checkpoints {
+		res = append(res, n)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] > res[j] })
+	return res
+}
+
+func (db *fakeDB) filterLogs(q string, args []driver.Value) *fakeRows {
+	from := args[0].(int64)
+	rest := args[1:]
+	to := int64(-1)
+	if strings.Contains(q, "block_number <=") {
+		to = rest[0].(int64)
+		rest = rest[1:]
+	}
+
+	res := &fakeRows{cols: make([]string, 8)}
+	for _, l := range db.logs {
+		n := l[0].(int64)
+		if n < from || (to >= 0 && n > to) {
+			continue
+		}
+		if len(rest) > 0 && !containsValue(rest, l[5]) {
+			continue
+		}
+		res.rows = append(res.rows, l)
+	}
+	sort.Slice(res.rows, func(i, j int) bool {
+		if res.rows[i][0].(int64) != res.rows[j][0].(int64) {
+			return res.rows[i][0].(int64) < res.rows[j][0].(int64)
+		}
+		return res.rows[i][4].(int64) < res.rows[j][4].(int64)
+	})
+	return res
+}
+
+func containsValue(values []driver.Value, v driver.Value) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+	pos  int
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
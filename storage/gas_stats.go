@@ -0,0 +1,92 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"math/big"
+	"time"
+
+	"github.com/mysteriumnetwork/payments/fees"
+)
+
+const gasStatsMigrationSet = "gas_stats"
+
+// GasStatsStore is a SQL backed gas sample storage.
+type GasStatsStore struct {
+	db *sql.DB
+}
+
+// NewGasStatsStore returns a new instance of gas statistics store.
+func NewGasStatsStore(db *sql.DB, migrate bool) (*GasStatsStore, error) {
+	if migrate {
+		if err := Migrate(db, gasStatsMigrationSet, GasStatsMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &GasStatsStore{db: db}, nil
+}
+
+// InsertGasSamples stores the samples in a single transaction. Samples of blocks that are already stored are replaced.
+func (gs *GasStatsStore) InsertGasSamples(samples []fees.GasSample) error {
+	tx, err := gs.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, s := range samples {
+		_, err := tx.Exec(
+			`INSERT INTO gas_samples (chain_id, block_number, block_time, base_fee, priority_fee)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (chain_id, block_number) DO UPDATE SET block_time = EXCLUDED.block_time, base_fee = EXCLUDED.base_fee, priority_fee = EXCLUDED.priority_fee`,
+			s.ChainID, s.BlockNumber, s.Time.UTC(), s.BaseFee.String(), s.PriorityFee.String(),
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetGasSamples returns the samples of the chain in the [from, to) time window, oldest first.
+func (gs *GasStatsStore) GetGasSamples(chainID int64, from, to time.Time) ([]fees.GasSample, error) {
+	rows, err := gs.db.Query(
+		`SELECT chain_id, block_number, block_time, base_fee, priority_fee
+		FROM gas_samples WHERE chain_id = $1 AND block_time >= $2 AND block_time < $3
+		ORDER BY block_number`,
+		chainID, from.UTC(), to.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []fees.GasSample
+	for rows.Next() {
+		var s fees.GasSample
+		var baseFee, priorityFee string
+		if err := rows.Scan(&s.ChainID, &s.BlockNumber, &s.Time, &baseFee, &priorityFee); err != nil {
+			return nil, err
+		}
+		s.BaseFee, _ = new(big.Int).SetString(baseFee, 10)
+		s.PriorityFee, _ = new(big.Int).SetString(priorityFee, 10)
+		res = append(res, s)
+	}
+
+	return res, rows.Err()
+}
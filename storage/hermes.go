@@ -0,0 +1,155 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/crypto"
+	"github.com/mysteriumnetwork/payments/hermes"
+)
+
+const hermesMigrationSet = "hermes"
+
+// HermesStore is a SQL backed storage of the promises issued by a hermes.
+type HermesStore struct {
+	db *sql.DB
+}
+
+// NewHermesStore returns a new instance of hermes store.
+func NewHermesStore(db *sql.DB, migrate bool) (*HermesStore, error) {
+	if migrate {
+		if err := Migrate(db, hermesMigrationSet, HermesMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &HermesStore{db: db}, nil
+}
+
+// InsertIssuance stores the issued promise along with the consumer promise it was exchanged for.
+func (hs *HermesStore) InsertIssuance(i hermes.Issuance) error {
+	consumerPromise, err := json.Marshal(i.ConsumerPromise)
+	if err != nil {
+		return err
+	}
+	providerPromise, err := json.Marshal(i.ProviderPromise)
+	if err != nil {
+		return err
+	}
+
+	_, err = hs.db.Exec(
+		`INSERT INTO hermes_issuances (chain_id, consumer, consumer_channel, provider, agreement_id, agreement_total,
+		hashlock, consumer_promise, provider_promise, r, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		i.ChainID, i.Consumer.Hex(), common.BytesToAddress(i.ConsumerPromise.ChannelID).Hex(), i.Provider.Hex(),
+		i.AgreementID.String(), i.AgreementTotal.String(), hexBytes(i.ConsumerPromise.Hashlock),
+		string(consumerPromise), string(providerPromise), hexBytes(i.ConsumerPromise.R), i.CreatedAt,
+	)
+	return err
+}
+
+// GetLatestConsumerPromise returns the latest promise of the consumer channel or nil if there is none.
+func (hs *HermesStore) GetLatestConsumerPromise(chainID int64, channel common.Address) (*crypto.Promise, error) {
+	res, err := hs.query(`WHERE chain_id = $1 AND consumer_channel = $2 ORDER BY id DESC LIMIT 1`, chainID, channel.Hex())
+	if err != nil || len(res) == 0 {
+		return nil, err
+	}
+	return &res[0].ConsumerPromise, nil
+}
+
+// GetLatestProviderPromise returns the latest promise issued to the provider or nil if there is none.
+func (hs *HermesStore) GetLatestProviderPromise(chainID int64, provider common.Address) (*crypto.Promise, error) {
+	res, err := hs.query(`WHERE chain_id = $1 AND provider = $2 ORDER BY id DESC LIMIT 1`, chainID, provider.Hex())
+	if err != nil || len(res) == 0 {
+		return nil, err
+	}
+	return &res[0].ProviderPromise, nil
+}
+
+// GetAgreementTotal returns the total of the last exchange of the agreement or nil if there was none.
+func (hs *HermesStore) GetAgreementTotal(chainID int64, provider common.Address, agreementID *big.Int) (*big.Int, error) {
+	res, err := hs.query(`WHERE chain_id = $1 AND provider = $2 AND agreement_id = $3 ORDER BY id DESC LIMIT 1`,
+		chainID, provider.Hex(), agreementID.String())
+	if err != nil || len(res) == 0 {
+		return nil, err
+	}
+	return res[0].AgreementTotal, nil
+}
+
+// GetIssuance returns the latest issuance with the hashlock or nil if there is none.
+func (hs *HermesStore) GetIssuance(chainID int64, hashlock []byte) (*hermes.Issuance, error) {
+	res, err := hs.query(`WHERE chain_id = $1 AND hashlock = $2 ORDER BY id DESC LIMIT 1`, chainID, hexBytes(hashlock))
+	if err != nil || len(res) == 0 {
+		return nil, err
+	}
+	return &res[0], nil
+}
+
+// UpdateR sets the revealed R of the issuances with the hashlock.
+func (hs *HermesStore) UpdateR(chainID int64, hashlock, r []byte) error {
+	_, err := hs.db.Exec(`UPDATE hermes_issuances SET r = $1 WHERE chain_id = $2 AND hashlock = $3`,
+		hexBytes(r), chainID, hexBytes(hashlock))
+	return err
+}
+
+func (hs *HermesStore) query(where string, args ...interface{}) ([]hermes.Issuance, error) {
+	rows, err := hs.db.Query(`SELECT chain_id, consumer, provider, agreement_id, agreement_total, consumer_promise, provider_promise, r, created_at
+		FROM hermes_issuances `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []hermes.Issuance
+	for rows.Next() {
+		var i hermes.Issuance
+		var consumer, provider, agreementID, agreementTotal, consumerPromise, providerPromise, r string
+		if err := rows.Scan(&i.ChainID, &consumer, &provider, &agreementID, &agreementTotal, &consumerPromise, &providerPromise, &r, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(consumerPromise), &i.ConsumerPromise); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(providerPromise), &i.ProviderPromise); err != nil {
+			return nil, err
+		}
+		i.Consumer = common.HexToAddress(consumer)
+		i.Provider = common.HexToAddress(provider)
+		i.AgreementID, _ = new(big.Int).SetString(agreementID, 10)
+		i.AgreementTotal, _ = new(big.Int).SetString(agreementTotal, 10)
+		if r != "" {
+			i.ConsumerPromise.R, _ = hex.DecodeString(r)
+			i.ProviderPromise.R = i.ConsumerPromise.R
+		}
+		res = append(res, i)
+	}
+
+	return res, rows.Err()
+}
+
+// hexBytes renders nil as an empty string.
+func hexBytes(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
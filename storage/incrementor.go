@@ -0,0 +1,95 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mysteriumnetwork/payments/fees"
+)
+
+const incrementorMigrationSet = "incrementor"
+
+// IncrementorStore is a SQL backed gas price incrementor storage.
+type IncrementorStore struct {
+	db *sql.DB
+}
+
+// NewIncrementorStore returns a new instance of incrementor store.
+func NewIncrementorStore(db *sql.DB, migrate bool) (*IncrementorStore, error) {
+	if migrate {
+		if err := Migrate(db, incrementorMigrationSet, IncrementorMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &IncrementorStore{db: db}, nil
+}
+
+// UpsertIncrementorTransaction inserts a new transaction or updates the existing one.
+func (is *IncrementorStore) UpsertIncrementorTransaction(tx fees.Transaction) error {
+	opts, err := json.Marshal(tx.Opts)
+	if err != nil {
+		return fmt.Errorf("could not marshal transaction opts: %w", err)
+	}
+
+	var createdAt sql.NullTime
+	if !tx.CreatedAt.IsZero() {
+		createdAt = sql.NullTime{Time: tx.CreatedAt, Valid: true}
+	}
+
+	_, err = is.db.Exec(
+		`INSERT INTO incrementor_transactions (unique_id, opts, state, tx_hash, chain_id, original_tx, created_at, bumps)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (unique_id) DO UPDATE SET opts = EXCLUDED.opts, state = EXCLUDED.state, original_tx = EXCLUDED.original_tx, bumps = EXCLUDED.bumps`,
+		tx.UniqueID, string(opts), string(tx.State), tx.TxHashHex, tx.ChainID, string(tx.OriginalTx), createdAt, tx.Bumps,
+	)
+	return err
+}
+
+// GetIncrementorTransactionsToCheck returns all the transactions that are not finalized yet.
+func (is *IncrementorStore) GetIncrementorTransactionsToCheck() ([]fees.Transaction, error) {
+	rows, err := is.db.Query(
+		`SELECT unique_id, opts, state, tx_hash, chain_id, original_tx, created_at, bumps FROM incrementor_transactions WHERE state NOT IN ($1, $2, $3)`,
+		string(fees.TxStateFailed), string(fees.TxStateSucceed), string(fees.TxStateReplaced),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []fees.Transaction
+	for rows.Next() {
+		var tx fees.Transaction
+		var opts, state, original string
+		var createdAt sql.NullTime
+		if err := rows.Scan(&tx.UniqueID, &opts, &state, &tx.TxHashHex, &tx.ChainID, &original, &createdAt, &tx.Bumps); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(opts), &tx.Opts); err != nil {
+			return nil, fmt.Errorf("could not unmarshal transaction opts: %w", err)
+		}
+		tx.State = fees.TransactionState(state)
+		tx.OriginalTx = []byte(original)
+		tx.CreatedAt = createdAt.Time
+		res = append(res, tx)
+	}
+
+	return res, rows.Err()
+}
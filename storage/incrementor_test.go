@@ -0,0 +1,73 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/mysteriumnetwork/payments/fees"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementorStoreRoundTrip(t *testing.T) {
+	db, fdb := newFakeDB(t)
+	store, err := NewIncrementorStore(db, true)
+	assert.NoError(t, err)
+	assert.Len(t, fdb.migrations[incrementorMigrationSet], len(IncrementorMigrations))
+
+	createdAt := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	tx := fees.Transaction{
+		UniqueID:  "0x1|1",
+		TxHashHex: "0x1",
+		ChainID:   1,
+		State:     fees.TxStateCreated,
+		Opts: fees.TransactionOpts{
+			PriceMultiplier:  1.1,
+			MaxPrice:         big.NewInt(1000),
+			Timeout:          time.Minute,
+			IncreaseInterval: time.Second,
+			CheckInterval:    time.Second,
+			RequestType:      "settle",
+		},
+		OriginalTx: []byte("tx"),
+		CreatedAt:  createdAt,
+	}
+	assert.NoError(t, store.UpsertIncrementorTransaction(tx))
+
+	done := tx
+	done.UniqueID = "0x2|1"
+	done.State = fees.TxStateSucceed
+	assert.NoError(t, store.UpsertIncrementorTransaction(done))
+
+	res, err := store.GetIncrementorTransactionsToCheck()
+	assert.NoError(t, err)
+	assert.Equal(t, []fees.Transaction{tx}, res)
+
+	bumped := tx
+	bumped.State = fees.TxStatePriceIncreased
+	bumped.OriginalTx = []byte("bumped")
+	bumped.Bumps = 1
+	bumped.CreatedAt = createdAt.Add(time.Hour)
+	assert.NoError(t, store.UpsertIncrementorTransaction(bumped))
+
+	res, err = store.GetIncrementorTransactionsToCheck()
+	assert.NoError(t, err)
+	bumped.CreatedAt = createdAt
+	assert.Equal(t, []fees.Transaction{bumped}, res)
+}
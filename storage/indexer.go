@@ -0,0 +1,222 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/indexer"
+)
+
+const indexerMigrationSet = "indexer"
+
+// IndexerStore is a SQL backed indexer storage.
+type IndexerStore struct {
+	db *sql.DB
+}
+
+// NewIndexerStore returns a new instance of indexer store.
+func NewIndexerStore(db *sql.DB, migrate bool) (*IndexerStore, error) {
+	if migrate {
+		if err := Migrate(db, indexerMigrationSet, IndexerMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &IndexerStore{db: db}, nil
+}
+
+// InsertIndexerBlocks stores the logs together with the checkpoint in a single transaction.
+func (is *IndexerStore) InsertIndexerBlocks(cp indexer.Checkpoint, logs []types.Log) error {
+	tx, err := is.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, l := range logs {
+		_, err := tx.Exec(
+			`INSERT INTO indexer_logs (block_number, block_hash, tx_hash, tx_index, log_index, address, topics, data)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (block_number, log_index) DO NOTHING`,
+			l.BlockNumber, l.BlockHash.Hex(), l.TxHash.Hex(), l.TxIndex, l.Index, l.Address.Hex(), encodeTopics(l.Topics), l.Data,
+		)
+		if err != nil {
+			return fmt.Errorf("could not insert log: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO indexer_checkpoints (block_number, block_hash) VALUES ($1, $2)
+		ON CONFLICT (block_number) DO UPDATE SET block_hash = EXCLUDED.block_hash`,
+		cp.Number, cp.Hash.Hex(),
+	)
+	if err != nil {
+		return fmt.Errorf("could not insert checkpoint: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetIndexerCheckpoints returns the stored checkpoints.
+func (is *IndexerStore) GetIndexerCheckpoints() ([]indexer.Checkpoint, error) {
+	rows, err := is.db.Query(`SELECT block_number, block_hash FROM indexer_checkpoints ORDER BY block_number DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []indexer.Checkpoint
+	for rows.Next() {
+		var cp indexer.Checkpoint
+		var hash string
+		if err := rows.Scan(&cp.Number, &hash); err != nil {
+			return nil, err
+		}
+		cp.Hash = common.HexToHash(hash)
+		res = append(res, cp)
+	}
+
+	return res, rows.Err()
+}
+
+// PruneIndexerCheckpoints removes all but the latest keep checkpoints.
+func (is *IndexerStore) PruneIndexerCheckpoints(keep int) error {
+	_, err := is.db.Exec(
+		`DELETE FROM indexer_checkpoints WHERE block_number NOT IN
+		(SELECT block_number FROM indexer_checkpoints ORDER BY block_number DESC LIMIT $1)`,
+		keep,
+	)
+	return err
+}
+
+// RollbackIndexer removes all logs and checkpoints of blocks greater than or equal to the given block number.
+func (is *IndexerStore) RollbackIndexer(from uint64) error {
+	tx, err := is.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM indexer_logs WHERE block_number >= $1`, from); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM indexer_checkpoints WHERE block_number >= $1`, from); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func encodeTopics(topics []common.Hash) string {
+	res := make([]string, len(topics))
+	for i := range topics {
+		res[i] = topics[i].Hex()
+	}
+	return strings.Join(res, ",")
+}
+
+// FilterLogs returns the indexed logs matching the given query.
+// Unlike the node, the query block range defaults to all indexed blocks.
+func (is *IndexerStore) FilterLogs(q ethereum.FilterQuery) ([]types.Log, error) {
+	query := `SELECT block_number, block_hash, tx_hash, tx_index, log_index, address, topics, data FROM indexer_logs WHERE block_number >= $1`
+	args := []interface{}{uint64(0)}
+	if q.FromBlock != nil {
+		args[0] = q.FromBlock.Uint64()
+	}
+	if q.ToBlock != nil {
+		args = append(args, q.ToBlock.Uint64())
+		query += fmt.Sprintf(" AND block_number <= $%d", len(args))
+	}
+	if len(q.Addresses) > 0 {
+		placeholders := make([]string, len(q.Addresses))
+		for i := range q.Addresses {
+			args = append(args, q.Addresses[i].Hex())
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		query += " AND address IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	query += " ORDER BY block_number, log_index"
+
+	rows, err := is.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []types.Log
+	for rows.Next() {
+		var l types.Log
+		var blockHash, txHash, address, topics string
+		if err := rows.Scan(&l.BlockNumber, &blockHash, &txHash, &l.TxIndex, &l.Index, &address, &topics, &l.Data); err != nil {
+			return nil, err
+		}
+		l.BlockHash = common.HexToHash(blockHash)
+		l.TxHash = common.HexToHash(txHash)
+		l.Address = common.HexToAddress(address)
+		l.Topics = decodeTopics(topics)
+		if matchTopics(l.Topics, q.Topics) {
+			res = append(res, l)
+		}
+	}
+
+	return res, rows.Err()
+}
+
+func decodeTopics(s string) []common.Hash {
+	if s == "" {
+		return nil
+	}
+
+	parts := strings.Split(s, ",")
+	res := make([]common.Hash, len(parts))
+	for i := range parts {
+		res[i] = common.HexToHash(parts[i])
+	}
+	return res
+}
+
+// matchTopics matches the topics the same way the node does, an empty position matches anything.
+func matchTopics(topics []common.Hash, filter [][]common.Hash) bool {
+	if len(filter) > len(topics) {
+		return false
+	}
+
+	for i, sub := range filter {
+		if len(sub) == 0 {
+			continue
+		}
+
+		found := false
+		for _, t := range sub {
+			if topics[i] == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,91 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/indexer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexerStoreRoundTrip(t *testing.T) {
+	db, fdb := newFakeDB(t)
+	store, err := NewIndexerStore(db, true)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int64]string{1: "indexer_init"}, fdb.migrations[indexerMigrationSet])
+
+	addr := common.HexToAddress("0x1")
+	other := common.HexToAddress("0x2")
+	transfer := common.HexToHash("0xaa")
+	logs := []types.Log{
+		{
+			Address:     addr,
+			Topics:      []common.Hash{transfer, common.HexToHash("0xbb")},
+			Data:        []byte{1, 2, 3},
+			BlockNumber: 10,
+			TxHash:      common.HexToHash("0x10"),
+			TxIndex:     1,
+			BlockHash:   common.HexToHash("0x100"),
+			Index:       2,
+		},
+		{
+			Address:     other,
+			Topics:      []common.Hash{common.HexToHash("0xcc")},
+			Data:        []byte{4},
+			BlockNumber: 11,
+			TxHash:      common.HexToHash("0x11"),
+			BlockHash:   common.HexToHash("0x101"),
+			Index:       0,
+		},
+	}
+	assert.NoError(t, store.InsertIndexerBlocks(indexer.Checkpoint{Number: 10, Hash: common.HexToHash("0x100")}, logs[:1]))
+	assert.NoError(t, store.InsertIndexerBlocks(indexer.Checkpoint{Number: 11, Hash: common.HexToHash("0x101")}, logs[1:]))
+	// inserting the same block twice does not duplicate its logs.
+	assert.NoError(t, store.InsertIndexerBlocks(indexer.Checkpoint{Number: 11, Hash: common.HexToHash("0x101")}, logs[1:]))
+
+	res, err := store.FilterLogs(ethereum.FilterQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, logs, res)
+
+	res, err = store.FilterLogs(ethereum.FilterQuery{Addresses: []common.Address{addr}, Topics: [][]common.Hash{{transfer}}})
+	assert.NoError(t, err)
+	assert.Equal(t, logs[:1], res)
+
+	res, err = store.FilterLogs(ethereum.FilterQuery{FromBlock: big.NewInt(11), ToBlock: big.NewInt(11)})
+	assert.NoError(t, err)
+	assert.Equal(t, logs[1:], res)
+
+	cps, err := store.GetIndexerCheckpoints()
+	assert.NoError(t, err)
+	assert.Equal(t, []indexer.Checkpoint{
+		{Number: 11, Hash: common.HexToHash("0x101")},
+		{Number: 10, Hash: common.HexToHash("0x100")},
+	}, cps)
+
+	assert.NoError(t, store.RollbackIndexer(11))
+	res, err = store.FilterLogs(ethereum.FilterQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, logs[:1], res)
+	cps, err = store.GetIndexerCheckpoints()
+	assert.NoError(t, err)
+	assert.Equal(t, []indexer.Checkpoint{{Number: 10, Hash: common.HexToHash("0x100")}}, cps)
+}
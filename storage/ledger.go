@@ -0,0 +1,94 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/ledger"
+)
+
+const ledgerMigrationSet = "ledger"
+
+// LedgerStore is a SQL backed ledger storage.
+type LedgerStore struct {
+	db *sql.DB
+}
+
+// NewLedgerStore returns a new instance of ledger store.
+func NewLedgerStore(db *sql.DB, migrate bool) (*LedgerStore, error) {
+	if migrate {
+		if err := Migrate(db, ledgerMigrationSet, LedgerMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &LedgerStore{db: db}, nil
+}
+
+// InsertLedgerEntry stores the entry. Entries that are already stored are ignored.
+func (ls *LedgerStore) InsertLedgerEntry(e ledger.Entry) error {
+	_, err := ls.db.Exec(
+		`INSERT INTO ledger_entries (identity, tx_hash, log_index, block_number, created_at, kind, sender, recipient, amount, debit_account, credit_account)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (tx_hash, log_index, identity) DO NOTHING`,
+		e.Identity.Hex(), e.TxHash.Hex(), e.LogIndex, e.BlockNumber, e.Time, string(e.Kind),
+		e.From.Hex(), e.To.Hex(), e.Amount.String(), e.DebitAccount, e.CreditAccount,
+	)
+	return err
+}
+
+// DeleteLedgerEntries deletes the entries created for the given log.
+func (ls *LedgerStore) DeleteLedgerEntries(txHash common.Hash, logIndex uint) error {
+	_, err := ls.db.Exec(`DELETE FROM ledger_entries WHERE tx_hash = $1 AND log_index = $2`, txHash.Hex(), logIndex)
+	return err
+}
+
+// GetLedgerEntries returns the entries of the identity in the [from, to) time window, oldest first.
+func (ls *LedgerStore) GetLedgerEntries(identity common.Address, from, to time.Time) ([]ledger.Entry, error) {
+	rows, err := ls.db.Query(
+		`SELECT identity, tx_hash, log_index, block_number, created_at, kind, sender, recipient, amount, debit_account, credit_account
+		FROM ledger_entries WHERE identity = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY block_number, log_index`,
+		identity.Hex(), from, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []ledger.Entry
+	for rows.Next() {
+		var e ledger.Entry
+		var id, txHash, kind, sender, recipient, amount string
+		if err := rows.Scan(&id, &txHash, &e.LogIndex, &e.BlockNumber, &e.Time, &kind, &sender, &recipient, &amount, &e.DebitAccount, &e.CreditAccount); err != nil {
+			return nil, err
+		}
+		e.Identity = common.HexToAddress(id)
+		e.TxHash = common.HexToHash(txHash)
+		e.Kind = ledger.EntryKind(kind)
+		e.From = common.HexToAddress(sender)
+		e.To = common.HexToAddress(recipient)
+		e.Amount, _ = new(big.Int).SetString(amount, 10)
+		res = append(res, e)
+	}
+
+	return res, rows.Err()
+}
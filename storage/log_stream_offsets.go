@@ -0,0 +1,68 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mysteriumnetwork/payments/client"
+)
+
+const logStreamOffsetMigrationSet = "log_stream_offsets"
+
+// LogStreamOffsetStore is a SQL backed storage of named log stream offsets.
+type LogStreamOffsetStore struct {
+	db *sql.DB
+}
+
+// NewLogStreamOffsetStore returns a new instance of log stream offset store.
+func NewLogStreamOffsetStore(db *sql.DB, migrate bool) (*LogStreamOffsetStore, error) {
+	if migrate {
+		if err := Migrate(db, logStreamOffsetMigrationSet, LogStreamOffsetMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &LogStreamOffsetStore{db: db}, nil
+}
+
+// GetLogStreamOffset returns the offset stored under the name or nil if there is none.
+func (ls *LogStreamOffsetStore) GetLogStreamOffset(name string) (*client.LogCursor, error) {
+	var blockNumber uint64
+	var index int64
+	err := ls.db.QueryRow(`SELECT block_number, log_index FROM log_stream_offsets WHERE name = $1`, name).Scan(&blockNumber, &index)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the index is stored as a signed integer, the cursor positioned after the last log of a block wraps around
+	return &client.LogCursor{BlockNumber: blockNumber, Index: uint(index)}, nil
+}
+
+// SaveLogStreamOffset stores the offset under the name.
+func (ls *LogStreamOffsetStore) SaveLogStreamOffset(name string, cursor client.LogCursor) error {
+	_, err := ls.db.Exec(
+		`INSERT INTO log_stream_offsets (name, block_number, log_index, updated_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (name) DO UPDATE SET block_number = EXCLUDED.block_number, log_index = EXCLUDED.log_index, updated_at = EXCLUDED.updated_at`,
+		name, cursor.BlockNumber, int64(cursor.Index), time.Now().UTC(),
+	)
+	return err
+}
@@ -0,0 +1,453 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package storage contains SQL backed implementations of the storages used by the payments library.
+// The queries are written for Postgres, the database driver is provided by the caller.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// Migration represents a single schema change.
+type Migration struct {
+	// Version must be unique and increasing within the migration set.
+	Version int
+	Name    string
+	Up      string
+}
+
+// IndexerMigrations creates the schema required by IndexerStore.
+var IndexerMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "indexer_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS indexer_logs (
+	block_number BIGINT NOT NULL,
+	block_hash CHAR(66) NOT NULL,
+	tx_hash CHAR(66) NOT NULL,
+	tx_index INTEGER NOT NULL,
+	log_index INTEGER NOT NULL,
+	address CHAR(42) NOT NULL,
+	topics TEXT NOT NULL,
+	data BYTEA NOT NULL,
+	PRIMARY KEY (block_number, log_index)
+);
+CREATE INDEX IF NOT EXISTS indexer_logs_address_idx ON indexer_logs (address);
+CREATE TABLE IF NOT EXISTS indexer_checkpoints (
+	block_number BIGINT PRIMARY KEY,
+	block_hash CHAR(66) NOT NULL
+);`,
+	},
+}
+
+// IncrementorMigrations creates the schema required by IncrementorStore.
+var IncrementorMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "incrementor_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS incrementor_transactions (
+	unique_id TEXT PRIMARY KEY,
+	opts TEXT NOT NULL,
+	state TEXT NOT NULL,
+	tx_hash CHAR(66) NOT NULL,
+	chain_id BIGINT NOT NULL,
+	original_tx TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS incrementor_transactions_state_idx ON incrementor_transactions (state);`,
+	},
+	{
+		Version: 2,
+		Name:    "incrementor_created_at_bumps",
+		Up: `
+ALTER TABLE incrementor_transactions ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+ALTER TABLE incrementor_transactions ADD COLUMN IF NOT EXISTS bumps INTEGER NOT NULL DEFAULT 0;`,
+	},
+}
+
+// PolicyMigrations creates the schema required by PolicyStore.
+var PolicyMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "policy_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS policy_entries (
+	address CHAR(42) NOT NULL,
+	source TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	PRIMARY KEY (address, source)
+);`,
+	},
+}
+
+// AuditMigrations creates the schema required by AuditStore.
+var AuditMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "audit_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS audit_records (
+	sequence BIGINT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	actor CHAR(42) NOT NULL,
+	action TEXT NOT NULL,
+	tx_hash CHAR(66) NOT NULL,
+	params TEXT NOT NULL,
+	prev_hash CHAR(66) NOT NULL,
+	hash CHAR(66) NOT NULL
+);`,
+	},
+}
+
+// DeadLetterMigrations creates the schema required by DeadLetterStore.
+var DeadLetterMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "dead_letter_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS dead_letters (
+	id CHAR(66) PRIMARY KEY,
+	kind TEXT NOT NULL,
+	request TEXT NOT NULL,
+	gas_price TEXT NOT NULL,
+	gas_limit BIGINT NOT NULL,
+	errors TEXT NOT NULL,
+	attempts INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);`,
+	},
+}
+
+// LedgerMigrations creates the schema required by LedgerStore.
+var LedgerMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "ledger_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS ledger_entries (
+	identity CHAR(42) NOT NULL,
+	tx_hash CHAR(66) NOT NULL,
+	log_index INTEGER NOT NULL,
+	block_number BIGINT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	kind TEXT NOT NULL,
+	sender CHAR(42) NOT NULL,
+	recipient CHAR(42) NOT NULL,
+	amount NUMERIC(78) NOT NULL,
+	debit_account TEXT NOT NULL,
+	credit_account TEXT NOT NULL,
+	PRIMARY KEY (tx_hash, log_index, identity)
+);
+CREATE INDEX IF NOT EXISTS ledger_entries_identity_time ON ledger_entries (identity, created_at);`,
+	},
+}
+
+// ExposureMigrations creates the schema required by ExposureStore.
+var ExposureMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "exposure_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS exposures (
+	hermes_id CHAR(42) NOT NULL,
+	provider CHAR(42) NOT NULL,
+	promised NUMERIC(78) NOT NULL,
+	settled NUMERIC(78) NOT NULL,
+	PRIMARY KEY (hermes_id, provider)
+);`,
+	},
+}
+
+// GasStatsMigrations creates the schema required by GasStatsStore.
+var GasStatsMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "gas_stats_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS gas_samples (
+	chain_id BIGINT NOT NULL,
+	block_number BIGINT NOT NULL,
+	block_time TIMESTAMP NOT NULL,
+	base_fee NUMERIC(78) NOT NULL,
+	priority_fee NUMERIC(78) NOT NULL,
+	PRIMARY KEY (chain_id, block_number)
+);
+CREATE INDEX IF NOT EXISTS gas_samples_chain_time ON gas_samples (chain_id, block_time);`,
+	},
+}
+
+// ScheduleMigrations creates the schema required by ScheduleStore.
+var ScheduleMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "schedule_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS scheduled_settlements (
+	id CHAR(66) PRIMARY KEY,
+	kind TEXT NOT NULL,
+	request TEXT NOT NULL,
+	not_before TIMESTAMP NOT NULL,
+	max_gas_price TEXT NOT NULL,
+	max_fee TEXT NOT NULL,
+	last_error TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS scheduled_settlements_not_before ON scheduled_settlements (not_before);`,
+	},
+	{
+		Version: 2,
+		Name:    "schedule_strategy",
+		Up:      `ALTER TABLE scheduled_settlements ADD COLUMN IF NOT EXISTS strategy TEXT NOT NULL DEFAULT '';`,
+	},
+}
+
+// SpendCapMigrations creates the schema required by SpendCapStore.
+var SpendCapMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "spend_cap_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS consumer_spends (
+	chain_id BIGINT NOT NULL,
+	consumer CHAR(42) NOT NULL,
+	hermes_id CHAR(42) NOT NULL,
+	promised NUMERIC(78) NOT NULL,
+	spend_cap TEXT NOT NULL,
+	stopped BOOLEAN NOT NULL,
+	PRIMARY KEY (chain_id, consumer, hermes_id)
+);`,
+	},
+}
+
+// SplitMigrations creates the schema required by SplitStore.
+var SplitMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "split_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS settlement_splits (
+	id CHAR(66) PRIMARY KEY,
+	provider CHAR(42) NOT NULL,
+	beneficiary CHAR(42) NOT NULL,
+	proceeds NUMERIC(78) NOT NULL,
+	transfers TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS settlement_splits_provider ON settlement_splits (provider);`,
+	},
+}
+
+// LogStreamOffsetMigrations creates the schema required by LogStreamOffsetStore.
+var LogStreamOffsetMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "log_stream_offsets_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS log_stream_offsets (
+	name TEXT PRIMARY KEY,
+	block_number BIGINT NOT NULL,
+	log_index BIGINT NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);`,
+	},
+}
+
+// TopUpMigrations creates the schema required by TopUpStore.
+var TopUpMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "topups_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS topups (
+	chain_id BIGINT NOT NULL,
+	tx_hash CHAR(66) NOT NULL,
+	log_index INTEGER NOT NULL,
+	channel CHAR(42) NOT NULL,
+	payer CHAR(42) NOT NULL,
+	amount NUMERIC(78) NOT NULL,
+	block_number BIGINT NOT NULL,
+	PRIMARY KEY (chain_id, tx_hash, log_index)
+);
+CREATE INDEX IF NOT EXISTS topups_channel_idx ON topups (chain_id, channel);`,
+	},
+}
+
+// HermesMigrations creates the schema required by HermesStore.
+var HermesMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "hermes_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS hermes_issuances (
+	id BIGSERIAL PRIMARY KEY,
+	chain_id BIGINT NOT NULL,
+	consumer CHAR(42) NOT NULL,
+	consumer_channel CHAR(42) NOT NULL,
+	provider CHAR(42) NOT NULL,
+	agreement_id NUMERIC(78) NOT NULL,
+	agreement_total NUMERIC(78) NOT NULL,
+	hashlock CHAR(64) NOT NULL,
+	consumer_promise TEXT NOT NULL,
+	provider_promise TEXT NOT NULL,
+	r TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS hermes_issuances_channel_idx ON hermes_issuances (chain_id, consumer_channel);
+CREATE INDEX IF NOT EXISTS hermes_issuances_provider_idx ON hermes_issuances (chain_id, provider, agreement_id);
+CREATE INDEX IF NOT EXISTS hermes_issuances_hashlock_idx ON hermes_issuances (chain_id, hashlock);`,
+	},
+}
+
+// SponsorshipMigrations creates the schema required by SponsorshipStore.
+var SponsorshipMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "sponsorship_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS sponsorships (
+	tx_hash CHAR(66) PRIMARY KEY,
+	sponsor CHAR(42) NOT NULL,
+	identity CHAR(42) NOT NULL,
+	block_number BIGINT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	operation TEXT NOT NULL,
+	gas_used BIGINT NOT NULL,
+	gas_price NUMERIC(78) NOT NULL,
+	cost NUMERIC(78) NOT NULL,
+	fee NUMERIC(78) NOT NULL,
+	failed BOOLEAN NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sponsorships_sponsor_identity_time ON sponsorships (sponsor, identity, created_at);
+CREATE INDEX IF NOT EXISTS sponsorships_sponsor_time ON sponsorships (sponsor, created_at);`,
+	},
+}
+
+// RotationMigrations creates the schema required by RotationStore.
+var RotationMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "rotation_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS beneficiary_rotations (
+	provider CHAR(42) PRIMARY KEY,
+	hermes_id CHAR(42) NOT NULL,
+	previous CHAR(42) NOT NULL,
+	beneficiary CHAR(42) NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	verification_tx CHAR(66) NOT NULL,
+	verification_amount NUMERIC(78) NOT NULL,
+	error TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);`,
+	},
+}
+
+// AddressBookMigrations creates the schema required by AddressBookStore.
+var AddressBookMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "address_book_init",
+		Up: `
+CREATE TABLE IF NOT EXISTS address_book_entries (
+	label TEXT PRIMARY KEY,
+	address CHAR(42) NOT NULL
+);`,
+	},
+}
+
+// migrationLockID is the Postgres advisory lock key held while migrations are applied.
+const migrationLockID = 6829717432901
+
+// Migrate applies the migrations that have not been applied yet, in the order of their versions.
+// Applied versions are tracked per set in the schema_migrations table, so different sets can share a database
+// and running Migrate again only applies the new migrations.
+// An advisory lock is held for the whole run so that processes starting at the same time do not race.
+//
+// Store constructors call it for their own set when migrate is set.
+func Migrate(db *sql.DB, set string, migrations []Migration) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("could not lock migrations: %w", err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	set_name TEXT NOT NULL,
+	version INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	PRIMARY KEY (set_name, version)
+)`)
+	if err != nil {
+		return fmt.Errorf("could not create migrations table: %w", err)
+	}
+
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+
+	for _, m := range sorted {
+		if err := apply(ctx, conn, set, m); err != nil {
+			return fmt.Errorf("could not apply migration %v_%v: %w", m.Version, m.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func apply(ctx context.Context, conn *sql.Conn, set string, m Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var applied int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE set_name = $1 AND version = $2`, set, m.Version).Scan(&applied)
+	if err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(m.Up); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (set_name, version, name) VALUES ($1, $2, $3)`, set, m.Version, m.Name); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,84 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateAppliesInVersionOrder(t *testing.T) {
+	db, fdb := newFakeDB(t)
+
+	err := Migrate(db, "test", []Migration{
+		{Version: 3, Name: "third", Up: "third"},
+		{Version: 1, Name: "first", Up: "first"},
+		{Version: 2, Name: "second", Up: "second"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second", "third"}, fdb.executed)
+	assert.Equal(t, map[int64]string{1: "first", 2: "second", 3: "third"}, fdb.migrations["test"])
+}
+
+func TestMigrateRerunAppliesOnlyNewMigrations(t *testing.T) {
+	db, fdb := newFakeDB(t)
+
+	migrations := []Migration{{Version: 1, Name: "first", Up: "first"}}
+	assert.NoError(t, Migrate(db, "test", migrations))
+	assert.NoError(t, Migrate(db, "test", migrations))
+	assert.Equal(t, []string{"first"}, fdb.executed)
+
+	migrations = append(migrations, Migration{Version: 2, Name: "second", Up: "second"})
+	assert.NoError(t, Migrate(db, "test", migrations))
+	assert.Equal(t, []string{"first", "second"}, fdb.executed)
+}
+
+func TestMigrateTracksSetsSeparately(t *testing.T) {
+	db, fdb := newFakeDB(t)
+
+	assert.NoError(t, Migrate(db, "a", []Migration{{Version: 1, Name: "a_init", Up: "a"}}))
+	assert.NoError(t, Migrate(db, "b", []Migration{{Version: 1, Name: "b_init", Up: "b"}}))
+
+	assert.Equal(t, []string{"a", "b"}, fdb.executed)
+	assert.Equal(t, map[int64]string{1: "a_init"}, fdb.migrations["a"])
+	assert.Equal(t, map[int64]string{1: "b_init"}, fdb.migrations["b"])
+}
+
+func TestMigrateHoldsLock(t *testing.T) {
+	db, fdb := newFakeDB(t)
+
+	migrations := []Migration{
+		{Version: 1, Name: "first", Up: "first"},
+		{Version: 2, Name: "second", Up: "second"},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, Migrate(db, "test", migrations))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, []string{"first", "second"}, fdb.executed)
+	assert.Equal(t, 5, fdb.locks)
+	assert.Equal(t, 5, fdb.unlocks)
+}
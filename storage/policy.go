@@ -0,0 +1,81 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/policy"
+)
+
+const policyMigrationSet = "policy"
+
+// PolicyStore is a SQL backed address policy storage.
+type PolicyStore struct {
+	db *sql.DB
+}
+
+// NewPolicyStore returns a new instance of policy store.
+func NewPolicyStore(db *sql.DB, migrate bool) (*PolicyStore, error) {
+	if migrate {
+		if err := Migrate(db, policyMigrationSet, PolicyMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &PolicyStore{db: db}, nil
+}
+
+// UpsertPolicyEntry inserts a new entry or updates the existing one of the same address and source.
+func (ps *PolicyStore) UpsertPolicyEntry(e policy.Entry) error {
+	_, err := ps.db.Exec(
+		`INSERT INTO policy_entries (address, source, kind, reason) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (address, source) DO UPDATE SET kind = EXCLUDED.kind, reason = EXCLUDED.reason`,
+		e.Address.Hex(), e.Source, string(e.Kind), e.Reason,
+	)
+	return err
+}
+
+// DeletePolicyEntry deletes the entry of the address from the given source.
+func (ps *PolicyStore) DeletePolicyEntry(address common.Address, source string) error {
+	_, err := ps.db.Exec(`DELETE FROM policy_entries WHERE address = $1 AND source = $2`, address.Hex(), source)
+	return err
+}
+
+// GetPolicyEntries returns all the entries.
+func (ps *PolicyStore) GetPolicyEntries() ([]policy.Entry, error) {
+	rows, err := ps.db.Query(`SELECT address, source, kind, reason FROM policy_entries`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []policy.Entry
+	for rows.Next() {
+		var e policy.Entry
+		var address, kind string
+		if err := rows.Scan(&address, &e.Source, &kind, &e.Reason); err != nil {
+			return nil, err
+		}
+		e.Address = common.HexToAddress(address)
+		e.Kind = policy.Kind(kind)
+		res = append(res, e)
+	}
+
+	return res, rows.Err()
+}
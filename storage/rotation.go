@@ -0,0 +1,83 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/settlement"
+)
+
+const rotationMigrationSet = "rotation"
+
+// RotationStore is a SQL backed beneficiary rotation storage.
+type RotationStore struct {
+	db *sql.DB
+}
+
+// NewRotationStore returns a new instance of rotation store.
+func NewRotationStore(db *sql.DB, migrate bool) (*RotationStore, error) {
+	if migrate {
+		if err := Migrate(db, rotationMigrationSet, RotationMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &RotationStore{db: db}, nil
+}
+
+// UpsertRotation inserts the rotation or replaces the previous rotation of the provider.
+func (rs *RotationStore) UpsertRotation(r settlement.Rotation) error {
+	_, err := rs.db.Exec(
+		`INSERT INTO beneficiary_rotations (provider, hermes_id, previous, beneficiary, status, verification_tx, verification_amount, error, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (provider) DO UPDATE SET hermes_id = EXCLUDED.hermes_id, previous = EXCLUDED.previous,
+			beneficiary = EXCLUDED.beneficiary, status = EXCLUDED.status, verification_tx = EXCLUDED.verification_tx,
+			verification_amount = EXCLUDED.verification_amount, error = EXCLUDED.error,
+			created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at`,
+		r.Provider.Hex(), r.HermesID.Hex(), r.Previous.Hex(), r.Beneficiary.Hex(), string(r.Status),
+		r.VerificationTx.Hex(), r.VerificationAmount.String(), r.Error, r.CreatedAt, r.UpdatedAt,
+	)
+	return err
+}
+
+// GetRotation returns the rotation of the provider or nil if there is none.
+func (rs *RotationStore) GetRotation(provider common.Address) (*settlement.Rotation, error) {
+	var r settlement.Rotation
+	var hermesID, previous, beneficiary, status, tx, amount string
+	err := rs.db.QueryRow(
+		`SELECT hermes_id, previous, beneficiary, status, verification_tx, verification_amount, error, created_at, updated_at
+		FROM beneficiary_rotations WHERE provider = $1`, provider.Hex(),
+	).Scan(&hermesID, &previous, &beneficiary, &status, &tx, &amount, &r.Error, &r.CreatedAt, &r.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.Provider = provider
+	r.HermesID = common.HexToAddress(hermesID)
+	r.Previous = common.HexToAddress(previous)
+	r.Beneficiary = common.HexToAddress(beneficiary)
+	r.Status = settlement.RotationStatus(status)
+	r.VerificationTx = common.HexToHash(tx)
+	r.VerificationAmount, _ = new(big.Int).SetString(amount, 10)
+	return &r, nil
+}
@@ -0,0 +1,117 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math/big"
+
+	"github.com/mysteriumnetwork/payments/settlement"
+)
+
+const scheduleMigrationSet = "schedule"
+
+// ScheduleStore is a SQL backed scheduled settlement storage.
+type ScheduleStore struct {
+	db *sql.DB
+}
+
+// NewScheduleStore returns a new instance of scheduled settlement store.
+func NewScheduleStore(db *sql.DB, migrate bool) (*ScheduleStore, error) {
+	if migrate {
+		if err := Migrate(db, scheduleMigrationSet, ScheduleMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ScheduleStore{db: db}, nil
+}
+
+// UpsertScheduledSettlement inserts a new scheduled settlement or updates the existing one.
+func (ss *ScheduleStore) UpsertScheduledSettlement(s settlement.ScheduledSettlement) error {
+	_, err := ss.db.Exec(
+		`INSERT INTO scheduled_settlements (id, kind, request, not_before, max_gas_price, max_fee, strategy, last_error, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (id) DO UPDATE SET not_before = EXCLUDED.not_before, max_gas_price = EXCLUDED.max_gas_price,
+		max_fee = EXCLUDED.max_fee, strategy = EXCLUDED.strategy, last_error = EXCLUDED.last_error, updated_at = EXCLUDED.updated_at`,
+		s.ID, s.Kind, string(s.Request), s.NotBefore, bigString(s.Constraints.MaxGasPrice), bigString(s.Constraints.MaxFee),
+		s.Constraints.Strategy, s.LastError, s.CreatedAt, s.UpdatedAt,
+	)
+	return err
+}
+
+// DeleteScheduledSettlement deletes the scheduled settlement.
+func (ss *ScheduleStore) DeleteScheduledSettlement(id string) error {
+	_, err := ss.db.Exec(`DELETE FROM scheduled_settlements WHERE id = $1`, id)
+	return err
+}
+
+// GetScheduledSettlement returns the scheduled settlement or nil if it does not exist.
+func (ss *ScheduleStore) GetScheduledSettlement(id string) (*settlement.ScheduledSettlement, error) {
+	res, err := ss.query(`SELECT id, kind, request, not_before, max_gas_price, max_fee, strategy, last_error, created_at, updated_at
+		FROM scheduled_settlements WHERE id = $1`, id)
+	if err != nil || len(res) == 0 {
+		return nil, err
+	}
+	return &res[0], nil
+}
+
+// GetScheduledSettlements returns all the scheduled settlements, earliest first.
+func (ss *ScheduleStore) GetScheduledSettlements() ([]settlement.ScheduledSettlement, error) {
+	return ss.query(`SELECT id, kind, request, not_before, max_gas_price, max_fee, strategy, last_error, created_at, updated_at
+		FROM scheduled_settlements ORDER BY not_before`)
+}
+
+func (ss *ScheduleStore) query(query string, args ...interface{}) ([]settlement.ScheduledSettlement, error) {
+	rows, err := ss.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []settlement.ScheduledSettlement
+	for rows.Next() {
+		var s settlement.ScheduledSettlement
+		var request, maxGasPrice, maxFee string
+		if err := rows.Scan(&s.ID, &s.Kind, &request, &s.NotBefore, &maxGasPrice, &maxFee, &s.Constraints.Strategy, &s.LastError, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		s.Request = json.RawMessage(request)
+		s.Constraints.MaxGasPrice = parseBig(maxGasPrice)
+		s.Constraints.MaxFee = parseBig(maxFee)
+		res = append(res, s)
+	}
+
+	return res, rows.Err()
+}
+
+// bigString renders nil as an empty string, so optional amounts can be stored in TEXT columns.
+func bigString(v *big.Int) string {
+	if v == nil {
+		return ""
+	}
+	return v.String()
+}
+
+func parseBig(s string) *big.Int {
+	if s == "" {
+		return nil
+	}
+	v, _ := new(big.Int).SetString(s, 10)
+	return v
+}
@@ -0,0 +1,80 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/spendcap"
+)
+
+const spendCapMigrationSet = "spend_cap"
+
+// SpendCapStore is a SQL backed consumer spend storage.
+type SpendCapStore struct {
+	db *sql.DB
+}
+
+// NewSpendCapStore returns a new instance of spend cap store.
+func NewSpendCapStore(db *sql.DB, migrate bool) (*SpendCapStore, error) {
+	if migrate {
+		if err := Migrate(db, spendCapMigrationSet, SpendCapMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &SpendCapStore{db: db}, nil
+}
+
+// UpsertSpend inserts or updates the spending state of the consumer with the hermes.
+func (ss *SpendCapStore) UpsertSpend(s spendcap.Spend) error {
+	_, err := ss.db.Exec(
+		`INSERT INTO consumer_spends (chain_id, consumer, hermes_id, promised, spend_cap, stopped)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (chain_id, consumer, hermes_id) DO UPDATE SET promised = EXCLUDED.promised,
+		spend_cap = EXCLUDED.spend_cap, stopped = EXCLUDED.stopped`,
+		s.ChainID, s.Consumer.Hex(), s.HermesID.Hex(), s.Promised.String(), bigString(s.Cap), s.Stopped,
+	)
+	return err
+}
+
+// GetSpends returns the spending state of all consumers.
+func (ss *SpendCapStore) GetSpends() ([]spendcap.Spend, error) {
+	rows, err := ss.db.Query(`SELECT chain_id, consumer, hermes_id, promised, spend_cap, stopped FROM consumer_spends`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []spendcap.Spend
+	for rows.Next() {
+		var s spendcap.Spend
+		var consumer, hermesID, promised, limit string
+		if err := rows.Scan(&s.ChainID, &consumer, &hermesID, &promised, &limit, &s.Stopped); err != nil {
+			return nil, err
+		}
+		s.Consumer = common.HexToAddress(consumer)
+		s.HermesID = common.HexToAddress(hermesID)
+		s.Promised, _ = new(big.Int).SetString(promised, 10)
+		s.Cap = parseBig(limit)
+		res = append(res, s)
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,103 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/settlement"
+)
+
+const splitMigrationSet = "split"
+
+// SplitStore is a SQL backed settlement split storage.
+type SplitStore struct {
+	db *sql.DB
+}
+
+// NewSplitStore returns a new instance of split store.
+func NewSplitStore(db *sql.DB, migrate bool) (*SplitStore, error) {
+	if migrate {
+		if err := Migrate(db, splitMigrationSet, SplitMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &SplitStore{db: db}, nil
+}
+
+// UpsertSplit inserts a new split or updates the transfers of the existing one.
+func (ss *SplitStore) UpsertSplit(s settlement.Split) error {
+	transfers, err := json.Marshal(s.Transfers)
+	if err != nil {
+		return err
+	}
+
+	_, err = ss.db.Exec(
+		`INSERT INTO settlement_splits (id, provider, beneficiary, proceeds, transfers, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (id) DO UPDATE SET transfers = EXCLUDED.transfers, updated_at = EXCLUDED.updated_at`,
+		s.ID.Hex(), s.Provider.Hex(), s.Beneficiary.Hex(), s.Proceeds.String(), string(transfers), s.CreatedAt, s.UpdatedAt,
+	)
+	return err
+}
+
+// GetSplit returns the split or nil if it does not exist.
+func (ss *SplitStore) GetSplit(id common.Hash) (*settlement.Split, error) {
+	res, err := ss.query(`SELECT id, provider, beneficiary, proceeds, transfers, created_at, updated_at
+		FROM settlement_splits WHERE id = $1`, id.Hex())
+	if err != nil || len(res) == 0 {
+		return nil, err
+	}
+	return &res[0], nil
+}
+
+// GetSplits returns the splits of the provider, oldest first.
+func (ss *SplitStore) GetSplits(provider common.Address) ([]settlement.Split, error) {
+	return ss.query(`SELECT id, provider, beneficiary, proceeds, transfers, created_at, updated_at
+		FROM settlement_splits WHERE provider = $1 ORDER BY created_at`, provider.Hex())
+}
+
+func (ss *SplitStore) query(query string, args ...interface{}) ([]settlement.Split, error) {
+	rows, err := ss.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []settlement.Split
+	for rows.Next() {
+		var s settlement.Split
+		var id, provider, beneficiary, proceeds, transfers string
+		if err := rows.Scan(&id, &provider, &beneficiary, &proceeds, &transfers, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(transfers), &s.Transfers); err != nil {
+			return nil, err
+		}
+		s.ID = common.HexToHash(id)
+		s.Provider = common.HexToAddress(provider)
+		s.Beneficiary = common.HexToAddress(beneficiary)
+		s.Proceeds, _ = new(big.Int).SetString(proceeds, 10)
+		res = append(res, s)
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,108 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/ledger"
+)
+
+const sponsorshipMigrationSet = "sponsorship"
+
+// SponsorshipStore is a SQL backed gas sponsorship storage.
+type SponsorshipStore struct {
+	db *sql.DB
+}
+
+// NewSponsorshipStore returns a new instance of sponsorship store.
+func NewSponsorshipStore(db *sql.DB, migrate bool) (*SponsorshipStore, error) {
+	if migrate {
+		if err := Migrate(db, sponsorshipMigrationSet, SponsorshipMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &SponsorshipStore{db: db}, nil
+}
+
+// InsertSponsorship stores the sponsorship. Sponsorships of already stored transactions are ignored.
+func (ss *SponsorshipStore) InsertSponsorship(s ledger.Sponsorship) error {
+	_, err := ss.db.Exec(
+		`INSERT INTO sponsorships (tx_hash, sponsor, identity, block_number, created_at, operation, gas_used, gas_price, cost, fee, failed)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (tx_hash) DO NOTHING`,
+		s.TxHash.Hex(), s.Sponsor.Hex(), s.Identity.Hex(), s.BlockNumber, s.Time, s.Operation,
+		s.GasUsed, s.GasPrice.String(), s.Cost.String(), s.Fee.String(), s.Failed,
+	)
+	return err
+}
+
+// DeleteSponsorship deletes the sponsorship of the transaction.
+func (ss *SponsorshipStore) DeleteSponsorship(txHash common.Hash) error {
+	_, err := ss.db.Exec(`DELETE FROM sponsorships WHERE tx_hash = $1`, txHash.Hex())
+	return err
+}
+
+// GetSponsorships returns the sponsorships of the sponsor in the [from, to) time window, oldest first.
+func (ss *SponsorshipStore) GetSponsorships(sponsor common.Address, from, to time.Time) ([]ledger.Sponsorship, error) {
+	return ss.query(
+		`SELECT tx_hash, sponsor, identity, block_number, created_at, operation, gas_used, gas_price, cost, fee, failed
+		FROM sponsorships WHERE sponsor = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY block_number, tx_hash`,
+		sponsor.Hex(), from, to,
+	)
+}
+
+// GetIdentitySponsorships returns the sponsorships of the identity by the sponsor in the [from, to) time window, oldest first.
+func (ss *SponsorshipStore) GetIdentitySponsorships(sponsor, identity common.Address, from, to time.Time) ([]ledger.Sponsorship, error) {
+	return ss.query(
+		`SELECT tx_hash, sponsor, identity, block_number, created_at, operation, gas_used, gas_price, cost, fee, failed
+		FROM sponsorships WHERE sponsor = $1 AND identity = $2 AND created_at >= $3 AND created_at < $4
+		ORDER BY block_number, tx_hash`,
+		sponsor.Hex(), identity.Hex(), from, to,
+	)
+}
+
+func (ss *SponsorshipStore) query(query string, args ...interface{}) ([]ledger.Sponsorship, error) {
+	rows, err := ss.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []ledger.Sponsorship
+	for rows.Next() {
+		var s ledger.Sponsorship
+		var txHash, sponsor, identity, gasPrice, cost, fee string
+		if err := rows.Scan(&txHash, &sponsor, &identity, &s.BlockNumber, &s.Time, &s.Operation, &s.GasUsed, &gasPrice, &cost, &fee, &s.Failed); err != nil {
+			return nil, err
+		}
+		s.TxHash = common.HexToHash(txHash)
+		s.Sponsor = common.HexToAddress(sponsor)
+		s.Identity = common.HexToAddress(identity)
+		s.GasPrice, _ = new(big.Int).SetString(gasPrice, 10)
+		s.Cost, _ = new(big.Int).SetString(cost, 10)
+		s.Fee, _ = new(big.Int).SetString(fee, 10)
+		res = append(res, s)
+	}
+
+	return res, rows.Err()
+}
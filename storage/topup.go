@@ -0,0 +1,88 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mysteriumnetwork/payments/topup"
+)
+
+const topUpMigrationSet = "topup"
+
+// TopUpStore is a SQL backed channel top-up storage.
+type TopUpStore struct {
+	db *sql.DB
+}
+
+// NewTopUpStore returns a new instance of top-up store.
+func NewTopUpStore(db *sql.DB, migrate bool) (*TopUpStore, error) {
+	if migrate {
+		if err := Migrate(db, topUpMigrationSet, TopUpMigrations); err != nil {
+			return nil, err
+		}
+	}
+
+	return &TopUpStore{db: db}, nil
+}
+
+// InsertTopUp stores the top-up, top-ups of an already stored log are ignored.
+func (ts *TopUpStore) InsertTopUp(t topup.TopUp) error {
+	_, err := ts.db.Exec(
+		`INSERT INTO topups (chain_id, tx_hash, log_index, channel, payer, amount, block_number)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (chain_id, tx_hash, log_index) DO NOTHING`,
+		t.ChainID, t.TxHash.Hex(), t.LogIndex, t.Channel.Hex(), t.Payer.Hex(), t.Amount.String(), t.BlockNumber,
+	)
+	return err
+}
+
+// DeleteTopUp removes the top-up of the log.
+func (ts *TopUpStore) DeleteTopUp(chainID int64, txHash common.Hash, logIndex uint) error {
+	_, err := ts.db.Exec(`DELETE FROM topups WHERE chain_id = $1 AND tx_hash = $2 AND log_index = $3`, chainID, txHash.Hex(), logIndex)
+	return err
+}
+
+// GetTopUps returns the top-ups of the channel, oldest first.
+func (ts *TopUpStore) GetTopUps(chainID int64, channel common.Address) ([]topup.TopUp, error) {
+	rows, err := ts.db.Query(
+		`SELECT tx_hash, log_index, payer, amount, block_number FROM topups
+		WHERE chain_id = $1 AND channel = $2 ORDER BY block_number, log_index`,
+		chainID, channel.Hex(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []topup.TopUp
+	for rows.Next() {
+		var txHash, payer, amount string
+		t := topup.TopUp{ChainID: chainID, Channel: channel}
+		if err := rows.Scan(&txHash, &t.LogIndex, &payer, &amount, &t.BlockNumber); err != nil {
+			return nil, err
+		}
+		t.TxHash = common.HexToHash(txHash)
+		t.Payer = common.HexToAddress(payer)
+		t.Amount, _ = new(big.Int).SetString(amount, 10)
+		res = append(res, t)
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,271 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package supervisor runs background workers, restarting them with backoff when they panic.
+package supervisor
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Worker is a background loop. Run blocks until Stop is called.
+// The indexer, the schedulers, the alert engine and the gas price incrementor all satisfy it.
+type Worker interface {
+	Run()
+	Stop()
+}
+
+// PanicError is reported when a worker panics.
+type PanicError struct {
+	Worker string
+	Value  interface{}
+	Stack  []byte
+}
+
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("worker %v panicked: %v", pe.Worker, pe.Value)
+}
+
+// LogFunc is called with the panics of the workers.
+type LogFunc func(*PanicError)
+
+// Opts configure the supervisor.
+type Opts struct {
+	// InitialBackoff is the wait before the first restart of a crashed worker. It doubles with every crash up to MaxBackoff.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the restart wait. A worker that ran longer than MaxBackoff before crashing restarts after InitialBackoff again.
+	MaxBackoff time.Duration
+	// CrashWindow and MaxCrashes mark a worker unhealthy once it crashed MaxCrashes times within CrashWindow.
+	CrashWindow time.Duration
+	MaxCrashes  int
+}
+
+// DefaultOpts returns the default supervisor options.
+func DefaultOpts() Opts {
+	return Opts{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		CrashWindow:    10 * time.Minute,
+		MaxCrashes:     3,
+	}
+}
+
+// WorkerHealth is the state of a supervised worker.
+type WorkerHealth struct {
+	Name string
+	// Running is false once the worker returned or the supervisor stopped.
+	Running  bool
+	Restarts int
+	// RecentCrashes is the number of crashes within the crash window.
+	RecentCrashes int
+	LastPanic     string
+	LastPanicAt   time.Time
+	Healthy       bool
+}
+
+type workerState struct {
+	name        string
+	worker      Worker
+	running     bool
+	restarts    int
+	crashes     []time.Time
+	lastPanic   string
+	lastPanicAt time.Time
+}
+
+// Supervisor runs workers, recovering their panics and restarting them with exponential backoff.
+type Supervisor struct {
+	opts    Opts
+	logFunc LogFunc
+	now     func() time.Time
+
+	lock    sync.Mutex
+	workers []*workerState
+
+	wg   sync.WaitGroup
+	stop chan struct{}
+	once sync.Once
+}
+
+// New returns a new supervisor.
+func New(opts Opts) *Supervisor {
+	return &Supervisor{
+		opts:    opts,
+		logFunc: func(*PanicError) {},
+		now:     time.Now,
+		stop:    make(chan struct{}),
+	}
+}
+
+// AttachLogFunc sets the function worker panics are reported to.
+// Not thread safe, call before Supervise.
+func (s *Supervisor) AttachLogFunc(f LogFunc) {
+	s.logFunc = f
+}
+
+// Supervise runs the worker on its own goroutine until it returns or the supervisor is stopped.
+func (s *Supervisor) Supervise(name string, w Worker) {
+	state := &workerState{name: name, worker: w, running: true}
+
+	s.lock.Lock()
+	s.workers = append(s.workers, state)
+	s.lock.Unlock()
+
+	s.wg.Add(1)
+	go s.supervise(state)
+}
+
+// Go runs the function as a worker without a Stop method. It is meant for loops that watch their own stop signal.
+func (s *Supervisor) Go(name string, run func()) {
+	s.Supervise(name, funcWorker(run))
+}
+
+// Stop stops the workers and waits for them to return.
+func (s *Supervisor) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+
+		s.lock.Lock()
+		workers := append([]*workerState{}, s.workers...)
+		s.lock.Unlock()
+
+		for _, w := range workers {
+			w.worker.Stop()
+		}
+	})
+	s.wg.Wait()
+}
+
+// Health returns the state of the supervised workers, sorted by name.
+func (s *Supervisor) Health() []WorkerHealth {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+	res := make([]WorkerHealth, 0, len(s.workers))
+	for _, w := range s.workers {
+		recent := 0
+		for _, c := range w.crashes {
+			if now.Sub(c) <= s.opts.CrashWindow {
+				recent++
+			}
+		}
+		res = append(res, WorkerHealth{
+			Name:          w.name,
+			Running:       w.running,
+			Restarts:      w.restarts,
+			RecentCrashes: recent,
+			LastPanic:     w.lastPanic,
+			LastPanicAt:   w.lastPanicAt,
+			Healthy:       s.opts.MaxCrashes <= 0 || recent < s.opts.MaxCrashes,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// Healthy returns false if any of the workers crashed too often recently.
+func (s *Supervisor) Healthy() bool {
+	for _, h := range s.Health() {
+		if !h.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Supervisor) supervise(w *workerState) {
+	defer s.wg.Done()
+	defer s.setStopped(w)
+
+	backoff := s.opts.InitialBackoff
+	for {
+		started := s.now()
+		perr := run(w.name, w.worker)
+		if perr == nil {
+			return
+		}
+
+		s.logFunc(perr)
+		s.crashed(w, perr)
+
+		if s.now().Sub(started) > s.opts.MaxBackoff {
+			backoff = s.opts.InitialBackoff
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > s.opts.MaxBackoff {
+			backoff = s.opts.MaxBackoff
+		}
+
+		s.lock.Lock()
+		w.restarts++
+		s.lock.Unlock()
+	}
+}
+
+func (s *Supervisor) crashed(w *workerState, perr *PanicError) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+	w.lastPanic = fmt.Sprint(perr.Value)
+	w.lastPanicAt = now
+
+	crashes := w.crashes[:0]
+	for _, c := range w.crashes {
+		if now.Sub(c) <= s.opts.CrashWindow {
+			crashes = append(crashes, c)
+		}
+	}
+	w.crashes = append(crashes, now)
+}
+
+func (s *Supervisor) setStopped(w *workerState) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	w.running = false
+}
+
+func run(name string, w Worker) (perr *PanicError) {
+	defer func() {
+		if r := recover(); r != nil {
+			perr = &PanicError{Worker: name, Value: r, Stack: debug.Stack()}
+		}
+	}()
+	w.Run()
+	return nil
+}
+
+type funcWorker func()
+
+func (f funcWorker) Run() {
+	f()
+}
+
+func (f funcWorker) Stop() {}
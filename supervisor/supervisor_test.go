@@ -0,0 +1,86 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package supervisor
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type crashingWorker struct {
+	lock    sync.Mutex
+	crashes int
+	runs    int
+	stop    chan struct{}
+	once    sync.Once
+}
+
+func (cw *crashingWorker) Run() {
+	cw.lock.Lock()
+	cw.runs++
+	crash := cw.runs <= cw.crashes
+	cw.lock.Unlock()
+
+	if crash {
+		panic("boom")
+	}
+	<-cw.stop
+}
+
+func (cw *crashingWorker) Stop() {
+	cw.once.Do(func() {
+		close(cw.stop)
+	})
+}
+
+func TestSupervisorRestartsPanickingWorkers(t *testing.T) {
+	s := New(Opts{InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, CrashWindow: time.Minute, MaxCrashes: 2})
+	panics := make(chan *PanicError, 10)
+	s.AttachLogFunc(func(pe *PanicError) { panics <- pe })
+
+	w := &crashingWorker{crashes: 2, stop: make(chan struct{})}
+	s.Supervise("crashing", w)
+	s.Go("returning", func() {})
+
+	assert.Eventually(t, func() bool {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		return w.runs == 3
+	}, time.Second, time.Millisecond)
+
+	pe := <-panics
+	assert.Equal(t, "crashing", pe.Worker)
+	assert.Equal(t, "boom", pe.Value)
+	assert.NotEmpty(t, pe.Stack)
+
+	health := s.Health()
+	assert.Len(t, health, 2)
+	assert.Equal(t, "crashing", health[0].Name)
+	assert.True(t, health[0].Running)
+	assert.Equal(t, 2, health[0].Restarts)
+	assert.Equal(t, 2, health[0].RecentCrashes)
+	assert.Equal(t, "boom", health[0].LastPanic)
+	assert.False(t, health[0].Healthy)
+	assert.False(t, s.Healthy())
+
+	s.Stop()
+	assert.False(t, s.Health()[0].Running)
+	assert.False(t, s.Health()[1].Running)
+}
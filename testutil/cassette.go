@@ -0,0 +1,299 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ErrNoInteraction is returned when a replayed request was never recorded.
+var ErrNoInteraction = errors.New("no recorded interaction")
+
+// Interaction is a single recorded JSON-RPC call.
+type Interaction struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+// Cassette holds recorded JSON-RPC interactions in the order they were made.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadCassette reads a cassette file.
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read cassette: %w", err)
+	}
+
+	var c Cassette
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("could not decode cassette: %w", err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to a file.
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode cassette: %w", err)
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+type rpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+// Recorder is a http round tripper that records the JSON-RPC traffic passing through it.
+type Recorder struct {
+	next http.RoundTripper
+
+	lock     sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder returns a new recorder that forwards requests to the given round tripper.
+// If next is nil, the default transport is used.
+func NewRecorder(next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{next: next}
+}
+
+// RoundTrip forwards the request and records the calls and their responses.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	reqs, _, err := decodeBatch(body, &[]rpcRequest{})
+	if err != nil {
+		return resp, nil
+	}
+	resps, _, err := decodeBatch(respBody, &[]rpcResponse{})
+	if err != nil {
+		return resp, nil
+	}
+
+	byID := make(map[string]rpcResponse)
+	for _, res := range *resps.(*[]rpcResponse) {
+		byID[string(res.ID)] = res
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, call := range *reqs.(*[]rpcRequest) {
+		res, ok := byID[string(call.ID)]
+		if !ok {
+			continue
+		}
+		r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+			Method: call.Method,
+			Params: canonicalJSON(call.Params),
+			Result: res.Result,
+			Error:  res.Error,
+		})
+	}
+
+	return resp, nil
+}
+
+// Cassette returns a copy of the recorded interactions.
+func (r *Recorder) Cassette() *Cassette {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return &Cassette{Interactions: append([]Interaction(nil), r.cassette.Interactions...)}
+}
+
+// Replayer is a http round tripper that answers JSON-RPC calls from a cassette without touching the network.
+// Calls are matched by method and params. Repeated calls are answered in the recorded order,
+// the last recorded answer is repeated once they run out.
+type Replayer struct {
+	lock    sync.Mutex
+	answers map[string][]Interaction
+}
+
+// NewReplayer returns a new replayer for the given cassette.
+func NewReplayer(c *Cassette) *Replayer {
+	answers := make(map[string][]Interaction)
+	for _, in := range c.Interactions {
+		key := interactionKey(in.Method, in.Params)
+		answers[key] = append(answers[key], in)
+	}
+	return &Replayer{answers: answers}
+}
+
+// RoundTrip answers the request from the cassette.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	reqs, batch, err := decodeBatch(body, &[]rpcRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("could not decode request: %w", err)
+	}
+
+	var resps []rpcResponse
+	for _, call := range *reqs.(*[]rpcRequest) {
+		in, err := r.next(call)
+		if err != nil {
+			return nil, err
+		}
+		resps = append(resps, rpcResponse{Version: "2.0", ID: call.ID, Result: in.Result, Error: in.Error})
+	}
+
+	var out interface{} = resps
+	if !batch {
+		out = resps[0]
+	}
+	respBody, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     http.StatusText(http.StatusOK),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(respBody)),
+		Request:    req,
+	}, nil
+}
+
+func (r *Replayer) next(call rpcRequest) (Interaction, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := interactionKey(call.Method, canonicalJSON(call.Params))
+	answers := r.answers[key]
+	if len(answers) == 0 {
+		return Interaction{}, fmt.Errorf("%w: %v %s", ErrNoInteraction, call.Method, call.Params)
+	}
+	if len(answers) > 1 {
+		r.answers[key] = answers[1:]
+	}
+	return answers[0], nil
+}
+
+// CassetteClient is an ethereum client backed by a replayer.
+// It can be passed to client.NewBlockchain in place of a live connection.
+type CassetteClient struct {
+	client *ethclient.Client
+}
+
+// NewCassetteClient returns a new ethereum client that replays the given cassette file.
+func NewCassetteClient(path string) (*CassetteClient, error) {
+	c, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := rpc.DialHTTPWithClient("http://cassette", &http.Client{Transport: NewReplayer(c)})
+	if err != nil {
+		return nil, err
+	}
+	return &CassetteClient{client: ethclient.NewClient(rc)}, nil
+}
+
+// Client returns the ethereum client.
+func (cc *CassetteClient) Client() *ethclient.Client {
+	return cc.client
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// decodeBatch decodes either a single JSON-RPC message or a batch into the given slice pointer.
+func decodeBatch(body []byte, into interface{}) (interface{}, bool, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return into, true, json.Unmarshal(trimmed, into)
+	}
+
+	wrapped := append(append([]byte{'['}, trimmed...), ']')
+	return into, false, json.Unmarshal(wrapped, into)
+}
+
+func interactionKey(method string, params json.RawMessage) string {
+	return method + string(params)
+}
+
+func canonicalJSON(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return raw
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return raw
+	}
+	return b
+}
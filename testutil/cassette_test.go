@@ -0,0 +1,72 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package testutil
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/mysteriumnetwork/payments/client"
+	"github.com/stretchr/testify/assert"
+)
+
+type chainService struct{}
+
+func (chainService) ChainId() string  { return "0x5" }
+func (chainService) GasPrice() string { return "0x3b9aca00" }
+
+func TestRecordReplay(t *testing.T) {
+	srv := rpc.NewServer()
+	assert.NoError(t, srv.RegisterName("eth", chainService{}))
+	defer srv.Stop()
+
+	node := httptest.NewServer(srv)
+	defer node.Close()
+
+	recorder := NewRecorder(nil)
+	rc, err := rpc.DialHTTPWithClient(node.URL, &http.Client{Transport: recorder})
+	assert.NoError(t, err)
+	live := ethclient.NewClient(rc)
+
+	id, err := live.ChainID(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(5), id)
+	_, err = live.SuggestGasPrice(context.Background())
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	assert.NoError(t, recorder.Cassette().Save(path))
+
+	cc, err := NewCassetteClient(path)
+	assert.NoError(t, err)
+
+	bc := client.NewBlockchain(cc, time.Second)
+	price, err := bc.SuggestGasPrice()
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1000000000), price)
+
+	_, err = cc.Client().NetworkID(context.Background())
+	assert.True(t, errors.Is(err, ErrNoInteraction))
+}
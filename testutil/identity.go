@@ -0,0 +1,177 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package testutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/mysteriumnetwork/payments/bindings"
+	pc "github.com/mysteriumnetwork/payments/crypto"
+	"github.com/mysteriumnetwork/payments/registration"
+)
+
+// ErrNoFaucet is returned when funding identities on a backend without an attached faucet.
+var ErrNoFaucet = errors.New("no faucet attached to the simulated backend")
+
+// Contracts are the contracts funded identities are registered with.
+type Contracts struct {
+	Myst     common.Address
+	Registry common.Address
+	Hermes   common.Address
+}
+
+// Identity is a registered identity with a funded account and consumer channel.
+type Identity struct {
+	Key     *ecdsa.PrivateKey
+	Address common.Address
+	// Channel is the consumer channel of the identity with the faucet hermes.
+	Channel common.Address
+	Opts    *bind.TransactOpts
+}
+
+type faucet struct {
+	opts      *bind.TransactOpts
+	contracts Contracts
+	created   uint64
+}
+
+// AttachFaucet sets the account funding the identities created by NewFundedIdentity and the contracts they are registered with.
+// The faucet account has to hold enough eth and myst. Not thread safe, call before creating identities.
+func (sb *SimulatedBackend) AttachFaucet(opts *bind.TransactOpts, contracts Contracts) {
+	sb.faucet = &faucet{opts: opts, contracts: contracts}
+}
+
+// NewFundedIdentity creates an identity, sends it the eth amount, tops up its consumer channel with the myst amount
+// and registers it with the faucet hermes. The backend must have a faucet attached and auto mining enabled.
+// Identity keys are derived from the number of identities created on the backend, so they are the same on every run.
+func NewFundedIdentity(backend *SimulatedBackend, ethAmount, mystAmount *big.Int) (*Identity, error) {
+	f := backend.faucet
+	if f == nil {
+		return nil, ErrNoFaucet
+	}
+
+	f.created++
+	seed := make([]byte, 8)
+	binary.BigEndian.PutUint64(seed, f.created)
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte("testutil identity"), seed))
+	if err != nil {
+		return nil, err
+	}
+
+	id := &Identity{
+		Key:     key,
+		Address: crypto.PubkeyToAddress(key.PublicKey),
+		Opts:    bind.NewKeyedTransactor(key),
+	}
+
+	registry, err := bindings.NewRegistry(f.contracts.Registry, backend)
+	if err != nil {
+		return nil, err
+	}
+	id.Channel, err = registry.GetChannelAddress(nil, id.Address, f.contracts.Hermes)
+	if err != nil {
+		return nil, fmt.Errorf("could not get channel address: %w", err)
+	}
+
+	if err := backend.sendEth(f.opts, id.Address, ethAmount); err != nil {
+		return nil, fmt.Errorf("could not send eth: %w", err)
+	}
+
+	if mystAmount != nil && mystAmount.Sign() > 0 {
+		myst, err := bindings.NewMystToken(f.contracts.Myst, backend)
+		if err != nil {
+			return nil, err
+		}
+		tx, err := myst.Transfer(f.opts, id.Channel, mystAmount)
+		if err := backend.waitMined(tx, err); err != nil {
+			return nil, fmt.Errorf("could not top up channel: %w", err)
+		}
+	}
+
+	signature, err := signRegistration(key, f.contracts, id.Address)
+	if err != nil {
+		return nil, fmt.Errorf("could not sign registration: %w", err)
+	}
+	tx, err := registry.RegisterIdentity(id.Opts, f.contracts.Hermes, big.NewInt(0), big.NewInt(0), id.Address, signature)
+	if err := backend.waitMined(tx, err); err != nil {
+		return nil, fmt.Errorf("could not register identity: %w", err)
+	}
+
+	return id, nil
+}
+
+func signRegistration(key *ecdsa.PrivateKey, contracts Contracts, beneficiary common.Address) ([]byte, error) {
+	req := registration.Request{
+		HermesID:        contracts.Hermes.Hex(),
+		Stake:           big.NewInt(0),
+		Fee:             big.NewInt(0),
+		Beneficiary:     beneficiary.Hex(),
+		RegistryAddress: contracts.Registry.Hex(),
+	}
+
+	signature, err := crypto.Sign(crypto.Keccak256(req.GetMessage()), key)
+	if err != nil {
+		return nil, err
+	}
+	return signature, pc.ReformatSignatureVForBC(signature)
+}
+
+func (sb *SimulatedBackend) sendEth(opts *bind.TransactOpts, to common.Address, amount *big.Int) error {
+	if amount == nil || amount.Sign() == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	nonce, err := sb.PendingNonceAt(ctx, opts.From)
+	if err != nil {
+		return err
+	}
+	gasPrice, err := sb.SuggestGasPrice(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := opts.Signer(types.HomesteadSigner{}, opts.From, types.NewTransaction(nonce, to, amount, 21000, gasPrice, nil))
+	if err != nil {
+		return err
+	}
+	return sb.waitMined(tx, sb.SendTransaction(ctx, tx))
+}
+
+func (sb *SimulatedBackend) waitMined(tx *types.Transaction, err error) error {
+	if err != nil {
+		return err
+	}
+
+	receipt, err := sb.TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		return err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return errors.New("transaction failed")
+	}
+	return nil
+}
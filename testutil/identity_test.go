@@ -0,0 +1,70 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package testutil_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/mysteriumnetwork/payments/deploy"
+	"github.com/mysteriumnetwork/payments/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFundedIdentity(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	opts := bind.NewKeyedTransactor(key)
+	backend := testutil.NewSimulatedBackend(core.GenesisAlloc{opts.From: {Balance: big.NewInt(0).Exp(big.NewInt(10), big.NewInt(20), nil)}}, 10000000)
+	defer backend.Close()
+
+	_, err := testutil.NewFundedIdentity(backend, big.NewInt(1), big.NewInt(1))
+	assert.Equal(t, testutil.ErrNoFaucet, err)
+
+	suite, err := deploy.NewDeployer(backend, opts, time.Second*5).Deploy(deploy.DefaultParams())
+	assert.NoError(t, err)
+	backend.AttachFaucet(opts, testutil.Contracts{Myst: suite.Myst, Registry: suite.Registry, Hermes: suite.Hermes})
+
+	eth, myst := big.NewInt(1e18), big.NewInt(5e18)
+	id, err := testutil.NewFundedIdentity(backend, eth, myst)
+	assert.NoError(t, err)
+
+	registry, err := bindings.NewRegistryCaller(suite.Registry, backend)
+	assert.NoError(t, err)
+	registered, err := registry.IsRegistered(nil, id.Address)
+	assert.NoError(t, err)
+	assert.True(t, registered)
+
+	balance, err := backend.BalanceAt(context.Background(), id.Address, nil)
+	assert.NoError(t, err)
+	assert.True(t, balance.Sign() > 0 && balance.Cmp(eth) < 0)
+
+	token, err := bindings.NewMystTokenCaller(suite.Myst, backend)
+	assert.NoError(t, err)
+	channelBalance, err := token.BalanceOf(nil, id.Channel)
+	assert.NoError(t, err)
+	assert.Equal(t, myst, channelBalance)
+
+	other, err := testutil.NewFundedIdentity(backend, eth, nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, id.Address, other.Address)
+}
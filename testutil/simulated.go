@@ -0,0 +1,150 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package testutil contains helpers for testing against a simulated blockchain.
+package testutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// simulatedBlockTime is the fixed time between blocks mined by the simulated backend.
+const simulatedBlockTime = 10 * time.Second
+
+// ErrTimestampInPast is returned when the requested block timestamp is not after the latest block.
+var ErrTimestampInPast = errors.New("timestamp must be after the latest block")
+
+// SimulatedBackend wraps the go-ethereum simulated backend with block time control.
+// Time shifts apply to the next mined block and carry over to all the following blocks.
+type SimulatedBackend struct {
+	*backends.SimulatedBackend
+
+	lock     sync.Mutex
+	autoMine bool
+	offset   time.Duration
+
+	faucet *faucet
+}
+
+// NewSimulatedBackend returns a new simulated backend with auto mining enabled.
+func NewSimulatedBackend(alloc core.GenesisAlloc, gasLimit uint64) *SimulatedBackend {
+	return &SimulatedBackend{
+		SimulatedBackend: backends.NewSimulatedBackend(alloc, gasLimit),
+		autoMine:         true,
+	}
+}
+
+// SetAutoMine toggles mining a block after every sent transaction.
+// With auto mining disabled, blocks are only mined by calling Commit or Mine.
+func (sb *SimulatedBackend) SetAutoMine(enabled bool) {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+	sb.autoMine = enabled
+}
+
+// SendTransaction sends the transaction and mines it if auto mining is enabled.
+func (sb *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	if err := sb.SimulatedBackend.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+
+	if sb.autoMine {
+		sb.commit()
+	}
+	return nil
+}
+
+// Commit mines the pending transactions into a new block.
+func (sb *SimulatedBackend) Commit() {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+	sb.commit()
+}
+
+func (sb *SimulatedBackend) commit() {
+	sb.SimulatedBackend.Commit()
+	sb.offset = 0
+}
+
+// Mine mines the given number of blocks.
+func (sb *SimulatedBackend) Mine(blocks int) {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+	for i := 0; i < blocks; i++ {
+		sb.commit()
+	}
+}
+
+// AdjustTime moves the clock of the next block forward by the given duration.
+// It can only be called while there are no pending transactions.
+func (sb *SimulatedBackend) AdjustTime(d time.Duration) error {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+	return sb.adjustTime(sb.offset + d)
+}
+
+// SetNextBlockTimestamp sets the timestamp of the next mined block.
+// It can only be called while there are no pending transactions.
+func (sb *SimulatedBackend) SetNextBlockTimestamp(t time.Time) error {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	latest := sb.latestTime()
+	if !t.After(latest) {
+		return ErrTimestampInPast
+	}
+	return sb.adjustTime(t.Sub(latest.Add(simulatedBlockTime)))
+}
+
+func (sb *SimulatedBackend) adjustTime(offset time.Duration) error {
+	if offset <= -simulatedBlockTime {
+		return ErrTimestampInPast
+	}
+
+	if err := sb.SimulatedBackend.AdjustTime(offset); err != nil {
+		return err
+	}
+	sb.offset = offset
+	return nil
+}
+
+// LatestTime returns the timestamp of the latest mined block.
+func (sb *SimulatedBackend) LatestTime() time.Time {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+	return sb.latestTime()
+}
+
+// NextBlockTime returns the timestamp the next mined block will have.
+func (sb *SimulatedBackend) NextBlockTime() time.Time {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+	return sb.latestTime().Add(simulatedBlockTime + sb.offset)
+}
+
+func (sb *SimulatedBackend) latestTime() time.Time {
+	return time.Unix(int64(sb.Blockchain().CurrentBlock().Time()), 0)
+}
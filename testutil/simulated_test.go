@@ -0,0 +1,68 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package testutil
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulatedBackendTime(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	sb := NewSimulatedBackend(core.GenesisAlloc{from: {Balance: big.NewInt(1e18)}}, 8000000)
+	defer sb.Close()
+
+	start := sb.LatestTime()
+	assert.NoError(t, sb.AdjustTime(time.Hour))
+	assert.NoError(t, sb.AdjustTime(time.Hour))
+	sb.Commit()
+	assert.Equal(t, start.Add(2*time.Hour+simulatedBlockTime), sb.LatestTime())
+
+	next := sb.LatestTime().Add(24 * time.Hour)
+	assert.NoError(t, sb.SetNextBlockTimestamp(next))
+	assert.Equal(t, next, sb.NextBlockTime())
+	sb.Mine(1)
+	assert.Equal(t, next, sb.LatestTime())
+
+	assert.Equal(t, ErrTimestampInPast, sb.SetNextBlockTimestamp(next))
+
+	sb.SetAutoMine(false)
+	signer := types.HomesteadSigner{}
+	tx, err := types.SignTx(types.NewTransaction(0, common.HexToAddress("0x1"), big.NewInt(1), 21000, big.NewInt(1), nil), signer, key)
+	assert.NoError(t, err)
+	assert.NoError(t, sb.SendTransaction(context.Background(), tx))
+
+	_, pending, err := sb.TransactionByHash(context.Background(), tx.Hash())
+	assert.NoError(t, err)
+	assert.True(t, pending)
+	assert.Error(t, sb.AdjustTime(time.Hour))
+
+	sb.SetAutoMine(true)
+	sb.Commit()
+	_, pending, err = sb.TransactionByHash(context.Background(), tx.Hash())
+	assert.NoError(t, err)
+	assert.False(t, pending)
+}
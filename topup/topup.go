@@ -0,0 +1,136 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package topup attributes myst transfers into consumer channels to the addresses that paid them,
+// so payment processors can reconcile on-ramp orders with channel top-ups.
+package topup
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mysteriumnetwork/payments/bindings"
+	"github.com/mysteriumnetwork/payments/client"
+)
+
+// TransferTopic is the topic of the ERC-20 Transfer(address,address,uint256) event.
+var TransferTopic = common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+
+// TopUp is a myst transfer into a consumer channel.
+type TopUp struct {
+	ChainID int64
+	Channel common.Address
+	// Payer is the sender of the transfer.
+	Payer       common.Address
+	Amount      *big.Int
+	TxHash      common.Hash
+	BlockNumber uint64
+	LogIndex    uint
+}
+
+// Storage persists the top-ups.
+type Storage interface {
+	// InsertTopUp stores the top-up, top-ups of an already stored log are ignored.
+	InsertTopUp(t TopUp) error
+	// DeleteTopUp removes the top-up of the log, e.g. after a reorg.
+	DeleteTopUp(chainID int64, txHash common.Hash, logIndex uint) error
+	// GetTopUps returns the top-ups of the channel, oldest first.
+	GetTopUps(chainID int64, channel common.Address) ([]TopUp, error)
+}
+
+// Tracker records the top-ups of consumer channels out of myst token Transfer logs.
+type Tracker struct {
+	chainID  int64
+	token    common.Address
+	storage  Storage
+	filterer *bindings.MystTokenFilterer
+}
+
+// NewTracker returns a new top-up tracker of the given chain and myst token.
+func NewTracker(chainID int64, token common.Address, storage Storage) (*Tracker, error) {
+	filterer, err := bindings.NewMystTokenFilterer(token, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Tracker{
+		chainID:  chainID,
+		token:    token,
+		storage:  storage,
+		filterer: filterer,
+	}, nil
+}
+
+// Query returns the filter of the myst transfers into the given channels.
+// It is meant for a named log stream, so no top-up is missed across restarts.
+func (t *Tracker) Query(channels ...common.Address) ethereum.FilterQuery {
+	to := make([]common.Hash, len(channels))
+	for i := range channels {
+		to[i] = common.BytesToHash(channels[i].Bytes())
+	}
+	return ethereum.FilterQuery{
+		Addresses: []common.Address{t.token},
+		Topics:    [][]common.Hash{{TransferTopic}, nil, to},
+	}
+}
+
+// Handle records the top-up of the Transfer log. Removed logs delete the top-up recorded before.
+// Logs of other contracts and events are ignored.
+func (t *Tracker) Handle(l types.Log) error {
+	if l.Address != t.token || len(l.Topics) == 0 || l.Topics[0] != TransferTopic {
+		return nil
+	}
+
+	if l.Removed {
+		return t.storage.DeleteTopUp(t.chainID, l.TxHash, l.Index)
+	}
+
+	transfer, err := t.filterer.ParseTransfer(l)
+	if err != nil {
+		return fmt.Errorf("could not parse transfer: %w", err)
+	}
+
+	return t.storage.InsertTopUp(TopUp{
+		ChainID:     t.chainID,
+		Channel:     transfer.To,
+		Payer:       transfer.From,
+		Amount:      transfer.Value,
+		TxHash:      l.TxHash,
+		BlockNumber: l.BlockNumber,
+		LogIndex:    l.Index,
+	})
+}
+
+// Consume handles the logs of the stream until it is closed, committing every handled log of a named stream.
+func (t *Tracker) Consume(stream *client.LogStream) error {
+	for l := range stream.Logs() {
+		if err := t.Handle(l); err != nil {
+			return err
+		}
+		if err := stream.Commit(l); err != nil && !errors.Is(err, client.ErrUnnamedLogStream) {
+			return fmt.Errorf("could not commit log stream offset: %w", err)
+		}
+	}
+	return nil
+}
+
+// TopUpHistory returns the top-ups of the channel, oldest first.
+func (t *Tracker) TopUpHistory(channel common.Address) ([]TopUp, error) {
+	return t.storage.GetTopUps(t.chainID, channel)
+}
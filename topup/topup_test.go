@@ -0,0 +1,111 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package topup
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type topUpKey struct {
+	txHash   common.Hash
+	logIndex uint
+}
+
+type mockStorage struct {
+	topUps map[topUpKey]TopUp
+	order  []topUpKey
+}
+
+func (ms *mockStorage) InsertTopUp(t TopUp) error {
+	key := topUpKey{t.TxHash, t.LogIndex}
+	if _, ok := ms.topUps[key]; ok {
+		return nil
+	}
+	ms.topUps[key] = t
+	ms.order = append(ms.order, key)
+	return nil
+}
+
+func (ms *mockStorage) DeleteTopUp(chainID int64, txHash common.Hash, logIndex uint) error {
+	delete(ms.topUps, topUpKey{txHash, logIndex})
+	return nil
+}
+
+func (ms *mockStorage) GetTopUps(chainID int64, channel common.Address) ([]TopUp, error) {
+	var res []TopUp
+	for _, key := range ms.order {
+		if t, ok := ms.topUps[key]; ok && t.Channel == channel {
+			res = append(res, t)
+		}
+	}
+	return res, nil
+}
+
+func transferLog(token, from, to common.Address, amount int64, tx byte, index uint) types.Log {
+	return types.Log{
+		Address:     token,
+		Topics:      []common.Hash{TransferTopic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+		Data:        common.LeftPadBytes(big.NewInt(amount).Bytes(), 32),
+		TxHash:      common.Hash{tx},
+		BlockNumber: 10,
+		Index:       index,
+	}
+}
+
+func TestTracker(t *testing.T) {
+	token, channel, payer := common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")
+	storage := &mockStorage{topUps: make(map[topUpKey]TopUp)}
+	tracker, err := NewTracker(5, token, storage)
+	assert.NoError(t, err)
+
+	first := transferLog(token, payer, channel, 100, 1, 0)
+	assert.NoError(t, tracker.Handle(first))
+	assert.NoError(t, tracker.Handle(first))
+	assert.NoError(t, tracker.Handle(transferLog(token, payer, common.HexToAddress("0x4"), 7, 2, 0)))
+	assert.NoError(t, tracker.Handle(transferLog(common.HexToAddress("0x9"), payer, channel, 7, 3, 0)), "other tokens are ignored")
+
+	second := transferLog(token, common.HexToAddress("0x5"), channel, 50, 4, 2)
+	assert.NoError(t, tracker.Handle(second))
+
+	history, err := tracker.TopUpHistory(channel)
+	assert.NoError(t, err)
+	assert.Equal(t, []TopUp{
+		{ChainID: 5, Channel: channel, Payer: payer, Amount: big.NewInt(100), TxHash: common.Hash{1}, BlockNumber: 10, LogIndex: 0},
+		{ChainID: 5, Channel: channel, Payer: common.HexToAddress("0x5"), Amount: big.NewInt(50), TxHash: common.Hash{4}, BlockNumber: 10, LogIndex: 2},
+	}, history)
+
+	second.Removed = true
+	assert.NoError(t, tracker.Handle(second))
+	history, err = tracker.TopUpHistory(channel)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+}
+
+func TestTrackerQuery(t *testing.T) {
+	token, channel := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	tracker, err := NewTracker(5, token, nil)
+	assert.NoError(t, err)
+
+	q := tracker.Query(channel)
+	assert.Equal(t, []common.Address{token}, q.Addresses)
+	assert.Equal(t, [][]common.Hash{{TransferTopic}, nil, {common.BytesToHash(channel.Bytes())}}, q.Topics)
+}
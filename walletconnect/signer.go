@@ -0,0 +1,178 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package walletconnect allows signing payment messages and transactions with a wallet connected over WalletConnect v2.
+// The library does not manage the relay connection itself, it only needs a paired session to forward JSON-RPC requests to.
+package walletconnect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/signer/core"
+)
+
+// Session is a paired WalletConnect v2 session.
+// Request forwards a JSON-RPC request to the wallet for the given CAIP-2 chain ("eip155:<chainID>") and returns the raw result.
+type Session interface {
+	Request(ctx context.Context, chain string, method string, params interface{}) (json.RawMessage, error)
+}
+
+// ErrSignerMismatch is returned when the wallet signed with an account other than the one requested.
+var ErrSignerMismatch = errors.New("wallet signed with an unexpected account")
+
+// Signer signs hashes, typed data and transactions using a wallet connected via WalletConnect.
+// Hashes are signed as personal messages, see SignHash.
+type Signer struct {
+	session Session
+	chainID int64
+	timeout time.Duration
+}
+
+// NewSigner returns a new WalletConnect backed signer.
+// The timeout should be generous as every request waits for the user to confirm it on the wallet.
+func NewSigner(session Session, chainID int64, timeout time.Duration) *Signer {
+	return &Signer{
+		session: session,
+		chainID: chainID,
+		timeout: timeout,
+	}
+}
+
+func (s *Signer) chain() string {
+	return fmt.Sprintf("eip155:%d", s.chainID)
+}
+
+func (s *Signer) request(method string, params interface{}, result interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	res, err := s.session.Request(ctx, s.chain(), method, params)
+	if err != nil {
+		return fmt.Errorf("wallet rejected %v: %w", method, err)
+	}
+
+	if err := json.Unmarshal(res, result); err != nil {
+		return fmt.Errorf("could not decode %v result: %w", method, err)
+	}
+	return nil
+}
+
+// SignHash asks the wallet to sign the given hash using personal_sign.
+// Wallets do not sign raw hashes, the signature is over the personal message hash of it,
+// keccak256("\x19Ethereum Signed Message:\n32" ‖ hash), so it only verifies where the verifier applies the same prefix.
+// The returned signature has V normalized to 0 or 1 and is checked to be created by the given account.
+func (s *Signer) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
+	var sig hexutil.Bytes
+	if err := s.request("personal_sign", []interface{}{hexutil.Bytes(hash), a.Address}, &sig); err != nil {
+		return nil, err
+	}
+
+	if len(sig) != 65 {
+		return nil, fmt.Errorf("wallet returned a signature of invalid length %v", len(sig))
+	}
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+
+	pub, err := crypto.SigToPub(accounts.TextHash(hash), sig)
+	if err != nil {
+		return nil, fmt.Errorf("could not recover signer: %w", err)
+	}
+	if crypto.PubkeyToAddress(*pub) != a.Address {
+		return nil, ErrSignerMismatch
+	}
+
+	return sig, nil
+}
+
+// SignTypedData asks the wallet to sign the given EIP-712 typed data using eth_signTypedData_v4.
+func (s *Signer) SignTypedData(a accounts.Account, typedData core.TypedData) ([]byte, error) {
+	payload, err := json.Marshal(typedData)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal typed data: %w", err)
+	}
+
+	var sig hexutil.Bytes
+	if err := s.request("eth_signTypedData_v4", []interface{}{a.Address, string(payload)}, &sig); err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
+
+type sendTxArgs struct {
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to,omitempty"`
+	Gas      hexutil.Uint64  `json:"gas"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	Value    *hexutil.Big    `json:"value"`
+	Nonce    hexutil.Uint64  `json:"nonce"`
+	Data     hexutil.Bytes   `json:"data"`
+}
+
+// SignTx asks the wallet to sign the given transaction using eth_signTransaction.
+// The signed transaction is checked to be the same as the one requested.
+func (s *Signer) SignTx(signer types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	args := sendTxArgs{
+		From:     address,
+		To:       tx.To(),
+		Gas:      hexutil.Uint64(tx.Gas()),
+		GasPrice: (*hexutil.Big)(tx.GasPrice()),
+		Value:    (*hexutil.Big)(tx.Value()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+		Data:     tx.Data(),
+	}
+
+	var raw hexutil.Bytes
+	if err := s.request("eth_signTransaction", []interface{}{args}, &raw); err != nil {
+		return nil, err
+	}
+
+	signed := new(types.Transaction)
+	if err := rlp.DecodeBytes(raw, signed); err != nil {
+		return nil, fmt.Errorf("could not decode signed transaction: %w", err)
+	}
+
+	if signer.Hash(signed) != signer.Hash(tx) {
+		return nil, errors.New("wallet signed a different transaction")
+	}
+
+	from, err := types.Sender(signer, signed)
+	if err != nil {
+		return nil, fmt.Errorf("could not recover transaction sender: %w", err)
+	}
+	if from != address {
+		return nil, ErrSignerMismatch
+	}
+
+	return signed, nil
+}
+
+// SignerFn returns the signer as a bind.SignerFn that can be used in write requests.
+func (s *Signer) SignerFn() bind.SignerFn {
+	return s.SignTx
+}
@@ -0,0 +1,106 @@
+/* Mysterium network payment library.
+ *
+ * Copyright (C) 2021 BlockDev AG
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package walletconnect
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/assert"
+)
+
+type walletMock struct {
+	key     *ecdsa.PrivateKey
+	chain   string
+	methods []string
+}
+
+func (w *walletMock) Request(ctx context.Context, chain string, method string, params interface{}) (json.RawMessage, error) {
+	w.chain = chain
+	w.methods = append(w.methods, method)
+
+	switch method {
+	case "personal_sign":
+		// wallets sign the prefixed message and return V as 27 or 28.
+		data := params.([]interface{})[0].(hexutil.Bytes)
+		sig, err := crypto.Sign(accounts.TextHash(data), w.key)
+		if err != nil {
+			return nil, err
+		}
+		sig[64] += 27
+		return json.Marshal(hexutil.Bytes(sig))
+	case "eth_signTransaction":
+		args := params.([]interface{})[0].(sendTxArgs)
+		tx := types.NewTransaction(uint64(args.Nonce), *args.To, args.Value.ToInt(), uint64(args.Gas), args.GasPrice.ToInt(), args.Data)
+		signed, err := types.SignTx(tx, types.NewEIP155Signer(big.NewInt(5)), w.key)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := rlp.EncodeToBytes(signed)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(hexutil.Bytes(raw))
+	}
+	return nil, nil
+}
+
+func TestSigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	wallet := &walletMock{key: key}
+	s := NewSigner(wallet, 5, time.Second)
+
+	t.Run("signs hash", func(t *testing.T) {
+		hash := crypto.Keccak256([]byte("promise"))
+		sig, err := s.SignHash(accounts.Account{Address: addr}, hash)
+		assert.NoError(t, err)
+		assert.Equal(t, "eip155:5", wallet.chain)
+
+		assert.True(t, sig[64] == 0 || sig[64] == 1)
+
+		pub, err := crypto.SigToPub(accounts.TextHash(hash), sig)
+		assert.NoError(t, err)
+		assert.Equal(t, addr, crypto.PubkeyToAddress(*pub))
+
+		_, err = s.SignHash(accounts.Account{Address: common.HexToAddress("0x2")}, hash)
+		assert.Equal(t, ErrSignerMismatch, err)
+	})
+
+	t.Run("signs transaction", func(t *testing.T) {
+		tx := types.NewTransaction(1, common.HexToAddress("0x1"), big.NewInt(10), 21000, big.NewInt(1), nil)
+		signer := types.NewEIP155Signer(big.NewInt(5))
+
+		signed, err := s.SignerFn()(signer, addr, tx)
+		assert.NoError(t, err)
+		assert.Equal(t, signer.Hash(tx), signer.Hash(signed))
+
+		_, err = s.SignerFn()(signer, common.HexToAddress("0x2"), tx)
+		assert.Equal(t, ErrSignerMismatch, err)
+	})
+}